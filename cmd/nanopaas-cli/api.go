@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// apiClient talks to the NanoPaaS HTTP API
+type apiClient struct {
+	baseURL *url.URL
+	token   string
+	http    *http.Client
+}
+
+// appRef identifies an app by ID and display name
+type appRef struct {
+	ID   string
+	Name string
+}
+
+// newAPIClient creates a client from NANOPAAS_URL and NANOPAAS_TOKEN
+func newAPIClient() (*apiClient, error) {
+	raw := os.Getenv("NANOPAAS_URL")
+	if raw == "" {
+		raw = "http://localhost:8080"
+	}
+	base, err := url.Parse(strings.TrimRight(raw, "/"))
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") {
+		return nil, fmt.Errorf("invalid NANOPAAS_URL %q", raw)
+	}
+
+	return &apiClient{
+		baseURL: base,
+		token:   os.Getenv("NANOPAAS_TOKEN"),
+		http:    &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// getJSON performs an authenticated GET and decodes the JSON response
+func (c *apiClient) getJSON(ctx context.Context, path string, query url.Values, out interface{}) error {
+	u := *c.baseURL
+	u.Path += path
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&apiErr)
+		if apiErr.Error == "" {
+			apiErr.Error = resp.Status
+		}
+		return fmt.Errorf("GET %s: %s", path, apiErr.Error)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// resolveApps maps slugs or IDs to app references
+func (c *apiClient) resolveApps(ctx context.Context, refs []string) ([]appRef, error) {
+	var apps []struct {
+		ID   string `json:"id"`
+		Slug string `json:"slug"`
+	}
+	listErr := c.getJSON(ctx, "/api/v1/apps", nil, &apps)
+
+	resolved := make([]appRef, 0, len(refs))
+	for _, ref := range refs {
+		found := false
+		for _, app := range apps {
+			if app.Slug == ref || app.ID == ref {
+				resolved = append(resolved, appRef{ID: app.ID, Name: app.Slug})
+				found = true
+				break
+			}
+		}
+		if found {
+			continue
+		}
+
+		// Fall back to raw IDs so following works without a token
+		if _, err := uuid.Parse(ref); err == nil {
+			resolved = append(resolved, appRef{ID: ref, Name: ref[:8]})
+			continue
+		}
+		if listErr != nil {
+			return nil, fmt.Errorf("cannot resolve app %q: %w", ref, listErr)
+		}
+		return nil, fmt.Errorf("app %q not found", ref)
+	}
+	return resolved, nil
+}
+
+// wsURL returns the WebSocket URL for a path on the API host
+func (c *apiClient) wsURL(path string, query url.Values) string {
+	u := *c.baseURL
+	u.Scheme = "ws"
+	if c.baseURL.Scheme == "https" {
+		u.Scheme = "wss"
+	}
+	u.Path += path
+	u.RawQuery = query.Encode()
+	return u.String()
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/url"
+	"os"
+	"os/signal"
+	"regexp"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	minReconnectDelay = 1 * time.Second
+	maxReconnectDelay = 30 * time.Second
+)
+
+// ANSI colors used for app and replica prefixes
+var palette = []string{"\033[36m", "\033[33m", "\033[32m", "\033[35m", "\033[34m", "\033[91m", "\033[96m", "\033[93m"}
+
+const (
+	colorReset  = "\033[0m"
+	colorDim    = "\033[2m"
+	colorStderr = "\033[31m"
+)
+
+// logsOptions holds parsed flags for the logs command
+type logsOptions struct {
+	follow  bool
+	since   string
+	tail    string
+	grep    *regexp.Regexp
+	noColor bool
+}
+
+// logMessage is a message from the log WebSocket or an entry from the HTTP logs endpoint
+type logMessage struct {
+	Type          string    `json:"type"`
+	AppID         string    `json:"app_id"`
+	ContainerID   string    `json:"container_id"`
+	ContainerName string    `json:"container_name"`
+	Stream        string    `json:"stream"`
+	Content       string    `json:"content"`
+	Message       string    `json:"message"`
+	Timestamp     time.Time `json:"timestamp"`
+	Error         string    `json:"error"`
+	Hint          string    `json:"hint"`
+}
+
+// runLogs implements `nanopaas logs [-f] [--since 10m] [--tail N] [--grep RE] app...`
+func runLogs(args []string) error {
+	fs := flag.NewFlagSet("logs", flag.ContinueOnError)
+	opts := logsOptions{}
+	var grep string
+	fs.BoolVar(&opts.follow, "f", false, "follow log output, reconnecting across restarts")
+	fs.BoolVar(&opts.follow, "follow", false, "follow log output, reconnecting across restarts")
+	fs.StringVar(&opts.since, "since", "", "show logs since a duration (10m) or RFC3339 time")
+	fs.StringVar(&opts.tail, "tail", "", "number of lines per container to show initially")
+	fs.StringVar(&grep, "grep", "", "only show lines matching this regular expression")
+	fs.BoolVar(&opts.noColor, "no-color", false, "disable colored output")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: nanopaas logs [-f] [--since 10m] [--tail N] [--grep ERROR] app [app...]")
+		fs.PrintDefaults()
+	}
+
+	// Allow flags before, between and after app names
+	var refs []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		refs = append(refs, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(refs) == 0 {
+		fs.Usage()
+		return errors.New("at least one app is required")
+	}
+
+	if grep != "" {
+		re, err := regexp.Compile(grep)
+		if err != nil {
+			return fmt.Errorf("invalid --grep: %w", err)
+		}
+		opts.grep = re
+	}
+	if opts.tail != "" && opts.tail != "all" {
+		if _, err := strconv.Atoi(opts.tail); err != nil {
+			return fmt.Errorf("--tail must be a number or \"all\"")
+		}
+	}
+	if fi, err := os.Stdout.Stat(); err == nil && fi.Mode()&os.ModeCharDevice == 0 {
+		opts.noColor = true
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	client, err := newAPIClient()
+	if err != nil {
+		return err
+	}
+	apps, err := client.resolveApps(ctx, refs)
+	if err != nil {
+		return err
+	}
+
+	out := &logPrinter{w: os.Stdout, opts: opts, multi: len(apps) > 1}
+	for i := range apps {
+		out.colors = append(out.colors, palette[i%len(palette)])
+	}
+
+	if !opts.follow {
+		for i, app := range apps {
+			if err := printRecentLogs(ctx, client, app, i, opts, out); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	for i, app := range apps {
+		wg.Add(1)
+		go func(i int, app appRef) {
+			defer wg.Done()
+			followApp(ctx, client, app, i, opts, out)
+		}(i, app)
+	}
+	wg.Wait()
+	return nil
+}
+
+// printRecentLogs fetches logs over HTTP without following
+func printRecentLogs(ctx context.Context, client *apiClient, app appRef, index int, opts logsOptions, out *logPrinter) error {
+	query := url.Values{}
+	if opts.tail != "" {
+		query.Set("tail", opts.tail)
+	}
+	if opts.since != "" {
+		query.Set("since", opts.since)
+	}
+
+	var resp struct {
+		Logs []struct {
+			ContainerID   string    `json:"container_id"`
+			ContainerName string    `json:"container_name"`
+			Stream        string    `json:"stream"`
+			Message       string    `json:"message"`
+			Timestamp     time.Time `json:"timestamp"`
+		} `json:"logs"`
+	}
+	if err := client.getJSON(ctx, "/api/v1/apps/"+app.ID+"/logs", query, &resp); err != nil {
+		return err
+	}
+
+	for _, entry := range resp.Logs {
+		out.print(app, index, logMessage{
+			ContainerID:   entry.ContainerID,
+			ContainerName: entry.ContainerName,
+			Stream:        entry.Stream,
+			Content:       entry.Message,
+			Timestamp:     entry.Timestamp,
+		})
+	}
+	return nil
+}
+
+// followApp tails an app, reconnecting with backoff and resuming from the last line seen.
+// The server ends the stream when the app's containers go away (restart, redeploy),
+// and reconnecting picks up the replacement containers.
+func followApp(ctx context.Context, client *apiClient, app appRef, index int, opts logsOptions, out *logPrinter) {
+	resume := &resumePoint{}
+	delay := minReconnectDelay
+	connected := false
+
+	for ctx.Err() == nil {
+		query := url.Values{}
+		switch {
+		case !resume.last.IsZero():
+			query.Set("since", resume.last.Format(time.RFC3339Nano))
+		case opts.since != "":
+			query.Set("since", opts.since)
+		}
+		if opts.tail != "" && resume.last.IsZero() {
+			query.Set("tail", opts.tail)
+		}
+
+		received, err := streamOnce(ctx, client.wsURL("/ws/apps/"+app.ID+"/logs", query), func(msg logMessage) {
+			switch msg.Type {
+			case "log":
+				if resume.seen(msg) {
+					return
+				}
+				out.print(app, index, msg)
+			case "container_exit":
+				replica := msg.ContainerName
+				if replica == "" {
+					replica = msg.ContainerID
+				}
+				out.notice(app, index, replica+" "+msg.Hint)
+			case "no_containers":
+				if connected {
+					out.notice(app, index, "waiting for containers...")
+					connected = false
+				}
+			}
+		})
+		if ctx.Err() != nil {
+			return
+		}
+
+		if received {
+			delay = minReconnectDelay
+			connected = true
+		}
+		if err != nil && connected {
+			out.notice(app, index, fmt.Sprintf("connection lost (%v), reconnecting...", err))
+			connected = false
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+}
+
+// streamOnce reads one WebSocket session, reporting whether any log line arrived
+func streamOnce(ctx context.Context, wsURL string, handle func(logMessage)) (bool, error) {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, wsURL, nil)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	// Unblock ReadJSON when interrupted
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	received := false
+	for {
+		var msg logMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure) || errors.Is(err, io.EOF) {
+				return received, nil
+			}
+			return received, err
+		}
+		if msg.Error != "" {
+			return received, errors.New(msg.Error)
+		}
+		if msg.Type == "stream_end" {
+			return received, nil
+		}
+		if msg.Type == "log" {
+			received = true
+		}
+		handle(msg)
+	}
+}
+
+// resumePoint tracks the newest timestamp printed so reconnects don't repeat lines
+type resumePoint struct {
+	last   time.Time
+	atLast map[string]bool // lines already printed with timestamp == last
+}
+
+// seen records msg and reports whether it was already printed
+func (r *resumePoint) seen(msg logMessage) bool {
+	key := msg.ContainerID + "\x00" + msg.Content
+	switch {
+	case msg.Timestamp.Before(r.last):
+		return true
+	case msg.Timestamp.Equal(r.last):
+		if r.atLast[key] {
+			return true
+		}
+	default:
+		r.last = msg.Timestamp
+		r.atLast = make(map[string]bool)
+	}
+	r.atLast[key] = true
+	return false
+}
+
+// logPrinter writes prefixed, optionally colored lines from concurrent app streams
+type logPrinter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	opts   logsOptions
+	multi  bool
+	colors []string
+}
+
+func (p *logPrinter) print(app appRef, index int, msg logMessage) {
+	if p.opts.grep != nil && !p.opts.grep.MatchString(msg.Content) {
+		return
+	}
+
+	replica := msg.ContainerName
+	if replica == "" {
+		replica = msg.ContainerID
+	}
+	prefix := replica
+	if p.multi {
+		prefix = app.Name + "/" + replica
+	}
+	ts := msg.Timestamp.Local().Format("15:04:05.000")
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.opts.noColor {
+		fmt.Fprintf(p.w, "%s %s | %s\n", ts, prefix, msg.Content)
+		return
+	}
+
+	content := msg.Content
+	if msg.Stream == "stderr" {
+		content = colorStderr + content + colorReset
+	}
+	fmt.Fprintf(p.w, "%s%s%s %s%s%s | %s\n", colorDim, ts, colorReset, replicaColor(p.colors[index], replica), prefix, colorReset, content)
+}
+
+func (p *logPrinter) notice(app appRef, index int, text string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.opts.noColor {
+		fmt.Fprintf(os.Stderr, "[%s] %s\n", app.Name, text)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s[%s] %s%s\n", p.colors[index], app.Name, text, colorReset)
+}
+
+// replicaColor derives a per-replica shade of the app color so replicas stay distinguishable
+func replicaColor(appColor, replica string) string {
+	h := fnv.New32a()
+	h.Write([]byte(replica))
+	if h.Sum32()%2 == 0 {
+		return appColor
+	}
+	return "\033[1m" + appColor // bold variant for alternate replicas
+}
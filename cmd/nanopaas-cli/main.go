@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+const usage = `NanoPaaS command line client
+
+Usage:
+  nanopaas <command> [flags]
+
+Commands:
+  logs    Show or follow logs for one or more apps
+
+Environment:
+  NANOPAAS_URL    API base URL (default http://localhost:8080)
+  NANOPAAS_TOKEN  API access token, used to resolve app slugs
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "logs":
+		err = runLogs(os.Args[2:])
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/nanopaas/nanopaas/internal/config"
+)
+
+// openDatabase creates the PostgreSQL connection pool and verifies it can connect. The
+// connections identify themselves with applicationName, which change notifications
+// carry as their origin.
+func openDatabase(cfg config.PostgresConfig, applicationName string) (*pgxpool.Pool, error) {
+	dbURL := fmt.Sprintf("postgres://%s:%s@%s:%d/%s?sslmode=%s",
+		cfg.User,
+		cfg.Password,
+		cfg.Host,
+		cfg.Port,
+		cfg.Database,
+		cfg.SSLMode,
+	)
+
+	poolConfig, err := pgxpool.ParseConfig(dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse database config: %w", err)
+	}
+	poolConfig.MaxConns = int32(cfg.PoolSize)
+	poolConfig.ConnConfig.RuntimeParams["application_name"] = applicationName
+
+	dbPool, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create database pool: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := dbPool.Ping(ctx); err != nil {
+		dbPool.Close()
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	return dbPool, nil
+}
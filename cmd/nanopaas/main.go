@@ -11,18 +11,36 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"go.uber.org/zap"
 
 	"github.com/nanopaas/nanopaas/internal/config"
+	"github.com/nanopaas/nanopaas/internal/domain"
 	"github.com/nanopaas/nanopaas/internal/handlers"
+	"github.com/nanopaas/nanopaas/internal/infrastructure/breaker"
 	"github.com/nanopaas/nanopaas/internal/infrastructure/docker"
+	apimw "github.com/nanopaas/nanopaas/internal/middleware"
 	"github.com/nanopaas/nanopaas/internal/repository/postgres"
+	redisrepo "github.com/nanopaas/nanopaas/internal/repository/redis"
+	"github.com/nanopaas/nanopaas/internal/services/acme"
 	"github.com/nanopaas/nanopaas/internal/services/auth"
 	"github.com/nanopaas/nanopaas/internal/services/builder"
+	"github.com/nanopaas/nanopaas/internal/services/cost"
+	"github.com/nanopaas/nanopaas/internal/services/gitea"
 	"github.com/nanopaas/nanopaas/internal/services/github"
+	"github.com/nanopaas/nanopaas/internal/services/gitlab"
+	"github.com/nanopaas/nanopaas/internal/services/gitprovider"
+	"github.com/nanopaas/nanopaas/internal/services/logstream"
+	"github.com/nanopaas/nanopaas/internal/services/mail"
+	"github.com/nanopaas/nanopaas/internal/services/maintenance"
+	"github.com/nanopaas/nanopaas/internal/services/notify"
+	"github.com/nanopaas/nanopaas/internal/services/oidc"
 	"github.com/nanopaas/nanopaas/internal/services/orchestrator"
 	"github.com/nanopaas/nanopaas/internal/services/router"
+	"github.com/nanopaas/nanopaas/internal/services/secrets"
+	"github.com/nanopaas/nanopaas/internal/services/templates"
+	"github.com/nanopaas/nanopaas/migrations"
 	ws "github.com/nanopaas/nanopaas/pkg/websocket"
 )
 
@@ -35,38 +53,73 @@ func main() {
 	}
 	defer logger.Sync()
 
-	// Load configuration
-	cfg := config.Load()
+	// Load configuration: environment variables, then the config file, then defaults.
+	// Invalid settings stop startup rather than falling back to defaults.
+	opts := parseOptions(os.Args[1:])
+	cfg, err := config.Load(opts.configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "nanopaas: invalid configuration:\n%v\n", err)
+		logger.Sync()
+		os.Exit(1)
+	}
+	if opts.printConfig {
+		cfg.Print(os.Stdout)
+	}
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "nanopaas: invalid configuration:\n%v\n", err)
+		logger.Sync()
+		os.Exit(1)
+	}
+	if opts.printConfig {
+		return
+	}
+	for _, problem := range cfg.Insecure() {
+		logger.Warn("Insecure setting, refused when NANOPAAS_ENV=production", zap.String("problem", problem))
+	}
+
+	// Subcommands run instead of the server
+	if len(opts.args) > 0 && opts.args[0] == "migrate" {
+		if err := runMigrate(cfg, opts.args[1:], logger); err != nil {
+			fmt.Fprintf(os.Stderr, "nanopaas migrate: %v\n", err)
+			logger.Sync()
+			os.Exit(1)
+		}
+		return
+	}
 
 	logger.Info("Starting NanoPaaS",
 		zap.String("host", cfg.Server.Host),
 		zap.Int("port", cfg.Server.Port),
 	)
 
-	// Initialize Docker client
-	dockerClient, err := docker.NewClient(
+	// Initialize container runtime client (Docker or Podman)
+	dockerClient, err := docker.NewRuntime(
+		cfg.Docker.Runtime,
 		cfg.Docker.Host,
 		cfg.Docker.APIVersion,
 		cfg.Docker.ContainerPrefix,
 		cfg.Docker.DefaultNetwork,
+		docker.TLSOptions{
+			Verify:   cfg.Docker.TLSVerify,
+			CertPath: cfg.Docker.CertPath,
+		},
 		logger,
 	)
 	if err != nil {
-		logger.Fatal("Failed to create Docker client", zap.Error(err))
+		logger.Fatal("Failed to create container runtime client", zap.Error(err), zap.String("runtime", cfg.Docker.Runtime))
 	}
 	defer dockerClient.Close()
 
-	// Verify Docker connection
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	if err := dockerClient.Ping(ctx); err != nil {
-		cancel()
-		logger.Fatal("Failed to connect to Docker daemon", zap.Error(err))
+	// Verify Docker connection. An unreachable daemon is not fatal: the API starts with
+	// container operations disabled and enables them once the daemon answers.
+	dockerAvailability := docker.NewAvailability(dockerClient, cfg.Docker.CheckInterval, logger)
+	if dockerAvailability.Check(context.Background()) {
+		logger.Info("Connected to container runtime", zap.String("runtime", dockerClient.Name()))
 	}
-	cancel()
-	logger.Info("Connected to Docker daemon")
+	requireDocker := handlers.RequireDocker(dockerAvailability)
 
 	// Ensure the NanoPaaS network exists
-	ctx, cancel = context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	if err := dockerClient.EnsureNetwork(ctx); err != nil {
 		cancel()
 		logger.Warn("Failed to ensure Docker network", zap.Error(err))
@@ -75,41 +128,46 @@ func main() {
 	}
 	cancel()
 
-	// Initialize PostgreSQL connection pool
-	dbURL := fmt.Sprintf("postgres://%s:%s@%s:%d/%s?sslmode=%s",
-		cfg.Postgres.User,
-		cfg.Postgres.Password,
-		cfg.Postgres.Host,
-		cfg.Postgres.Port,
-		cfg.Postgres.Database,
-		cfg.Postgres.SSLMode,
-	)
-
-	poolConfig, err := pgxpool.ParseConfig(dbURL)
-	if err != nil {
-		logger.Fatal("Failed to parse database config", zap.Error(err))
-	}
-	poolConfig.MaxConns = int32(cfg.Postgres.PoolSize)
+	// Initialize storage: PostgreSQL, or process memory for development
+	var dbPool *pgxpool.Pool
+	var dbBreaker *breaker.Breaker
+	var repos *repositories
+	// Names this process's database connections, so it can tell its own changes from
+	// those of other instances
+	instanceName := "nanopaas-" + uuid.NewString()
+	switch cfg.Server.Storage {
+	case "postgres":
+		dbPool, err = openDatabase(cfg.Postgres, instanceName)
+		if err != nil {
+			logger.Fatal("Failed to open database", zap.Error(err))
+		}
+		defer dbPool.Close()
+		logger.Info("Connected to PostgreSQL")
 
-	dbPool, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
-	if err != nil {
-		logger.Fatal("Failed to create database pool", zap.Error(err))
-	}
-	defer dbPool.Close()
+		// Shed API requests with 503 while the database is unreachable
+		dbBreaker = breaker.New("postgres", dbPool.Ping, cfg.Postgres.CheckInterval, logger)
+		dbBreaker.Start()
 
-	// Verify database connection
-	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
-	if err := dbPool.Ping(ctx); err != nil {
-		cancel()
-		logger.Fatal("Failed to connect to database", zap.Error(err))
+		// Bring the schema up to date before anything reads it
+		if cfg.Postgres.AutoMigrate {
+			ctx, cancel = context.WithTimeout(context.Background(), 5*time.Minute)
+			applied, err := postgres.NewMigrator(dbPool, migrations.FS, logger).Up(ctx)
+			cancel()
+			if err != nil {
+				logger.Fatal("Failed to migrate database", zap.Error(err))
+			}
+			logger.Info("Database schema up to date", zap.Int("applied", applied))
+		}
+		repos = postgresRepositories(dbPool, logger)
+	case "memory":
+		logger.Warn("Keeping all data in memory; it is lost when NanoPaaS stops")
+		repos = memoryRepositories()
+	default:
+		logger.Fatal("Invalid STORAGE_DRIVER, expected postgres or memory", zap.String("storage", cfg.Server.Storage))
 	}
-	cancel()
-	logger.Info("Connected to PostgreSQL")
 
 	// Initialize repositories
-	userRepo := postgres.NewUserRepository(dbPool, logger)
-	// Note: App repository will be used when we switch to persistent storage
-	// appRepo := postgres.NewAppRepository(dbPool, logger)
+	userRepo := repos.users
 
 	// Initialize GitHub service
 	githubService := github.NewService(github.Config{
@@ -119,6 +177,40 @@ func main() {
 		RedirectURI:   cfg.GitHub.RedirectURI,
 		Scopes:        cfg.GitHub.Scopes,
 	}, logger)
+	if cfg.GitHub.AppID != 0 {
+		privateKey := []byte(cfg.GitHub.AppPrivateKey)
+		if len(privateKey) == 0 && cfg.GitHub.AppPrivateKeyPath != "" {
+			if privateKey, err = os.ReadFile(cfg.GitHub.AppPrivateKeyPath); err != nil {
+				logger.Fatal("Failed to read GITHUB_APP_PRIVATE_KEY_PATH", zap.Error(err))
+			}
+		}
+		if err := githubService.SetApp(github.AppConfig{
+			ID:         cfg.GitHub.AppID,
+			Slug:       cfg.GitHub.AppSlug,
+			PrivateKey: privateKey,
+		}); err != nil {
+			logger.Fatal("Invalid GitHub App configuration", zap.Error(err))
+		}
+	}
+
+	// Git providers apps build from; users connect to those other than GitHub
+	gitConnectionRepo := repos.gitConnections
+	gitProviders := gitprovider.NewRegistry(gitConnectionRepo, logger)
+	gitProviders.Register(gitprovider.NewGitHub(githubService))
+	gitProviders.Register(gitlab.NewService(gitlab.Config{
+		BaseURL:       cfg.GitLab.BaseURL,
+		ClientID:      cfg.GitLab.ClientID,
+		ClientSecret:  cfg.GitLab.ClientSecret,
+		RedirectURI:   cfg.GitLab.RedirectURI,
+		WebhookSecret: cfg.GitLab.WebhookSecret,
+		Scopes:        gitlab.DefaultConfig().Scopes,
+	}, logger))
+	if cfg.Gitea.BaseURL != "" {
+		gitProviders.Register(gitea.NewService(gitea.Config{
+			BaseURL:       cfg.Gitea.BaseURL,
+			WebhookSecret: cfg.Gitea.WebhookSecret,
+		}, logger))
+	}
 
 	// Initialize auth service
 	authService := auth.NewService(auth.Config{
@@ -126,6 +218,70 @@ func main() {
 		JWTExpiry:        cfg.Auth.JWTExpiry,
 		JWTRefreshExpiry: cfg.Auth.JWTRefreshExpiry,
 	}, userRepo, logger)
+	authService.SetAPIKeyRepository(repos.apiKeys) // Scoped keys for CI
+	authService.SetDeployTokenRepository(repos.deployTokens)
+	authService.SetLoginEventRepository(repos.loginEvents)
+	authService.SetBootstrapAdmins(cfg.Auth.AdminEmails, cfg.Auth.FirstUserAdmin) // Admins before anyone can grant the role
+	authService.SetTwoFactorPolicy(cfg.Auth.TwoFactorIssuer, cfg.Auth.RequireTwoFactor)
+
+	// Email/password accounts; verification and reset emails are logged without SMTP_HOST
+	if cfg.Auth.PasswordLogin {
+		var sender mail.Sender = mail.NewLogSender(logger)
+		if cfg.SMTP.Host != "" {
+			smtpSender, err := mail.NewSMTPSender(mail.SMTPConfig{
+				Host:     cfg.SMTP.Host,
+				Port:     cfg.SMTP.Port,
+				Username: cfg.SMTP.Username,
+				Password: cfg.SMTP.Password,
+				From:     cfg.SMTP.From,
+			})
+			if err != nil {
+				logger.Fatal("Invalid SMTP configuration", zap.Error(err))
+			}
+			sender = smtpSender
+		}
+		authService.SetPasswordLogin(repos.userTokens, sender, auth.PasswordOptions{
+			Signup:      cfg.Auth.PasswordSignup,
+			FrontendURL: cfg.Auth.FrontendURL,
+		})
+	}
+
+	// Single sign-on through an OpenID Connect provider
+	var oidcProvider *oidc.Provider
+	if cfg.OIDC.IssuerURL != "" {
+		defaultRole := domain.UserRole(cfg.OIDC.DefaultRole)
+		if !defaultRole.IsValid() {
+			logger.Fatal("Invalid OIDC_DEFAULT_ROLE", zap.String("role", cfg.OIDC.DefaultRole))
+		}
+		oidcProvider = oidc.NewProvider(oidc.Config{
+			Name:         cfg.OIDC.Name,
+			IssuerURL:    cfg.OIDC.IssuerURL,
+			ClientID:     cfg.OIDC.ClientID,
+			ClientSecret: cfg.OIDC.ClientSecret,
+			RedirectURI:  cfg.OIDC.RedirectURI,
+			Scopes:       cfg.OIDC.Scopes,
+			RoleClaim:    cfg.OIDC.RoleClaim,
+			AdminValues:  cfg.OIDC.AdminValues,
+			ViewerValues: cfg.OIDC.ViewerValues,
+			DefaultRole:  defaultRole,
+		}, logger)
+		authService.SetIdentityRepository(repos.identities)
+		logger.Info("OIDC single sign-on enabled", zap.String("issuer", cfg.OIDC.IssuerURL))
+	}
+
+	// Sign tokens with asymmetric keys, so other services can verify them through the JWKS endpoint
+	var signingKeys *auth.KeySet
+	if cfg.Auth.JWTAlgorithm != auth.AlgorithmHS256 {
+		signingKeys, err = auth.NewKeySet(cfg.Auth.JWTAlgorithm, cfg.Auth.JWTKeysDir, logger)
+		if err != nil {
+			logger.Fatal("Failed to load JWT signing keys", zap.Error(err))
+		}
+		authService.SetKeySet(signingKeys)
+		if cfg.Auth.JWTKeyRotation > 0 {
+			// Retired keys keep verifying until every token they signed has expired
+			signingKeys.Start(cfg.Auth.JWTKeyRotation, max(cfg.Auth.JWTExpiry, cfg.Auth.JWTRefreshExpiry))
+		}
+	}
 
 	// Initialize orchestrator for container lifecycle management
 	orch := orchestrator.NewOrchestrator(
@@ -137,60 +293,397 @@ func main() {
 	logger.Info("Orchestrator initialized")
 
 	// Initialize builder service for Docker image builds
+	builderConfig := builder.DefaultBuilderConfig()
+	builderConfig.StripANSI = cfg.Build.StripANSI
 	builderService := builder.NewBuilder(
-		builder.DefaultBuilderConfig(),
+		builderConfig,
 		dockerClient,
 		logger,
 	)
 	defer builderService.Shutdown()
+	workspaceDriver, err := builder.NewWorkspaceDriver(builder.WorkspaceConfig{
+		Driver:     cfg.Build.WorkspaceDriver,
+		Root:       cfg.Build.WorkspaceRoot,
+		Quota:      cfg.Build.WorkspaceQuota,
+		ZFSDataset: cfg.Build.WorkspaceZFSDataset,
+	}, builderConfig.WorkDir, logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize build workspace driver", zap.Error(err))
+	}
+	builderService.SetWorkspaceDriver(workspaceDriver)
+	builderService.SetCloneAuthenticator(githubService) // Installation tokens clone private repos the GitHub App is on
+	// Keep builds and their full logs after the in-memory tails are gone
+	builderService.SetArchive(repos.builds, repos.buildLogs)
 	logger.Info("Builder service initialized")
 
-	// Initialize Traefik router for dynamic routing
-	traefikRouter, err := router.NewTraefikRouter(router.RouterConfig{
-		Domain:      cfg.Router.Domain,
-		ConfigPath:  cfg.Router.ConfigPath,
-		HTTPPort:    cfg.Router.HTTPPort,
-		HTTPSPort:   cfg.Router.HTTPSPort,
-		EnableHTTPS: cfg.Router.EnableHTTPS,
-	}, logger)
+	// Initialize the reverse proxy router. Managed certificates imply HTTPS routes.
+	platformDomain, err := router.NormalizePlatformDomain(cfg.Router.Domain)
+	if err != nil {
+		logger.Fatal("Invalid ROUTER_DOMAIN", zap.Error(err))
+	}
+	cfg.Router.Domain = platformDomain
+	if cfg.ACME.Enabled && cfg.ACME.WildcardDomain && cfg.ACME.Challenge != acme.ChallengeDNS01 {
+		logger.Fatal("ACME_WILDCARD requires ACME_CHALLENGE=dns-01")
+	}
+	routerConfig := router.RouterConfig{
+		Domain:       cfg.Router.Domain,
+		ConfigPath:   cfg.Router.ConfigPath,
+		HTTPPort:     cfg.Router.HTTPPort,
+		HTTPSPort:    cfg.Router.HTTPSPort,
+		EnableHTTPS:  cfg.Router.EnableHTTPS || cfg.ACME.Enabled,
+		RedirectHTTP: cfg.Router.RedirectHTTP,
+
+		MaintenanceURL: cfg.Router.MaintenanceURL,
+		TCPPorts:       cfg.Router.TCPPorts,
+		UDPPorts:       cfg.Router.UDPPorts,
+
+		Provider:      cfg.Router.Provider,
+		ProviderToken: cfg.Router.ProviderToken,
+		TraefikAPI:    cfg.Router.TraefikAPI,
+		VerifyTimeout: cfg.Router.VerifyTimeout,
+		MetricsURL:    cfg.Router.MetricsURL,
+
+		ApexApp:            cfg.Router.ApexApp,
+		ReservedSubdomains: cfg.Router.Reserved,
+
+		CaddyAdminURL: cfg.Router.CaddyAdminURL,
+		CaddyServer:   cfg.Router.CaddyServer,
+	}
+	if cfg.ACME.Enabled && cfg.ACME.Challenge == acme.ChallengeHTTP01 {
+		routerConfig.ACMEChallengeURL = cfg.ACME.ChallengeURL
+	}
+	appRouter, err := router.New(cfg.Router.Backend, routerConfig, logger)
 	if err != nil {
-		logger.Fatal("Failed to initialize Traefik router", zap.Error(err))
+		logger.Fatal("Failed to initialize router", zap.Error(err))
+	}
+	logger.Info("Router initialized", zap.String("backend", cfg.Router.Backend))
+	if cfg.Router.DNSCheck {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := router.CheckPlatformDNS(ctx, routerConfig, nil); err != nil {
+				logger.Warn("Platform domain DNS check failed; apps may be unreachable", zap.String("domain", cfg.Router.Domain), zap.Error(err))
+			}
+		}()
+	}
+
+	// Initialize ACME certificate management for app domains. Caddy obtains its own
+	// certificates, so the manager only runs in front of Traefik.
+	var certManager *acme.Manager
+	if cfg.ACME.Enabled && cfg.Router.Backend == router.BackendCaddy {
+		logger.Warn("ACME_ENABLED is ignored with the caddy router backend; Caddy manages certificates itself")
+	}
+	if cfg.ACME.Enabled && cfg.Router.Backend != router.BackendCaddy {
+		dnsProvider, err := acme.NewDNSProvider(cfg.ACME.DNSProvider, cfg.ACME.CloudflareToken, cfg.ACME.DNSExecCommand)
+		if err != nil {
+			logger.Fatal("Failed to initialize ACME DNS provider", zap.Error(err))
+		}
+		certManager, err = acme.NewManager(acme.Config{
+			Email:              cfg.ACME.Email,
+			DirectoryURL:       cfg.ACME.DirectoryURL,
+			Challenge:          cfg.ACME.Challenge,
+			RenewBefore:        cfg.ACME.RenewBefore,
+			CheckInterval:      cfg.ACME.CheckInterval,
+			DNSPropagationWait: cfg.ACME.DNSPropagationWait,
+		}, repos.certificates, dnsProvider, logger)
+		if err != nil {
+			logger.Fatal("Failed to initialize ACME certificate manager", zap.Error(err))
+		}
+		certManager.SetCertificatesChangedHandler(func(certs []*domain.Certificate) {
+			if err := appRouter.SetCertificates(certs); err != nil {
+				logger.Error("Failed to write certificates to router", zap.Error(err))
+			}
+		})
+		if err := certManager.Start(context.Background()); err != nil {
+			logger.Fatal("Failed to start ACME certificate manager", zap.Error(err))
+		}
+		if cfg.ACME.WildcardDomain {
+			// One certificate covers every app subdomain; custom domains still get their own
+			certManager.Request(cfg.Router.Domain, "*."+cfg.Router.Domain)
+		}
+		appRouter.SetHostRoutedHandler(func(hosts []string) { certManager.Request(hosts...) })
+		logger.Info("ACME certificate manager initialized")
 	}
-	logger.Info("Traefik router initialized")
 
 	// Initialize WebSocket hub for real-time log streaming
 	wsHub := ws.NewHub(logger)
+	wsHub.SetReplay(cfg.WebSocket.ReplaySize, cfg.WebSocket.ReplayTTL, "build:*", "pull:*", "app:*:events") // Late subscribers get earlier output
 	go wsHub.Run()
 	logger.Info("WebSocket hub initialized")
 
+	// Relay broadcasts between API replicas
+	var redisClient *redisrepo.Client
+	var hubBridge *redisrepo.HubBridge
+	if cfg.WebSocket.RedisBridge || cfg.Server.IdempotencyTTL > 0 || cfg.RateLimit.Enabled || cfg.Auth.SessionRevocation {
+		redisClient, err = redisrepo.NewClient(cfg.Redis.Host, cfg.Redis.Port, cfg.Redis.Password, cfg.Redis.DB, logger)
+		if err != nil && cfg.WebSocket.RedisBridge {
+			logger.Fatal("Failed to connect to Redis for the WebSocket bridge", zap.Error(err))
+		}
+		if err != nil {
+			logger.Warn("Redis unavailable; requests are not rate limited, Idempotency-Key headers are ignored and logout does not revoke tokens", zap.Error(err))
+		}
+	}
+	// Fail Redis commands fast while Redis is unreachable; every feature on it carries on without
+	var redisBreaker *breaker.Breaker
+	if redisClient != nil {
+		redisBreaker = breaker.New("redis", redisClient.Ping, cfg.Redis.CheckInterval, logger)
+		redisClient.SetBreaker(redisBreaker)
+		redisBreaker.Start()
+	}
+	if cfg.Auth.SessionRevocation && redisClient != nil {
+		authService.SetSessionStore(redisrepo.NewSessionStore(redisClient)) // Logout and session revocation invalidate tokens
+	}
+	if cfg.WebSocket.RedisBridge {
+		hubBridge = redisrepo.NewHubBridge(redisClient, wsHub, logger)
+		if err := hubBridge.Start(); err != nil {
+			logger.Fatal("Failed to start WebSocket Redis bridge", zap.Error(err))
+		}
+	}
+
 	// Initialize HTTP router
 	r := chi.NewRouter()
+	openapiHandler := handlers.NewOpenAPIHandler(r, logger)
 
 	// Middleware
 	r.Use(middleware.RequestID)
+	r.Use(apimw.ExposeRequestID)
 	r.Use(middleware.RealIP)
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
-	r.Use(middleware.Timeout(60 * time.Second))
+	r.Use(timeoutMiddleware(60 * time.Second))
 
 	// CORS middleware with configurable origins
 	r.Use(corsMiddleware(cfg.Auth.CORSOrigins))
 
+	// Deprecation headers and usage counts for routes in handlers.DeprecatedRoutes
+	deprecations := apimw.NewDeprecationTracker(r, handlers.DeprecatedRoutes, logger)
+	r.Use(deprecations.Middleware)
+
+	// Initialize scheduled database maintenance
+	maintenanceService := maintenance.NewService(dbPool, maintenance.Config{
+		Enabled:                 cfg.Maintenance.Enabled,
+		Interval:                cfg.Maintenance.Interval,
+		BuildRetentionDays:      cfg.Maintenance.BuildRetentionDays,
+		DeploymentRetentionDays: cfg.Maintenance.DeploymentRetentionDays,
+		BuildLogRetentionDays:   cfg.Maintenance.BuildLogRetentionDays,
+		PromotionRetentionDays:  cfg.Maintenance.PromotionRetentionDays,
+		LoginEventRetentionDays: cfg.Maintenance.LoginEventRetentionDays,
+		KeepDeploymentsPerApp:   cfg.Maintenance.KeepDeploymentsPerApp,
+	}, logger)
+	maintenanceService.Start()
+
 	// Initialize repositories
-	appRepo := postgres.NewAppRepository(dbPool, logger)
-	buildRepo := postgres.NewBuildRepository(dbPool, logger)
+	appRepo := repos.apps
+	buildRepo := repos.builds
+
+	// Initialize personal notifications, pushed over the WebSocket hub
+	notificationRepo := repos.notifications
+	notifier := notify.NewService(notificationRepo, wsHub, logger)
 
 	// Initialize handlers
 	healthHandler := handlers.NewHealthHandler(dockerClient, logger)
+	var requiredDependencies []*breaker.Breaker
+	if dbBreaker != nil {
+		healthHandler.AddDependency(dbBreaker, true)
+		requiredDependencies = append(requiredDependencies, dbBreaker)
+	}
+	if redisBreaker != nil {
+		// Only the WebSocket bridge can't do without Redis
+		healthHandler.AddDependency(redisBreaker, cfg.WebSocket.RedisBridge)
+	}
+	requireDependencies := handlers.RequireDependencies(requiredDependencies...)
 	containerHandler := handlers.NewContainerHandler(dockerClient, logger)
+	containerHandler.SetCaptureImage(cfg.Docker.CaptureImage)
 	authHandler := handlers.NewAuthHandler(authService, githubService, cfg.Auth.FrontendURL, logger)
+	if oidcProvider != nil {
+		authHandler.SetOIDCProvider(oidcProvider)
+	}
+	sameSite, err := handlers.ParseSameSite(cfg.Auth.CookieSameSite)
+	if err != nil {
+		logger.Fatal("Invalid AUTH_COOKIE_SAMESITE", zap.Error(err))
+	}
+	authHandler.SetCookieOptions(handlers.CookieOptions{
+		Secure:   cfg.Auth.CookieSecure,
+		SameSite: sameSite,
+		Domain:   cfg.Auth.CookieDomain,
+		Session:  cfg.Auth.CookieSession,
+		StateKey: []byte(cfg.Auth.StateSecret),
+	})
 	githubHandler := handlers.NewGitHubHandler(githubService, logger)
-	appHandler := handlers.NewAppHandler(orch, traefikRouter, logger)
+	if cfg.GitHub.RepoCacheTTL > 0 && redisClient != nil {
+		githubHandler.SetRepoCache(redisrepo.NewRepoCache(redisClient, cfg.GitHub.RepoCacheTTL))
+	}
+	gitProviderHandler := handlers.NewGitProviderHandler(gitProviders, gitConnectionRepo, cfg.Auth.FrontendURL, []byte(cfg.Auth.StateSecret), logger)
+	appHandler := handlers.NewAppHandler(orch, appRouter, logger)
+	orch.SetContainersChangedHandler(appHandler.RefreshRoute) // Keep route IPs current after health restarts
+	appHandler.SetAppStore(appRepo)
+	appHandler.SetTransactor(repos.tx) // Stacks and templates store all their apps or none
+	appHandler.SetTeamMembershipSource(repos.teams)
+	appHandler.SetIncidentStore(repos.incidents)
+	appHandler.SetBuildLogSource(builderService) // Build logs for diagnostic bundles
+	appHandler.SetBuildHistory(builderService)   // Builds behind each deployment, for changelogs
+	appHandler.SetCustomDomainStore(repos.customDomains)
+	appHandler.SetProjectStore(repos.projects)
+	appHandler.SetCollaboratorStore(repos.collaborators, userRepo)
+	appHandler.SetDeployTokenIssuer(authService)
+	gitProviderHandler.SetGitHubHandler(githubHandler)
+	gitProviderHandler.SetWebhookApps(appHandler)
+	appHandler.SetGitHubWebhookRemover(gitProviderHandler) // Apps' webhooks are deleted from their providers with them
+	appHandler.SetGitRepoConnector(gitProviderHandler)
+	appHandler.SetPublicURL(cfg.Server.PublicURL)
+	githubHandler.SetWebhookApps(appHandler)
+	appHandler.SetCostEstimator(cost.NewEstimator(cost.Rates{
+		Currency:     cfg.Cost.Currency,
+		MemoryGBHour: cfg.Cost.MemoryGBHourRate,
+		VCPUHour:     cfg.Cost.VCPUHourRate,
+		BuildMinute:  cfg.Cost.BuildMinuteRate,
+	}, builderService))
+
+	// App secrets are encrypted with the master key and only decrypted to start containers
+	var secretBox *secrets.Box
+	if cfg.Auth.SecretsMasterKey != "" {
+		secretBox, err = secrets.NewBox(cfg.Auth.SecretsMasterKey)
+		if err != nil {
+			logger.Fatal("Invalid SECRETS_MASTER_KEY", zap.Error(err))
+		}
+		appHandler.SetSecretSealer(secretBox)
+		appHandler.SetWebhookSecretBox(secretBox) // Each app's webhooks get their own secret
+		orch.SetSecretOpener(secretBox)
+	} else {
+		logger.Warn("SECRETS_MASTER_KEY is not set; the secrets API and per-app webhook secrets are disabled")
+	}
+
+	// Restore apps persisted by a previous run, re-adopting their containers and routes
+	ctx, cancel = context.WithTimeout(context.Background(), 30*time.Second)
+	if err := appHandler.LoadApps(ctx); err != nil {
+		logger.Error("Failed to load apps", zap.Error(err))
+	}
+	cancel()
+
+	// Refresh apps and projects changed by other instances sharing the database
+	var changeListener *postgres.ChangeListener
+	if dbPool != nil {
+		changeListener = postgres.NewChangeListener(dbPool, instanceName, logger)
+		changeListener.Start(func(ctx context.Context, change postgres.Change) {
+			ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			defer cancel()
+			switch change.Table {
+			case "apps":
+				appHandler.ReloadApp(ctx, change.ID)
+			case "projects":
+				appHandler.ReloadProject(ctx, change.ID)
+			}
+		}, func(ctx context.Context) {
+			ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+			defer cancel()
+			appHandler.Resync(ctx)
+		})
+	}
+
+	// When the daemon returns from an outage, recreate the network and resync app containers and routes
+	dockerAvailability.SetRecoveredHandler(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+		if err := dockerClient.EnsureNetwork(ctx); err != nil {
+			logger.Warn("Failed to ensure Docker network", zap.Error(err))
+		}
+		appHandler.RecoverContainers(ctx)
+	})
+	dockerAvailability.Start()
+	wsOrigins := cfg.Auth.WSOrigins
+	if len(wsOrigins) == 0 {
+		wsOrigins = cfg.Auth.CORSOrigins
+	}
+	wsAuth := handlers.NewWSAuthenticator(authService, wsOrigins, logger)
+	wsAuth.SetAppFinder(appHandler) // Streams follow the apps' REST access rules
+	wsAuth.SetBuildFinder(builderService)
+	wsHandler := handlers.NewWSHandler(wsHub, wsAuth, logger)
 	buildHandler := handlers.NewBuildHandler(builderService, wsHub, logger)
+	buildHandler.SetWSAuthenticator(wsAuth)
 	buildHandler.SetAppUpdater(appHandler) // Connect build completion to app updates
+	buildHandler.SetNotifier(notifier)
+	appHandler.SetGitBuilder(buildHandler) // First builds of apps created from templates
+	templateCatalog, err := templates.Load(cfg.Build.TemplatesDir)
+	if err != nil {
+		logger.Fatal("Failed to load templates", zap.Error(err))
+	}
+	appHandler.SetTemplateCatalog(templateCatalog)
+	imageHandler := handlers.NewImageHandler(dockerClient, logger)
+	imageHandler.SetAppLister(appHandler) // Report which apps reference each image
+	promotionHandler := handlers.NewPromotionHandler(
+		dockerClient,
+		repos.promotions,
+		cfg.Docker.Registry,
+		cfg.Docker.RegistryAuth,
+		logger,
+	)
+	promotionHandler.SetManagedAppLister(appHandler) // Users promote their own apps' images
 	metricsHandler := handlers.NewMetricsHandler(dockerClient, orch, builderService, wsHub, logger)
-	logHandler := handlers.NewLogHandler(dockerClient, wsHub, logger)
+	logStreamer := logstream.NewStreamer(dockerClient, wsHub, logger)
+	logStreamer.Start()
+	logHandler := handlers.NewLogHandler(dockerClient, wsHub, wsAuth, logStreamer, logger)
+	orch.SetPullProgressHandler(logHandler.BroadcastPullProgress) // Stream deploy image pulls
+	logHandler.SetBuildArchive(repos.builds, repos.buildLogs)
+
+	// Post deploy, build and crash-loop events to the configured Slack, Discord and webhook channels
+	notificationChannelRepo := repos.notificationChannels
+	dispatcher := notify.NewDispatcher(notificationChannelRepo, appHandler, logger)
+	orch.SetDeploymentHandler(func(d *domain.Deployment) {
+		logHandler.BroadcastDeployment(d)
+		dispatcher.DeploymentChanged(d)
+	})
+	orch.SetContainerExitHandler(func(appID uuid.UUID, exit domain.ContainerExit) {
+		appHandler.RecordContainerExit(appID, exit)
+		dispatcher.ContainerExited(appID, exit)
+	})
+	builderService.SetBuildFinishedHandler(dispatcher.BuildFinished)
+	notificationChannelHandler := handlers.NewNotificationChannelHandler(notificationChannelRepo, dispatcher, appHandler, logger)
+	var logShipper *logstream.Shipper
+	if cfg.Logs.ShippingEnabled {
+		appLogRepo := repos.appLogs
+		logShipper = logstream.NewShipper(dockerClient, appLogRepo, cfg.Logs.RetentionDays, logger)
+		logShipper.Start()
+		logHandler.SetLogStore(appLogRepo) // Search logs of removed containers
+	}
+	maintenanceHandler := handlers.NewMaintenanceHandler(maintenanceService, logger)
+	userHandler := handlers.NewUserHandler(authService, logger)
+	userHandler.SetOwnerReassigner(appHandler) // Deleted users' apps move to their new owner in memory too
+	systemHandler := handlers.NewSystemHandler(dockerClient, logger)
+	systemHandler.SetAppLister(appHandler) // Keep app images when pruning
+	certificateHandler := handlers.NewCertificateHandler(certManager, logger)
+	deprecationHandler := handlers.NewDeprecationHandler(deprecations, logger)
+	metricsHandler.SetDeprecationTracker(deprecations)
+	metricsHandler.SetRouter(appRouter)
+	metricsHandler.SetGitHubRateLimits(githubService)
+	notificationHandler := handlers.NewNotificationHandler(notificationRepo, wsAuth, wsHub, logger)
 	webhookHandler := handlers.NewWebhookHandler(appRepo, buildRepo, builderService, cfg.GitHub.WebhookSecret, logger)
+	webhookHandler.SetTransactor(repos.tx)
+	webhookHandler.SetBuildDeployer(appHandler) // Auto-deploys, held for approval on protected apps
+	webhookHandler.SetGitHubDeployments(githubService, userRepo)
+	webhookHandler.SetGitProviders(gitProviders)
+	if secretBox != nil {
+		webhookHandler.SetWebhookSecretOpener(secretBox)
+	}
+
+	// Replay responses to retried deploys, scales, builds and webhook deliveries
+	var idempotencyStore handlers.IdempotencyStore
+	if cfg.Server.IdempotencyTTL > 0 && redisClient != nil {
+		idempotencyStore = redisrepo.NewIdempotencyStore(redisClient, cfg.Server.IdempotencyTTL)
+	}
+	idempotency := handlers.NewIdempotency(idempotencyStore, logger)
+	githubDelivery := idempotency.KeyedBy("X-GitHub-Delivery")
+	gitlabDelivery := idempotency.KeyedBy("X-Gitlab-Event-UUID")
+	giteaDelivery := idempotency.KeyedBy("X-Gitea-Delivery")
+
+	// Per-user (per-IP before login) request budgets; health and metrics are not limited
+	var rateLimitStore handlers.RateLimitStore
+	if cfg.RateLimit.Enabled && redisClient != nil {
+		rateLimitStore = redisClient
+	}
+	rateLimiter := handlers.NewRateLimiter(rateLimitStore, logger)
+	apiLimit := rateLimiter.Limit("api", handlers.RateLimitPolicy{Requests: cfg.RateLimit.APIRequests, Window: cfg.RateLimit.Window})
+	authLimit := rateLimiter.Limit("auth", handlers.RateLimitPolicy{Requests: cfg.RateLimit.AuthRequests, Window: cfg.RateLimit.Window})
+	buildLimit := rateLimiter.Limit("build", handlers.RateLimitPolicy{Requests: cfg.RateLimit.BuildRequests, Window: cfg.RateLimit.Window})
 
 	// Health routes
 	r.Get("/health", healthHandler.Health)
@@ -201,78 +694,367 @@ func main() {
 	r.Get("/metrics", metricsHandler.Metrics)
 	r.Get("/api/v1/stats", metricsHandler.Stats)
 
+	// Public keys for verifying tokens (also served at /api/v1/auth/jwks.json)
+	r.Get("/.well-known/jwks.json", authHandler.JWKS)
+
 	// Webhook routes (public with signature verification)
-	r.Post("/webhooks/github", webhookHandler.HandleGitHub)
-	r.Post("/api/v1/webhooks/github/{appId}", webhookHandler.HandleGitHubForApp)
+	r.With(requireDependencies, githubDelivery).Post("/webhooks/github", webhookHandler.HandleGitHub)
+	r.With(requireDependencies, githubDelivery).Post("/api/v1/webhooks/github/{appId}", webhookHandler.HandleGitHubForApp)
+	r.With(requireDependencies, gitlabDelivery, giteaDelivery).Post("/api/v1/webhooks/{provider}/{appId}", webhookHandler.HandleProviderForApp)
+
+	// ACME HTTP-01 challenge responses (public, forwarded by Traefik)
+	if certManager != nil {
+		r.Handle(acme.ChallengePathPrefix+"*", certManager)
+	}
+
+	// Maintenance pages (public, Traefik rewrites requests to apps in maintenance here)
+	r.HandleFunc(router.MaintenancePathPrefix+"{appId}", appHandler.ServeMaintenancePage)
+
+	// Dynamic configuration for Traefik's HTTP provider (authenticated by the provider token)
+	if traefikRouter, ok := appRouter.(*router.TraefikRouter); ok && cfg.Router.Provider == router.ProviderHTTP {
+		r.Get("/traefik/config", traefikRouter.ServeProviderConfig)
+	}
 
 	// WebSocket routes
+	r.Get("/ws", wsHandler.Connect)
 	r.Get("/ws/apps/{appId}/logs", logHandler.StreamAppLogs)
+	r.Get("/ws/apps/{appId}/pull", logHandler.StreamPullProgress)
 	r.Get("/ws/containers/{containerId}/logs", logHandler.StreamContainerLogs)
 	r.Get("/ws/builds/{buildId}/logs", logHandler.StreamBuildLogs)
+	r.Get("/ws/notifications", notificationHandler.Stream)
+
+	// Versioned API routes. v2 starts as v1 without its deprecated routes, and breaking
+	// changes land in v2 only.
+	apiRoutes := func(version int) func(r chi.Router) {
+		return func(r chi.Router) {
+			r.Use(apimw.APIVersion(version))
+			r.Use(requireDependencies)
+
+			// API description (public), generated from these routes
+			r.Get("/openapi.json", openapiHandler.Spec)
+			if cfg.Server.APIDocs {
+				r.Get("/docs", openapiHandler.Docs)
+			}
 
-	// API v1 routes
-	r.Route("/api/v1", func(r chi.Router) {
-		// Auth routes (public)
-		r.Route("/auth", func(r chi.Router) {
-			r.Get("/github", authHandler.GitHubLogin)
-			r.Get("/github/callback", authHandler.GitHubCallback)
-			r.Post("/refresh", authHandler.RefreshToken)
-			r.Post("/logout", authHandler.Logout)
-
-			// Protected auth routes
-			r.Group(func(r chi.Router) {
+			// Auth routes (public)
+			r.Route("/auth", func(r chi.Router) {
+				r.Use(authLimit)
+				r.Get("/github", authHandler.GitHubLogin)
+				r.Get("/github/callback", authHandler.GitHubCallback)
+				r.Get("/oidc", authHandler.OIDCLogin)
+				r.Get("/oidc/callback", authHandler.OIDCCallback)
+				r.Get("/providers", authHandler.Providers)
+				r.Post("/refresh", authHandler.RefreshToken)
+				r.Post("/logout", authHandler.Logout)
+				r.Get("/jwks.json", authHandler.JWKS)
+				r.Post("/signup", authHandler.Signup)
+				r.Post("/login", authHandler.Login)
+				r.Post("/verify-email", authHandler.VerifyEmail)
+				r.Post("/verify-email/resend", authHandler.ResendVerification)
+				r.Post("/password/forgot", authHandler.ForgotPassword)
+				r.Post("/password/reset", authHandler.ResetPassword)
+				r.Post("/2fa/verify", authHandler.VerifyTwoFactor)
+
+				// Routes users reach before enrolling in required two-factor authentication
+				r.Group(func(r chi.Router) {
+					r.Use(handlers.EnrollmentAuthMiddleware(authService))
+					r.Use(apiLimit)
+					r.Get("/me", authHandler.GetCurrentUser)
+					r.Post("/2fa/enroll", authHandler.EnrollTwoFactor)
+					r.Post("/2fa/confirm", authHandler.ConfirmTwoFactor)
+				})
+
+				// Protected auth routes
+				r.Group(func(r chi.Router) {
+					r.Use(handlers.AuthMiddleware(authService))
+					r.Use(apiLimit)
+					r.Post("/2fa/disable", authHandler.DisableTwoFactor)
+					r.Post("/2fa/recovery-codes", authHandler.RegenerateRecoveryCodes)
+					r.Put("/password", authHandler.ChangePassword)
+					r.Get("/api-keys", authHandler.ListAPIKeys)
+					r.Post("/api-keys", authHandler.CreateAPIKey)
+					r.Delete("/api-keys/{keyId}", authHandler.RevokeAPIKey)
+					r.Get("/sessions", authHandler.ListSessions)
+					r.Delete("/sessions", authHandler.RevokeAllSessions)
+					r.Delete("/sessions/{sessionId}", authHandler.RevokeSession)
+					r.Get("/logins", authHandler.ListLogins)
+				})
+			})
+
+			// GitHub routes (protected)
+			r.Route("/github", func(r chi.Router) {
 				r.Use(handlers.AuthMiddleware(authService))
-				r.Get("/me", authHandler.GetCurrentUser)
+				r.Use(apiLimit)
+				r.Use(handlers.RequireRole(domain.UserRoleMember))
+				r.Get("/repos", githubHandler.ListRepositories)
+				r.Get("/repos/{owner}/{repo}", githubHandler.GetRepository)
+				r.Get("/repos/{owner}/{repo}/branches", githubHandler.ListBranches)
+				r.Get("/repos/{owner}/{repo}/webhooks", githubHandler.ListWebhooks)
+				r.Post("/webhooks", githubHandler.CreateWebhook)
+				r.Delete("/webhooks/{owner}/{repo}/{webhookId}", githubHandler.DeleteWebhook)
+				r.Get("/app", githubHandler.GetApp)
+				r.Get("/app/installations", githubHandler.ListInstallations)
+				r.Get("/app/installations/{installationId}/repos", githubHandler.ListInstallationRepos)
+				r.Get("/app/webhook", githubHandler.GetAppWebhook)
+				r.Put("/app/webhook", githubHandler.UpdateAppWebhook)
 			})
-		})
 
-		// GitHub routes (protected)
-		r.Route("/github", func(r chi.Router) {
-			r.Use(handlers.AuthMiddleware(authService))
-			r.Get("/repos", githubHandler.ListRepositories)
-			r.Get("/repos/{owner}/{repo}", githubHandler.GetRepository)
-			r.Get("/repos/{owner}/{repo}/branches", githubHandler.ListBranches)
-			r.Post("/webhooks", githubHandler.CreateWebhook)
-			r.Delete("/webhooks/{owner}/{repo}/{webhookId}", githubHandler.DeleteWebhook)
-		})
+			// Git provider routes (protected, except the OAuth callback, whose state names the user)
+			r.Route("/git", func(r chi.Router) {
+				r.With(authLimit).Get("/{provider}/callback", gitProviderHandler.Callback)
 
-		// Apps routes (protected)
-		r.Route("/apps", func(r chi.Router) {
-			r.Use(handlers.AuthMiddleware(authService))
-			r.Get("/", appHandler.List)
-			r.Post("/", appHandler.Create)
-			r.Get("/{appId}", appHandler.Get)
-			r.Put("/{appId}", appHandler.Update)
-			r.Delete("/{appId}", appHandler.Delete)
-			r.Post("/{appId}/deploy", appHandler.Deploy)
-			r.Post("/{appId}/scale", appHandler.Scale)
-			r.Post("/{appId}/restart", appHandler.Restart)
-			r.Post("/{appId}/stop", appHandler.Stop)
-			r.Put("/{appId}/env", appHandler.SetEnvVars)
-			r.Delete("/{appId}/env/{key}", appHandler.DeleteEnvVar)
-			r.Get("/{appId}/logs", logHandler.GetAppLogs)
-
-			// Build routes within apps
-			r.Post("/{appId}/builds", buildHandler.Create)
-			r.Post("/{appId}/builds/git", buildHandler.StartBuildFromGit)
-			r.Get("/{appId}/builds/{buildId}", buildHandler.Get)
-			r.Post("/{appId}/builds/{buildId}/cancel", buildHandler.Cancel)
-			r.Get("/{appId}/builds/{buildId}/logs", logHandler.GetBuildLogs)
-		})
+				r.Group(func(r chi.Router) {
+					r.Use(handlers.AuthMiddleware(authService))
+					r.Use(apiLimit)
+					r.Use(handlers.RequireRole(domain.UserRoleMember))
+					r.Get("/providers", gitProviderHandler.ListProviders)
+					r.Get("/{provider}/connect", gitProviderHandler.Connect)
+					r.Put("/{provider}/token", gitProviderHandler.SetToken)
+					r.Delete("/{provider}/connection", gitProviderHandler.Disconnect)
+					r.Get("/{provider}/repos", gitProviderHandler.ListRepositories)
+					r.Get("/{provider}/repos/{owner}/{repo}", gitProviderHandler.GetRepository)
+					r.Get("/{provider}/repos/{owner}/{repo}/branches", gitProviderHandler.ListBranches)
+					r.Get("/{provider}/repos/{owner}/{repo}/webhooks", gitProviderHandler.ListWebhooks)
+					r.Post("/{provider}/repos/{owner}/{repo}/webhooks", gitProviderHandler.CreateWebhook)
+					r.Delete("/{provider}/repos/{owner}/{repo}/webhooks/{webhookId}", gitProviderHandler.DeleteWebhook)
+				})
+			})
 
-		// Container management (protected)
-		r.Route("/containers", func(r chi.Router) {
-			r.Use(handlers.AuthMiddleware(authService))
-			r.Get("/", containerHandler.List)
-			r.Post("/", containerHandler.Create)
-			r.Get("/{id}", containerHandler.Get)
-			r.Delete("/{id}", containerHandler.Delete)
-			r.Post("/{id}/start", containerHandler.Start)
-			r.Post("/{id}/stop", containerHandler.Stop)
-			r.Post("/{id}/restart", containerHandler.Restart)
-			r.Get("/{id}/logs", containerHandler.Logs)
-		})
-	})
+			// Starter template catalog (protected)
+			r.Route("/templates", func(r chi.Router) {
+				r.Use(handlers.AuthMiddleware(authService))
+				r.Use(apiLimit)
+				r.Use(handlers.RequireRole(domain.UserRoleMember))
+				r.Get("/", appHandler.ListTemplates)
+			})
+
+			// Dashboard overview of the apps the user can access (protected)
+			r.Route("/overview", func(r chi.Router) {
+				r.Use(handlers.AuthMiddleware(authService))
+				r.Use(apiLimit)
+				r.Use(handlers.RequireRole(domain.UserRoleMember))
+				r.Get("/", appHandler.Overview)
+			})
+
+			// Apps routes (protected)
+			r.Route("/apps", func(r chi.Router) {
+				r.Use(handlers.AuthMiddleware(authService))
+				r.Use(apiLimit)
+				r.Use(handlers.RequireRole(domain.UserRoleMember))
+				r.Get("/", appHandler.List)
+				r.Post("/", appHandler.Create)
+				r.Post("/import/compose", appHandler.ImportCompose)
+				r.Post("/manifest", appHandler.ApplyManifest)
+				r.With(requireDocker, buildLimit).Post("/from-template", appHandler.CreateFromTemplate)
+				r.Group(func(r chi.Router) {
+					r.Use(appHandler.RequireAppAccess) // Owner, team members, admins and collaborators
+					r.Get("/{appId}", appHandler.Get)
+					r.Put("/{appId}", appHandler.Update)
+					r.With(requireDocker).Delete("/{appId}", appHandler.Delete)
+					r.Get("/{appId}/manifest", appHandler.ExportManifest)
+					r.Get("/{appId}/config", appHandler.GetEffectiveConfig)
+					r.Post("/{appId}/clone", appHandler.Clone)
+					r.With(requireDocker, idempotency.Middleware).Post("/{appId}/deploy", appHandler.Deploy)
+					r.Get("/{appId}/deployments", appHandler.ListDeployments)
+					r.Get("/{appId}/changelog", appHandler.Changelog)
+					r.With(requireDocker, idempotency.Middleware).Post("/{appId}/scale", appHandler.Scale)
+					r.With(requireDocker).Post("/{appId}/restart", appHandler.Restart)
+					r.With(requireDocker).Post("/{appId}/stop", appHandler.Stop)
+					r.Put("/{appId}/repository", appHandler.ConnectRepository)
+					r.Delete("/{appId}/repository", appHandler.DisconnectRepository)
+					r.Put("/{appId}/env", appHandler.SetEnvVars)
+					r.Get("/{appId}/secrets", appHandler.ListSecrets)
+					r.Put("/{appId}/secrets", appHandler.SetSecrets)
+					r.Delete("/{appId}/secrets/{key}", appHandler.DeleteSecret)
+					r.Delete("/{appId}/env/{key}", appHandler.DeleteEnvVar)
+					r.Get("/{appId}/logs", logHandler.GetAppLogs)
+					r.With(requireDocker).Get("/{appId}/logs/stream", logHandler.StreamAppLogsSSE)
+					r.Get("/{appId}/events/stream", logHandler.StreamAppEventsSSE)
+					r.Get("/{appId}/cost-estimate", appHandler.CostEstimate)
+					r.Get("/{appId}/cors", appHandler.GetCORS)
+					r.Put("/{appId}/cors", appHandler.SetCORS)
+					r.Delete("/{appId}/cors", appHandler.DeleteCORS)
+					r.Get("/{appId}/hsts", appHandler.GetHSTS)
+					r.Put("/{appId}/hsts", appHandler.SetHSTS)
+					r.Delete("/{appId}/hsts", appHandler.DeleteHSTS)
+					r.Get("/{appId}/sticky-sessions", appHandler.GetStickySessions)
+					r.Put("/{appId}/sticky-sessions", appHandler.SetStickySessions)
+					r.Delete("/{appId}/sticky-sessions", appHandler.DeleteStickySessions)
+					r.Get("/{appId}/maintenance", appHandler.GetMaintenance)
+					r.Post("/{appId}/maintenance", appHandler.SetMaintenance)
+					r.Get("/{appId}/network", appHandler.GetNetworkRoute)
+					r.Put("/{appId}/network", appHandler.SetNetworkRoute)
+					r.Delete("/{appId}/network", appHandler.DeleteNetworkRoute)
+					r.Get("/{appId}/traffic", appHandler.GetTraffic)
+					r.Post("/{appId}/protect", appHandler.Protect)
+					r.Post("/{appId}/protect/rotate", appHandler.RotateProtection)
+					r.Delete("/{appId}/protect", appHandler.Unprotect)
+					r.Get("/{appId}/routing", appHandler.GetRouting)
+					r.Get("/{appId}/routing/preview", appHandler.PreviewRouting)
+					r.Put("/{appId}/routing", appHandler.SetRouting)
+					r.Post("/{appId}/pin", appHandler.Pin)
+					r.Delete("/{appId}/pin", appHandler.Unpin)
+					r.Get("/{appId}/diagnostics.tar.gz", appHandler.DiagnosticsBundle)
+					if version == 1 {
+						r.Post("/{appId}/incident", appHandler.OpenIncident) // Deprecated, see handlers.DeprecatedRoutes
+					}
+					r.Post("/{appId}/incidents", appHandler.OpenIncident)
+					r.Get("/{appId}/incidents", appHandler.ListIncidents)
+					r.Get("/{appId}/incidents/{incidentId}", appHandler.GetIncident)
+					r.Post("/{appId}/incidents/{incidentId}/events", appHandler.AddIncidentNote)
+					r.Post("/{appId}/incidents/{incidentId}/resolve", appHandler.ResolveIncident)
+					r.Get("/{appId}/domains", appHandler.ListDomains)
+					r.Post("/{appId}/domains", appHandler.AddDomain)
+					r.Post("/{appId}/domains/{domainId}/verify", appHandler.VerifyDomain)
+					r.Delete("/{appId}/domains/{domainId}", appHandler.DeleteDomain)
+					r.Get("/{appId}/notification-channels", notificationChannelHandler.List)
+					r.Post("/{appId}/notification-channels", notificationChannelHandler.Create)
+					r.Put("/{appId}/notification-channels/{channelId}", notificationChannelHandler.Update)
+					r.Delete("/{appId}/notification-channels/{channelId}", notificationChannelHandler.Delete)
+					r.Get("/{appId}/notification-channels/{channelId}/deliveries", notificationChannelHandler.Deliveries)
+					r.Post("/{appId}/notification-channels/{channelId}/test", notificationChannelHandler.Test)
+					r.Get("/{appId}/collaborators", appHandler.ListCollaborators)
+					r.Post("/{appId}/collaborators", appHandler.AddCollaborator)
+					r.Put("/{appId}/collaborators/{userId}", appHandler.UpdateCollaborator)
+					r.Delete("/{appId}/collaborators/{userId}", appHandler.RemoveCollaborator)
+					r.Get("/{appId}/deploy-tokens", appHandler.ListDeployTokens)
+					r.Post("/{appId}/deploy-tokens", appHandler.CreateDeployToken)
+					r.Delete("/{appId}/deploy-tokens/{tokenId}", appHandler.RevokeDeployToken)
+
+					// Build routes within apps
+					r.Get("/{appId}/builds", buildHandler.List)
+					r.With(requireDocker, buildLimit, idempotency.Middleware).Post("/{appId}/builds", buildHandler.Create)
+					r.With(requireDocker, buildLimit, idempotency.Middleware).Post("/{appId}/builds/git", buildHandler.StartBuildFromGit)
+					r.Get("/{appId}/builds/{buildId}", buildHandler.Get)
+					r.Post("/{appId}/builds/{buildId}/cancel", buildHandler.Cancel)
+					r.Get("/{appId}/builds/{buildId}/logs", logHandler.GetBuildLogs)
+				})
+			})
+
+			// Projects grouping apps with shared env (protected, access checked per handler)
+			r.Route("/projects", func(r chi.Router) {
+				r.Use(handlers.AuthMiddleware(authService))
+				r.Use(apiLimit)
+				r.Use(handlers.RequireRole(domain.UserRoleMember))
+				r.Get("/", appHandler.ListProjects)
+				r.Post("/", appHandler.CreateProject)
+				r.Get("/{projectId}", appHandler.GetProject)
+				r.Put("/{projectId}", appHandler.UpdateProject)
+				r.Delete("/{projectId}", appHandler.DeleteProject)
+				r.Put("/{projectId}/env", appHandler.SetProjectEnvVars)
+				r.Delete("/{projectId}/env/{key}", appHandler.DeleteProjectEnvVar)
+				r.Post("/{projectId}/apps", appHandler.AddProjectApp)
+				r.Delete("/{projectId}/apps/{appId}", appHandler.RemoveProjectApp)
+				r.With(requireDocker).Post("/{projectId}/deploy", appHandler.DeployProject)
+				r.With(requireDocker).Post("/{projectId}/stop", appHandler.StopProject)
+			})
+
+			// Deployment approval for protected apps (protected, reviewers checked per handler)
+			r.Route("/deployments", func(r chi.Router) {
+				r.Use(handlers.AuthMiddleware(authService))
+				r.Use(apiLimit)
+				r.Use(handlers.RequireRole(domain.UserRoleMember))
+				r.Get("/pending", appHandler.ListPendingApprovals)
+				r.With(requireDocker).Post("/{deploymentId}/approve", appHandler.ApproveDeployment)
+				r.Post("/{deploymentId}/reject", appHandler.RejectDeployment)
+			})
+
+			// Container management (admin only)
+			r.Route("/containers", func(r chi.Router) {
+				r.Use(handlers.AuthMiddleware(authService))
+				r.Use(apiLimit)
+				r.Use(handlers.RequireRole(domain.UserRoleAdmin))
+				r.Use(requireDocker)
+				r.Get("/", containerHandler.List)
+				r.Post("/", containerHandler.Create)
+				r.Get("/{id}", containerHandler.Get)
+				r.Delete("/{id}", containerHandler.Delete)
+				r.Post("/{id}/start", containerHandler.Start)
+				r.Post("/{id}/stop", containerHandler.Stop)
+				r.Post("/{id}/restart", containerHandler.Restart)
+				r.Get("/{id}/logs", containerHandler.Logs)
+				r.Get("/{id}/files", containerHandler.DownloadFile)
+				r.Post("/{id}/files", containerHandler.UploadFile)
+				r.Get("/{id}/capture", containerHandler.Capture)
+			})
+
+			// Team routes (protected)
+			r.Route("/teams", func(r chi.Router) {
+				r.Use(handlers.AuthMiddleware(authService))
+				r.Use(apiLimit)
+				r.Use(handlers.RequireRole(domain.UserRoleMember))
+				r.Get("/{teamId}/cost-estimate", appHandler.TeamCostEstimate)
+			})
+
+			// Image promotion history (protected)
+			r.Route("/promotions", func(r chi.Router) {
+				r.Use(handlers.AuthMiddleware(authService))
+				r.Use(apiLimit)
+				r.Use(handlers.RequireRole(domain.UserRoleMember))
+				r.Get("/", promotionHandler.List)
+			})
+
+			// Image inspection (protected)
+			r.Route("/images", func(r chi.Router) {
+				r.Use(handlers.AuthMiddleware(authService))
+				r.Use(apiLimit)
+				r.Use(handlers.RequireRole(domain.UserRoleMember))
+				r.Use(requireDocker)
+				r.Get("/", imageHandler.List)
+				r.Get("/{id}", imageHandler.Get)
+				r.Post("/{id}/promote", promotionHandler.Promote)
+			})
+
+			// Container host disk usage and cleanup (admin only)
+			r.Route("/system", func(r chi.Router) {
+				r.Use(handlers.AuthMiddleware(authService))
+				r.Use(apiLimit)
+				r.Use(handlers.RequireRole(domain.UserRoleAdmin))
+				r.Use(requireDocker)
+				r.Get("/disk-usage", systemHandler.DiskUsage)
+				r.Post("/prune", systemHandler.Prune)
+			})
+
+			// Notification inbox routes (protected)
+			r.Route("/notifications", func(r chi.Router) {
+				r.Use(handlers.AuthMiddleware(authService))
+				r.Use(apiLimit)
+				r.Get("/", notificationHandler.List)
+				r.Post("/read-all", notificationHandler.MarkAllRead)
+				r.Post("/{id}/read", notificationHandler.MarkRead)
+			})
+
+			// Admin routes (admin only)
+			r.Route("/admin", func(r chi.Router) {
+				r.Use(handlers.AuthMiddleware(authService))
+				r.Use(apiLimit)
+				r.Use(handlers.RequireRole(domain.UserRoleAdmin))
+				r.Get("/maintenance", maintenanceHandler.Report)
+				r.Post("/maintenance/run", maintenanceHandler.Run)
+				r.Get("/deprecations", deprecationHandler.Usage)
+				r.Get("/users", userHandler.List)
+				r.Get("/users/{userId}", userHandler.Get)
+				r.Put("/users/{userId}/role", userHandler.UpdateRole)
+				r.Post("/users/{userId}/deactivate", userHandler.Deactivate)
+				r.Post("/users/{userId}/reactivate", userHandler.Reactivate)
+				r.Delete("/users/{userId}", userHandler.Delete)
+				r.Delete("/users/{userId}/2fa", userHandler.ResetTwoFactor)
+				r.Get("/users/{userId}/logins", userHandler.ListLogins)
+				r.Get("/certificates", certificateHandler.List)
+				r.Post("/certificates", certificateHandler.Issue)
+				r.Post("/certificates/{domain}/renew", certificateHandler.Renew)
+				r.Delete("/certificates/{domain}", certificateHandler.Delete)
+				r.Get("/notification-channels", notificationChannelHandler.List)
+				r.Post("/notification-channels", notificationChannelHandler.Create)
+				r.Put("/notification-channels/{channelId}", notificationChannelHandler.Update)
+				r.Delete("/notification-channels/{channelId}", notificationChannelHandler.Delete)
+				r.Get("/notification-channels/{channelId}/deliveries", notificationChannelHandler.Deliveries)
+				r.Post("/notification-channels/{channelId}/test", notificationChannelHandler.Test)
+			})
+		}
+	}
+	r.Route("/api/v1", apiRoutes(1))
+	r.Route("/api/v2", apiRoutes(2))
 
 	// Create server
 	server := &http.Server{
@@ -309,15 +1091,45 @@ func main() {
 
 		// 3. Stop WebSocket hub
 		logger.Info("Stopping WebSocket hub...")
+		logStreamer.Stop()
+		if hubBridge != nil {
+			hubBridge.Stop()
+		}
+		if redisBreaker != nil {
+			redisBreaker.Stop()
+		}
+		if redisClient != nil {
+			redisClient.Close()
+		}
 		wsHub.Stop()
 		logger.Info("WebSocket hub stopped")
 
-		// 4. Close database connection pool
-		logger.Info("Closing database connections...")
-		dbPool.Close()
-		logger.Info("Database connections closed")
+		// 4. Flush shipped logs, stop notification retries, scheduled maintenance, change notifications, certificate renewal and key rotation, then close database connection pool
+		if logShipper != nil {
+			logShipper.Stop()
+		}
+		if signingKeys != nil {
+			signingKeys.Stop()
+		}
+		dispatcher.Stop()
+		maintenanceService.Stop()
+		if changeListener != nil {
+			changeListener.Stop()
+		}
+		if dbBreaker != nil {
+			dbBreaker.Stop()
+		}
+		if certManager != nil {
+			certManager.Stop()
+		}
+		if dbPool != nil {
+			logger.Info("Closing database connections...")
+			dbPool.Close()
+			logger.Info("Database connections closed")
+		}
 
-		// 5. Close Docker client
+		// 5. Stop daemon probes and close Docker client
+		dockerAvailability.Stop()
 		logger.Info("Closing Docker client...")
 		if err := dockerClient.Close(); err != nil {
 			logger.Error("Docker client close error", zap.Error(err))
@@ -339,6 +1151,21 @@ func main() {
 	logger.Info("Server stopped")
 }
 
+// timeoutMiddleware cancels requests after the timeout, except Server-Sent Event streams
+// which stay open until the client leaves
+func timeoutMiddleware(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		timed := middleware.Timeout(timeout)(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if handlers.IsEventStream(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			timed.ServeHTTP(w, r)
+		})
+	}
+}
+
 // corsMiddleware creates a CORS middleware with the specified allowed origins
 func corsMiddleware(allowedOrigins []string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -361,7 +1188,7 @@ func corsMiddleware(allowedOrigins []string) func(http.Handler) http.Handler {
 			}
 
 			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS, PATCH")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Last-Event-ID, X-CSRF-Token")
 			w.Header().Set("Access-Control-Allow-Credentials", "true")
 
 			if r.Method == "OPTIONS" {
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/config"
+	"github.com/nanopaas/nanopaas/internal/repository/postgres"
+	"github.com/nanopaas/nanopaas/migrations"
+)
+
+const migrateUsage = `usage: nanopaas migrate [up|status]
+
+  up      apply pending migrations (default)
+  status  list migrations and when each was applied`
+
+// runMigrate implements `nanopaas migrate`, which applies or lists the embedded schema
+// migrations using the POSTGRES_* settings
+func runMigrate(cfg *config.Config, args []string, logger *zap.Logger) error {
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+	if len(args) > 1 || (command != "up" && command != "status") {
+		return fmt.Errorf("unexpected arguments %q\n%s", args, migrateUsage)
+	}
+
+	dbPool, err := openDatabase(cfg.Postgres, "nanopaas-migrate")
+	if err != nil {
+		return err
+	}
+	defer dbPool.Close()
+
+	migrator := postgres.NewMigrator(dbPool, migrations.FS, logger)
+	ctx := context.Background()
+
+	if command == "status" {
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "MIGRATION\tAPPLIED")
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = status.AppliedAt.Local().Format(time.RFC3339)
+			}
+			if status.Modified {
+				applied += " (file changed since)"
+			}
+			fmt.Fprintf(w, "%s\t%s\n", status.Name, applied)
+		}
+		return w.Flush()
+	}
+
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Applied %d migration(s)\n", applied)
+	return nil
+}
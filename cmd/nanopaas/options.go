@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+const usage = `usage: nanopaas [--config FILE] [--print-config] [migrate [up|status]]
+
+Settings come from environment variables, then the config file, then defaults.
+
+  --config FILE    read settings from a YAML file (default $NANOPAAS_CONFIG)
+  --print-config   print the effective settings, secrets redacted, and exit
+  migrate          apply or list schema migrations instead of serving`
+
+// options are the command-line options of the server
+type options struct {
+	configPath  string
+	printConfig bool
+	args        []string // a subcommand and its arguments
+}
+
+// parseOptions parses the command line, exiting with the usage on a bad flag or command.
+// Flags come before the subcommand.
+func parseOptions(args []string) options {
+	var opts options
+	fs := flag.NewFlagSet("nanopaas", flag.ExitOnError)
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, usage) }
+	fs.StringVar(&opts.configPath, "config", os.Getenv("NANOPAAS_CONFIG"), "")
+	fs.BoolVar(&opts.printConfig, "print-config", false, "")
+	fs.Parse(args)
+	opts.args = fs.Args()
+	if len(opts.args) > 0 && opts.args[0] != "migrate" {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n%s\n", opts.args[0], usage)
+		os.Exit(2)
+	}
+	return opts
+}
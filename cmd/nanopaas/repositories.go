@@ -0,0 +1,131 @@
+package main
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/handlers"
+	"github.com/nanopaas/nanopaas/internal/repository/memory"
+	"github.com/nanopaas/nanopaas/internal/repository/postgres"
+	"github.com/nanopaas/nanopaas/internal/services/acme"
+	"github.com/nanopaas/nanopaas/internal/services/auth"
+	"github.com/nanopaas/nanopaas/internal/services/builder"
+	"github.com/nanopaas/nanopaas/internal/services/logstream"
+	"github.com/nanopaas/nanopaas/internal/services/notify"
+)
+
+// Repositories used by more than one consumer, typed as the union of what they need
+type (
+	appRepository interface {
+		handlers.AppStore
+		handlers.WebhookApps
+	}
+	notificationRepository interface {
+		notify.Inbox
+		handlers.NotificationStore
+	}
+	notificationChannelRepository interface {
+		notify.ChannelStore
+		handlers.NotificationChannelStore
+	}
+	buildRepository interface {
+		handlers.BuildRecorder
+		handlers.BuildLookup
+		builder.BuildStore
+	}
+	buildLogRepository interface {
+		handlers.BuildLogReader
+		builder.BuildLogStore
+	}
+	appLogRepository interface {
+		logstream.LogStore
+		handlers.LogSearcher
+	}
+)
+
+// repositories holds every store the server persists to, backed by PostgreSQL or, in
+// development, by process memory
+type repositories struct {
+	tx handlers.Transactor // groups writes to several repositories
+
+	users          auth.UserRepository
+	apiKeys        auth.APIKeyRepository
+	deployTokens   auth.DeployTokenRepository
+	loginEvents    auth.LoginEventRepository
+	userTokens     auth.UserTokenRepository
+	identities     auth.IdentityRepository
+	gitConnections handlers.GitConnectionStore
+	certificates   acme.Store
+
+	apps                 appRepository
+	builds               buildRepository
+	buildLogs            buildLogRepository
+	notifications        notificationRepository
+	notificationChannels notificationChannelRepository
+	teams                handlers.TeamMembershipSource
+	incidents            handlers.IncidentStore
+	customDomains        handlers.CustomDomainStore
+	projects             handlers.ProjectStore
+	collaborators        handlers.CollaboratorStore
+	promotions           handlers.PromotionStore
+	appLogs              appLogRepository
+}
+
+// postgresRepositories creates the repositories on a PostgreSQL pool
+func postgresRepositories(pool *pgxpool.Pool, logger *zap.Logger) *repositories {
+	return &repositories{
+		tx: postgres.NewTransactor(pool),
+
+		users:          postgres.NewUserRepository(pool, logger),
+		apiKeys:        postgres.NewAPIKeyRepository(pool, logger),
+		deployTokens:   postgres.NewDeployTokenRepository(pool, logger),
+		loginEvents:    postgres.NewLoginEventRepository(pool, logger),
+		userTokens:     postgres.NewUserTokenRepository(pool, logger),
+		identities:     postgres.NewUserIdentityRepository(pool, logger),
+		gitConnections: postgres.NewGitConnectionRepository(pool, logger),
+		certificates:   postgres.NewCertificateRepository(pool, logger),
+
+		apps:                 postgres.NewAppRepository(pool, logger),
+		builds:               postgres.NewBuildRepository(pool, logger),
+		buildLogs:            postgres.NewBuildLogRepository(pool, logger),
+		notifications:        postgres.NewNotificationRepository(pool, logger),
+		notificationChannels: postgres.NewNotificationChannelRepository(pool, logger),
+		teams:                postgres.NewTeamRepository(pool, logger),
+		incidents:            postgres.NewIncidentRepository(pool, logger),
+		customDomains:        postgres.NewCustomDomainRepository(pool, logger),
+		projects:             postgres.NewProjectRepository(pool, logger),
+		collaborators:        postgres.NewAppCollaboratorRepository(pool, logger),
+		promotions:           postgres.NewPromotionRepository(pool, logger),
+		appLogs:              postgres.NewAppLogRepository(pool, logger),
+	}
+}
+
+// memoryRepositories creates the repositories on one in-memory store
+func memoryRepositories() *repositories {
+	store := memory.NewStore()
+	return &repositories{
+		tx: store,
+
+		users:          memory.NewUserRepository(store),
+		apiKeys:        memory.NewAPIKeyRepository(store),
+		deployTokens:   memory.NewDeployTokenRepository(store),
+		loginEvents:    memory.NewLoginEventRepository(store),
+		userTokens:     memory.NewUserTokenRepository(store),
+		identities:     memory.NewUserIdentityRepository(store),
+		gitConnections: memory.NewGitConnectionRepository(store),
+		certificates:   memory.NewCertificateRepository(store),
+
+		apps:                 memory.NewAppRepository(store),
+		builds:               memory.NewBuildRepository(store),
+		buildLogs:            memory.NewBuildLogRepository(store),
+		notifications:        memory.NewNotificationRepository(store),
+		notificationChannels: memory.NewNotificationChannelRepository(store),
+		teams:                memory.NewTeamRepository(store),
+		incidents:            memory.NewIncidentRepository(store),
+		customDomains:        memory.NewCustomDomainRepository(store),
+		projects:             memory.NewProjectRepository(store),
+		collaborators:        memory.NewAppCollaboratorRepository(store),
+		promotions:           memory.NewPromotionRepository(store),
+		appLogs:              memory.NewAppLogRepository(store),
+	}
+}
@@ -93,7 +93,8 @@ export default function AppDetails() {
         let reconnectTimer: number | null = null
 
         const connect = () => {
-            ws = new WebSocket(wsUrl)
+            const token = api.getToken()
+            ws = new WebSocket(wsUrl, token ? ['nanopaas', `bearer.${token}`] : undefined)
 
             ws.onopen = () => {
                 console.log('WebSocket connected for logs')
@@ -102,8 +103,8 @@ export default function AppDetails() {
             ws.onmessage = (event) => {
                 try {
                     const data = JSON.parse(event.data)
-                    if (data.type === 'log' && data.data) {
-                        setLogs(prev => [...prev.slice(-500), data.data])
+                    if (data.type === 'log' && data.payload) {
+                        setLogs(prev => [...prev.slice(-500), data.payload.content])
                     }
                 } catch {
                     // Plain text log
@@ -1,6 +1,8 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"strconv"
 	"strings"
@@ -15,7 +17,21 @@ type Config struct {
 	Redis    RedisConfig
 	Router   RouterConfig
 	GitHub   GitHubConfig
+	GitLab   GitLabConfig
+	Gitea    GiteaConfig
+	OIDC     OIDCConfig
 	Auth     AuthConfig
+	Cost     CostConfig
+	Build    BuildConfig
+
+	Maintenance MaintenanceConfig
+	ACME        ACMEConfig
+	WebSocket   WebSocketConfig
+	Logs        LogsConfig
+	RateLimit   RateLimitConfig
+	SMTP        SMTPConfig
+
+	settings []Setting // as resolved by Load, in the order it read them
 }
 
 // ServerConfig holds HTTP server configuration
@@ -25,17 +41,34 @@ type ServerConfig struct {
 	ReadTimeout     time.Duration
 	WriteTimeout    time.Duration
 	ShutdownTimeout time.Duration
+	APIDocs         bool          // serve Swagger UI at /api/vN/docs
+	IdempotencyTTL  time.Duration // how long Idempotency-Key responses are replayed from Redis, 0 disables
+	PublicURL       string        // where git providers reach the API for webhooks, taken from requests when empty
+
+	// Storage is "postgres", or "memory" to keep all data in process memory, for
+	// development without a database. Nothing survives a restart in memory.
+	Storage string
+
+	// Environment is "production", where Validate rejects insecure settings, or
+	// "development", where they are only reported by Insecure
+	Environment string
 }
 
 // DockerConfig holds Docker daemon configuration
 type DockerConfig struct {
+	Runtime         string // "docker" or "podman"
 	Host            string
 	APIVersion      string
 	TLSVerify       bool
 	CertPath        string
 	RegistryAuth    string
+	Registry        string // registry host promoted images are pushed to; empty disables pushing
 	DefaultNetwork  string
 	ContainerPrefix string
+	CaptureImage    string // image providing tcpdump for on-demand packet captures
+
+	// How often the daemon is pinged; container operations get 503 while it is unreachable
+	CheckInterval time.Duration
 }
 
 // PostgresConfig holds PostgreSQL configuration
@@ -47,6 +80,12 @@ type PostgresConfig struct {
 	Database string
 	SSLMode  string
 	PoolSize int
+
+	// AutoMigrate applies pending schema migrations at startup
+	AutoMigrate bool
+
+	// How often the database is pinged; the API gets 503 while it is unreachable
+	CheckInterval time.Duration
 }
 
 // RedisConfig holds Redis configuration
@@ -55,16 +94,33 @@ type RedisConfig struct {
 	Port     int
 	Password string
 	DB       int
+
+	// How often Redis is pinged; commands fail fast while it is unreachable
+	CheckInterval time.Duration
 }
 
 // RouterConfig holds reverse proxy configuration
 type RouterConfig struct {
-	Domain      string
-	TraefikAPI  string
-	ConfigPath  string
-	HTTPPort    int
-	HTTPSPort   int
-	EnableHTTPS bool
+	Backend        string // "traefik" or "caddy"
+	Domain         string
+	TraefikAPI     string
+	MetricsURL     string   // Traefik's Prometheus endpoint, for per-app traffic
+	ApexApp        string   // slug of the app also served on the bare domain
+	Reserved       []string // subdomains apps can't claim
+	DNSCheck       bool     // check the wildcard (and apex) records at startup
+	ConfigPath     string
+	HTTPPort       int
+	HTTPSPort      int
+	EnableHTTPS    bool
+	RedirectHTTP   bool          // redirect plain HTTP to HTTPS when HTTPS is enabled
+	MaintenanceURL string        // NanoPaaS as reached by Traefik, serving maintenance pages
+	TCPPorts       string        // router ports for TCP routes, e.g. "10000-10019"
+	UDPPorts       string        // router ports for UDP routes
+	Provider       string        // "file" or "http"
+	ProviderToken  string        // bearer token Traefik's HTTP provider sends
+	VerifyTimeout  time.Duration // wait for the proxy to serve a new route, 0 disables verification
+	CaddyAdminURL  string        // Caddy admin API, used by the caddy backend
+	CaddyServer    string        // name of the Caddy HTTP server NanoPaaS manages
 }
 
 // GitHubConfig holds GitHub OAuth configuration
@@ -74,6 +130,45 @@ type GitHubConfig struct {
 	WebhookSecret string
 	RedirectURI   string
 	Scopes        []string
+
+	// How long users' repository listings are cached in Redis; 0 disables the cache
+	RepoCacheTTL time.Duration
+
+	// GitHub App; repository access uses installation tokens when AppID is set
+	AppID             int64
+	AppSlug           string
+	AppPrivateKey     string // PEM
+	AppPrivateKeyPath string // PEM file, used when AppPrivateKey is empty
+}
+
+// GitLabConfig holds GitLab settings. Users connect with OAuth when ClientID is set, and
+// with personal access tokens either way.
+type GitLabConfig struct {
+	BaseURL       string // GitLab.com or a self-managed instance
+	ClientID      string
+	ClientSecret  string
+	RedirectURI   string
+	WebhookSecret string
+}
+
+// GiteaConfig holds Gitea or Forgejo settings; the provider is off without a base URL
+type GiteaConfig struct {
+	BaseURL       string
+	WebhookSecret string
+}
+
+// OIDCConfig holds single sign-on settings; SSO is off without an issuer
+type OIDCConfig struct {
+	Name         string
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURI  string
+	Scopes       []string
+	RoleClaim    string   // claim or dotted path listing groups or roles, e.g. groups or realm_access.roles
+	AdminValues  []string // claim values granting admin
+	ViewerValues []string // claim values granting viewer
+	DefaultRole  string   // role when no value matches
 }
 
 // AuthConfig holds authentication configuration
@@ -81,114 +176,453 @@ type AuthConfig struct {
 	JWTSecret        string
 	JWTExpiry        time.Duration
 	JWTRefreshExpiry time.Duration
+	JWTAlgorithm     string        // EdDSA, RS256 or HS256 with JWTSecret
+	JWTKeysDir       string        // PEM signing keys shared by replicas; in memory when empty
+	JWTKeyRotation   time.Duration // how often a new signing key is generated, 0 disables
 	FrontendURL      string
 	CORSOrigins      []string
+	WSOrigins        []string // Browser origins allowed to open WebSockets, CORSOrigins when empty
+
+	// Track sessions in Redis so logout and session revocation invalidate issued tokens
+	SessionRevocation bool
+
+	// Email/password accounts alongside GitHub OAuth; PasswordSignup lets anyone register
+	PasswordLogin  bool
+	PasswordSignup bool
+
+	// Bootstrap admins: users with these verified emails become admins when they sign
+	// in, and with FirstUserAdmin so does the first account of a new install
+	AdminEmails    []string
+	FirstUserAdmin bool
+
+	// TOTP two-factor authentication; with RequireTwoFactor every user must enroll
+	TwoFactorIssuer  string // name authenticator apps list the account under
+	RequireTwoFactor bool
+
+	// Browser cookies. CookieSecure sends them over HTTPS only and CookieSameSite is lax,
+	// strict or none. With CookieSession, sign-in tokens are delivered in httpOnly
+	// cookies instead of redirect URLs. StateSecret signs OAuth state cookies; replicas
+	// need the same one.
+	CookieSecure   bool
+	CookieSameSite string
+	CookieDomain   string
+	CookieSession  bool
+	StateSecret    string
+
+	// Base64-encoded 32-byte AES key encrypting app secrets; the secrets API is off without it
+	SecretsMasterKey string
+}
+
+// CostConfig holds admin-configured rates for cost estimation
+type CostConfig struct {
+	Currency         string
+	MemoryGBHourRate float64
+	VCPUHourRate     float64
+	BuildMinuteRate  float64
+}
+
+// BuildConfig holds build workspace settings
+type BuildConfig struct {
+	WorkspaceDriver     string // dir, tmpfs, zfs or quota
+	WorkspaceRoot       string
+	WorkspaceQuota      string // e.g. "2g"
+	WorkspaceZFSDataset string
+	StripANSI           bool   // Remove color codes from build output
+	TemplatesDir        string // Extra starter templates, *.yaml
+}
+
+// WebSocketConfig holds WebSocket hub settings
+type WebSocketConfig struct {
+	ReplaySize int           // Recent build and pull messages sent to late subscribers, 0 disables
+	ReplayTTL  time.Duration // How long an idle topic's messages are kept
+
+	// Relay broadcasts through Redis pub/sub so clients of every API replica receive them
+	RedisBridge bool
+}
+
+// LogsConfig holds app log history settings
+type LogsConfig struct {
+	ShippingEnabled bool // Store container logs in Postgres so they survive redeploys
+	RetentionDays   int  // Days of stored logs kept, 0 keeps them forever
 }
 
-// Load loads configuration from environment variables with defaults
-func Load() *Config {
+// RateLimitConfig holds API rate limits, counted in Redis per user or client IP
+type RateLimitConfig struct {
+	Enabled       bool
+	Window        time.Duration
+	APIRequests   int // per window on every authenticated route
+	AuthRequests  int // per window on login and token routes
+	BuildRequests int // per window on build submission
+}
+
+// MaintenanceConfig holds scheduled database maintenance settings
+type MaintenanceConfig struct {
+	Enabled                 bool
+	Interval                time.Duration
+	BuildRetentionDays      int // 0 keeps builds forever
+	DeploymentRetentionDays int
+	BuildLogRetentionDays   int
+	PromotionRetentionDays  int
+	LoginEventRetentionDays int
+	KeepDeploymentsPerApp   int
+}
+
+// SMTPConfig holds the mail server verification and password reset emails are sent
+// through; without a host they are logged instead
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// ACMEConfig holds automatic certificate settings
+type ACMEConfig struct {
+	Enabled            bool
+	Email              string
+	DirectoryURL       string
+	Challenge          string // http-01 or dns-01
+	RenewBefore        time.Duration
+	CheckInterval      time.Duration
+	DNSProvider        string // cloudflare or exec, for dns-01
+	CloudflareToken    string
+	DNSExecCommand     string
+	DNSPropagationWait time.Duration
+	WildcardDomain     bool   // issue *.ROUTER_DOMAIN instead of one certificate per app (dns-01 only)
+	ChallengeURL       string // NanoPaaS API as reached by Traefik, for http-01
+}
+
+// Load loads configuration from environment variables, falling back to the YAML config
+// file at path, if any, and then to defaults. It fails on a file it can't read, a setting
+// the file names that doesn't exist, or a value that can't be parsed; Validate checks the
+// values themselves.
+func Load(path string) (*Config, error) {
+	l := &loader{seen: make(map[string]bool)}
+	if path != "" {
+		file, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		l.file = file
+	}
+
+	cfg := l.load()
+	cfg.settings = l.settings
+
+	for key, v := range l.file {
+		if !l.seen[key] {
+			l.errs = append(l.errs, fmt.Errorf("%s line %d: unknown setting %s", path, v.line, key))
+		}
+	}
+	if len(l.errs) > 0 {
+		sortErrors(l.errs)
+		return nil, errors.Join(l.errs...)
+	}
+	return cfg, nil
+}
+
+// load reads every setting into a Config
+func (l *loader) load() *Config {
 	return &Config{
 		Server: ServerConfig{
-			Host:            getEnv("SERVER_HOST", "0.0.0.0"),
-			Port:            getEnvInt("SERVER_PORT", 8080),
-			ReadTimeout:     getEnvDuration("SERVER_READ_TIMEOUT", 30*time.Second),
-			WriteTimeout:    getEnvDuration("SERVER_WRITE_TIMEOUT", 30*time.Second),
-			ShutdownTimeout: getEnvDuration("SERVER_SHUTDOWN_TIMEOUT", 15*time.Second),
+			Host:            l.getEnv("SERVER_HOST", "0.0.0.0"),
+			Port:            l.getEnvInt("SERVER_PORT", 8080),
+			ReadTimeout:     l.getEnvDuration("SERVER_READ_TIMEOUT", 30*time.Second),
+			WriteTimeout:    l.getEnvDuration("SERVER_WRITE_TIMEOUT", 30*time.Second),
+			ShutdownTimeout: l.getEnvDuration("SERVER_SHUTDOWN_TIMEOUT", 15*time.Second),
+			APIDocs:         l.getEnvBool("API_DOCS_ENABLED", true),
+			IdempotencyTTL:  l.getEnvDuration("IDEMPOTENCY_TTL", 24*time.Hour),
+			PublicURL:       l.getEnv("SERVER_PUBLIC_URL", ""),
+
+			Storage:     l.getEnv("STORAGE_DRIVER", "postgres"),
+			Environment: l.getEnv("NANOPAAS_ENV", EnvDevelopment),
 		},
 		Docker: DockerConfig{
-			Host:            getEnv("DOCKER_HOST", ""),
-			APIVersion:      getEnv("DOCKER_API_VERSION", "1.44"),
-			TLSVerify:       getEnvBool("DOCKER_TLS_VERIFY", false),
-			CertPath:        getEnv("DOCKER_CERT_PATH", ""),
-			RegistryAuth:    getEnv("DOCKER_REGISTRY_AUTH", ""),
-			DefaultNetwork:  getEnv("DOCKER_NETWORK", "nanopaas"),
-			ContainerPrefix: getEnv("DOCKER_CONTAINER_PREFIX", "nanopaas-"),
+			Runtime:         l.getEnv("CONTAINER_RUNTIME", "docker"),
+			Host:            l.getEnv("DOCKER_HOST", ""),
+			APIVersion:      l.getEnv("DOCKER_API_VERSION", "1.44"),
+			TLSVerify:       l.getEnvBool("DOCKER_TLS_VERIFY", false),
+			CertPath:        l.getEnv("DOCKER_CERT_PATH", ""),
+			RegistryAuth:    l.getEnv("DOCKER_REGISTRY_AUTH", ""),
+			Registry:        l.getEnv("DOCKER_REGISTRY", ""),
+			DefaultNetwork:  l.getEnv("DOCKER_NETWORK", "nanopaas"),
+			ContainerPrefix: l.getEnv("DOCKER_CONTAINER_PREFIX", "nanopaas-"),
+			CaptureImage:    l.getEnv("DOCKER_CAPTURE_IMAGE", "nicolaka/netshoot:latest"),
+			CheckInterval:   l.getEnvDuration("DOCKER_CHECK_INTERVAL", 10*time.Second),
 		},
 		Postgres: PostgresConfig{
-			Host:     getEnv("POSTGRES_HOST", "localhost"),
-			Port:     getEnvInt("POSTGRES_PORT", 5432),
-			User:     getEnv("POSTGRES_USER", "nanopaas"),
-			Password: getEnv("POSTGRES_PASSWORD", "nanopaas"),
-			Database: getEnv("POSTGRES_DB", "nanopaas"),
-			SSLMode:  getEnv("POSTGRES_SSL_MODE", "disable"),
-			PoolSize: getEnvInt("POSTGRES_POOL_SIZE", 10),
+			Host:     l.getEnv("POSTGRES_HOST", "localhost"),
+			Port:     l.getEnvInt("POSTGRES_PORT", 5432),
+			User:     l.getEnv("POSTGRES_USER", "nanopaas"),
+			Password: l.getEnv("POSTGRES_PASSWORD", "nanopaas"),
+			Database: l.getEnv("POSTGRES_DB", "nanopaas"),
+			SSLMode:  l.getEnv("POSTGRES_SSL_MODE", "disable"),
+			PoolSize: l.getEnvInt("POSTGRES_POOL_SIZE", 10),
+
+			AutoMigrate:   l.getEnvBool("POSTGRES_AUTO_MIGRATE", true),
+			CheckInterval: l.getEnvDuration("POSTGRES_CHECK_INTERVAL", 10*time.Second),
 		},
 		Redis: RedisConfig{
-			Host:     getEnv("REDIS_HOST", "localhost"),
-			Port:     getEnvInt("REDIS_PORT", 6379),
-			Password: getEnv("REDIS_PASSWORD", ""),
-			DB:       getEnvInt("REDIS_DB", 0),
+			Host:     l.getEnv("REDIS_HOST", "localhost"),
+			Port:     l.getEnvInt("REDIS_PORT", 6379),
+			Password: l.getEnv("REDIS_PASSWORD", ""),
+			DB:       l.getEnvInt("REDIS_DB", 0),
+
+			CheckInterval: l.getEnvDuration("REDIS_CHECK_INTERVAL", 10*time.Second),
 		},
 		Router: RouterConfig{
-			Domain:      getEnv("ROUTER_DOMAIN", "localhost"),
-			TraefikAPI:  getEnv("TRAEFIK_API", "http://localhost:8081"),
-			ConfigPath:  getEnv("TRAEFIK_CONFIG_PATH", "./traefik/dynamic"),
-			HTTPPort:    getEnvInt("ROUTER_HTTP_PORT", 80),
-			HTTPSPort:   getEnvInt("ROUTER_HTTPS_PORT", 443),
-			EnableHTTPS: getEnvBool("ROUTER_ENABLE_HTTPS", false),
+			Domain:      l.getEnv("ROUTER_DOMAIN", "localhost"),
+			TraefikAPI:  l.getEnv("TRAEFIK_API", "http://localhost:8081"),
+			ConfigPath:  l.getEnv("TRAEFIK_CONFIG_PATH", "./traefik/dynamic"),
+			HTTPPort:    l.getEnvInt("ROUTER_HTTP_PORT", 80),
+			HTTPSPort:   l.getEnvInt("ROUTER_HTTPS_PORT", 443),
+			EnableHTTPS: l.getEnvBool("ROUTER_ENABLE_HTTPS", false),
+
+			RedirectHTTP:   l.getEnvBool("ROUTER_HTTPS_REDIRECT", true),
+			MaintenanceURL: l.getEnv("ROUTER_MAINTENANCE_URL", "http://nanopaas:8080"),
+			TCPPorts:       l.getEnv("ROUTER_TCP_PORTS", ""),
+			UDPPorts:       l.getEnv("ROUTER_UDP_PORTS", ""),
+			Provider:       l.getEnv("ROUTER_PROVIDER", "file"),
+			ProviderToken:  l.getEnv("ROUTER_PROVIDER_TOKEN", ""),
+			VerifyTimeout:  l.getEnvDuration("ROUTER_VERIFY_TIMEOUT", 0),
+			Backend:        l.getEnv("ROUTER_BACKEND", "traefik"),
+			MetricsURL:     l.getEnv("TRAEFIK_METRICS_URL", "http://localhost:8082/metrics"),
+			ApexApp:        l.getEnv("ROUTER_APEX_APP", ""),
+			Reserved:       l.getEnvSlice("ROUTER_RESERVED_SUBDOMAINS", []string{"api", "www", "traefik"}),
+			DNSCheck:       l.getEnvBool("ROUTER_DNS_CHECK", true),
+			CaddyAdminURL:  l.getEnv("CADDY_ADMIN_URL", "http://localhost:2019"),
+			CaddyServer:    l.getEnv("CADDY_SERVER_NAME", "nanopaas"),
 		},
 		GitHub: GitHubConfig{
-			ClientID:      getEnv("GITHUB_CLIENT_ID", ""),
-			ClientSecret:  getEnv("GITHUB_CLIENT_SECRET", ""),
-			WebhookSecret: getEnv("GITHUB_WEBHOOK_SECRET", ""),
-			RedirectURI:   getEnv("GITHUB_REDIRECT_URI", "http://localhost:8080/api/v1/auth/github/callback"),
-			Scopes:        []string{"user:email", "repo", "read:org"},
+			ClientID:          l.getEnv("GITHUB_CLIENT_ID", ""),
+			ClientSecret:      l.getEnv("GITHUB_CLIENT_SECRET", ""),
+			WebhookSecret:     l.getEnv("GITHUB_WEBHOOK_SECRET", ""),
+			RedirectURI:       l.getEnv("GITHUB_REDIRECT_URI", "http://localhost:8080/api/v1/auth/github/callback"),
+			Scopes:            []string{"user:email", "repo", "read:org"},
+			RepoCacheTTL:      l.getEnvDuration("GITHUB_REPO_CACHE_TTL", time.Minute),
+			AppID:             int64(l.getEnvInt("GITHUB_APP_ID", 0)),
+			AppSlug:           l.getEnv("GITHUB_APP_SLUG", ""),
+			AppPrivateKey:     l.getEnv("GITHUB_APP_PRIVATE_KEY", ""),
+			AppPrivateKeyPath: l.getEnv("GITHUB_APP_PRIVATE_KEY_PATH", ""),
+		},
+		GitLab: GitLabConfig{
+			BaseURL:       l.getEnv("GITLAB_BASE_URL", "https://gitlab.com"),
+			ClientID:      l.getEnv("GITLAB_CLIENT_ID", ""),
+			ClientSecret:  l.getEnv("GITLAB_CLIENT_SECRET", ""),
+			RedirectURI:   l.getEnv("GITLAB_REDIRECT_URI", "http://localhost:8080/api/v1/git/gitlab/callback"),
+			WebhookSecret: l.getEnv("GITLAB_WEBHOOK_SECRET", ""),
+		},
+		Gitea: GiteaConfig{
+			BaseURL:       l.getEnv("GITEA_BASE_URL", ""),
+			WebhookSecret: l.getEnv("GITEA_WEBHOOK_SECRET", ""),
+		},
+		OIDC: OIDCConfig{
+			Name:         l.getEnv("OIDC_NAME", "SSO"),
+			IssuerURL:    l.getEnv("OIDC_ISSUER_URL", ""),
+			ClientID:     l.getEnv("OIDC_CLIENT_ID", ""),
+			ClientSecret: l.getEnv("OIDC_CLIENT_SECRET", ""),
+			RedirectURI:  l.getEnv("OIDC_REDIRECT_URI", "http://localhost:8080/api/v1/auth/oidc/callback"),
+			Scopes:       l.getEnvSlice("OIDC_SCOPES", []string{"openid", "profile", "email"}),
+			RoleClaim:    l.getEnv("OIDC_ROLE_CLAIM", ""),
+			AdminValues:  l.getEnvSlice("OIDC_ADMIN_VALUES", nil),
+			ViewerValues: l.getEnvSlice("OIDC_VIEWER_VALUES", nil),
+			DefaultRole:  l.getEnv("OIDC_DEFAULT_ROLE", "member"),
 		},
 		Auth: AuthConfig{
-			JWTSecret:        getEnv("JWT_SECRET", "change-me-in-production"),
-			JWTExpiry:        getEnvDuration("JWT_EXPIRY", 24*time.Hour),
-			JWTRefreshExpiry: getEnvDuration("JWT_REFRESH_EXPIRY", 168*time.Hour),
-			FrontendURL:      getEnv("FRONTEND_URL", "http://localhost:3000"),
-			CORSOrigins:      getEnvSlice("CORS_ALLOWED_ORIGINS", []string{"http://localhost:3000", "http://localhost:8080"}),
+			JWTSecret:        l.getEnv("JWT_SECRET", defaultJWTSecret),
+			JWTExpiry:        l.getEnvDuration("JWT_EXPIRY", 24*time.Hour),
+			JWTRefreshExpiry: l.getEnvDuration("JWT_REFRESH_EXPIRY", 168*time.Hour),
+			JWTAlgorithm:     l.getEnv("JWT_ALGORITHM", "EdDSA"),
+			JWTKeysDir:       l.getEnv("JWT_KEYS_DIR", "./jwt-keys"),
+			JWTKeyRotation:   l.getEnvDuration("JWT_KEY_ROTATION", 0),
+			FrontendURL:      l.getEnv("FRONTEND_URL", "http://localhost:3000"),
+			CORSOrigins:      l.getEnvSlice("CORS_ALLOWED_ORIGINS", []string{"http://localhost:3000", "http://localhost:8080"}),
+			WSOrigins:        l.getEnvSlice("WS_ALLOWED_ORIGINS", nil),
+			SecretsMasterKey: l.getEnv("SECRETS_MASTER_KEY", ""),
+
+			SessionRevocation: l.getEnvBool("AUTH_SESSION_REVOCATION", true),
+
+			PasswordLogin:  l.getEnvBool("AUTH_PASSWORD_LOGIN", true),
+			PasswordSignup: l.getEnvBool("AUTH_PASSWORD_SIGNUP", true),
+
+			AdminEmails:    l.getEnvSlice("AUTH_ADMIN_EMAILS", nil),
+			FirstUserAdmin: l.getEnvBool("AUTH_FIRST_USER_ADMIN", true),
+
+			TwoFactorIssuer:  l.getEnv("AUTH_2FA_ISSUER", "NanoPaaS"),
+			RequireTwoFactor: l.getEnvBool("AUTH_REQUIRE_2FA", false),
+
+			CookieSecure:   l.getEnvBool("AUTH_COOKIE_SECURE", false),
+			CookieSameSite: l.getEnv("AUTH_COOKIE_SAMESITE", "lax"),
+			CookieDomain:   l.getEnv("AUTH_COOKIE_DOMAIN", ""),
+			CookieSession:  l.getEnvBool("AUTH_COOKIE_SESSION", false),
+			StateSecret:    l.getEnv("AUTH_STATE_SECRET", ""),
+		},
+		Cost: CostConfig{
+			Currency:         l.getEnv("COST_CURRENCY", "USD"),
+			MemoryGBHourRate: l.getEnvFloat("COST_MEMORY_GB_HOUR", 0.005),
+			VCPUHourRate:     l.getEnvFloat("COST_VCPU_HOUR", 0.02),
+			BuildMinuteRate:  l.getEnvFloat("COST_BUILD_MINUTE", 0.005),
+		},
+		Build: BuildConfig{
+			WorkspaceDriver:     l.getEnv("BUILD_WORKSPACE_DRIVER", "dir"),
+			WorkspaceRoot:       l.getEnv("BUILD_WORKSPACE_ROOT", ""),
+			WorkspaceQuota:      l.getEnv("BUILD_WORKSPACE_QUOTA", ""),
+			WorkspaceZFSDataset: l.getEnv("BUILD_WORKSPACE_ZFS_DATASET", ""),
+			StripANSI:           l.getEnvBool("BUILD_STRIP_ANSI", true),
+			TemplatesDir:        l.getEnv("TEMPLATES_DIR", ""),
+		},
+		WebSocket: WebSocketConfig{
+			ReplaySize: l.getEnvInt("WS_REPLAY_SIZE", 200),
+			ReplayTTL:  l.getEnvDuration("WS_REPLAY_TTL", 10*time.Minute),
+
+			RedisBridge: l.getEnvBool("WS_REDIS_BRIDGE", false),
+		},
+		Logs: LogsConfig{
+			ShippingEnabled: l.getEnvBool("LOG_SHIPPING_ENABLED", false),
+			RetentionDays:   l.getEnvInt("LOG_RETENTION_DAYS", 7),
+		},
+		RateLimit: RateLimitConfig{
+			Enabled:       l.getEnvBool("RATE_LIMIT_ENABLED", true),
+			Window:        l.getEnvDuration("RATE_LIMIT_WINDOW", time.Minute),
+			APIRequests:   l.getEnvInt("RATE_LIMIT_API_REQUESTS", 300),
+			AuthRequests:  l.getEnvInt("RATE_LIMIT_AUTH_REQUESTS", 20),
+			BuildRequests: l.getEnvInt("RATE_LIMIT_BUILD_REQUESTS", 30),
+		},
+		SMTP: SMTPConfig{
+			Host:     l.getEnv("SMTP_HOST", ""),
+			Port:     l.getEnvInt("SMTP_PORT", 587),
+			Username: l.getEnv("SMTP_USERNAME", ""),
+			Password: l.getEnv("SMTP_PASSWORD", ""),
+			From:     l.getEnv("SMTP_FROM", "NanoPaaS <noreply@localhost>"),
+		},
+		Maintenance: MaintenanceConfig{
+			Enabled:                 l.getEnvBool("MAINTENANCE_ENABLED", true),
+			Interval:                l.getEnvDuration("MAINTENANCE_INTERVAL", 24*time.Hour),
+			BuildRetentionDays:      l.getEnvInt("RETENTION_BUILD_DAYS", 90),
+			DeploymentRetentionDays: l.getEnvInt("RETENTION_DEPLOYMENT_DAYS", 90),
+			BuildLogRetentionDays:   l.getEnvInt("RETENTION_BUILD_LOG_DAYS", 90),
+			PromotionRetentionDays:  l.getEnvInt("RETENTION_PROMOTION_DAYS", 365),
+			LoginEventRetentionDays: l.getEnvInt("RETENTION_LOGIN_EVENT_DAYS", 90),
+			KeepDeploymentsPerApp:   l.getEnvInt("RETENTION_KEEP_DEPLOYMENTS", 10),
+		},
+		ACME: ACMEConfig{
+			Enabled:            l.getEnvBool("ACME_ENABLED", false),
+			Email:              l.getEnv("ACME_EMAIL", ""),
+			DirectoryURL:       l.getEnv("ACME_DIRECTORY_URL", "https://acme-v02.api.letsencrypt.org/directory"),
+			Challenge:          l.getEnv("ACME_CHALLENGE", "http-01"),
+			RenewBefore:        l.getEnvDuration("ACME_RENEW_BEFORE", 30*24*time.Hour),
+			CheckInterval:      l.getEnvDuration("ACME_CHECK_INTERVAL", 12*time.Hour),
+			DNSProvider:        l.getEnv("ACME_DNS_PROVIDER", ""),
+			CloudflareToken:    l.getEnv("ACME_CLOUDFLARE_API_TOKEN", ""),
+			DNSExecCommand:     l.getEnv("ACME_DNS_EXEC_COMMAND", ""),
+			DNSPropagationWait: l.getEnvDuration("ACME_DNS_PROPAGATION_WAIT", 60*time.Second),
+			WildcardDomain:     l.getEnvBool("ACME_WILDCARD", false),
+			ChallengeURL:       l.getEnv("ACME_CHALLENGE_URL", "http://nanopaas:8080"),
 		},
 	}
 }
 
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// loader resolves the settings Load reads: an environment variable wins, then the config
+// file, then the default. It records each setting it resolved and every value it could
+// not parse.
+type loader struct {
+	file     map[string]fileValue
+	seen     map[string]bool
+	settings []Setting
+	errs     []error
+}
+
+// lookup resolves a setting, returning its raw value, or false when it is unset and the
+// default applies
+func (l *loader) lookup(key string, defaultValue string) (string, bool) {
+	source, value := SourceDefault, defaultValue
+	if v := os.Getenv(key); v != "" {
+		source, value = SourceEnv, v
+	} else if v, ok := l.file[key]; ok && v.value != "" {
+		source, value = SourceFile, v.value
+	}
+	if !l.seen[key] {
+		l.seen[key] = true
+		l.settings = append(l.settings, Setting{Key: key, Value: value, Source: source})
 	}
-	return defaultValue
+	return value, source != SourceDefault
 }
 
-func getEnvInt(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
-		if intValue, err := strconv.Atoi(value); err == nil {
-			return intValue
-		}
+// invalid records a value that could not be parsed
+func (l *loader) invalid(key, value, want string) {
+	l.errs = append(l.errs, fmt.Errorf("%s: %q is not a valid %s", key, value, want))
+}
+
+func (l *loader) getEnv(key, defaultValue string) string {
+	value, _ := l.lookup(key, defaultValue)
+	return value
+}
+
+func (l *loader) getEnvInt(key string, defaultValue int) int {
+	value, set := l.lookup(key, strconv.Itoa(defaultValue))
+	if !set {
+		return defaultValue
+	}
+	intValue, err := strconv.Atoi(value)
+	if err != nil {
+		l.invalid(key, value, "integer")
+		return defaultValue
 	}
-	return defaultValue
+	return intValue
 }
 
-func getEnvBool(key string, defaultValue bool) bool {
-	if value := os.Getenv(key); value != "" {
-		if boolValue, err := strconv.ParseBool(value); err == nil {
-			return boolValue
-		}
+func (l *loader) getEnvFloat(key string, defaultValue float64) float64 {
+	value, set := l.lookup(key, strconv.FormatFloat(defaultValue, 'f', -1, 64))
+	if !set {
+		return defaultValue
 	}
-	return defaultValue
+	floatValue, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		l.invalid(key, value, "number")
+		return defaultValue
+	}
+	return floatValue
 }
 
-func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
-	if value := os.Getenv(key); value != "" {
-		if duration, err := time.ParseDuration(value); err == nil {
-			return duration
-		}
+func (l *loader) getEnvBool(key string, defaultValue bool) bool {
+	value, set := l.lookup(key, strconv.FormatBool(defaultValue))
+	if !set {
+		return defaultValue
+	}
+	boolValue, err := strconv.ParseBool(value)
+	if err != nil {
+		l.invalid(key, value, "boolean")
+		return defaultValue
 	}
-	return defaultValue
+	return boolValue
 }
 
-func getEnvSlice(key string, defaultValue []string) []string {
-	if value := os.Getenv(key); value != "" {
-		parts := strings.Split(value, ",")
-		result := make([]string, 0, len(parts))
-		for _, part := range parts {
-			trimmed := strings.TrimSpace(part)
-			if trimmed != "" {
-				result = append(result, trimmed)
-			}
+func (l *loader) getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, set := l.lookup(key, defaultValue.String())
+	if !set {
+		return defaultValue
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		l.invalid(key, value, "duration")
+		return defaultValue
+	}
+	return duration
+}
+
+func (l *loader) getEnvSlice(key string, defaultValue []string) []string {
+	value, set := l.lookup(key, strings.Join(defaultValue, ","))
+	if !set {
+		return defaultValue
+	}
+	parts := strings.Split(value, ",")
+	result := make([]string, 0, len(parts))
+	for _, part := range parts {
+		trimmed := strings.TrimSpace(part)
+		if trimmed != "" {
+			result = append(result, trimmed)
 		}
-		return result
 	}
-	return defaultValue
+	return result
 }
@@ -0,0 +1,229 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// fileValue is a setting from the config file and the line it is on
+type fileValue struct {
+	value string
+	line  int
+}
+
+// readFile reads a YAML config file into settings keyed by environment variable name
+func readFile(path string) (map[string]fileValue, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	settings, err := parseFile(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s %w", path, err)
+	}
+	return settings, nil
+}
+
+// parseFile parses the subset of YAML a config file is written in: nested mappings,
+// scalars, quoted or not, and lists, inline or one "- item" per line. A setting's name
+// is its path of keys joined with underscores and upper-cased, so
+//
+//	postgres:
+//	  host: db
+//	cors_allowed_origins: [https://a.example, https://b.example]
+//
+// sets POSTGRES_HOST=db and CORS_ALLOWED_ORIGINS=https://a.example,https://b.example.
+func parseFile(data string) (map[string]fileValue, error) {
+	settings := make(map[string]fileValue)
+
+	// Open mappings, innermost last, with the indent of their keys
+	type mapping struct {
+		indent int
+		prefix string
+	}
+	stack := []mapping{{indent: 0}}
+
+	// A key with no value opens a mapping or a list on the following lines
+	var open *fileValue
+	var openKey string
+	var openIndent int
+	var list []string
+	inList := false
+
+	set := func(key string, v fileValue) error {
+		if prev, ok := settings[key]; ok {
+			return fmt.Errorf("line %d: %s is already set on line %d", v.line, key, prev.line)
+		}
+		settings[key] = v
+		return nil
+	}
+	closeOpen := func() error {
+		if open == nil {
+			return nil
+		}
+		v := *open
+		if inList {
+			v.value = strings.Join(list, ",")
+		}
+		open, list, inList = nil, nil, false
+		return set(openKey, v)
+	}
+
+	for i, raw := range strings.Split(data, "\n") {
+		line := i + 1
+		text := strings.TrimRight(stripComment(strings.TrimSuffix(raw, "\r")), " ")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("line %d: indent with spaces, not tabs", line)
+		}
+		indent := len(text) - len(trimmed)
+		item, isItem := strings.CutPrefix(trimmed, "-")
+		isItem = isItem && (item == "" || item[0] == ' ')
+
+		if open != nil {
+			switch {
+			case isItem && indent >= openIndent:
+				inList = true
+				value, err := parseScalar(strings.TrimSpace(item))
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", line, err)
+				}
+				list = append(list, value)
+				continue
+			case !inList && indent > openIndent:
+				// The key opens a mapping
+				stack = append(stack, mapping{indent: indent, prefix: openKey + "_"})
+				open = nil
+			default:
+				if err := closeOpen(); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if isItem {
+			return nil, fmt.Errorf("line %d: list item without a key", line)
+		}
+
+		for len(stack) > 1 && indent < stack[len(stack)-1].indent {
+			stack = stack[:len(stack)-1]
+		}
+		current := stack[len(stack)-1]
+		if indent != current.indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", line)
+		}
+
+		key, value, ok := cutKey(trimmed)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key: value", line)
+		}
+		name := current.prefix + strings.ToUpper(key)
+
+		if value == "" {
+			open, openKey, openIndent = &fileValue{line: line}, name, indent
+			continue
+		}
+		if strings.HasPrefix(value, "{") {
+			return nil, fmt.Errorf("line %d: inline mappings are not supported; nest the keys instead", line)
+		}
+		if strings.HasPrefix(value, "[") {
+			value, err := parseInlineList(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			if err := set(name, fileValue{value: value, line: line}); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		scalar, err := parseScalar(value)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if err := set(name, fileValue{value: scalar, line: line}); err != nil {
+			return nil, err
+		}
+	}
+	if err := closeOpen(); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// cutKey splits a "key: value" line. Keys are letters, digits and underscores.
+func cutKey(s string) (key, value string, ok bool) {
+	key, value, ok = strings.Cut(s, ":")
+	if !ok || key == "" || (value != "" && value[0] != ' ') {
+		return "", "", false
+	}
+	for _, r := range key {
+		if !(r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			return "", "", false
+		}
+	}
+	return key, strings.TrimSpace(value), true
+}
+
+// parseScalar unquotes a scalar value
+func parseScalar(s string) (string, error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		value, err := strconv.Unquote(s)
+		if err != nil {
+			return "", fmt.Errorf("invalid double-quoted value %s", s)
+		}
+		return value, nil
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") {
+			return "", fmt.Errorf("invalid single-quoted value %s", s)
+		}
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	}
+	return s, nil
+}
+
+// parseInlineList parses a [a, b] list into the comma-separated form list settings take
+func parseInlineList(s string) (string, error) {
+	if !strings.HasSuffix(s, "]") {
+		return "", fmt.Errorf("unterminated list %s", s)
+	}
+	inner := strings.TrimSpace(s[1 : len(s)-1])
+	if inner == "" {
+		return "", nil
+	}
+	items := strings.Split(inner, ",")
+	for i, item := range items {
+		value, err := parseScalar(strings.TrimSpace(item))
+		if err != nil {
+			return "", err
+		}
+		items[i] = value
+	}
+	return strings.Join(items, ","), nil
+}
+
+// stripComment removes a # comment, which starts a line or follows a space outside a
+// quoted value, from a line
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && (i == 0 || strings.IndexByte(" [,", line[i-1]) >= 0):
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// Where a setting's value came from
+const (
+	SourceEnv     = "env"
+	SourceFile    = "file"
+	SourceDefault = "default"
+)
+
+// Setting is a configuration variable as Load resolved it
+type Setting struct {
+	Key    string
+	Value  string
+	Source string // env, file or default
+}
+
+// secretMarkers are parts of the names of settings holding credentials
+var secretMarkers = []string{"SECRET", "PASSWORD", "TOKEN", "PRIVATE_KEY", "MASTER_KEY", "REGISTRY_AUTH"}
+
+// IsSecret reports whether a setting holds a credential, which is never printed
+func IsSecret(key string) bool {
+	for _, marker := range secretMarkers {
+		if strings.Contains(key, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// Settings returns every setting as Load resolved it, in the order Config lists them,
+// with the values of secrets redacted
+func (c *Config) Settings() []Setting {
+	settings := make([]Setting, len(c.settings))
+	for i, s := range c.settings {
+		if IsSecret(s.Key) && s.Value != "" {
+			s.Value = "<redacted>"
+		}
+		settings[i] = s
+	}
+	return settings
+}
+
+// Print writes the effective configuration as a table of settings, their values and
+// where each came from, with secrets redacted
+func (c *Config) Print(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SETTING\tVALUE\tSOURCE")
+	for _, s := range c.Settings() {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", s.Key, s.Value, s.Source)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Environments NANOPAAS_ENV selects
+const (
+	EnvProduction  = "production"
+	EnvDevelopment = "development"
+)
+
+// defaultJWTSecret is JWT_SECRET's placeholder default
+const defaultJWTSecret = "change-me-in-production"
+
+// minJWTSecretLength is the shortest HS256 secret accepted in production, in bytes
+const minJWTSecretLength = 32
+
+// defaultPostgresPasswords are the database passwords the defaults and bundled compose
+// files use
+var defaultPostgresPasswords = map[string]bool{"nanopaas": true, "nanopaas_secret": true}
+
+// Validate checks that the settings make sense together, failing with every problem it
+// finds. In production, the insecure settings Insecure reports are problems too.
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	switch c.Server.Environment {
+	case EnvProduction, EnvDevelopment:
+	default:
+		fail("NANOPAAS_ENV: %q is not %s or %s", c.Server.Environment, EnvProduction, EnvDevelopment)
+	}
+	switch c.Server.Storage {
+	case "postgres", "memory":
+	default:
+		fail("STORAGE_DRIVER: %q is not postgres or memory", c.Server.Storage)
+	}
+	switch c.Auth.JWTAlgorithm {
+	case "EdDSA", "RS256", "HS256":
+	default:
+		fail("JWT_ALGORITHM: %q is not EdDSA, RS256 or HS256", c.Auth.JWTAlgorithm)
+	}
+
+	for key, port := range map[string]int{
+		"SERVER_PORT":   c.Server.Port,
+		"POSTGRES_PORT": c.Postgres.Port,
+		"REDIS_PORT":    c.Redis.Port,
+	} {
+		if port < 1 || port > 65535 {
+			fail("%s: %d is not a port", key, port)
+		}
+	}
+	if c.Postgres.PoolSize < 1 {
+		fail("POSTGRES_POOL_SIZE: must be at least 1")
+	}
+	// Background checks tick at these intervals, so each must be positive
+	for key, interval := range map[string]time.Duration{
+		"DOCKER_CHECK_INTERVAL":   c.Docker.CheckInterval,
+		"POSTGRES_CHECK_INTERVAL": c.Postgres.CheckInterval,
+		"REDIS_CHECK_INTERVAL":    c.Redis.CheckInterval,
+		"ACME_CHECK_INTERVAL":     c.ACME.CheckInterval,
+		"MAINTENANCE_INTERVAL":    c.Maintenance.Interval,
+	} {
+		if interval <= 0 {
+			fail("%s: must be positive", key)
+		}
+	}
+
+	if c.Server.Environment == EnvProduction {
+		for _, problem := range c.Insecure() {
+			fail("%s (NANOPAAS_ENV=production)", problem)
+		}
+	}
+
+	sortErrors(errs)
+	return errors.Join(errs...)
+}
+
+// Insecure lists settings that are fine for development but not for production: built-in
+// credentials, and keeping data in memory
+func (c *Config) Insecure() []string {
+	var problems []string
+	if c.Auth.JWTAlgorithm == "HS256" {
+		switch {
+		case c.Auth.JWTSecret == defaultJWTSecret:
+			problems = append(problems, "JWT_SECRET: the built-in placeholder signs tokens; set a random secret")
+		case len(c.Auth.JWTSecret) < minJWTSecretLength:
+			problems = append(problems, fmt.Sprintf("JWT_SECRET: must be at least %d bytes to sign tokens", minJWTSecretLength))
+		}
+	}
+	if c.Server.Storage == "memory" {
+		problems = append(problems, "STORAGE_DRIVER: memory loses all data when NanoPaaS stops")
+	}
+	if c.Server.Storage == "postgres" && defaultPostgresPasswords[c.Postgres.Password] {
+		problems = append(problems, "POSTGRES_PASSWORD: the built-in default is in use")
+	}
+	return problems
+}
+
+// sortErrors orders errors by message, so problems found in maps are reported stably
+func sortErrors(errs []error) {
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+}
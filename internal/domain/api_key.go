@@ -0,0 +1,64 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APIKeyPrefix starts every API key, telling keys apart from JWTs and making leaked keys
+// easy to search for
+const APIKeyPrefix = "npk_"
+
+// APIKeyScope limits what an API key can do
+type APIKeyScope string
+
+const (
+	APIKeyScopeFull   APIKeyScope = "full"   // everything the user can do, except managing API keys
+	APIKeyScopeDeploy APIKeyScope = "deploy" // read access plus builds, deploys, restarts and scaling
+	APIKeyScopeRead   APIKeyScope = "read"   // read-only requests
+)
+
+// ParseAPIKeyScope parses a scope name; empty means full
+func ParseAPIKeyScope(s string) (APIKeyScope, bool) {
+	switch APIKeyScope(s) {
+	case "", APIKeyScopeFull:
+		return APIKeyScopeFull, true
+	case APIKeyScopeDeploy, APIKeyScopeRead:
+		return APIKeyScope(s), true
+	}
+	return "", false
+}
+
+// APIKey is a long-lived credential for automation such as CI pipelines. Only a hash of
+// the key is stored; the key itself is shown once, when it is created.
+type APIKey struct {
+	ID         uuid.UUID   `json:"id"`
+	UserID     uuid.UUID   `json:"user_id"`
+	Name       string      `json:"name"`
+	Prefix     string      `json:"prefix"` // first characters of the key, to recognize it
+	Hash       string      `json:"-"`
+	Scope      APIKeyScope `json:"scope"`
+	ExpiresAt  *time.Time  `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time  `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time   `json:"created_at"`
+}
+
+// NewAPIKey creates an API key record for a key with the given hash
+func NewAPIKey(userID uuid.UUID, name, prefix, hash string, scope APIKeyScope, expiresAt *time.Time) *APIKey {
+	return &APIKey{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Name:      name,
+		Prefix:    prefix,
+		Hash:      hash,
+		Scope:     scope,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now().UTC(),
+	}
+}
+
+// IsExpired reports whether the key's expiry has passed
+func (k *APIKey) IsExpired() bool {
+	return k.ExpiresAt != nil && time.Now().After(*k.ExpiresAt)
+}
@@ -28,6 +28,14 @@ type App struct {
 	EnvVars     map[string]string `json:"env_vars,omitempty"`
 	Labels      map[string]string `json:"labels,omitempty"`
 
+	// Encrypted secrets by name, injected as env vars at container start and never serialized
+	Secrets map[string]Secret `json:"-"`
+
+	// How env var and secret changes reach running containers, and since when running
+	// containers predate such a change
+	EnvRestartPolicy    EnvRestartPolicy `json:"env_restart_policy,omitempty"`
+	PendingRestartSince *time.Time       `json:"pending_restart_since,omitempty"`
+
 	// Docker-related fields
 	CurrentImageID  string `json:"current_image_id,omitempty"`
 	PreviousImageID string `json:"previous_image_id,omitempty"`
@@ -38,16 +46,89 @@ type App struct {
 	MemoryLimit int64 `json:"memory_limit"` // in bytes
 	CPUQuota    int64 `json:"cpu_quota"`    // in microseconds
 
+	// Container runtime options (validated by ValidateRuntimeOptions)
+	RestartPolicy string            `json:"restart_policy"`
+	NoFileLimit   int64             `json:"nofile_limit,omitempty"` // 0 uses the daemon default
+	Tmpfs         map[string]string `json:"tmpfs,omitempty"`        // mount path -> options
+	ShmSize       int64             `json:"shm_size,omitempty"`     // in bytes, 0 uses the daemon default
+	Sysctls       map[string]string `json:"sysctls,omitempty"`
+	Command       []string          `json:"command,omitempty"` // overrides the image command when set
+	Volumes       []VolumeMount     `json:"volumes,omitempty"`
+
+	// Extra DNS names for the app's containers on the shared app network, e.g. compose service names
+	NetworkAliases []string `json:"network_aliases,omitempty"`
+
 	// Routing
 	Subdomain    string `json:"subdomain"`
 	ExposedPort  int    `json:"exposed_port"`
 	InternalPort int    `json:"internal_port,omitempty"`
 
-	// Git/CI integration
-	GitRepoURL string `json:"git_repo_url,omitempty"`
+	// Verified custom domains routed to the app alongside its subdomain
+	CustomDomains []string `json:"custom_domains,omitempty"`
+
+	// Long-lived connection tuning for the route (validated by ValidateStreaming)
+	StreamingMode     StreamingMode `json:"streaming_mode,omitempty"`
+	StreamIdleTimeout int           `json:"stream_idle_timeout,omitempty"` // seconds, 0 uses the default
+
+	// Git/CI integration. GitProvider names the host GitRepoURL is on, github when empty.
+	GitProvider string `json:"git_provider,omitempty"`
+	GitRepoURL  string `json:"git_repo_url,omitempty"`
 	GitBranch  string `json:"git_branch,omitempty"`
 	AutoDeploy bool   `json:"auto_deploy"`
 
+	// Auto-deploys happen on branch pushes, tag pushes or GitHub releases, branch when
+	// empty. Tags must match TagPattern, a glob, when it is set.
+	DeployTrigger DeployTrigger `json:"deploy_trigger,omitempty"`
+	TagPattern    string        `json:"tag_pattern,omitempty"`
+
+	// Auto-deploys from GitHub wait for the commit's required status checks to pass, and
+	// are dropped once the commit is no longer on the tracked branch
+	RequireChecks bool `json:"require_checks"`
+
+	// Webhook created on GitHub for the app; deleted from GitHub with the app
+	GitHubWebhook *GitHubWebhook `json:"github_webhook,omitempty"`
+
+	// Sealed secret signing the app's webhook deliveries instead of the global one, set
+	// when its first webhook is created and never serialized
+	WebhookSecret []byte `json:"-"`
+
+	// Protected environment: deployments of webhook-triggered builds wait for approval
+	RequireApproval bool `json:"require_approval"`
+
+	// Post-deploy verification
+	SmokeChecks []SmokeCheck `json:"smoke_checks,omitempty"`
+
+	// Cross-origin policy applied by the router; nil disables CORS handling
+	CORS *CORSPolicy `json:"cors,omitempty"`
+
+	// HTTP basic auth enforced by the router; the hash is bcrypt and never exposed
+	BasicAuthUser string `json:"basic_auth_user,omitempty"`
+	BasicAuthHash string `json:"-"`
+
+	// IP allowlist and rate limit enforced by the router; nil leaves traffic unrestricted
+	Routing *RoutingPolicy `json:"routing,omitempty"`
+
+	// Strict-Transport-Security sent on HTTPS responses; nil sends none
+	HSTS *HSTSPolicy `json:"hsts,omitempty"`
+
+	// Session affinity across replicas; nil balances every request independently
+	StickySessions *StickySessionPolicy `json:"sticky_sessions,omitempty"`
+
+	// Set while the router serves the maintenance page instead of the app
+	MaintenancePage *MaintenancePage `json:"maintenance_page,omitempty"`
+
+	// Set when the app is exposed over TCP or UDP instead of HTTP
+	NetworkRoute *NetworkRoute `json:"network_route,omitempty"`
+
+	// Most recent container exit observed by the orchestrator
+	LastExit *ContainerExit `json:"last_exit,omitempty"`
+
+	// Set while the current deployment is pinned against automatic replacement
+	Pin *DeploymentPin `json:"pin,omitempty"`
+
+	// Set while an open incident restricts the app's traffic
+	Lockdown *Lockdown `json:"lockdown,omitempty"`
+
 	// Timestamps
 	CreatedAt time.Time  `json:"created_at"`
 	UpdatedAt time.Time  `json:"updated_at"`
@@ -55,7 +136,12 @@ type App struct {
 	StoppedAt *time.Time `json:"stopped_at,omitempty"`
 
 	// Ownership
-	OwnerID uuid.UUID `json:"owner_id"`
+	OwnerID uuid.UUID  `json:"owner_id"`
+	TeamID  *uuid.UUID `json:"team_id,omitempty"`
+
+	// Project grouping; the project's shared env vars apply unless the app sets the same key
+	ProjectID  *uuid.UUID        `json:"project_id,omitempty"`
+	ProjectEnv map[string]string `json:"-"`
 }
 
 // NewApp creates a new application with defaults
@@ -72,8 +158,10 @@ func NewApp(name, slug string, ownerID uuid.UUID) *App {
 		TargetReplicas: 1,
 		MemoryLimit:    512 * 1024 * 1024, // 512MB default
 		CPUQuota:       50000,              // 50% of one CPU
+		RestartPolicy:  DefaultRestartPolicy,
 		Subdomain:      slug,
 		ExposedPort:    8080,
+		GitProvider:    GitProviderGitHub,
 		CreatedAt:      now,
 		UpdatedAt:      now,
 		OwnerID:        ownerID,
@@ -95,9 +183,15 @@ func (a *App) DeleteEnvVar(key string) {
 	a.UpdatedAt = time.Now().UTC()
 }
 
-// GetEnvSlice returns environment variables as a slice for Docker
+// GetEnvSlice returns the app's environment variables, over its project's shared ones,
+// as a slice for Docker
 func (a *App) GetEnvSlice() []string {
-	envs := make([]string, 0, len(a.EnvVars))
+	envs := make([]string, 0, len(a.ProjectEnv)+len(a.EnvVars))
+	for k, v := range a.ProjectEnv {
+		if _, overridden := a.EnvVars[k]; !overridden {
+			envs = append(envs, k+"="+v)
+		}
+	}
 	for k, v := range a.EnvVars {
 		envs = append(envs, k+"="+v)
 	}
@@ -168,6 +262,25 @@ func (a *App) UpdateImage(newImageID string) {
 	a.UpdatedAt = time.Now().UTC()
 }
 
+// RecordExit stores the latest container exit
+func (a *App) RecordExit(exit ContainerExit) {
+	a.LastExit = &exit
+	a.UpdatedAt = time.Now().UTC()
+}
+
+// IsProtected reports whether the app's route requires basic auth
+func (a *App) IsProtected() bool {
+	return a.BasicAuthUser != "" && a.BasicAuthHash != ""
+}
+
+// BasicAuthEntry returns the htpasswd-style "user:hash" entry for the router
+func (a *App) BasicAuthEntry() string {
+	if !a.IsProtected() {
+		return ""
+	}
+	return a.BasicAuthUser + ":" + a.BasicAuthHash
+}
+
 // GetContainerName returns the container name for a given replica
 func (a *App) GetContainerName(replica int) string {
 	if replica == 0 {
@@ -0,0 +1,48 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CollaboratorPermission is what a collaborator may do with an app
+type CollaboratorPermission string
+
+const (
+	CollaboratorRead   CollaboratorPermission = "read"   // read-only requests
+	CollaboratorDeploy CollaboratorPermission = "deploy" // read access plus builds, deploys, restarts and scaling
+)
+
+// IsValid reports whether the permission is known
+func (p CollaboratorPermission) IsValid() bool {
+	return p == CollaboratorRead || p == CollaboratorDeploy
+}
+
+// AppCollaborator grants a user access to a single app without making them a member
+// of the app's team
+type AppCollaborator struct {
+	AppID      uuid.UUID              `json:"app_id"`
+	UserID     uuid.UUID              `json:"user_id"`
+	Permission CollaboratorPermission `json:"permission"`
+	GrantedBy  *uuid.UUID             `json:"granted_by,omitempty"`
+	CreatedAt  time.Time              `json:"created_at"`
+	UpdatedAt  time.Time              `json:"updated_at"`
+
+	// Filled in from users when listed
+	Email string `json:"email,omitempty"`
+	Name  string `json:"name,omitempty"`
+}
+
+// NewAppCollaborator creates a collaborator grant
+func NewAppCollaborator(appID, userID uuid.UUID, permission CollaboratorPermission, grantedBy uuid.UUID) *AppCollaborator {
+	now := time.Now().UTC()
+	return &AppCollaborator{
+		AppID:      appID,
+		UserID:     userID,
+		Permission: permission,
+		GrantedBy:  &grantedBy,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+}
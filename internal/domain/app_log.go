@@ -0,0 +1,28 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AppLogEntry is a stored container log line of an app
+type AppLogEntry struct {
+	AppID         uuid.UUID `json:"app_id"`
+	ContainerID   string    `json:"container_id"`
+	ContainerName string    `json:"container_name,omitempty"`
+	Replica       *int      `json:"replica,omitempty"`
+	Stream        string    `json:"stream"`
+	Message       string    `json:"message"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+// AppLogQuery selects stored log lines of an app
+type AppLogQuery struct {
+	AppID  uuid.UUID
+	From   time.Time // zero for no lower bound
+	To     time.Time // zero for no upper bound
+	Search string    // case-insensitive substring of the message
+	Stream string    // stdout, stderr or empty for both
+	Limit  int
+}
@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// NewApprovalDeployment creates a deployment of an app's build that waits for an
+// admin or the app's owner to approve it
+func NewApprovalDeployment(app *App, buildID uuid.UUID, imageID string) *Deployment {
+	deployment := NewDeployment(app.ID, imageID, app.TargetReplicas)
+	deployment.BuildID = buildID
+	deployment.Status = DeploymentStatusAwaitingApproval
+	return deployment
+}
+
+// AwaitsApproval reports whether the deployment is waiting to be approved or rejected
+func (d *Deployment) AwaitsApproval() bool {
+	return d.Status == DeploymentStatusAwaitingApproval
+}
+
+// Approve records the approval and queues the deployment to run
+func (d *Deployment) Approve(by uuid.UUID) {
+	now := time.Now().UTC()
+	d.Status = DeploymentStatusPending
+	d.ReviewedBy = &by
+	d.ReviewedAt = &now
+}
+
+// Reject closes a deployment that was awaiting approval without running it. by is nil
+// when the deployment was superseded rather than rejected by a user.
+func (d *Deployment) Reject(by *uuid.UUID, reason string) {
+	now := time.Now().UTC()
+	d.Status = DeploymentStatusRejected
+	d.ReviewedBy = by
+	d.ReviewedAt = &now
+	d.CompletedAt = &now
+	d.RejectReason = reason
+}
@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -36,6 +37,13 @@ type Build struct {
 	SourceURL    string      `json:"source_url,omitempty"`
 	GitRef       string      `json:"git_ref,omitempty"`
 	GitCommit    string      `json:"git_commit,omitempty"`
+	GitTag       string      `json:"git_tag,omitempty"` // set on builds of pushed tags and releases
+
+	// Pushed commits of webhook-triggered builds, newest last. GitCommit is the head.
+	CommitMessage string   `json:"commit_message,omitempty"`
+	CommitAuthor  string   `json:"commit_author,omitempty"`
+	CompareURL    string   `json:"compare_url,omitempty"` // the push's diff on the git provider
+	Commits       []Commit `json:"commits,omitempty"`
 
 	// Docker build info
 	DockerfilePath string            `json:"dockerfile_path"`
@@ -58,6 +66,21 @@ type Build struct {
 	TriggerType string `json:"trigger_type,omitempty"` // manual, webhook, etc.
 }
 
+// BuildLogLine is one line of an archived build log
+type BuildLogLine struct {
+	Line      int       `json:"line"` // 1-based, in output order
+	Content   string    `json:"content"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Commit is a git commit built into an image
+type Commit struct {
+	SHA     string `json:"sha"`
+	Message string `json:"message"`
+	Author  string `json:"author,omitempty"`
+	URL     string `json:"url,omitempty"`
+}
+
 // NewBuild creates a new build
 func NewBuild(appID uuid.UUID, source BuildSource) *Build {
 	return &Build{
@@ -137,7 +160,28 @@ func (b *Build) SetLogsKey(key string) {
 	b.LogsKey = key
 }
 
-// GenerateImageTag generates the Docker image tag for this build
+// GenerateImageTag generates the Docker image tag for this build. Builds of git tags are
+// tagged with the git tag too, e.g. nanopaas/web:v1.2.0-1a2b3c4d, keeping rebuilds of a
+// tag apart.
 func (b *Build) GenerateImageTag(appSlug string) string {
+	if tag := dockerTagSafe(b.GitTag); tag != "" {
+		return "nanopaas/" + appSlug + ":" + tag + "-" + b.ID.String()[:8]
+	}
 	return "nanopaas/" + appSlug + ":" + b.ID.String()[:8]
 }
+
+// dockerTagSafe turns a git tag into a Docker tag, replacing characters Docker tags
+// can't hold and leaving room for the build ID suffix
+func dockerTagSafe(tag string) string {
+	safe := []byte(tag)
+	for i, c := range safe {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '.' || c == '-') {
+			safe[i] = '-'
+		}
+	}
+	result := strings.TrimLeft(string(safe), ".-")
+	if len(result) > 100 {
+		result = result[:100]
+	}
+	return result
+}
@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+// Certificate is a TLS certificate issued by an ACME CA
+type Certificate struct {
+	Domain    string    `json:"domain"`  // primary name, also the storage key
+	Domains   []string  `json:"domains"` // every name the certificate covers
+	CertPEM   []byte    `json:"-"`       // leaf followed by the issuer chain
+	KeyPEM    []byte    `json:"-"`
+	Issuer    string    `json:"issuer"`
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// NeedsRenewal reports whether the certificate expires within the given window
+func (c *Certificate) NeedsRenewal(window time.Duration) bool {
+	return time.Until(c.NotAfter) < window
+}
+
+// Covers reports whether the certificate is valid for a host name, including wildcard matches
+func (c *Certificate) Covers(host string) bool {
+	host = strings.ToLower(host)
+	for _, name := range c.Domains {
+		name = strings.ToLower(name)
+		if name == host {
+			return true
+		}
+		if suffix, ok := strings.CutPrefix(name, "*."); ok {
+			if label, rest, found := strings.Cut(host, "."); found && label != "" && rest == suffix {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ACMEAccount is the CA account certificates are ordered with
+type ACMEAccount struct {
+	Email     string    `json:"email"`
+	KeyPEM    []byte    `json:"-"`
+	URI       string    `json:"uri"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,81 @@
+package domain
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+// CloneOptions selects what a clone copies beyond the app's configuration
+type CloneOptions struct {
+	Image bool // copy the current image so the clone can be deployed right away
+}
+
+// Clone returns a new, never deployed app owned by ownerID with the configuration of a:
+// env vars, resources, runtime options and routing settings. State tied to the original
+// app is left out: containers, volumes, network aliases, TCP/UDP routes, custom domains,
+// maintenance pages, pins and lockdowns. Secrets are sealed for the original app's ID,
+// so they are left out too; copying them means re-encrypting them for the clone. A clone doesn't auto-deploy from the original's
+// repository until enabled.
+func (a *App) Clone(name, slug string, ownerID uuid.UUID, opts CloneOptions) *App {
+	c := NewApp(name, slug, ownerID)
+	c.Description = a.Description
+	c.TeamID = clonePtr(a.TeamID)
+	c.ProjectID = clonePtr(a.ProjectID)
+	c.ProjectEnv = a.ProjectEnv
+
+	c.EnvVars = maps.Clone(a.EnvVars)
+	if c.EnvVars == nil {
+		c.EnvVars = make(map[string]string)
+	}
+	c.Labels = maps.Clone(a.Labels)
+	if c.Labels == nil {
+		c.Labels = make(map[string]string)
+	}
+	c.EnvRestartPolicy = a.EnvRestartPolicy
+
+	if opts.Image {
+		c.CurrentImageID = a.CurrentImageID
+	}
+	c.TargetReplicas = a.TargetReplicas
+
+	c.MemoryLimit = a.MemoryLimit
+	c.CPUQuota = a.CPUQuota
+	c.RestartPolicy = a.RestartPolicy
+	c.NoFileLimit = a.NoFileLimit
+	c.Tmpfs = maps.Clone(a.Tmpfs)
+	c.ShmSize = a.ShmSize
+	c.Sysctls = maps.Clone(a.Sysctls)
+	c.Command = slices.Clone(a.Command)
+
+	c.ExposedPort = a.ExposedPort
+	c.InternalPort = a.InternalPort
+	c.StreamingMode = a.StreamingMode
+	c.StreamIdleTimeout = a.StreamIdleTimeout
+	c.GitProvider = a.GitProvider
+	c.GitRepoURL = a.GitRepoURL
+	c.GitBranch = a.GitBranch
+	c.DeployTrigger = a.DeployTrigger
+	c.TagPattern = a.TagPattern
+	c.RequireChecks = a.RequireChecks
+	c.RequireApproval = a.RequireApproval
+	c.SmokeChecks = slices.Clone(a.SmokeChecks)
+	c.CORS = clonePtr(a.CORS)
+	c.BasicAuthUser = a.BasicAuthUser
+	c.BasicAuthHash = a.BasicAuthHash
+	c.Routing = clonePtr(a.Routing)
+	c.HSTS = clonePtr(a.HSTS)
+	c.StickySessions = clonePtr(a.StickySessions)
+	return c
+}
+
+// clonePtr copies the value p points to. Policies are replaced rather than edited in
+// place, so a shallow copy is enough.
+func clonePtr[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+	v := *p
+	return &v
+}
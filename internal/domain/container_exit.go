@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// Common container exit codes (128 + signal for signal deaths)
+const (
+	ExitCodeNotExecutable = 126
+	ExitCodeNotFound      = 127
+	ExitCodeSIGKILL       = 137
+	ExitCodeSIGSEGV       = 139
+	ExitCodeSIGTERM       = 143
+)
+
+// ContainerExit records how a container last stopped, taken from its inspect state
+type ContainerExit struct {
+	ContainerID string    `json:"container_id"`
+	ExitCode    int       `json:"exit_code"`
+	OOMKilled   bool      `json:"oom_killed"`
+	Error       string    `json:"error,omitempty"` // daemon-reported error, e.g. a failed start
+	FinishedAt  time.Time `json:"finished_at"`
+}
+
+// Failed reports whether the exit indicates a problem rather than a clean stop
+func (e *ContainerExit) Failed() bool {
+	return e.OOMKilled || e.Error != "" || (e.ExitCode != 0 && e.ExitCode != ExitCodeSIGTERM)
+}
+
+// Hint returns an actionable explanation of the exit; memoryLimit is the app's limit in bytes
+func (e *ContainerExit) Hint(memoryLimit int64) string {
+	switch {
+	case e.OOMKilled:
+		if memoryLimit > 0 {
+			return fmt.Sprintf("exited %d — out of memory at the %d MiB limit, raise memory_limit", e.ExitCode, memoryLimit/(1024*1024))
+		}
+		return fmt.Sprintf("exited %d — out of memory, set or raise memory_limit", e.ExitCode)
+	case e.Error != "":
+		return fmt.Sprintf("failed to run — %s", e.Error)
+	}
+
+	switch e.ExitCode {
+	case 0:
+		return "exited 0 — the process finished; web apps must keep running in the foreground"
+	case ExitCodeNotExecutable:
+		return "exited 126 — the start command is not executable, check file permissions"
+	case ExitCodeNotFound:
+		return "exited 127 — the start command was not found, check the image CMD/ENTRYPOINT"
+	case ExitCodeSIGKILL:
+		return "exited 137 — killed by SIGKILL, likely a failed health check restart or a stop timeout"
+	case ExitCodeSIGSEGV:
+		return "exited 139 — segmentation fault in the application"
+	case ExitCodeSIGTERM:
+		return "exited 143 — stopped by SIGTERM"
+	default:
+		return fmt.Sprintf("exited %d — the application crashed, check its logs", e.ExitCode)
+	}
+}
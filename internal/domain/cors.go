@@ -0,0 +1,89 @@
+package domain
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	// MaxCORSOrigins limits the number of allowed origins per app
+	MaxCORSOrigins = 20
+
+	// MaxCORSMaxAge caps how long browsers may cache preflight responses (24h)
+	MaxCORSMaxAge = 86400
+)
+
+// allowedCORSMethods lists the HTTP methods that may be allowed cross-origin
+var allowedCORSMethods = map[string]bool{
+	"GET": true, "HEAD": true, "POST": true, "PUT": true,
+	"PATCH": true, "DELETE": true, "OPTIONS": true,
+}
+
+// corsHeaderPattern matches valid HTTP header names
+var corsHeaderPattern = regexp.MustCompile(`^[A-Za-z0-9-]{1,64}$`)
+
+// CORSPolicy is an app's cross-origin policy, enforced at the router
+type CORSPolicy struct {
+	AllowOrigins     []string `json:"allow_origins"` // exact origins, or "*"
+	AllowMethods     []string `json:"allow_methods,omitempty"`
+	AllowHeaders     []string `json:"allow_headers,omitempty"`
+	ExposeHeaders    []string `json:"expose_headers,omitempty"`
+	AllowCredentials bool     `json:"allow_credentials,omitempty"`
+	MaxAge           int      `json:"max_age,omitempty"` // preflight cache in seconds
+}
+
+// Normalize upper-cases methods and applies the default method list
+func (p *CORSPolicy) Normalize() {
+	if len(p.AllowMethods) == 0 {
+		p.AllowMethods = []string{"GET", "HEAD", "POST", "OPTIONS"}
+	}
+	for i, m := range p.AllowMethods {
+		p.AllowMethods[i] = strings.ToUpper(strings.TrimSpace(m))
+	}
+	for i, o := range p.AllowOrigins {
+		p.AllowOrigins[i] = strings.TrimRight(strings.TrimSpace(o), "/")
+	}
+}
+
+// Validate checks that the CORS policy is well-formed
+func (p *CORSPolicy) Validate() error {
+	if len(p.AllowOrigins) == 0 {
+		return fmt.Errorf("cors allow_origins must not be empty")
+	}
+	if len(p.AllowOrigins) > MaxCORSOrigins {
+		return fmt.Errorf("at most %d cors origins are allowed", MaxCORSOrigins)
+	}
+
+	for _, origin := range p.AllowOrigins {
+		if origin == "*" {
+			if p.AllowCredentials {
+				return fmt.Errorf("cors allow_credentials cannot be combined with origin *")
+			}
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" || u.RawQuery != "" {
+			return fmt.Errorf("invalid cors origin %q: must be scheme://host[:port]", origin)
+		}
+	}
+
+	for _, method := range p.AllowMethods {
+		if !allowedCORSMethods[method] {
+			return fmt.Errorf("cors method %q is not allowed", method)
+		}
+	}
+
+	for _, header := range append(append([]string{}, p.AllowHeaders...), p.ExposeHeaders...) {
+		if header != "*" && !corsHeaderPattern.MatchString(header) {
+			return fmt.Errorf("invalid cors header %q", header)
+		}
+	}
+
+	if p.MaxAge < 0 || p.MaxAge > MaxCORSMaxAge {
+		return fmt.Errorf("cors max_age must be between 0 and %d seconds", MaxCORSMaxAge)
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package domain
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CustomDomainStatus represents where a custom domain is in verification
+type CustomDomainStatus string
+
+const (
+	CustomDomainPending  CustomDomainStatus = "pending"
+	CustomDomainVerified CustomDomainStatus = "verified"
+	CustomDomainFailed   CustomDomainStatus = "failed"
+)
+
+// CustomDomainTXTPrefix is prepended to a hostname to form its verification TXT record name
+const CustomDomainTXTPrefix = "_nanopaas-verify."
+
+// CustomDomain is a user-owned host name attached to an app
+type CustomDomain struct {
+	ID       uuid.UUID          `json:"id"`
+	AppID    uuid.UUID          `json:"app_id"`
+	Hostname string             `json:"hostname"`
+	Status   CustomDomainStatus `json:"status"`
+
+	// Ownership is proven by publishing Token in a TXT record at TXTRecord, or by a
+	// CNAME from Hostname to the app's own host
+	Token     string `json:"token"`
+	TXTRecord string `json:"txt_record"`
+
+	LastError     string     `json:"last_error,omitempty"`
+	LastCheckedAt *time.Time `json:"last_checked_at,omitempty"`
+	VerifiedAt    *time.Time `json:"verified_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// NewCustomDomain creates a pending custom domain with a fresh verification token
+func NewCustomDomain(appID uuid.UUID, hostname string) *CustomDomain {
+	token := make([]byte, 16)
+	rand.Read(token)
+
+	hostname = NormalizeHostname(hostname)
+	return &CustomDomain{
+		ID:        uuid.New(),
+		AppID:     appID,
+		Hostname:  hostname,
+		Status:    CustomDomainPending,
+		Token:     "nanopaas-verify=" + hex.EncodeToString(token),
+		TXTRecord: CustomDomainTXTPrefix + hostname,
+		CreatedAt: time.Now().UTC(),
+	}
+}
+
+// IsVerified reports whether the domain is routed to its app
+func (d *CustomDomain) IsVerified() bool {
+	return d.Status == CustomDomainVerified
+}
+
+// MarkVerified records a successful verification
+func (d *CustomDomain) MarkVerified() {
+	now := time.Now().UTC()
+	d.Status = CustomDomainVerified
+	d.LastError = ""
+	d.LastCheckedAt = &now
+	d.VerifiedAt = &now
+}
+
+// MarkFailed records a failed verification attempt
+func (d *CustomDomain) MarkFailed(reason string) {
+	now := time.Now().UTC()
+	d.Status = CustomDomainFailed
+	d.LastError = reason
+	d.LastCheckedAt = &now
+}
+
+// NormalizeHostname lowercases a host name and strips a trailing dot
+func NormalizeHostname(hostname string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(hostname)), ".")
+}
+
+// ValidateHostname checks that a custom domain is a fully qualified DNS name
+func ValidateHostname(hostname string) error {
+	if len(hostname) == 0 || len(hostname) > 253 {
+		return fmt.Errorf("hostname must be between 1 and 253 characters")
+	}
+	labels := strings.Split(hostname, ".")
+	if len(labels) < 2 {
+		return fmt.Errorf("hostname must be fully qualified, e.g. api.example.com")
+	}
+	for _, label := range labels {
+		if len(label) == 0 || len(label) > 63 {
+			return fmt.Errorf("hostname labels must be between 1 and 63 characters")
+		}
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return fmt.Errorf("hostname labels must not start or end with a hyphen")
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z') && !(r >= '0' && r <= '9') && r != '-' {
+				return fmt.Errorf("hostname may only contain letters, digits, hyphens and dots")
+			}
+		}
+	}
+	return nil
+}
+
+// AddCustomDomain routes a verified host name to the app
+func (a *App) AddCustomDomain(hostname string) {
+	for _, existing := range a.CustomDomains {
+		if existing == hostname {
+			return
+		}
+	}
+	a.CustomDomains = append(a.CustomDomains, hostname)
+	a.UpdatedAt = time.Now().UTC()
+}
+
+// RemoveCustomDomain stops routing a host name to the app
+func (a *App) RemoveCustomDomain(hostname string) {
+	domains := make([]string, 0, len(a.CustomDomains))
+	for _, existing := range a.CustomDomains {
+		if existing != hostname {
+			domains = append(domains, existing)
+		}
+	}
+	a.CustomDomains = domains
+	a.UpdatedAt = time.Now().UTC()
+}
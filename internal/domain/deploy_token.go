@@ -0,0 +1,44 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DeployTokenPrefix starts every deploy token, telling them apart from API keys and JWTs
+const DeployTokenPrefix = "npd_"
+
+// DeployToken lets external CI build and deploy a single app. It acts as the user who
+// created it, but only for that app's builds and deploys. Only a hash of the token is
+// stored; the token itself is shown once, when it is created.
+type DeployToken struct {
+	ID         uuid.UUID  `json:"id"`
+	AppID      uuid.UUID  `json:"app_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"` // first characters of the token, to recognize it
+	Hash       string     `json:"-"`
+	CreatedBy  uuid.UUID  `json:"created_by"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// NewDeployToken creates a deploy token record for a token with the given hash
+func NewDeployToken(appID, createdBy uuid.UUID, name, prefix, hash string, expiresAt time.Time) *DeployToken {
+	return &DeployToken{
+		ID:        uuid.New(),
+		AppID:     appID,
+		Name:      name,
+		Prefix:    prefix,
+		Hash:      hash,
+		CreatedBy: createdBy,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now().UTC(),
+	}
+}
+
+// IsExpired reports whether the token's expiry has passed
+func (t *DeployToken) IsExpired() bool {
+	return time.Now().After(t.ExpiresAt)
+}
@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"fmt"
+	"path"
+)
+
+// DeployTrigger is the git event that auto-deploys an app
+type DeployTrigger string
+
+const (
+	DeployTriggerBranch  DeployTrigger = "branch"  // pushes to the tracked branch
+	DeployTriggerTag     DeployTrigger = "tag"     // pushed tags matching the tag pattern
+	DeployTriggerRelease DeployTrigger = "release" // published GitHub releases whose tag matches the pattern
+)
+
+// IsValid reports whether the deploy trigger is known. Empty means branch.
+func (t DeployTrigger) IsValid() bool {
+	switch t {
+	case "", DeployTriggerBranch, DeployTriggerTag, DeployTriggerRelease:
+		return true
+	}
+	return false
+}
+
+// EffectiveDeployTrigger returns the app's deploy trigger, applying the default
+func (a *App) EffectiveDeployTrigger() DeployTrigger {
+	if a.DeployTrigger == "" {
+		return DeployTriggerBranch
+	}
+	return a.DeployTrigger
+}
+
+// ValidateDeployTrigger checks the app's deploy trigger and tag pattern
+func (a *App) ValidateDeployTrigger() error {
+	if !a.DeployTrigger.IsValid() {
+		return fmt.Errorf("deploy_trigger must be branch, tag or release")
+	}
+	if _, err := path.Match(a.TagPattern, ""); err != nil {
+		return fmt.Errorf("tag_pattern %q is not a valid glob pattern", a.TagPattern)
+	}
+	if a.DeployTrigger == DeployTriggerRelease && a.GitProvider != "" && a.GitProvider != GitProviderGitHub {
+		return fmt.Errorf("deploy_trigger release is only supported on github")
+	}
+	if a.RequireChecks && a.GitProvider != "" && a.GitProvider != GitProviderGitHub {
+		return fmt.Errorf("require_checks is only supported on github")
+	}
+	return nil
+}
+
+// MatchesTag reports whether a pushed or released tag deploys the app. An empty tag
+// pattern matches every tag.
+func (a *App) MatchesTag(tag string) bool {
+	if a.TagPattern == "" {
+		return true
+	}
+	ok, _ := path.Match(a.TagPattern, tag)
+	return ok
+}
@@ -15,6 +15,8 @@ const (
 	DeploymentStatusSucceeded DeploymentStatus = "succeeded"
 	DeploymentStatusFailed    DeploymentStatus = "failed"
 	DeploymentStatusRolledBack DeploymentStatus = "rolled_back"
+	DeploymentStatusAwaitingApproval DeploymentStatus = "awaiting_approval"
+	DeploymentStatusRejected DeploymentStatus = "rejected"
 )
 
 // Deployment represents a deployment attempt
@@ -32,6 +34,21 @@ type Deployment struct {
 	RollbackReason     string `json:"rollback_reason,omitempty"`
 	RolledBackFromID   *uuid.UUID `json:"rolled_back_from_id,omitempty"`
 
+	// Smoke check outcome
+	SmokeResults []SmokeCheckResult `json:"smoke_results,omitempty"`
+	FailedCheck  string             `json:"failed_check,omitempty"`
+
+	// Pinned deployments are not replaced by automatic actions
+	Pinned bool `json:"pinned"`
+
+	// Review of deployments to apps that require approval
+	ReviewedBy   *uuid.UUID `json:"reviewed_by,omitempty"`
+	ReviewedAt   *time.Time `json:"reviewed_at,omitempty"`
+	RejectReason string     `json:"reject_reason,omitempty"`
+
+	// Most recent exit of one of this deployment's containers
+	LastExit *ContainerExit `json:"last_exit,omitempty"`
+
 	// Timestamps
 	CreatedAt   time.Time  `json:"created_at"`
 	StartedAt   *time.Time `json:"started_at,omitempty"`
@@ -87,6 +104,14 @@ func (d *Deployment) MarkRolledBack(reason string) {
 	d.RollbackReason = reason
 }
 
+// RecordSmokeResult appends a smoke check result and notes the first failing check
+func (d *Deployment) RecordSmokeResult(result SmokeCheckResult) {
+	d.SmokeResults = append(d.SmokeResults, result)
+	if !result.Passed && d.FailedCheck == "" {
+		d.FailedCheck = result.Name
+	}
+}
+
 // AddContainerID adds a container ID to the deployment
 func (d *Deployment) AddContainerID(containerID string) {
 	d.ContainerIDs = append(d.ContainerIDs, containerID)
@@ -0,0 +1,36 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// DiagnosticSnapshot captures the state of an app's containers at one point in time
+type DiagnosticSnapshot struct {
+	CapturedAt time.Time              `json:"captured_at"`
+	ImageID    string                 `json:"image_id,omitempty"`
+	Containers []ContainerDiagnostics `json:"containers"`
+	Errors     []string               `json:"errors,omitempty"`
+}
+
+// ContainerDiagnostics holds the recent logs, inspect output and resource usage of one container
+type ContainerDiagnostics struct {
+	ContainerID string          `json:"container_id"`
+	Name        string          `json:"name,omitempty"`
+	State       string          `json:"state,omitempty"`
+	Inspect     json.RawMessage `json:"inspect,omitempty"` // environment values are redacted
+	Logs        []string        `json:"logs,omitempty"`
+	Stats       *ContainerStats `json:"stats,omitempty"`
+	Exit        *ContainerExit  `json:"exit,omitempty"`
+}
+
+// ContainerStats is a point-in-time resource usage sample
+type ContainerStats struct {
+	CPUPercent    float64 `json:"cpu_percent"`
+	MemoryUsage   uint64  `json:"memory_usage"`
+	MemoryLimit   uint64  `json:"memory_limit"`
+	MemoryPercent float64 `json:"memory_percent"`
+	NetworkRx     uint64  `json:"network_rx_bytes"`
+	NetworkTx     uint64  `json:"network_tx_bytes"`
+	PIDs          uint64  `json:"pids"`
+}
@@ -0,0 +1,94 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EnvSource is where a container environment variable comes from
+type EnvSource string
+
+const (
+	EnvSourceApp     EnvSource = "app"     // the app's env vars
+	EnvSourceProject EnvSource = "project" // shared env vars of the app's project
+	EnvSourceSecret  EnvSource = "secret"  // an app secret, value redacted
+	EnvSourceImage   EnvSource = "image"   // set by the image or the runtime
+)
+
+// RedactedValue replaces secret values in effective configuration
+const RedactedValue = "[redacted]"
+
+// EffectiveEnvVar is one resolved environment variable
+type EffectiveEnvVar struct {
+	Key    string    `json:"key"`
+	Value  string    `json:"value"`
+	Source EnvSource `json:"source"`
+}
+
+// EffectiveResources are the limits and runtime options applied to a container
+type EffectiveResources struct {
+	MemoryLimit   int64             `json:"memory_limit"` // in bytes, 0 is unlimited
+	CPUQuota      int64             `json:"cpu_quota"`    // in microseconds, 0 is unlimited
+	RestartPolicy string            `json:"restart_policy"`
+	NoFileLimit   int64             `json:"nofile_limit,omitempty"`
+	ShmSize       int64             `json:"shm_size,omitempty"`
+	Tmpfs         map[string]string `json:"tmpfs,omitempty"`
+	Sysctls       map[string]string `json:"sysctls,omitempty"`
+	Binds         []string          `json:"binds,omitempty"`
+}
+
+// EffectiveHealthCheck is the health check a container runs, defined by its image
+type EffectiveHealthCheck struct {
+	Test     []string      `json:"test"`
+	Interval time.Duration `json:"interval,omitempty"`
+	Timeout  time.Duration `json:"timeout,omitempty"`
+	Retries  int           `json:"retries,omitempty"`
+	Status   string        `json:"status,omitempty"` // starting, healthy or unhealthy
+}
+
+// EffectiveRouting is the route the proxy serves for an app
+type EffectiveRouting struct {
+	Backend    string   `json:"backend"`
+	URL        string   `json:"url"`
+	Hosts      []string `json:"hosts"`
+	Port       int      `json:"port"`
+	Middleware []string `json:"middleware,omitempty"`
+	Replicas   []string `json:"replicas"` // backend addresses
+	// Whether the proxy serves the configuration the app's current settings produce
+	Applied bool   `json:"applied"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ContainerConfig is the configuration one of an app's containers actually runs with,
+// and how it differs from what a new container would get
+type ContainerConfig struct {
+	ContainerID string                `json:"container_id"`
+	Name        string                `json:"name,omitempty"`
+	State       string                `json:"state,omitempty"`
+	Image       string                `json:"image"`
+	Command     []string              `json:"command,omitempty"`
+	Env         []EffectiveEnvVar     `json:"env"`
+	Resources   EffectiveResources    `json:"resources"`
+	Labels      map[string]string     `json:"labels,omitempty"`
+	HealthCheck *EffectiveHealthCheck `json:"health_check,omitempty"`
+	StartedAt   *time.Time            `json:"started_at,omitempty"`
+	Drift       []string              `json:"drift,omitempty"` // empty when the container is current
+}
+
+// EffectiveConfig is an app's resolved runtime configuration: what a container started
+// now would get, and what each running container was actually started with
+type EffectiveConfig struct {
+	AppID          uuid.UUID             `json:"app_id"`
+	Image          string                `json:"image"`
+	Command        []string              `json:"command,omitempty"`
+	Env            []EffectiveEnvVar     `json:"env"`
+	Resources      EffectiveResources    `json:"resources"`
+	HealthCheck    *EffectiveHealthCheck `json:"health_check,omitempty"`
+	Port           int                   `json:"port,omitempty"` // container port routed to
+	Routing        *EffectiveRouting     `json:"routing,omitempty"`
+	Containers     []ContainerConfig     `json:"containers"`
+	PendingRestart bool                  `json:"pending_restart"`
+	ResolvedAt     time.Time             `json:"resolved_at"`
+	Errors         []string              `json:"errors,omitempty"`
+}
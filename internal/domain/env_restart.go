@@ -0,0 +1,51 @@
+package domain
+
+import "time"
+
+// EnvRestartPolicy decides how env var and secret changes reach running containers
+type EnvRestartPolicy string
+
+const (
+	EnvRestartNextDeploy EnvRestartPolicy = ""          // containers keep their env until the next deploy or restart
+	EnvRestartImmediate  EnvRestartPolicy = "immediate" // all containers are recreated at once
+	EnvRestartRolling    EnvRestartPolicy = "rolling"   // containers are recreated one at a time
+)
+
+// ParseEnvRestartPolicy reads a policy, accepting next_deploy for the default
+func ParseEnvRestartPolicy(s string) (EnvRestartPolicy, bool) {
+	switch p := EnvRestartPolicy(s); p {
+	case EnvRestartNextDeploy, EnvRestartImmediate, EnvRestartRolling:
+		return p, true
+	case "next_deploy":
+		return EnvRestartNextDeploy, true
+	}
+	return "", false
+}
+
+// String names the policy, reporting the default as next_deploy
+func (p EnvRestartPolicy) String() string {
+	if p == EnvRestartNextDeploy {
+		return "next_deploy"
+	}
+	return string(p)
+}
+
+// MarkPendingRestart records that running containers predate a configuration change.
+// A stopped app has nothing to restart; its next start picks the change up.
+func (a *App) MarkPendingRestart() {
+	if a.Replicas == 0 || a.PendingRestartSince != nil {
+		return
+	}
+	now := time.Now().UTC()
+	a.PendingRestartSince = &now
+}
+
+// ClearPendingRestart records that the app's containers run its current configuration
+func (a *App) ClearPendingRestart() {
+	a.PendingRestartSince = nil
+}
+
+// HasPendingRestart reports whether running containers predate a configuration change
+func (a *App) HasPendingRestart() bool {
+	return a.PendingRestartSince != nil
+}
@@ -0,0 +1,13 @@
+package domain
+
+import "errors"
+
+// Sentinel errors that stores and services wrap so handlers can map failures to HTTP
+// statuses with errors.Is, e.g. fmt.Errorf("app %w", ErrNotFound)
+var (
+	ErrNotFound      = errors.New("not found")
+	ErrConflict      = errors.New("already exists")
+	ErrInvalid       = errors.New("invalid")
+	ErrForbidden     = errors.New("forbidden")
+	ErrQuotaExceeded = errors.New("quota exceeded")
+)
@@ -0,0 +1,45 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Git providers an app can build from
+const (
+	GitProviderGitHub = "github"
+	GitProviderGitLab = "gitlab"
+	GitProviderGitea  = "gitea" // Gitea and Forgejo
+)
+
+// ValidGitProvider reports whether name is a git provider NanoPaaS supports
+func ValidGitProvider(name string) bool {
+	switch name {
+	case GitProviderGitHub, GitProviderGitLab, GitProviderGitea:
+		return true
+	}
+	return false
+}
+
+// GitConnection is a user's access token for a git provider other than GitHub, whose
+// token is kept on the user from GitHub sign-in
+type GitConnection struct {
+	UserID   uuid.UUID `json:"user_id"`
+	Provider string    `json:"provider"`
+	Username string    `json:"username"`
+
+	// OAuth connections carry a refresh token and expiry; personal access tokens don't
+	Token        string     `json:"-"`
+	RefreshToken string     `json:"-"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Expired reports whether the connection's token has expired, allowing a minute of
+// clock skew
+func (c *GitConnection) Expired() bool {
+	return c.ExpiresAt != nil && time.Until(*c.ExpiresAt) < time.Minute
+}
@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ValidateGitSource checks the repository and branch an app builds from and what
+// deploys it. Auto-deploy needs a repository to watch.
+func (a *App) ValidateGitSource() error {
+	if a.GitProvider != "" && !ValidGitProvider(a.GitProvider) {
+		return fmt.Errorf("git_provider %q is not a supported git provider", a.GitProvider)
+	}
+	if a.GitRepoURL != "" {
+		u, err := url.Parse(a.GitRepoURL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("git_repo_url must be an http or https URL")
+		}
+		if u.User != nil {
+			return fmt.Errorf("git_repo_url must not contain credentials")
+		}
+	}
+	if strings.ContainsAny(a.GitBranch, " \t\n~^:?*[\\") || strings.HasPrefix(a.GitBranch, "-") {
+		return fmt.Errorf("git_branch %q is not a valid branch name", a.GitBranch)
+	}
+	if a.AutoDeploy && a.GitRepoURL == "" {
+		return fmt.Errorf("auto_deploy requires git_repo_url")
+	}
+	return a.ValidateDeployTrigger()
+}
+
+// GitRepoKey identifies a repository whatever form its URL takes, as the lower-case
+// host/owner/repo: https and ssh clone URLs, with or without .git, match. It returns ""
+// for an empty URL.
+func GitRepoKey(repoURL string) string {
+	key := strings.TrimSpace(repoURL)
+	if key == "" {
+		return ""
+	}
+	if strings.Contains(key, "://") {
+		u, err := url.Parse(key)
+		if err != nil {
+			return ""
+		}
+		key = u.Host + u.Path
+	} else {
+		// scp-like ssh URLs: git@github.com:acme/shop.git
+		if _, rest, ok := strings.Cut(key, "@"); ok {
+			key = rest
+		}
+		key = strings.Replace(key, ":", "/", 1)
+	}
+	key = strings.TrimSuffix(strings.TrimRight(key, "/"), ".git")
+	return strings.ToLower(key)
+}
@@ -0,0 +1,31 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// GitHubWebhook is a repository webhook created on a git provider for an app. Most are
+// on GitHub, hence the name.
+type GitHubWebhook struct {
+	ID        int64     `json:"id"`                 // the provider's hook ID
+	Provider  string    `json:"provider,omitempty"` // github when empty
+	Owner     string    `json:"owner"`
+	Repo      string    `json:"repo"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ProviderName returns the git provider the webhook is on
+func (w *GitHubWebhook) ProviderName() string {
+	if w.Provider == "" {
+		return GitProviderGitHub
+	}
+	return w.Provider
+}
+
+// WebhookSecretAAD binds an app's sealed webhook secret to the app. Secret names can't
+// contain '#', so it never collides with an app secret's SecretAAD.
+func WebhookSecretAAD(appID uuid.UUID) []byte {
+	return SecretAAD(appID, "#webhook")
+}
@@ -0,0 +1,36 @@
+package domain
+
+import "fmt"
+
+const (
+	// DefaultHSTSMaxAge is one year, the minimum accepted for browser preload lists
+	DefaultHSTSMaxAge = 31536000
+
+	// MaxHSTSMaxAge caps the policy at two years
+	MaxHSTSMaxAge = 63072000
+)
+
+// HSTSPolicy is an app's Strict-Transport-Security policy, sent on HTTPS responses by the router
+type HSTSPolicy struct {
+	MaxAge            int  `json:"max_age"` // seconds, 0 uses the default
+	IncludeSubdomains bool `json:"include_subdomains,omitempty"`
+	Preload           bool `json:"preload,omitempty"`
+}
+
+// Normalize applies the default max age
+func (p *HSTSPolicy) Normalize() {
+	if p.MaxAge == 0 {
+		p.MaxAge = DefaultHSTSMaxAge
+	}
+}
+
+// Validate checks that the HSTS policy is well-formed
+func (p *HSTSPolicy) Validate() error {
+	if p.MaxAge < 0 || p.MaxAge > MaxHSTSMaxAge {
+		return fmt.Errorf("hsts max_age must be between 0 and %d seconds", MaxHSTSMaxAge)
+	}
+	if p.Preload && (!p.IncludeSubdomains || p.MaxAge < DefaultHSTSMaxAge) {
+		return fmt.Errorf("hsts preload requires include_subdomains and a max_age of at least %d seconds", DefaultHSTSMaxAge)
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IncidentStatus represents whether an incident is still being worked on
+type IncidentStatus string
+
+const (
+	IncidentStatusOpen     IncidentStatus = "open"
+	IncidentStatusResolved IncidentStatus = "resolved"
+)
+
+// LockdownMode selects how the router restricts traffic to an app during an incident
+type LockdownMode string
+
+const (
+	LockdownNone        LockdownMode = "none"
+	LockdownRateLimit   LockdownMode = "rate_limit"  // throttle requests per client IP
+	LockdownMaintenance LockdownMode = "maintenance" // stop sending traffic; the router answers 503
+)
+
+// Default rate limit applied when an incident does not specify one
+const (
+	DefaultLockdownAverage = 10 // requests per second
+	DefaultLockdownBurst   = 20
+	MaxLockdownAverage     = 10000
+)
+
+// Incident timeline event kinds
+const (
+	IncidentEventOpened        = "opened"
+	IncidentEventPinned        = "pinned"
+	IncidentEventLockdown      = "lockdown"
+	IncidentEventDiagnostics   = "diagnostics"
+	IncidentEventNote          = "note"
+	IncidentEventDeploy        = "deploy"
+	IncidentEventScale         = "scale"
+	IncidentEventContainerExit = "container_exit"
+	IncidentEventResolved      = "resolved"
+)
+
+// RateLimit is a per-client-IP request budget
+type RateLimit struct {
+	Average int `json:"average"` // sustained requests per second
+	Burst   int `json:"burst"`
+}
+
+// Lockdown restricts an app's traffic while an incident is open
+type Lockdown struct {
+	IncidentID uuid.UUID    `json:"incident_id"`
+	Mode       LockdownMode `json:"mode"`
+	RateLimit  *RateLimit   `json:"rate_limit,omitempty"`
+	Since      time.Time    `json:"since"`
+}
+
+// IncidentEvent is one entry in an incident's timeline
+type IncidentEvent struct {
+	At      time.Time  `json:"at"`
+	Kind    string     `json:"kind"`
+	Message string     `json:"message"`
+	ActorID *uuid.UUID `json:"actor_id,omitempty"` // nil for events recorded by the platform
+}
+
+// Incident tracks a lockdown of a misbehaving app from opening until it is resolved
+type Incident struct {
+	ID       uuid.UUID      `json:"id"`
+	AppID    uuid.UUID      `json:"app_id"`
+	Title    string         `json:"title"`
+	Status   IncidentStatus `json:"status"`
+	Lockdown LockdownMode   `json:"lockdown"`
+
+	// Set when opening the incident pinned the deployment, so resolving releases it
+	PinnedDeploymentID *uuid.UUID `json:"pinned_deployment_id,omitempty"`
+
+	Diagnostics *DiagnosticSnapshot `json:"diagnostics,omitempty"`
+	Timeline    []IncidentEvent     `json:"timeline"`
+
+	OpenedBy   uuid.UUID  `json:"opened_by"`
+	OpenedAt   time.Time  `json:"opened_at"`
+	ResolvedBy *uuid.UUID `json:"resolved_by,omitempty"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+}
+
+// NewIncident creates an open incident with an opening timeline entry
+func NewIncident(appID, openedBy uuid.UUID, title string, mode LockdownMode) *Incident {
+	now := time.Now().UTC()
+	inc := &Incident{
+		ID:       uuid.New(),
+		AppID:    appID,
+		Title:    title,
+		Status:   IncidentStatusOpen,
+		Lockdown: mode,
+		OpenedBy: openedBy,
+		OpenedAt: now,
+	}
+	inc.AddEvent(IncidentEventOpened, "Incident opened: "+title, &openedBy)
+	return inc
+}
+
+// AddEvent appends an entry to the timeline and returns it
+func (i *Incident) AddEvent(kind, message string, actorID *uuid.UUID) IncidentEvent {
+	event := IncidentEvent{
+		At:      time.Now().UTC(),
+		Kind:    kind,
+		Message: message,
+		ActorID: actorID,
+	}
+	i.Timeline = append(i.Timeline, event)
+	return event
+}
+
+// IsOpen reports whether the incident has not been resolved
+func (i *Incident) IsOpen() bool {
+	return i.Status == IncidentStatusOpen
+}
+
+// Resolve closes the incident
+func (i *Incident) Resolve(resolvedBy uuid.UUID, message string) {
+	now := time.Now().UTC()
+	i.Status = IncidentStatusResolved
+	i.ResolvedBy = &resolvedBy
+	i.ResolvedAt = &now
+	if message == "" {
+		message = "Incident resolved"
+	}
+	i.AddEvent(IncidentEventResolved, message, &resolvedBy)
+}
+
+// ValidateLockdown checks a lockdown mode and fills in the default rate limit
+func ValidateLockdown(mode LockdownMode, limit *RateLimit) (*RateLimit, error) {
+	switch mode {
+	case LockdownNone, LockdownMaintenance:
+		return nil, nil
+	case LockdownRateLimit:
+	default:
+		return nil, fmt.Errorf("lockdown must be one of: none, rate_limit, maintenance")
+	}
+
+	if limit == nil {
+		return &RateLimit{Average: DefaultLockdownAverage, Burst: DefaultLockdownBurst}, nil
+	}
+	if limit.Average <= 0 || limit.Average > MaxLockdownAverage {
+		return nil, fmt.Errorf("rate_limit.average must be between 1 and %d", MaxLockdownAverage)
+	}
+	if limit.Burst < 0 {
+		return nil, fmt.Errorf("rate_limit.burst must not be negative")
+	}
+	if limit.Burst == 0 {
+		limit.Burst = limit.Average
+	}
+	return limit, nil
+}
+
+// InLockdown reports whether the router is restricting the app's traffic
+func (a *App) InLockdown() bool {
+	return a.Lockdown != nil && a.Lockdown.Mode != LockdownNone
+}
+
+// StartLockdown restricts the app's traffic for an incident
+func (a *App) StartLockdown(incidentID uuid.UUID, mode LockdownMode, limit *RateLimit) {
+	now := time.Now().UTC()
+	a.Lockdown = &Lockdown{
+		IncidentID: incidentID,
+		Mode:       mode,
+		RateLimit:  limit,
+		Since:      now,
+	}
+	a.UpdatedAt = now
+}
+
+// EndLockdown restores normal traffic
+func (a *App) EndLockdown() {
+	a.Lockdown = nil
+	a.UpdatedAt = time.Now().UTC()
+}
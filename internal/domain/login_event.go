@@ -0,0 +1,36 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LoginProvider is how a user signed in
+type LoginProvider string
+
+const (
+	LoginProviderGitHub   LoginProvider = "github"
+	LoginProviderOIDC     LoginProvider = "oidc"
+	LoginProviderPassword LoginProvider = "password"
+)
+
+// LoginEvent records a sign-in attempt on a known account, successful or not
+type LoginEvent struct {
+	ID        uuid.UUID     `json:"id"`
+	UserID    uuid.UUID     `json:"user_id"`
+	Provider  LoginProvider `json:"provider,omitempty"`
+	Success   bool          `json:"success"`
+	Reason    string        `json:"reason,omitempty"`     // why a failed attempt failed
+	SessionID string        `json:"session_id,omitempty"` // the session a successful sign-in started
+	IP        string        `json:"ip,omitempty"`
+	UserAgent string        `json:"user_agent,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+// Reasons sign-in attempts fail
+const (
+	LoginFailedPassword    = "invalid_password"
+	LoginFailedTwoFactor   = "invalid_two_factor_code"
+	LoginFailedDeactivated = "account_deactivated"
+)
@@ -0,0 +1,36 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// MaxMaintenanceHTMLSize limits custom maintenance pages to 256KB
+const MaxMaintenanceHTMLSize = 256 * 1024
+
+// MaintenancePage replaces an app's traffic with a placeholder page while its containers keep running
+type MaintenancePage struct {
+	HTML       string    `json:"html,omitempty"` // empty serves the default page
+	Message    string    `json:"message,omitempty"`
+	RetryAfter int       `json:"retry_after,omitempty"` // seconds, sent as the Retry-After header
+	Since      time.Time `json:"since"`
+}
+
+// Validate checks that the maintenance page is well-formed
+func (p *MaintenancePage) Validate() error {
+	if len(p.HTML) > MaxMaintenanceHTMLSize {
+		return fmt.Errorf("maintenance html must be at most %d bytes", MaxMaintenanceHTMLSize)
+	}
+	if len(p.Message) > 1000 {
+		return fmt.Errorf("maintenance message must be at most 1000 characters")
+	}
+	if p.RetryAfter < 0 {
+		return fmt.Errorf("maintenance retry_after must not be negative")
+	}
+	return nil
+}
+
+// InMaintenance reports whether the app is serving its maintenance page
+func (a *App) InMaintenance() bool {
+	return a.MaintenancePage != nil
+}
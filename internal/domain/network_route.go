@@ -0,0 +1,35 @@
+package domain
+
+import "fmt"
+
+// RouteProtocol is the protocol an app is exposed with
+type RouteProtocol string
+
+const (
+	RouteProtocolTCP RouteProtocol = "tcp"
+	RouteProtocolUDP RouteProtocol = "udp"
+)
+
+// NetworkRoute exposes an app as a raw TCP or UDP service instead of over HTTP
+type NetworkRoute struct {
+	Protocol RouteProtocol `json:"protocol"`
+	// TCP only: clients connect with TLS on the shared HTTPS port and are routed by
+	// server name, so no dedicated port is allocated
+	SNI bool `json:"sni,omitempty"`
+	// Router port allocated to the app, 0 with SNI
+	PublicPort int `json:"public_port,omitempty"`
+}
+
+// Validate checks that the network route is well-formed
+func (n *NetworkRoute) Validate() error {
+	switch n.Protocol {
+	case RouteProtocolTCP:
+	case RouteProtocolUDP:
+		if n.SNI {
+			return fmt.Errorf("sni routing is only available for tcp")
+		}
+	default:
+		return fmt.Errorf("protocol must be tcp or udp")
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// NotificationKind categorizes a personal notification
+type NotificationKind string
+
+const (
+	NotificationBuildFailed      NotificationKind = "build_failed"
+	NotificationBuildSucceeded   NotificationKind = "build_succeeded"
+	NotificationDeploymentFailed NotificationKind = "deployment_failed"
+	NotificationTeamAdded        NotificationKind = "team_added"
+)
+
+// Notification is a message for a single user, shown in the dashboard inbox
+type Notification struct {
+	ID        uuid.UUID         `json:"id"`
+	UserID    uuid.UUID         `json:"user_id"`
+	Kind      NotificationKind  `json:"kind"`
+	Title     string            `json:"title"`
+	Body      string            `json:"body,omitempty"`
+	Link      string            `json:"link,omitempty"` // dashboard path, e.g. /apps/{id}/builds/{id}
+	Data      map[string]string `json:"data,omitempty"`
+	ReadAt    *time.Time        `json:"read_at,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// NewNotification creates an unread notification
+func NewNotification(userID uuid.UUID, kind NotificationKind, title, body string) *Notification {
+	return &Notification{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Kind:      kind,
+		Title:     title,
+		Body:      body,
+		Data:      make(map[string]string),
+		CreatedAt: time.Now().UTC(),
+	}
+}
+
+// IsRead reports whether the notification has been marked read
+func (n *Notification) IsRead() bool {
+	return n.ReadAt != nil
+}
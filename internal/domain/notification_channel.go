@@ -0,0 +1,148 @@
+package domain
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ChannelKind is where a notification channel posts events
+type ChannelKind string
+
+const (
+	ChannelSlack   ChannelKind = "slack"
+	ChannelDiscord ChannelKind = "discord"
+	ChannelWebhook ChannelKind = "webhook" // generic JSON POST, optionally signed
+)
+
+// NotificationEvent is an app event that notification channels can subscribe to
+type NotificationEvent string
+
+const (
+	EventDeploySucceeded NotificationEvent = "deploy_succeeded"
+	EventDeployFailed    NotificationEvent = "deploy_failed"
+	EventBuildFailed     NotificationEvent = "build_failed"
+	EventCrashLoop       NotificationEvent = "crash_loop"
+
+	// EventTest is sent when a channel is tested; channels cannot subscribe to it
+	EventTest NotificationEvent = "test"
+)
+
+// NotificationEvents lists the events channels can subscribe to
+var NotificationEvents = []NotificationEvent{EventDeploySucceeded, EventDeployFailed, EventBuildFailed, EventCrashLoop}
+
+// NotificationChannel posts an app's events, or every app's when AppID is nil, to a
+// Slack or Discord incoming webhook or a generic HTTP endpoint
+type NotificationChannel struct {
+	ID      uuid.UUID           `json:"id"`
+	AppID   *uuid.UUID          `json:"app_id,omitempty"` // nil for global channels
+	Name    string              `json:"name"`
+	Kind    ChannelKind         `json:"kind"`
+	URL     string              `json:"-"`                // contains the webhook token for Slack and Discord
+	Secret  string              `json:"-"`                // signs generic webhook payloads when set
+	Events  []NotificationEvent `json:"events,omitempty"` // empty subscribes to all events
+	Enabled bool                `json:"enabled"`
+
+	CreatedBy uuid.UUID `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewNotificationChannel creates an enabled channel
+func NewNotificationChannel(appID *uuid.UUID, name string, kind ChannelKind, rawURL string, events []NotificationEvent, createdBy uuid.UUID) *NotificationChannel {
+	return &NotificationChannel{
+		ID:        uuid.New(),
+		AppID:     appID,
+		Name:      name,
+		Kind:      kind,
+		URL:       rawURL,
+		Events:    events,
+		Enabled:   true,
+		CreatedBy: createdBy,
+		CreatedAt: time.Now().UTC(),
+	}
+}
+
+// Validate checks the channel's kind, URL and events
+func (c *NotificationChannel) Validate() error {
+	if c.Name == "" || len(c.Name) > 100 {
+		return fmt.Errorf("name must be 1-100 characters")
+	}
+	switch c.Kind {
+	case ChannelSlack, ChannelDiscord, ChannelWebhook:
+	default:
+		return fmt.Errorf("kind must be slack, discord or webhook")
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("url must be an http or https URL")
+	}
+	if c.Kind != ChannelWebhook && u.Scheme != "https" {
+		return fmt.Errorf("%s webhook URLs must use https", c.Kind)
+	}
+	for _, event := range c.Events {
+		if !isNotificationEvent(event) {
+			return fmt.Errorf("unknown event %q", event)
+		}
+	}
+	return nil
+}
+
+// Wants reports whether the channel is enabled and subscribed to an event
+func (c *NotificationChannel) Wants(event NotificationEvent) bool {
+	if !c.Enabled {
+		return false
+	}
+	if len(c.Events) == 0 {
+		return true
+	}
+	for _, e := range c.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactedURL returns the channel URL without its path and query, which carry tokens
+func (c *NotificationChannel) RedactedURL() string {
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host + "/..."
+}
+
+func isNotificationEvent(event NotificationEvent) bool {
+	for _, e := range NotificationEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// DeliveryStatus is the outcome of posting an event to a channel
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending" // sending or waiting to retry
+	DeliveryDelivered DeliveryStatus = "delivered"
+	DeliveryFailed    DeliveryStatus = "failed"
+)
+
+// NotificationDelivery records one event posted to a channel, across its retries
+type NotificationDelivery struct {
+	ID             uuid.UUID         `json:"id"`
+	ChannelID      uuid.UUID         `json:"channel_id"`
+	AppID          uuid.UUID         `json:"app_id"`
+	Event          NotificationEvent `json:"event"`
+	Title          string            `json:"title"`
+	Status         DeliveryStatus    `json:"status"`
+	Attempts       int               `json:"attempts"`
+	ResponseStatus int               `json:"response_status,omitempty"` // HTTP status of the last attempt
+	LastError      string            `json:"last_error,omitempty"`
+	CreatedAt      time.Time         `json:"created_at"`
+	DeliveredAt    *time.Time        `json:"delivered_at,omitempty"`
+}
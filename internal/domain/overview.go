@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AppOverview is an app as the dashboard lists it: its state, its latest build and its
+// latest deployment
+type AppOverview struct {
+	AppID            uuid.UUID          `json:"app_id"`
+	Name             string             `json:"name"`
+	Slug             string             `json:"slug"`
+	Status           AppStatus          `json:"status"`
+	Replicas         int                `json:"replicas"`
+	TargetReplicas   int                `json:"target_replicas"`
+	URL              string             `json:"url,omitempty"`
+	LatestBuild      *BuildSummary      `json:"latest_build,omitempty"`
+	LatestDeployment *DeploymentSummary `json:"latest_deployment,omitempty"`
+	UpdatedAt        time.Time          `json:"updated_at"`
+}
+
+// BuildSummary is the part of a build an overview shows
+type BuildSummary struct {
+	ID          uuid.UUID   `json:"id"`
+	Status      BuildStatus `json:"status"`
+	GitCommit   string      `json:"git_commit,omitempty"`
+	ImageTag    string      `json:"image_tag,omitempty"`
+	CreatedAt   time.Time   `json:"created_at"`
+	CompletedAt *time.Time  `json:"completed_at,omitempty"`
+}
+
+// Summary returns the build's summary
+func (b *Build) Summary() *BuildSummary {
+	return &BuildSummary{
+		ID:          b.ID,
+		Status:      b.Status,
+		GitCommit:   b.GitCommit,
+		ImageTag:    b.ImageTag,
+		CreatedAt:   b.CreatedAt,
+		CompletedAt: b.CompletedAt,
+	}
+}
+
+// DeploymentSummary is the part of a deployment an overview shows
+type DeploymentSummary struct {
+	ID          uuid.UUID        `json:"id"`
+	Status      DeploymentStatus `json:"status"`
+	ImageID     string           `json:"image_id"`
+	Replicas    int              `json:"replicas"`
+	CreatedAt   time.Time        `json:"created_at"`
+	CompletedAt *time.Time       `json:"completed_at,omitempty"`
+}
+
+// Summary returns the deployment's summary
+func (d *Deployment) Summary() *DeploymentSummary {
+	return &DeploymentSummary{
+		ID:          d.ID,
+		Status:      d.Status,
+		ImageID:     d.ImageID,
+		Replicas:    d.Replicas,
+		CreatedAt:   d.CreatedAt,
+		CompletedAt: d.CompletedAt,
+	}
+}
@@ -0,0 +1,62 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DeploymentPin holds an app's current deployment in place, blocking automatic
+// actions (webhook auto-deploys, image refreshes, scheduled rebuilds) until released
+type DeploymentPin struct {
+	DeploymentID uuid.UUID `json:"deployment_id"`
+	Reason       string    `json:"reason"`
+	PinnedBy     uuid.UUID `json:"pinned_by"`
+	PinnedAt     time.Time `json:"pinned_at"`
+}
+
+// PinnedError is returned when an action is blocked by a deployment pin
+type PinnedError struct {
+	Pin *DeploymentPin
+}
+
+func (e *PinnedError) Error() string {
+	return fmt.Sprintf("deployment %s is pinned: %s", e.Pin.DeploymentID, e.Pin.Reason)
+}
+
+// Is makes a pin block match ErrConflict
+func (e *PinnedError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// IsPinned reports whether the app's current deployment is pinned
+func (a *App) IsPinned() bool {
+	return a.Pin != nil
+}
+
+// PinDeployment pins the given deployment
+func (a *App) PinDeployment(deploymentID, pinnedBy uuid.UUID, reason string) {
+	now := time.Now().UTC()
+	a.Pin = &DeploymentPin{
+		DeploymentID: deploymentID,
+		Reason:       reason,
+		PinnedBy:     pinnedBy,
+		PinnedAt:     now,
+	}
+	a.UpdatedAt = now
+}
+
+// Unpin releases the deployment pin
+func (a *App) Unpin() {
+	a.Pin = nil
+	a.UpdatedAt = time.Now().UTC()
+}
+
+// CheckAutomaticAction returns a PinnedError if automatic actions may not replace the current deployment
+func (a *App) CheckAutomaticAction() error {
+	if a.Pin != nil {
+		return &PinnedError{Pin: a.Pin}
+	}
+	return nil
+}
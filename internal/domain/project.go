@@ -0,0 +1,67 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Project groups the apps of one system, e.g. a frontend, an API and a worker, so they
+// share env vars and can be deployed and stopped together
+type Project struct {
+	ID          uuid.UUID         `json:"id"`
+	Name        string            `json:"name"`
+	Slug        string            `json:"slug"`
+	Description string            `json:"description,omitempty"`
+	EnvVars     map[string]string `json:"env_vars,omitempty"` // shared by every app in the project
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Ownership
+	OwnerID uuid.UUID  `json:"owner_id"`
+	TeamID  *uuid.UUID `json:"team_id,omitempty"`
+}
+
+// NewProject creates a project without apps
+func NewProject(name, slug string, ownerID uuid.UUID) *Project {
+	now := time.Now().UTC()
+	return &Project{
+		ID:        uuid.New(),
+		Name:      name,
+		Slug:      slug,
+		EnvVars:   make(map[string]string),
+		CreatedAt: now,
+		UpdatedAt: now,
+		OwnerID:   ownerID,
+	}
+}
+
+// SetEnvVar sets a shared environment variable
+func (p *Project) SetEnvVar(key, value string) {
+	if p.EnvVars == nil {
+		p.EnvVars = make(map[string]string)
+	}
+	p.EnvVars[key] = value
+	p.UpdatedAt = time.Now().UTC()
+}
+
+// DeleteEnvVar removes a shared environment variable
+func (p *Project) DeleteEnvVar(key string) {
+	delete(p.EnvVars, key)
+	p.UpdatedAt = time.Now().UTC()
+}
+
+// JoinProject adds the app to a project, inheriting its shared env vars
+func (a *App) JoinProject(p *Project) {
+	a.ProjectID = &p.ID
+	a.ProjectEnv = p.EnvVars
+	a.UpdatedAt = time.Now().UTC()
+}
+
+// LeaveProject removes the app from its project and the project's shared env vars
+func (a *App) LeaveProject() {
+	a.ProjectID = nil
+	a.ProjectEnv = nil
+	a.UpdatedAt = time.Now().UTC()
+}
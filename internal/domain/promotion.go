@@ -0,0 +1,45 @@
+package domain
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// environmentPattern restricts promotion environments to valid Docker tag names
+var environmentPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+// ImagePromotion records an image being retagged for an environment without a rebuild
+type ImagePromotion struct {
+	ID          uuid.UUID `json:"id"`
+	AppSlug     string    `json:"app_slug"`
+	BuildID     string    `json:"build_id,omitempty"`
+	ImageID     string    `json:"image_id"` // content-addressed image ID (sha256:...)
+	SourceRef   string    `json:"source_ref"`
+	TargetTag   string    `json:"target_tag"`
+	Environment string    `json:"environment"`
+	Pushed      bool      `json:"pushed"`
+	Digest      string    `json:"digest,omitempty"` // registry digest when pushed
+	PromotedBy  uuid.UUID `json:"promoted_by"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// NewImagePromotion creates a new promotion record
+func NewImagePromotion(appSlug, imageID, sourceRef, targetTag, environment string, promotedBy uuid.UUID) *ImagePromotion {
+	return &ImagePromotion{
+		ID:          uuid.New(),
+		AppSlug:     appSlug,
+		ImageID:     imageID,
+		SourceRef:   sourceRef,
+		TargetTag:   targetTag,
+		Environment: environment,
+		PromotedBy:  promotedBy,
+		CreatedAt:   time.Now().UTC(),
+	}
+}
+
+// IsValidEnvironment checks that an environment name can be used as an image tag
+func IsValidEnvironment(env string) bool {
+	return environmentPattern.MatchString(env)
+}
@@ -0,0 +1,85 @@
+package domain
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+const (
+	// MaxIPAllowList limits the number of CIDR ranges per app
+	MaxIPAllowList = 50
+
+	// MaxRateLimitAverage caps the sustained per-client rate an app may configure
+	MaxRateLimitAverage = 10000
+)
+
+// RoutingPolicy holds per-app traffic restrictions enforced at the router.
+// Basic auth is kept on the app itself so its hash is never serialized.
+type RoutingPolicy struct {
+	IPAllowList []string   `json:"ip_allowlist,omitempty"` // CIDR ranges allowed to reach the app
+	RateLimit   *RateLimit `json:"rate_limit,omitempty"`   // per client IP
+}
+
+// IsEmpty reports whether the policy restricts nothing
+func (p *RoutingPolicy) IsEmpty() bool {
+	return p == nil || (len(p.IPAllowList) == 0 && p.RateLimit == nil)
+}
+
+// Normalize turns bare IP addresses into single-host CIDR ranges
+func (p *RoutingPolicy) Normalize() {
+	for i, entry := range p.IPAllowList {
+		entry = strings.TrimSpace(entry)
+		if ip := net.ParseIP(entry); ip != nil {
+			if ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		p.IPAllowList[i] = entry
+	}
+}
+
+// Validate checks that the routing policy is well-formed
+func (p *RoutingPolicy) Validate() error {
+	if len(p.IPAllowList) > MaxIPAllowList {
+		return fmt.Errorf("at most %d ip_allowlist entries are allowed", MaxIPAllowList)
+	}
+	for _, cidr := range p.IPAllowList {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("invalid ip_allowlist entry %q: must be an IP address or CIDR range", cidr)
+		}
+	}
+
+	if p.RateLimit != nil {
+		if p.RateLimit.Average < 1 || p.RateLimit.Average > MaxRateLimitAverage {
+			return fmt.Errorf("rate_limit average must be between 1 and %d requests per second", MaxRateLimitAverage)
+		}
+		if p.RateLimit.Burst < 1 {
+			p.RateLimit.Burst = p.RateLimit.Average
+		}
+	}
+
+	return nil
+}
+
+// EffectiveRateLimit returns the rate limit the router enforces: an incident lockdown's
+// limit takes precedence over the app's own
+func (a *App) EffectiveRateLimit() *RateLimit {
+	if a.InLockdown() && a.Lockdown.RateLimit != nil {
+		return a.Lockdown.RateLimit
+	}
+	if a.Routing != nil {
+		return a.Routing.RateLimit
+	}
+	return nil
+}
+
+// IPAllowList returns the CIDR ranges allowed to reach the app, empty when unrestricted
+func (a *App) IPAllowList() []string {
+	if a.Routing == nil {
+		return nil
+	}
+	return a.Routing.IPAllowList
+}
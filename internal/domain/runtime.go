@@ -0,0 +1,286 @@
+package domain
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Runtime option limits applied to user-supplied container settings
+const (
+	MaxNoFileLimit       = 65536
+	MaxShmSize           = 1024 * 1024 * 1024 // 1GB
+	MaxTmpfsMounts       = 4
+	MaxTmpfsSize         = 512 * 1024 * 1024 // 512MB per mount
+	MaxVolumeMounts      = 8
+	MaxNetworkAliases    = 8
+	DefaultRestartPolicy = "on-failure"
+)
+
+// AllowedRestartPolicies lists the restart policies users may select
+var AllowedRestartPolicies = map[string]bool{
+	"no":             true,
+	"always":         true,
+	"on-failure":     true,
+	"unless-stopped": true,
+}
+
+// SysctlRange bounds the value of an allowed sysctl. Pair sysctls take two values,
+// low and high, with low not above high.
+type SysctlRange struct {
+	Min, Max int64
+	Pair     bool
+}
+
+// AllowedSysctls lists namespaced sysctls that are safe to expose to untrusted users,
+// and the values they accept. Anything outside this list could affect the host or
+// other tenants.
+var AllowedSysctls = map[string]SysctlRange{
+	"net.core.somaxconn":            {Min: 1, Max: 65535},
+	"net.ipv4.ip_local_port_range":  {Min: 1024, Max: 65535, Pair: true},
+	"net.ipv4.tcp_fin_timeout":      {Min: 1, Max: 300},
+	"net.ipv4.tcp_keepalive_intvl":  {Min: 1, Max: 32767},
+	"net.ipv4.tcp_keepalive_probes": {Min: 1, Max: 127},
+	"net.ipv4.tcp_keepalive_time":   {Min: 1, Max: 32767},
+	"net.ipv4.tcp_tw_reuse":         {Min: 0, Max: 2},
+}
+
+// allowedTmpfsOptions lists mount options permitted on tmpfs mounts
+var allowedTmpfsOptions = map[string]bool{
+	"rw":     true,
+	"ro":     true,
+	"noexec": true,
+	"nosuid": true,
+	"nodev":  true,
+	"exec":   true,
+}
+
+// reservedMountPaths cannot be shadowed by a tmpfs or volume mount
+var reservedMountPaths = []string{"/", "/proc", "/sys", "/dev", "/etc", "/bin", "/sbin", "/usr", "/lib"}
+
+// volumeNamePattern matches names Docker accepts for named volumes
+var volumeNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// networkAliasPattern matches a single DNS label
+var networkAliasPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// VolumeMount mounts a named volume into the app's containers; host bind mounts are not supported.
+// Names are scoped to the app, so apps never see each other's volumes, unless the mount is
+// external: an existing volume mounted by its Docker name, which only admins may set up.
+type VolumeMount struct {
+	Name     string `json:"name"`
+	Target   string `json:"target"`
+	ReadOnly bool   `json:"read_only,omitempty"`
+	External bool   `json:"external,omitempty"`
+}
+
+// Bind returns the mount in Docker's name:path[:ro] form, with the volume the app's
+// copy of the named volume
+func (v VolumeMount) Bind(appID uuid.UUID) string {
+	bind := v.Source(appID) + ":" + v.Target
+	if v.ReadOnly {
+		bind += ":ro"
+	}
+	return bind
+}
+
+// Source returns the Docker volume the mount uses: the external volume itself, or the
+// app's copy of the named volume
+func (v VolumeMount) Source(appID uuid.UUID) string {
+	if v.External {
+		return v.Name
+	}
+	return "nanopaas_" + appID.String() + "_" + v.Name
+}
+
+// AddedExternalVolumes returns the external volumes mounts adds to the ones in before
+func AddedExternalVolumes(before, mounts []VolumeMount) []string {
+	existing := make(map[string]bool, len(before))
+	for _, v := range before {
+		if v.External {
+			existing[v.Name] = true
+		}
+	}
+	var added []string
+	for _, v := range mounts {
+		if v.External && !existing[v.Name] {
+			added = append(added, v.Name)
+		}
+	}
+	return added
+}
+
+// ValidateRuntimeOptions checks the app's container runtime settings against the allowlists
+func (a *App) ValidateRuntimeOptions() error {
+	if a.RestartPolicy != "" && !AllowedRestartPolicies[a.RestartPolicy] {
+		return fmt.Errorf("restart_policy must be one of: no, always, on-failure, unless-stopped")
+	}
+
+	if a.NoFileLimit < 0 || a.NoFileLimit > MaxNoFileLimit {
+		return fmt.Errorf("nofile_limit must be between 0 and %d", MaxNoFileLimit)
+	}
+
+	if a.ShmSize < 0 || a.ShmSize > MaxShmSize {
+		return fmt.Errorf("shm_size must be between 0 and %d bytes", MaxShmSize)
+	}
+
+	if len(a.Tmpfs) > MaxTmpfsMounts {
+		return fmt.Errorf("at most %d tmpfs mounts are allowed", MaxTmpfsMounts)
+	}
+	for mountPath, opts := range a.Tmpfs {
+		if err := validateTmpfsMount(mountPath, opts); err != nil {
+			return err
+		}
+	}
+
+	for key, value := range a.Sysctls {
+		bounds, ok := AllowedSysctls[key]
+		if !ok {
+			return fmt.Errorf("sysctl %q is not allowed", key)
+		}
+		if err := bounds.validate(key, value); err != nil {
+			return err
+		}
+	}
+
+	if len(a.Volumes) > MaxVolumeMounts {
+		return fmt.Errorf("at most %d volume mounts are allowed", MaxVolumeMounts)
+	}
+	for _, v := range a.Volumes {
+		if !volumeNamePattern.MatchString(v.Name) {
+			return fmt.Errorf("volume name %q is invalid", v.Name)
+		}
+		if err := validateMountPath("volume", v.Target); err != nil {
+			return err
+		}
+	}
+
+	if len(a.NetworkAliases) > MaxNetworkAliases {
+		return fmt.Errorf("at most %d network aliases are allowed", MaxNetworkAliases)
+	}
+	for _, alias := range a.NetworkAliases {
+		if !networkAliasPattern.MatchString(alias) {
+			return fmt.Errorf("network alias %q must be a lowercase DNS label", alias)
+		}
+	}
+
+	return nil
+}
+
+// ContainerPorts returns the exposed port for container creation, or nil for apps
+// that only serve other apps on the shared network
+func (a *App) ContainerPorts() []string {
+	if a.ExposedPort <= 0 {
+		return nil
+	}
+	return []string{strconv.Itoa(a.ExposedPort)}
+}
+
+// VolumeBinds returns the app's volume mounts in Docker's bind form
+func (a *App) VolumeBinds() []string {
+	binds := make([]string, 0, len(a.Volumes))
+	for _, v := range a.Volumes {
+		binds = append(binds, v.Bind(a.ID))
+	}
+	return binds
+}
+
+// validateTmpfsMount checks a single tmpfs mount path and its options
+func validateTmpfsMount(mountPath, opts string) error {
+	if err := validateMountPath("tmpfs", mountPath); err != nil {
+		return err
+	}
+
+	// Without a size the mount may grow to half the host's memory
+	sized := false
+	for _, opt := range strings.Split(opts, ",") {
+		opt = strings.TrimSpace(opt)
+		switch {
+		case strings.HasPrefix(opt, "size="):
+			size, err := parseByteSize(strings.TrimPrefix(opt, "size="))
+			if err != nil || size <= 0 || size > MaxTmpfsSize {
+				return fmt.Errorf("tmpfs size for %q must be between 1 byte and %d bytes", mountPath, MaxTmpfsSize)
+			}
+			sized = true
+		case strings.HasPrefix(opt, "mode="):
+			if _, err := strconv.ParseUint(strings.TrimPrefix(opt, "mode="), 8, 32); err != nil {
+				return fmt.Errorf("tmpfs mode for %q must be octal", mountPath)
+			}
+		case allowedTmpfsOptions[opt]:
+		default:
+			return fmt.Errorf("tmpfs option %q is not allowed", opt)
+		}
+	}
+	if !sized {
+		return fmt.Errorf("tmpfs mount %q requires a size= option of at most %d bytes", mountPath, MaxTmpfsSize)
+	}
+
+	return nil
+}
+
+// validate checks a sysctl value against the bounds
+func (b SysctlRange) validate(key, value string) error {
+	fields := strings.Fields(value)
+	if b.Pair {
+		if len(fields) != 2 {
+			return fmt.Errorf("sysctl %q must be two ascending values between %d and %d", key, b.Min, b.Max)
+		}
+		low, lowErr := strconv.ParseInt(fields[0], 10, 64)
+		high, highErr := strconv.ParseInt(fields[1], 10, 64)
+		if lowErr != nil || highErr != nil || low < b.Min || high > b.Max || low > high {
+			return fmt.Errorf("sysctl %q must be two ascending values between %d and %d", key, b.Min, b.Max)
+		}
+		return nil
+	}
+	if len(fields) != 1 {
+		return fmt.Errorf("sysctl %q must be a value between %d and %d", key, b.Min, b.Max)
+	}
+	n, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil || n < b.Min || n > b.Max {
+		return fmt.Errorf("sysctl %q must be a value between %d and %d", key, b.Min, b.Max)
+	}
+	return nil
+}
+
+// validateMountPath checks that a mount target is a clean absolute path outside the reserved system paths
+func validateMountPath(kind, mountPath string) error {
+	if !strings.HasPrefix(mountPath, "/") || path.Clean(mountPath) != mountPath {
+		return fmt.Errorf("%s mount path %q must be a clean absolute path", kind, mountPath)
+	}
+	for _, reserved := range reservedMountPaths {
+		if mountPath == reserved || (reserved != "/" && strings.HasPrefix(mountPath, reserved+"/")) {
+			return fmt.Errorf("%s mount path %q is reserved", kind, mountPath)
+		}
+	}
+	return nil
+}
+
+// parseByteSize parses sizes like "64m", "1g" or "1048576"
+func parseByteSize(s string) (int64, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	multiplier := int64(1)
+	switch {
+	case strings.HasSuffix(s, "k"):
+		multiplier = 1024
+		s = strings.TrimSuffix(s, "k")
+	case strings.HasSuffix(s, "m"):
+		multiplier = 1024 * 1024
+		s = strings.TrimSuffix(s, "m")
+	case strings.HasSuffix(s, "g"):
+		multiplier = 1024 * 1024 * 1024
+		s = strings.TrimSuffix(s, "g")
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if n > math.MaxInt64/multiplier {
+		return 0, fmt.Errorf("size %q is too large", s)
+	}
+	return n * multiplier, nil
+}
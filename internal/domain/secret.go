@@ -0,0 +1,74 @@
+package domain
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SecretMask stands in for secret values wherever secrets are listed
+const SecretMask = "********"
+
+// MaxSecretSize caps a secret's plaintext
+const MaxSecretSize = 64 * 1024
+
+// secretKeyPattern matches names usable as environment variables
+var secretKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Secret is an app secret encrypted at rest. The plaintext is only decrypted to start
+// containers and is never stored or returned.
+type Secret struct {
+	Ciphertext []byte    `json:"ciphertext"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// ValidateSecret checks a secret's name and plaintext size
+func ValidateSecret(key string, value string) error {
+	if !secretKeyPattern.MatchString(key) {
+		return fmt.Errorf("secret name must be a valid environment variable name")
+	}
+	if len(value) > MaxSecretSize {
+		return fmt.Errorf("secret value must be at most %d bytes", MaxSecretSize)
+	}
+	return nil
+}
+
+// SecretAAD binds a secret's ciphertext to its app and name, so a ciphertext copied to
+// another app or key fails to decrypt
+func SecretAAD(appID uuid.UUID, key string) []byte {
+	return []byte(appID.String() + "/" + key)
+}
+
+// SetSecret stores an encrypted secret, replacing any plain env var of the same name
+func (a *App) SetSecret(key string, ciphertext []byte) {
+	if a.Secrets == nil {
+		a.Secrets = make(map[string]Secret)
+	}
+	now := time.Now().UTC()
+	a.Secrets[key] = Secret{Ciphertext: ciphertext, UpdatedAt: now}
+	delete(a.EnvVars, key)
+	a.UpdatedAt = now
+}
+
+// DeleteSecret removes a secret, reporting whether it existed
+func (a *App) DeleteSecret(key string) bool {
+	if _, ok := a.Secrets[key]; !ok {
+		return false
+	}
+	delete(a.Secrets, key)
+	a.UpdatedAt = time.Now().UTC()
+	return true
+}
+
+// SecretKeys returns the names of the app's secrets, sorted
+func (a *App) SecretKeys() []string {
+	keys := make([]string, 0, len(a.Secrets))
+	for k := range a.Secrets {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,21 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session is a sign-in on one device. The access and refresh tokens issued at sign-in,
+// and the tokens they are refreshed into, all belong to it; revoking the session
+// invalidates every one of them.
+type Session struct {
+	ID          string        `json:"id"`
+	UserID      uuid.UUID     `json:"user_id"`
+	IP          string        `json:"ip,omitempty"`
+	UserAgent   string        `json:"user_agent,omitempty"`
+	Provider    LoginProvider `json:"provider,omitempty"` // how the user signed in
+	CreatedAt   time.Time     `json:"created_at"`
+	RefreshedAt time.Time     `json:"refreshed_at"`
+	ExpiresAt   time.Time     `json:"expires_at"` // when the current refresh token expires
+}
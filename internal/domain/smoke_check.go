@@ -0,0 +1,104 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SmokeCheckType represents how a smoke check is executed
+type SmokeCheckType string
+
+const (
+	SmokeCheckHTTP    SmokeCheckType = "http"
+	SmokeCheckCommand SmokeCheckType = "command"
+)
+
+// MaxSmokeChecks limits the number of smoke checks per app
+const MaxSmokeChecks = 10
+
+// SmokeCheck is a post-deploy verification run after routing switches to a new deployment
+type SmokeCheck struct {
+	Name string         `json:"name"`
+	Type SmokeCheckType `json:"type"`
+
+	// HTTP checks: request Path on the app URL and compare the response
+	Method         string `json:"method,omitempty"`
+	Path           string `json:"path,omitempty"`
+	ExpectedStatus int    `json:"expected_status,omitempty"`
+	ExpectedBody   string `json:"expected_body,omitempty"` // substring match
+
+	// Command checks: run Command in a one-off container from the deployed image; exit 0 passes
+	Command []string `json:"command,omitempty"`
+
+	TimeoutSeconds int `json:"timeout_seconds,omitempty"`
+}
+
+// SmokeCheckResult records the outcome of a single smoke check
+type SmokeCheckResult struct {
+	Name     string        `json:"name"`
+	Passed   bool          `json:"passed"`
+	Message  string        `json:"message,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Timeout returns the check timeout, defaulting to 10 seconds
+func (c SmokeCheck) Timeout() time.Duration {
+	if c.TimeoutSeconds <= 0 {
+		return 10 * time.Second
+	}
+	return time.Duration(c.TimeoutSeconds) * time.Second
+}
+
+// Validate checks that the smoke check is well-formed
+func (c SmokeCheck) Validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("smoke check name is required")
+	}
+	if c.TimeoutSeconds < 0 || c.TimeoutSeconds > 300 {
+		return fmt.Errorf("smoke check %q timeout must be between 0 and 300 seconds", c.Name)
+	}
+
+	switch c.Type {
+	case SmokeCheckHTTP:
+		if !strings.HasPrefix(c.Path, "/") {
+			return fmt.Errorf("smoke check %q path must start with /", c.Name)
+		}
+		switch strings.ToUpper(c.Method) {
+		case "", "GET", "HEAD", "POST":
+		default:
+			return fmt.Errorf("smoke check %q method must be GET, HEAD or POST", c.Name)
+		}
+		if c.ExpectedStatus != 0 && (c.ExpectedStatus < 100 || c.ExpectedStatus > 599) {
+			return fmt.Errorf("smoke check %q expected_status is invalid", c.Name)
+		}
+	case SmokeCheckCommand:
+		if len(c.Command) == 0 {
+			return fmt.Errorf("smoke check %q command is required", c.Name)
+		}
+	default:
+		return fmt.Errorf("smoke check %q type must be http or command", c.Name)
+	}
+
+	return nil
+}
+
+// ValidateSmokeChecks validates a list of smoke checks
+func ValidateSmokeChecks(checks []SmokeCheck) error {
+	if len(checks) > MaxSmokeChecks {
+		return fmt.Errorf("at most %d smoke checks are allowed", MaxSmokeChecks)
+	}
+
+	seen := make(map[string]bool, len(checks))
+	for _, c := range checks {
+		if err := c.Validate(); err != nil {
+			return err
+		}
+		if seen[c.Name] {
+			return fmt.Errorf("duplicate smoke check name %q", c.Name)
+		}
+		seen[c.Name] = true
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package domain
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// DefaultStickyCookie is the affinity cookie name when none is configured
+const DefaultStickyCookie = "nanopaas_affinity"
+
+// stickyCookiePattern matches cookie names that need no quoting
+var stickyCookiePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+// allowedSameSite lists the accepted SameSite cookie attributes
+var allowedSameSite = map[string]bool{"": true, "lax": true, "strict": true, "none": true}
+
+// StickySessionPolicy pins each client to one replica with a load balancer cookie
+type StickySessionPolicy struct {
+	CookieName string `json:"cookie_name"`
+	Secure     bool   `json:"secure"`
+	HTTPOnly   bool   `json:"http_only"`
+	SameSite   string `json:"same_site,omitempty"` // lax, strict or none
+	MaxAge     int    `json:"max_age,omitempty"`   // seconds, 0 makes it a session cookie
+}
+
+// Normalize applies the default cookie name
+func (p *StickySessionPolicy) Normalize() {
+	if p.CookieName == "" {
+		p.CookieName = DefaultStickyCookie
+	}
+}
+
+// Validate checks that the sticky session policy is well-formed
+func (p *StickySessionPolicy) Validate() error {
+	if !stickyCookiePattern.MatchString(p.CookieName) {
+		return fmt.Errorf("sticky cookie_name must be 1-64 letters, digits, dots, dashes or underscores")
+	}
+	if !allowedSameSite[p.SameSite] {
+		return fmt.Errorf("sticky same_site must be lax, strict or none")
+	}
+	if p.SameSite == "none" && !p.Secure {
+		return fmt.Errorf("sticky same_site none requires a secure cookie")
+	}
+	if p.MaxAge < 0 {
+		return fmt.Errorf("sticky max_age must not be negative")
+	}
+	return nil
+}
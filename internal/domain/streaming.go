@@ -0,0 +1,48 @@
+package domain
+
+import "fmt"
+
+// StreamingMode tunes proxying for apps holding long-lived connections
+type StreamingMode string
+
+const (
+	StreamingNone      StreamingMode = ""
+	StreamingWebSocket StreamingMode = "websocket"
+	StreamingSSE       StreamingMode = "sse"
+)
+
+const (
+	// DefaultStreamIdleTimeout is the idle timeout for streaming routes, in seconds
+	DefaultStreamIdleTimeout = 3600
+
+	// MaxStreamIdleTimeout caps how long an idle streaming connection is kept (24h)
+	MaxStreamIdleTimeout = 86400
+)
+
+// IsValid reports whether the streaming mode is known
+func (m StreamingMode) IsValid() bool {
+	switch m {
+	case StreamingNone, StreamingWebSocket, StreamingSSE:
+		return true
+	}
+	return false
+}
+
+// ValidateStreaming checks the app's streaming mode and idle timeout
+func (a *App) ValidateStreaming() error {
+	if !a.StreamingMode.IsValid() {
+		return fmt.Errorf("streaming_mode must be websocket, sse or empty")
+	}
+	if a.StreamIdleTimeout < 0 || a.StreamIdleTimeout > MaxStreamIdleTimeout {
+		return fmt.Errorf("stream_idle_timeout must be between 0 and %d seconds", MaxStreamIdleTimeout)
+	}
+	return nil
+}
+
+// EffectiveStreamIdleTimeout returns the idle timeout in seconds, applying the default
+func (a *App) EffectiveStreamIdleTimeout() int {
+	if a.StreamIdleTimeout == 0 {
+		return DefaultStreamIdleTimeout
+	}
+	return a.StreamIdleTimeout
+}
@@ -15,6 +15,40 @@ const (
 	UserRoleViewer UserRole = "viewer"
 )
 
+// Permission is something a role allows
+type Permission string
+
+const (
+	PermissionAppsRead    Permission = "apps:read"    // view apps, builds, logs and repositories
+	PermissionAppsWrite   Permission = "apps:write"   // create, change and deploy apps the user can manage
+	PermissionSystemRead  Permission = "system:read"  // view containers, images outside apps and host state
+	PermissionSystemWrite Permission = "system:write" // manage containers, the host and platform settings
+)
+
+// rolePermissions lists what each role allows. Which apps a member may change is
+// decided by ownership and team membership, not by the role.
+var rolePermissions = map[UserRole][]Permission{
+	UserRoleViewer: {PermissionAppsRead},
+	UserRoleMember: {PermissionAppsRead, PermissionAppsWrite},
+	UserRoleAdmin:  {PermissionAppsRead, PermissionAppsWrite, PermissionSystemRead, PermissionSystemWrite},
+}
+
+// IsValid reports whether the role is one of the known roles
+func (r UserRole) IsValid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+// Can reports whether the role allows a permission
+func (r UserRole) Can(p Permission) bool {
+	for _, allowed := range rolePermissions[r] {
+		if allowed == p {
+			return true
+		}
+	}
+	return false
+}
+
 // User represents a platform user
 type User struct {
 	ID            uuid.UUID  `json:"id"`
@@ -24,11 +58,31 @@ type User struct {
 	GitHubID      int64      `json:"github_id,omitempty"`
 	GitHubLogin   string     `json:"github_login,omitempty"`
 	GitHubToken   string     `json:"-"` // Never expose in JSON
+	PasswordHash  string     `json:"-"` // bcrypt; empty for GitHub-only accounts
 	Role          UserRole   `json:"role"`
 	EmailVerified bool       `json:"email_verified"`
 	LastLoginAt   *time.Time `json:"last_login_at,omitempty"`
+	DeactivatedAt *time.Time `json:"deactivated_at,omitempty"` // set while an admin has disabled the account
 	CreatedAt     time.Time  `json:"created_at"`
 	UpdatedAt     time.Time  `json:"updated_at"`
+
+	// Two-factor authentication. TOTPSecret is set from enrollment on, but only
+	// checked at sign-in once a first code confirms it and TwoFactorEnabled is set.
+	TwoFactorEnabled bool     `json:"two_factor_enabled"`
+	TOTPSecret       string   `json:"-"`
+	TOTPLastStep     int64    `json:"-"` // time step of the last accepted code, so codes can't be replayed
+	RecoveryCodes    []string `json:"-"` // SHA-256 hashes of the unused recovery codes
+}
+
+// UserQuery selects users for the admin user list
+type UserQuery struct {
+	Search string   // case-insensitive substring of the name or email
+	Role   UserRole // empty for every role
+	Status string   // active, deactivated or empty for both
+	Sort   string   // created_at, email, name or last_login_at
+	Desc   bool
+	Limit  int // 0 returns every user
+	Offset int
 }
 
 // NewUser creates a new user
@@ -77,6 +131,43 @@ func (u *User) UpdateLastLogin() {
 	u.UpdatedAt = now
 }
 
+// SetPasswordHash sets the bcrypt hash the user signs in with
+func (u *User) SetPasswordHash(hash string) {
+	u.PasswordHash = hash
+	u.UpdatedAt = time.Now().UTC()
+}
+
+// HasPassword reports whether the user can sign in with email and password
+func (u *User) HasPassword() bool {
+	return u.PasswordHash != ""
+}
+
+// IsActive reports whether the user may sign in
+func (u *User) IsActive() bool {
+	return u.DeactivatedAt == nil
+}
+
+// Deactivate disables the account without deleting it
+func (u *User) Deactivate() {
+	now := time.Now().UTC()
+	u.DeactivatedAt = &now
+	u.UpdatedAt = now
+}
+
+// Reactivate lets a deactivated user sign in again
+func (u *User) Reactivate() {
+	u.DeactivatedAt = nil
+	u.UpdatedAt = time.Now().UTC()
+}
+
+// DisableTwoFactor removes the user's TOTP secret and recovery codes
+func (u *User) DisableTwoFactor() {
+	u.TwoFactorEnabled = false
+	u.TOTPSecret = ""
+	u.RecoveryCodes = nil
+	u.UpdatedAt = time.Now().UTC()
+}
+
 // IsAdmin checks if user is admin
 func (u *User) IsAdmin() bool {
 	return u.Role == UserRoleAdmin
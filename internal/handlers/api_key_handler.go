@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+	"github.com/nanopaas/nanopaas/internal/services/auth"
+)
+
+// Note: AuthHandler and NewAuthHandler are defined in auth_handler.go
+// This file adds API keys for CI and other automation to the existing AuthHandler
+
+const apiKeyContextKey contextKey = "api_key"
+
+// deployScopePaths are the POST endpoints, by path suffix, a deploy-scoped key may call
+var deployScopePaths = []string{"/deploy", "/builds", "/builds/git", "/restart", "/scale", "/cancel"}
+
+// CreateAPIKeyRequest represents a request to create an API key
+type CreateAPIKeyRequest struct {
+	Name      string `json:"name"`
+	Scope     string `json:"scope,omitempty"`      // full, deploy or read; defaults to full
+	ExpiresIn int    `json:"expires_in,omitempty"` // days, 0 never expires
+}
+
+// CreateAPIKeyResponse returns a new API key. Key is shown only once.
+type CreateAPIKeyResponse struct {
+	*domain.APIKey
+	Key string `json:"key"`
+}
+
+// ListAPIKeys returns the user's API keys, without the keys themselves
+func (h *AuthHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	user, ok := requireSessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	keys, err := h.authService.ListAPIKeys(r.Context(), user.ID)
+	if err != nil {
+		h.logger.Error("Failed to list API keys", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "Failed to list API keys")
+		return
+	}
+	writeJSON(w, http.StatusOK, keys)
+}
+
+// CreateAPIKey creates an API key acting as the user, limited to the requested scope
+func (h *AuthHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	user, ok := requireSessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, "Key name is required")
+		return
+	}
+	scope, ok := domain.ParseAPIKeyScope(req.Scope)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "scope must be full, deploy or read")
+		return
+	}
+	if req.ExpiresIn < 0 {
+		writeError(w, http.StatusBadRequest, "expires_in must not be negative")
+		return
+	}
+	var expiresAt *time.Time
+	if req.ExpiresIn > 0 {
+		t := time.Now().UTC().AddDate(0, 0, req.ExpiresIn)
+		expiresAt = &t
+	}
+
+	key, token, err := h.authService.CreateAPIKey(r.Context(), user, req.Name, scope, expiresAt)
+	if err != nil {
+		if errors.Is(err, auth.ErrAPIKeysDisabled) {
+			writeError(w, http.StatusServiceUnavailable, "API keys are not enabled")
+			return
+		}
+		h.logger.Error("Failed to create API key", zap.Error(err))
+		writeDomainError(w, err, "Failed to create API key")
+		return
+	}
+	writeJSON(w, http.StatusCreated, CreateAPIKeyResponse{APIKey: key, Key: token})
+}
+
+// RevokeAPIKey deletes one of the user's API keys
+func (h *AuthHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	user, ok := requireSessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := uuid.Parse(chi.URLParam(r, "keyId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "API key not found")
+		return
+	}
+	if err := h.authService.RevokeAPIKey(r.Context(), user.ID, id); err != nil {
+		if errors.Is(err, auth.ErrAPIKeysDisabled) {
+			writeError(w, http.StatusServiceUnavailable, "API keys are not enabled")
+			return
+		}
+		writeDomainError(w, err, "Failed to revoke API key")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"message": "API key revoked",
+	})
+}
+
+// requireSessionUser returns the authenticated user, refusing requests made with an API
+// key or deploy token so a leaked one cannot mint or revoke credentials
+func requireSessionUser(w http.ResponseWriter, r *http.Request) (*domain.User, bool) {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Not authenticated")
+		return nil, false
+	}
+	if GetAPIKeyFromContext(r.Context()) != nil || GetDeployTokenFromContext(r.Context()) != nil {
+		writeError(w, http.StatusForbidden, "API keys and deploy tokens cannot manage credentials; sign in instead")
+		return nil, false
+	}
+	return user, true
+}
+
+// serveWithAPIKey authenticates a request made with an API key, enforces the key's scope
+// and records the request against the key
+func serveWithAPIKey(w http.ResponseWriter, r *http.Request, next http.Handler, authService *auth.Service, token string) {
+	user, key, err := authService.AuthenticateAPIKey(r.Context(), token)
+	if errors.Is(err, auth.ErrUserDeactivated) {
+		writeError(w, http.StatusForbidden, "This account has been deactivated")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "Invalid or expired API key")
+		return
+	}
+	if !apiKeyAllows(key.Scope, r) {
+		writeError(w, http.StatusForbidden, "API key scope "+string(key.Scope)+" does not allow this request")
+		return
+	}
+	authService.AuditAPIKeyRequest(key, r.Method, r.URL.Path)
+
+	ctx := SetUserInContext(r.Context(), user)
+	ctx = context.WithValue(ctx, apiKeyContextKey, key)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// apiKeyAllows checks a request against an API key scope. Read-only keys may only read;
+// deploy keys may also build, deploy, restart and scale.
+func apiKeyAllows(scope domain.APIKeyScope, r *http.Request) bool {
+	switch scope {
+	case domain.APIKeyScopeFull:
+		return true
+	case domain.APIKeyScopeDeploy:
+		return isReadRequest(r) || isDeployRequest(r)
+	}
+	return isReadRequest(r)
+}
+
+// isReadRequest reports whether a request only reads
+func isReadRequest(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// isDeployRequest reports whether a request builds, deploys, restarts or scales an app
+func isDeployRequest(r *http.Request) bool {
+	if r.Method != http.MethodPost {
+		return false
+	}
+	path := strings.TrimSuffix(r.URL.Path, "/")
+	for _, suffix := range deployScopePaths {
+		if strings.HasSuffix(path, suffix) && strings.Contains(path, "/apps/") {
+			return true
+		}
+	}
+	return false
+}
+
+// GetAPIKeyFromContext returns the API key a request was authenticated with, or nil for
+// requests authenticated with a JWT
+func GetAPIKeyFromContext(ctx context.Context) *domain.APIKey {
+	key, ok := ctx.Value(apiKeyContextKey).(*domain.APIKey)
+	if !ok {
+		return nil
+	}
+	return key
+}
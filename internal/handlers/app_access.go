@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// TeamMembershipSource looks up the teams a user belongs to
+type TeamMembershipSource interface {
+	TeamIDsForUser(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error)
+}
+
+// SetTeamMembershipSource sets where team membership is looked up, letting team
+// members manage the team's apps
+func (h *AppHandler) SetTeamMembershipSource(src TeamMembershipSource) {
+	h.teams = src
+}
+
+// RequireAppAccess rejects requests for an app the authenticated user can't manage.
+// Collaborators are let through for the requests their permission covers. It must be
+// mounted inline (chi Group/With) so the appId URL parameter is resolved.
+func (h *AppHandler) RequireAppAccess(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := GetUserFromContext(r.Context())
+		if user == nil {
+			writeError(w, http.StatusUnauthorized, "Not authenticated")
+			return
+		}
+		app, err := h.getApp(chi.URLParam(r, "appId"))
+		if err != nil {
+			writeError(w, http.StatusNotFound, "App not found")
+			return
+		}
+		if !h.canManageApp(user, app, h.userTeams(r.Context(), user)) && !h.collaboratorAllows(r, user, app) {
+			h.logger.Warn("App access denied",
+				zap.String("user_id", user.ID.String()),
+				zap.String("app_id", app.ID.String()),
+			)
+			writeError(w, http.StatusForbidden, "Access denied")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// CanReadApp reports whether the user may read the app, as RequireAppAccess lets them
+// make read-only requests for it: they can manage it or collaborate on it
+func (h *AppHandler) CanReadApp(ctx context.Context, user *domain.User, app *domain.App) bool {
+	if h.canManageApp(user, app, h.userTeams(ctx, user)) {
+		return true
+	}
+	_, collaborates := h.collaboratorPermission(ctx, user, app)
+	return collaborates
+}
+
+// requireAppManager resolves the appId URL parameter to an app the user can manage,
+// writing 403 with message for collaborators and others who can't
+func (h *AppHandler) requireAppManager(w http.ResponseWriter, r *http.Request, message string) (*domain.App, bool) {
+	app, err := h.getApp(chi.URLParam(r, "appId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "App not found")
+		return nil, false
+	}
+	user := GetUserFromContext(r.Context())
+	if !h.canManageApp(user, app, h.userTeams(r.Context(), user)) {
+		writeError(w, http.StatusForbidden, message)
+		return nil, false
+	}
+	return app, true
+}
+
+// canManageApp checks the user owns the app, is an admin or belongs to the app's team
+func (h *AppHandler) canManageApp(user *domain.User, app *domain.App, teams map[uuid.UUID]bool) bool {
+	if user.CanManageApp(app) {
+		return true
+	}
+	return app.TeamID != nil && teams[*app.TeamID]
+}
+
+// ManagedApps returns the apps the user can manage
+func (h *AppHandler) ManagedApps(ctx context.Context, user *domain.User) []*domain.App {
+	teams := h.userTeams(ctx, user)
+	apps := make([]*domain.App, 0)
+	for _, app := range h.ListApps() {
+		if h.canManageApp(user, app, teams) {
+			apps = append(apps, app)
+		}
+	}
+	return apps
+}
+
+// VisibleApps returns the apps the user can manage or collaborates on
+func (h *AppHandler) VisibleApps(ctx context.Context, user *domain.User) []*domain.App {
+	teams := h.userTeams(ctx, user)
+	shared := h.collaboratorApps(ctx, user)
+	apps := make([]*domain.App, 0)
+	for _, app := range h.ListApps() {
+		if h.canManageApp(user, app, teams) || shared[app.ID] {
+			apps = append(apps, app)
+		}
+	}
+	return apps
+}
+
+// ReassignOwner hands the loaded apps and projects of one user to another, after the
+// store reassigned them when the user was deleted
+func (h *AppHandler) ReassignOwner(from, to uuid.UUID) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, app := range h.apps {
+		if app.OwnerID == from {
+			app.OwnerID = to
+		}
+	}
+	for _, project := range h.projects {
+		if project.OwnerID == from {
+			project.OwnerID = to
+		}
+	}
+}
+
+// userTeams returns the set of teams the user belongs to. Lookup failures are logged
+// and treated as no memberships, so access falls back to ownership.
+func (h *AppHandler) userTeams(ctx context.Context, user *domain.User) map[uuid.UUID]bool {
+	teams := make(map[uuid.UUID]bool)
+	if h.teams == nil || user.IsAdmin() {
+		return teams
+	}
+	teamIDs, err := h.teams.TeamIDsForUser(ctx, user.ID)
+	if err != nil {
+		h.logger.Warn("Failed to look up user teams", zap.String("user_id", user.ID.String()), zap.Error(err))
+		return teams
+	}
+	for _, id := range teamIDs {
+		teams[id] = true
+	}
+	return teams
+}
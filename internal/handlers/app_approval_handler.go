@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// Note: AppHandler and NewAppHandler are defined in app_handler.go
+// This file adds deployment approval for protected apps to the existing AppHandler
+
+// PendingApprovalResponse is a deployment awaiting approval, with the app it deploys
+type PendingApprovalResponse struct {
+	*domain.Deployment
+	AppName    string `json:"app_name"`
+	AppSlug    string `json:"app_slug"`
+	CanApprove bool   `json:"can_approve"` // the caller is an admin or the app's owner
+}
+
+// RejectDeploymentRequest represents a request to reject a deployment
+type RejectDeploymentRequest struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+// ErrAwaitingApproval is returned by DeployBuild for apps that require approval
+var ErrAwaitingApproval = errors.New("deployment is awaiting approval")
+
+// DeployBuild deploys the image of a webhook-triggered build and returns the app's URL.
+// Apps that require approval get a deployment awaiting approval instead, and
+// ErrAwaitingApproval.
+func (h *AppHandler) DeployBuild(appID uuid.UUID, build *domain.Build, imageTag string) (string, error) {
+	app, exists := h.FindApp(appID)
+	if !exists {
+		h.logger.Warn("DeployBuild: app not found", zap.String("app_id", appID.String()))
+		return "", fmt.Errorf("app %w", domain.ErrNotFound)
+	}
+
+	if err := app.CheckAutomaticAction(); err != nil {
+		h.logger.Warn("Skipping deploy for pinned deployment",
+			zap.String("app_id", appID.String()),
+			zap.String("image_tag", imageTag),
+			zap.Error(err),
+		)
+		return "", err
+	}
+
+	if app.RequireApproval {
+		h.orchestrator.RequestApproval(app, build.ID, imageTag)
+		return "", ErrAwaitingApproval
+	}
+
+	app.UpdateImage(imageTag)
+	if err := h.deployInBackground(app, true); err != nil {
+		return "", err
+	}
+	return h.router.GetAppURL(app), nil
+}
+
+// ListPendingApprovals returns the deployments awaiting approval for apps the user can manage
+func (h *AppHandler) ListPendingApprovals(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Not authenticated")
+		return
+	}
+	teams := h.userTeams(r.Context(), user)
+
+	pending := make([]PendingApprovalResponse, 0)
+	for _, d := range h.orchestrator.PendingApprovals() {
+		app, ok := h.FindApp(d.AppID)
+		if !ok || !h.canManageApp(user, app, teams) {
+			continue
+		}
+		pending = append(pending, PendingApprovalResponse{
+			Deployment: d,
+			AppName:    app.Name,
+			AppSlug:    app.Slug,
+			CanApprove: user.CanManageApp(app),
+		})
+	}
+	writeJSON(w, http.StatusOK, pending)
+}
+
+// ApproveDeployment runs a deployment awaiting approval. Only admins and the app's owner
+// can approve.
+func (h *AppHandler) ApproveDeployment(w http.ResponseWriter, r *http.Request) {
+	app, deploymentID, ok := h.reviewableDeployment(w, r)
+	if !ok {
+		return
+	}
+	user := GetUserFromContext(r.Context())
+
+	if app.IsPinned() {
+		writePinConflict(w, &domain.PinnedError{Pin: app.Pin})
+		return
+	}
+
+	deployment, err := h.orchestrator.ApproveDeployment(r.Context(), app, deploymentID, user.ID)
+	if deployment == nil {
+		writeDomainError(w, err, "Failed to approve deployment")
+		return
+	}
+	h.saveApp(r.Context(), app)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Deployment failed: "+err.Error())
+		return
+	}
+
+	if !h.verifyDeployment(w, r, app, deployment) {
+		return
+	}
+
+	h.recordIncidentEvent(app.ID, domain.IncidentEventDeploy,
+		fmt.Sprintf("Deployed %s as approved deployment %s", deployment.ImageID, deployment.ID), requestActor(r))
+
+	h.logger.Info("Approved deployment deployed",
+		zap.String("app_id", app.ID.String()),
+		zap.String("deployment_id", deployment.ID.String()),
+		zap.String("approved_by", user.ID.String()),
+	)
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message":       "Deployment approved",
+		"deployment_id": deployment.ID.String(),
+		"status":        string(deployment.Status),
+		"url":           h.router.GetAppURL(app),
+		"smoke_results": deployment.SmokeResults,
+	})
+}
+
+// RejectDeployment closes a deployment awaiting approval without running it
+func (h *AppHandler) RejectDeployment(w http.ResponseWriter, r *http.Request) {
+	var req RejectDeploymentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	app, deploymentID, ok := h.reviewableDeployment(w, r)
+	if !ok {
+		return
+	}
+	user := GetUserFromContext(r.Context())
+
+	deployment, err := h.orchestrator.RejectDeployment(app, deploymentID, user.ID, req.Reason)
+	if err != nil {
+		writeDomainError(w, err, "Failed to reject deployment")
+		return
+	}
+	writeJSON(w, http.StatusOK, deployment)
+}
+
+// reviewableDeployment resolves the deployment in the URL and checks the user may review
+// it, writing the error response when not
+func (h *AppHandler) reviewableDeployment(w http.ResponseWriter, r *http.Request) (*domain.App, uuid.UUID, bool) {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Not authenticated")
+		return nil, uuid.Nil, false
+	}
+	deploymentID, err := uuid.Parse(chi.URLParam(r, "deploymentId"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid deployment ID")
+		return nil, uuid.Nil, false
+	}
+	deployment, ok := h.orchestrator.GetDeployment(deploymentID)
+	if !ok {
+		writeError(w, http.StatusNotFound, "Deployment not found")
+		return nil, uuid.Nil, false
+	}
+	app, ok := h.FindApp(deployment.AppID)
+	if !ok || !h.canManageApp(user, app, h.userTeams(r.Context(), user)) {
+		writeError(w, http.StatusNotFound, "Deployment not found")
+		return nil, uuid.Nil, false
+	}
+	if !user.CanManageApp(app) {
+		writeError(w, http.StatusForbidden, "Only admins and the app's owner can review deployments")
+		return nil, uuid.Nil, false
+	}
+	return app, deploymentID, true
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// Note: AppHandler and NewAppHandler are defined in app_handler.go
+// This file adds deployment changelogs to the existing AppHandler
+
+// BuildHistory provides an app's retained builds
+type BuildHistory interface {
+	// AppBuilds returns the app's builds, newest first
+	AppBuilds(appID uuid.UUID) []*domain.Build
+}
+
+// SetBuildHistory sets where changelogs find the builds deployments were made from
+func (h *AppHandler) SetBuildHistory(builds BuildHistory) {
+	h.builds = builds
+}
+
+// ChangelogEntry lists the commits a deployment took live
+type ChangelogEntry struct {
+	DeploymentID string          `json:"deployment_id"`
+	DeployedAt   time.Time       `json:"deployed_at"`
+	ImageID      string          `json:"image_id"`
+	BuildID      string          `json:"build_id,omitempty"`
+	GitRef       string          `json:"git_ref,omitempty"`
+	GitTag       string          `json:"git_tag,omitempty"`
+	GitCommit    string          `json:"git_commit,omitempty"`
+	CompareURL   string          `json:"compare_url,omitempty"` // diff from the previous deployment
+	Commits      []domain.Commit `json:"commits"`               // oldest first
+}
+
+// changelogSorts compares changelog entries by each sortable key of the changelog
+var changelogSorts = map[string]func(a, b ChangelogEntry) int{
+	"deployed_at": func(a, b ChangelogEntry) int { return a.DeployedAt.Compare(b.DeployedAt) },
+}
+
+// Changelog lists an app's successful deployments with the commits each took live: those
+// pushed since the previous successful deployment. Deployments of images not built from
+// a push, and of builds no longer retained, list no commits.
+func (h *AppHandler) Changelog(w http.ResponseWriter, r *http.Request) {
+	app, err := h.getApp(chi.URLParam(r, "appId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "App not found")
+		return
+	}
+	params, err := parseListParams(r, []string{"deployed_at"}, "-deployed_at")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var builds []*domain.Build
+	if h.builds != nil {
+		builds = h.builds.AppBuilds(app.ID)
+	}
+	slices.Reverse(builds) // oldest first
+	byImage := make(map[string]*domain.Build, len(builds))
+	for _, b := range builds {
+		if b.ImageTag != "" {
+			byImage[b.ImageTag] = b
+		}
+	}
+
+	deployments := h.orchestrator.AppDeployments(app.ID)
+	slices.Reverse(deployments) // oldest first
+
+	entries := make([]ChangelogEntry, 0)
+	var previous *domain.Build
+	for _, d := range deployments {
+		if d.Status != domain.DeploymentStatusSucceeded {
+			continue
+		}
+		entry := ChangelogEntry{
+			DeploymentID: d.ID.String(),
+			DeployedAt:   d.CreatedAt,
+			ImageID:      d.ImageID,
+			Commits:      []domain.Commit{},
+		}
+		if d.CompletedAt != nil {
+			entry.DeployedAt = *d.CompletedAt
+		}
+
+		build := byImage[d.ImageID]
+		if build != nil {
+			entry.BuildID = build.ID.String()
+			entry.GitRef = build.GitRef
+			entry.GitTag = build.GitTag
+			entry.GitCommit = build.GitCommit
+			entry.CompareURL = build.CompareURL
+			entry.Commits = commitsSince(builds, previous, build)
+			if previous != nil && previous.GitCommit != "" && build.GitCommit != "" {
+				entry.CompareURL = compareRange(build.CompareURL, previous.GitCommit, build.GitCommit)
+			}
+		}
+		previous = build
+		entries = append(entries, entry)
+	}
+
+	page, total := sortAndPage(entries, params, changelogSorts)
+	writeList(w, r, page, total, params)
+}
+
+// commitsSince returns the commits pushed in builds after previous up to and including
+// build, oldest first and without repeats. builds are oldest first.
+func commitsSince(builds []*domain.Build, previous, build *domain.Build) []domain.Commit {
+	commits := make([]domain.Commit, 0)
+	seen := make(map[string]bool)
+	for _, b := range builds {
+		if previous != nil && !b.CreatedAt.After(previous.CreatedAt) {
+			continue
+		}
+		if b.CreatedAt.After(build.CreatedAt) {
+			break
+		}
+		for _, c := range b.Commits {
+			if !seen[c.SHA] {
+				seen[c.SHA] = true
+				commits = append(commits, c)
+			}
+		}
+	}
+	return commits
+}
+
+// compareRange points a provider's compare URL, like
+// https://github.com/acme/shop/compare/a...b, at the range from...to. URLs of other
+// forms are returned unchanged.
+func compareRange(compareURL, from, to string) string {
+	i := strings.LastIndex(compareURL, "/compare/")
+	if i < 0 {
+		return compareURL
+	}
+	return compareURL[:i] + "/compare/" + from + "..." + to
+}
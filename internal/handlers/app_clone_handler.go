@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// Note: AppHandler and NewAppHandler are defined in app_handler.go
+// This file adds app cloning to the existing AppHandler
+
+// CloneLabel labels cloned apps with the ID of the app they were cloned from
+const CloneLabel = "nanopaas.cloned-from"
+
+// CloneAppRequest represents a request to clone an app
+type CloneAppRequest struct {
+	Name        string `json:"name"`
+	Slug        string `json:"slug,omitempty"`
+	CopyImage   bool   `json:"copy_image,omitempty"`   // start from the original's current image
+	CopySecrets bool   `json:"copy_secrets,omitempty"` // copy the original's secrets
+	Deploy      bool   `json:"deploy,omitempty"`       // deploy the copied image right away
+}
+
+// Clone creates a new app with the configuration of an existing one, e.g. a staging
+// copy. The clone gets its own slug and subdomain.
+func (h *AppHandler) Clone(w http.ResponseWriter, r *http.Request) {
+	source, err := h.getApp(chi.URLParam(r, "appId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	var req CloneAppRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, "App name is required")
+		return
+	}
+	if req.Slug == "" {
+		req.Slug = slugify(req.Name)
+	}
+	if err := h.router.ValidateSubdomain(req.Slug); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid slug: "+err.Error())
+		return
+	}
+	for _, app := range h.ListApps() {
+		if app.Slug == req.Slug {
+			writeError(w, http.StatusConflict, "App with this slug already exists")
+			return
+		}
+	}
+	if req.Deploy && (!req.CopyImage || source.CurrentImageID == "") {
+		writeError(w, http.StatusBadRequest, "deploy requires copy_image and an app with a deployed image")
+		return
+	}
+
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Not authenticated")
+		return
+	}
+	clone := source.Clone(req.Name, req.Slug, user.ID, domain.CloneOptions{Image: req.CopyImage})
+	clone.Labels[CloneLabel] = source.ID.String()
+	if req.CopySecrets {
+		if err := h.copySecrets(source, clone); err != nil {
+			h.logger.Error("Failed to copy secrets to clone", zap.String("source_app_id", source.ID.String()), zap.Error(err))
+			writeError(w, http.StatusInternalServerError, "Failed to copy secrets")
+			return
+		}
+	}
+
+	if h.appStore != nil {
+		if err := h.appStore.Create(r.Context(), clone); err != nil {
+			h.logger.Error("Failed to store app", zap.String("slug", clone.Slug), zap.Error(err))
+			writeDomainError(w, err, "Failed to create app")
+			return
+		}
+	}
+	h.cacheApp(clone)
+
+	h.logger.Info("App cloned",
+		zap.String("app_id", clone.ID.String()),
+		zap.String("source_app_id", source.ID.String()),
+		zap.String("slug", clone.Slug),
+	)
+
+	response := h.appToResponse(clone)
+	if req.Deploy {
+		go h.deployInBackground(clone, true)
+	}
+	writeJSON(w, http.StatusCreated, response)
+}
+
+// copySecrets re-encrypts the source app's secrets for its clone. Each secret is sealed
+// for its app's ID, so a copied ciphertext wouldn't decrypt for the clone.
+func (h *AppHandler) copySecrets(source, clone *domain.App) error {
+	if len(source.Secrets) == 0 {
+		return nil
+	}
+	if h.secrets == nil {
+		return fmt.Errorf("app has secrets but no secrets master key is configured")
+	}
+	for _, key := range source.SecretKeys() {
+		secret := source.Secrets[key]
+		plaintext, err := h.secrets.Open(secret.Ciphertext, domain.SecretAAD(source.ID, key))
+		if err != nil {
+			return fmt.Errorf("failed to decrypt secret %s: %w", key, err)
+		}
+		sealed, err := h.secrets.Seal(plaintext, domain.SecretAAD(clone.ID, key))
+		if err != nil {
+			return fmt.Errorf("failed to encrypt secret %s: %w", key, err)
+		}
+		clone.SetSecret(key, sealed)
+	}
+	return nil
+}
@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// Note: AppHandler and NewAppHandler are defined in app_handler.go
+// This file adds per-app collaborators to the existing AppHandler
+
+// CollaboratorStore persists app collaborator grants
+type CollaboratorStore interface {
+	Create(ctx context.Context, c *domain.AppCollaborator) error
+	Update(ctx context.Context, c *domain.AppCollaborator) error
+	Delete(ctx context.Context, appID, userID uuid.UUID) error
+	Get(ctx context.Context, appID, userID uuid.UUID) (*domain.AppCollaborator, error)
+	ListForApp(ctx context.Context, appID uuid.UUID) ([]*domain.AppCollaborator, error)
+	ListForUser(ctx context.Context, userID uuid.UUID) ([]*domain.AppCollaborator, error)
+}
+
+// UserLookup finds the users collaborators are added by
+type UserLookup interface {
+	GetByID(ctx context.Context, id uuid.UUID) (*domain.User, error)
+	GetByEmail(ctx context.Context, email string) (*domain.User, error)
+}
+
+// AddCollaboratorRequest represents a request to give a user access to an app
+type AddCollaboratorRequest struct {
+	UserID     string                        `json:"user_id,omitempty"`
+	Email      string                        `json:"email,omitempty"` // used when user_id is empty
+	Permission domain.CollaboratorPermission `json:"permission"`      // read or deploy
+}
+
+// UpdateCollaboratorRequest represents a request to change a collaborator's permission
+type UpdateCollaboratorRequest struct {
+	Permission domain.CollaboratorPermission `json:"permission"`
+}
+
+// SetCollaboratorStore sets the store collaborators are persisted to and where the
+// users they are granted to are looked up
+func (h *AppHandler) SetCollaboratorStore(store CollaboratorStore, users UserLookup) {
+	h.collaborators = store
+	h.users = users
+}
+
+// ListCollaborators returns the users an app is shared with
+func (h *AppHandler) ListCollaborators(w http.ResponseWriter, r *http.Request) {
+	app, ok := h.requireCollaboratorAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	collaborators, err := h.collaborators.ListForApp(r.Context(), app.ID)
+	if err != nil {
+		h.logger.Error("Failed to list collaborators", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "Failed to list collaborators")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"collaborators": collaborators,
+		"total":         len(collaborators),
+	})
+}
+
+// AddCollaborator gives a user read or deploy access to an app, without adding them
+// to the app's team
+func (h *AppHandler) AddCollaborator(w http.ResponseWriter, r *http.Request) {
+	app, ok := h.requireCollaboratorAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	var req AddCollaboratorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if !req.Permission.IsValid() {
+		writeError(w, http.StatusBadRequest, "permission must be read or deploy")
+		return
+	}
+
+	var target *domain.User
+	var err error
+	switch {
+	case req.UserID != "":
+		id, parseErr := uuid.Parse(req.UserID)
+		if parseErr != nil {
+			writeError(w, http.StatusBadRequest, "Invalid user ID")
+			return
+		}
+		target, err = h.users.GetByID(r.Context(), id)
+	case strings.TrimSpace(req.Email) != "":
+		target, err = h.users.GetByEmail(r.Context(), strings.ToLower(strings.TrimSpace(req.Email)))
+	default:
+		writeError(w, http.StatusBadRequest, "user_id or email is required")
+		return
+	}
+	if errors.Is(err, domain.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to look up collaborator", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "Failed to add collaborator")
+		return
+	}
+	if target.ID == app.OwnerID {
+		writeError(w, http.StatusBadRequest, "The app owner already has full access")
+		return
+	}
+
+	user := GetUserFromContext(r.Context())
+	c := domain.NewAppCollaborator(app.ID, target.ID, req.Permission, user.ID)
+	if err := h.collaborators.Create(r.Context(), c); err != nil {
+		if errors.Is(err, domain.ErrConflict) {
+			writeError(w, http.StatusConflict, "User is already a collaborator; update their permission instead")
+			return
+		}
+		writeDomainError(w, err, "Failed to add collaborator")
+		return
+	}
+	c.Email, c.Name = target.Email, target.Name
+
+	h.logger.Info("Collaborator added",
+		zap.String("app_id", app.ID.String()),
+		zap.String("user_id", target.ID.String()),
+		zap.String("permission", string(c.Permission)),
+		zap.String("by", user.ID.String()),
+	)
+	writeJSON(w, http.StatusCreated, c)
+}
+
+// UpdateCollaborator changes a collaborator's permission
+func (h *AppHandler) UpdateCollaborator(w http.ResponseWriter, r *http.Request) {
+	app, ok := h.requireCollaboratorAdmin(w, r)
+	if !ok {
+		return
+	}
+	userID, ok := parseUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req UpdateCollaboratorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if !req.Permission.IsValid() {
+		writeError(w, http.StatusBadRequest, "permission must be read or deploy")
+		return
+	}
+
+	c, err := h.collaborators.Get(r.Context(), app.ID, userID)
+	if err != nil {
+		h.writeCollaboratorError(w, err, "Failed to update collaborator")
+		return
+	}
+	c.Permission = req.Permission
+	c.UpdatedAt = time.Now().UTC()
+	if err := h.collaborators.Update(r.Context(), c); err != nil {
+		h.writeCollaboratorError(w, err, "Failed to update collaborator")
+		return
+	}
+
+	h.logger.Info("Collaborator permission changed",
+		zap.String("app_id", app.ID.String()),
+		zap.String("user_id", userID.String()),
+		zap.String("permission", string(c.Permission)),
+	)
+	writeJSON(w, http.StatusOK, c)
+}
+
+// RemoveCollaborator takes a user's access to an app away
+func (h *AppHandler) RemoveCollaborator(w http.ResponseWriter, r *http.Request) {
+	app, ok := h.requireCollaboratorAdmin(w, r)
+	if !ok {
+		return
+	}
+	userID, ok := parseUserID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.collaborators.Delete(r.Context(), app.ID, userID); err != nil {
+		h.writeCollaboratorError(w, err, "Failed to remove collaborator")
+		return
+	}
+
+	h.logger.Info("Collaborator removed",
+		zap.String("app_id", app.ID.String()),
+		zap.String("user_id", userID.String()),
+	)
+	writeJSON(w, http.StatusOK, map[string]string{
+		"message": "Collaborator removed",
+	})
+}
+
+// requireCollaboratorAdmin resolves an app whose collaborators the user may manage:
+// the owner, admins and team members, but not collaborators themselves
+func (h *AppHandler) requireCollaboratorAdmin(w http.ResponseWriter, r *http.Request) (*domain.App, bool) {
+	if h.collaborators == nil {
+		writeError(w, http.StatusServiceUnavailable, "Collaborators are not enabled")
+		return nil, false
+	}
+	return h.requireAppManager(w, r, "Only the app owner, its team and admins can manage collaborators")
+}
+
+// writeCollaboratorError maps collaborator store errors to responses
+func (h *AppHandler) writeCollaboratorError(w http.ResponseWriter, err error, message string) {
+	if errors.Is(err, domain.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Collaborator not found")
+		return
+	}
+	h.logger.Error(message, zap.Error(err))
+	writeError(w, http.StatusInternalServerError, message)
+}
+
+// collaboratorAllows reports whether the user collaborates on the app with a permission
+// covering the request. Read access allows read-only requests; deploy access also
+// allows builds, deploys, restarts and scaling.
+func (h *AppHandler) collaboratorAllows(r *http.Request, user *domain.User, app *domain.App) bool {
+	permission, ok := h.collaboratorPermission(r.Context(), user, app)
+	if !ok {
+		return false
+	}
+	switch permission {
+	case domain.CollaboratorDeploy:
+		return isReadRequest(r) || isDeployRequest(r)
+	case domain.CollaboratorRead:
+		return isReadRequest(r)
+	}
+	return false
+}
+
+// collaboratorPermission returns the permission the user collaborates on the app with,
+// if any. Lookup failures are logged and treated as none.
+func (h *AppHandler) collaboratorPermission(ctx context.Context, user *domain.User, app *domain.App) (domain.CollaboratorPermission, bool) {
+	if h.collaborators == nil {
+		return "", false
+	}
+	c, err := h.collaborators.Get(ctx, app.ID, user.ID)
+	if err != nil {
+		if !errors.Is(err, domain.ErrNotFound) {
+			h.logger.Warn("Failed to look up collaborator", zap.String("user_id", user.ID.String()), zap.Error(err))
+		}
+		return "", false
+	}
+	return c.Permission, true
+}
+
+// collaboratorApps returns the set of apps shared with the user. Lookup failures are
+// logged and treated as none.
+func (h *AppHandler) collaboratorApps(ctx context.Context, user *domain.User) map[uuid.UUID]bool {
+	apps := make(map[uuid.UUID]bool)
+	if h.collaborators == nil || user.IsAdmin() {
+		return apps
+	}
+	collaborators, err := h.collaborators.ListForUser(ctx, user.ID)
+	if err != nil {
+		h.logger.Warn("Failed to look up shared apps", zap.String("user_id", user.ID.String()), zap.Error(err))
+		return apps
+	}
+	for _, c := range collaborators {
+		apps[c.AppID] = true
+	}
+	return apps
+}
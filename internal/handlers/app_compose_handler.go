@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/services/compose"
+)
+
+// Note: AppHandler and NewAppHandler are defined in app_handler.go
+// This file adds docker-compose import to the existing AppHandler
+
+// maxComposeFileSize bounds an uploaded compose file
+const maxComposeFileSize = 1 << 20
+
+// ComposeImportRequest represents a request to import a docker-compose file
+type ComposeImportRequest struct {
+	Compose   string            `json:"compose"`
+	Stack     string            `json:"stack,omitempty"`     // group the apps as <stack>-<service>
+	Variables map[string]string `json:"variables,omitempty"` // values for ${VAR} interpolation
+	Deploy    bool              `json:"deploy"`
+	DryRun    bool              `json:"dry_run,omitempty"`
+}
+
+// ComposeServiceResult describes the app created for one compose service
+type ComposeServiceResult struct {
+	Service      string       `json:"service"`
+	App          *AppResponse `json:"app,omitempty"`
+	Public       bool         `json:"public"`
+	DependsOn    []string     `json:"depends_on,omitempty"`
+	Deployed     bool         `json:"deployed"`
+	DeploymentID string       `json:"deployment_id,omitempty"`
+	URL          string       `json:"url,omitempty"`
+	Error        string       `json:"error,omitempty"`
+	Warnings     []string     `json:"warnings,omitempty"`
+}
+
+// ComposeImportResponse is the result of a compose import
+type ComposeImportResponse struct {
+	Stack    string                 `json:"stack,omitempty"`
+	DryRun   bool                   `json:"dry_run,omitempty"`
+	Services []ComposeServiceResult `json:"services"`
+	Skipped  []compose.Skipped      `json:"skipped,omitempty"`
+	Warnings []string               `json:"warnings,omitempty"`
+}
+
+// ImportCompose creates one app per docker-compose service and optionally deploys them
+// in dependency order. The body is either JSON (ComposeImportRequest) or the raw YAML
+// file, in which case stack, deploy and dry_run are read from the query string.
+func (h *AppHandler) ImportCompose(w http.ResponseWriter, r *http.Request) {
+	req, err := decodeComposeImportRequest(w, r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if strings.TrimSpace(req.Compose) == "" {
+		writeError(w, http.StatusBadRequest, "compose file is required")
+		return
+	}
+
+	file, missing, err := compose.Parse([]byte(req.Compose), req.Variables)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Not authenticated")
+		return
+	}
+	plan, err := compose.BuildPlan(file, compose.ImportOptions{
+		Stack:                req.Stack,
+		OwnerID:              user.ID,
+		AllowExternalVolumes: user.IsAdmin(),
+	})
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response := ComposeImportResponse{Stack: plan.Stack, DryRun: req.DryRun, Skipped: plan.Skipped}
+	for _, name := range missing {
+		response.Warnings = append(response.Warnings, "variable "+name+" is not set and defaulted to an empty string")
+	}
+
+	// Refuse the whole import rather than leave a half-created stack behind
+	for _, sp := range plan.Services {
+		if err := h.router.ValidateSubdomain(sp.App.Subdomain); err != nil {
+			writeError(w, http.StatusBadRequest, "Service "+sp.Service+": "+err.Error())
+			return
+		}
+	}
+	existing := make(map[string]bool)
+	for _, app := range h.ListApps() {
+		existing[app.Slug] = true
+	}
+	var conflicts []string
+	for _, sp := range plan.Services {
+		if existing[sp.App.Slug] {
+			conflicts = append(conflicts, sp.App.Slug)
+		}
+	}
+	if len(conflicts) > 0 {
+		writeErrorDetails(w, http.StatusConflict, "Apps with these slugs already exist; import under a different stack name",
+			map[string]interface{}{"slugs": conflicts})
+		return
+	}
+	response.Warnings = append(response.Warnings, h.aliasCollisions(plan)...)
+
+	if !req.DryRun && h.appStore != nil {
+		// All of the stack's apps are stored or none are
+		var failed string
+		err := inTx(r.Context(), h.tx, func(ctx context.Context) error {
+			for _, sp := range plan.Services {
+				if err := h.appStore.Create(ctx, sp.App); err != nil {
+					failed = sp.Service
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			h.logger.Error("Failed to persist compose app", zap.String("service", failed), zap.Error(err))
+			writeDomainError(w, err, "Failed to create app for service "+failed)
+			return
+		}
+	}
+
+	for _, sp := range plan.Services {
+		result := ComposeServiceResult{
+			Service:   sp.Service,
+			Public:    sp.Public,
+			DependsOn: sp.DependsOn,
+			Warnings:  sp.Warnings,
+		}
+		if !req.DryRun {
+			h.cacheApp(sp.App)
+		}
+		appResponse := h.appToResponse(sp.App)
+		result.App = &appResponse
+		response.Services = append(response.Services, result)
+	}
+
+	if req.DryRun {
+		writeJSON(w, http.StatusOK, response)
+		return
+	}
+
+	h.logger.Info("Compose file imported",
+		zap.String("stack", plan.Stack),
+		zap.Int("apps", len(plan.Services)),
+		zap.Int("skipped", len(plan.Skipped)),
+	)
+
+	if req.Deploy {
+		h.deployComposePlan(r, plan, response.Services)
+	}
+
+	writeJSON(w, http.StatusCreated, response)
+}
+
+// deployComposePlan deploys imported apps in dependency order, skipping apps whose
+// dependencies failed to deploy
+func (h *AppHandler) deployComposePlan(r *http.Request, plan *compose.Plan, results []ComposeServiceResult) {
+	failed := make(map[string]bool)
+	for i, sp := range plan.Services {
+		result := &results[i]
+
+		var blockedBy []string
+		for _, dep := range sp.DependsOn {
+			if failed[dep] {
+				blockedBy = append(blockedBy, dep)
+			}
+		}
+		if len(blockedBy) > 0 {
+			failed[sp.Service] = true
+			result.Error = "not deployed: dependency " + strings.Join(blockedBy, ", ") + " failed"
+			continue
+		}
+
+		app := sp.App
+		deployment, err := h.orchestrator.Deploy(r.Context(), app)
+		if err != nil {
+			failed[sp.Service] = true
+			result.Error = "Deployment failed: " + err.Error()
+			h.logger.Warn("Compose service deployment failed",
+				zap.String("service", sp.Service),
+				zap.String("app_id", app.ID.String()),
+				zap.Error(err),
+			)
+		} else {
+			result.Deployed = true
+			result.DeploymentID = deployment.ID.String()
+			if sp.Public {
+				h.router.AddRoute(r.Context(), app, h.appReplicas(r.Context(), app))
+				result.URL = h.router.GetAppURL(app)
+			}
+		}
+
+		h.saveApp(r.Context(), app)
+
+		appResponse := h.appToResponse(app)
+		result.App = &appResponse
+	}
+}
+
+// aliasCollisions warns when a service's network alias is already used by another app,
+// since DNS on the shared network would then round-robin between them
+func (h *AppHandler) aliasCollisions(plan *compose.Plan) []string {
+	owners := make(map[string]string)
+	for _, app := range h.ListApps() {
+		for _, alias := range app.NetworkAliases {
+			owners[alias] = app.Slug
+		}
+	}
+
+	var warnings []string
+	for _, sp := range plan.Services {
+		for _, alias := range sp.App.NetworkAliases {
+			if owner, taken := owners[alias]; taken {
+				warnings = append(warnings, "hostname "+alias+" is also used by app "+owner+"; lookups may reach either app")
+			}
+		}
+	}
+	sort.Strings(warnings)
+	return warnings
+}
+
+// decodeComposeImportRequest reads a JSON import request or a raw YAML compose file
+func decodeComposeImportRequest(w http.ResponseWriter, r *http.Request) (*ComposeImportRequest, error) {
+	body := http.MaxBytesReader(w, r.Body, maxComposeFileSize)
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/json" || mediaType == "" {
+		var req ComposeImportRequest
+		if err := json.NewDecoder(body).Decode(&req); err != nil {
+			return nil, fmt.Errorf("Invalid request body")
+		}
+		return &req, nil
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid request body")
+	}
+	query := r.URL.Query()
+	return &ComposeImportRequest{
+		Compose: string(data),
+		Stack:   query.Get("stack"),
+		Deploy:  query.Get("deploy") == "true",
+		DryRun:  query.Get("dry_run") == "true",
+	}, nil
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// Note: AppHandler and NewAppHandler are defined in app_handler.go
+// This file adds the effective runtime configuration endpoint to the existing AppHandler
+
+// GetEffectiveConfig returns the app's resolved runtime configuration: merged env,
+// resource limits, image, health check and route, and what each running container was
+// actually started with. Containers that no longer match the app list their drift.
+func (h *AppHandler) GetEffectiveConfig(w http.ResponseWriter, r *http.Request) {
+	app, err := h.getApp(chi.URLParam(r, "appId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	cfg := h.orchestrator.EffectiveConfig(r.Context(), app)
+	if app.ExposedPort > 0 {
+		cfg.Routing = h.effectiveRouting(app)
+	}
+	writeJSON(w, http.StatusOK, cfg)
+}
+
+// effectiveRouting describes the app's live route and whether it matches the app's settings
+func (h *AppHandler) effectiveRouting(app *domain.App) *domain.EffectiveRouting {
+	routing := &domain.EffectiveRouting{
+		URL:      h.router.GetAppURL(app),
+		Port:     app.ExposedPort,
+		Replicas: []string{},
+	}
+	if route, ok := h.router.GetRoute(app.ID); ok {
+		routing.Middleware = route.Middleware
+		for _, replica := range route.Replicas {
+			routing.Replicas = append(routing.Replicas, fmt.Sprintf("%s:%d", replica.IPAddress, replica.Port))
+		}
+	} else {
+		routing.Error = "App has no active route"
+	}
+
+	preview, err := h.router.PreviewRoute(app)
+	if err != nil {
+		h.logger.Warn("Failed to render route preview", zap.Error(err), zap.String("app_id", app.ID.String()))
+		routing.Error = "Failed to render route preview"
+		return routing
+	}
+	routing.Backend = preview.Backend
+	routing.Hosts = preview.Hosts
+	routing.Applied = preview.Applied
+	if !preview.Valid {
+		routing.Error = preview.Error
+	}
+	return routing
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// Note: AppHandler and NewAppHandler are defined in app_handler.go
+// This file adds per-app CORS policy routes to the existing AppHandler
+
+// GetCORS returns an app's CORS policy
+func (h *AppHandler) GetCORS(w http.ResponseWriter, r *http.Request) {
+	app, err := h.getApp(chi.URLParam(r, "appId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	if app.CORS == nil {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"enabled": false,
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"enabled": true,
+		"policy":  app.CORS,
+	})
+}
+
+// SetCORS replaces an app's CORS policy and applies it to the live route
+func (h *AppHandler) SetCORS(w http.ResponseWriter, r *http.Request) {
+	app, err := h.getApp(chi.URLParam(r, "appId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	var policy domain.CORSPolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	policy.Normalize()
+	if err := policy.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.applyCORS(r, app, &policy); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to update route")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"enabled": true,
+		"policy":  app.CORS,
+	})
+}
+
+// DeleteCORS removes an app's CORS policy
+func (h *AppHandler) DeleteCORS(w http.ResponseWriter, r *http.Request) {
+	app, err := h.getApp(chi.URLParam(r, "appId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	if err := h.applyCORS(r, app, nil); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to update route")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"enabled": false,
+	})
+}
+
+// applyCORS stores the policy on the app and updates its route if one is active
+func (h *AppHandler) applyCORS(r *http.Request, app *domain.App, policy *domain.CORSPolicy) error {
+	if _, routed := h.router.GetRoute(app.ID); routed {
+		if err := h.router.SetCORS(r.Context(), app.ID, policy); err != nil {
+			h.logger.Error("Failed to apply CORS policy", zap.Error(err), zap.String("app_id", app.ID.String()))
+			return err
+		}
+	}
+
+	app.CORS = policy
+	app.UpdatedAt = time.Now().UTC()
+	h.saveApp(r.Context(), app)
+
+	h.logger.Info("App CORS policy updated",
+		zap.String("app_id", app.ID.String()),
+		zap.Bool("enabled", policy != nil),
+	)
+	return nil
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/nanopaas/nanopaas/internal/services/cost"
+)
+
+// Note: AppHandler and NewAppHandler are defined in app_handler.go
+// This file adds cost estimation routes to the existing AppHandler
+
+// SetCostEstimator sets the estimator used for cost estimate endpoints
+func (h *AppHandler) SetCostEstimator(estimator *cost.Estimator) {
+	h.costEstimator = estimator
+}
+
+// CostEstimate returns the estimated monthly cost of an app
+func (h *AppHandler) CostEstimate(w http.ResponseWriter, r *http.Request) {
+	if h.costEstimator == nil {
+		writeError(w, http.StatusServiceUnavailable, "Cost estimation is not configured")
+		return
+	}
+
+	app, err := h.getApp(chi.URLParam(r, "appId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, h.costEstimator.EstimateApp(app))
+}
+
+// TeamCostEstimate returns the aggregated monthly cost of a team's apps
+func (h *AppHandler) TeamCostEstimate(w http.ResponseWriter, r *http.Request) {
+	if h.costEstimator == nil {
+		writeError(w, http.StatusServiceUnavailable, "Cost estimation is not configured")
+		return
+	}
+
+	teamID, err := uuid.Parse(chi.URLParam(r, "teamId"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid team ID")
+		return
+	}
+	if user := GetUserFromContext(r.Context()); user == nil || (!user.IsAdmin() && !h.userTeams(r.Context(), user)[teamID]) {
+		writeError(w, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, h.costEstimator.EstimateTeam(teamID, h.ListApps()))
+}
@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+	"github.com/nanopaas/nanopaas/internal/services/auth"
+)
+
+// Note: AppHandler and NewAppHandler are defined in app_handler.go
+// This file adds per-app deploy tokens for external CI to the existing AppHandler
+
+const deployTokenContextKey contextKey = "deploy_token"
+
+// Deploy tokens expire after defaultDeployTokenDays unless asked otherwise, and never
+// live longer than maxDeployTokenDays
+const (
+	defaultDeployTokenDays = 30
+	maxDeployTokenDays     = 90
+)
+
+// DeployTokenIssuer creates, lists and revokes deploy tokens
+type DeployTokenIssuer interface {
+	CreateDeployToken(ctx context.Context, user *domain.User, appID uuid.UUID, name string, expiresAt time.Time) (*domain.DeployToken, string, error)
+	ListDeployTokens(ctx context.Context, appID uuid.UUID) ([]*domain.DeployToken, error)
+	RevokeDeployToken(ctx context.Context, user *domain.User, appID, id uuid.UUID) error
+}
+
+// CreateDeployTokenRequest represents a request to create a deploy token
+type CreateDeployTokenRequest struct {
+	Name      string `json:"name"`
+	ExpiresIn int    `json:"expires_in,omitempty"` // days, 30 by default and at most 90
+}
+
+// CreateDeployTokenResponse returns a new deploy token. Token is shown only once.
+type CreateDeployTokenResponse struct {
+	*domain.DeployToken
+	Token string `json:"token"`
+}
+
+// SetDeployTokenIssuer enables deploy tokens
+func (h *AppHandler) SetDeployTokenIssuer(issuer DeployTokenIssuer) {
+	h.deployTokens = issuer
+}
+
+// ListDeployTokens returns an app's deploy tokens, without the tokens themselves
+func (h *AppHandler) ListDeployTokens(w http.ResponseWriter, r *http.Request) {
+	app, _, ok := h.requireDeployTokenAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	tokens, err := h.deployTokens.ListDeployTokens(r.Context(), app.ID)
+	if err != nil {
+		h.logger.Error("Failed to list deploy tokens", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "Failed to list deploy tokens")
+		return
+	}
+	writeJSON(w, http.StatusOK, tokens)
+}
+
+// CreateDeployToken creates a token that can only build and deploy this app, acting as
+// the requesting user
+func (h *AppHandler) CreateDeployToken(w http.ResponseWriter, r *http.Request) {
+	app, user, ok := h.requireDeployTokenAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	var req CreateDeployTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, "Token name is required")
+		return
+	}
+	if req.ExpiresIn < 0 || req.ExpiresIn > maxDeployTokenDays {
+		writeError(w, http.StatusBadRequest, "expires_in must be between 1 and 90 days")
+		return
+	}
+	if req.ExpiresIn == 0 {
+		req.ExpiresIn = defaultDeployTokenDays
+	}
+
+	expiresAt := time.Now().UTC().AddDate(0, 0, req.ExpiresIn)
+	t, token, err := h.deployTokens.CreateDeployToken(r.Context(), user, app.ID, req.Name, expiresAt)
+	if err != nil {
+		if errors.Is(err, auth.ErrDeployTokensDisabled) {
+			writeError(w, http.StatusServiceUnavailable, "Deploy tokens are not enabled")
+			return
+		}
+		h.logger.Error("Failed to create deploy token", zap.Error(err))
+		writeDomainError(w, err, "Failed to create deploy token")
+		return
+	}
+	writeJSON(w, http.StatusCreated, CreateDeployTokenResponse{DeployToken: t, Token: token})
+}
+
+// RevokeDeployToken deletes one of an app's deploy tokens
+func (h *AppHandler) RevokeDeployToken(w http.ResponseWriter, r *http.Request) {
+	app, user, ok := h.requireDeployTokenAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := uuid.Parse(chi.URLParam(r, "tokenId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Deploy token not found")
+		return
+	}
+	if err := h.deployTokens.RevokeDeployToken(r.Context(), user, app.ID, id); err != nil {
+		if errors.Is(err, auth.ErrDeployTokensDisabled) {
+			writeError(w, http.StatusServiceUnavailable, "Deploy tokens are not enabled")
+			return
+		}
+		writeDomainError(w, err, "Failed to revoke deploy token")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"message": "Deploy token revoked",
+	})
+}
+
+// requireDeployTokenAdmin resolves an app whose deploy tokens the signed-in user may
+// manage: the owner, admins and team members, but not collaborators
+func (h *AppHandler) requireDeployTokenAdmin(w http.ResponseWriter, r *http.Request) (*domain.App, *domain.User, bool) {
+	if h.deployTokens == nil {
+		writeError(w, http.StatusServiceUnavailable, "Deploy tokens are not enabled")
+		return nil, nil, false
+	}
+	user, ok := requireSessionUser(w, r)
+	if !ok {
+		return nil, nil, false
+	}
+	app, ok := h.requireAppManager(w, r, "Only the app owner, its team and admins can manage deploy tokens")
+	if !ok {
+		return nil, nil, false
+	}
+	return app, user, true
+}
+
+// serveWithDeployToken authenticates a request made with a deploy token and lets it
+// through only for the token's app
+func serveWithDeployToken(w http.ResponseWriter, r *http.Request, next http.Handler, authService *auth.Service, token string) {
+	user, t, err := authService.AuthenticateDeployToken(r.Context(), token)
+	if errors.Is(err, auth.ErrUserDeactivated) {
+		writeError(w, http.StatusForbidden, "The user who created this deploy token has been deactivated")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "Invalid or expired deploy token")
+		return
+	}
+	if !deployTokenAllows(t, r) {
+		writeError(w, http.StatusForbidden, "Deploy tokens can only build and deploy their app")
+		return
+	}
+	authService.AuditDeployTokenRequest(t, r.Method, r.URL.Path)
+
+	ctx := SetUserInContext(r.Context(), user)
+	ctx = context.WithValue(ctx, deployTokenContextKey, t)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// deployTokenAllows checks a request against a deploy token: it may deploy, start and
+// cancel builds of its app, and read the app's builds and deployments to follow them
+func deployTokenAllows(t *domain.DeployToken, r *http.Request) bool {
+	path := strings.TrimSuffix(r.URL.Path, "/")
+	prefix := "/apps/" + t.AppID.String()
+	i := strings.Index(path, prefix+"/")
+	if i < 0 {
+		return false
+	}
+	rest := path[i+len(prefix):]
+
+	switch r.Method {
+	case http.MethodPost:
+		switch rest {
+		case "/deploy", "/builds", "/builds/git":
+			return true
+		}
+		return strings.HasPrefix(rest, "/builds/") && strings.HasSuffix(rest, "/cancel")
+	case http.MethodGet, http.MethodHead:
+		return rest == "/builds" || strings.HasPrefix(rest, "/builds/") || rest == "/deployments"
+	}
+	return false
+}
+
+// GetDeployTokenFromContext returns the deploy token a request was authenticated with,
+// or nil for other requests
+func GetDeployTokenFromContext(ctx context.Context) *domain.DeployToken {
+	t, ok := ctx.Value(deployTokenContextKey).(*domain.DeployToken)
+	if !ok {
+		return nil
+	}
+	return t
+}
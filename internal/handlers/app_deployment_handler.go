@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// deploymentSorts compares deployments by each sortable key of the deployment list
+var deploymentSorts = map[string]func(a, b *domain.Deployment) int{
+	"status":     func(a, b *domain.Deployment) int { return strings.Compare(string(a.Status), string(b.Status)) },
+	"created_at": func(a, b *domain.Deployment) int { return a.CreatedAt.Compare(b.CreatedAt) },
+}
+
+// ListDeployments returns an app's deployments, filtered by status and image (name=)
+func (h *AppHandler) ListDeployments(w http.ResponseWriter, r *http.Request) {
+	app, err := h.getApp(chi.URLParam(r, "appId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "App not found")
+		return
+	}
+	params, err := parseListParams(r, []string{"status", "created_at"}, "-created_at")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	matched := make([]*domain.Deployment, 0)
+	for _, d := range h.orchestrator.AppDeployments(app.ID) {
+		if params.Status != "" && string(d.Status) != params.Status {
+			continue
+		}
+		if params.matchesName(d.ImageID) {
+			matched = append(matched, d)
+		}
+	}
+
+	page, total := sortAndPage(matched, params, deploymentSorts)
+	writeList(w, r, page, total, params)
+}
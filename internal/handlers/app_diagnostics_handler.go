@@ -0,0 +1,301 @@
+package handlers
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+	"github.com/nanopaas/nanopaas/internal/services/builder"
+	"github.com/nanopaas/nanopaas/internal/services/orchestrator"
+	"github.com/nanopaas/nanopaas/internal/version"
+)
+
+// Note: AppHandler and NewAppHandler are defined in app_handler.go
+// This file adds diagnostic bundle export to the existing AppHandler
+
+// Diagnostic bundle limits
+const (
+	defaultBundleHistory = 5
+	maxBundleHistory     = 20
+	maxBundleLogLines    = 5000
+	maxBundleEvents      = 200
+)
+
+// redactedValue replaces secret values in diagnostic output
+const redactedValue = "[redacted]"
+
+// BuildLogSource provides the retained logs of an app's recent builds
+type BuildLogSource interface {
+	RecentBuildLogs(appID uuid.UUID) []builder.BuildLog
+}
+
+// BundleManifest describes the contents of a diagnostic bundle
+type BundleManifest struct {
+	GeneratedAt time.Time `json:"generated_at"`
+	AppID       string    `json:"app_id"`
+	AppSlug     string    `json:"app_slug"`
+	Files       []string  `json:"files"`
+	Errors      []string  `json:"errors,omitempty"`
+}
+
+// BundleEvent is one entry in a diagnostic bundle's event history
+type BundleEvent struct {
+	At      time.Time `json:"at"`
+	Source  string    `json:"source"` // deployment, build, container or incident
+	Message string    `json:"message"`
+}
+
+// SetBuildLogSource sets where diagnostic bundles read build logs from
+func (h *AppHandler) SetBuildLogSource(src BuildLogSource) {
+	h.buildLogs = src
+}
+
+// DiagnosticsBundle returns a tar.gz with everything needed for a bug report: the app's
+// config with secrets redacted, recent build and deployment logs, container inspect output
+// and logs, recent events and platform version info. The lines query parameter sets how
+// many log lines are kept per container; history sets how many builds and deployments.
+func (h *AppHandler) DiagnosticsBundle(w http.ResponseWriter, r *http.Request) {
+	app, err := h.getApp(chi.URLParam(r, "appId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	lines, err := boundedQueryInt(r, "lines", orchestrator.DefaultDiagnosticLogLines, maxBundleLogLines)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	history, err := boundedQueryInt(r, "history", defaultBundleHistory, maxBundleHistory)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	bundle := newDiagnosticBundle()
+	now := time.Now().UTC()
+
+	config := h.appToResponse(app)
+	config.EnvVars = redactEnv(app.EnvVars)
+	bundle.addJSON("app.json", config)
+
+	bundle.addJSON("version.json", map[string]interface{}{
+		"nanopaas": version.Get(),
+		"runtime":  h.orchestrator.RuntimeInfo(r.Context()),
+	})
+
+	deployments := h.orchestrator.AppDeployments(app.ID)
+	if len(deployments) > history {
+		deployments = deployments[:history]
+	}
+	bundle.addJSON("deployments.json", deployments)
+
+	var builds []builder.BuildLog
+	if h.buildLogs != nil {
+		builds = h.buildLogs.RecentBuildLogs(app.ID)
+		if len(builds) > history {
+			builds = builds[:history]
+		}
+		for _, b := range builds {
+			bundle.addText("builds/"+b.BuildID.String()+".log", strings.Join(b.Lines, "\n"))
+		}
+	}
+	bundle.addJSON("builds.json", builds)
+
+	snapshot := h.orchestrator.Diagnostics(r.Context(), app, lines)
+	bundle.errors = append(bundle.errors, snapshot.Errors...)
+	for _, c := range snapshot.Containers {
+		dir := "containers/" + c.ContainerID + "/"
+		if len(c.Inspect) > 0 {
+			bundle.addJSON(dir+"inspect.json", c.Inspect)
+		}
+		bundle.addText(dir+"logs.txt", strings.Join(c.Logs, "\n"))
+		if c.Stats != nil {
+			bundle.addJSON(dir+"stats.json", c.Stats)
+		}
+	}
+
+	bundle.addJSON("events.json", h.bundleEvents(app, deployments, builds, snapshot))
+
+	manifest := BundleManifest{
+		GeneratedAt: now,
+		AppID:       app.ID.String(),
+		AppSlug:     app.Slug,
+		Files:       bundle.names(),
+		Errors:      bundle.errors,
+	}
+	bundle.addJSON("manifest.json", manifest)
+
+	filename := fmt.Sprintf("%s-diagnostics-%s.tar.gz", app.Slug, now.Format("20060102-150405"))
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.WriteHeader(http.StatusOK)
+	if err := bundle.write(w, now); err != nil {
+		h.logger.Warn("Failed to write diagnostic bundle", zap.String("app_id", app.ID.String()), zap.Error(err))
+		return
+	}
+
+	h.logger.Info("Diagnostic bundle exported",
+		zap.String("app_id", app.ID.String()),
+		zap.Int("files", len(bundle.files)),
+	)
+}
+
+// bundleEvents merges deployments, builds, container exits and incident timelines
+// into one history, newest first
+func (h *AppHandler) bundleEvents(app *domain.App, deployments []*domain.Deployment, builds []builder.BuildLog, snapshot *domain.DiagnosticSnapshot) []BundleEvent {
+	var events []BundleEvent
+	for _, d := range deployments {
+		events = append(events, BundleEvent{At: d.CreatedAt, Source: "deployment",
+			Message: fmt.Sprintf("Deployment %s of %s created", d.ID, d.ImageID)})
+		if d.CompletedAt != nil {
+			message := fmt.Sprintf("Deployment %s %s", d.ID, d.Status)
+			if d.ErrorMessage != "" {
+				message += ": " + d.ErrorMessage
+			}
+			if d.RollbackReason != "" {
+				message += " (rollback: " + d.RollbackReason + ")"
+			}
+			events = append(events, BundleEvent{At: *d.CompletedAt, Source: "deployment", Message: message})
+		}
+	}
+	for _, b := range builds {
+		events = append(events, BundleEvent{At: b.StartedAt, Source: "build",
+			Message: fmt.Sprintf("Build %s started", b.BuildID)})
+		if b.FinishedAt != nil {
+			message := fmt.Sprintf("Build %s %s", b.BuildID, b.Status)
+			if b.Error != "" {
+				message += ": " + b.Error
+			}
+			events = append(events, BundleEvent{At: *b.FinishedAt, Source: "build", Message: message})
+		}
+	}
+
+	exits := make(map[string]domain.ContainerExit)
+	if app.LastExit != nil {
+		exits[app.LastExit.ContainerID+app.LastExit.FinishedAt.String()] = *app.LastExit
+	}
+	for _, c := range snapshot.Containers {
+		if c.Exit != nil {
+			exits[c.Exit.ContainerID+c.Exit.FinishedAt.String()] = *c.Exit
+		}
+	}
+	for _, exit := range exits {
+		message := fmt.Sprintf("Container %s exited with code %d", exit.ContainerID, exit.ExitCode)
+		if exit.OOMKilled {
+			message += " (OOM killed)"
+		}
+		events = append(events, BundleEvent{At: exit.FinishedAt, Source: "container", Message: message})
+	}
+
+	for _, inc := range h.incidents {
+		if inc.AppID != app.ID {
+			continue
+		}
+		for _, e := range inc.Timeline {
+			events = append(events, BundleEvent{At: e.At, Source: "incident", Message: inc.Title + ": " + e.Message})
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool { return events[i].At.After(events[j].At) })
+	if len(events) > maxBundleEvents {
+		events = events[:maxBundleEvents]
+	}
+	return events
+}
+
+// redactEnv keeps environment variable names and hides their values
+func redactEnv(env map[string]string) map[string]string {
+	redacted := make(map[string]string, len(env))
+	for k := range env {
+		redacted[k] = redactedValue
+	}
+	return redacted
+}
+
+// boundedQueryInt parses an optional positive integer query parameter
+func boundedQueryInt(r *http.Request, name string, def, max int) (int, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1 || n > max {
+		return 0, fmt.Errorf("%s must be between 1 and %d", name, max)
+	}
+	return n, nil
+}
+
+// diagnosticBundle collects files in memory so failures surface before the response starts
+type diagnosticBundle struct {
+	files  []bundleFile
+	errors []string
+}
+
+type bundleFile struct {
+	name string
+	data []byte
+}
+
+func newDiagnosticBundle() *diagnosticBundle {
+	return &diagnosticBundle{}
+}
+
+func (b *diagnosticBundle) addJSON(name string, v interface{}) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		b.errors = append(b.errors, fmt.Sprintf("%s: %v", name, err))
+		return
+	}
+	b.files = append(b.files, bundleFile{name: name, data: append(data, '\n')})
+}
+
+func (b *diagnosticBundle) addText(name, text string) {
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	b.files = append(b.files, bundleFile{name: name, data: []byte(text)})
+}
+
+func (b *diagnosticBundle) names() []string {
+	names := make([]string, 0, len(b.files)+1)
+	for _, f := range b.files {
+		names = append(names, f.name)
+	}
+	return append(names, "manifest.json")
+}
+
+// write streams the bundle as a gzipped tarball under a single top-level directory
+func (b *diagnosticBundle) write(w http.ResponseWriter, modTime time.Time) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, f := range b.files {
+		header := &tar.Header{
+			Name:    "diagnostics/" + f.name,
+			Mode:    0644,
+			Size:    int64(len(f.data)),
+			ModTime: modTime,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
@@ -0,0 +1,332 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// Note: AppHandler and NewAppHandler are defined in app_handler.go
+// This file adds custom domains to the existing AppHandler
+
+// domainLookupTimeout bounds the DNS lookups of a verification attempt
+const domainLookupTimeout = 10 * time.Second
+
+// CustomDomainStore persists custom domain records
+type CustomDomainStore interface {
+	Create(ctx context.Context, d *domain.CustomDomain) error
+	Update(ctx context.Context, d *domain.CustomDomain) error
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+// AddDomainRequest represents a request to attach a custom domain
+type AddDomainRequest struct {
+	Hostname string `json:"hostname"`
+}
+
+// CustomDomainResponse is a custom domain with the DNS records needed to verify it
+type CustomDomainResponse struct {
+	*domain.CustomDomain
+	CNAMETarget string `json:"cname_target"` // point the domain here to route it to the app
+}
+
+// SetCustomDomainStore sets the store custom domains are persisted to
+func (h *AppHandler) SetCustomDomainStore(store CustomDomainStore) {
+	h.domainStore = store
+}
+
+// ListDomains returns an app's custom domains
+func (h *AppHandler) ListDomains(w http.ResponseWriter, r *http.Request) {
+	app, err := h.getApp(chi.URLParam(r, "appId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	domains := make([]CustomDomainResponse, 0)
+	for _, d := range h.appDomains(app.ID) {
+		domains = append(domains, h.domainResponse(app, d))
+	}
+	sort.Slice(domains, func(i, j int) bool {
+		return domains[i].CreatedAt.Before(domains[j].CreatedAt)
+	})
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"domains": domains,
+		"total":   len(domains),
+	})
+}
+
+// AddDomain attaches a custom domain to an app. The domain is routed once verified. Only
+// a verified domain is exclusive to its app: until then, other apps may claim it too, so
+// a claim left unverified can't keep the domain's owner from adding it.
+func (h *AppHandler) AddDomain(w http.ResponseWriter, r *http.Request) {
+	app, err := h.getApp(chi.URLParam(r, "appId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	var req AddDomainRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	hostname := domain.NormalizeHostname(req.Hostname)
+	if err := domain.ValidateHostname(hostname); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if platform := h.router.PlatformDomain(); hostname == platform || strings.HasSuffix(hostname, "."+platform) {
+		writeError(w, http.StatusBadRequest, "Subdomains of "+platform+" are assigned by NanoPaaS and cannot be added as custom domains")
+		return
+	}
+	if message := h.domainClaimConflict(app.ID, hostname); message != "" {
+		writeError(w, http.StatusConflict, message)
+		return
+	}
+
+	d := domain.NewCustomDomain(app.ID, hostname)
+	if h.domainStore != nil {
+		if err := h.domainStore.Create(r.Context(), d); err != nil {
+			writeDomainError(w, err, "Failed to save domain")
+			return
+		}
+	}
+	h.mu.Lock()
+	h.customDomains[d.ID] = d
+	h.mu.Unlock()
+
+	h.logger.Info("Custom domain added",
+		zap.String("app_id", app.ID.String()),
+		zap.String("hostname", hostname),
+	)
+
+	writeJSON(w, http.StatusCreated, h.domainResponse(app, d))
+}
+
+// VerifyDomain checks the domain's DNS records and starts routing it on success
+func (h *AppHandler) VerifyDomain(w http.ResponseWriter, r *http.Request) {
+	app, d, ok := h.getCustomDomain(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), domainLookupTimeout)
+	defer cancel()
+
+	if err := verifyDomainOwnership(ctx, d, h.router.AppHost(app)); err != nil {
+		d.MarkFailed(err.Error())
+		h.updateCustomDomain(d)
+		h.saveCustomDomain(r.Context(), d)
+		writeJSON(w, http.StatusUnprocessableEntity, h.domainResponse(app, d))
+		return
+	}
+
+	if h.verifiedElsewhere(d) {
+		writeError(w, http.StatusConflict, "Domain is already verified for another app")
+		return
+	}
+	d.MarkVerified()
+	// The store has the last word when two apps verify the same domain at once
+	if h.domainStore != nil {
+		if err := h.domainStore.Update(r.Context(), d); err != nil {
+			if errors.Is(err, domain.ErrConflict) {
+				writeError(w, http.StatusConflict, "Domain is already verified for another app")
+				return
+			}
+			writeDomainError(w, err, "Failed to save domain")
+			return
+		}
+	}
+	h.updateCustomDomain(d)
+
+	// Routing the new host also requests its certificate when HTTPS is managed
+	app.AddCustomDomain(d.Hostname)
+	h.saveApp(r.Context(), app)
+	if err := h.reapplyRoute(r.Context(), app); err != nil {
+		h.logger.Warn("Failed to route custom domain", zap.String("hostname", d.Hostname), zap.Error(err))
+	}
+
+	h.logger.Info("Custom domain verified",
+		zap.String("app_id", app.ID.String()),
+		zap.String("hostname", d.Hostname),
+	)
+
+	writeJSON(w, http.StatusOK, h.domainResponse(app, d))
+}
+
+// DeleteDomain detaches a custom domain and stops routing it
+func (h *AppHandler) DeleteDomain(w http.ResponseWriter, r *http.Request) {
+	app, d, ok := h.getCustomDomain(w, r)
+	if !ok {
+		return
+	}
+
+	if h.domainStore != nil {
+		if err := h.domainStore.Delete(r.Context(), d.ID); err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to delete domain: "+err.Error())
+			return
+		}
+	}
+	h.mu.Lock()
+	delete(h.customDomains, d.ID)
+	h.mu.Unlock()
+
+	if d.IsVerified() {
+		app.RemoveCustomDomain(d.Hostname)
+		h.saveApp(r.Context(), app)
+		if err := h.reapplyRoute(r.Context(), app); err != nil {
+			h.logger.Warn("Failed to unroute custom domain", zap.String("hostname", d.Hostname), zap.Error(err))
+		}
+	}
+
+	h.logger.Info("Custom domain removed",
+		zap.String("app_id", app.ID.String()),
+		zap.String("hostname", d.Hostname),
+	)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// verifyDomainOwnership accepts either the verification TXT record or a CNAME to the app's host
+func verifyDomainOwnership(ctx context.Context, d *domain.CustomDomain, appHost string) error {
+	records, txtErr := net.DefaultResolver.LookupTXT(ctx, d.TXTRecord)
+	for _, record := range records {
+		if strings.TrimSpace(record) == d.Token {
+			return nil
+		}
+	}
+
+	cname, cnameErr := net.DefaultResolver.LookupCNAME(ctx, d.Hostname)
+	if cnameErr == nil && domain.NormalizeHostname(cname) == appHost {
+		return nil
+	}
+
+	switch {
+	case txtErr == nil && len(records) > 0:
+		return fmt.Errorf("TXT record %s does not contain the verification token", d.TXTRecord)
+	case cnameErr == nil && domain.NormalizeHostname(cname) != d.Hostname:
+		return fmt.Errorf("%s is a CNAME for %s, not %s", d.Hostname, domain.NormalizeHostname(cname), appHost)
+	}
+	return fmt.Errorf("no TXT record at %s and no CNAME to %s found", d.TXTRecord, appHost)
+}
+
+// getCustomDomain resolves the domain in the URL, writing a 404 if it doesn't belong to
+// the app. The domain is a copy: changes are kept with updateCustomDomain.
+func (h *AppHandler) getCustomDomain(w http.ResponseWriter, r *http.Request) (*domain.App, *domain.CustomDomain, bool) {
+	app, err := h.getApp(chi.URLParam(r, "appId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "App not found")
+		return nil, nil, false
+	}
+	id, err := uuid.Parse(chi.URLParam(r, "domainId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Domain not found")
+		return nil, nil, false
+	}
+	h.mu.RLock()
+	d, exists := h.customDomains[id]
+	h.mu.RUnlock()
+	if !exists || d.AppID != app.ID {
+		writeError(w, http.StatusNotFound, "Domain not found")
+		return nil, nil, false
+	}
+	copied := *d
+	return app, &copied, true
+}
+
+// appDomains returns copies of an app's custom domains
+func (h *AppHandler) appDomains(appID uuid.UUID) []*domain.CustomDomain {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	domains := make([]*domain.CustomDomain, 0)
+	for _, d := range h.customDomains {
+		if d.AppID == appID {
+			copied := *d
+			domains = append(domains, &copied)
+		}
+	}
+	return domains
+}
+
+// domainClaimConflict explains why an app can't claim a host name, or returns "" when
+// it can: the app has claimed it already, or another app has verified it
+func (h *AppHandler) domainClaimConflict(appID uuid.UUID, hostname string) string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, existing := range h.customDomains {
+		switch {
+		case existing.Hostname != hostname:
+		case existing.AppID == appID:
+			return "Domain is already added to this app"
+		case existing.IsVerified():
+			return "Domain is already attached to an app"
+		}
+	}
+	return ""
+}
+
+// verifiedElsewhere reports whether another app has verified a domain's host name
+func (h *AppHandler) verifiedElsewhere(d *domain.CustomDomain) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, existing := range h.customDomains {
+		if existing.ID != d.ID && existing.Hostname == d.Hostname && existing.IsVerified() {
+			return true
+		}
+	}
+	return false
+}
+
+// updateCustomDomain keeps a changed copy of a domain, unless it was removed meanwhile
+func (h *AppHandler) updateCustomDomain(d *domain.CustomDomain) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, exists := h.customDomains[d.ID]; exists {
+		h.customDomains[d.ID] = d
+	}
+}
+
+// removeAppDomains forgets the custom domains of a deleted app
+func (h *AppHandler) removeAppDomains(appID uuid.UUID) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for id, d := range h.customDomains {
+		if d.AppID == appID {
+			delete(h.customDomains, id)
+		}
+	}
+}
+
+// saveCustomDomain persists a verification result, logging rather than failing the request
+func (h *AppHandler) saveCustomDomain(ctx context.Context, d *domain.CustomDomain) {
+	if h.domainStore == nil {
+		return
+	}
+	if err := h.domainStore.Update(ctx, d); err != nil {
+		h.logger.Warn("Failed to persist custom domain", zap.String("hostname", d.Hostname), zap.Error(err))
+	}
+}
+
+func (h *AppHandler) domainResponse(app *domain.App, d *domain.CustomDomain) CustomDomainResponse {
+	return CustomDomainResponse{
+		CustomDomain: d,
+		CNAMETarget:  h.router.AppHost(app),
+	}
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+	"github.com/nanopaas/nanopaas/internal/services/gitprovider"
+)
+
+// Note: AppHandler and NewAppHandler are defined in app_handler.go
+// This file links apps to git repositories on the existing AppHandler
+
+// GitRepoConnector looks up repositories on git providers and adds push webhooks to them
+type GitRepoConnector interface {
+	ConnectRepository(ctx context.Context, user *domain.User, provider, owner, repo, webhookURL, webhookSecret string) (*gitprovider.Repository, *domain.GitHubWebhook, error)
+}
+
+// SetGitRepoConnector sets what looks up the repositories apps are linked to and adds
+// their webhooks
+func (h *AppHandler) SetGitRepoConnector(connector GitRepoConnector) {
+	h.repoConnector = connector
+}
+
+// SetPublicURL sets the URL git providers reach this API at, which webhooks are pointed
+// at. Without one it is taken from each request.
+func (h *AppHandler) SetPublicURL(publicURL string) {
+	h.publicURL = strings.TrimSuffix(publicURL, "/")
+}
+
+// ConnectRepoRequest represents a request to link an app to a repository
+type ConnectRepoRequest struct {
+	Provider   string `json:"provider,omitempty"` // github when empty
+	Owner      string `json:"owner"`
+	Repo       string `json:"repo"`
+	Branch     string `json:"branch,omitempty"`      // the repository's default branch when empty
+	AutoDeploy *bool  `json:"auto_deploy,omitempty"` // true when omitted
+	Webhook    *bool  `json:"webhook,omitempty"`     // add a push webhook, true when omitted
+}
+
+// ConnectRepository links an app to a repository and branch, replacing the webhook of
+// any repository it was linked to with one on the new repository
+func (h *AppHandler) ConnectRepository(w http.ResponseWriter, r *http.Request) {
+	app, ok := h.requireAppManager(w, r, "Only the app owner, its team and admins can link its repository")
+	if !ok {
+		return
+	}
+	app = copyApp(app) // swapped in by saveApp once linked
+	if h.repoConnector == nil {
+		writeError(w, http.StatusServiceUnavailable, "Git providers are not enabled")
+		return
+	}
+
+	var req ConnectRepoRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Provider == "" {
+		req.Provider = domain.GitProviderGitHub
+	}
+	if !domain.ValidGitProvider(req.Provider) {
+		writeError(w, http.StatusBadRequest, "provider is not a supported git provider")
+		return
+	}
+	if req.Owner == "" || req.Repo == "" {
+		writeError(w, http.StatusBadRequest, "Owner and repo are required")
+		return
+	}
+
+	var webhookURL, webhookSecret string
+	if req.Webhook == nil || *req.Webhook {
+		webhookURL = h.apiURL(r) + "/api/v1/webhooks/" + req.Provider + "/" + app.ID.String()
+		var err error
+		if webhookSecret, err = h.appWebhookSecret(r.Context(), app); err != nil {
+			h.logger.Error("Failed to get app webhook secret", zap.String("app_id", app.ID.String()), zap.Error(err))
+			writeError(w, http.StatusInternalServerError, "Failed to connect repository")
+			return
+		}
+	}
+
+	// The old webhook goes first: providers refuse a second hook with the same URL
+	user := GetUserFromContext(r.Context())
+	h.removeGitHubWebhook(r.Context(), user, app)
+	app.GitHubWebhook = nil
+
+	repository, hook, err := h.repoConnector.ConnectRepository(r.Context(), user, req.Provider, req.Owner, req.Repo, webhookURL, webhookSecret)
+	if err != nil {
+		h.saveApp(r.Context(), app)
+		switch {
+		case errors.Is(err, gitprovider.ErrNotConnected):
+			writeError(w, http.StatusUnauthorized, req.Provider+" not connected")
+		case errors.Is(err, gitprovider.ErrUnknownProvider):
+			writeError(w, http.StatusBadRequest, req.Provider+" is not configured")
+		default:
+			h.logger.Error("Failed to connect repository",
+				zap.String("app_id", app.ID.String()),
+				zap.String("provider", req.Provider),
+				zap.String("repo", req.Owner+"/"+req.Repo),
+				zap.Error(err),
+			)
+			writeGitHubError(w, err, http.StatusBadGateway, "Failed to connect repository")
+		}
+		return
+	}
+
+	app.GitProvider = req.Provider
+	app.GitRepoURL = repository.CloneURL
+	app.GitBranch = req.Branch
+	if app.GitBranch == "" {
+		app.GitBranch = repository.DefaultBranch
+	}
+	app.AutoDeploy = req.AutoDeploy == nil || *req.AutoDeploy
+	app.GitHubWebhook = hook
+	app.UpdatedAt = time.Now().UTC()
+	h.saveApp(r.Context(), app)
+
+	h.logger.Info("App linked to repository",
+		zap.String("app_id", app.ID.String()),
+		zap.String("provider", req.Provider),
+		zap.String("repo", repository.FullName),
+		zap.String("branch", app.GitBranch),
+	)
+	writeJSON(w, http.StatusOK, h.appToResponse(app))
+}
+
+// DisconnectRepository unlinks an app from its repository, deleting its webhook and
+// turning off auto-deploy
+func (h *AppHandler) DisconnectRepository(w http.ResponseWriter, r *http.Request) {
+	app, ok := h.requireAppManager(w, r, "Only the app owner, its team and admins can unlink its repository")
+	if !ok {
+		return
+	}
+
+	h.removeGitHubWebhook(r.Context(), GetUserFromContext(r.Context()), app)
+	app.GitHubWebhook = nil
+	app.GitRepoURL = ""
+	app.GitBranch = ""
+	app.AutoDeploy = false
+	app.UpdatedAt = time.Now().UTC()
+	h.saveApp(r.Context(), app)
+
+	writeJSON(w, http.StatusOK, h.appToResponse(app))
+}
+
+// apiURL returns the URL git providers reach this API at
+func (h *AppHandler) apiURL(r *http.Request) string {
+	if h.publicURL != "" {
+		return h.publicURL
+	}
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
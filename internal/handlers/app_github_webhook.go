@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// Note: AppHandler and NewAppHandler are defined in app_handler.go
+// This file records the GitHub webhooks created for apps on the existing AppHandler
+
+// GitHubWebhookRemover deletes an app's webhook from the git provider it is on
+type GitHubWebhookRemover interface {
+	RemoveGitHubWebhook(ctx context.Context, user *domain.User, hook *domain.GitHubWebhook) error
+}
+
+// SetGitHubWebhookRemover sets what deletes apps' webhooks from their git providers when
+// the apps are deleted or linked to another repository
+func (h *AppHandler) SetGitHubWebhookRemover(remover GitHubWebhookRemover) {
+	h.webhookRemover = remover
+}
+
+// CheckGitHubWebhookApp checks a webhook can be created for an app: the user manages it
+// and it has none yet
+func (h *AppHandler) CheckGitHubWebhookApp(ctx context.Context, user *domain.User, appID uuid.UUID) error {
+	app, ok := h.FindApp(appID)
+	if !ok {
+		return fmt.Errorf("app %w", domain.ErrNotFound)
+	}
+	if user == nil || !h.canManageApp(user, app, h.userTeams(ctx, user)) {
+		return domain.ErrForbidden
+	}
+	if app.GitHubWebhook != nil {
+		return fmt.Errorf("app webhook %w", domain.ErrConflict)
+	}
+	return nil
+}
+
+// SetGitHubWebhook records the webhook created for an app
+func (h *AppHandler) SetGitHubWebhook(ctx context.Context, appID uuid.UUID, hook *domain.GitHubWebhook) {
+	app, ok := h.FindApp(appID)
+	if !ok {
+		return
+	}
+	app.GitHubWebhook = hook
+	h.saveApp(ctx, app)
+}
+
+// ForgetGitHubWebhook clears a deleted webhook from the app it was created for, if any
+func (h *AppHandler) ForgetGitHubWebhook(ctx context.Context, provider, owner, repo string, hookID int64) {
+	for _, app := range h.ListApps() {
+		hook := app.GitHubWebhook
+		if hook != nil && hook.ID == hookID && hook.ProviderName() == provider && hook.Owner == owner && hook.Repo == repo {
+			app.GitHubWebhook = nil
+			h.saveApp(ctx, app)
+		}
+	}
+}
+
+// removeGitHubWebhook deletes an app's webhook from its git provider. Failures are
+// logged, leaving the webhook to be deleted by hand.
+func (h *AppHandler) removeGitHubWebhook(ctx context.Context, user *domain.User, app *domain.App) {
+	hook := app.GitHubWebhook
+	if hook == nil || h.webhookRemover == nil {
+		return
+	}
+	if err := h.webhookRemover.RemoveGitHubWebhook(ctx, user, hook); err != nil {
+		h.logger.Warn("Failed to delete app webhook",
+			zap.String("app_id", app.ID.String()),
+			zap.String("provider", hook.ProviderName()),
+			zap.String("repo", hook.Owner+"/"+hook.Repo),
+			zap.Int64("hook_id", hook.ID),
+			zap.Error(err),
+		)
+	}
+}
@@ -1,29 +1,85 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"maps"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 
 	"github.com/nanopaas/nanopaas/internal/domain"
+	"github.com/nanopaas/nanopaas/internal/services/cost"
 	"github.com/nanopaas/nanopaas/internal/services/orchestrator"
 	"github.com/nanopaas/nanopaas/internal/services/router"
+	"github.com/nanopaas/nanopaas/internal/services/templates"
 )
 
 // AppHandler handles application management endpoints
 type AppHandler struct {
-	orchestrator *orchestrator.Orchestrator
-	router       *router.TraefikRouter
-	logger       *zap.Logger
-	apps         map[uuid.UUID]*domain.App // In-memory store (use DB in production)
+	orchestrator  *orchestrator.Orchestrator
+	router        router.Router
+	costEstimator *cost.Estimator
+	logger        *zap.Logger
+	mu            sync.RWMutex              // guards apps, projects and customDomains, which background work reaches too
+	apps          map[uuid.UUID]*domain.App // Loaded from appStore at startup and written through
+	appStore      AppStore
+	tx            Transactor // groups multi-app writes, set by SetTransactor
+	incidents     map[uuid.UUID]*domain.Incident
+	incidentStore IncidentStore
+	buildLogs     BuildLogSource
+	builds        BuildHistory
+	customDomains map[uuid.UUID]*domain.CustomDomain
+	domainStore   CustomDomainStore
+	teams         TeamMembershipSource
+	secrets       SecretBox
+	templates     *templates.Catalog
+	gitBuilder    GitBuilder
+	projects      map[uuid.UUID]*domain.Project
+	projectStore  ProjectStore
+	collaborators CollaboratorStore
+	users         UserLookup
+	deployTokens  DeployTokenIssuer
+
+	webhookRemover  GitHubWebhookRemover
+	webhookSecrets  WebhookSecretBox
+	webhookSecretMu sync.Mutex // serializes generating apps' webhook secrets
+	repoConnector   GitRepoConnector
+	publicURL       string
+}
+
+// AppStore persists apps
+type AppStore interface {
+	Create(ctx context.Context, app *domain.App) error
+	GetByID(ctx context.Context, id uuid.UUID) (*domain.App, error)
+	Update(ctx context.Context, app *domain.App) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	ListAll(ctx context.Context) ([]*domain.App, error)
+
+	// SetEnvVars and DeleteEnvVar change single variables without rewriting the app
+	SetEnvVars(ctx context.Context, id uuid.UUID, envVars map[string]string) error
+	DeleteEnvVar(ctx context.Context, id uuid.UUID, key string) error
+
+	// EnsureWebhookSecret stores a sealed webhook secret unless the app has one, and
+	// returns the one it keeps
+	EnsureWebhookSecret(ctx context.Context, id uuid.UUID, sealed []byte) ([]byte, error)
+
+	// ListByLabel finds apps by label; an empty value matches any
+	ListByLabel(ctx context.Context, key, value string) ([]*domain.App, error)
+
+	// ListOverview returns every app with its latest stored build and deployment
+	ListOverview(ctx context.Context) ([]*domain.AppOverview, error)
 }
 
 // CreateAppRequest represents a request to create an app
 type CreateAppRequest struct {
+	TeamID      string            `json:"team_id,omitempty"`
 	Name        string            `json:"name"`
 	Slug        string            `json:"slug"`
 	Description string            `json:"description,omitempty"`
@@ -31,6 +87,31 @@ type CreateAppRequest struct {
 	ExposedPort int               `json:"exposed_port,omitempty"`
 	MemoryLimit int64             `json:"memory_limit,omitempty"`
 	CPUQuota    int64             `json:"cpu_quota,omitempty"`
+
+	RestartPolicy string            `json:"restart_policy,omitempty"`
+	NoFileLimit   int64             `json:"nofile_limit,omitempty"`
+	Tmpfs         map[string]string `json:"tmpfs,omitempty"`
+	ShmSize       int64             `json:"shm_size,omitempty"`
+	Sysctls       map[string]string `json:"sysctls,omitempty"`
+
+	SmokeChecks []domain.SmokeCheck `json:"smoke_checks,omitempty"`
+	CORS        *domain.CORSPolicy  `json:"cors,omitempty"`
+
+	StreamingMode     domain.StreamingMode `json:"streaming_mode,omitempty"`
+	StreamIdleTimeout int                  `json:"stream_idle_timeout,omitempty"`
+
+	EnvRestartPolicy string `json:"env_restart_policy,omitempty"` // immediate, rolling or next_deploy
+
+	RequireApproval bool `json:"require_approval,omitempty"` // webhook deploys wait for approval
+
+	GitProvider string `json:"git_provider,omitempty"` // github when empty
+	GitRepoURL  string `json:"git_repo_url,omitempty"`
+	GitBranch   string `json:"git_branch,omitempty"`
+	AutoDeploy  bool   `json:"auto_deploy,omitempty"` // pushes to git_branch build and deploy
+
+	DeployTrigger string `json:"deploy_trigger,omitempty"` // branch, tag or release
+	TagPattern    string `json:"tag_pattern,omitempty"`    // glob the tags that deploy must match
+	RequireChecks bool   `json:"require_checks,omitempty"` // GitHub deploys wait for required checks
 }
 
 // UpdateAppRequest represents a request to update an app
@@ -41,12 +122,37 @@ type UpdateAppRequest struct {
 	ExposedPort int               `json:"exposed_port,omitempty"`
 	MemoryLimit int64             `json:"memory_limit,omitempty"`
 	CPUQuota    int64             `json:"cpu_quota,omitempty"`
+
+	RestartPolicy string            `json:"restart_policy,omitempty"`
+	NoFileLimit   int64             `json:"nofile_limit,omitempty"`
+	Tmpfs         map[string]string `json:"tmpfs,omitempty"`
+	ShmSize       int64             `json:"shm_size,omitempty"`
+	Sysctls       map[string]string `json:"sysctls,omitempty"`
+
+	SmokeChecks []domain.SmokeCheck `json:"smoke_checks,omitempty"`
+
+	StreamingMode     *domain.StreamingMode `json:"streaming_mode,omitempty"` // "" disables streaming tuning
+	StreamIdleTimeout int                   `json:"stream_idle_timeout,omitempty"`
+
+	EnvRestartPolicy string `json:"env_restart_policy,omitempty"` // immediate, rolling or next_deploy
+
+	RequireApproval *bool `json:"require_approval,omitempty"` // webhook deploys wait for approval
+
+	GitProvider *string `json:"git_provider,omitempty"`
+	GitRepoURL  *string `json:"git_repo_url,omitempty"` // "" unlinks the repository
+	GitBranch   *string `json:"git_branch,omitempty"`
+	AutoDeploy  *bool   `json:"auto_deploy,omitempty"`
+
+	DeployTrigger *string `json:"deploy_trigger,omitempty"` // branch, tag or release
+	TagPattern    *string `json:"tag_pattern,omitempty"`
+	RequireChecks *bool   `json:"require_checks,omitempty"`
 }
 
 // DeployRequest represents a deployment request
 type DeployRequest struct {
 	ImageID  string `json:"image_id"`
 	Replicas int    `json:"replicas,omitempty"`
+	Unpin    bool   `json:"unpin,omitempty"` // release a deployment pin and deploy anyway
 }
 
 // ScaleRequest represents a scaling request
@@ -56,30 +162,149 @@ type ScaleRequest struct {
 
 // AppResponse represents an app in API responses
 type AppResponse struct {
-	ID             string            `json:"id"`
-	Name           string            `json:"name"`
-	Slug           string            `json:"slug"`
-	Description    string            `json:"description,omitempty"`
-	Status         string            `json:"status"`
-	URL            string            `json:"url,omitempty"`
-	Replicas       int               `json:"replicas"`
-	TargetReplicas int               `json:"target_replicas"`
-	CurrentImageID string            `json:"current_image_id,omitempty"`
-	EnvVars        map[string]string `json:"env_vars,omitempty"`
-	ExposedPort    int               `json:"exposed_port"`
-	MemoryLimit    int64             `json:"memory_limit"`
-	CPUQuota       int64             `json:"cpu_quota"`
-	CreatedAt      string            `json:"created_at"`
-	UpdatedAt      string            `json:"updated_at"`
+	ID                string                `json:"id"`
+	TeamID            string                `json:"team_id,omitempty"`
+	ProjectID         string                `json:"project_id,omitempty"`
+	Name              string                `json:"name"`
+	Slug              string                `json:"slug"`
+	Description       string                `json:"description,omitempty"`
+	Status            string                `json:"status"`
+	URL               string                `json:"url,omitempty"`
+	Replicas          int                   `json:"replicas"`
+	TargetReplicas    int                   `json:"target_replicas"`
+	CurrentImageID    string                `json:"current_image_id,omitempty"`
+	EnvVars           map[string]string     `json:"env_vars,omitempty"`
+	Labels            map[string]string     `json:"labels,omitempty"`
+	Secrets           map[string]string     `json:"secrets,omitempty"` // values masked
+	ExposedPort       int                   `json:"exposed_port"`
+	MemoryLimit       int64                 `json:"memory_limit"`
+	CPUQuota          int64                 `json:"cpu_quota"`
+	RestartPolicy     string                `json:"restart_policy"`
+	NoFileLimit       int64                 `json:"nofile_limit,omitempty"`
+	Tmpfs             map[string]string     `json:"tmpfs,omitempty"`
+	ShmSize           int64                 `json:"shm_size,omitempty"`
+	Sysctls           map[string]string     `json:"sysctls,omitempty"`
+	Command           []string              `json:"command,omitempty"`
+	Volumes           []domain.VolumeMount  `json:"volumes,omitempty"`
+	NetworkAliases    []string              `json:"network_aliases,omitempty"`
+	SmokeChecks       []domain.SmokeCheck   `json:"smoke_checks,omitempty"`
+	CORS              *domain.CORSPolicy    `json:"cors,omitempty"`
+	Protected         bool                  `json:"protected"`
+	StreamingMode     string                `json:"streaming_mode,omitempty"`
+	StreamIdleTimeout int                   `json:"stream_idle_timeout,omitempty"`
+	LastExit          *ExitResponse         `json:"last_exit,omitempty"`
+	Pin               *domain.DeploymentPin `json:"pin,omitempty"`
+	Lockdown          *domain.Lockdown      `json:"lockdown,omitempty"`
+	CustomDomains     []string              `json:"custom_domains,omitempty"`
+	EnvRestartPolicy  string                `json:"env_restart_policy"`
+	PendingRestart    bool                  `json:"pending_restart"` // containers predate an env var or secret change
+	RequireApproval   bool                  `json:"require_approval"`
+	GitProvider       string                `json:"git_provider"`
+	GitRepoURL        string                `json:"git_repo_url,omitempty"`
+	GitBranch         string                `json:"git_branch,omitempty"`
+	AutoDeploy        bool                  `json:"auto_deploy"`
+	DeployTrigger     string                `json:"deploy_trigger"`
+	TagPattern        string                `json:"tag_pattern,omitempty"`
+	RequireChecks     bool                  `json:"require_checks"`
+	GitHubWebhook     *domain.GitHubWebhook `json:"github_webhook,omitempty"`
+	CreatedAt         string                `json:"created_at"`
+	UpdatedAt         string                `json:"updated_at"`
+}
+
+// ExitResponse describes a container exit with an actionable hint
+type ExitResponse struct {
+	domain.ContainerExit
+	Hint string `json:"hint"`
 }
 
 // NewAppHandler creates a new app handler
-func NewAppHandler(orch *orchestrator.Orchestrator, rtr *router.TraefikRouter, logger *zap.Logger) *AppHandler {
+func NewAppHandler(orch *orchestrator.Orchestrator, rtr router.Router, logger *zap.Logger) *AppHandler {
 	return &AppHandler{
 		orchestrator: orch,
 		router:       rtr,
 		logger:       logger,
 		apps:         make(map[uuid.UUID]*domain.App),
+		incidents:    make(map[uuid.UUID]*domain.Incident),
+		projects:     make(map[uuid.UUID]*domain.Project),
+
+		customDomains: make(map[uuid.UUID]*domain.CustomDomain),
+	}
+}
+
+// SetAppStore sets the store apps are persisted to
+func (h *AppHandler) SetAppStore(store AppStore) {
+	h.appStore = store
+}
+
+// SetTransactor sets what makes writes to several apps commit or roll back together
+func (h *AppHandler) SetTransactor(tx Transactor) {
+	h.tx = tx
+}
+
+// LoadApps restores projects and apps from the store. Running apps adopt the containers left by the
+// previous process and get their routes back; apps whose containers are gone are marked stopped.
+func (h *AppHandler) LoadApps(ctx context.Context) error {
+	if err := h.loadProjects(ctx); err != nil {
+		return err
+	}
+	if h.appStore == nil {
+		return nil
+	}
+	apps, err := h.appStore.ListAll(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load apps: %w", err)
+	}
+
+	for _, app := range apps {
+		if app.ProjectID != nil {
+			if project, ok := h.findProject(*app.ProjectID); ok {
+				app.ProjectEnv = project.EnvVars
+			}
+		}
+		h.cacheApp(app)
+		if app.Status == domain.AppStatusRunning {
+			h.restoreApp(ctx, app)
+		}
+	}
+
+	h.logger.Info("Apps loaded", zap.Int("apps", len(apps)))
+	return nil
+}
+
+// RecoverContainers resyncs running apps after the container runtime comes back from an
+// outage. Apps whose containers were never adopted, because the runtime was down at
+// startup, are restored as in LoadApps; the others get their routes refreshed, since
+// restarted containers may have new addresses.
+func (h *AppHandler) RecoverContainers(ctx context.Context) {
+	for _, app := range h.ListApps() {
+		if app.Status != domain.AppStatusRunning {
+			continue
+		}
+		if len(h.orchestrator.GetAppContainers(app.ID)) == 0 {
+			h.restoreApp(ctx, app)
+			continue
+		}
+		h.RefreshRoute(app.ID)
+	}
+}
+
+// restoreApp adopts a running app's containers and restores its route, marking the app
+// stopped when its containers are gone
+func (h *AppHandler) restoreApp(ctx context.Context, app *domain.App) {
+	adopted, err := h.orchestrator.AdoptContainers(ctx, app)
+	if err != nil {
+		h.logger.Warn("Failed to adopt app containers", zap.String("app_id", app.ID.String()), zap.Error(err))
+		return
+	}
+	if adopted == 0 {
+		h.logger.Warn("Running app has no containers, marking stopped", zap.String("app_id", app.ID.String()))
+		app.MarkStopped()
+		h.saveApp(ctx, app)
+		return
+	}
+
+	if err := h.router.AddRoute(ctx, app, h.appReplicas(ctx, app)); err != nil {
+		h.logger.Warn("Failed to restore app route", zap.String("app_id", app.ID.String()), zap.Error(err))
 	}
 }
 
@@ -100,8 +325,14 @@ func (h *AppHandler) Create(w http.ResponseWriter, r *http.Request) {
 		req.Slug = slugify(req.Name)
 	}
 
+	// The slug is also the app's subdomain
+	if err := h.router.ValidateSubdomain(req.Slug); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid slug: "+err.Error())
+		return
+	}
+
 	// Check for duplicate slug
-	for _, app := range h.apps {
+	for _, app := range h.ListApps() {
 		if app.Slug == req.Slug {
 			writeError(w, http.StatusConflict, "App with this slug already exists")
 			return
@@ -109,10 +340,27 @@ func (h *AppHandler) Create(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Create app
-	ownerID := uuid.New() // Placeholder - get from auth in production
-	app := domain.NewApp(req.Name, req.Slug, ownerID)
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Not authenticated")
+		return
+	}
+	app := domain.NewApp(req.Name, req.Slug, user.ID)
 	app.Description = req.Description
 
+	if req.TeamID != "" {
+		teamID, err := uuid.Parse(req.TeamID)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid team_id")
+			return
+		}
+		if !user.IsAdmin() && !h.userTeams(r.Context(), user)[teamID] {
+			writeError(w, http.StatusForbidden, "Not a member of this team")
+			return
+		}
+		app.TeamID = &teamID
+	}
+
 	if req.ExposedPort > 0 {
 		app.ExposedPort = req.ExposedPort
 	}
@@ -122,12 +370,64 @@ func (h *AppHandler) Create(w http.ResponseWriter, r *http.Request) {
 	if req.CPUQuota > 0 {
 		app.CPUQuota = req.CPUQuota
 	}
+	if err := applyRuntimeOptions(app, req.RestartPolicy, req.NoFileLimit, req.Tmpfs, req.ShmSize, req.Sysctls); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := domain.ValidateSmokeChecks(req.SmokeChecks); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	app.SmokeChecks = req.SmokeChecks
+	if req.CORS != nil {
+		req.CORS.Normalize()
+		if err := req.CORS.Validate(); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		app.CORS = req.CORS
+	}
+	app.StreamingMode = req.StreamingMode
+	app.StreamIdleTimeout = req.StreamIdleTimeout
+	if err := app.ValidateStreaming(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.EnvRestartPolicy != "" {
+		policy, ok := domain.ParseEnvRestartPolicy(req.EnvRestartPolicy)
+		if !ok {
+			writeError(w, http.StatusBadRequest, "env_restart_policy must be immediate, rolling or next_deploy")
+			return
+		}
+		app.EnvRestartPolicy = policy
+	}
+	app.RequireApproval = req.RequireApproval
+	if req.GitProvider != "" {
+		app.GitProvider = req.GitProvider
+	}
+	app.GitRepoURL = req.GitRepoURL
+	app.GitBranch = req.GitBranch
+	app.AutoDeploy = req.AutoDeploy
+	app.DeployTrigger = domain.DeployTrigger(req.DeployTrigger)
+	app.TagPattern = req.TagPattern
+	app.RequireChecks = req.RequireChecks
+	if err := app.ValidateGitSource(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	for k, v := range req.EnvVars {
 		app.SetEnvVar(k, v)
 	}
 
 	// Store app
-	h.apps[app.ID] = app
+	if h.appStore != nil {
+		if err := h.appStore.Create(r.Context(), app); err != nil {
+			h.logger.Error("Failed to store app", zap.String("slug", app.Slug), zap.Error(err))
+			writeDomainError(w, err, "Failed to create app")
+			return
+		}
+	}
+	h.cacheApp(app)
 
 	h.logger.Info("App created",
 		zap.String("app_id", app.ID.String()),
@@ -138,13 +438,86 @@ func (h *AppHandler) Create(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusCreated, h.appToResponse(app))
 }
 
-// List returns all applications
+// appSorts compares apps by each sortable key of the app list
+var appSorts = map[string]func(a, b *domain.App) int{
+	"name":       func(a, b *domain.App) int { return strings.Compare(a.Name, b.Name) },
+	"status":     func(a, b *domain.App) int { return strings.Compare(string(a.Status), string(b.Status)) },
+	"created_at": func(a, b *domain.App) int { return a.CreatedAt.Compare(b.CreatedAt) },
+	"updated_at": func(a, b *domain.App) int { return a.UpdatedAt.Compare(b.UpdatedAt) },
+}
+
+// List returns the applications the user can manage or collaborates on, filtered by
+// status and name
 func (h *AppHandler) List(w http.ResponseWriter, r *http.Request) {
-	apps := make([]AppResponse, 0, len(h.apps))
-	for _, app := range h.apps {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Not authenticated")
+		return
+	}
+	params, err := parseListParams(r, []string{"name", "status", "created_at", "updated_at"}, "-created_at")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	labeled, err := h.labeledApps(r.Context(), r.URL.Query().Get("label"))
+	if err != nil {
+		h.logger.Error("Failed to list apps by label", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "Failed to list apps")
+		return
+	}
+	teams := h.userTeams(r.Context(), user)
+	shared := h.collaboratorApps(r.Context(), user)
+
+	matched := make([]*domain.App, 0)
+	for _, app := range h.ListApps() {
+		if !h.canManageApp(user, app, teams) && !shared[app.ID] {
+			continue
+		}
+		if params.Status != "" && string(app.Status) != params.Status {
+			continue
+		}
+		if labeled != nil && !labeled[app.ID] {
+			continue
+		}
+		if !params.matchesName(app.Name) && !params.matchesName(app.Slug) {
+			continue
+		}
+		matched = append(matched, app)
+	}
+
+	page, total := sortAndPage(matched, params, appSorts)
+	apps := make([]AppResponse, 0, len(page))
+	for _, app := range page {
 		apps = append(apps, h.appToResponse(app))
 	}
-	writeJSON(w, http.StatusOK, apps)
+	writeList(w, r, apps, total, params)
+}
+
+// labeledApps returns the IDs of the apps a label filter matches, or nil without one.
+// label=key matches apps with the label, label=key=value only those with that value.
+func (h *AppHandler) labeledApps(ctx context.Context, filter string) (map[uuid.UUID]bool, error) {
+	if filter == "" {
+		return nil, nil
+	}
+	key, value, _ := strings.Cut(filter, "=")
+
+	matched := make(map[uuid.UUID]bool)
+	if h.appStore == nil {
+		for _, app := range h.ListApps() {
+			if v, ok := app.Labels[key]; ok && (value == "" || v == value) {
+				matched[app.ID] = true
+			}
+		}
+		return matched, nil
+	}
+	apps, err := h.appStore.ListByLabel(ctx, key, value)
+	if err != nil {
+		return nil, err
+	}
+	for _, app := range apps {
+		matched[app.ID] = true
+	}
+	return matched, nil
 }
 
 // Get returns an application by ID
@@ -174,23 +547,109 @@ func (h *AppHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Apply the request to a copy so a bad request or a failed save leaves the app untouched
+	candidate := *app
+	candidate.EnvVars = maps.Clone(app.EnvVars)
+	if err := applyRuntimeOptions(&candidate, req.RestartPolicy, req.NoFileLimit, req.Tmpfs, req.ShmSize, req.Sysctls); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.StreamingMode != nil {
+		candidate.StreamingMode = *req.StreamingMode
+	}
+	if req.StreamIdleTimeout != 0 {
+		candidate.StreamIdleTimeout = req.StreamIdleTimeout
+	}
+	if err := candidate.ValidateStreaming(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.SmokeChecks != nil {
+		if err := domain.ValidateSmokeChecks(req.SmokeChecks); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		candidate.SmokeChecks = req.SmokeChecks
+	}
+	streamingChanged := app.StreamingMode != candidate.StreamingMode || app.StreamIdleTimeout != candidate.StreamIdleTimeout
+	if req.EnvRestartPolicy != "" {
+		policy, ok := domain.ParseEnvRestartPolicy(req.EnvRestartPolicy)
+		if !ok {
+			writeError(w, http.StatusBadRequest, "env_restart_policy must be immediate, rolling or next_deploy")
+			return
+		}
+		candidate.EnvRestartPolicy = policy
+	}
+	if req.RequireApproval != nil {
+		candidate.RequireApproval = *req.RequireApproval
+	}
+	if req.GitProvider != nil {
+		candidate.GitProvider = *req.GitProvider
+	}
+	if req.GitRepoURL != nil {
+		candidate.GitRepoURL = *req.GitRepoURL
+	}
+	if req.GitBranch != nil {
+		candidate.GitBranch = *req.GitBranch
+	}
+	if req.AutoDeploy != nil {
+		candidate.AutoDeploy = *req.AutoDeploy
+	}
+	if req.DeployTrigger != nil {
+		candidate.DeployTrigger = domain.DeployTrigger(*req.DeployTrigger)
+	}
+	if req.TagPattern != nil {
+		candidate.TagPattern = *req.TagPattern
+	}
+	if req.RequireChecks != nil {
+		candidate.RequireChecks = *req.RequireChecks
+	}
+	if err := candidate.ValidateGitSource(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	if req.Name != "" {
-		app.Name = req.Name
+		candidate.Name = req.Name
 	}
 	if req.Description != "" {
-		app.Description = req.Description
+		candidate.Description = req.Description
 	}
 	if req.ExposedPort > 0 {
-		app.ExposedPort = req.ExposedPort
+		candidate.ExposedPort = req.ExposedPort
 	}
 	if req.MemoryLimit > 0 {
-		app.MemoryLimit = req.MemoryLimit
+		candidate.MemoryLimit = req.MemoryLimit
 	}
 	if req.CPUQuota > 0 {
-		app.CPUQuota = req.CPUQuota
+		candidate.CPUQuota = req.CPUQuota
+	}
+	if key := secretEnvConflict(app, req.EnvVars); key != "" {
+		writeError(w, http.StatusConflict, key+" is a secret; update it through the secrets API")
+		return
 	}
 	for k, v := range req.EnvVars {
-		app.SetEnvVar(k, v)
+		candidate.SetEnvVar(k, v)
+	}
+	if len(req.EnvVars) > 0 || req.MemoryLimit > 0 || req.CPUQuota > 0 {
+		candidate.MarkPendingRestart()
+	}
+	candidate.UpdatedAt = time.Now().UTC()
+	if h.appStore != nil {
+		if err := h.appStore.Update(r.Context(), &candidate); err != nil {
+			h.logger.Error("Failed to store app", zap.String("app_id", appID), zap.Error(err))
+			writeDomainError(w, err, "Failed to update app")
+			return
+		}
+	}
+	app = &candidate
+	h.replaceApp(app)
+
+	// Re-render a live route so streaming tuning applies without a redeploy
+	if _, routed := h.router.GetRoute(app.ID); routed && streamingChanged {
+		if err := h.router.AddRoute(r.Context(), app, h.appReplicas(r.Context(), app)); err != nil {
+			h.logger.Warn("Failed to update route", zap.String("app_id", appID), zap.Error(err))
+		}
 	}
 
 	h.logger.Info("App updated", zap.String("app_id", appID))
@@ -206,6 +665,15 @@ func (h *AppHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Delete from store first, so an app whose rows can't be deleted keeps running
+	if h.appStore != nil {
+		if err := h.appStore.Delete(r.Context(), app.ID); err != nil {
+			h.logger.Error("Failed to delete app", zap.String("app_id", appID), zap.Error(err))
+			writeDomainError(w, err, "Failed to delete app")
+			return
+		}
+	}
+
 	// Stop containers
 	if err := h.orchestrator.Stop(r.Context(), app); err != nil {
 		h.logger.Warn("Failed to stop app containers", zap.Error(err))
@@ -213,9 +681,11 @@ func (h *AppHandler) Delete(w http.ResponseWriter, r *http.Request) {
 
 	// Remove route
 	h.router.RemoveRoute(r.Context(), app.ID)
+	h.router.ReleasePorts(app.ID)
 
-	// Delete from store
-	delete(h.apps, app.ID)
+	h.uncacheApp(app.ID)
+	h.removeAppDomains(app.ID)
+	h.removeGitHubWebhook(r.Context(), GetUserFromContext(r.Context()), app)
 
 	h.logger.Info("App deleted", zap.String("app_id", appID))
 	writeJSON(w, http.StatusOK, map[string]string{
@@ -243,6 +713,19 @@ func (h *AppHandler) Deploy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if app.IsPinned() {
+		if !req.Unpin {
+			writePinConflict(w, &domain.PinnedError{Pin: app.Pin})
+			return
+		}
+		h.logger.Info("Deployment pin released by manual deploy",
+			zap.String("app_id", appID),
+			zap.String("deployment_id", app.Pin.DeploymentID.String()),
+		)
+		h.orchestrator.SetDeploymentPinned(app.Pin.DeploymentID, false)
+		app.Unpin()
+	}
+
 	if req.Replicas > 0 {
 		app.TargetReplicas = req.Replicas
 	}
@@ -251,24 +734,18 @@ func (h *AppHandler) Deploy(w http.ResponseWriter, r *http.Request) {
 
 	// Deploy
 	deployment, err := h.orchestrator.Deploy(r.Context(), app)
+	h.saveApp(r.Context(), app)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "Deployment failed: "+err.Error())
 		return
 	}
 
-	// Update route
-	containerIDs := h.orchestrator.GetAppContainers(app.ID)
-	replicas := make([]router.Replica, 0, len(containerIDs))
-	// Note: In production, get actual container IPs from Docker
-	for i := range containerIDs {
-		replicas = append(replicas, router.Replica{
-			ContainerID: containerIDs[i],
-			IPAddress:   "127.0.0.1", // Placeholder
-			Port:        app.ExposedPort,
-			Weight:      1,
-		})
+	if !h.verifyDeployment(w, r, app, deployment) {
+		return
 	}
-	h.router.AddRoute(r.Context(), app, replicas)
+
+	h.recordIncidentEvent(app.ID, domain.IncidentEventDeploy,
+		fmt.Sprintf("Deployed %s as deployment %s", req.ImageID, deployment.ID), requestActor(r))
 
 	h.logger.Info("App deployed",
 		zap.String("app_id", appID),
@@ -280,9 +757,65 @@ func (h *AppHandler) Deploy(w http.ResponseWriter, r *http.Request) {
 		"deployment_id": deployment.ID.String(),
 		"status":        string(deployment.Status),
 		"url":           h.router.GetAppURL(app),
+		"smoke_results": deployment.SmokeResults,
 	})
 }
 
+// verifyDeployment routes traffic to a deployment that started, then runs its smoke
+// checks. On failure it writes the error response and returns false.
+func (h *AppHandler) verifyDeployment(w http.ResponseWriter, r *http.Request, app *domain.App, deployment *domain.Deployment) bool {
+	appID := app.ID.String()
+
+	// Update route
+	h.router.AddRoute(r.Context(), app, h.appReplicas(r.Context(), app))
+
+	// The deployment only counts as done once Traefik serves the new replicas
+	if err := h.router.WaitForRoute(r.Context(), app.ID); err != nil {
+		deployment.Fail(fmt.Errorf("route verification failed: %w", err))
+		h.logger.Warn("Traefik did not pick up route",
+			zap.String("app_id", appID),
+			zap.String("deployment_id", deployment.ID.String()),
+			zap.Error(err),
+		)
+		writeErrorDetails(w, http.StatusGatewayTimeout, "Route verification failed: "+err.Error(), map[string]interface{}{
+			"deployment_id": deployment.ID.String(),
+			"status":        string(deployment.Status),
+		})
+		return false
+	}
+
+	// Verify the new deployment now that it receives traffic; failures roll back automatically
+	if err := h.orchestrator.RunSmokeChecks(r.Context(), app, deployment, h.router.GetAppURL(app)); err != nil {
+		if deployment.Status == domain.DeploymentStatusRolledBack {
+			h.saveApp(r.Context(), app)
+			h.router.AddRoute(r.Context(), app, h.appReplicas(r.Context(), app))
+		}
+		h.logger.Warn("Smoke checks failed",
+			zap.String("app_id", appID),
+			zap.String("deployment_id", deployment.ID.String()),
+			zap.String("failed_check", deployment.FailedCheck),
+		)
+		details := map[string]interface{}{
+			"deployment_id": deployment.ID.String(),
+			"status":        string(deployment.Status),
+			"failed_check":  deployment.FailedCheck,
+			"smoke_results": deployment.SmokeResults,
+		}
+		// A crashed container is the usual cause; surface why it exited
+		if exits := h.orchestrator.ContainerExits(r.Context(), app.ID); len(exits) > 0 {
+			exitResponses := make([]*ExitResponse, 0, len(exits))
+			for _, exit := range exits {
+				exitResponses = append(exitResponses, exitToResponse(exit, app.MemoryLimit))
+			}
+			details["container_exits"] = exitResponses
+			details["hint"] = exitResponses[0].Hint
+		}
+		writeErrorDetails(w, http.StatusUnprocessableEntity, "Smoke checks failed: "+err.Error(), details)
+		return false
+	}
+	return true
+}
+
 // Scale scales an application
 func (h *AppHandler) Scale(w http.ResponseWriter, r *http.Request) {
 	appID := chi.URLParam(r, "appId")
@@ -303,10 +836,15 @@ func (h *AppHandler) Scale(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.orchestrator.Scale(r.Context(), app, req.Replicas); err != nil {
+	err = h.orchestrator.Scale(r.Context(), app, req.Replicas)
+	h.saveApp(r.Context(), app)
+	if err != nil {
 		writeError(w, http.StatusInternalServerError, "Scaling failed: "+err.Error())
 		return
 	}
+	h.RefreshRoute(app.ID)
+	h.recordIncidentEvent(app.ID, domain.IncidentEventScale,
+		fmt.Sprintf("Scaled to %d replicas", req.Replicas), requestActor(r))
 
 	h.logger.Info("App scaled",
 		zap.String("app_id", appID),
@@ -328,10 +866,18 @@ func (h *AppHandler) Restart(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.orchestrator.Restart(r.Context(), app); err != nil {
+	// A Docker restart keeps the old env, so stale containers are replaced instead
+	if app.HasPendingRestart() {
+		err = h.orchestrator.Recreate(r.Context(), app, false)
+	} else {
+		err = h.orchestrator.Restart(r.Context(), app)
+	}
+	h.saveApp(r.Context(), app)
+	if err != nil {
 		writeError(w, http.StatusInternalServerError, "Restart failed: "+err.Error())
 		return
 	}
+	h.RefreshRoute(app.ID)
 
 	h.logger.Info("App restarted", zap.String("app_id", appID))
 	writeJSON(w, http.StatusOK, map[string]string{
@@ -348,7 +894,9 @@ func (h *AppHandler) Stop(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.orchestrator.Stop(r.Context(), app); err != nil {
+	err = h.orchestrator.Stop(r.Context(), app)
+	h.saveApp(r.Context(), app)
+	if err != nil {
 		writeError(w, http.StatusInternalServerError, "Stop failed: "+err.Error())
 		return
 	}
@@ -377,19 +925,38 @@ func (h *AppHandler) SetEnvVars(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if key := secretEnvConflict(app, envVars); key != "" {
+		writeError(w, http.StatusConflict, key+" is a secret; update it through the secrets API")
+		return
+	}
+	policy, ok := envRestartPolicy(r, app)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "restart must be immediate, rolling or next_deploy")
+		return
+	}
+	if h.appStore != nil {
+		if err := h.appStore.SetEnvVars(r.Context(), app.ID, envVars); err != nil {
+			h.logger.Error("Failed to save env vars", zap.String("app_id", appID), zap.Error(err))
+			writeError(w, http.StatusInternalServerError, "Failed to save environment variables")
+			return
+		}
+	}
+	app = copyApp(app)
 	for k, v := range envVars {
 		app.SetEnvVar(k, v)
 	}
+	h.replaceApp(app)
+	restartErr := h.applyEnvChange(r.Context(), app, policy)
 
 	h.logger.Info("Env vars updated",
 		zap.String("app_id", appID),
 		zap.Int("count", len(envVars)),
+		zap.Stringer("restart", policy),
 	)
 
-	writeJSON(w, http.StatusOK, map[string]interface{}{
-		"message":  "Environment variables updated",
-		"env_vars": app.EnvVars,
-	})
+	response := envChangeResponse("Environment variables updated", app, policy, restartErr)
+	response["env_vars"] = app.EnvVars
+	writeJSON(w, http.StatusOK, response)
 }
 
 // DeleteEnvVar deletes an environment variable
@@ -407,16 +974,69 @@ func (h *AppHandler) DeleteEnvVar(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	policy, ok := envRestartPolicy(r, app)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "restart must be immediate, rolling or next_deploy")
+		return
+	}
+
+	if h.appStore != nil {
+		if err := h.appStore.DeleteEnvVar(r.Context(), app.ID, key); err != nil {
+			h.logger.Error("Failed to delete env var", zap.String("app_id", appID), zap.Error(err))
+			writeError(w, http.StatusInternalServerError, "Failed to delete environment variable")
+			return
+		}
+	}
+	app = copyApp(app)
 	app.DeleteEnvVar(key)
+	h.replaceApp(app)
+	restartErr := h.applyEnvChange(r.Context(), app, policy)
 
 	h.logger.Info("Env var deleted",
 		zap.String("app_id", appID),
 		zap.String("key", key),
+		zap.Stringer("restart", policy),
 	)
 
-	writeJSON(w, http.StatusOK, map[string]string{
-		"message": "Environment variable deleted",
-	})
+	writeJSON(w, http.StatusOK, envChangeResponse("Environment variable deleted", app, policy, restartErr))
+}
+
+// envRestartPolicy returns the restart policy for an env change: the restart query
+// parameter, or else the app's own policy
+func envRestartPolicy(r *http.Request, app *domain.App) (domain.EnvRestartPolicy, bool) {
+	if restart := r.URL.Query().Get("restart"); restart != "" {
+		return domain.ParseEnvRestartPolicy(restart)
+	}
+	return app.EnvRestartPolicy, true
+}
+
+// applyEnvChange saves an env var or secret change, marks running containers stale and
+// recreates them as policy asks. A failed restart leaves the app pending restart.
+func (h *AppHandler) applyEnvChange(ctx context.Context, app *domain.App, policy domain.EnvRestartPolicy) error {
+	app.MarkPendingRestart()
+	var err error
+	if policy != domain.EnvRestartNextDeploy && app.HasPendingRestart() {
+		err = h.orchestrator.Recreate(ctx, app, policy == domain.EnvRestartRolling)
+		if err != nil {
+			h.logger.Warn("Failed to restart app after env change", zap.String("app_id", app.ID.String()), zap.Error(err))
+		}
+		h.RefreshRoute(app.ID)
+	}
+	h.saveApp(ctx, app)
+	return err
+}
+
+// envChangeResponse describes the outcome of an env var or secret change
+func envChangeResponse(message string, app *domain.App, policy domain.EnvRestartPolicy, restartErr error) map[string]interface{} {
+	response := map[string]interface{}{
+		"message":         message,
+		"restart":         policy.String(),
+		"pending_restart": app.HasPendingRestart(),
+	}
+	if restartErr != nil {
+		response["restart_error"] = restartErr.Error()
+	}
+	return response
 }
 
 // Logs streams application logs
@@ -450,13 +1070,25 @@ func (h *AppHandler) getApp(idStr string) (*domain.App, error) {
 	if err != nil {
 		return nil, fmt.Errorf("invalid app ID format: %w", err)
 	}
-	app, exists := h.apps[id]
+	app, exists := h.FindApp(id)
 	if !exists {
-		return nil, fmt.Errorf("app not found: %s", idStr)
+		return nil, fmt.Errorf("app %s %w", idStr, domain.ErrNotFound)
 	}
 	return app, nil
 }
 
+// saveApp caches and persists an app change, logging rather than failing the request
+// since the change has already been applied to containers or routes
+func (h *AppHandler) saveApp(ctx context.Context, app *domain.App) {
+	h.replaceApp(app)
+	if h.appStore == nil {
+		return
+	}
+	if err := h.appStore.Update(ctx, app); err != nil {
+		h.logger.Warn("Failed to persist app", zap.String("app_id", app.ID.String()), zap.Error(err))
+	}
+}
+
 func (h *AppHandler) appToResponse(app *domain.App) AppResponse {
 	response := AppResponse{
 		ID:             app.ID.String(),
@@ -468,20 +1100,221 @@ func (h *AppHandler) appToResponse(app *domain.App) AppResponse {
 		TargetReplicas: app.TargetReplicas,
 		CurrentImageID: app.CurrentImageID,
 		EnvVars:        app.EnvVars,
+		Labels:         app.Labels,
 		ExposedPort:    app.ExposedPort,
 		MemoryLimit:    app.MemoryLimit,
 		CPUQuota:       app.CPUQuota,
+		RestartPolicy:  app.RestartPolicy,
+		NoFileLimit:    app.NoFileLimit,
+		Tmpfs:          app.Tmpfs,
+		ShmSize:        app.ShmSize,
+		Sysctls:        app.Sysctls,
+		Command:        app.Command,
+		Volumes:        app.Volumes,
+		NetworkAliases: app.NetworkAliases,
+		SmokeChecks:    app.SmokeChecks,
+		CORS:           app.CORS,
+		Protected:      app.IsProtected(),
+		StreamingMode:  string(app.StreamingMode),
+		Pin:            app.Pin,
+		Lockdown:       app.Lockdown,
+		CustomDomains:  app.CustomDomains,
 		CreatedAt:      app.CreatedAt.Format("2006-01-02T15:04:05Z"),
 		UpdatedAt:      app.UpdatedAt.Format("2006-01-02T15:04:05Z"),
 	}
 
-	if app.Status == domain.AppStatusRunning {
+	response.EnvRestartPolicy = app.EnvRestartPolicy.String()
+	response.PendingRestart = app.HasPendingRestart()
+	response.RequireApproval = app.RequireApproval
+	response.GitProvider = app.GitProvider
+	if response.GitProvider == "" {
+		response.GitProvider = domain.GitProviderGitHub
+	}
+	response.GitRepoURL = app.GitRepoURL
+	response.GitBranch = app.GitBranch
+	response.AutoDeploy = app.AutoDeploy
+	response.DeployTrigger = string(app.EffectiveDeployTrigger())
+	response.TagPattern = app.TagPattern
+	response.RequireChecks = app.RequireChecks
+	response.GitHubWebhook = app.GitHubWebhook
+
+	if app.StreamingMode != domain.StreamingNone {
+		response.StreamIdleTimeout = app.EffectiveStreamIdleTimeout()
+	}
+
+	if len(app.Secrets) > 0 {
+		response.Secrets = make(map[string]string, len(app.Secrets))
+		for key := range app.Secrets {
+			response.Secrets[key] = domain.SecretMask
+		}
+	}
+
+	if app.LastExit != nil {
+		response.LastExit = exitToResponse(*app.LastExit, app.MemoryLimit)
+	}
+
+	if app.TeamID != nil {
+		response.TeamID = app.TeamID.String()
+	}
+	if app.ProjectID != nil {
+		response.ProjectID = app.ProjectID.String()
+	}
+
+	// Apps without an exposed port only serve other apps on the shared network
+	if app.Status == domain.AppStatusRunning && app.ExposedPort > 0 {
 		response.URL = h.router.GetAppURL(app)
 	}
 
 	return response
 }
 
+// appReplicas builds router replicas from the IPs of the app's tracked containers
+func (h *AppHandler) appReplicas(ctx context.Context, app *domain.App) []router.Replica {
+	containerIDs := h.orchestrator.GetAppContainers(app.ID)
+	ips := h.orchestrator.ContainerIPs(ctx, app.ID)
+
+	replicas := make([]router.Replica, 0, len(containerIDs))
+	for _, containerID := range containerIDs {
+		ip, ok := ips[containerID]
+		if !ok {
+			continue
+		}
+		replicas = append(replicas, router.Replica{
+			ContainerID: containerID,
+			IPAddress:   ip,
+			Port:        app.ExposedPort,
+			Weight:      1,
+		})
+	}
+	return replicas
+}
+
+// RefreshRoute re-resolves container IPs for an app and updates its route
+// Called after restarts and scaling, where containers may come back with new addresses
+func (h *AppHandler) RefreshRoute(appID uuid.UUID) {
+	app, exists := h.FindApp(appID)
+	if !exists {
+		return
+	}
+	if _, routed := h.router.GetRoute(appID); !routed {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := h.router.UpdateReplicas(ctx, appID, h.appReplicas(ctx, app)); err != nil {
+		h.logger.Warn("Failed to refresh route", zap.String("app_id", appID.String()), zap.Error(err))
+	}
+}
+
+// RecordContainerExit stores a container exit reported by the orchestrator on its app
+func (h *AppHandler) RecordContainerExit(appID uuid.UUID, exit domain.ContainerExit) {
+	app, exists := h.FindApp(appID)
+	if !exists {
+		return
+	}
+	h.mu.Lock()
+	app.RecordExit(exit)
+	h.mu.Unlock()
+	h.saveApp(context.Background(), app)
+
+	message := fmt.Sprintf("Container %s exited with code %d", exit.ContainerID, exit.ExitCode)
+	if exit.OOMKilled {
+		message += " (OOM killed)"
+	}
+	h.recordIncidentEvent(appID, domain.IncidentEventContainerExit, message, nil)
+}
+
+// exitToResponse adds the actionable hint to a container exit
+func exitToResponse(exit domain.ContainerExit, memoryLimit int64) *ExitResponse {
+	return &ExitResponse{ContainerExit: exit, Hint: exit.Hint(memoryLimit)}
+}
+
+// applyRuntimeOptions sets any provided runtime options on the app and validates the result
+func applyRuntimeOptions(app *domain.App, restartPolicy string, noFileLimit int64, tmpfs map[string]string, shmSize int64, sysctls map[string]string) error {
+	if restartPolicy != "" {
+		app.RestartPolicy = restartPolicy
+	}
+	if noFileLimit != 0 {
+		app.NoFileLimit = noFileLimit
+	}
+	if tmpfs != nil {
+		app.Tmpfs = tmpfs
+	}
+	if shmSize != 0 {
+		app.ShmSize = shmSize
+	}
+	if sysctls != nil {
+		app.Sysctls = sysctls
+	}
+	return app.ValidateRuntimeOptions()
+}
+
+// FindApp returns an app by ID
+func (h *AppHandler) FindApp(appID uuid.UUID) (*domain.App, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	app, exists := h.apps[appID]
+	return app, exists
+}
+
+// ListApps returns all known apps
+func (h *AppHandler) ListApps() []*domain.App {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	apps := make([]*domain.App, 0, len(h.apps))
+	for _, app := range h.apps {
+		apps = append(apps, app)
+	}
+	return apps
+}
+
+// cacheApp adds a created or loaded app to the apps served from memory
+func (h *AppHandler) cacheApp(app *domain.App) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.apps[app.ID] = app
+}
+
+// replaceApp makes a changed copy of an app the one served from memory, unless the app
+// was deleted meanwhile
+func (h *AppHandler) replaceApp(app *domain.App) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, cached := h.apps[app.ID]; cached {
+		h.linkProjectEnv(app)
+		h.apps[app.ID] = app
+	}
+}
+
+// linkProjectEnv points an app at its project's shared env vars. h.mu must be held.
+func (h *AppHandler) linkProjectEnv(app *domain.App) {
+	if app.ProjectID == nil {
+		return
+	}
+	if project, ok := h.projects[*app.ProjectID]; ok {
+		app.ProjectEnv = project.EnvVars
+	}
+}
+
+// copyApp returns a copy of a cached app to change and swap in with replaceApp, so
+// reloads and readers never see the cached app change under them. The maps changed in
+// place are copied too.
+func copyApp(app *domain.App) *domain.App {
+	c := *app
+	c.EnvVars = maps.Clone(app.EnvVars)
+	c.Labels = maps.Clone(app.Labels)
+	return &c
+}
+
+// uncacheApp drops a deleted app from the apps served from memory
+func (h *AppHandler) uncacheApp(id uuid.UUID) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.apps, id)
+}
+
 // UpdateAppImage updates an app's current image (called by build handler on success)
 func (h *AppHandler) UpdateAppImage(appID string, imageID, imageTag string) {
 	id, err := uuid.Parse(appID)
@@ -490,13 +1323,24 @@ func (h *AppHandler) UpdateAppImage(appID string, imageID, imageTag string) {
 		return
 	}
 
-	app, exists := h.apps[id]
+	app, exists := h.FindApp(id)
 	if !exists {
 		h.logger.Warn("UpdateAppImage: app not found", zap.String("app_id", appID))
 		return
 	}
 
+	if err := app.CheckAutomaticAction(); err != nil {
+		h.logger.Warn("Skipping image update for pinned deployment",
+			zap.String("app_id", appID),
+			zap.String("image_tag", imageTag),
+			zap.Error(err),
+		)
+		return
+	}
+
+	app = copyApp(app)
 	app.UpdateImage(imageTag)
+	h.saveApp(context.Background(), app)
 	h.logger.Info("App image updated after build",
 		zap.String("app_id", appID),
 		zap.String("image_tag", imageTag),
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// Note: AppHandler and NewAppHandler are defined in app_handler.go
+// This file adds per-app HSTS policy routes to the existing AppHandler
+
+// GetHSTS returns an app's HSTS policy
+func (h *AppHandler) GetHSTS(w http.ResponseWriter, r *http.Request) {
+	app, err := h.getApp(chi.URLParam(r, "appId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, h.hstsResponse(app))
+}
+
+// SetHSTS replaces an app's HSTS policy and applies it to the live route
+func (h *AppHandler) SetHSTS(w http.ResponseWriter, r *http.Request) {
+	app, err := h.getApp(chi.URLParam(r, "appId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	var policy domain.HSTSPolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	policy.Normalize()
+	if err := policy.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.applyHSTS(r, app, &policy); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to update route")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, h.hstsResponse(app))
+}
+
+// DeleteHSTS removes an app's HSTS policy
+func (h *AppHandler) DeleteHSTS(w http.ResponseWriter, r *http.Request) {
+	app, err := h.getApp(chi.URLParam(r, "appId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	if err := h.applyHSTS(r, app, nil); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to update route")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, h.hstsResponse(app))
+}
+
+// applyHSTS stores the policy on the app and updates its route if one is active
+func (h *AppHandler) applyHSTS(r *http.Request, app *domain.App, policy *domain.HSTSPolicy) error {
+	previous := app.HSTS
+	app.HSTS = policy
+	if err := h.reapplyRoute(r.Context(), app); err != nil {
+		app.HSTS = previous
+		h.logger.Error("Failed to apply HSTS policy", zap.Error(err), zap.String("app_id", app.ID.String()))
+		return err
+	}
+	app.UpdatedAt = time.Now().UTC()
+	h.saveApp(r.Context(), app)
+
+	h.logger.Info("App HSTS policy updated",
+		zap.String("app_id", app.ID.String()),
+		zap.Bool("enabled", policy != nil),
+	)
+	return nil
+}
+
+// hstsResponse reports the policy and whether it takes effect; browsers ignore HSTS over plain HTTP
+func (h *AppHandler) hstsResponse(app *domain.App) map[string]interface{} {
+	resp := map[string]interface{}{
+		"enabled":       app.HSTS != nil,
+		"https_enabled": h.router.HTTPSEnabled(),
+	}
+	if app.HSTS != nil {
+		resp["policy"] = app.HSTS
+	}
+	return resp
+}
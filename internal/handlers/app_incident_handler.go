@@ -0,0 +1,337 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// Note: AppHandler and NewAppHandler are defined in app_handler.go
+// This file adds incident mode to the existing AppHandler
+
+// maxIncidentTitleLength matches the incidents.title column
+const maxIncidentTitleLength = 255
+
+// IncidentStore persists incident records
+type IncidentStore interface {
+	Create(ctx context.Context, inc *domain.Incident) error
+	Update(ctx context.Context, inc *domain.Incident) error
+}
+
+// OpenIncidentRequest represents a request to lock down an app
+type OpenIncidentRequest struct {
+	Title     string              `json:"title"`
+	Lockdown  domain.LockdownMode `json:"lockdown,omitempty"` // defaults to maintenance
+	RateLimit *domain.RateLimit   `json:"rate_limit,omitempty"`
+	Pin       *bool               `json:"pin,omitempty"`       // pin the current deployment, default true
+	LogLines  int                 `json:"log_lines,omitempty"` // per container, default 200
+}
+
+// IncidentEventRequest represents a timeline note or resolution message
+type IncidentEventRequest struct {
+	Message string `json:"message"`
+}
+
+// SetIncidentStore sets the store incidents are persisted to
+func (h *AppHandler) SetIncidentStore(store IncidentStore) {
+	h.incidentStore = store
+}
+
+// OpenIncident locks down a misbehaving app in one action: it pins the current
+// deployment, restricts traffic at the router, captures diagnostics and opens an
+// incident whose timeline is tracked until it is resolved
+func (h *AppHandler) OpenIncident(w http.ResponseWriter, r *http.Request) {
+	app, err := h.getApp(chi.URLParam(r, "appId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	if open := h.openIncident(app.ID); open != nil {
+		writeErrorDetails(w, http.StatusConflict, "App already has an open incident",
+			map[string]interface{}{"incident_id": open.ID.String()})
+		return
+	}
+
+	var req OpenIncidentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	req.Title = strings.TrimSpace(req.Title)
+	if req.Title == "" {
+		req.Title = "Incident on " + app.Slug
+	}
+	if len(req.Title) > maxIncidentTitleLength {
+		writeError(w, http.StatusBadRequest, "title must be at most 255 characters")
+		return
+	}
+	if req.Lockdown == "" {
+		req.Lockdown = domain.LockdownMaintenance
+	}
+	limit, err := domain.ValidateLockdown(req.Lockdown, req.RateLimit)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var actorID uuid.UUID
+	if user := GetUserFromContext(r.Context()); user != nil {
+		actorID = user.ID
+	}
+	actor := &actorID
+
+	inc := domain.NewIncident(app.ID, actorID, req.Title, req.Lockdown)
+
+	if req.Pin == nil || *req.Pin {
+		h.pinForIncident(app, inc, actor)
+	}
+
+	if req.Lockdown != domain.LockdownNone {
+		app.StartLockdown(inc.ID, req.Lockdown, limit)
+		message := "Maintenance lockdown: traffic stopped, the router answers 503"
+		if limit != nil {
+			message = fmt.Sprintf("Rate limit lockdown: %d requests/s per client IP, burst %d", limit.Average, limit.Burst)
+		}
+		if err := h.reapplyRoute(r.Context(), app); err != nil {
+			message += " (route update failed: " + err.Error() + ")"
+		}
+		inc.AddEvent(domain.IncidentEventLockdown, message, actor)
+	}
+
+	inc.Diagnostics = h.orchestrator.Diagnostics(r.Context(), app, req.LogLines)
+	message := fmt.Sprintf("Captured diagnostics from %d containers", len(inc.Diagnostics.Containers))
+	if n := len(inc.Diagnostics.Errors); n > 0 {
+		message += fmt.Sprintf(" with %d errors", n)
+	}
+	inc.AddEvent(domain.IncidentEventDiagnostics, message, actor)
+
+	h.saveApp(r.Context(), app) // pin and lockdown
+	h.incidents[inc.ID] = inc
+	if h.incidentStore != nil {
+		if err := h.incidentStore.Create(r.Context(), inc); err != nil {
+			h.logger.Warn("Failed to persist incident", zap.String("incident_id", inc.ID.String()), zap.Error(err))
+		}
+	}
+
+	h.logger.Warn("Incident opened",
+		zap.String("app_id", app.ID.String()),
+		zap.String("incident_id", inc.ID.String()),
+		zap.String("lockdown", string(req.Lockdown)),
+	)
+
+	writeJSON(w, http.StatusCreated, inc)
+}
+
+// ListIncidents returns an app's incidents, newest first, without diagnostics
+func (h *AppHandler) ListIncidents(w http.ResponseWriter, r *http.Request) {
+	app, err := h.getApp(chi.URLParam(r, "appId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	incidents := make([]domain.Incident, 0)
+	for _, inc := range h.incidents {
+		if inc.AppID == app.ID {
+			summary := *inc
+			summary.Diagnostics = nil
+			incidents = append(incidents, summary)
+		}
+	}
+	sort.Slice(incidents, func(i, j int) bool {
+		return incidents[i].OpenedAt.After(incidents[j].OpenedAt)
+	})
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"incidents": incidents,
+		"total":     len(incidents),
+	})
+}
+
+// GetIncident returns an incident with its diagnostics and full timeline
+func (h *AppHandler) GetIncident(w http.ResponseWriter, r *http.Request) {
+	inc, ok := h.getIncident(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, inc)
+}
+
+// AddIncidentNote appends a note to an incident's timeline
+func (h *AppHandler) AddIncidentNote(w http.ResponseWriter, r *http.Request) {
+	inc, ok := h.getIncident(w, r)
+	if !ok {
+		return
+	}
+
+	var req IncidentEventRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	req.Message = strings.TrimSpace(req.Message)
+	if req.Message == "" {
+		writeError(w, http.StatusBadRequest, "message is required")
+		return
+	}
+
+	event := inc.AddEvent(domain.IncidentEventNote, req.Message, requestActor(r))
+	h.saveIncident(r.Context(), inc)
+
+	writeJSON(w, http.StatusCreated, event)
+}
+
+// ResolveIncident lifts the app's lockdown, releases the pin the incident placed
+// and closes the incident
+func (h *AppHandler) ResolveIncident(w http.ResponseWriter, r *http.Request) {
+	inc, ok := h.getIncident(w, r)
+	if !ok {
+		return
+	}
+	if !inc.IsOpen() {
+		writeError(w, http.StatusConflict, "Incident is already resolved")
+		return
+	}
+
+	var req IncidentEventRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	var actorID uuid.UUID
+	if user := GetUserFromContext(r.Context()); user != nil {
+		actorID = user.ID
+	}
+
+	if app, exists := h.FindApp(inc.AppID); exists {
+		if app.Lockdown != nil && app.Lockdown.IncidentID == inc.ID {
+			app.EndLockdown()
+			message := "Lockdown lifted, normal traffic restored"
+			if err := h.reapplyRoute(r.Context(), app); err != nil {
+				message += " (route update failed: " + err.Error() + ")"
+			}
+			inc.AddEvent(domain.IncidentEventLockdown, message, &actorID)
+		}
+		if inc.PinnedDeploymentID != nil && app.IsPinned() && app.Pin.DeploymentID == *inc.PinnedDeploymentID {
+			app.Unpin()
+			h.orchestrator.SetDeploymentPinned(*inc.PinnedDeploymentID, false)
+			inc.AddEvent(domain.IncidentEventPinned, "Deployment unpinned", &actorID)
+		}
+		h.saveApp(r.Context(), app)
+	}
+
+	inc.Resolve(actorID, strings.TrimSpace(req.Message))
+	h.saveIncident(r.Context(), inc)
+
+	h.logger.Info("Incident resolved",
+		zap.String("app_id", inc.AppID.String()),
+		zap.String("incident_id", inc.ID.String()),
+	)
+
+	writeJSON(w, http.StatusOK, inc)
+}
+
+// pinForIncident pins the app's current deployment unless it is already pinned
+func (h *AppHandler) pinForIncident(app *domain.App, inc *domain.Incident, actor *uuid.UUID) {
+	if app.IsPinned() {
+		inc.AddEvent(domain.IncidentEventPinned, "Deployment already pinned: "+app.Pin.Reason, actor)
+		return
+	}
+	deployment, ok := h.orchestrator.CurrentDeployment(app.ID)
+	if !ok {
+		inc.AddEvent(domain.IncidentEventPinned, "No successful deployment to pin", actor)
+		return
+	}
+
+	reason := "Incident: " + inc.Title
+	if len(reason) > maxPinReasonLength {
+		reason = reason[:maxPinReasonLength]
+	}
+	app.PinDeployment(deployment.ID, *actor, reason)
+	h.orchestrator.SetDeploymentPinned(deployment.ID, true)
+	inc.PinnedDeploymentID = &deployment.ID
+	inc.AddEvent(domain.IncidentEventPinned, "Pinned deployment "+deployment.ID.String(), actor)
+}
+
+// reapplyRoute rewrites the app's route so the router picks up a lockdown or domain change
+func (h *AppHandler) reapplyRoute(ctx context.Context, app *domain.App) error {
+	if _, routed := h.router.GetRoute(app.ID); !routed {
+		return nil
+	}
+	return h.router.AddRoute(ctx, app, h.appReplicas(ctx, app))
+}
+
+// getIncident resolves the incident in the URL, writing a 404 if it doesn't belong to the app
+func (h *AppHandler) getIncident(w http.ResponseWriter, r *http.Request) (*domain.Incident, bool) {
+	app, err := h.getApp(chi.URLParam(r, "appId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "App not found")
+		return nil, false
+	}
+	id, err := uuid.Parse(chi.URLParam(r, "incidentId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Incident not found")
+		return nil, false
+	}
+	inc, exists := h.incidents[id]
+	if !exists || inc.AppID != app.ID {
+		writeError(w, http.StatusNotFound, "Incident not found")
+		return nil, false
+	}
+	return inc, true
+}
+
+// openIncident returns the app's open incident, if any
+func (h *AppHandler) openIncident(appID uuid.UUID) *domain.Incident {
+	for _, inc := range h.incidents {
+		if inc.AppID == appID && inc.IsOpen() {
+			return inc
+		}
+	}
+	return nil
+}
+
+// recordIncidentEvent adds a platform event to the app's open incident, if any
+func (h *AppHandler) recordIncidentEvent(appID uuid.UUID, kind, message string, actorID *uuid.UUID) {
+	inc := h.openIncident(appID)
+	if inc == nil {
+		return
+	}
+	inc.AddEvent(kind, message, actorID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	h.saveIncident(ctx, inc)
+}
+
+// requestActor returns the authenticated user's ID for timeline entries, or nil
+func requestActor(r *http.Request) *uuid.UUID {
+	if user := GetUserFromContext(r.Context()); user != nil {
+		return &user.ID
+	}
+	return nil
+}
+
+// saveIncident persists an incident update, logging rather than failing the request
+func (h *AppHandler) saveIncident(ctx context.Context, inc *domain.Incident) {
+	if h.incidentStore == nil {
+		return
+	}
+	if err := h.incidentStore.Update(ctx, inc); err != nil {
+		h.logger.Warn("Failed to persist incident", zap.String("incident_id", inc.ID.String()), zap.Error(err))
+	}
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// Note: AppHandler and NewAppHandler are defined in app_handler.go
+// This file adds maintenance mode to the existing AppHandler
+
+// defaultMaintenanceMessage is shown when maintenance is enabled without a message
+const defaultMaintenanceMessage = "We're performing scheduled maintenance and will be back shortly."
+
+// defaultMaintenancePage is served when an app has no custom maintenance HTML
+var defaultMaintenancePage = template.Must(template.New("maintenance").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{ .Name }} - Maintenance</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", sans-serif; background: #f5f5f7; color: #1d1d1f; display: flex; align-items: center; justify-content: center; min-height: 100vh; margin: 0; }
+main { max-width: 32rem; padding: 2rem; text-align: center; }
+h1 { font-size: 1.5rem; }
+</style>
+</head>
+<body>
+<main>
+<h1>{{ .Name }} is down for maintenance</h1>
+<p>{{ .Message }}</p>
+</main>
+</body>
+</html>
+`))
+
+// MaintenanceRequest turns maintenance mode on or off
+type MaintenanceRequest struct {
+	Enabled    bool   `json:"enabled"`
+	HTML       string `json:"html,omitempty"` // full page replacing the default
+	Message    string `json:"message,omitempty"`
+	RetryAfter int    `json:"retry_after,omitempty"`
+}
+
+// GetMaintenance reports whether an app is in maintenance mode
+func (h *AppHandler) GetMaintenance(w http.ResponseWriter, r *http.Request) {
+	app, err := h.getApp(chi.URLParam(r, "appId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, maintenanceResponse(app))
+}
+
+// SetMaintenance swaps the app's route to the maintenance page, or back to its
+// containers when disabled. The containers keep running either way.
+func (h *AppHandler) SetMaintenance(w http.ResponseWriter, r *http.Request) {
+	app, err := h.getApp(chi.URLParam(r, "appId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	var req MaintenanceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	var page *domain.MaintenancePage
+	if req.Enabled {
+		page = &domain.MaintenancePage{
+			HTML:       req.HTML,
+			Message:    req.Message,
+			RetryAfter: req.RetryAfter,
+			Since:      time.Now().UTC(),
+		}
+		if err := page.Validate(); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		// Keep the original start time when only the page changes
+		if app.InMaintenance() {
+			page.Since = app.MaintenancePage.Since
+		}
+	}
+
+	previous := app.MaintenancePage
+	app.MaintenancePage = page
+	if err := h.reapplyRoute(r.Context(), app); err != nil {
+		app.MaintenancePage = previous
+		h.logger.Error("Failed to apply maintenance mode", zap.Error(err), zap.String("app_id", app.ID.String()))
+		writeError(w, http.StatusInternalServerError, "Failed to update route")
+		return
+	}
+	app.UpdatedAt = time.Now().UTC()
+	h.saveApp(r.Context(), app)
+
+	h.logger.Info("App maintenance mode updated",
+		zap.String("app_id", app.ID.String()),
+		zap.Bool("enabled", req.Enabled),
+	)
+
+	writeJSON(w, http.StatusOK, maintenanceResponse(app))
+}
+
+// ServeMaintenancePage renders an app's maintenance page. Traefik rewrites every request
+// to an app in maintenance to this path, so it is public.
+func (h *AppHandler) ServeMaintenancePage(w http.ResponseWriter, r *http.Request) {
+	app, err := h.getApp(chi.URLParam(r, "appId"))
+	if err != nil || !app.InMaintenance() {
+		http.NotFound(w, r)
+		return
+	}
+	page := app.MaintenancePage
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if page.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(page.RetryAfter))
+	}
+	w.WriteHeader(http.StatusServiceUnavailable)
+
+	if page.HTML != "" {
+		w.Write([]byte(page.HTML))
+		return
+	}
+	message := page.Message
+	if message == "" {
+		message = defaultMaintenanceMessage
+	}
+	defaultMaintenancePage.Execute(w, map[string]string{
+		"Name":    app.Name,
+		"Message": message,
+	})
+}
+
+func maintenanceResponse(app *domain.App) map[string]interface{} {
+	resp := map[string]interface{}{
+		"enabled": app.InMaintenance(),
+	}
+	if app.InMaintenance() {
+		resp["maintenance"] = app.MaintenancePage
+	}
+	return resp
+}
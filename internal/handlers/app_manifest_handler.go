@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+	"github.com/nanopaas/nanopaas/internal/services/manifest"
+)
+
+// Note: AppHandler and NewAppHandler are defined in app_handler.go
+// This file adds app.yaml manifest export and apply to the existing AppHandler
+
+// maxManifestSize bounds an uploaded manifest
+const maxManifestSize = 256 << 10
+
+// Manifest apply actions
+const (
+	ManifestCreated   = "created"
+	ManifestUpdated   = "updated"
+	ManifestUnchanged = "unchanged"
+)
+
+// ManifestApplyResponse is the result of applying a manifest
+type ManifestApplyResponse struct {
+	Action   string            `json:"action"` // created, updated or unchanged
+	DryRun   bool              `json:"dry_run,omitempty"`
+	App      *AppResponse      `json:"app"`
+	Changes  []manifest.Change `json:"changes"`
+	Warnings []string          `json:"warnings,omitempty"`
+}
+
+// ExportManifest returns an app's configuration as an app.yaml manifest, or as JSON
+// with ?format=json
+func (h *AppHandler) ExportManifest(w http.ResponseWriter, r *http.Request) {
+	app, err := h.getApp(chi.URLParam(r, "appId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	m := manifest.FromApp(app)
+	if r.URL.Query().Get("format") == "json" {
+		writeJSON(w, http.StatusOK, m)
+		return
+	}
+	data, err := m.YAML()
+	if err != nil {
+		h.logger.Error("Failed to render manifest", zap.String("app_id", app.ID.String()), zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "Failed to render manifest")
+		return
+	}
+	w.Header().Set("Content-Type", "application/yaml")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// ApplyManifest creates the app a YAML or JSON manifest describes, or updates the app
+// with its slug to match it. With ?dry_run=true it only reports the changes.
+func (h *AppHandler) ApplyManifest(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxManifestSize))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	m, err := manifest.Parse(data)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Not authenticated")
+		return
+	}
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+
+	slug := m.Slug
+	if slug == "" {
+		slug = slugify(m.Name)
+	}
+	for _, app := range h.ListApps() {
+		if app.Slug == slug {
+			if !h.canManageApp(user, app, h.userTeams(r.Context(), user)) {
+				writeError(w, http.StatusForbidden, "Access denied")
+				return
+			}
+			h.updateFromManifest(w, r, user, app, m, dryRun)
+			return
+		}
+	}
+	h.createFromManifest(w, r, user, slug, m, dryRun)
+}
+
+// createFromManifest creates a new app from a manifest
+func (h *AppHandler) createFromManifest(w http.ResponseWriter, r *http.Request, user *domain.User, slug string, m *manifest.Manifest, dryRun bool) {
+	// The slug is also the app's subdomain
+	if err := h.router.ValidateSubdomain(slug); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid slug: "+err.Error())
+		return
+	}
+	app := domain.NewApp(m.Name, slug, user.ID)
+	if err := m.Apply(app); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !allowExternalVolumes(w, user, nil, app.Volumes) {
+		return
+	}
+
+	response := ManifestApplyResponse{
+		Action:   ManifestCreated,
+		DryRun:   dryRun,
+		Changes:  manifest.Diff(nil, manifest.FromApp(app)),
+		Warnings: unsetManifestSecrets(app, m),
+	}
+	if dryRun {
+		appResponse := h.appToResponse(app)
+		response.App = &appResponse
+		writeJSON(w, http.StatusOK, response)
+		return
+	}
+
+	if h.appStore != nil {
+		if err := h.appStore.Create(r.Context(), app); err != nil {
+			h.logger.Error("Failed to store app", zap.String("slug", app.Slug), zap.Error(err))
+			writeDomainError(w, err, "Failed to create app")
+			return
+		}
+	}
+	h.cacheApp(app)
+
+	h.logger.Info("App created from manifest",
+		zap.String("app_id", app.ID.String()),
+		zap.String("slug", app.Slug),
+	)
+	appResponse := h.appToResponse(app)
+	response.App = &appResponse
+	writeJSON(w, http.StatusCreated, response)
+}
+
+// updateFromManifest brings an existing app in line with a manifest
+func (h *AppHandler) updateFromManifest(w http.ResponseWriter, r *http.Request, user *domain.User, app *domain.App, m *manifest.Manifest, dryRun bool) {
+	if key := secretEnvConflict(app, m.Env); key != "" {
+		writeError(w, http.StatusConflict, key+" is a secret; update it through the secrets API")
+		return
+	}
+
+	// Apply the manifest to a copy so a bad manifest or a failed save leaves the app untouched
+	candidate := *app
+	if err := m.Apply(&candidate); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !allowExternalVolumes(w, user, app.Volumes, candidate.Volumes) {
+		return
+	}
+
+	changes := manifest.Diff(manifest.FromApp(app), manifest.FromApp(&candidate))
+	response := ManifestApplyResponse{
+		Action:   ManifestUpdated,
+		DryRun:   dryRun,
+		Changes:  changes,
+		Warnings: unsetManifestSecrets(app, m),
+	}
+	if len(changes) == 0 {
+		response.Action = ManifestUnchanged
+	}
+	if dryRun || len(changes) == 0 {
+		appResponse := h.appToResponse(&candidate)
+		response.App = &appResponse
+		writeJSON(w, http.StatusOK, response)
+		return
+	}
+
+	if needsRestart(changes) {
+		candidate.MarkPendingRestart()
+	}
+	candidate.UpdatedAt = time.Now().UTC()
+	if h.appStore != nil {
+		if err := h.appStore.Update(r.Context(), &candidate); err != nil {
+			h.logger.Error("Failed to store app", zap.String("app_id", app.ID.String()), zap.Error(err))
+			writeDomainError(w, err, "Failed to update app")
+			return
+		}
+	}
+	replicasBefore := app.TargetReplicas
+	app = &candidate
+	h.replaceApp(app)
+	response.Warnings = append(response.Warnings, h.rolloutManifestChanges(r.Context(), app, replicasBefore, changes)...)
+
+	h.logger.Info("App updated from manifest",
+		zap.String("app_id", app.ID.String()),
+		zap.Int("changes", len(changes)),
+	)
+	appResponse := h.appToResponse(app)
+	response.App = &appResponse
+	writeJSON(w, http.StatusOK, response)
+}
+
+// allowExternalVolumes refuses with 403 a manifest mounting external volumes the app
+// didn't already mount, unless an admin applies it: external volumes are outside the
+// app's scope and may hold another app's or NanoPaaS's own data
+func allowExternalVolumes(w http.ResponseWriter, user *domain.User, before, mounts []domain.VolumeMount) bool {
+	added := domain.AddedExternalVolumes(before, mounts)
+	if len(added) == 0 || user.IsAdmin() {
+		return true
+	}
+	writeErrorDetails(w, http.StatusForbidden, "Only admins can mount external volumes",
+		map[string]interface{}{"volumes": added})
+	return false
+}
+
+// rolloutManifestChanges applies saved changes to a live app: routing is re-rendered,
+// replicas are scaled and stale containers are recreated as the app's env restart
+// policy asks
+func (h *AppHandler) rolloutManifestChanges(ctx context.Context, app *domain.App, replicasBefore int, changes []manifest.Change) []string {
+	routing := false
+	for _, c := range changes {
+		if strings.HasPrefix(c.Path, "routing.") {
+			routing = true
+		}
+	}
+
+	var warnings []string
+	if routing {
+		if err := h.reapplyRoute(ctx, app); err != nil {
+			h.logger.Warn("Failed to update route", zap.String("app_id", app.ID.String()), zap.Error(err))
+			warnings = append(warnings, "Failed to update route: "+err.Error())
+		}
+	}
+	if app.Status != domain.AppStatusRunning {
+		return warnings
+	}
+	if app.TargetReplicas != replicasBefore {
+		err := h.orchestrator.Scale(ctx, app, app.TargetReplicas)
+		h.saveApp(ctx, app)
+		if err != nil {
+			warnings = append(warnings, "Scaling failed: "+err.Error())
+		} else {
+			h.RefreshRoute(app.ID)
+		}
+	}
+	if app.HasPendingRestart() {
+		if app.EnvRestartPolicy == domain.EnvRestartNextDeploy {
+			warnings = append(warnings, "Restart or redeploy the app to apply env, resource and runtime changes to its containers")
+		} else if err := h.applyEnvChange(ctx, app, app.EnvRestartPolicy); err != nil {
+			warnings = append(warnings, "Restart failed: "+err.Error())
+		}
+	}
+	return warnings
+}
+
+// needsRestart reports whether changes only reach containers started after them
+func needsRestart(changes []manifest.Change) bool {
+	for _, c := range changes {
+		if strings.HasPrefix(c.Path, "env.") || strings.HasPrefix(c.Path, "resources.") ||
+			(strings.HasPrefix(c.Path, "runtime.") && c.Path != "runtime.env_restart_policy") {
+			return true
+		}
+	}
+	return false
+}
+
+// unsetManifestSecrets warns about secrets a manifest lists that the app does not have
+func unsetManifestSecrets(app *domain.App, m *manifest.Manifest) []string {
+	var warnings []string
+	for _, key := range m.Secrets {
+		if _, ok := app.Secrets[key]; !ok {
+			warnings = append(warnings, "secret "+key+" is not set; set it through the secrets API")
+		}
+	}
+	return warnings
+}
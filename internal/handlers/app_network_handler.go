@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// Note: AppHandler and NewAppHandler are defined in app_handler.go
+// This file adds TCP/UDP exposure to the existing AppHandler
+
+// NetworkRouteRequest selects how a non-HTTP app is exposed
+type NetworkRouteRequest struct {
+	Protocol domain.RouteProtocol `json:"protocol"`
+	SNI      bool                 `json:"sni,omitempty"`
+}
+
+// GetNetworkRoute returns how an app is exposed and the address clients connect to
+func (h *AppHandler) GetNetworkRoute(w http.ResponseWriter, r *http.Request) {
+	app, err := h.getApp(chi.URLParam(r, "appId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, h.networkRouteResponse(app))
+}
+
+// SetNetworkRoute exposes an app over TCP or UDP, allocating a router port unless
+// TCP connections are routed by SNI
+func (h *AppHandler) SetNetworkRoute(w http.ResponseWriter, r *http.Request) {
+	app, err := h.getApp(chi.URLParam(r, "appId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	var req NetworkRouteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	route := &domain.NetworkRoute{Protocol: req.Protocol, SNI: req.SNI}
+	if err := route.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if route.SNI && !h.router.SNIEnabled() {
+		writeError(w, http.StatusBadRequest, "SNI routing requires HTTPS to be enabled on the router")
+		return
+	}
+
+	// A protocol change moves the app to a port from the other pool
+	previous := app.NetworkRoute
+	if previous != nil && previous.Protocol != route.Protocol {
+		h.router.ReleasePorts(app.ID)
+	}
+	if !route.SNI {
+		port, err := h.router.AllocatePort(app.ID, route.Protocol)
+		if err != nil {
+			writeError(w, http.StatusConflict, "Failed to allocate port: "+err.Error())
+			return
+		}
+		route.PublicPort = port
+	} else {
+		h.router.ReleasePorts(app.ID)
+	}
+
+	if err := h.applyNetworkRoute(r, app, route); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to update route")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, h.networkRouteResponse(app))
+}
+
+// DeleteNetworkRoute returns an app to HTTP routing and frees its router port
+func (h *AppHandler) DeleteNetworkRoute(w http.ResponseWriter, r *http.Request) {
+	app, err := h.getApp(chi.URLParam(r, "appId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	if err := h.applyNetworkRoute(r, app, nil); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to update route")
+		return
+	}
+	h.router.ReleasePorts(app.ID)
+
+	writeJSON(w, http.StatusOK, h.networkRouteResponse(app))
+}
+
+// applyNetworkRoute stores the network route on the app and updates its route if one is active
+func (h *AppHandler) applyNetworkRoute(r *http.Request, app *domain.App, route *domain.NetworkRoute) error {
+	previous := app.NetworkRoute
+	app.NetworkRoute = route
+	if err := h.reapplyRoute(r.Context(), app); err != nil {
+		app.NetworkRoute = previous
+		h.logger.Error("Failed to apply network route", zap.Error(err), zap.String("app_id", app.ID.String()))
+		return err
+	}
+	app.UpdatedAt = time.Now().UTC()
+	h.saveApp(r.Context(), app)
+
+	fields := []zap.Field{zap.String("app_id", app.ID.String())}
+	if route != nil {
+		fields = append(fields,
+			zap.String("protocol", string(route.Protocol)),
+			zap.Int("public_port", route.PublicPort),
+			zap.Bool("sni", route.SNI),
+		)
+	}
+	h.logger.Info("App network route updated", fields...)
+	return nil
+}
+
+func (h *AppHandler) networkRouteResponse(app *domain.App) map[string]interface{} {
+	if app.NetworkRoute == nil {
+		return map[string]interface{}{
+			"protocol": "http",
+			"url":      h.router.GetAppURL(app),
+		}
+	}
+	return map[string]interface{}{
+		"protocol":    app.NetworkRoute.Protocol,
+		"sni":         app.NetworkRoute.SNI,
+		"public_port": app.NetworkRoute.PublicPort,
+		"url":         h.router.GetAppURL(app),
+	}
+}
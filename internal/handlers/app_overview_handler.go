@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// Note: AppHandler and NewAppHandler are defined in app_handler.go
+// This file adds the dashboard overview to the existing AppHandler
+
+// OverviewResponse lists the apps on the dashboard
+type OverviewResponse struct {
+	Apps     []*domain.AppOverview `json:"apps"`
+	Statuses map[string]int        `json:"statuses"` // app count per status
+}
+
+// Overview lists the apps the user can access, by name, each with its URL, replica
+// counts, latest build and latest deployment, so the dashboard needs one request. The
+// stored builds and deployments are read in one query; ones tracked in memory that are
+// at least as recent replace them, as they may not be stored yet.
+func (h *AppHandler) Overview(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Not authenticated")
+		return
+	}
+
+	stored := make(map[uuid.UUID]*domain.AppOverview)
+	if h.appStore != nil {
+		rows, err := h.appStore.ListOverview(r.Context())
+		if err != nil {
+			h.logger.Error("Failed to load app overview", zap.Error(err))
+			writeError(w, http.StatusInternalServerError, "Failed to load overview")
+			return
+		}
+		for _, o := range rows {
+			stored[o.AppID] = o
+		}
+	}
+
+	teams := h.userTeams(r.Context(), user)
+	shared := h.collaboratorApps(r.Context(), user)
+
+	overview := make([]*domain.AppOverview, 0)
+	statuses := make(map[string]int)
+	for _, app := range h.ListApps() {
+		if !h.canManageApp(user, app, teams) && !shared[app.ID] {
+			continue
+		}
+		o := &domain.AppOverview{
+			AppID:          app.ID,
+			Name:           app.Name,
+			Slug:           app.Slug,
+			Status:         app.Status,
+			Replicas:       app.Replicas,
+			TargetReplicas: app.TargetReplicas,
+			UpdatedAt:      app.UpdatedAt,
+		}
+		// As in app responses, apps without an exposed port have no URL
+		if app.Status == domain.AppStatusRunning && app.ExposedPort > 0 {
+			o.URL = h.router.GetAppURL(app)
+		}
+		if s := stored[app.ID]; s != nil {
+			o.LatestBuild = s.LatestBuild
+			o.LatestDeployment = s.LatestDeployment
+		}
+		if h.builds != nil {
+			if builds := h.builds.AppBuilds(app.ID); len(builds) > 0 &&
+				(o.LatestBuild == nil || !builds[0].CreatedAt.Before(o.LatestBuild.CreatedAt)) {
+				o.LatestBuild = builds[0].Summary()
+			}
+		}
+		if deployments := h.orchestrator.AppDeployments(app.ID); len(deployments) > 0 &&
+			(o.LatestDeployment == nil || !deployments[0].CreatedAt.Before(o.LatestDeployment.CreatedAt)) {
+			o.LatestDeployment = deployments[0].Summary()
+		}
+		overview = append(overview, o)
+		statuses[string(app.Status)]++
+	}
+
+	sort.Slice(overview, func(i, j int) bool {
+		if overview[i].Name != overview[j].Name {
+			return overview[i].Name < overview[j].Name
+		}
+		return overview[i].AppID.String() < overview[j].AppID.String()
+	})
+	writeJSON(w, http.StatusOK, OverviewResponse{Apps: overview, Statuses: statuses})
+}
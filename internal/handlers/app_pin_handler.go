@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// Note: AppHandler and NewAppHandler are defined in app_handler.go
+// This file adds deployment pinning routes to the existing AppHandler
+
+// maxPinReasonLength bounds the reason shown to blocked callers
+const maxPinReasonLength = 500
+
+// PinDeploymentRequest represents a request to pin an app's current deployment
+type PinDeploymentRequest struct {
+	Reason string `json:"reason"`
+}
+
+// Pin pins the app's current deployment so automatic actions cannot replace it
+func (h *AppHandler) Pin(w http.ResponseWriter, r *http.Request) {
+	app, err := h.getApp(chi.URLParam(r, "appId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	if app.IsPinned() {
+		writePinConflict(w, &domain.PinnedError{Pin: app.Pin})
+		return
+	}
+
+	var req PinDeploymentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		writeError(w, http.StatusBadRequest, "reason is required")
+		return
+	}
+	if len(req.Reason) > maxPinReasonLength {
+		writeError(w, http.StatusBadRequest, "reason must be at most 500 characters")
+		return
+	}
+
+	deployment, ok := h.orchestrator.CurrentDeployment(app.ID)
+	if !ok {
+		writeError(w, http.StatusConflict, "App has no successful deployment to pin")
+		return
+	}
+
+	var pinnedBy uuid.UUID
+	if user := GetUserFromContext(r.Context()); user != nil {
+		pinnedBy = user.ID
+	}
+
+	app.PinDeployment(deployment.ID, pinnedBy, req.Reason)
+	h.orchestrator.SetDeploymentPinned(deployment.ID, true)
+	h.saveApp(r.Context(), app)
+
+	h.logger.Info("Deployment pinned",
+		zap.String("app_id", app.ID.String()),
+		zap.String("deployment_id", deployment.ID.String()),
+		zap.String("reason", req.Reason),
+	)
+
+	writeJSON(w, http.StatusOK, app.Pin)
+}
+
+// Unpin releases the app's deployment pin
+func (h *AppHandler) Unpin(w http.ResponseWriter, r *http.Request) {
+	app, err := h.getApp(chi.URLParam(r, "appId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	if !app.IsPinned() {
+		writeError(w, http.StatusConflict, "App deployment is not pinned")
+		return
+	}
+
+	deploymentID := app.Pin.DeploymentID
+	app.Unpin()
+	h.orchestrator.SetDeploymentPinned(deploymentID, false)
+	h.saveApp(r.Context(), app)
+
+	h.logger.Info("Deployment unpinned",
+		zap.String("app_id", app.ID.String()),
+		zap.String("deployment_id", deploymentID.String()),
+	)
+
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Deployment unpinned"})
+}
+
+// writePinConflict responds 409 with the pin that blocked the action
+func writePinConflict(w http.ResponseWriter, err *domain.PinnedError) {
+	writeErrorDetails(w, http.StatusConflict, "Deployment is pinned: "+err.Pin.Reason, map[string]interface{}{
+		"pin_reason":    err.Pin.Reason,
+		"deployment_id": err.Pin.DeploymentID.String(),
+		"pinned_at":     err.Pin.PinnedAt,
+	})
+}
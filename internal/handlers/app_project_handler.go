@@ -0,0 +1,636 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// Note: AppHandler and NewAppHandler are defined in app_handler.go
+// This file adds projects, groups of apps sharing env vars, to the existing AppHandler
+
+// ProjectStore persists projects
+type ProjectStore interface {
+	Create(ctx context.Context, p *domain.Project) error
+	GetByID(ctx context.Context, id uuid.UUID) (*domain.Project, error)
+	Update(ctx context.Context, p *domain.Project) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	ListAll(ctx context.Context) ([]*domain.Project, error)
+}
+
+// CreateProjectRequest represents a request to create a project
+type CreateProjectRequest struct {
+	TeamID      string            `json:"team_id,omitempty"`
+	Name        string            `json:"name"`
+	Slug        string            `json:"slug"`
+	Description string            `json:"description,omitempty"`
+	EnvVars     map[string]string `json:"env_vars,omitempty"`
+}
+
+// UpdateProjectRequest represents a request to update a project
+type UpdateProjectRequest struct {
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// ProjectAppRequest adds an app to a project
+type ProjectAppRequest struct {
+	AppID string `json:"app_id"`
+}
+
+// ProjectResponse represents a project in API responses
+type ProjectResponse struct {
+	*domain.Project
+	AppIDs []string `json:"app_ids"`
+}
+
+// ProjectAppSummary is an app's row on the project dashboard
+type ProjectAppSummary struct {
+	ID               string             `json:"id"`
+	Name             string             `json:"name"`
+	Slug             string             `json:"slug"`
+	Status           string             `json:"status"`
+	URL              string             `json:"url,omitempty"`
+	Replicas         int                `json:"replicas"`
+	TargetReplicas   int                `json:"target_replicas"`
+	CurrentImageID   string             `json:"current_image_id,omitempty"`
+	PendingRestart   bool               `json:"pending_restart"`
+	LatestDeployment *domain.Deployment `json:"latest_deployment,omitempty"`
+	LastExit         *ExitResponse      `json:"last_exit,omitempty"`
+}
+
+// ProjectDashboardResponse combines a project with the state of its apps
+type ProjectDashboardResponse struct {
+	Project  *domain.Project     `json:"project"`
+	Apps     []ProjectAppSummary `json:"apps"`
+	Statuses map[string]int      `json:"statuses"` // app count per status
+}
+
+// ProjectAppResult is the outcome of a cascade operation for one app
+type ProjectAppResult struct {
+	AppID  string `json:"app_id"`
+	Slug   string `json:"slug"`
+	Result string `json:"result"` // queued, stopped, skipped or failed
+	Reason string `json:"reason,omitempty"`
+}
+
+// SetProjectStore sets the store projects are persisted to
+func (h *AppHandler) SetProjectStore(store ProjectStore) {
+	h.projectStore = store
+}
+
+// loadProjects restores projects from the store. It runs before apps are loaded so
+// each app can inherit its project's env vars.
+func (h *AppHandler) loadProjects(ctx context.Context) error {
+	if h.projectStore == nil {
+		return nil
+	}
+	projects, err := h.projectStore.ListAll(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load projects: %w", err)
+	}
+	for _, p := range projects {
+		h.cacheProject(p)
+	}
+	return nil
+}
+
+// ListProjects returns the projects the user can manage
+func (h *AppHandler) ListProjects(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Not authenticated")
+		return
+	}
+
+	teams := h.userTeams(r.Context(), user)
+	projects := make([]ProjectResponse, 0)
+	for _, p := range h.allProjects() {
+		if h.canManageProject(user, p, teams) {
+			projects = append(projects, h.projectResponse(p))
+		}
+	}
+	sort.Slice(projects, func(i, j int) bool {
+		return projects[i].CreatedAt.Before(projects[j].CreatedAt)
+	})
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"projects": projects,
+		"total":    len(projects),
+	})
+}
+
+// CreateProject creates an empty project
+func (h *AppHandler) CreateProject(w http.ResponseWriter, r *http.Request) {
+	var req CreateProjectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, "Project name is required")
+		return
+	}
+	if req.Slug == "" {
+		req.Slug = slugify(req.Name)
+	}
+	for _, p := range h.allProjects() {
+		if p.Slug == req.Slug {
+			writeError(w, http.StatusConflict, "Project with this slug already exists")
+			return
+		}
+	}
+
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Not authenticated")
+		return
+	}
+	project := domain.NewProject(req.Name, req.Slug, user.ID)
+	project.Description = req.Description
+	for k, v := range req.EnvVars {
+		project.SetEnvVar(k, v)
+	}
+
+	if req.TeamID != "" {
+		teamID, err := uuid.Parse(req.TeamID)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid team_id")
+			return
+		}
+		if !user.IsAdmin() && !h.userTeams(r.Context(), user)[teamID] {
+			writeError(w, http.StatusForbidden, "Not a member of this team")
+			return
+		}
+		project.TeamID = &teamID
+	}
+
+	if h.projectStore != nil {
+		if err := h.projectStore.Create(r.Context(), project); err != nil {
+			h.logger.Error("Failed to create project", zap.Error(err))
+			writeDomainError(w, err, "Failed to create project")
+			return
+		}
+	}
+	h.cacheProject(project)
+
+	h.logger.Info("Project created",
+		zap.String("project_id", project.ID.String()),
+		zap.String("slug", project.Slug),
+	)
+	writeJSON(w, http.StatusCreated, h.projectResponse(project))
+}
+
+// GetProject returns the project dashboard: the project and the state of each of its apps
+func (h *AppHandler) GetProject(w http.ResponseWriter, r *http.Request) {
+	project, ok := h.requireProject(w, r)
+	if !ok {
+		return
+	}
+
+	dashboard := ProjectDashboardResponse{
+		Project:  project,
+		Apps:     make([]ProjectAppSummary, 0),
+		Statuses: make(map[string]int),
+	}
+	for _, app := range h.projectApps(project.ID) {
+		summary := ProjectAppSummary{
+			ID:             app.ID.String(),
+			Name:           app.Name,
+			Slug:           app.Slug,
+			Status:         string(app.Status),
+			Replicas:       app.Replicas,
+			TargetReplicas: app.TargetReplicas,
+			CurrentImageID: app.CurrentImageID,
+			PendingRestart: app.HasPendingRestart(),
+		}
+		if app.Status == domain.AppStatusRunning && app.ExposedPort > 0 {
+			summary.URL = h.router.GetAppURL(app)
+		}
+		if deployments := h.orchestrator.AppDeployments(app.ID); len(deployments) > 0 {
+			summary.LatestDeployment = deployments[0]
+		}
+		if app.LastExit != nil {
+			summary.LastExit = exitToResponse(*app.LastExit, app.MemoryLimit)
+		}
+		dashboard.Apps = append(dashboard.Apps, summary)
+		dashboard.Statuses[string(app.Status)]++
+	}
+
+	writeJSON(w, http.StatusOK, dashboard)
+}
+
+// UpdateProject updates a project's name and description
+func (h *AppHandler) UpdateProject(w http.ResponseWriter, r *http.Request) {
+	project, ok := h.requireProject(w, r)
+	if !ok {
+		return
+	}
+
+	var req UpdateProjectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Name != "" {
+		project.Name = req.Name
+	}
+	if req.Description != "" {
+		project.Description = req.Description
+	}
+
+	if !h.saveProject(w, r, project) {
+		return
+	}
+	writeJSON(w, http.StatusOK, h.projectResponse(project))
+}
+
+// DeleteProject deletes a project. Its apps must be removed from it first.
+func (h *AppHandler) DeleteProject(w http.ResponseWriter, r *http.Request) {
+	project, ok := h.requireProject(w, r)
+	if !ok {
+		return
+	}
+
+	if apps := h.projectApps(project.ID); len(apps) > 0 {
+		writeError(w, http.StatusConflict, fmt.Sprintf("Project still has %d apps; remove them first", len(apps)))
+		return
+	}
+
+	if h.projectStore != nil {
+		if err := h.projectStore.Delete(r.Context(), project.ID); err != nil {
+			h.logger.Error("Failed to delete project", zap.String("project_id", project.ID.String()), zap.Error(err))
+			writeDomainError(w, err, "Failed to delete project")
+			return
+		}
+	}
+	h.uncacheProject(project.ID)
+
+	h.logger.Info("Project deleted", zap.String("project_id", project.ID.String()))
+	writeJSON(w, http.StatusOK, map[string]string{
+		"message": "Project deleted successfully",
+	})
+}
+
+// SetProjectEnvVars sets shared env vars and applies them to every app in the project.
+// The restart query parameter applies to all apps; without it each app's own policy is used.
+func (h *AppHandler) SetProjectEnvVars(w http.ResponseWriter, r *http.Request) {
+	project, ok := h.requireProject(w, r)
+	if !ok {
+		return
+	}
+
+	var envVars map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&envVars); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	for _, app := range h.projectApps(project.ID) {
+		if key := secretEnvConflict(app, envVars); key != "" {
+			writeError(w, http.StatusConflict, fmt.Sprintf("%s is a secret of app %s; rename the shared variable", key, app.Slug))
+			return
+		}
+	}
+	if !validRestartParam(r) {
+		writeError(w, http.StatusBadRequest, "restart must be immediate, rolling or next_deploy")
+		return
+	}
+
+	for k, v := range envVars {
+		project.SetEnvVar(k, v)
+	}
+	if !h.saveProject(w, r, project) {
+		return
+	}
+	results := h.applyProjectEnvChange(r, project)
+
+	h.logger.Info("Project env vars updated",
+		zap.String("project_id", project.ID.String()),
+		zap.Int("count", len(envVars)),
+	)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message":  "Environment variables updated",
+		"env_vars": project.EnvVars,
+		"apps":     results,
+	})
+}
+
+// DeleteProjectEnvVar deletes a shared env var from the project and its apps
+func (h *AppHandler) DeleteProjectEnvVar(w http.ResponseWriter, r *http.Request) {
+	project, ok := h.requireProject(w, r)
+	if !ok {
+		return
+	}
+
+	key := chi.URLParam(r, "key")
+	if key == "" {
+		writeError(w, http.StatusBadRequest, "Key is required")
+		return
+	}
+	if !validRestartParam(r) {
+		writeError(w, http.StatusBadRequest, "restart must be immediate, rolling or next_deploy")
+		return
+	}
+
+	project.DeleteEnvVar(key)
+	if !h.saveProject(w, r, project) {
+		return
+	}
+	results := h.applyProjectEnvChange(r, project)
+
+	h.logger.Info("Project env var deleted",
+		zap.String("project_id", project.ID.String()),
+		zap.String("key", key),
+	)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Environment variable deleted",
+		"apps":    results,
+	})
+}
+
+// AddProjectApp adds an app the user manages to the project. The app picks up the
+// shared env vars on its next restart or deploy.
+func (h *AppHandler) AddProjectApp(w http.ResponseWriter, r *http.Request) {
+	project, ok := h.requireProject(w, r)
+	if !ok {
+		return
+	}
+
+	var req ProjectAppRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	app, ok := h.requireProjectApp(w, r, req.AppID)
+	if !ok {
+		return
+	}
+	if app.ProjectID != nil {
+		if *app.ProjectID == project.ID {
+			writeJSON(w, http.StatusOK, h.appToResponse(app))
+			return
+		}
+		writeError(w, http.StatusConflict, "App already belongs to another project")
+		return
+	}
+
+	if key := secretEnvConflict(app, project.EnvVars); key != "" {
+		writeError(w, http.StatusConflict, key+" is a secret of this app and a shared variable of the project")
+		return
+	}
+
+	app.JoinProject(project)
+	h.applyEnvChange(r.Context(), app, domain.EnvRestartNextDeploy)
+
+	h.logger.Info("App added to project",
+		zap.String("project_id", project.ID.String()),
+		zap.String("app_id", app.ID.String()),
+	)
+	writeJSON(w, http.StatusOK, h.appToResponse(app))
+}
+
+// RemoveProjectApp removes an app from the project. The app drops the shared env vars
+// on its next restart or deploy.
+func (h *AppHandler) RemoveProjectApp(w http.ResponseWriter, r *http.Request) {
+	project, ok := h.requireProject(w, r)
+	if !ok {
+		return
+	}
+
+	app, ok := h.requireProjectApp(w, r, chi.URLParam(r, "appId"))
+	if !ok {
+		return
+	}
+	if app.ProjectID == nil || *app.ProjectID != project.ID {
+		writeError(w, http.StatusNotFound, "App is not in this project")
+		return
+	}
+
+	app.LeaveProject()
+	h.applyEnvChange(r.Context(), app, domain.EnvRestartNextDeploy)
+
+	h.logger.Info("App removed from project",
+		zap.String("project_id", project.ID.String()),
+		zap.String("app_id", app.ID.String()),
+	)
+	writeJSON(w, http.StatusOK, h.appToResponse(app))
+}
+
+// DeployProject redeploys the current image of every app in the project. Apps are
+// deployed one after another in the background; pinned apps, apps without an image and
+// apps busy building or deploying are skipped.
+func (h *AppHandler) DeployProject(w http.ResponseWriter, r *http.Request) {
+	project, ok := h.requireProject(w, r)
+	if !ok {
+		return
+	}
+
+	results := make([]ProjectAppResult, 0)
+	queued := make([]*domain.App, 0)
+	for _, app := range h.projectApps(project.ID) {
+		result := ProjectAppResult{AppID: app.ID.String(), Slug: app.Slug, Result: "queued"}
+		switch {
+		case app.CurrentImageID == "":
+			result.Result, result.Reason = "skipped", "app has no image"
+		case app.IsPinned():
+			result.Result, result.Reason = "skipped", "app is pinned"
+		case !app.CanDeploy():
+			result.Result, result.Reason = "skipped", "app is "+string(app.Status)
+		default:
+			queued = append(queued, app)
+		}
+		results = append(results, result)
+	}
+
+	go func() {
+		for _, app := range queued {
+			h.deployInBackground(app, true)
+		}
+	}()
+
+	h.logger.Info("Project deploy started",
+		zap.String("project_id", project.ID.String()),
+		zap.Int("apps", len(queued)),
+	)
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{
+		"message": "Deployment started",
+		"apps":    results,
+	})
+}
+
+// StopProject stops every app in the project
+func (h *AppHandler) StopProject(w http.ResponseWriter, r *http.Request) {
+	project, ok := h.requireProject(w, r)
+	if !ok {
+		return
+	}
+
+	results := make([]ProjectAppResult, 0)
+	for _, app := range h.projectApps(project.ID) {
+		result := ProjectAppResult{AppID: app.ID.String(), Slug: app.Slug, Result: "stopped"}
+		err := h.orchestrator.Stop(r.Context(), app)
+		h.saveApp(r.Context(), app)
+		if err != nil {
+			h.logger.Warn("Failed to stop project app", zap.String("app_id", app.ID.String()), zap.Error(err))
+			result.Result, result.Reason = "failed", err.Error()
+		} else {
+			h.router.RemoveRoute(r.Context(), app.ID)
+		}
+		results = append(results, result)
+	}
+
+	h.logger.Info("Project stopped", zap.String("project_id", project.ID.String()))
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Project stopped",
+		"apps":    results,
+	})
+}
+
+// requireProject resolves the projectId URL parameter to a project the user can
+// manage, writing the error response otherwise
+func (h *AppHandler) requireProject(w http.ResponseWriter, r *http.Request) (*domain.Project, bool) {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Not authenticated")
+		return nil, false
+	}
+	id, err := uuid.Parse(chi.URLParam(r, "projectId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Project not found")
+		return nil, false
+	}
+	project, exists := h.findProject(id)
+	if !exists {
+		writeError(w, http.StatusNotFound, "Project not found")
+		return nil, false
+	}
+	if !h.canManageProject(user, project, h.userTeams(r.Context(), user)) {
+		writeError(w, http.StatusForbidden, "Access denied")
+		return nil, false
+	}
+	return project, true
+}
+
+// requireProjectApp resolves an app the user can manage, writing the error response otherwise
+func (h *AppHandler) requireProjectApp(w http.ResponseWriter, r *http.Request, appID string) (*domain.App, bool) {
+	app, err := h.getApp(appID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "App not found")
+		return nil, false
+	}
+	user := GetUserFromContext(r.Context())
+	if !h.canManageApp(user, app, h.userTeams(r.Context(), user)) {
+		writeError(w, http.StatusForbidden, "Access denied")
+		return nil, false
+	}
+	return app, true
+}
+
+// canManageProject checks the user owns the project, is an admin or belongs to its team
+func (h *AppHandler) canManageProject(user *domain.User, p *domain.Project, teams map[uuid.UUID]bool) bool {
+	if user.IsAdmin() || p.OwnerID == user.ID {
+		return true
+	}
+	return p.TeamID != nil && teams[*p.TeamID]
+}
+
+// projectApps returns the project's apps, sorted by slug
+func (h *AppHandler) projectApps(projectID uuid.UUID) []*domain.App {
+	apps := make([]*domain.App, 0)
+	for _, app := range h.ListApps() {
+		if app.ProjectID != nil && *app.ProjectID == projectID {
+			apps = append(apps, app)
+		}
+	}
+	sort.Slice(apps, func(i, j int) bool { return apps[i].Slug < apps[j].Slug })
+	return apps
+}
+
+// findProject returns a loaded project by ID
+func (h *AppHandler) findProject(id uuid.UUID) (*domain.Project, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	project, exists := h.projects[id]
+	return project, exists
+}
+
+// allProjects returns the loaded projects
+func (h *AppHandler) allProjects() []*domain.Project {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	projects := make([]*domain.Project, 0, len(h.projects))
+	for _, p := range h.projects {
+		projects = append(projects, p)
+	}
+	return projects
+}
+
+// cacheProject adds a created or loaded project to the projects served from memory
+func (h *AppHandler) cacheProject(p *domain.Project) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.projects[p.ID] = p
+}
+
+// uncacheProject drops a deleted project from the projects served from memory
+func (h *AppHandler) uncacheProject(id uuid.UUID) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.projects, id)
+}
+
+// applyProjectEnvChange re-links each app to the project's env vars and restarts it
+// as the restart query parameter or the app's own policy asks
+func (h *AppHandler) applyProjectEnvChange(r *http.Request, project *domain.Project) []map[string]interface{} {
+	results := make([]map[string]interface{}, 0)
+	for _, app := range h.projectApps(project.ID) {
+		app.JoinProject(project)
+		policy, _ := envRestartPolicy(r, app)
+		err := h.applyEnvChange(r.Context(), app, policy)
+		result := envChangeResponse(app.Slug, app, policy, err)
+		delete(result, "message")
+		result["app_id"] = app.ID.String()
+		results = append(results, result)
+	}
+	return results
+}
+
+// saveProject persists a project change, writing the error response on failure
+func (h *AppHandler) saveProject(w http.ResponseWriter, r *http.Request, project *domain.Project) bool {
+	if h.projectStore == nil {
+		return true
+	}
+	if err := h.projectStore.Update(r.Context(), project); err != nil {
+		h.logger.Error("Failed to persist project", zap.String("project_id", project.ID.String()), zap.Error(err))
+		writeDomainError(w, err, "Failed to update project")
+		return false
+	}
+	return true
+}
+
+func (h *AppHandler) projectResponse(p *domain.Project) ProjectResponse {
+	response := ProjectResponse{Project: p, AppIDs: make([]string, 0)}
+	for _, app := range h.projectApps(p.ID) {
+		response.AppIDs = append(response.AppIDs, app.ID.String())
+	}
+	return response
+}
+
+// validRestartParam checks the optional restart query parameter of an env change
+func validRestartParam(r *http.Request) bool {
+	restart := r.URL.Query().Get("restart")
+	if restart == "" {
+		return true
+	}
+	_, ok := domain.ParseEnvRestartPolicy(restart)
+	return ok
+}
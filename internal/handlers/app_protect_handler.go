@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// Note: AppHandler and NewAppHandler are defined in app_handler.go
+// This file adds basic auth protection routes to the existing AppHandler
+
+// basicAuthUserPattern restricts usernames to characters safe in htpasswd entries
+var basicAuthUserPattern = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,64}$`)
+
+// ProtectAppRequest represents a request to protect an app with basic auth
+type ProtectAppRequest struct {
+	Username string `json:"username,omitempty"` // defaults to the app slug
+}
+
+// ProtectAppResponse returns generated credentials; the password is only shown once
+type ProtectAppResponse struct {
+	Protected bool   `json:"protected"`
+	Username  string `json:"username,omitempty"`
+	Password  string `json:"password,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+// Protect generates basic auth credentials and applies them to the app's route
+func (h *AppHandler) Protect(w http.ResponseWriter, r *http.Request) {
+	app, err := h.getApp(chi.URLParam(r, "appId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	if app.IsProtected() {
+		writeError(w, http.StatusConflict, "App is already protected; rotate or remove the existing credentials")
+		return
+	}
+
+	var req ProtectAppRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	username := req.Username
+	if username == "" {
+		username = app.Slug
+	}
+	if !basicAuthUserPattern.MatchString(username) {
+		writeError(w, http.StatusBadRequest, "username must be 1-64 letters, digits, dots, dashes or underscores")
+		return
+	}
+
+	h.setCredentials(w, r, app, username)
+}
+
+// RotateProtection replaces the app's basic auth password, keeping the username
+func (h *AppHandler) RotateProtection(w http.ResponseWriter, r *http.Request) {
+	app, err := h.getApp(chi.URLParam(r, "appId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	if !app.IsProtected() {
+		writeError(w, http.StatusConflict, "App is not protected")
+		return
+	}
+
+	h.setCredentials(w, r, app, app.BasicAuthUser)
+}
+
+// Unprotect removes basic auth from the app's route
+func (h *AppHandler) Unprotect(w http.ResponseWriter, r *http.Request) {
+	app, err := h.getApp(chi.URLParam(r, "appId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	if !app.IsProtected() {
+		writeJSON(w, http.StatusOK, ProtectAppResponse{Protected: false})
+		return
+	}
+
+	if err := h.applyBasicAuth(r, app, ""); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to update route")
+		return
+	}
+
+	app.BasicAuthUser = ""
+	app.BasicAuthHash = ""
+	app.UpdatedAt = time.Now().UTC()
+	h.saveApp(r.Context(), app)
+
+	h.logger.Info("App protection removed", zap.String("app_id", app.ID.String()))
+	writeJSON(w, http.StatusOK, ProtectAppResponse{Protected: false})
+}
+
+// setCredentials generates a password for username, applies it and returns it to the caller
+func (h *AppHandler) setCredentials(w http.ResponseWriter, r *http.Request, app *domain.App, username string) {
+	password, err := generatePassword()
+	if err != nil {
+		h.logger.Error("Failed to generate password", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "Failed to generate credentials")
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		h.logger.Error("Failed to hash password", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "Failed to generate credentials")
+		return
+	}
+
+	if err := h.applyBasicAuth(r, app, username+":"+string(hash)); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to update route")
+		return
+	}
+
+	app.BasicAuthUser = username
+	app.BasicAuthHash = string(hash)
+	app.UpdatedAt = time.Now().UTC()
+	h.saveApp(r.Context(), app)
+
+	h.logger.Info("App protected with basic auth",
+		zap.String("app_id", app.ID.String()),
+		zap.String("username", username),
+	)
+
+	writeJSON(w, http.StatusOK, ProtectAppResponse{
+		Protected: true,
+		Username:  username,
+		Password:  password,
+		URL:       h.router.GetAppURL(app),
+	})
+}
+
+// applyBasicAuth updates the app's live route, if any, with a basic auth entry
+func (h *AppHandler) applyBasicAuth(r *http.Request, app *domain.App, entry string) error {
+	if _, routed := h.router.GetRoute(app.ID); !routed {
+		return nil
+	}
+	if err := h.router.SetBasicAuth(r.Context(), app.ID, entry); err != nil {
+		h.logger.Error("Failed to apply basic auth", zap.Error(err), zap.String("app_id", app.ID.String()))
+		return err
+	}
+	return nil
+}
+
+// generatePassword returns a random 24-character URL-safe password
+func generatePassword() (string, error) {
+	bytes := make([]byte, 18)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// Note: AppHandler and NewAppHandler are defined in app_handler.go
+// This file adds per-app routing middleware settings to the existing AppHandler
+
+// minBasicAuthPassword is the shortest password accepted for caller-chosen credentials
+const minBasicAuthPassword = 8
+
+// BasicAuthSettings are basic auth credentials set through the routing endpoint
+type BasicAuthSettings struct {
+	Username string `json:"username"`
+	Password string `json:"password,omitempty"` // may be omitted to keep the current password for the same user
+}
+
+// RoutingRequest replaces an app's routing middleware; omitted settings are removed
+type RoutingRequest struct {
+	BasicAuth   *BasicAuthSettings `json:"basic_auth,omitempty"`
+	IPAllowList []string           `json:"ip_allowlist,omitempty"`
+	RateLimit   *domain.RateLimit  `json:"rate_limit,omitempty"`
+}
+
+// RoutingResponse describes the middleware in front of an app
+type RoutingResponse struct {
+	BasicAuth   *BasicAuthSettings `json:"basic_auth,omitempty"` // username only
+	IPAllowList []string           `json:"ip_allowlist"`
+	RateLimit   *domain.RateLimit  `json:"rate_limit,omitempty"`
+	// Set while an incident lockdown's rate limit overrides the app's own
+	LockdownRateLimit *domain.RateLimit `json:"lockdown_rate_limit,omitempty"`
+}
+
+// GetRouting returns the middleware configured in front of an app
+func (h *AppHandler) GetRouting(w http.ResponseWriter, r *http.Request) {
+	app, err := h.getApp(chi.URLParam(r, "appId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, routingResponse(app))
+}
+
+// PreviewRouting renders the proxy configuration the app's current settings produce,
+// without applying it, and reports whether it is valid and already live
+func (h *AppHandler) PreviewRouting(w http.ResponseWriter, r *http.Request) {
+	app, err := h.getApp(chi.URLParam(r, "appId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "App not found")
+		return
+	}
+	if app.ExposedPort <= 0 {
+		writeError(w, http.StatusConflict, "App exposes no port and is not routed")
+		return
+	}
+
+	preview, err := h.router.PreviewRoute(app)
+	if err != nil {
+		h.logger.Error("Failed to render route preview", zap.Error(err), zap.String("app_id", app.ID.String()))
+		writeError(w, http.StatusInternalServerError, "Failed to render route preview")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, preview)
+}
+
+// SetRouting replaces an app's basic auth, IP allowlist and rate limit and applies them to the live route
+func (h *AppHandler) SetRouting(w http.ResponseWriter, r *http.Request) {
+	app, err := h.getApp(chi.URLParam(r, "appId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	var req RoutingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	policy := &domain.RoutingPolicy{
+		IPAllowList: req.IPAllowList,
+		RateLimit:   req.RateLimit,
+	}
+	policy.Normalize()
+	if err := policy.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if policy.IsEmpty() {
+		policy = nil
+	}
+
+	username, hash := "", ""
+	if req.BasicAuth != nil {
+		username = req.BasicAuth.Username
+		if !basicAuthUserPattern.MatchString(username) {
+			writeError(w, http.StatusBadRequest, "basic_auth username must be 1-64 letters, digits, dots, dashes or underscores")
+			return
+		}
+		switch {
+		case req.BasicAuth.Password == "" && app.IsProtected() && app.BasicAuthUser == username:
+			hash = app.BasicAuthHash
+		case len(req.BasicAuth.Password) < minBasicAuthPassword:
+			writeError(w, http.StatusBadRequest, "basic_auth password must be at least 8 characters")
+			return
+		default:
+			generated, err := bcrypt.GenerateFromPassword([]byte(req.BasicAuth.Password), bcrypt.DefaultCost)
+			if err != nil {
+				h.logger.Error("Failed to hash password", zap.Error(err))
+				writeError(w, http.StatusInternalServerError, "Failed to set credentials")
+				return
+			}
+			hash = string(generated)
+		}
+	}
+
+	app.BasicAuthUser = username
+	app.BasicAuthHash = hash
+	app.Routing = policy
+	app.UpdatedAt = time.Now().UTC()
+	h.saveApp(r.Context(), app)
+
+	if err := h.reapplyRoute(r.Context(), app); err != nil {
+		h.logger.Error("Failed to apply routing policy", zap.Error(err), zap.String("app_id", app.ID.String()))
+		writeError(w, http.StatusInternalServerError, "Failed to update route")
+		return
+	}
+
+	h.logger.Info("App routing policy updated",
+		zap.String("app_id", app.ID.String()),
+		zap.Bool("basic_auth", app.IsProtected()),
+		zap.Int("ip_allowlist", len(app.IPAllowList())),
+		zap.Bool("rate_limit", policy != nil && policy.RateLimit != nil),
+	)
+
+	writeJSON(w, http.StatusOK, routingResponse(app))
+}
+
+func routingResponse(app *domain.App) RoutingResponse {
+	resp := RoutingResponse{IPAllowList: []string{}}
+	if app.IsProtected() {
+		resp.BasicAuth = &BasicAuthSettings{Username: app.BasicAuthUser}
+	}
+	if app.Routing != nil {
+		if len(app.Routing.IPAllowList) > 0 {
+			resp.IPAllowList = app.Routing.IPAllowList
+		}
+		resp.RateLimit = app.Routing.RateLimit
+	}
+	if app.InLockdown() && app.Lockdown.RateLimit != nil {
+		resp.LockdownRateLimit = app.Lockdown.RateLimit
+	}
+	return resp
+}
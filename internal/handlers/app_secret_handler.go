@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// SecretSealer encrypts secret values before they are stored
+type SecretSealer interface {
+	Seal(plaintext, additionalData []byte) ([]byte, error)
+}
+
+// SecretResponse describes a secret without its value
+type SecretResponse struct {
+	Key       string    `json:"key"`
+	Value     string    `json:"value"` // always masked
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SecretBox encrypts secret values, and decrypts them to copy them to another app
+type SecretBox interface {
+	SecretSealer
+	Open(sealed, additionalData []byte) ([]byte, error)
+}
+
+// SetSecretSealer enables the secrets API; without a sealer it responds 503
+func (h *AppHandler) SetSecretSealer(sealer SecretBox) {
+	h.secrets = sealer
+}
+
+// ListSecrets returns an app's secret names with masked values
+func (h *AppHandler) ListSecrets(w http.ResponseWriter, r *http.Request) {
+	app, err := h.getApp(chi.URLParam(r, "appId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	secrets := make([]SecretResponse, 0, len(app.Secrets))
+	for _, key := range app.SecretKeys() {
+		secrets = append(secrets, SecretResponse{
+			Key:       key,
+			Value:     domain.SecretMask,
+			UpdatedAt: app.Secrets[key].UpdatedAt,
+		})
+	}
+	writeJSON(w, http.StatusOK, secrets)
+}
+
+// SetSecrets encrypts and stores secrets, replacing plain env vars of the same names.
+// Running containers pick them up as the app's env restart policy, or ?restart=, asks.
+func (h *AppHandler) SetSecrets(w http.ResponseWriter, r *http.Request) {
+	app, err := h.getApp(chi.URLParam(r, "appId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "App not found")
+		return
+	}
+	if h.secrets == nil {
+		writeError(w, http.StatusServiceUnavailable, "Secrets are not configured; set SECRETS_MASTER_KEY")
+		return
+	}
+
+	var values map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&values); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if len(values) == 0 {
+		writeError(w, http.StatusBadRequest, "At least one secret is required")
+		return
+	}
+	policy, ok := envRestartPolicy(r, app)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "restart must be immediate, rolling or next_deploy")
+		return
+	}
+
+	sealed := make(map[string][]byte, len(values))
+	for key, value := range values {
+		if err := domain.ValidateSecret(key, value); err != nil {
+			writeError(w, http.StatusBadRequest, key+": "+err.Error())
+			return
+		}
+		ciphertext, err := h.secrets.Seal([]byte(value), domain.SecretAAD(app.ID, key))
+		if err != nil {
+			h.logger.Error("Failed to encrypt secret", zap.String("app_id", app.ID.String()), zap.String("key", key), zap.Error(err))
+			writeError(w, http.StatusInternalServerError, "Failed to encrypt secret")
+			return
+		}
+		sealed[key] = ciphertext
+	}
+	for key, ciphertext := range sealed {
+		app.SetSecret(key, ciphertext)
+	}
+	restartErr := h.applyEnvChange(r.Context(), app, policy)
+
+	h.logger.Info("Secrets updated",
+		zap.String("app_id", app.ID.String()),
+		zap.Strings("keys", app.SecretKeys()),
+		zap.Stringer("restart", policy),
+	)
+
+	response := envChangeResponse("Secrets updated", app, policy, restartErr)
+	response["keys"] = app.SecretKeys()
+	writeJSON(w, http.StatusOK, response)
+}
+
+// secretEnvConflict returns the first env var name that is already one of the app's
+// secrets, or "" when none is
+func secretEnvConflict(app *domain.App, envVars map[string]string) string {
+	for key := range envVars {
+		if _, ok := app.Secrets[key]; ok {
+			return key
+		}
+	}
+	return ""
+}
+
+// DeleteSecret removes a secret
+func (h *AppHandler) DeleteSecret(w http.ResponseWriter, r *http.Request) {
+	app, err := h.getApp(chi.URLParam(r, "appId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	policy, ok := envRestartPolicy(r, app)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "restart must be immediate, rolling or next_deploy")
+		return
+	}
+	key := chi.URLParam(r, "key")
+	if !app.DeleteSecret(key) {
+		writeError(w, http.StatusNotFound, "Secret not found")
+		return
+	}
+	restartErr := h.applyEnvChange(r.Context(), app, policy)
+
+	h.logger.Info("Secret deleted",
+		zap.String("app_id", app.ID.String()),
+		zap.String("key", key),
+		zap.Stringer("restart", policy),
+	)
+
+	writeJSON(w, http.StatusOK, envChangeResponse("Secret deleted", app, policy, restartErr))
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// Note: AppHandler and NewAppHandler are defined in app_handler.go
+// This file adds sticky session routes to the existing AppHandler
+
+// StickySessionsRequest configures session affinity. Secure defaults to whether
+// apps are served over HTTPS.
+type StickySessionsRequest struct {
+	domain.StickySessionPolicy
+	Secure *bool `json:"secure,omitempty"`
+}
+
+// GetStickySessions returns an app's session affinity settings
+func (h *AppHandler) GetStickySessions(w http.ResponseWriter, r *http.Request) {
+	app, err := h.getApp(chi.URLParam(r, "appId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, stickyResponse(app))
+}
+
+// SetStickySessions enables session affinity on an app's load balancer
+func (h *AppHandler) SetStickySessions(w http.ResponseWriter, r *http.Request) {
+	app, err := h.getApp(chi.URLParam(r, "appId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	var req StickySessionsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	policy := req.StickySessionPolicy
+	policy.Secure = h.router.HTTPSEnabled()
+	if req.Secure != nil {
+		policy.Secure = *req.Secure
+	}
+	policy.Normalize()
+	if err := policy.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.applyStickySessions(r, app, &policy); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to update route")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, stickyResponse(app))
+}
+
+// DeleteStickySessions turns session affinity off
+func (h *AppHandler) DeleteStickySessions(w http.ResponseWriter, r *http.Request) {
+	app, err := h.getApp(chi.URLParam(r, "appId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "App not found")
+		return
+	}
+
+	if err := h.applyStickySessions(r, app, nil); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to update route")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, stickyResponse(app))
+}
+
+// applyStickySessions stores the policy on the app and updates its route if one is active
+func (h *AppHandler) applyStickySessions(r *http.Request, app *domain.App, policy *domain.StickySessionPolicy) error {
+	previous := app.StickySessions
+	app.StickySessions = policy
+	if err := h.reapplyRoute(r.Context(), app); err != nil {
+		app.StickySessions = previous
+		h.logger.Error("Failed to apply sticky sessions", zap.Error(err), zap.String("app_id", app.ID.String()))
+		return err
+	}
+	app.UpdatedAt = time.Now().UTC()
+	h.saveApp(r.Context(), app)
+
+	h.logger.Info("App sticky sessions updated",
+		zap.String("app_id", app.ID.String()),
+		zap.Bool("enabled", policy != nil),
+	)
+	return nil
+}
+
+func stickyResponse(app *domain.App) map[string]interface{} {
+	resp := map[string]interface{}{
+		"enabled": app.StickySessions != nil,
+	}
+	if app.StickySessions != nil {
+		resp["policy"] = app.StickySessions
+	}
+	return resp
+}
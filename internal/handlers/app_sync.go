@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// Note: AppHandler and NewAppHandler are defined in app_handler.go
+// This file refreshes the existing AppHandler's apps and projects after another
+// instance sharing the store changed them
+
+// ReloadApp replaces the cached app with its stored copy, or drops it when it was
+// deleted, then resyncs its containers and route. The stored copy is swapped in, so
+// handlers still holding the previous app never see it change under them.
+func (h *AppHandler) ReloadApp(ctx context.Context, id uuid.UUID) {
+	if h.appStore == nil {
+		return
+	}
+	stored, err := h.appStore.GetByID(ctx, id)
+	if errors.Is(err, domain.ErrNotFound) {
+		h.forgetApp(ctx, id)
+		return
+	}
+	if err != nil {
+		h.logger.Warn("Failed to reload app", zap.String("app_id", id.String()), zap.Error(err))
+		return
+	}
+	h.applyStoredApp(ctx, stored)
+}
+
+// applyStoredApp caches the stored copy of an app and resyncs its containers and route
+func (h *AppHandler) applyStoredApp(ctx context.Context, stored *domain.App) {
+	h.mu.Lock()
+	h.linkProjectEnv(stored)
+	h.apps[stored.ID] = stored
+	h.mu.Unlock()
+	h.syncAppRoute(ctx, stored)
+}
+
+// forgetApp drops an app another instance deleted, with its route and containers
+func (h *AppHandler) forgetApp(ctx context.Context, id uuid.UUID) {
+	h.mu.Lock()
+	app, cached := h.apps[id]
+	delete(h.apps, id)
+	h.mu.Unlock()
+	if !cached {
+		return
+	}
+	h.router.RemoveRoute(ctx, id)
+	// The containers are gone, so adopting them again forgets them
+	if _, err := h.orchestrator.AdoptContainers(ctx, app); err != nil {
+		h.logger.Warn("Failed to resync deleted app containers", zap.String("app_id", id.String()), zap.Error(err))
+	}
+	h.logger.Info("App deleted by another instance", zap.String("app_id", id.String()))
+}
+
+// syncAppRoute adopts a running app's containers and routes to them, and removes the
+// route of an app that isn't running. Unlike restoreApp, it never saves the app: the
+// instance that changed it owns its status.
+func (h *AppHandler) syncAppRoute(ctx context.Context, app *domain.App) {
+	if app.Status != domain.AppStatusRunning {
+		if _, routed := h.router.GetRoute(app.ID); routed {
+			h.router.RemoveRoute(ctx, app.ID)
+		}
+		return
+	}
+
+	adopted, err := h.orchestrator.AdoptContainers(ctx, app)
+	if err != nil {
+		h.logger.Warn("Failed to adopt app containers", zap.String("app_id", app.ID.String()), zap.Error(err))
+		return
+	}
+	if adopted == 0 {
+		return
+	}
+	if err := h.router.AddRoute(ctx, app, h.appReplicas(ctx, app)); err != nil {
+		h.logger.Warn("Failed to update app route", zap.String("app_id", app.ID.String()), zap.Error(err))
+	}
+}
+
+// ReloadProject replaces the cached project with its stored copy, or drops it when it
+// was deleted, and relinks its apps to its shared env vars
+func (h *AppHandler) ReloadProject(ctx context.Context, id uuid.UUID) {
+	if h.projectStore == nil {
+		return
+	}
+	stored, err := h.projectStore.GetByID(ctx, id)
+	if errors.Is(err, domain.ErrNotFound) {
+		h.uncacheProject(id)
+		// The apps left the project; their own change notifications reload them
+		return
+	}
+	if err != nil {
+		h.logger.Warn("Failed to reload project", zap.String("project_id", id.String()), zap.Error(err))
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.projects[id] = stored
+	for appID, app := range h.apps {
+		if app.ProjectID != nil && *app.ProjectID == id {
+			linked := copyApp(app)
+			linked.ProjectEnv = stored.EnvVars
+			h.apps[appID] = linked
+		}
+	}
+}
+
+// Resync reloads every project and app from the store, for when change notifications
+// may have been missed
+func (h *AppHandler) Resync(ctx context.Context) {
+	if h.projectStore != nil {
+		projects, err := h.projectStore.ListAll(ctx)
+		if err != nil {
+			h.logger.Warn("Failed to resync projects", zap.Error(err))
+			return
+		}
+		stored := make(map[uuid.UUID]bool, len(projects))
+		h.mu.Lock()
+		for _, p := range projects {
+			stored[p.ID] = true
+			h.projects[p.ID] = p
+		}
+		for id := range h.projects {
+			if !stored[id] {
+				delete(h.projects, id)
+			}
+		}
+		h.mu.Unlock()
+	}
+
+	if h.appStore == nil {
+		return
+	}
+	apps, err := h.appStore.ListAll(ctx)
+	if err != nil {
+		h.logger.Warn("Failed to resync apps", zap.Error(err))
+		return
+	}
+	stored := make(map[uuid.UUID]bool, len(apps))
+	for _, app := range apps {
+		stored[app.ID] = true
+		h.applyStoredApp(ctx, app)
+	}
+	for _, app := range h.ListApps() {
+		if !stored[app.ID] {
+			h.forgetApp(ctx, app.ID)
+		}
+	}
+	h.logger.Info("Apps resynced", zap.Int("apps", len(apps)))
+}
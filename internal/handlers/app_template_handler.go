@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+	"github.com/nanopaas/nanopaas/internal/services/manifest"
+	"github.com/nanopaas/nanopaas/internal/services/templates"
+)
+
+// Note: AppHandler and NewAppHandler are defined in app_handler.go
+// This file adds the starter template catalog to the existing AppHandler
+
+// TemplateLabel labels apps with the template they were created from
+const TemplateLabel = "nanopaas.template"
+
+// GitBuilder queues image builds from a git repository
+type GitBuilder interface {
+	SubmitGitBuild(r *http.Request, appID uuid.UUID, appSlug, repoURL, branch string, onSuccess func(imageID, imageTag string)) (*domain.Build, error)
+}
+
+// CreateFromTemplateRequest represents a request to create an app from a template
+type CreateFromTemplateRequest struct {
+	Template string `json:"template"`
+	Name     string `json:"name"`
+	Slug     string `json:"slug,omitempty"`
+}
+
+// CreateFromTemplateResponse describes the apps created from a template and the first build
+type CreateFromTemplateResponse struct {
+	Template string         `json:"template"`
+	App      *AppResponse   `json:"app"`
+	Services []AppResponse  `json:"services,omitempty"`
+	Build    *BuildResponse `json:"build,omitempty"`
+	URL      string         `json:"url"`
+	Warnings []string       `json:"warnings,omitempty"`
+}
+
+// SetTemplateCatalog enables the starter templates
+func (h *AppHandler) SetTemplateCatalog(catalog *templates.Catalog) {
+	h.templates = catalog
+}
+
+// SetGitBuilder sets the builder that runs the first build of apps created from templates
+func (h *AppHandler) SetGitBuilder(builder GitBuilder) {
+	h.gitBuilder = builder
+}
+
+// ListTemplates returns the starter template catalog
+func (h *AppHandler) ListTemplates(w http.ResponseWriter, r *http.Request) {
+	if h.templates == nil {
+		writeJSON(w, http.StatusOK, []*templates.Template{})
+		return
+	}
+	writeJSON(w, http.StatusOK, h.templates.List())
+}
+
+// CreateFromTemplate creates an app and its supporting services from a template, then
+// deploys the services and builds the app from the template repo. The app is deployed
+// once its build succeeds.
+func (h *AppHandler) CreateFromTemplate(w http.ResponseWriter, r *http.Request) {
+	var req CreateFromTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if h.templates == nil {
+		writeError(w, http.StatusNotFound, "Template not found")
+		return
+	}
+	tmpl, ok := h.templates.Get(req.Template)
+	if !ok {
+		writeError(w, http.StatusNotFound, "Template not found")
+		return
+	}
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, "App name is required")
+		return
+	}
+	if req.Slug == "" {
+		req.Slug = slugify(req.Name)
+	}
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Not authenticated")
+		return
+	}
+
+	instance, err := tmpl.Instantiate(req.Name, req.Slug)
+	if err != nil {
+		h.logger.Error("Failed to instantiate template", zap.String("template", tmpl.ID), zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "Failed to instantiate template")
+		return
+	}
+
+	// Services come first so they are up by the time the app's build finishes
+	type plannedApp struct {
+		manifest *manifest.Manifest
+		secrets  map[string]string
+		image    string
+	}
+	planned := make([]plannedApp, 0, len(instance.Services)+1)
+	for _, svc := range instance.Services {
+		planned = append(planned, plannedApp{manifest: svc.App, secrets: svc.Secrets, image: svc.Image})
+	}
+	planned = append(planned, plannedApp{manifest: instance.App, secrets: instance.Secrets})
+
+	// Refuse the whole template rather than leave some of its apps behind
+	existing := make(map[string]bool)
+	for _, app := range h.ListApps() {
+		existing[app.Slug] = true
+	}
+	var conflicts []string
+	for _, p := range planned {
+		if err := h.router.ValidateSubdomain(p.manifest.Slug); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid slug: "+err.Error())
+			return
+		}
+		if existing[p.manifest.Slug] {
+			conflicts = append(conflicts, p.manifest.Slug)
+		}
+	}
+	if len(conflicts) > 0 {
+		writeErrorDetails(w, http.StatusConflict, "Apps with these slugs already exist; choose a different slug",
+			map[string]interface{}{"slugs": conflicts})
+		return
+	}
+
+	response := CreateFromTemplateResponse{Template: tmpl.ID}
+	apps := make([]*domain.App, 0, len(planned))
+	for _, p := range planned {
+		app := domain.NewApp(p.manifest.Name, p.manifest.Slug, user.ID)
+		if err := p.manifest.Apply(app); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		app.Labels[TemplateLabel] = tmpl.ID
+		if p.image != "" {
+			// Reachable from the app under its slug, which the template's env refers to
+			app.NetworkAliases = []string{app.Slug}
+			app.UpdateImage(p.image)
+		} else {
+			app.GitRepoURL = tmpl.RepoURL
+			app.GitBranch = tmpl.Branch
+		}
+		warnings, err := h.setTemplateSecrets(app, p.secrets)
+		if err != nil {
+			h.logger.Error("Failed to encrypt secret", zap.String("slug", app.Slug), zap.Error(err))
+			writeError(w, http.StatusInternalServerError, "Failed to encrypt secret")
+			return
+		}
+		response.Warnings = append(response.Warnings, warnings...)
+		apps = append(apps, app)
+	}
+
+	if h.appStore != nil {
+		// The app and its services are stored together or not at all
+		var failed string
+		err := inTx(r.Context(), h.tx, func(ctx context.Context) error {
+			for _, app := range apps {
+				if err := h.appStore.Create(ctx, app); err != nil {
+					failed = app.Slug
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			h.logger.Error("Failed to store app", zap.String("slug", failed), zap.Error(err))
+			writeDomainError(w, err, "Failed to create app "+failed)
+			return
+		}
+	}
+	for _, app := range apps {
+		h.cacheApp(app)
+	}
+	app := apps[len(apps)-1]
+	services := apps[:len(apps)-1]
+
+	go func() {
+		for _, svc := range services {
+			h.deployInBackground(svc, false)
+		}
+	}()
+
+	if h.gitBuilder == nil {
+		response.Warnings = append(response.Warnings, "Builds are not available; build and deploy the app manually")
+	} else {
+		appID := app.ID.String()
+		build, err := h.gitBuilder.SubmitGitBuild(r, app.ID, app.Slug, app.GitRepoURL, app.GitBranch, func(imageID, imageTag string) {
+			h.UpdateAppImage(appID, imageID, imageTag)
+			h.deployInBackground(app, true)
+		})
+		if err != nil {
+			h.logger.Warn("Failed to start template build", zap.String("app_id", appID), zap.Error(err))
+			response.Warnings = append(response.Warnings, "Build could not be started: "+err.Error())
+		} else {
+			response.Build = &BuildResponse{
+				ID:           build.ID.String(),
+				AppID:        appID,
+				Status:       string(build.Status),
+				Source:       string(build.Source),
+				CreatedAt:    build.CreatedAt.Format("2006-01-02T15:04:05Z"),
+				WebSocketURL: fmt.Sprintf("/ws/builds/%s/logs", build.ID.String()),
+			}
+		}
+	}
+
+	h.logger.Info("App created from template",
+		zap.String("app_id", app.ID.String()),
+		zap.String("template", tmpl.ID),
+		zap.Int("services", len(services)),
+	)
+
+	appResponse := h.appToResponse(app)
+	response.App = &appResponse
+	for _, svc := range services {
+		response.Services = append(response.Services, h.appToResponse(svc))
+	}
+	response.URL = h.router.GetAppURL(app)
+	writeJSON(w, http.StatusAccepted, response)
+}
+
+// setTemplateSecrets stores a template's secrets, falling back to plain env vars with a
+// warning when no secret sealer is configured
+func (h *AppHandler) setTemplateSecrets(app *domain.App, values map[string]string) ([]string, error) {
+	var warnings []string
+	for key, value := range values {
+		if h.secrets == nil {
+			app.SetEnvVar(key, value)
+			warnings = append(warnings, app.Slug+": "+key+" is stored as a plain env var; set SECRETS_MASTER_KEY to encrypt it")
+			continue
+		}
+		ciphertext, err := h.secrets.Seal([]byte(value), domain.SecretAAD(app.ID, key))
+		if err != nil {
+			return nil, err
+		}
+		app.SetSecret(key, ciphertext)
+	}
+	return warnings, nil
+}
+
+// deployInBackground deploys an app's current image outside of a request, e.g. once its
+// first build is done, routing it when public is set. Failures are logged and returned.
+func (h *AppHandler) deployInBackground(app *domain.App, public bool) error {
+	ctx := context.Background()
+	deployment, err := h.orchestrator.Deploy(ctx, app)
+	h.saveApp(ctx, app)
+	if err != nil {
+		h.logger.Warn("Background deployment failed", zap.String("app_id", app.ID.String()), zap.Error(err))
+		return err
+	}
+	if public {
+		if err := h.router.AddRoute(ctx, app, h.appReplicas(ctx, app)); err != nil {
+			h.logger.Warn("Failed to add route", zap.String("app_id", app.ID.String()), zap.Error(err))
+		}
+	}
+	h.logger.Info("App deployed",
+		zap.String("app_id", app.ID.String()),
+		zap.String("deployment_id", deployment.ID.String()),
+	)
+	return nil
+}
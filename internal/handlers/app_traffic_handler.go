@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/services/router"
+)
+
+// Note: AppHandler and NewAppHandler are defined in app_handler.go
+// This file adds router traffic metrics to the existing AppHandler
+
+// GetTraffic returns the requests the router served for an app: totals, status codes,
+// latency and recent request counts
+func (h *AppHandler) GetTraffic(w http.ResponseWriter, r *http.Request) {
+	app, err := h.getApp(chi.URLParam(r, "appId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "App not found")
+		return
+	}
+	if _, routed := h.router.GetRoute(app.ID); !routed {
+		writeError(w, http.StatusNotFound, "App has no active route")
+		return
+	}
+
+	stats, err := h.router.Traffic(r.Context(), app.ID)
+	if errors.Is(err, router.ErrTrafficUnavailable) {
+		writeError(w, http.StatusNotImplemented, err.Error())
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to read router metrics", zap.Error(err), zap.String("app_id", app.ID.String()))
+		writeError(w, http.StatusBadGateway, "Failed to read router metrics")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, stats)
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// Note: AppHandler and NewAppHandler are defined in app_handler.go
+// This file adds per-app webhook secrets to the existing AppHandler
+
+// WebhookSecretBox seals and opens apps' webhook secrets
+type WebhookSecretBox interface {
+	SecretSealer
+	WebhookSecretOpener
+}
+
+// SetWebhookSecretBox enables per-app webhook secrets. Without a box, webhooks are
+// created with each provider's global secret.
+func (h *AppHandler) SetWebhookSecretBox(box WebhookSecretBox) {
+	h.webhookSecrets = box
+}
+
+// WebhookSecret returns the secret to create an app's webhooks with, generating one the
+// first time. It is "" when per-app secrets are disabled.
+func (h *AppHandler) WebhookSecret(ctx context.Context, appID uuid.UUID) (string, error) {
+	app, ok := h.FindApp(appID)
+	if !ok {
+		return "", fmt.Errorf("app %w", domain.ErrNotFound)
+	}
+	return h.appWebhookSecret(ctx, copyApp(app))
+}
+
+// appWebhookSecret returns app's webhook secret, generating and storing a random one
+// when it has none. app is the caller's copy and gets the secret too, so saving it
+// later keeps the secret.
+func (h *AppHandler) appWebhookSecret(ctx context.Context, app *domain.App) (string, error) {
+	if h.webhookSecrets == nil {
+		return "", nil
+	}
+
+	// Under the lock, a concurrent request that already generated the secret has cached it
+	h.webhookSecretMu.Lock()
+	defer h.webhookSecretMu.Unlock()
+	cached, ok := h.FindApp(app.ID)
+	if !ok {
+		return "", fmt.Errorf("app %w", domain.ErrNotFound)
+	}
+	sealed := cached.WebhookSecret
+	if len(sealed) == 0 {
+		raw := make([]byte, 32)
+		if _, err := rand.Read(raw); err != nil {
+			return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+		}
+		var err error
+		sealed, err = h.webhookSecrets.Seal([]byte(hex.EncodeToString(raw)), domain.WebhookSecretAAD(app.ID))
+		if err != nil {
+			return "", fmt.Errorf("failed to seal webhook secret: %w", err)
+		}
+		// Another instance may have stored one first; the stored one wins
+		if h.appStore != nil {
+			if sealed, err = h.appStore.EnsureWebhookSecret(ctx, app.ID, sealed); err != nil {
+				return "", fmt.Errorf("failed to store webhook secret: %w", err)
+			}
+		}
+		updated := copyApp(cached)
+		updated.WebhookSecret = sealed
+		h.replaceApp(updated)
+	}
+	app.WebhookSecret = sealed
+
+	secret, err := h.webhookSecrets.Open(sealed, domain.WebhookSecretAAD(app.ID))
+	if err != nil {
+		return "", fmt.Errorf("failed to open webhook secret: %w", err)
+	}
+	return string(secret), nil
+}
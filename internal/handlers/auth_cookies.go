@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nanopaas/nanopaas/internal/services/auth"
+)
+
+// Note: AuthHandler and NewAuthHandler are defined in auth_handler.go
+// This file adds cookie settings, signed OAuth state and cookie sessions to the existing AuthHandler
+
+// Cookies set by the auth handlers. The CSRF cookie is readable by the frontend, which
+// echoes it in csrfHeader on requests authenticated by the access token cookie.
+const (
+	oauthStateCookie   = "oauth_state"
+	accessTokenCookie  = "nanopaas_access"
+	refreshTokenCookie = "nanopaas_refresh"
+	csrfCookie         = "nanopaas_csrf"
+	csrfHeader         = "X-CSRF-Token"
+
+	// stateCookieMaxAge is how long a user has to finish signing in with a provider
+	stateCookieMaxAge = 600
+)
+
+// CookieOptions controls the cookies the auth handlers set
+type CookieOptions struct {
+	Secure   bool // send cookies over HTTPS only
+	SameSite http.SameSite
+	Domain   string // shares cookies between the API and frontend subdomains when set
+
+	// Session delivers the tokens of GitHub and OIDC sign-ins in httpOnly cookies
+	// instead of the redirect URL
+	Session bool
+
+	// StateKey signs OAuth state cookies. Replicas behind a load balancer need the same
+	// key; a random one is used when empty.
+	StateKey []byte
+}
+
+// ParseSameSite parses a SameSite mode: lax, strict or none
+func ParseSameSite(s string) (http.SameSite, error) {
+	switch strings.ToLower(s) {
+	case "", "lax":
+		return http.SameSiteLaxMode, nil
+	case "strict":
+		return http.SameSiteStrictMode, nil
+	case "none":
+		return http.SameSiteNoneMode, nil
+	}
+	return 0, fmt.Errorf("invalid SameSite mode %q: must be lax, strict or none", s)
+}
+
+// SetCookieOptions sets how auth cookies are scoped and whether sign-ins are delivered
+// in cookies. Browsers reject SameSite=None cookies that aren't Secure, so None implies
+// Secure.
+func (h *AuthHandler) SetCookieOptions(opts CookieOptions) {
+	if opts.SameSite == http.SameSiteNoneMode {
+		opts.Secure = true
+	}
+	if opts.SameSite == 0 {
+		opts.SameSite = http.SameSiteLaxMode
+	}
+	if len(opts.StateKey) == 0 {
+		opts.StateKey = randomKey()
+	}
+	h.cookies = opts
+}
+
+// setCookie sets a cookie with the configured scope
+func (h *AuthHandler) setCookie(w http.ResponseWriter, name, value string, maxAge int, httpOnly bool) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Domain:   h.cookies.Domain,
+		MaxAge:   maxAge,
+		Secure:   h.cookies.Secure,
+		HttpOnly: httpOnly,
+		SameSite: h.cookies.SameSite,
+	})
+}
+
+// clearCookie deletes a cookie set by setCookie
+func (h *AuthHandler) clearCookie(w http.ResponseWriter, name string) {
+	h.setCookie(w, name, "", -1, true)
+}
+
+// setStateCookie stores the state of a sign-in in progress, signed so it can't be forged
+// or swapped for another cookie's value
+func (h *AuthHandler) setStateCookie(w http.ResponseWriter, name, value string) {
+	h.setCookie(w, name, value+"."+h.signState(name, value), stateCookieMaxAge, true)
+}
+
+// readStateCookie returns the state stored by setStateCookie and clears the cookie. It
+// returns false when the cookie is missing or its signature doesn't match.
+func (h *AuthHandler) readStateCookie(w http.ResponseWriter, r *http.Request, name string) (string, bool) {
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return "", false
+	}
+	h.clearCookie(w, name)
+
+	i := strings.LastIndex(cookie.Value, ".")
+	if i < 0 {
+		return "", false
+	}
+	value, signature := cookie.Value[:i], cookie.Value[i+1:]
+	if !hmac.Equal([]byte(signature), []byte(h.signState(name, value))) {
+		return "", false
+	}
+	return value, true
+}
+
+// signState returns the signature of a state cookie's value
+func (h *AuthHandler) signState(name, value string) string {
+	mac := hmac.New(sha256.New, h.cookies.StateKey)
+	mac.Write([]byte(name + "=" + value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// redirectSignedIn sends a browser back to the frontend after a sign-in, with the tokens
+// in httpOnly cookies or, without cookie sessions, in the URL
+func (h *AuthHandler) redirectSignedIn(w http.ResponseWriter, r *http.Request, tokens *auth.TokenPair) {
+	redirectURL := h.frontendURL + "/auth/callback"
+	if h.cookies.Session {
+		h.setSessionCookies(w, tokens)
+	} else {
+		redirectURL += "?access_token=" + tokens.AccessToken + "&refresh_token=" + tokens.RefreshToken
+	}
+	http.Redirect(w, r, redirectURL, http.StatusTemporaryRedirect)
+}
+
+// setSessionCookies stores a session's tokens in httpOnly cookies, with a new CSRF token
+// the frontend can read
+func (h *AuthHandler) setSessionCookies(w http.ResponseWriter, tokens *auth.TokenPair) {
+	refreshAge := int(h.authService.RefreshTokenTTL().Seconds())
+	h.setCookie(w, accessTokenCookie, tokens.AccessToken, int(time.Until(tokens.ExpiresAt).Seconds()), true)
+	h.setCookie(w, refreshTokenCookie, tokens.RefreshToken, refreshAge, true)
+	h.setCookie(w, csrfCookie, base64.RawURLEncoding.EncodeToString(randomKey()), refreshAge, false)
+}
+
+// clearSessionCookies deletes the cookies set by setSessionCookies
+func (h *AuthHandler) clearSessionCookies(w http.ResponseWriter) {
+	for _, name := range []string{accessTokenCookie, refreshTokenCookie, csrfCookie} {
+		h.clearCookie(w, name)
+	}
+}
+
+// cookieToken returns the token stored in a session cookie, or ""
+func cookieToken(r *http.Request, name string) string {
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+// validCSRF reports whether a request authenticated by cookie may proceed: reads always
+// may, anything else must echo the CSRF cookie in csrfHeader
+func validCSRF(r *http.Request) bool {
+	if isReadRequest(r) {
+		return true
+	}
+	expected := cookieToken(r, csrfCookie)
+	got := r.Header.Get(csrfHeader)
+	return expected != "" && subtle.ConstantTimeCompare([]byte(expected), []byte(got)) == 1
+}
+
+// randomKey returns 32 random bytes
+func randomKey() []byte {
+	key := make([]byte, 32)
+	rand.Read(key)
+	return key
+}
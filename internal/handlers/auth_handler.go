@@ -2,10 +2,14 @@ package handlers
 
 import (
 	"context"
+	"crypto/hmac"
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/go-chi/chi/v5"
@@ -14,13 +18,16 @@ import (
 	"github.com/nanopaas/nanopaas/internal/domain"
 	"github.com/nanopaas/nanopaas/internal/services/auth"
 	"github.com/nanopaas/nanopaas/internal/services/github"
+	"github.com/nanopaas/nanopaas/internal/services/oidc"
 )
 
 // AuthHandler handles authentication endpoints
 type AuthHandler struct {
 	authService   *auth.Service
 	githubService *github.Service
+	oidc          *oidc.Provider
 	frontendURL   string
+	cookies       CookieOptions
 	logger        *zap.Logger
 }
 
@@ -30,24 +37,16 @@ func NewAuthHandler(authService *auth.Service, githubService *github.Service, fr
 		authService:   authService,
 		githubService: githubService,
 		frontendURL:   frontendURL,
+		cookies:       CookieOptions{SameSite: http.SameSiteLaxMode, StateKey: randomKey()},
 		logger:        logger,
 	}
 }
 
 // GitHubLogin redirects to GitHub OAuth
 func (h *AuthHandler) GitHubLogin(w http.ResponseWriter, r *http.Request) {
-	// Generate state token for CSRF protection
+	// Generate state token for CSRF protection, remembered in a signed cookie
 	state := generateState()
-
-	// Store state in cookie (in production, use secure cookie or session)
-	http.SetCookie(w, &http.Cookie{
-		Name:     "oauth_state",
-		Value:    state,
-		Path:     "/",
-		MaxAge:   600, // 10 minutes
-		HttpOnly: true,
-		SameSite: http.SameSiteLaxMode,
-	})
+	h.setStateCookie(w, oauthStateCookie, state)
 
 	authURL := h.githubService.GetAuthURL(state)
 	http.Redirect(w, r, authURL, http.StatusTemporaryRedirect)
@@ -63,21 +62,13 @@ func (h *AuthHandler) GitHubCallback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Verify state
-	stateCookie, err := r.Cookie("oauth_state")
-	if err != nil || stateCookie.Value != state {
+	// Verify state, clearing the cookie
+	expected, ok := h.readStateCookie(w, r, oauthStateCookie)
+	if !ok || state == "" || !hmac.Equal([]byte(expected), []byte(state)) {
 		h.redirectWithError(w, r, "invalid_state", "Invalid state parameter")
 		return
 	}
 
-	// Clear state cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:   "oauth_state",
-		Value:  "",
-		Path:   "/",
-		MaxAge: -1,
-	})
-
 	// Exchange code for token
 	token, err := h.githubService.ExchangeCode(r.Context(), code)
 	if err != nil {
@@ -95,8 +86,9 @@ func (h *AuthHandler) GitHubCallback(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Authenticate/create user
+	ctx := auth.WithClientInfo(r.Context(), auth.ClientInfo{IP: r.RemoteAddr, UserAgent: r.UserAgent(), Provider: domain.LoginProviderGitHub})
 	user, tokens, err := h.authService.AuthenticateGitHub(
-		r.Context(),
+		ctx,
 		ghUser.ID,
 		ghUser.Login,
 		ghUser.Email,
@@ -104,6 +96,13 @@ func (h *AuthHandler) GitHubCallback(w http.ResponseWriter, r *http.Request) {
 		ghUser.AvatarURL,
 		token.AccessToken,
 	)
+	if h.redirectTwoFactor(w, r, err) {
+		return
+	}
+	if errors.Is(err, auth.ErrUserDeactivated) {
+		h.redirectWithError(w, r, "account_deactivated", "This account has been deactivated")
+		return
+	}
 	if err != nil {
 		h.logger.Error("Failed to authenticate user", zap.Error(err))
 		h.redirectWithError(w, r, "auth_failed", "Failed to authenticate")
@@ -115,28 +114,47 @@ func (h *AuthHandler) GitHubCallback(w http.ResponseWriter, r *http.Request) {
 		zap.String("github_login", ghUser.Login),
 	)
 
-	// Redirect to frontend with token
-	redirectURL := h.frontendURL + "/auth/callback?access_token=" + tokens.AccessToken + "&refresh_token=" + tokens.RefreshToken
-	http.Redirect(w, r, redirectURL, http.StatusTemporaryRedirect)
+	h.redirectSignedIn(w, r, tokens)
 }
 
-// RefreshToken refreshes the access token
+// RefreshToken refreshes the access token. Without refresh_token in the body, the
+// refresh token cookie of a cookie session is used and the new tokens are set as cookies.
 func (h *AuthHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		RefreshToken string `json:"refresh_token"`
 	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "Invalid request body")
-		return
+	fromCookie := false
+	if req.RefreshToken == "" {
+		if req.RefreshToken = cookieToken(r, refreshTokenCookie); req.RefreshToken == "" {
+			writeError(w, http.StatusBadRequest, "refresh_token is required")
+			return
+		}
+		if !validCSRF(r) {
+			writeError(w, http.StatusForbidden, "Missing or invalid CSRF token")
+			return
+		}
+		fromCookie = true
 	}
 
 	tokens, err := h.authService.RefreshTokens(r.Context(), req.RefreshToken)
 	if err != nil {
+		if fromCookie {
+			h.clearSessionCookies(w)
+		}
 		writeError(w, http.StatusUnauthorized, "Invalid refresh token")
 		return
 	}
 
+	if fromCookie {
+		h.setSessionCookies(w, tokens)
+	}
 	writeJSON(w, http.StatusOK, tokens)
 }
 
@@ -151,17 +169,53 @@ func (h *AuthHandler) GetCurrentUser(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, user)
 }
 
-// Logout logs out the user
+// Logout ends the session of the bearer token, and of refresh_token when the body has
+// one, so their tokens stop working before they expire. Session cookies are ended and
+// cleared too.
 func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
-	// In a real implementation, you might want to:
-	// - Invalidate the token in a blacklist
-	// - Clear any server-side session
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+
+	tokens := []string{bearerToken(r), req.RefreshToken}
+	if access, refresh := cookieToken(r, accessTokenCookie), cookieToken(r, refreshTokenCookie); access != "" || refresh != "" {
+		if !validCSRF(r) {
+			writeError(w, http.StatusForbidden, "Missing or invalid CSRF token")
+			return
+		}
+		tokens = append(tokens, access, refresh)
+		h.clearSessionCookies(w)
+	}
+
+	for _, token := range tokens {
+		if token == "" || auth.IsAPIKey(token) {
+			continue
+		}
+		err := h.authService.Logout(r.Context(), token)
+		if err != nil && !errors.Is(err, auth.ErrInvalidToken) && !errors.Is(err, auth.ErrExpiredToken) {
+			h.logger.Warn("Failed to end session on logout", zap.Error(err))
+		}
+	}
 
 	writeJSON(w, http.StatusOK, map[string]string{
 		"message": "Logged out successfully",
 	})
 }
 
+// JWKS publishes the public keys tokens are signed with, so other services can verify
+// them without sharing a secret
+func (h *AuthHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	// Short enough that verifiers pick up a rotated key well before it signs much
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(w, http.StatusOK, h.authService.JWKS())
+}
+
 // redirectWithError redirects to frontend with error
 func (h *AuthHandler) redirectWithError(w http.ResponseWriter, r *http.Request, code, message string) {
 	redirectURL := h.frontendURL + "/auth/error?error=" + code + "&message=" + message
@@ -175,11 +229,43 @@ func generateState() string {
 	return hex.EncodeToString(bytes)
 }
 
-// AuthMiddleware validates JWT tokens
+// AuthMiddleware validates JWT tokens, API keys and deploy tokens. While two-factor
+// authentication is required, users who haven't enrolled are turned away.
 func AuthMiddleware(authService *auth.Service) func(http.Handler) http.Handler {
+	return authMiddleware(authService, true)
+}
+
+// EnrollmentAuthMiddleware authenticates like AuthMiddleware but lets in users the
+// two-factor policy blocks, for the routes they enroll with
+func EnrollmentAuthMiddleware(authService *auth.Service) func(http.Handler) http.Handler {
+	return authMiddleware(authService, false)
+}
+
+// authMiddleware validates JWT tokens, API keys and deploy tokens, optionally enforcing
+// the two-factor policy
+func authMiddleware(authService *auth.Service, enforceTwoFactor bool) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
+		if enforceTwoFactor {
+			next = requireTwoFactorEnrollment(authService, next)
+		}
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			authHeader := r.Header.Get("Authorization")
+			if authHeader == "" && IsEventStream(r) {
+				// EventSource cannot set headers
+				if token := r.URL.Query().Get("token"); token != "" {
+					authHeader = "Bearer " + token
+				}
+			}
+			if authHeader == "" {
+				// Browsers signed in with cookie sessions
+				if token := cookieToken(r, accessTokenCookie); token != "" {
+					if !validCSRF(r) {
+						writeError(w, http.StatusForbidden, "Missing or invalid CSRF token")
+						return
+					}
+					authHeader = "Bearer " + token
+				}
+			}
 			if authHeader == "" {
 				writeError(w, http.StatusUnauthorized, "Missing authorization header")
 				return
@@ -191,7 +277,20 @@ func AuthMiddleware(authService *auth.Service) func(http.Handler) http.Handler {
 				return
 			}
 
+			if auth.IsAPIKey(parts[1]) {
+				serveWithAPIKey(w, r, next, authService, parts[1])
+				return
+			}
+			if auth.IsDeployToken(parts[1]) {
+				serveWithDeployToken(w, r, next, authService, parts[1])
+				return
+			}
+
 			user, err := authService.GetUserFromToken(r.Context(), parts[1])
+			if errors.Is(err, auth.ErrUserDeactivated) {
+				writeError(w, http.StatusForbidden, "This account has been deactivated")
+				return
+			}
 			if err != nil {
 				writeError(w, http.StatusUnauthorized, "Invalid or expired token")
 				return
@@ -227,6 +326,8 @@ func OptionalAuthMiddleware(authService *auth.Service) func(http.Handler) http.H
 // GitHubHandler handles GitHub-related endpoints
 type GitHubHandler struct {
 	githubService *github.Service
+	apps          GitHubWebhookApps
+	repoCache     RepoCache
 	logger        *zap.Logger
 }
 
@@ -238,7 +339,9 @@ func NewGitHubHandler(githubService *github.Service, logger *zap.Logger) *GitHub
 	}
 }
 
-// ListRepositories lists user's GitHub repositories
+// ListRepositories lists user's GitHub repositories, paged with page and per_page.
+// search filters by name and description across all of them, and all=true returns
+// every repository; both report the number of matches in X-Total-Count.
 func (h *GitHubHandler) ListRepositories(w http.ResponseWriter, r *http.Request) {
 	user := GetUserFromContext(r.Context())
 	if user == nil || user.GitHubToken == "" {
@@ -246,34 +349,65 @@ func (h *GitHubHandler) ListRepositories(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	page := 1
-	perPage := 30
+	q := r.URL.Query()
+	page, perPage, err := parsePageParams(q.Get("page"), q.Get("per_page"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	search := strings.ToLower(strings.TrimSpace(q.Get("search")))
+	all := q.Get("all") == "true"
 
-	repos, err := h.githubService.ListRepositories(r.Context(), user.GitHubToken, page, perPage)
+	if search == "" && !all {
+		repos, err := h.cachedRepositories(r.Context(), user, fmt.Sprintf("page:%d:%d", page, perPage), func(ctx context.Context) ([]github.Repository, error) {
+			return h.githubService.ListRepositories(ctx, user.GitHubToken, page, perPage)
+		})
+		if err != nil {
+			h.logger.Error("Failed to list repositories", zap.Error(err))
+			writeGitHubError(w, err, http.StatusInternalServerError, "Failed to fetch repositories")
+			return
+		}
+		writeJSON(w, http.StatusOK, repos)
+		return
+	}
+
+	repos, err := h.cachedRepositories(r.Context(), user, "all", func(ctx context.Context) ([]github.Repository, error) {
+		return h.githubService.ListAllRepositories(ctx, user.GitHubToken)
+	})
 	if err != nil {
 		h.logger.Error("Failed to list repositories", zap.Error(err))
-		writeError(w, http.StatusInternalServerError, "Failed to fetch repositories")
+		writeGitHubError(w, err, http.StatusInternalServerError, "Failed to fetch repositories")
 		return
 	}
 
-	writeJSON(w, http.StatusOK, repos)
+	matched := make([]github.Repository, 0, len(repos))
+	for _, repo := range repos {
+		if search == "" || strings.Contains(strings.ToLower(repo.FullName), search) || strings.Contains(strings.ToLower(repo.Description), search) {
+			matched = append(matched, repo)
+		}
+	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(len(matched)))
+	if !all {
+		start := min((page-1)*perPage, len(matched))
+		matched = matched[start:min(start+perPage, len(matched))]
+	}
+	writeJSON(w, http.StatusOK, matched)
 }
 
 // GetRepository gets a specific repository
 func (h *GitHubHandler) GetRepository(w http.ResponseWriter, r *http.Request) {
-	user := GetUserFromContext(r.Context())
-	if user == nil || user.GitHubToken == "" {
-		writeError(w, http.StatusUnauthorized, "GitHub not connected")
-		return
-	}
-
 	owner := chi.URLParam(r, "owner")
 	repo := chi.URLParam(r, "repo")
 
-	repository, err := h.githubService.GetRepository(r.Context(), user.GitHubToken, owner, repo)
+	token, ok := h.repoToken(w, r, owner, repo)
+	if !ok {
+		return
+	}
+
+	repository, err := h.githubService.GetRepository(r.Context(), token, owner, repo)
 	if err != nil {
 		h.logger.Error("Failed to get repository", zap.Error(err))
-		writeError(w, http.StatusInternalServerError, "Failed to fetch repository")
+		writeGitHubError(w, err, http.StatusInternalServerError, "Failed to fetch repository")
 		return
 	}
 
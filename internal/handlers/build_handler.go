@@ -1,40 +1,42 @@
 package handlers
 
 import (
+	"cmp"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
-	"github.com/gorilla/websocket"
 	"go.uber.org/zap"
 
 	"github.com/nanopaas/nanopaas/internal/domain"
+	"github.com/nanopaas/nanopaas/internal/infrastructure/docker"
 	"github.com/nanopaas/nanopaas/internal/services/builder"
 	ws "github.com/nanopaas/nanopaas/pkg/websocket"
 )
 
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		return true // Allow all origins for development
-	},
-}
-
 // AppUpdater interface for updating app image after build success
 type AppUpdater interface {
 	UpdateAppImage(appID string, imageID, imageTag string)
 }
 
+// Notifier interface for sending personal notifications
+type Notifier interface {
+	Notify(ctx context.Context, userID uuid.UUID, kind domain.NotificationKind, title, body, link string)
+}
+
 // BuildHandler handles build-related endpoints
 type BuildHandler struct {
 	builder    *builder.Builder
 	wsHub      *ws.Hub
 	logger     *zap.Logger
 	appUpdater AppUpdater
+	notifier   Notifier
+	wsAuth     *WSAuthenticator
 }
 
 // CreateBuildRequest represents a request to create a new build
@@ -52,6 +54,13 @@ type BuildResponse struct {
 	AppID        string            `json:"app_id"`
 	Status       string            `json:"status"`
 	Source       string            `json:"source"`
+	GitRef       string            `json:"git_ref,omitempty"`
+	GitTag       string            `json:"git_tag,omitempty"`
+	GitCommit    string            `json:"git_commit,omitempty"`
+	CommitMessage string           `json:"commit_message,omitempty"`
+	CommitAuthor string            `json:"commit_author,omitempty"`
+	CompareURL   string            `json:"compare_url,omitempty"`
+	Commits      []domain.Commit   `json:"commits,omitempty"`
 	ImageTag     string            `json:"image_tag,omitempty"`
 	ImageID      string            `json:"image_id,omitempty"`
 	Duration     string            `json:"duration,omitempty"`
@@ -76,6 +85,17 @@ func (h *BuildHandler) SetAppUpdater(updater AppUpdater) {
 	h.appUpdater = updater
 }
 
+// SetNotifier sets the notifier used to tell users when their builds fail
+func (h *BuildHandler) SetNotifier(notifier Notifier) {
+	h.notifier = notifier
+}
+
+// SetWSAuthenticator sets the authenticator for build log streams, which are refused
+// until one is set
+func (h *BuildHandler) SetWSAuthenticator(wsAuth *WSAuthenticator) {
+	h.wsAuth = wsAuth
+}
+
 // Create initiates a new build
 func (h *BuildHandler) Create(w http.ResponseWriter, r *http.Request) {
 	appID := chi.URLParam(r, "appId")
@@ -196,17 +216,20 @@ func (h *BuildHandler) Upload(w http.ResponseWriter, r *http.Request) {
 
 	// Create log callback that broadcasts to WebSocket
 	logTopic := fmt.Sprintf("build:%s", buildID)
-	logCallback := func(msg string) {
-		h.wsHub.BroadcastString(logTopic, "log", msg)
+	logCallback := func(line docker.BuildLogLine) {
+		broadcastBuildLog(h.wsHub, logTopic, line)
 	}
 
 	// Submit build job
 	job := &builder.BuildJob{
-		Build:       build,
-		AppSlug:     appSlug,
-		SourceData:  file,
-		ResultChan:  resultChan,
-		LogCallback: logCallback,
+		Build:          build,
+		AppSlug:        appSlug,
+		SourceData:     file,
+		ResultChan:     resultChan,
+		LogCallback:    logCallback,
+		OnPullProgress: func(p docker.PullProgress) {
+			broadcastPullProgress(h.wsHub, logTopic, p)
+		},
 	}
 
 	if err := h.builder.SubmitBuild(job); err != nil {
@@ -248,11 +271,61 @@ func (h *BuildHandler) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	writeJSON(w, http.StatusOK, buildToResponse(build))
+}
+
+// buildSorts compares builds by each sortable key of the build list
+var buildSorts = map[string]func(a, b *domain.Build) int{
+	"status":     func(a, b *domain.Build) int { return strings.Compare(string(a.Status), string(b.Status)) },
+	"created_at": func(a, b *domain.Build) int { return a.CreatedAt.Compare(b.CreatedAt) },
+	"duration":   func(a, b *domain.Build) int { return cmp.Compare(a.Duration(), b.Duration()) },
+}
+
+// List returns an app's recent builds, filtered by status and image tag (name=)
+func (h *BuildHandler) List(w http.ResponseWriter, r *http.Request) {
+	appID, err := uuid.Parse(chi.URLParam(r, "appId"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid app ID format")
+		return
+	}
+	params, err := parseListParams(r, []string{"status", "created_at", "duration"}, "-created_at")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	matched := make([]*domain.Build, 0)
+	for _, build := range h.builder.AppBuilds(appID) {
+		if params.Status != "" && string(build.Status) != params.Status {
+			continue
+		}
+		if params.matchesName(build.ImageTag) {
+			matched = append(matched, build)
+		}
+	}
+
+	page, total := sortAndPage(matched, params, buildSorts)
+	builds := make([]BuildResponse, 0, len(page))
+	for _, build := range page {
+		builds = append(builds, buildToResponse(build))
+	}
+	writeList(w, r, builds, total, params)
+}
+
+// buildToResponse converts a build to its API representation
+func buildToResponse(build *domain.Build) BuildResponse {
 	response := BuildResponse{
 		ID:        build.ID.String(),
 		AppID:     build.AppID.String(),
 		Status:    string(build.Status),
 		Source:    string(build.Source),
+		GitRef:    build.GitRef,
+		GitTag:    build.GitTag,
+		GitCommit: build.GitCommit,
+		CommitMessage: build.CommitMessage,
+		CommitAuthor: build.CommitAuthor,
+		CompareURL: build.CompareURL,
+		Commits:   build.Commits,
 		ImageTag:  build.ImageTag,
 		ImageID:   build.ImageID,
 		CreatedAt: build.CreatedAt.Format("2006-01-02T15:04:05Z"),
@@ -270,8 +343,7 @@ func (h *BuildHandler) Get(w http.ResponseWriter, r *http.Request) {
 	if build.ErrorMessage != "" {
 		response.Error = build.ErrorMessage
 	}
-
-	writeJSON(w, http.StatusOK, response)
+	return response
 }
 
 // Cancel cancels a running build
@@ -302,11 +374,19 @@ func (h *BuildHandler) Cancel(w http.ResponseWriter, r *http.Request) {
 func (h *BuildHandler) StreamLogs(w http.ResponseWriter, r *http.Request) {
 	buildID := chi.URLParam(r, "buildId")
 	if buildID == "" {
-		http.Error(w, "Build ID is required", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, "Build ID is required")
+		return
+	}
+	if h.wsAuth == nil {
+		writeError(w, http.StatusServiceUnavailable, "WebSocket authentication is not configured")
+		return
+	}
+	user, ok := h.wsAuth.AuthorizeBuild(w, r, buildID)
+	if !ok {
 		return
 	}
 
-	conn, err := upgrader.Upgrade(w, r, nil)
+	conn, err := h.wsAuth.Upgrade(w, r)
 	if err != nil {
 		h.logger.Error("WebSocket upgrade failed", zap.Error(err))
 		return
@@ -314,6 +394,7 @@ func (h *BuildHandler) StreamLogs(w http.ResponseWriter, r *http.Request) {
 
 	// Create WebSocket client
 	client := ws.NewClient(h.wsHub, conn)
+	client.SetAuthorizer(h.wsAuth.TopicAuthorizer(user))
 	h.wsHub.Register(client)
 
 	// Subscribe to build logs
@@ -330,6 +411,11 @@ func (h *BuildHandler) StreamLogs(w http.ResponseWriter, r *http.Request) {
 	go client.ReadPump()
 }
 
+// broadcastBuildLog sends a build output line with its step to the build's topic
+func broadcastBuildLog(hub *ws.Hub, topic string, line docker.BuildLogLine) {
+	hub.Publish(topic, "log", line)
+}
+
 // Stats returns builder statistics
 func (h *BuildHandler) Stats(w http.ResponseWriter, r *http.Request) {
 	stats := map[string]interface{}{
@@ -375,35 +461,12 @@ func (h *BuildHandler) StartBuildFromGit(w http.ResponseWriter, r *http.Request)
 		req.AppSlug = "app"
 	}
 
-	// Create build entity
-	build := domain.NewBuild(appUUID, domain.BuildSourceGit)
-	build.SourceURL = req.RepoURL
-	build.GitRef = req.Branch
-
-	// Create result channel
-	resultChan := make(chan builder.BuildResult, 1)
-
-	// Create log callback
-	logTopic := fmt.Sprintf("build:%s", build.ID.String())
-	logCallback := func(msg string) {
-		h.wsHub.BroadcastString(logTopic, "log", msg)
-	}
-
-	// Submit build job
-	job := &builder.BuildJob{
-		Build:       build,
-		AppSlug:     req.AppSlug,
-		SourceURL:   req.RepoURL,
-		ResultChan:  resultChan,
-		LogCallback: logCallback,
-		OnSuccess: func(imageID, imageTag string) {
-			if h.appUpdater != nil {
-				h.appUpdater.UpdateAppImage(appID, imageID, imageTag)
-			}
-		},
-	}
-
-	if err := h.builder.SubmitBuild(job); err != nil {
+	build, err := h.SubmitGitBuild(r, appUUID, req.AppSlug, req.RepoURL, req.Branch, func(imageID, imageTag string) {
+		if h.appUpdater != nil {
+			h.appUpdater.UpdateAppImage(appID, imageID, imageTag)
+		}
+	})
+	if err != nil {
 		writeError(w, http.StatusServiceUnavailable, "Build queue is full: "+err.Error())
 		return
 	}
@@ -428,6 +491,59 @@ func (h *BuildHandler) StartBuildFromGit(w http.ResponseWriter, r *http.Request)
 	writeJSON(w, http.StatusAccepted, response)
 }
 
+// SubmitGitBuild queues a build of a git repository, streaming its logs over the
+// WebSocket hub and notifying the requesting user if it fails
+func (h *BuildHandler) SubmitGitBuild(r *http.Request, appID uuid.UUID, appSlug, repoURL, branch string, onSuccess func(imageID, imageTag string)) (*domain.Build, error) {
+	// Create build entity
+	build := domain.NewBuild(appID, domain.BuildSourceGit)
+	build.SourceURL = repoURL
+	build.GitRef = branch
+
+	// Create result channel
+	resultChan := make(chan builder.BuildResult, 1)
+
+	// Create log callback
+	logTopic := fmt.Sprintf("build:%s", build.ID.String())
+	logCallback := func(line docker.BuildLogLine) {
+		broadcastBuildLog(h.wsHub, logTopic, line)
+	}
+
+	// Submit build job
+	job := &builder.BuildJob{
+		Build:          build,
+		AppSlug:        appSlug,
+		SourceURL:      repoURL,
+		ResultChan:     resultChan,
+		LogCallback:    logCallback,
+		OnPullProgress: func(p docker.PullProgress) {
+			broadcastPullProgress(h.wsHub, logTopic, p)
+		},
+		OnSuccess: onSuccess,
+		OnFailure: h.notifyBuildFailed(r, build, appSlug),
+	}
+
+	if err := h.builder.SubmitBuild(job); err != nil {
+		return nil, err
+	}
+	return build, nil
+}
+
+// notifyBuildFailed returns a build failure callback that notifies the requesting user
+func (h *BuildHandler) notifyBuildFailed(r *http.Request, build *domain.Build, appSlug string) func(err error) {
+	user := GetUserFromContext(r.Context())
+	if h.notifier == nil || user == nil {
+		return nil
+	}
+
+	return func(err error) {
+		h.notifier.Notify(context.Background(), user.ID, domain.NotificationBuildFailed,
+			fmt.Sprintf("Build failed for %s", appSlug),
+			err.Error(),
+			fmt.Sprintf("/apps/%s/builds/%s", build.AppID, build.ID),
+		)
+	}
+}
+
 // HealthCheck placeholder for builder health
 func (h *BuildHandler) HealthCheck(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, map[string]interface{}{
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+	"github.com/nanopaas/nanopaas/internal/services/acme"
+)
+
+// certificateIssueTimeout bounds a manually requested certificate order
+const certificateIssueTimeout = 5 * time.Minute
+
+// CertificateHandler handles admin TLS certificate endpoints
+type CertificateHandler struct {
+	manager *acme.Manager // nil when ACME is disabled
+	logger  *zap.Logger
+}
+
+// NewCertificateHandler creates a new certificate handler
+func NewCertificateHandler(manager *acme.Manager, logger *zap.Logger) *CertificateHandler {
+	return &CertificateHandler{
+		manager: manager,
+		logger:  logger,
+	}
+}
+
+// CertificateResponse represents a managed certificate in API responses
+type CertificateResponse struct {
+	*domain.Certificate
+	ExpiresInDays int  `json:"expires_in_days"`
+	RenewalDue    bool `json:"renewal_due"`
+}
+
+// IssueCertificateRequest represents a request to issue a certificate
+type IssueCertificateRequest struct {
+	Domains []string `json:"domains"` // the first name is the primary domain
+}
+
+// List returns managed certificates and recent orders
+func (h *CertificateHandler) List(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) || !h.requireEnabled(w) {
+		return
+	}
+
+	certs := h.manager.Certificates()
+	response := make([]CertificateResponse, 0, len(certs))
+	for _, cert := range certs {
+		response = append(response, h.certificateResponse(cert))
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"certificates": response,
+		"orders":       h.manager.Orders(),
+	})
+}
+
+// Issue orders a certificate for the requested names and waits for it
+func (h *CertificateHandler) Issue(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) || !h.requireEnabled(w) {
+		return
+	}
+
+	var req IssueCertificateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if len(req.Domains) == 0 {
+		writeError(w, http.StatusBadRequest, "domains is required")
+		return
+	}
+
+	h.obtain(w, r, req.Domains)
+}
+
+// Renew reissues an existing certificate ahead of its renewal window
+func (h *CertificateHandler) Renew(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) || !h.requireEnabled(w) {
+		return
+	}
+
+	cert := h.findCertificate(chi.URLParam(r, "domain"))
+	if cert == nil {
+		writeError(w, http.StatusNotFound, "Certificate not found")
+		return
+	}
+
+	h.obtain(w, r, cert.Domains)
+}
+
+// Delete removes a certificate and stops renewing it
+func (h *CertificateHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) || !h.requireEnabled(w) {
+		return
+	}
+
+	primary := chi.URLParam(r, "domain")
+	if h.findCertificate(primary) == nil {
+		writeError(w, http.StatusNotFound, "Certificate not found")
+		return
+	}
+	if err := h.manager.Remove(r.Context(), primary); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to delete certificate: "+err.Error())
+		return
+	}
+
+	h.logger.Info("Certificate deleted", zap.String("domain", primary))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// obtain runs an order detached from the request so a client disconnect doesn't abandon it
+func (h *CertificateHandler) obtain(w http.ResponseWriter, r *http.Request, domains []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), certificateIssueTimeout)
+	defer cancel()
+
+	cert, err := h.manager.Obtain(ctx, domains)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "Certificate order failed: "+err.Error())
+		return
+	}
+
+	h.logger.Info("Certificate issued on request",
+		zap.Strings("domains", cert.Domains),
+		zap.String("user_id", GetUserFromContext(r.Context()).ID.String()),
+	)
+	writeJSON(w, http.StatusOK, h.certificateResponse(cert))
+}
+
+func (h *CertificateHandler) findCertificate(primary string) *domain.Certificate {
+	for _, cert := range h.manager.Certificates() {
+		if cert.Domain == primary {
+			return cert
+		}
+	}
+	return nil
+}
+
+func (h *CertificateHandler) certificateResponse(cert *domain.Certificate) CertificateResponse {
+	return CertificateResponse{
+		Certificate:   cert,
+		ExpiresInDays: int(time.Until(cert.NotAfter).Hours() / 24),
+		RenewalDue:    cert.NeedsRenewal(h.manager.RenewBefore()),
+	}
+}
+
+// requireEnabled writes 503 and returns false when ACME is not configured
+func (h *CertificateHandler) requireEnabled(w http.ResponseWriter) bool {
+	if h.manager == nil {
+		writeError(w, http.StatusServiceUnavailable, "Certificate management is disabled; set ACME_ENABLED=true")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/infrastructure/docker"
+)
+
+// Note: ContainerHandler and NewContainerHandler are defined in container_handler.go
+// This file adds an admin-only packet capture route to the existing ContainerHandler
+
+const (
+	// defaultCaptureDuration is used when no duration is requested
+	defaultCaptureDuration = 10 * time.Second
+
+	// maxCaptureDuration stays below the server write timeout so the download completes
+	maxCaptureDuration = 25 * time.Second
+
+	// defaultCaptureBytes and maxCaptureBytes bound the size of the pcap stream
+	defaultCaptureBytes = 10 * 1024 * 1024  // 10MB
+	maxCaptureBytes     = 50 * 1024 * 1024  // 50MB
+	captureMemoryLimit  = 128 * 1024 * 1024 // 128MB
+
+	// pcapHeaderSize and pcapRecordHeaderSize are the sizes of the file header and of the
+	// header before each packet
+	pcapHeaderSize       = 24
+	pcapRecordHeaderSize = 16
+)
+
+// captureFilterPattern restricts BPF filters to a conservative character set
+var captureFilterPattern = regexp.MustCompile(`^[a-zA-Z0-9 .:/()!&|<>=-]{0,200}$`)
+
+// errCaptureLimitReached stops the capture once the size bound is hit
+var errCaptureLimitReached = errors.New("capture size limit reached")
+
+// errCaptureFormat stops a capture whose output is not a pcap stream
+var errCaptureFormat = errors.New("capture output is not pcap")
+
+// SetCaptureImage sets the image used for packet captures (must provide tcpdump)
+func (h *ContainerHandler) SetCaptureImage(image string) {
+	h.captureImage = image
+}
+
+// Capture streams a bounded pcap of a container's network traffic
+// Query params: duration (e.g. "10s", max 25s), max_bytes, filter (BPF expression, e.g. "tcp port 8080")
+func (h *ContainerHandler) Capture(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r.Context())
+	if user == nil || !user.IsAdmin() {
+		writeError(w, http.StatusForbidden, "Packet capture requires admin privileges")
+		return
+	}
+
+	containerID := chi.URLParam(r, "id")
+	if containerID == "" {
+		writeError(w, http.StatusBadRequest, "Container ID is required")
+		return
+	}
+
+	if h.captureImage == "" {
+		writeError(w, http.StatusServiceUnavailable, "Packet capture is not configured")
+		return
+	}
+
+	duration := defaultCaptureDuration
+	if d := r.URL.Query().Get("duration"); d != "" {
+		parsed, err := time.ParseDuration(d)
+		if err != nil || parsed < time.Second || parsed > maxCaptureDuration {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("duration must be between 1s and %s", maxCaptureDuration))
+			return
+		}
+		duration = parsed
+	}
+
+	maxBytes := int64(defaultCaptureBytes)
+	if m := r.URL.Query().Get("max_bytes"); m != "" {
+		parsed, err := strconv.ParseInt(m, 10, 64)
+		if err != nil || parsed <= 0 || parsed > maxCaptureBytes {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("max_bytes must be between 1 and %d", maxCaptureBytes))
+			return
+		}
+		maxBytes = parsed
+	}
+
+	filter := r.URL.Query().Get("filter")
+	if !captureFilterPattern.MatchString(filter) {
+		writeError(w, http.StatusBadRequest, "filter contains unsupported characters")
+		return
+	}
+
+	target, err := h.dockerClient.InspectContainer(r.Context(), containerID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Container not found")
+		return
+	}
+	if target.State == nil || !target.State.Running {
+		writeError(w, http.StatusConflict, "Container is not running")
+		return
+	}
+
+	// tcpdump writes pcap to stdout; timeout bounds the capture even if the client stays connected
+	cmd := []string{"timeout", strconv.Itoa(int(math.Ceil(duration.Seconds()))), "tcpdump", "-i", "any", "-U", "-w", "-"}
+	if filter != "" {
+		cmd = append(cmd, filter)
+	}
+
+	// Share the target's network namespace so only its traffic is visible
+	opts := docker.ContainerOptions{
+		Name:          fmt.Sprintf("capture-%s-%d", docker.ShortID(target.ID), time.Now().UnixNano()),
+		Image:         h.captureImage,
+		Cmd:           cmd,
+		Labels:        map[string]string{"nanopaas.capture.target": target.ID},
+		NetworkMode:   "container:" + target.ID,
+		Memory:        captureMemoryLimit,
+		RestartPolicy: "no",
+		CapAdd:        []string{"NET_RAW", "NET_ADMIN"},
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), duration+5*time.Second)
+	defer cancel()
+
+	captureID, err := h.dockerClient.CreateContainer(ctx, opts)
+	if err != nil {
+		h.logger.Error("Failed to create capture container", zap.Error(err), zap.String("id", containerID))
+		writeError(w, http.StatusInternalServerError, "Failed to start capture")
+		return
+	}
+	// Use a fresh context so the capture container is removed after cancellation
+	defer h.dockerClient.RemoveContainer(context.Background(), captureID, true)
+
+	h.logger.Info("Packet capture started",
+		zap.String("container_id", target.ID),
+		zap.String("user_id", user.ID.String()),
+		zap.Duration("duration", duration),
+		zap.Int64("max_bytes", maxBytes),
+		zap.String("filter", filter),
+	)
+
+	w.Header().Set("Content-Type", "application/vnd.tcpdump.pcap")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", docker.ShortID(target.ID)+".pcap"))
+	w.WriteHeader(http.StatusOK)
+
+	out := &captureWriter{w: w, remaining: maxBytes, cancel: cancel}
+	if flusher, ok := w.(http.Flusher); ok {
+		out.flusher = flusher
+	}
+
+	err = h.dockerClient.AttachAndStart(ctx, captureID, out, io.Discard)
+	if err != nil && !errors.Is(err, errCaptureLimitReached) && !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, context.Canceled) {
+		h.logger.Warn("Packet capture ended with error", zap.Error(err), zap.String("container_id", target.ID))
+	}
+
+	h.logger.Info("Packet capture finished",
+		zap.String("container_id", target.ID),
+		zap.Int64("bytes", maxBytes-out.remaining),
+	)
+}
+
+// captureWriter forwards pcap bytes to the client a whole record at a time, and stops
+// before the first record that would pass the size bound so the file stays readable
+type captureWriter struct {
+	w         io.Writer
+	flusher   http.Flusher
+	remaining int64
+	cancel    context.CancelFunc
+
+	pending []byte           // bytes of the header or record not complete yet
+	order   binary.ByteOrder // byte order of the record headers; nil until the file header is read
+}
+
+func (c *captureWriter) Write(p []byte) (int, error) {
+	c.pending = append(c.pending, p...)
+
+	for {
+		size := c.nextSize()
+		if size < 0 {
+			return len(p), nil
+		}
+		if size == 0 {
+			c.cancel()
+			return 0, errCaptureFormat
+		}
+		if size > c.remaining {
+			c.cancel()
+			return 0, errCaptureLimitReached
+		}
+		if int64(len(c.pending)) < size {
+			return len(p), nil
+		}
+
+		n, err := c.w.Write(c.pending[:size])
+		c.remaining -= int64(n)
+		if c.flusher != nil {
+			c.flusher.Flush()
+		}
+		if err != nil {
+			return 0, err
+		}
+		c.pending = c.pending[size:]
+	}
+}
+
+// nextSize returns the size of the file header or record at the start of pending, -1
+// when its header is not complete yet and 0 when the stream is not pcap
+func (c *captureWriter) nextSize() int64 {
+	if c.order == nil {
+		if len(c.pending) < pcapHeaderSize {
+			return -1
+		}
+		switch binary.LittleEndian.Uint32(c.pending) {
+		case 0xa1b2c3d4, 0xa1b23c4d: // microsecond and nanosecond timestamps
+			c.order = binary.LittleEndian
+		case 0xd4c3b2a1, 0x4d3cb2a1:
+			c.order = binary.BigEndian
+		default:
+			return 0
+		}
+		return pcapHeaderSize
+	}
+	if len(c.pending) < pcapRecordHeaderSize {
+		return -1
+	}
+	return pcapRecordHeaderSize + int64(c.order.Uint32(c.pending[8:12]))
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// Note: ContainerHandler and NewContainerHandler are defined in container_handler.go
+// This file adds file copy routes to the existing ContainerHandler
+
+const (
+	// maxUploadFileSize limits files uploaded into a container
+	maxUploadFileSize = 10 * 1024 * 1024 // 10MB
+
+	// maxDownloadSize limits what is copied out of a container: a file, or the tar
+	// archive of a directory
+	maxDownloadSize = 100 * 1024 * 1024 // 100MB
+)
+
+// deniedContainerPaths are pseudo-filesystems that must never be read or written
+var deniedContainerPaths = []string{"/proc", "/sys", "/dev"}
+
+// UploadFile copies an uploaded file into a container
+// Expects a multipart form with a "file" field and a "path" field holding the absolute destination file path
+func (h *ContainerHandler) UploadFile(w http.ResponseWriter, r *http.Request) {
+	containerID := chi.URLParam(r, "id")
+	if containerID == "" {
+		writeError(w, http.StatusBadRequest, "Container ID is required")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadFileSize+1024*1024)
+	if err := r.ParseMultipartForm(maxUploadFileSize); err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Upload must be a multipart form no larger than %d bytes", maxUploadFileSize))
+		return
+	}
+
+	dstPath, err := sanitizeContainerPath(r.FormValue("path"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if dstPath == "/" {
+		writeError(w, http.StatusBadRequest, "Destination must be a file path")
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "File is required")
+		return
+	}
+	defer file.Close()
+
+	if header.Size > maxUploadFileSize {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("File exceeds %d bytes", maxUploadFileSize))
+		return
+	}
+
+	content, err := io.ReadAll(io.LimitReader(file, maxUploadFileSize+1))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Failed to read file")
+		return
+	}
+	if len(content) > maxUploadFileSize {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("File exceeds %d bytes", maxUploadFileSize))
+		return
+	}
+
+	// Docker expects a tar archive extracted into the destination directory
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	if err := tw.WriteHeader(&tar.Header{
+		Name: path.Base(dstPath),
+		Mode: 0644,
+		Size: int64(len(content)),
+	}); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to prepare archive")
+		return
+	}
+	if _, err := tw.Write(content); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to prepare archive")
+		return
+	}
+	if err := tw.Close(); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to prepare archive")
+		return
+	}
+
+	if err := h.dockerClient.CopyToContainer(r.Context(), containerID, path.Dir(dstPath), &archive); err != nil {
+		h.logger.Error("Failed to copy file to container",
+			zap.Error(err),
+			zap.String("id", containerID),
+			zap.String("path", dstPath),
+		)
+		writeError(w, http.StatusInternalServerError, "Failed to copy file to container")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"message": "File uploaded successfully",
+		"path":    dstPath,
+		"size":    len(content),
+	})
+}
+
+// DownloadFile copies a file or directory out of a container
+// Single files are returned as-is; directories are returned as a tar archive
+func (h *ContainerHandler) DownloadFile(w http.ResponseWriter, r *http.Request) {
+	containerID := chi.URLParam(r, "id")
+	if containerID == "" {
+		writeError(w, http.StatusBadRequest, "Container ID is required")
+		return
+	}
+
+	srcPath, err := sanitizeContainerPath(r.URL.Query().Get("path"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	stat, err := h.dockerClient.StatContainerPath(r.Context(), containerID, srcPath)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Path not found in container")
+		return
+	}
+	// A directory's own size says nothing of its contents, which are counted as they stream
+	if !stat.Mode.IsDir() && stat.Size > maxDownloadSize {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Path exceeds %d bytes", maxDownloadSize))
+		return
+	}
+
+	reader, _, err := h.dockerClient.CopyFromContainer(r.Context(), containerID, srcPath)
+	if err != nil {
+		h.logger.Error("Failed to copy from container",
+			zap.Error(err),
+			zap.String("id", containerID),
+			zap.String("path", srcPath),
+		)
+		writeError(w, http.StatusInternalServerError, "Failed to copy from container")
+		return
+	}
+	defer reader.Close()
+
+	if stat.Mode.IsDir() {
+		w.Header().Set("Content-Type", "application/x-tar")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", path.Base(srcPath)+".tar"))
+		h.sendDownload(w, r, &downloadLimitReader{r: reader, remaining: maxDownloadSize}, containerID, srcPath)
+		return
+	}
+
+	tr := tar.NewReader(reader)
+	header, err := tr.Next()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to read archive from container")
+		return
+	}
+	// The file may have grown since it was stat'ed
+	if header.Size > maxDownloadSize {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Path exceeds %d bytes", maxDownloadSize))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", path.Base(header.Name)))
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", header.Size))
+	h.sendDownload(w, r, tr, containerID, srcPath)
+}
+
+// sendDownload streams a download whose headers are set. The status is sent by then,
+// so a copy that fails partway aborts the response; the client sees a broken download
+// rather than a short file that looks complete.
+func (h *ContainerHandler) sendDownload(w http.ResponseWriter, r *http.Request, content io.Reader, containerID, srcPath string) {
+	if _, err := io.Copy(w, content); err != nil {
+		if errors.Is(err, errDownloadTooLarge) {
+			h.logger.Warn("Download from container exceeds the size limit",
+				zap.String("id", containerID),
+				zap.String("path", srcPath),
+			)
+		} else if r.Context().Err() == nil {
+			h.logger.Error("Failed to send file from container",
+				zap.Error(err),
+				zap.String("id", containerID),
+				zap.String("path", srcPath),
+			)
+		}
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// errDownloadTooLarge aborts a directory download that passes maxDownloadSize
+var errDownloadTooLarge = errors.New("download exceeds size limit")
+
+// downloadLimitReader passes through at most remaining bytes, failing once the stream
+// goes past them
+type downloadLimitReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (l *downloadLimitReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, errDownloadTooLarge
+	}
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return 0, errDownloadTooLarge
+	}
+	return n, err
+}
+
+// sanitizeContainerPath validates a user-supplied path inside a container
+func sanitizeContainerPath(p string) (string, error) {
+	if p == "" {
+		return "", fmt.Errorf("path is required")
+	}
+	if !strings.HasPrefix(p, "/") {
+		return "", fmt.Errorf("path must be absolute")
+	}
+	if strings.ContainsRune(p, 0) {
+		return "", fmt.Errorf("path contains invalid characters")
+	}
+	for _, part := range strings.Split(p, "/") {
+		if part == ".." {
+			return "", fmt.Errorf("path must not contain '..'")
+		}
+	}
+
+	cleaned := path.Clean(p)
+	for _, denied := range deniedContainerPaths {
+		if cleaned == denied || strings.HasPrefix(cleaned, denied+"/") {
+			return "", fmt.Errorf("access to %s is not allowed", denied)
+		}
+	}
+
+	return cleaned, nil
+}
@@ -2,9 +2,11 @@ package handlers
 
 import (
 	"encoding/json"
-	"io"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
@@ -14,8 +16,9 @@ import (
 
 // ContainerHandler handles container management endpoints
 type ContainerHandler struct {
-	dockerClient *docker.Client
+	dockerClient docker.ContainerRuntime
 	logger       *zap.Logger
+	captureImage string
 }
 
 // CreateContainerRequest represents a request to create a container
@@ -44,16 +47,28 @@ type ContainerResponse struct {
 }
 
 // NewContainerHandler creates a new container handler
-func NewContainerHandler(dockerClient *docker.Client, logger *zap.Logger) *ContainerHandler {
+func NewContainerHandler(dockerClient docker.ContainerRuntime, logger *zap.Logger) *ContainerHandler {
 	return &ContainerHandler{
 		dockerClient: dockerClient,
 		logger:       logger,
 	}
 }
 
-// List returns all containers
+// containerSorts compares containers by each sortable key of the container list
+var containerSorts = map[string]func(a, b docker.ContainerInfo) int{
+	"name":       func(a, b docker.ContainerInfo) int { return strings.Compare(a.Name, b.Name) },
+	"state":      func(a, b docker.ContainerInfo) int { return strings.Compare(a.State, b.State) },
+	"created_at": func(a, b docker.ContainerInfo) int { return a.CreatedAt.Compare(b.CreatedAt) },
+}
+
+// List returns containers, filtered by state (status=) and name
 func (h *ContainerHandler) List(w http.ResponseWriter, r *http.Request) {
 	all := r.URL.Query().Get("all") == "true"
+	params, err := parseListParams(r, []string{"name", "state", "created_at"}, "-created_at")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
 	containers, err := h.dockerClient.ListContainers(r.Context(), all)
 	if err != nil {
@@ -62,8 +77,19 @@ func (h *ContainerHandler) List(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	response := make([]ContainerResponse, 0, len(containers))
+	matched := make([]docker.ContainerInfo, 0, len(containers))
 	for _, c := range containers {
+		if params.Status != "" && c.State != params.Status {
+			continue
+		}
+		if params.matchesName(c.Name) {
+			matched = append(matched, c)
+		}
+	}
+	page, total := sortAndPage(matched, params, containerSorts)
+
+	response := make([]ContainerResponse, 0, len(page))
+	for _, c := range page {
 		response = append(response, ContainerResponse{
 			ID:        c.ID,
 			Name:      c.Name,
@@ -77,7 +103,7 @@ func (h *ContainerHandler) List(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
-	writeJSON(w, http.StatusOK, response)
+	writeList(w, r, response, total, params)
 }
 
 // Create creates a new container
@@ -296,21 +322,36 @@ func (h *ContainerHandler) Logs(w http.ResponseWriter, r *http.Request) {
 		tail = "100"
 	}
 
-	logs, err := h.dockerClient.GetContainerLogs(r.Context(), containerID, follow, tail)
+	flusher, _ := w.(http.Flusher)
+	started := false
+
+	// Write demultiplexed lines as "<timestamp> <stream> <message>"
+	err := h.dockerClient.StreamLogLines(r.Context(), containerID, docker.LogOptions{Follow: follow, Tail: tail}, func(line docker.LogLine) error {
+		if !started {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.WriteHeader(http.StatusOK)
+			started = true
+		}
+		if _, err := fmt.Fprintf(w, "%s %s %s\n", line.Timestamp.UTC().Format(time.RFC3339Nano), line.Stream, line.Message); err != nil {
+			return err
+		}
+		if follow && flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	})
 	if err != nil {
 		h.logger.Error("Failed to get logs", zap.Error(err), zap.String("id", containerID))
-		writeError(w, http.StatusInternalServerError, "Failed to get logs")
+		if !started {
+			writeError(w, http.StatusInternalServerError, "Failed to get logs")
+		}
 		return
 	}
-	defer logs.Close()
 
-	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-	if follow {
-		w.Header().Set("Transfer-Encoding", "chunked")
+	if !started {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
 	}
-
-	// Stream logs to response
-	io.Copy(w, logs)
 }
 
 // Helper functions
@@ -319,9 +360,3 @@ func writeJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(data)
 }
-
-func writeError(w http.ResponseWriter, status int, message string) {
-	writeJSON(w, status, map[string]string{
-		"error": message,
-	})
-}
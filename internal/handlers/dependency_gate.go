@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	chimw "github.com/go-chi/chi/v5/middleware"
+
+	"github.com/nanopaas/nanopaas/internal/infrastructure/breaker"
+)
+
+// RequireDependencies refuses requests with 503 while any of the breakers is open, so
+// a dependency outage sheds load with a clear error instead of every request waiting on
+// timeouts and failing with a 500. A server error triggers early probes, so outages
+// that begin between probes are noticed quickly.
+func RequireDependencies(breakers ...*breaker.Breaker) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if len(breakers) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, b := range breakers {
+				if !b.Allow() {
+					w.Header().Set("Retry-After", strconv.Itoa(int(b.Interval().Seconds())))
+					writeAPIError(w, &APIError{
+						Status:  http.StatusServiceUnavailable,
+						Code:    CodeDependencyDown,
+						Message: b.Name() + " is unreachable; requests resume once it is back",
+						Details: b.Status(),
+					})
+					return
+				}
+			}
+
+			ww := chimw.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+			if ww.Status() >= http.StatusInternalServerError {
+				for _, b := range breakers {
+					b.CheckSoon()
+				}
+			}
+		})
+	}
+}
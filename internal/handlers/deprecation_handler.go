@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/middleware"
+)
+
+// DeprecatedRoutes is the central table of deprecated API routes. Add an entry when a
+// route is superseded; the Deprecation and Sunset headers and usage counts follow from it.
+var DeprecatedRoutes = []middleware.Deprecation{
+	{
+		Method:    http.MethodPost,
+		Pattern:   "/api/v1/apps/{appId}/incident",
+		Since:     time.Date(2026, time.October, 17, 0, 0, 0, 0, time.UTC),
+		Sunset:    time.Date(2027, time.April, 1, 0, 0, 0, 0, time.UTC),
+		Successor: "/api/v2/apps/{appId}/incidents",
+		Note:      "Open incidents with POST /apps/{appId}/incidents",
+	},
+}
+
+// DeprecationHandler reports calls to deprecated API routes
+type DeprecationHandler struct {
+	tracker *middleware.DeprecationTracker
+	logger  *zap.Logger
+}
+
+// NewDeprecationHandler creates a new deprecation handler
+func NewDeprecationHandler(tracker *middleware.DeprecationTracker, logger *zap.Logger) *DeprecationHandler {
+	return &DeprecationHandler{
+		tracker: tracker,
+		logger:  logger,
+	}
+}
+
+// Usage returns how often each deprecated route has been called since startup
+func (h *DeprecationHandler) Usage(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	usage := h.tracker.Usage()
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"deprecations": usage,
+		"total":        len(usage),
+	})
+}
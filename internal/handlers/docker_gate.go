@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	chimw "github.com/go-chi/chi/v5/middleware"
+
+	"github.com/nanopaas/nanopaas/internal/infrastructure/docker"
+)
+
+// RequireDocker refuses requests with 503 while the container runtime is unreachable, so
+// operations that need it fail fast with a clear error instead of an opaque 500. Routes
+// served from the database and memory stay ungated and keep working during an outage.
+// A server error from a gated route triggers an early probe, so outages that begin
+// between probes are noticed quickly.
+func RequireDocker(availability *docker.Availability) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !availability.Available() {
+				w.Header().Set("Retry-After", strconv.Itoa(int(availability.Interval().Seconds())))
+				writeAPIError(w, &APIError{
+					Status:  http.StatusServiceUnavailable,
+					Code:    CodeRuntimeDown,
+					Message: "Container runtime is unreachable; container operations resume once it is back",
+					Details: availability.Status(),
+				})
+				return
+			}
+
+			ww := chimw.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+			if ww.Status() >= http.StatusInternalServerError {
+				availability.CheckSoon()
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+	"github.com/nanopaas/nanopaas/internal/middleware"
+)
+
+// Error codes reported in ErrorResponse.Code
+const (
+	CodeBadRequest     = "bad_request"
+	CodeUnauthorized   = "unauthorized"
+	CodeForbidden      = "forbidden"
+	CodeNotFound       = "not_found"
+	CodeConflict       = "conflict"
+	CodeTooLarge       = "payload_too_large"
+	CodeUnprocessable  = "unprocessable"
+	CodeQuotaExceeded  = "quota_exceeded"
+	CodeRateLimited    = "rate_limited"
+	CodeInternal       = "internal_error"
+	CodeNotImplemented = "not_implemented"
+	CodeUnavailable    = "unavailable"
+	CodeRuntimeDown    = "runtime_unavailable"    // the container runtime cannot be reached
+	CodeDependencyDown = "dependency_unavailable" // the database or another dependency cannot be reached
+	CodeTimeout        = "timeout"
+
+	// The two-factor policy blocks the user until they enroll at /auth/2fa/enroll
+	CodeTwoFactorEnrollment = "two_factor_enrollment_required"
+)
+
+// ErrorResponse is the body of every API error. Error is the human-readable message,
+// Code a stable identifier to branch on, and Details any structured context.
+type ErrorResponse struct {
+	Error     string      `json:"error"`
+	Code      string      `json:"code"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"request_id,omitempty"`
+}
+
+// APIError is an error carrying the status and code it is reported with
+type APIError struct {
+	Status  int
+	Code    string
+	Message string
+	Details interface{}
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+// statusCodes maps HTTP statuses to their default error code
+var statusCodes = map[int]string{
+	http.StatusBadRequest:            CodeBadRequest,
+	http.StatusUnauthorized:          CodeUnauthorized,
+	http.StatusForbidden:             CodeForbidden,
+	http.StatusNotFound:              CodeNotFound,
+	http.StatusConflict:              CodeConflict,
+	http.StatusRequestEntityTooLarge: CodeTooLarge,
+	http.StatusUnprocessableEntity:   CodeUnprocessable,
+	http.StatusTooManyRequests:       CodeRateLimited,
+	http.StatusNotImplemented:        CodeNotImplemented,
+	http.StatusServiceUnavailable:    CodeUnavailable,
+	http.StatusGatewayTimeout:        CodeTimeout,
+}
+
+// codeForStatus returns the default error code of an HTTP status
+func codeForStatus(status int) string {
+	if code, ok := statusCodes[status]; ok {
+		return code
+	}
+	if status >= http.StatusInternalServerError {
+		return CodeInternal
+	}
+	return CodeBadRequest
+}
+
+// writeError writes an error with the status's default code
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeAPIError(w, &APIError{Status: status, Code: codeForStatus(status), Message: message})
+}
+
+// writeErrorDetails writes an error with structured details, e.g. the conflicting resource
+func writeErrorDetails(w http.ResponseWriter, status int, message string, details interface{}) {
+	writeAPIError(w, &APIError{Status: status, Code: codeForStatus(status), Message: message, Details: details})
+}
+
+// writeAPIError writes an APIError, echoing the request ID set by middleware.ExposeRequestID
+func writeAPIError(w http.ResponseWriter, e *APIError) {
+	writeJSON(w, e.Status, ErrorResponse{
+		Error:     e.Message,
+		Code:      e.Code,
+		Details:   e.Details,
+		RequestID: w.Header().Get(middleware.RequestIDHeader),
+	})
+}
+
+// domainErrorStatus maps an error to the status and code it is reported with, falling
+// back to 500 for errors that wrap no domain sentinel
+func domainErrorStatus(err error) (int, string) {
+	var apiErr *APIError
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr.Status, apiErr.Code
+	case errors.Is(err, domain.ErrNotFound):
+		return http.StatusNotFound, CodeNotFound
+	case errors.Is(err, domain.ErrConflict):
+		return http.StatusConflict, CodeConflict
+	case errors.Is(err, domain.ErrInvalid):
+		return http.StatusBadRequest, CodeBadRequest
+	case errors.Is(err, domain.ErrForbidden):
+		return http.StatusForbidden, CodeForbidden
+	case errors.Is(err, domain.ErrQuotaExceeded):
+		return http.StatusTooManyRequests, CodeQuotaExceeded
+	}
+	return http.StatusInternalServerError, CodeInternal
+}
+
+// writeDomainError writes err with the status its domain sentinel maps to. Mapped errors
+// are reported with their own message; anything else is an internal error reported
+// as message so store details do not leak.
+func writeDomainError(w http.ResponseWriter, err error, message string) {
+	status, code := domainErrorStatus(err)
+	if status != http.StatusInternalServerError {
+		message = err.Error()
+	}
+	writeAPIError(w, &APIError{Status: status, Code: code, Message: message})
+}
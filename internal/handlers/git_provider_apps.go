@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+	"github.com/nanopaas/nanopaas/internal/services/gitprovider"
+)
+
+// Note: GitProviderHandler and NewGitProviderHandler are defined in git_provider_handler.go
+// This file links apps to repositories on any git provider through the existing GitProviderHandler
+
+// SetGitHubHandler sets the handler whose tokens reach GitHub repositories, preferring
+// GitHub App installation tokens over users' OAuth tokens
+func (h *GitProviderHandler) SetGitHubHandler(github *GitHubHandler) {
+	h.github = github
+}
+
+// SetWebhookApps sets where webhooks deleted from repositories are forgotten on the apps
+// they were created for
+func (h *GitProviderHandler) SetWebhookApps(apps GitHubWebhookApps) {
+	h.apps = apps
+}
+
+// ConnectRepository looks up a repository on a provider as user and, when webhookURL is
+// set, adds a push webhook pointed at it, signed with webhookSecret. The webhook is nil
+// when none was added.
+func (h *GitProviderHandler) ConnectRepository(ctx context.Context, user *domain.User, provider, owner, repo, webhookURL, webhookSecret string) (*gitprovider.Repository, *domain.GitHubWebhook, error) {
+	p, err := h.providers.Get(provider)
+	if err != nil {
+		return nil, nil, err
+	}
+	token, err := h.userToken(ctx, user, p, owner, repo)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	repository, err := p.GetRepository(ctx, token, owner, repo)
+	if err != nil {
+		return nil, nil, err
+	}
+	if webhookURL == "" {
+		return repository, nil, nil
+	}
+	hook, err := p.CreateWebhook(ctx, token, owner, repo, webhookURL, webhookSecret)
+	if err != nil {
+		return nil, nil, err
+	}
+	return repository, &domain.GitHubWebhook{
+		ID:        hook.ID,
+		Provider:  p.Name(),
+		Owner:     owner,
+		Repo:      repo,
+		CreatedAt: time.Now().UTC(),
+	}, nil
+}
+
+// RemoveGitHubWebhook deletes an app's webhook from the provider it is on, acting as user
+func (h *GitProviderHandler) RemoveGitHubWebhook(ctx context.Context, user *domain.User, hook *domain.GitHubWebhook) error {
+	p, err := h.providers.Get(hook.ProviderName())
+	if err != nil {
+		return err
+	}
+	token, err := h.userToken(ctx, user, p, hook.Owner, hook.Repo)
+	if err != nil {
+		return err
+	}
+	return p.DeleteWebhook(ctx, token, hook.Owner, hook.Repo, hook.ID)
+}
+
+// userToken returns the token calls about a repository on a provider are made with as
+// user. GitHub tokens come from the GitHub handler when it is set.
+func (h *GitProviderHandler) userToken(ctx context.Context, user *domain.User, p gitprovider.Provider, owner, repo string) (string, error) {
+	if p.Name() != domain.GitProviderGitHub {
+		if user == nil {
+			return "", gitprovider.ErrNotConnected
+		}
+		return h.providers.Token(ctx, user.ID, p.Name())
+	}
+	if h.github != nil {
+		if token, err := h.github.tokenFor(ctx, user, owner, repo); err == nil {
+			return token, nil
+		}
+	} else if user != nil && user.GitHubToken != "" {
+		return user.GitHubToken, nil
+	}
+	return "", gitprovider.ErrNotConnected
+}
+
+var _ GitHubWebhookRemover = (*GitProviderHandler)(nil)
@@ -0,0 +1,459 @@
+package handlers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+	"github.com/nanopaas/nanopaas/internal/services/gitprovider"
+)
+
+// gitConnectStateTTL is how long a user has to finish connecting a provider by OAuth
+const gitConnectStateTTL = 10 * time.Minute
+
+// GitConnectionStore persists and lists users' connections to git providers
+type GitConnectionStore interface {
+	gitprovider.ConnectionStore
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]*domain.GitConnection, error)
+	Delete(ctx context.Context, userID uuid.UUID, provider string) error
+}
+
+// GitProviderHandler lists repositories and manages webhooks on any git provider, and
+// connects users to providers other than GitHub, which is connected by signing in
+type GitProviderHandler struct {
+	providers   *gitprovider.Registry
+	connections GitConnectionStore
+	frontendURL string
+	stateKey    []byte
+	github      *GitHubHandler
+	apps        GitHubWebhookApps
+	logger      *zap.Logger
+}
+
+// NewGitProviderHandler creates a new git provider handler. stateKey signs OAuth state;
+// replicas need the same key, and a random one is used when empty.
+func NewGitProviderHandler(providers *gitprovider.Registry, connections GitConnectionStore, frontendURL string, stateKey []byte, logger *zap.Logger) *GitProviderHandler {
+	if len(stateKey) == 0 {
+		stateKey = randomKey()
+	}
+	return &GitProviderHandler{
+		providers:   providers,
+		connections: connections,
+		frontendURL: frontendURL,
+		stateKey:    stateKey,
+		logger:      logger,
+	}
+}
+
+// GitProviderResponse describes a provider and the user's connection to it
+type GitProviderResponse struct {
+	Name      string     `json:"name"`
+	OAuth     bool       `json:"oauth"` // users can connect by OAuth, not only with a token
+	Connected bool       `json:"connected"`
+	Username  string     `json:"username,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// GitTokenRequest represents a request to connect a provider with a personal access token
+type GitTokenRequest struct {
+	Token string `json:"token"`
+}
+
+// GitWebhookRequest represents a request to add a push webhook to a repository
+type GitWebhookRequest struct {
+	URL string `json:"url"`
+}
+
+// ListProviders lists the configured providers and whether the user is connected to each
+func (h *GitProviderHandler) ListProviders(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r.Context())
+	conns, err := h.connections.ListByUser(r.Context(), user.ID)
+	if err != nil {
+		h.logger.Error("Failed to list git connections", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "Failed to list git providers")
+		return
+	}
+	byProvider := make(map[string]*domain.GitConnection, len(conns))
+	for _, conn := range conns {
+		byProvider[conn.Provider] = conn
+	}
+
+	providers := make([]GitProviderResponse, 0)
+	for _, name := range h.providers.Names() {
+		p, _ := h.providers.Get(name)
+		resp := GitProviderResponse{Name: name}
+		if oauth, ok := p.(gitprovider.OAuthProvider); ok {
+			resp.OAuth = oauth.OAuthConfigured()
+		}
+		if name == domain.GitProviderGitHub {
+			resp.Connected = user.GitHubToken != ""
+			resp.Username = user.GitHubLogin
+		} else if conn, ok := byProvider[name]; ok {
+			resp.Connected = true
+			resp.Username = conn.Username
+			resp.ExpiresAt = conn.ExpiresAt
+		}
+		providers = append(providers, resp)
+	}
+	writeJSON(w, http.StatusOK, providers)
+}
+
+// Connect returns the URL that connects the user to a provider by OAuth
+func (h *GitProviderHandler) Connect(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.connectable(w, r)
+	if !ok {
+		return
+	}
+	oauth, ok := p.(gitprovider.OAuthProvider)
+	if !ok || !oauth.OAuthConfigured() {
+		writeError(w, http.StatusBadRequest, "OAuth is not configured for "+p.Name()+"; connect with a personal access token")
+		return
+	}
+
+	state := h.signConnectState(GetUserFromContext(r.Context()).ID, p.Name())
+	writeJSON(w, http.StatusOK, map[string]string{"url": oauth.GetAuthURL(state)})
+}
+
+// Callback finishes an OAuth connection and sends the browser back to the frontend
+func (h *GitProviderHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "provider")
+	p, err := h.providers.Get(name)
+	oauth, ok := p.(gitprovider.OAuthProvider)
+	if err != nil || !ok {
+		writeError(w, http.StatusNotFound, "Unknown git provider")
+		return
+	}
+
+	userID, ok := h.verifyConnectState(r.URL.Query().Get("state"), name)
+	if !ok {
+		h.redirectConnected(w, r, name, "invalid_state")
+		return
+	}
+	code := r.URL.Query().Get("code")
+	if code == "" {
+		h.redirectConnected(w, r, name, "missing_code")
+		return
+	}
+
+	token, err := oauth.ExchangeCode(r.Context(), code)
+	if err != nil {
+		h.logger.Error("Failed to exchange git provider code", zap.String("provider", name), zap.Error(err))
+		h.redirectConnected(w, r, name, "exchange_failed")
+		return
+	}
+	if err := h.saveConnection(r, userID, p, token); err != nil {
+		h.logger.Error("Failed to save git connection", zap.String("provider", name), zap.Error(err))
+		h.redirectConnected(w, r, name, "connect_failed")
+		return
+	}
+	h.redirectConnected(w, r, name, "")
+}
+
+// SetToken connects the user to a provider with a personal access token
+func (h *GitProviderHandler) SetToken(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.connectable(w, r)
+	if !ok {
+		return
+	}
+	var req GitTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Token == "" {
+		writeError(w, http.StatusBadRequest, "token is required")
+		return
+	}
+
+	user := GetUserFromContext(r.Context())
+	if err := h.saveConnection(r, user.ID, p, &gitprovider.Token{AccessToken: req.Token}); err != nil {
+		h.logger.Warn("Failed to connect git provider", zap.String("provider", p.Name()), zap.Error(err))
+		writeError(w, http.StatusBadRequest, "Token was rejected by "+p.Name())
+		return
+	}
+	h.ListProviders(w, r)
+}
+
+// Disconnect forgets the user's token for a provider
+func (h *GitProviderHandler) Disconnect(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.connectable(w, r)
+	if !ok {
+		return
+	}
+	if err := h.connections.Delete(r.Context(), GetUserFromContext(r.Context()).ID, p.Name()); err != nil {
+		writeDomainError(w, err, "Failed to disconnect git provider")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Disconnected from " + p.Name()})
+}
+
+// ListRepositories lists the repositories the user can access on a provider, paged with
+// page and per_page
+func (h *GitProviderHandler) ListRepositories(w http.ResponseWriter, r *http.Request) {
+	p, token, ok := h.providerToken(w, r)
+	if !ok {
+		return
+	}
+	page, perPage, err := parsePageParams(r.URL.Query().Get("page"), r.URL.Query().Get("per_page"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	repos, err := p.ListRepositories(r.Context(), token, page, perPage)
+	if err != nil {
+		h.logger.Error("Failed to list repositories", zap.String("provider", p.Name()), zap.Error(err))
+		writeGitHubError(w, err, http.StatusBadGateway, "Failed to fetch repositories")
+		return
+	}
+	writeJSON(w, http.StatusOK, repos)
+}
+
+// GetRepository gets a repository
+func (h *GitProviderHandler) GetRepository(w http.ResponseWriter, r *http.Request) {
+	p, token, ok := h.providerToken(w, r)
+	if !ok {
+		return
+	}
+	owner, repo := chi.URLParam(r, "owner"), chi.URLParam(r, "repo")
+
+	repository, err := p.GetRepository(r.Context(), token, owner, repo)
+	if err != nil {
+		h.logger.Error("Failed to get repository", zap.String("provider", p.Name()), zap.Error(err))
+		writeGitHubError(w, err, http.StatusBadGateway, "Failed to fetch repository")
+		return
+	}
+	writeJSON(w, http.StatusOK, repository)
+}
+
+// ListBranches lists a repository's branches
+func (h *GitProviderHandler) ListBranches(w http.ResponseWriter, r *http.Request) {
+	p, token, ok := h.providerToken(w, r)
+	if !ok {
+		return
+	}
+	owner, repo := chi.URLParam(r, "owner"), chi.URLParam(r, "repo")
+
+	branches, err := p.ListBranches(r.Context(), token, owner, repo)
+	if err != nil {
+		h.logger.Error("Failed to list branches",
+			zap.String("provider", p.Name()),
+			zap.String("repo", owner+"/"+repo),
+			zap.Error(err),
+		)
+		writeGitHubError(w, err, http.StatusBadGateway, "Failed to list branches")
+		return
+	}
+	writeJSON(w, http.StatusOK, branches)
+}
+
+// ListWebhooks lists a repository's webhooks
+func (h *GitProviderHandler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	p, token, ok := h.providerToken(w, r)
+	if !ok {
+		return
+	}
+	owner, repo := chi.URLParam(r, "owner"), chi.URLParam(r, "repo")
+
+	hooks, err := p.ListWebhooks(r.Context(), token, owner, repo)
+	if err != nil {
+		h.logger.Error("Failed to list webhooks",
+			zap.String("provider", p.Name()),
+			zap.String("repo", owner+"/"+repo),
+			zap.Error(err),
+		)
+		writeGitHubError(w, err, http.StatusBadGateway, "Failed to list webhooks")
+		return
+	}
+	writeJSON(w, http.StatusOK, hooks)
+}
+
+// CreateWebhook adds a push webhook to a repository, usually pointed at an app's
+// /api/v1/webhooks/{provider}/{appId}
+func (h *GitProviderHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	p, token, ok := h.providerToken(w, r)
+	if !ok {
+		return
+	}
+	owner, repo := chi.URLParam(r, "owner"), chi.URLParam(r, "repo")
+
+	var req GitWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if u, err := url.Parse(req.URL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		writeError(w, http.StatusBadRequest, "url must be an http or https URL")
+		return
+	}
+
+	hook, err := p.CreateWebhook(r.Context(), token, owner, repo, req.URL, "")
+	if err != nil {
+		h.logger.Error("Failed to create webhook",
+			zap.String("provider", p.Name()),
+			zap.String("repo", owner+"/"+repo),
+			zap.Error(err),
+		)
+		writeGitHubError(w, err, http.StatusBadGateway, "Failed to create webhook")
+		return
+	}
+	writeJSON(w, http.StatusCreated, hook)
+}
+
+// DeleteWebhook deletes a repository webhook, and forgets it on the app it was created
+// for
+func (h *GitProviderHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	p, token, ok := h.providerToken(w, r)
+	if !ok {
+		return
+	}
+	owner, repo := chi.URLParam(r, "owner"), chi.URLParam(r, "repo")
+	hookID, err := strconv.ParseInt(chi.URLParam(r, "webhookId"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid webhook ID")
+		return
+	}
+
+	if err := p.DeleteWebhook(r.Context(), token, owner, repo, hookID); err != nil {
+		h.logger.Error("Failed to delete webhook",
+			zap.String("provider", p.Name()),
+			zap.String("repo", owner+"/"+repo),
+			zap.Int64("hook_id", hookID),
+			zap.Error(err),
+		)
+		writeGitHubError(w, err, http.StatusBadGateway, "Failed to delete webhook")
+		return
+	}
+	if h.apps != nil {
+		h.apps.ForgetGitHubWebhook(r.Context(), p.Name(), owner, repo, hookID)
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Webhook deleted"})
+}
+
+// provider returns the provider named in the path, writing 404 when there is none
+func (h *GitProviderHandler) provider(w http.ResponseWriter, r *http.Request) (gitprovider.Provider, bool) {
+	p, err := h.providers.Get(chi.URLParam(r, "provider"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Unknown git provider")
+		return nil, false
+	}
+	return p, true
+}
+
+// connectable returns the provider named in the path when users connect to it here,
+// which excludes GitHub
+func (h *GitProviderHandler) connectable(w http.ResponseWriter, r *http.Request) (gitprovider.Provider, bool) {
+	p, ok := h.provider(w, r)
+	if ok && p.Name() == domain.GitProviderGitHub {
+		writeError(w, http.StatusBadRequest, "GitHub is connected by signing in with GitHub")
+		return nil, false
+	}
+	return p, ok
+}
+
+// providerToken returns the provider named in the path and the user's token for it,
+// writing 401 when the user isn't connected
+func (h *GitProviderHandler) providerToken(w http.ResponseWriter, r *http.Request) (gitprovider.Provider, string, bool) {
+	p, ok := h.provider(w, r)
+	if !ok {
+		return nil, "", false
+	}
+	user := GetUserFromContext(r.Context())
+	if p.Name() == domain.GitProviderGitHub {
+		if user.GitHubToken == "" {
+			writeError(w, http.StatusUnauthorized, "GitHub not connected")
+			return nil, "", false
+		}
+		return p, user.GitHubToken, true
+	}
+
+	token, err := h.providers.Token(r.Context(), user.ID, p.Name())
+	if err != nil {
+		if !errors.Is(err, gitprovider.ErrNotConnected) {
+			h.logger.Warn("Failed to get git provider token", zap.String("provider", p.Name()), zap.Error(err))
+		}
+		writeError(w, http.StatusUnauthorized, p.Name()+" not connected")
+		return nil, "", false
+	}
+	return p, token, true
+}
+
+// saveConnection stores a user's token for a provider once the provider accepts it
+func (h *GitProviderHandler) saveConnection(r *http.Request, userID uuid.UUID, p gitprovider.Provider, token *gitprovider.Token) error {
+	account, err := p.GetUser(r.Context(), token.AccessToken)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	return h.connections.Save(r.Context(), &domain.GitConnection{
+		UserID:       userID,
+		Provider:     p.Name(),
+		Username:     account.Username,
+		Token:        token.AccessToken,
+		RefreshToken: token.RefreshToken,
+		ExpiresAt:    token.ExpiresAt,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	})
+}
+
+// redirectConnected sends the browser back to the frontend's git settings after an OAuth
+// connection, with an error code when it failed
+func (h *GitProviderHandler) redirectConnected(w http.ResponseWriter, r *http.Request, provider, errCode string) {
+	params := url.Values{"provider": {provider}}
+	if errCode != "" {
+		params.Set("error", errCode)
+	}
+	http.Redirect(w, r, h.frontendURL+"/settings/git?"+params.Encode(), http.StatusTemporaryRedirect)
+}
+
+// signConnectState returns OAuth state naming the user connecting a provider, valid for
+// gitConnectStateTTL. The callback is unauthenticated, so the state is what ties the
+// token to the user.
+func (h *GitProviderHandler) signConnectState(userID uuid.UUID, provider string) string {
+	expires := strconv.FormatInt(time.Now().Add(gitConnectStateTTL).Unix(), 10)
+	value := base64.RawURLEncoding.EncodeToString([]byte(userID.String() + "|" + provider + "|" + expires))
+	return value + "." + h.stateSignature(value)
+}
+
+// verifyConnectState returns the user named by state signed for provider
+func (h *GitProviderHandler) verifyConnectState(state, provider string) (uuid.UUID, bool) {
+	value, signature, ok := strings.Cut(state, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(h.stateSignature(value))) {
+		return uuid.Nil, false
+	}
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return uuid.Nil, false
+	}
+	parts := strings.Split(string(data), "|")
+	if len(parts) != 3 || parts[1] != provider {
+		return uuid.Nil, false
+	}
+	expires, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return uuid.Nil, false
+	}
+	userID, err := uuid.Parse(parts[0])
+	return userID, err == nil
+}
+
+// stateSignature signs OAuth state
+func (h *GitProviderHandler) stateSignature(value string) string {
+	mac := hmac.New(sha256.New, h.stateKey)
+	mac.Write([]byte("git_connect=" + value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
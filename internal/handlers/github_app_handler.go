@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+	"github.com/nanopaas/nanopaas/internal/services/github"
+)
+
+// Note: GitHubHandler and NewGitHubHandler are defined in auth_handler.go
+// This file adds GitHub App installations to the existing GitHubHandler
+
+// errGitHubNotConnected is returned when neither the GitHub App nor the user's OAuth
+// token can reach a repository
+var errGitHubNotConnected = errors.New("GitHub not connected and the GitHub App is not installed on the repository")
+
+// GitHubAppResponse reports whether a GitHub App is set up and where to install it
+type GitHubAppResponse struct {
+	Configured bool   `json:"configured"`
+	InstallURL string `json:"install_url,omitempty"`
+}
+
+// InstallationReposResponse is a page of the repositories an installation can access
+type InstallationReposResponse struct {
+	Repositories []github.Repository `json:"repositories"`
+	Total        int                 `json:"total"`
+}
+
+// UpdateAppWebhookRequest represents a request to change where the App's webhooks go
+type UpdateAppWebhookRequest struct {
+	URL string `json:"url"`
+}
+
+// GetApp reports whether a GitHub App is set up and where users install it
+func (h *GitHubHandler) GetApp(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, GitHubAppResponse{
+		Configured: h.githubService.AppConfigured(),
+		InstallURL: h.githubService.InstallURL(),
+	})
+}
+
+// ListInstallations lists the accounts the GitHub App is installed on
+func (h *GitHubHandler) ListInstallations(w http.ResponseWriter, r *http.Request) {
+	if !h.requireApp(w) {
+		return
+	}
+
+	installations, err := h.githubService.ListInstallations(r.Context())
+	if err != nil {
+		h.logger.Error("Failed to list GitHub App installations", zap.Error(err))
+		writeGitHubError(w, err, http.StatusBadGateway, "Failed to list GitHub App installations")
+		return
+	}
+	writeJSON(w, http.StatusOK, installations)
+}
+
+// ListInstallationRepos lists the repositories an installation can access, paged
+// with page and per_page
+func (h *GitHubHandler) ListInstallationRepos(w http.ResponseWriter, r *http.Request) {
+	if !h.requireApp(w) {
+		return
+	}
+	installationID, err := strconv.ParseInt(chi.URLParam(r, "installationId"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid installation ID")
+		return
+	}
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+	if perPage > 100 {
+		perPage = 100
+	}
+
+	repos, total, err := h.githubService.ListInstallationRepositories(r.Context(), installationID, page, perPage)
+	if err != nil {
+		h.logger.Error("Failed to list installation repositories",
+			zap.Int64("installation_id", installationID),
+			zap.Error(err),
+		)
+		writeGitHubError(w, err, http.StatusBadGateway, "Failed to list installation repositories")
+		return
+	}
+	writeJSON(w, http.StatusOK, InstallationReposResponse{Repositories: repos, Total: total})
+}
+
+// GetAppWebhook returns where the App's webhooks are delivered
+func (h *GitHubHandler) GetAppWebhook(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) || !h.requireApp(w) {
+		return
+	}
+
+	config, err := h.githubService.GetAppWebhookConfig(r.Context())
+	if err != nil {
+		h.logger.Error("Failed to get GitHub App webhook", zap.Error(err))
+		writeGitHubError(w, err, http.StatusBadGateway, "Failed to get GitHub App webhook")
+		return
+	}
+	writeJSON(w, http.StatusOK, config)
+}
+
+// UpdateAppWebhook points the App's webhooks at a URL, usually this server's
+// /webhooks/github. One App webhook covers every repository the App is installed on.
+func (h *GitHubHandler) UpdateAppWebhook(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) || !h.requireApp(w) {
+		return
+	}
+
+	var req UpdateAppWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if u, err := url.Parse(req.URL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		writeError(w, http.StatusBadRequest, "url must be an http or https URL")
+		return
+	}
+
+	config, err := h.githubService.UpdateAppWebhookConfig(r.Context(), req.URL)
+	if err != nil {
+		h.logger.Error("Failed to update GitHub App webhook", zap.Error(err))
+		writeGitHubError(w, err, http.StatusBadGateway, "Failed to update GitHub App webhook")
+		return
+	}
+	writeJSON(w, http.StatusOK, config)
+}
+
+// requireApp writes 503 when no GitHub App is configured
+func (h *GitHubHandler) requireApp(w http.ResponseWriter) bool {
+	if !h.githubService.AppConfigured() {
+		writeError(w, http.StatusServiceUnavailable, "GitHub App is not configured")
+		return false
+	}
+	return true
+}
+
+// repoToken returns the token calls about a repository are made with, writing 401
+// when there is none
+func (h *GitHubHandler) repoToken(w http.ResponseWriter, r *http.Request, owner, repo string) (string, bool) {
+	token, err := h.tokenFor(r.Context(), GetUserFromContext(r.Context()), owner, repo)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "GitHub access token required")
+		return "", false
+	}
+	return token, true
+}
+
+// tokenFor returns the token calls about a repository are made with: an installation
+// token when the GitHub App is installed on it, or else the user's OAuth token
+func (h *GitHubHandler) tokenFor(ctx context.Context, user *domain.User, owner, repo string) (string, error) {
+	if h.githubService.AppConfigured() {
+		token, err := h.githubService.RepoToken(ctx, owner, repo)
+		if err == nil {
+			return token, nil
+		}
+		if !errors.Is(err, github.ErrNotInstalled) {
+			h.logger.Warn("Failed to get GitHub App installation token",
+				zap.String("repo", owner+"/"+repo),
+				zap.Error(err),
+			)
+		}
+	}
+
+	if user == nil || user.GitHubToken == "" {
+		return "", errGitHubNotConnected
+	}
+	return user.GitHubToken, nil
+}
@@ -1,11 +1,18 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
 	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
 )
 
 // Note: GitHubHandler and NewGitHubHandler are defined in auth_handler.go
@@ -21,20 +28,19 @@ func (h *GitHubHandler) ListBranches(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user := GetUserFromContext(r.Context())
-	if user == nil || user.GitHubToken == "" {
-		writeError(w, http.StatusUnauthorized, "GitHub access token required")
+	token, ok := h.repoToken(w, r, owner, repo)
+	if !ok {
 		return
 	}
 
-	branches, err := h.githubService.ListBranches(r.Context(), user.GitHubToken, owner, repo)
+	branches, err := h.githubService.ListBranches(r.Context(), token, owner, repo)
 	if err != nil {
 		h.logger.Error("Failed to list branches",
 			zap.String("owner", owner),
 			zap.String("repo", repo),
 			zap.Error(err),
 		)
-		writeError(w, http.StatusInternalServerError, "Failed to list branches")
+		writeGitHubError(w, err, http.StatusInternalServerError, "Failed to list branches")
 		return
 	}
 
@@ -47,9 +53,48 @@ type WebhookRequest struct {
 	Repo   string   `json:"repo"`
 	Events []string `json:"events"`
 	URL    string   `json:"url"`
+	AppID  string   `json:"app_id,omitempty"` // records the webhook on the app, deleting it with the app
+}
+
+// GitHubWebhookApps records the GitHub webhooks created for apps
+type GitHubWebhookApps interface {
+	CheckGitHubWebhookApp(ctx context.Context, user *domain.User, appID uuid.UUID) error
+	SetGitHubWebhook(ctx context.Context, appID uuid.UUID, hook *domain.GitHubWebhook)
+	WebhookSecret(ctx context.Context, appID uuid.UUID) (string, error)
+	ForgetGitHubWebhook(ctx context.Context, provider, owner, repo string, hookID int64)
+}
+
+// SetWebhookApps sets where webhooks created for apps are recorded
+func (h *GitHubHandler) SetWebhookApps(apps GitHubWebhookApps) {
+	h.apps = apps
+}
+
+// ListWebhooks lists a repository's webhooks
+func (h *GitHubHandler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	owner := chi.URLParam(r, "owner")
+	repo := chi.URLParam(r, "repo")
+
+	token, ok := h.repoToken(w, r, owner, repo)
+	if !ok {
+		return
+	}
+
+	hooks, err := h.githubService.ListWebhooks(r.Context(), token, owner, repo)
+	if err != nil {
+		h.logger.Error("Failed to list webhooks",
+			zap.String("owner", owner),
+			zap.String("repo", repo),
+			zap.Error(err),
+		)
+		writeGitHubError(w, err, http.StatusInternalServerError, "Failed to list webhooks")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, hooks)
 }
 
-// CreateWebhook creates a GitHub webhook
+// CreateWebhook creates a GitHub webhook. With app_id, the webhook is recorded on the
+// app and deleted from GitHub when the app is.
 func (h *GitHubHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
 	var req WebhookRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -62,58 +107,121 @@ func (h *GitHubHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user := GetUserFromContext(r.Context())
-	if user == nil || user.GitHubToken == "" {
-		writeError(w, http.StatusUnauthorized, "GitHub access token required")
+	var appID uuid.UUID
+	if req.AppID != "" {
+		var err error
+		if appID, err = uuid.Parse(req.AppID); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid app ID")
+			return
+		}
+		if h.apps == nil {
+			writeError(w, http.StatusServiceUnavailable, "App webhooks are not enabled")
+			return
+		}
+		if err := h.apps.CheckGitHubWebhookApp(r.Context(), GetUserFromContext(r.Context()), appID); err != nil {
+			switch {
+			case errors.Is(err, domain.ErrConflict):
+				writeError(w, http.StatusConflict, "App already has a GitHub webhook; delete it first")
+			case errors.Is(err, domain.ErrForbidden):
+				writeError(w, http.StatusForbidden, "Only the app owner, its team and admins can add its webhook")
+			default:
+				writeDomainError(w, err, "Failed to create webhook")
+			}
+			return
+		}
+	}
+
+	token, ok := h.repoToken(w, r, req.Owner, req.Repo)
+	if !ok {
 		return
 	}
 
-	err := h.githubService.CreateWebhook(r.Context(), user.GitHubToken, req.Owner, req.Repo, req.URL)
+	// App webhooks are signed with the app's own secret, others with the global one
+	var secret string
+	if appID != uuid.Nil {
+		var err error
+		if secret, err = h.apps.WebhookSecret(r.Context(), appID); err != nil {
+			h.logger.Error("Failed to get app webhook secret", zap.String("app_id", appID.String()), zap.Error(err))
+			writeDomainError(w, err, "Failed to create webhook")
+			return
+		}
+	}
+
+	hook, err := h.githubService.CreateWebhook(r.Context(), token, req.Owner, req.Repo, req.URL, secret)
 	if err != nil {
 		h.logger.Error("Failed to create webhook",
 			zap.String("owner", req.Owner),
 			zap.String("repo", req.Repo),
 			zap.Error(err),
 		)
-		writeError(w, http.StatusInternalServerError, "Failed to create webhook")
+		writeGitHubError(w, err, http.StatusInternalServerError, "Failed to create webhook")
 		return
 	}
 
+	if appID != uuid.Nil {
+		h.apps.SetGitHubWebhook(r.Context(), appID, &domain.GitHubWebhook{
+			ID:        hook.ID,
+			Owner:     req.Owner,
+			Repo:      req.Repo,
+			CreatedAt: time.Now().UTC(),
+		})
+	}
+
 	h.logger.Info("Webhook created",
 		zap.String("owner", req.Owner),
 		zap.String("repo", req.Repo),
+		zap.Int64("hook_id", hook.ID),
 	)
 
-	writeJSON(w, http.StatusCreated, map[string]string{
-		"message": "Webhook created successfully",
-	})
+	writeJSON(w, http.StatusCreated, hook)
 }
 
-// DeleteWebhook deletes a GitHub webhook
+// DeleteWebhook deletes a GitHub webhook, and forgets it on the app it was created for
 func (h *GitHubHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
 	owner := chi.URLParam(r, "owner")
 	repo := chi.URLParam(r, "repo")
-	webhookID := chi.URLParam(r, "webhookId")
+	hookID, err := strconv.ParseInt(chi.URLParam(r, "webhookId"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid webhook ID")
+		return
+	}
 
-	if owner == "" || repo == "" || webhookID == "" {
-		writeError(w, http.StatusBadRequest, "Owner, repo, and webhookId are required")
+	token, ok := h.repoToken(w, r, owner, repo)
+	if !ok {
 		return
 	}
 
-	// Note: DeleteWebhook is not implemented in the GitHub service yet
-	// This is a placeholder that returns success
-	h.logger.Warn("DeleteWebhook called but not fully implemented",
-		zap.String("owner", owner),
-		zap.String("repo", repo),
-		zap.String("webhook_id", webhookID),
-	)
+	if err := h.githubService.DeleteWebhook(r.Context(), token, owner, repo, hookID); err != nil {
+		h.logger.Error("Failed to delete webhook",
+			zap.String("owner", owner),
+			zap.String("repo", repo),
+			zap.Int64("hook_id", hookID),
+			zap.Error(err),
+		)
+		writeGitHubError(w, err, http.StatusInternalServerError, "Failed to delete webhook")
+		return
+	}
+	if h.apps != nil {
+		h.apps.ForgetGitHubWebhook(r.Context(), domain.GitProviderGitHub, owner, repo, hookID)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Webhook deleted"})
+}
 
-	writeJSON(w, http.StatusOK, map[string]string{"message": "Webhook deletion not implemented"})
+// RemoveGitHubWebhook deletes an app's webhook from GitHub, acting as user when the
+// GitHub App can't reach the repository
+func (h *GitHubHandler) RemoveGitHubWebhook(ctx context.Context, user *domain.User, hook *domain.GitHubWebhook) error {
+	token, err := h.tokenFor(ctx, user, hook.Owner, hook.Repo)
+	if err != nil {
+		return err
+	}
+	return h.githubService.DeleteWebhook(ctx, token, hook.Owner, hook.Repo, hook.ID)
 }
 
 // Ensure github_handler.go extends the GitHubHandler from auth_handler.go
 var _ interface {
 	ListBranches(w http.ResponseWriter, r *http.Request)
+	ListWebhooks(w http.ResponseWriter, r *http.Request)
 	CreateWebhook(w http.ResponseWriter, r *http.Request)
 	DeleteWebhook(w http.ResponseWriter, r *http.Request)
 } = (*GitHubHandler)(nil)
@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/nanopaas/nanopaas/internal/services/github"
+)
+
+// writeGitHubError reports a failed GitHub call. When GitHub's rate limit ran out it
+// answers 429 with Retry-After and the reset time, since the call will succeed then;
+// otherwise it writes status and message.
+func writeGitHubError(w http.ResponseWriter, err error, status int, message string) {
+	var limitErr *github.RateLimitError
+	if !errors.As(err, &limitErr) {
+		writeError(w, status, message)
+		return
+	}
+	retryAfter := int(math.Ceil(limitErr.RetryAfter().Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	writeErrorDetails(w, http.StatusTooManyRequests, "GitHub rate limit exceeded; try again after it resets", map[string]interface{}{
+		"reset_at":    limitErr.Reset.UTC(),
+		"retry_after": retryAfter,
+		"secondary":   limitErr.Secondary,
+	})
+}
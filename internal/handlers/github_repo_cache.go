@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+	"github.com/nanopaas/nanopaas/internal/services/github"
+)
+
+// Note: GitHubHandler and NewGitHubHandler are defined in auth_handler.go
+// This file adds per-user caching of repository listings to the existing GitHubHandler
+
+// Page sizes for git provider repository listings
+const (
+	defaultRepoPerPage = 30
+	maxRepoPerPage     = 100
+)
+
+// RepoCache keeps users' repository listings for a short while
+type RepoCache interface {
+	// Get returns a cached listing, or nil when there is none
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, data []byte) error
+}
+
+// SetRepoCache sets where repository listings are cached; without one, every listing
+// is fetched from GitHub
+func (h *GitHubHandler) SetRepoCache(cache RepoCache) {
+	h.repoCache = cache
+}
+
+// cachedRepositories returns a user's cached listing, fetching and caching it when there
+// is none. Cache failures are logged and the listing is fetched from GitHub.
+func (h *GitHubHandler) cachedRepositories(ctx context.Context, user *domain.User, variant string, fetch func(context.Context) ([]github.Repository, error)) ([]github.Repository, error) {
+	if h.repoCache == nil {
+		return fetch(ctx)
+	}
+
+	key := "github:" + user.ID.String() + ":" + variant
+	data, err := h.repoCache.Get(ctx, key)
+	if err != nil {
+		h.logger.Warn("Failed to read repository cache", zap.Error(err))
+	}
+	if data != nil {
+		var repos []github.Repository
+		if err := json.Unmarshal(data, &repos); err == nil {
+			return repos, nil
+		}
+	}
+
+	repos, err := fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if data, err := json.Marshal(repos); err == nil {
+		if err := h.repoCache.Set(ctx, key, data); err != nil {
+			h.logger.Warn("Failed to cache repositories", zap.Error(err))
+		}
+	}
+	return repos, nil
+}
+
+// parsePageParams reads GitHub-style page and per_page parameters, defaulting to the
+// first page of defaultRepoPerPage
+func parsePageParams(pageParam, perPageParam string) (int, int, error) {
+	page, perPage := 1, defaultRepoPerPage
+	if pageParam != "" {
+		p, err := strconv.Atoi(pageParam)
+		if err != nil || p < 1 {
+			return 0, 0, fmt.Errorf("page must be a positive integer")
+		}
+		page = p
+	}
+	if perPageParam != "" {
+		p, err := strconv.Atoi(perPageParam)
+		if err != nil || p < 1 {
+			return 0, 0, fmt.Errorf("per_page must be a positive integer")
+		}
+		perPage = min(p, maxRepoPerPage)
+	}
+	return page, perPage, nil
+}
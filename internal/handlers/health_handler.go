@@ -4,30 +4,44 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"strings"
 	"time"
 
 	"go.uber.org/zap"
 
+	"github.com/nanopaas/nanopaas/internal/infrastructure/breaker"
 	"github.com/nanopaas/nanopaas/internal/infrastructure/docker"
 )
 
 // HealthHandler handles health check endpoints
 type HealthHandler struct {
-	dockerClient *docker.Client
+	dockerClient docker.ContainerRuntime
+	dependencies []readinessCheck
 	logger       *zap.Logger
 	startTime    time.Time
 }
 
+// readinessCheck is a dependency reported by Ready
+type readinessCheck struct {
+	breaker  *breaker.Breaker
+	required bool // the instance is not ready while it is down
+}
+
 // HealthResponse represents the health check response
 type HealthResponse struct {
 	Status    string            `json:"status"`
 	Timestamp string            `json:"timestamp"`
 	Uptime    string            `json:"uptime"`
 	Checks    map[string]string `json:"checks,omitempty"`
+
+	Connection *docker.ConnectionStatus `json:"connection,omitempty"`
 }
 
+// certExpiryWarning is how far ahead an expiring Docker TLS certificate is reported
+const certExpiryWarning = 14 * 24 * time.Hour
+
 // NewHealthHandler creates a new health handler
-func NewHealthHandler(dockerClient *docker.Client, logger *zap.Logger) *HealthHandler {
+func NewHealthHandler(dockerClient docker.ContainerRuntime, logger *zap.Logger) *HealthHandler {
 	return &HealthHandler{
 		dockerClient: dockerClient,
 		logger:       logger,
@@ -55,14 +69,29 @@ func (h *HealthHandler) DockerHealth(w http.ResponseWriter, r *http.Request) {
 	checks := make(map[string]string)
 	status := "ok"
 
-	// Check Docker connection
-	if err := h.dockerClient.Ping(ctx); err != nil {
-		checks["docker"] = "unhealthy: " + err.Error()
+	// Check Docker connection, including transport and TLS details for remote daemons
+	conn := h.dockerClient.ConnectionStatus(ctx)
+	if !conn.Reachable {
+		checks["docker"] = "unhealthy: " + conn.Error
 		status = "degraded"
-		h.logger.Warn("Docker health check failed", zap.Error(err))
+		h.logger.Warn("Docker health check failed", zap.String("host", conn.Host), zap.String("error", conn.Error))
 	} else {
 		checks["docker"] = "healthy"
 	}
+	checks["runtime"] = conn.Runtime
+
+	if conn.CertExpiresAt != nil {
+		remaining := time.Until(*conn.CertExpiresAt)
+		switch {
+		case remaining <= 0:
+			checks["docker_tls_cert"] = "expired"
+			status = "degraded"
+		case remaining < certExpiryWarning:
+			checks["docker_tls_cert"] = "expires in " + remaining.Round(time.Hour).String()
+		default:
+			checks["docker_tls_cert"] = "valid"
+		}
+	}
 
 	// Get Docker info
 	if info, err := h.dockerClient.Info(ctx); err == nil {
@@ -75,6 +104,8 @@ func (h *HealthHandler) DockerHealth(w http.ResponseWriter, r *http.Request) {
 		Timestamp: time.Now().UTC().Format(time.RFC3339),
 		Uptime:    time.Since(h.startTime).String(),
 		Checks:    checks,
+
+		Connection: &conn,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -84,19 +115,51 @@ func (h *HealthHandler) DockerHealth(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-// Ready returns readiness status
+// AddDependency reports a dependency's breaker in Ready. A required dependency makes the
+// instance not ready while its breaker is open; an optional one is only listed.
+func (h *HealthHandler) AddDependency(b *breaker.Breaker, required bool) {
+	h.dependencies = append(h.dependencies, readinessCheck{breaker: b, required: required})
+}
+
+// Ready returns readiness status, with the state of each dependency in checks
 func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
 	// Check if Docker is available
 	if err := h.dockerClient.Ping(ctx); err != nil {
-		http.Error(w, "not ready: docker unavailable", http.StatusServiceUnavailable)
+		writeError(w, http.StatusServiceUnavailable, "not ready: docker unavailable")
+		return
+	}
+
+	// Dependencies are probed in the background; report their last known state
+	checks := map[string]string{"docker": "ok"}
+	var down []string
+	for _, dep := range h.dependencies {
+		status := dep.breaker.Status()
+		switch {
+		case status.State == breaker.Closed:
+			checks[status.Name] = "ok"
+		case dep.required:
+			checks[status.Name] = "unavailable: " + status.LastError
+			down = append(down, status.Name)
+		default:
+			checks[status.Name] = "degraded: " + status.LastError
+		}
+	}
+	if len(down) > 0 {
+		writeAPIError(w, &APIError{
+			Status:  http.StatusServiceUnavailable,
+			Code:    CodeDependencyDown,
+			Message: "not ready: " + strings.Join(down, ", ") + " unavailable",
+			Details: checks,
+		})
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{
+	json.NewEncoder(w).Encode(map[string]interface{}{
 		"status": "ready",
+		"checks": checks,
 	})
 }
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/middleware"
+)
+
+// IdempotencyHeader is the request header carrying a client's retry-safe key
+const IdempotencyHeader = "Idempotency-Key"
+
+// IdempotentReplayHeader marks a response replayed for a repeated key
+const IdempotentReplayHeader = "Idempotent-Replayed"
+
+// maxIdempotencyKeyLength bounds keys so they cannot bloat the store
+const maxIdempotencyKeyLength = 255
+
+// IdempotencyStore claims idempotency keys and keeps the responses sent for them
+type IdempotencyStore interface {
+	// Reserve claims key, or returns false with the stored snapshot (nil while the
+	// first request is still running) when it was already used
+	Reserve(ctx context.Context, key string) (bool, []byte, error)
+	Save(ctx context.Context, key string, snapshot []byte) error
+	Release(ctx context.Context, key string) error
+}
+
+// idempotentResponse is the snapshot of a response replayed for a repeated key
+type idempotentResponse struct {
+	Fingerprint string      `json:"fingerprint"` // hash of the request body
+	Status      int         `json:"status"`
+	Header      http.Header `json:"header"`
+	Body        []byte      `json:"body"`
+}
+
+// Idempotency replays the first response to requests that repeat a key, so retried
+// deploys, scales, builds and webhook deliveries run once
+type Idempotency struct {
+	store  IdempotencyStore
+	logger *zap.Logger
+}
+
+// NewIdempotency creates idempotency middleware backed by store
+func NewIdempotency(store IdempotencyStore, logger *zap.Logger) *Idempotency {
+	return &Idempotency{store: store, logger: logger}
+}
+
+// Middleware applies idempotency to requests sending an Idempotency-Key header
+func (i *Idempotency) Middleware(next http.Handler) http.Handler {
+	return i.KeyedBy(IdempotencyHeader)(next)
+}
+
+// KeyedBy applies idempotency keyed by another request header, e.g. the delivery ID of a
+// webhook. Requests without the header are served as usual. Keys are scoped to the user
+// and path, a key reused with a different body is rejected, and 5xx responses are not
+// kept so the request can be retried.
+func (i *Idempotency) KeyedBy(header string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(header)
+			if key == "" || i.store == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				writeError(w, http.StatusBadRequest, header+" must be at most 255 characters")
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "Failed to read request body")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			bodyHash := sha256.Sum256(body)
+			fingerprint := hex.EncodeToString(bodyHash[:])
+
+			storeKey := idempotencyScope(r, key)
+			reserved, snapshot, err := i.store.Reserve(r.Context(), storeKey)
+			if err != nil {
+				// Serving the request beats failing it when the store is down
+				i.logger.Warn("Idempotency store unavailable", zap.Error(err))
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !reserved {
+				i.replay(w, header, fingerprint, snapshot)
+				return
+			}
+
+			rec := &idempotencyRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+
+			// Store with a fresh context; the request's may already be canceled
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if rec.status >= http.StatusInternalServerError {
+				if err := i.store.Release(ctx, storeKey); err != nil {
+					i.logger.Warn("Failed to release idempotency key", zap.Error(err))
+				}
+				return
+			}
+			// A replay answers a new request, which keeps its own request ID
+			replayHeader := rec.Header().Clone()
+			replayHeader.Del(middleware.RequestIDHeader)
+			data, err := json.Marshal(idempotentResponse{
+				Fingerprint: fingerprint,
+				Status:      rec.status,
+				Header:      replayHeader,
+				Body:        rec.body.Bytes(),
+			})
+			if err == nil {
+				err = i.store.Save(ctx, storeKey, data)
+			}
+			if err != nil {
+				i.logger.Warn("Failed to save idempotent response", zap.Error(err))
+			}
+		})
+	}
+}
+
+// replay writes the stored response of a repeated key
+func (i *Idempotency) replay(w http.ResponseWriter, header, fingerprint string, snapshot []byte) {
+	if snapshot == nil {
+		writeError(w, http.StatusConflict, "A request with this "+header+" is still in progress")
+		return
+	}
+	var resp idempotentResponse
+	if err := json.Unmarshal(snapshot, &resp); err != nil {
+		i.logger.Warn("Failed to decode idempotent response", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "Failed to replay response")
+		return
+	}
+	if resp.Fingerprint != fingerprint {
+		writeError(w, http.StatusUnprocessableEntity, header+" was already used with a different request body")
+		return
+	}
+
+	for name, values := range resp.Header {
+		w.Header()[name] = values
+	}
+	w.Header().Set(IdempotentReplayHeader, "true")
+	w.WriteHeader(resp.Status)
+	w.Write(resp.Body)
+}
+
+// idempotencyScope keys a request by user, method and path so clients cannot collide
+// with each other's keys
+func idempotencyScope(r *http.Request, key string) string {
+	user := "anonymous"
+	if u := GetUserFromContext(r.Context()); u != nil {
+		user = u.ID.String()
+	}
+	sum := sha256.Sum256([]byte(user + "\x00" + r.Method + "\x00" + r.URL.Path + "\x00" + key))
+	return hex.EncodeToString(sum[:])
+}
+
+// idempotencyRecorder passes a response through while keeping a copy
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *idempotencyRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *idempotencyRecorder) Write(b []byte) (int, error) {
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+	"github.com/nanopaas/nanopaas/internal/infrastructure/docker"
+)
+
+// AppLister interface for looking up apps that reference an image, and the apps a user
+// can see images of
+type AppLister interface {
+	ListApps() []*domain.App
+	VisibleApps(ctx context.Context, user *domain.User) []*domain.App
+}
+
+// ImageHandler handles image inspection endpoints
+type ImageHandler struct {
+	dockerClient docker.ContainerRuntime
+	appLister    AppLister
+	logger       *zap.Logger
+}
+
+// ImageAppRef describes an app that references an image
+type ImageAppRef struct {
+	ID   string `json:"id"`
+	Slug string `json:"slug"`
+	Role string `json:"role"` // "current" or "previous"
+}
+
+// ImageResponse represents an image in API responses
+type ImageResponse struct {
+	ID         string            `json:"id"`
+	Tags       []string          `json:"tags"`
+	Size       int64             `json:"size"`
+	SharedSize int64             `json:"shared_size"`
+	CreatedAt  string            `json:"created_at"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	BuildID    string            `json:"build_id,omitempty"`
+	AppSlug    string            `json:"app_slug,omitempty"`
+	Apps       []ImageAppRef     `json:"apps"`
+	Containers []string          `json:"containers"`
+	InUse      bool              `json:"in_use"`
+}
+
+// ImageLayerResponse represents a layer from an image's history
+type ImageLayerResponse struct {
+	ID        string `json:"id,omitempty"`
+	CreatedBy string `json:"created_by"`
+	Size      int64  `json:"size"`
+	CreatedAt string `json:"created_at"`
+	Comment   string `json:"comment,omitempty"`
+}
+
+// ImageDetailResponse represents a single image with layers and history
+type ImageDetailResponse struct {
+	ImageResponse
+	Architecture string               `json:"architecture"`
+	OS           string               `json:"os"`
+	Layers       []string             `json:"layers"`
+	History      []ImageLayerResponse `json:"history"`
+}
+
+// NewImageHandler creates a new image handler
+func NewImageHandler(dockerClient docker.ContainerRuntime, logger *zap.Logger) *ImageHandler {
+	return &ImageHandler{
+		dockerClient: dockerClient,
+		logger:       logger,
+	}
+}
+
+// SetAppLister sets the app source used to report which apps reference an image. Users
+// only see the images of apps they can see; without it, only admins see images.
+func (h *ImageHandler) SetAppLister(lister AppLister) {
+	h.appLister = lister
+}
+
+// visibleSlugs returns the slugs of the apps the user can see, or nil for admins, who
+// see every app
+func (h *ImageHandler) visibleSlugs(r *http.Request) map[string]bool {
+	user := GetUserFromContext(r.Context())
+	if user != nil && user.IsAdmin() {
+		return nil
+	}
+	slugs := make(map[string]bool)
+	if user == nil || h.appLister == nil {
+		return slugs
+	}
+	for _, app := range h.appLister.VisibleApps(r.Context(), user) {
+		slugs[app.Slug] = true
+	}
+	return slugs
+}
+
+// List returns the NanoPaaS-built images of the apps the user can see, with sizes and
+// references
+func (h *ImageHandler) List(w http.ResponseWriter, r *http.Request) {
+	images, err := h.dockerClient.ListImages(r.Context())
+	if err != nil {
+		h.logger.Error("Failed to list images", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "Failed to list images")
+		return
+	}
+
+	containers := h.containersByImage(r)
+	visible := h.visibleSlugs(r)
+
+	response := make([]ImageResponse, 0, len(images))
+	for _, img := range images {
+		if visible != nil && !visible[img.Labels["nanopaas.app.slug"]] {
+			continue
+		}
+		resp := ImageResponse{
+			ID:         img.ID,
+			Tags:       img.RepoTags,
+			Size:       img.Size,
+			SharedSize: img.SharedSize,
+			CreatedAt:  time.Unix(img.Created, 0).UTC().Format(time.RFC3339),
+			Labels:     img.Labels,
+		}
+		h.annotate(&resp, containers, visible)
+		response = append(response, resp)
+	}
+
+	sort.Slice(response, func(i, j int) bool {
+		return response[i].CreatedAt > response[j].CreatedAt
+	})
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// Get returns a single image with its layers and build history
+func (h *ImageHandler) Get(w http.ResponseWriter, r *http.Request) {
+	imageID := chi.URLParam(r, "id")
+	if imageID == "" {
+		writeError(w, http.StatusBadRequest, "Image ID is required")
+		return
+	}
+
+	info, err := h.dockerClient.InspectImage(r.Context(), imageID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Image not found")
+		return
+	}
+
+	var labels map[string]string
+	if info.Config != nil {
+		labels = info.Config.Labels
+	}
+	if labels["built-by"] != "nanopaas" {
+		writeError(w, http.StatusNotFound, "Image not found")
+		return
+	}
+	visible := h.visibleSlugs(r)
+	if visible != nil && !visible[labels["nanopaas.app.slug"]] {
+		writeError(w, http.StatusNotFound, "Image not found")
+		return
+	}
+
+	history, err := h.dockerClient.ImageHistory(r.Context(), info.ID)
+	if err != nil {
+		h.logger.Error("Failed to get image history", zap.Error(err), zap.String("image", imageID))
+		writeError(w, http.StatusInternalServerError, "Failed to get image history")
+		return
+	}
+
+	resp := ImageDetailResponse{
+		ImageResponse: ImageResponse{
+			ID:        info.ID,
+			Tags:      info.RepoTags,
+			Size:      info.Size,
+			CreatedAt: info.Created,
+			Labels:    labels,
+		},
+		Architecture: info.Architecture,
+		OS:           info.Os,
+		Layers:       info.RootFS.Layers,
+		History:      make([]ImageLayerResponse, 0, len(history)),
+	}
+	h.annotate(&resp.ImageResponse, h.containersByImage(r), visible)
+
+	for _, item := range history {
+		layer := ImageLayerResponse{
+			CreatedBy: item.CreatedBy,
+			Size:      item.Size,
+			CreatedAt: time.Unix(item.Created, 0).UTC().Format(time.RFC3339),
+			Comment:   item.Comment,
+		}
+		if item.ID != "<missing>" {
+			layer.ID = item.ID
+		}
+		resp.History = append(resp.History, layer)
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// annotate fills in build, app and container references for an image. With visible,
+// only those apps are listed.
+func (h *ImageHandler) annotate(resp *ImageResponse, containers map[string][]string, visible map[string]bool) {
+	resp.BuildID = resp.Labels["nanopaas.build.id"]
+	resp.AppSlug = resp.Labels["nanopaas.app.slug"]
+	resp.Apps = []ImageAppRef{}
+	resp.Containers = []string{}
+
+	refs := append([]string{resp.ID}, resp.Tags...)
+	for _, ref := range refs {
+		resp.Containers = append(resp.Containers, containers[ref]...)
+	}
+
+	if h.appLister != nil {
+		for _, app := range h.appLister.ListApps() {
+			if visible != nil && !visible[app.Slug] {
+				continue
+			}
+			switch {
+			case matchesImage(app.CurrentImageID, refs):
+				resp.Apps = append(resp.Apps, ImageAppRef{ID: app.ID.String(), Slug: app.Slug, Role: "current"})
+			case matchesImage(app.PreviousImageID, refs):
+				resp.Apps = append(resp.Apps, ImageAppRef{ID: app.ID.String(), Slug: app.Slug, Role: "previous"})
+			}
+		}
+	}
+
+	resp.InUse = len(resp.Containers) > 0 || len(resp.Apps) > 0
+}
+
+// containersByImage maps image references to the containers created from them
+func (h *ImageHandler) containersByImage(r *http.Request) map[string][]string {
+	result := make(map[string][]string)
+
+	containers, err := h.dockerClient.ListContainers(r.Context(), true)
+	if err != nil {
+		h.logger.Warn("Failed to list containers for image references", zap.Error(err))
+		return result
+	}
+
+	for _, c := range containers {
+		result[c.Image] = append(result[c.Image], c.ID)
+	}
+	return result
+}
+
+// matchesImage reports whether an app image reference points at one of the image's IDs or tags
+func matchesImage(ref string, refs []string) bool {
+	if ref == "" {
+		return false
+	}
+	for _, candidate := range refs {
+		if ref == candidate || strings.TrimPrefix(candidate, "sha256:") == ref {
+			return true
+		}
+	}
+	return false
+}
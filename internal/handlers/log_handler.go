@@ -2,8 +2,12 @@ package handlers
 
 import (
 	"context"
-	"io"
+	"errors"
+	"fmt"
 	"net/http"
+	"sort"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -11,35 +15,128 @@ import (
 	"github.com/gorilla/websocket"
 	"go.uber.org/zap"
 
+	"github.com/nanopaas/nanopaas/internal/domain"
 	"github.com/nanopaas/nanopaas/internal/infrastructure/docker"
+	"github.com/nanopaas/nanopaas/internal/services/logstream"
 	ws "github.com/nanopaas/nanopaas/pkg/websocket"
 )
 
-var logUpgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		return true // Allow all origins for development
-	},
-}
-
 // LogHandler handles log streaming endpoints
 type LogHandler struct {
-	dockerClient *docker.Client
+	dockerClient docker.ContainerRuntime
 	wsHub        *ws.Hub
+	wsAuth       *WSAuthenticator
+	streamer     *logstream.Streamer
+	logStore     LogSearcher
+	builds       BuildLookup
+	buildLogs    BuildLogReader
 	logger       *zap.Logger
 }
 
+// LogSearcher queries stored app log history
+type LogSearcher interface {
+	Search(ctx context.Context, q domain.AppLogQuery) ([]domain.AppLogEntry, error)
+}
+
+// BuildLookup retrieves stored builds
+type BuildLookup interface {
+	GetByID(ctx context.Context, id uuid.UUID) (*domain.Build, error)
+}
+
+// BuildLogReader reads archived build logs a page at a time
+type BuildLogReader interface {
+	// List returns up to limit lines after the given line number, in order
+	List(ctx context.Context, buildID uuid.UUID, after, limit int) ([]domain.BuildLogLine, error)
+}
+
+// Stored log lines returned by default and at most
+const (
+	defaultLogSearchLimit = 500
+	maxLogSearchLimit     = 5000
+)
+
+// BuildLogPage is a page of a build's archived log
+type BuildLogPage struct {
+	BuildID   uuid.UUID             `json:"build_id"`
+	Status    domain.BuildStatus    `json:"status"`
+	Lines     []domain.BuildLogLine `json:"lines"`
+	Count     int                   `json:"count"`
+	NextAfter int                   `json:"next_after"` // after for the next page
+	HasMore   bool                  `json:"has_more"`
+}
+
+// Archived build log lines returned by default and at most per page
+const (
+	defaultBuildLogLimit = 1000
+	maxBuildLogLimit     = 10000
+)
+
+// LogEntry is a container log line in API responses
+type LogEntry struct {
+	ContainerID   string `json:"container_id"`
+	ContainerName string `json:"container_name,omitempty"`
+	docker.LogLine
+}
+
+// logConn serializes writes from concurrent container log streams to one WebSocket
+type logConn struct {
+	mu    sync.Mutex
+	conn  *websocket.Conn
+	topic string
+}
+
+// Send writes a message in the hub's envelope so direct streams look like hub topics
+func (c *logConn) Send(messageType string, payload interface{}) error {
+	data, err := ws.NewEnvelope(c.topic, messageType, payload)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// sendError writes an error message
+func (c *logConn) sendError(message string) {
+	c.Send("error", map[string]string{"error": message})
+}
+
+// logSender writes enveloped messages to a direct stream, WebSocket or Server-Sent Events
+type logSender interface {
+	Send(messageType string, payload interface{}) error
+	sendError(message string)
+}
+
+// containerLogTopic names a single container's log stream in message envelopes
+func containerLogTopic(containerID string) string {
+	return "container:" + docker.ShortID(containerID) + ":logs"
+}
+
 // NewLogHandler creates a new log handler
-func NewLogHandler(dockerClient *docker.Client, wsHub *ws.Hub, logger *zap.Logger) *LogHandler {
+func NewLogHandler(dockerClient docker.ContainerRuntime, wsHub *ws.Hub, wsAuth *WSAuthenticator, streamer *logstream.Streamer, logger *zap.Logger) *LogHandler {
 	return &LogHandler{
 		dockerClient: dockerClient,
 		wsHub:        wsHub,
+		wsAuth:       wsAuth,
+		streamer:     streamer,
 		logger:       logger,
 	}
 }
 
+// SetLogStore enables searching stored log history
+func (h *LogHandler) SetLogStore(store LogSearcher) {
+	h.logStore = store
+}
+
+// SetBuildArchive enables reading the full logs of finished builds
+func (h *LogHandler) SetBuildArchive(builds BuildLookup, logs BuildLogReader) {
+	h.builds = builds
+	h.buildLogs = logs
+}
+
 // GetAppLogs returns recent logs for an app (HTTP)
+// Query params: tail, since. With from, to, q, stream or source=history the stored log
+// history is searched instead, which includes containers removed since.
 func (h *LogHandler) GetAppLogs(w http.ResponseWriter, r *http.Request) {
 	appID := chi.URLParam(r, "appId")
 	if appID == "" {
@@ -47,11 +144,21 @@ func (h *LogHandler) GetAppLogs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if isLogHistoryQuery(r) {
+		h.searchAppLogs(w, r, appID)
+		return
+	}
+
 	// Get query parameters
 	tail := r.URL.Query().Get("tail")
 	if tail == "" {
 		tail = "100"
 	}
+	since, err := parseLogSince(r.URL.Query().Get("since"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
 	// Find containers for this app
 	allContainers, err := h.dockerClient.ListContainers(r.Context(), true)
@@ -71,7 +178,7 @@ func (h *LogHandler) GetAppLogs(w http.ResponseWriter, r *http.Request) {
 
 	if len(containers) == 0 {
 		writeJSON(w, http.StatusOK, map[string]interface{}{
-			"logs":       []string{},
+			"logs":       []LogEntry{},
 			"containers": 0,
 			"message":    "No running containers",
 		})
@@ -79,9 +186,9 @@ func (h *LogHandler) GetAppLogs(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Collect logs from all containers
-	var allLogs []string
+	allLogs := make([]LogEntry, 0)
 	for _, container := range containers {
-		logs, err := h.getContainerLogs(r.Context(), container.ID, tail)
+		lines, err := h.dockerClient.ContainerLogLines(r.Context(), container.ID, docker.LogOptions{Tail: tail, Since: since})
 		if err != nil {
 			h.logger.Warn("Failed to get logs for container",
 				zap.String("container_id", container.ID),
@@ -89,9 +196,16 @@ func (h *LogHandler) GetAppLogs(w http.ResponseWriter, r *http.Request) {
 			)
 			continue
 		}
-		allLogs = append(allLogs, logs...)
+		for _, line := range lines {
+			allLogs = append(allLogs, LogEntry{ContainerID: container.ID, ContainerName: container.Name, LogLine: line})
+		}
 	}
 
+	// Interleave containers chronologically
+	sort.SliceStable(allLogs, func(i, j int) bool {
+		return allLogs[i].Timestamp.Before(allLogs[j].Timestamp)
+	})
+
 	writeJSON(w, http.StatusOK, map[string]interface{}{
 		"logs":       allLogs,
 		"containers": len(containers),
@@ -100,59 +214,184 @@ func (h *LogHandler) GetAppLogs(w http.ResponseWriter, r *http.Request) {
 }
 
 // StreamAppLogs streams logs via WebSocket
+// Query params: tail (lines per container, default 50), since (RFC3339 time or duration such as 10m).
+// Recent lines are sent first, then live lines of every replica through the app's log topic,
+// including replicas started later by scaling or redeploys. Each line is tagged with its
+// container, replica and stream.
 func (h *LogHandler) StreamAppLogs(w http.ResponseWriter, r *http.Request) {
 	appID := chi.URLParam(r, "appId")
 	if appID == "" {
-		http.Error(w, "App ID required", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, "App ID required")
+		return
+	}
+
+	opts, err := streamLogOptions(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	user, ok := h.wsAuth.AuthorizeApp(w, r, appID)
+	if !ok {
 		return
 	}
+	appUUID, _ := uuid.Parse(appID)
 
 	// Upgrade to WebSocket
-	conn, err := logUpgrader.Upgrade(w, r, nil)
+	conn, err := h.wsAuth.Upgrade(w, r)
 	if err != nil {
 		h.logger.Error("WebSocket upgrade failed", zap.Error(err))
 		return
 	}
-	defer conn.Close()
 
-	// Find containers for this app
-	allContainers, err := h.dockerClient.ListContainers(r.Context(), true)
+	client := ws.NewClient(h.wsHub, conn)
+	client.SetAuthorizer(h.wsAuth.TopicAuthorizer(user))
+	h.wsHub.Register(client)
+	h.wsHub.Subscribe(client, logstream.Topic(appUUID))
+	h.streamer.Watch(appUUID)
+	subscribedAt := time.Now()
+
+	// Live lines queue in the client's buffer while the backlog is written directly
+	h.writeLogBacklog(r.Context(), &logConn{conn: conn, topic: logstream.Topic(appUUID)}, appID, opts, subscribedAt)
+
+	h.logger.Debug("Client subscribed to app logs",
+		zap.String("app_id", appID),
+		zap.String("client_id", client.ID.String()),
+	)
+
+	go client.WritePump()
+	go client.ReadPump()
+}
+
+// StreamAppLogsSSE streams an app's logs as Server-Sent Events, for clients that cannot
+// open WebSockets. Query params are those of StreamAppLogs; with Last-Event-ID every line
+// after that event is sent instead.
+func (h *LogHandler) StreamAppLogsSSE(w http.ResponseWriter, r *http.Request) {
+	appID, ok := h.authorizeAppStream(w, r)
+	if !ok {
+		return
+	}
+	opts, err := streamLogOptions(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	resumeAfter, err := lastEventID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	out, err := newSSEStream(w, logstream.Topic(appID), resumeAfter)
+	if err != nil {
+		h.logger.Debug("Event stream unavailable", zap.Error(err))
+		return
+	}
+	if out.resumeAfter != 0 {
+		opts.Since = time.Unix(0, out.resumeAfter+1)
+		opts.Tail = "all"
+	}
+
+	client := ws.NewStreamClient(h.wsHub)
+	h.wsHub.Register(client)
+	h.wsHub.Subscribe(client, out.topic)
+	h.streamer.Watch(appID)
+	subscribedAt := time.Now()
+
+	h.writeLogBacklog(r.Context(), out, appID.String(), opts, subscribedAt)
+	out.pump(r, client)
+}
+
+// StreamAppEventsSSE streams an app's deployment events as Server-Sent Events. Recent
+// events are replayed first; with Last-Event-ID only the ones after it.
+func (h *LogHandler) StreamAppEventsSSE(w http.ResponseWriter, r *http.Request) {
+	appID, ok := h.authorizeAppStream(w, r)
+	if !ok {
+		return
+	}
+
+	resumeAfter, err := lastEventID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	out, err := newSSEStream(w, appEventsTopic(appID.String()), resumeAfter)
+	if err != nil {
+		h.logger.Debug("Event stream unavailable", zap.Error(err))
+		return
+	}
+
+	client := ws.NewStreamClient(h.wsHub)
+	h.wsHub.Register(client)
+	h.wsHub.Subscribe(client, out.topic)
+	out.pump(r, client)
+}
+
+// authorizeAppStream checks the request's user may read the app's streams, by the same
+// rules as the app's read-only REST requests and its WebSocket streams
+func (h *LogHandler) authorizeAppStream(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	appID, err := uuid.Parse(chi.URLParam(r, "appId"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid app ID")
+		return uuid.Nil, false
+	}
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Unauthorized")
+		return uuid.Nil, false
+	}
+	if !h.wsAuth.canAccessApp(r.Context(), w, user, appID) {
+		return uuid.Nil, false
+	}
+	return appID, true
+}
+
+// writeLogBacklog sends the app's log lines from before the subscription, oldest first
+func (h *LogHandler) writeLogBacklog(ctx context.Context, out logSender, appID string, opts docker.LogOptions, until time.Time) {
+	allContainers, err := h.dockerClient.ListContainers(ctx, true)
 	if err != nil {
 		h.logger.Error("Failed to list containers", zap.Error(err))
-		conn.WriteJSON(map[string]string{"error": "Failed to list containers"})
+		out.sendError("Failed to list containers")
 		return
 	}
 
-	// Filter by app ID label
 	var containers []docker.ContainerInfo
 	for _, c := range allContainers {
 		if c.Labels["nanopaas.app.id"] == appID {
 			containers = append(containers, c)
 		}
 	}
-
 	if len(containers) == 0 {
-		conn.WriteJSON(map[string]string{"message": "No running containers"})
+		out.Send("no_containers", map[string]string{"message": "No containers yet; lines appear once the app starts"})
 		return
 	}
 
-	// Create context that cancels when connection closes
-	ctx, cancel := context.WithCancel(r.Context())
-	defer cancel()
-
-	// Start log streaming for each container
+	opts.Follow = false
+	var lines []logstream.Line
 	for _, container := range containers {
-		go h.streamContainerLogs(ctx, conn, container.ID, appID)
-	}
-
-	// Keep connection alive and handle incoming messages
-	for {
-		_, _, err := conn.ReadMessage()
+		containerLines, err := h.dockerClient.ContainerLogLines(ctx, container.ID, opts)
 		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				h.logger.Debug("WebSocket closed", zap.Error(err))
+			h.logger.Warn("Failed to get logs for container",
+				zap.String("container_id", container.ID),
+				zap.Error(err),
+			)
+			continue
+		}
+		for _, line := range containerLines {
+			if line.Timestamp.Before(until) {
+				lines = append(lines, logstream.NewLine(appID, container, line))
 			}
-			break
+		}
+	}
+
+	// Interleave containers chronologically
+	sort.SliceStable(lines, func(i, j int) bool {
+		return lines[i].Timestamp.Before(lines[j].Timestamp)
+	})
+	for _, line := range lines {
+		if err := out.Send("log", line); err != nil {
+			return
 		}
 	}
 }
@@ -161,12 +400,26 @@ func (h *LogHandler) StreamAppLogs(w http.ResponseWriter, r *http.Request) {
 func (h *LogHandler) StreamContainerLogs(w http.ResponseWriter, r *http.Request) {
 	containerID := chi.URLParam(r, "containerId")
 	if containerID == "" {
-		http.Error(w, "Container ID required", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, "Container ID required")
+		return
+	}
+
+	// Authorize by the app owning the container
+	info, err := h.dockerClient.InspectContainer(r.Context(), containerID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Container not found")
+		return
+	}
+	var labels map[string]string
+	if info.Config != nil {
+		labels = info.Config.Labels
+	}
+	if _, ok := h.wsAuth.AuthorizeContainer(w, r, labels); !ok {
 		return
 	}
 
 	// Upgrade to WebSocket
-	conn, err := logUpgrader.Upgrade(w, r, nil)
+	conn, err := h.wsAuth.Upgrade(w, r)
 	if err != nil {
 		h.logger.Error("WebSocket upgrade failed", zap.Error(err))
 		return
@@ -176,134 +429,283 @@ func (h *LogHandler) StreamContainerLogs(w http.ResponseWriter, r *http.Request)
 	ctx, cancel := context.WithCancel(r.Context())
 	defer cancel()
 
-	h.streamContainerLogs(ctx, conn, containerID, "")
+	out := &logConn{conn: conn, topic: containerLogTopic(containerID)}
+	opts, err := streamLogOptions(r)
+	if err != nil {
+		out.sendError(err.Error())
+		return
+	}
+
+	container := docker.ContainerInfo{ID: containerID, Name: info.Name, Labels: labels}
+	h.streamContainerLogs(ctx, out, container, opts)
 }
 
-func (h *LogHandler) streamContainerLogs(ctx context.Context, conn *websocket.Conn, containerID, appID string) {
-	reader, err := h.dockerClient.GetContainerLogs(ctx, containerID, true, "50")
+func (h *LogHandler) streamContainerLogs(ctx context.Context, conn *logConn, container docker.ContainerInfo, opts docker.LogOptions) {
+	containerID := container.ID
+	appID := container.Labels["nanopaas.app.id"]
+
+	err := h.dockerClient.StreamLogLines(ctx, containerID, opts, func(line docker.LogLine) error {
+		return conn.Send("log", logstream.NewLine(appID, container, line))
+	})
 	if err != nil {
-		h.logger.Error("Failed to get container logs",
+		h.logger.Debug("Log stream ended",
 			zap.String("container_id", containerID),
 			zap.Error(err),
 		)
-		conn.WriteJSON(map[string]string{"error": "Failed to stream logs"})
+		conn.sendError("Failed to stream logs")
 		return
 	}
-	defer reader.Close()
 
-	buf := make([]byte, 8*1024)
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		default:
-			n, err := reader.Read(buf)
-			if err != nil {
-				if err != io.EOF {
-					h.logger.Debug("Log stream ended",
-						zap.String("container_id", containerID),
-						zap.Error(err),
-					)
-				}
-				return
-			}
+	if ctx.Err() == nil {
+		h.writeContainerExit(ctx, conn, container, appID)
+	}
+}
 
-			if n > 0 {
-				// Docker log format: first 8 bytes are header
-				// We skip header for simple text output
-				var content string
-				if n > 8 {
-					content = string(buf[8:n])
-				} else {
-					content = string(buf[:n])
-				}
-
-				shortID := containerID
-				if len(containerID) > 12 {
-					shortID = containerID[:12]
-				}
-
-				message := map[string]interface{}{
-					"type":         "log",
-					"container_id": shortID,
-					"content":      content,
-					"timestamp":    time.Now().UTC().Format(time.RFC3339),
-				}
-
-				if appID != "" {
-					message["app_id"] = appID
-				}
-
-				if err := conn.WriteJSON(message); err != nil {
-					h.logger.Debug("Failed to write log message", zap.Error(err))
-					return
-				}
-			}
-		}
+// writeContainerExit tells the client why a container's log stream ended
+func (h *LogHandler) writeContainerExit(ctx context.Context, conn *logConn, container docker.ContainerInfo, appID string) {
+	info, err := h.dockerClient.InspectContainer(ctx, container.ID)
+	if err != nil {
+		return
+	}
+	if exit := logstream.ExitPayload(info, appID, container.Name); exit != nil {
+		conn.Send("container_exit", exit)
 	}
 }
 
-func (h *LogHandler) getContainerLogs(ctx context.Context, containerID, tail string) ([]string, error) {
-	reader, err := h.dockerClient.GetContainerLogs(ctx, containerID, false, tail)
+// streamLogOptions reads tail and since query params for a followed log stream
+func streamLogOptions(r *http.Request) (docker.LogOptions, error) {
+	opts := docker.LogOptions{Follow: true, Tail: r.URL.Query().Get("tail")}
+
+	since, err := parseLogSince(r.URL.Query().Get("since"))
 	if err != nil {
-		return nil, err
+		return opts, err
+	}
+	opts.Since = since
+
+	if opts.Tail == "" {
+		opts.Tail = "50"
+		if !since.IsZero() {
+			opts.Tail = "all" // resuming: everything after since
+		}
+	}
+	return opts, nil
+}
+
+// parseLogSince parses an RFC3339 timestamp or a duration relative to now (e.g. "10m")
+func parseLogSince(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
 	}
-	defer reader.Close()
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return time.Time{}, fmt.Errorf("since must be an RFC3339 timestamp or a duration like 10m")
+	}
+	return time.Now().Add(-d), nil
+}
+
+// isLogHistoryQuery reports whether a log request needs the stored history
+func isLogHistoryQuery(r *http.Request) bool {
+	q := r.URL.Query()
+	return q.Get("source") == "history" || q.Get("from") != "" || q.Get("to") != "" ||
+		q.Get("q") != "" || q.Get("stream") != ""
+}
 
-	content, err := io.ReadAll(reader)
+// searchAppLogs returns stored log lines of an app, newest first
+// Query params: from, to (RFC3339 time or duration such as 2h), q (case-insensitive text),
+// stream (stdout or stderr), limit (default 500, max 5000)
+func (h *LogHandler) searchAppLogs(w http.ResponseWriter, r *http.Request, appID string) {
+	if h.logStore == nil {
+		writeError(w, http.StatusNotImplemented, "Log history is not enabled")
+		return
+	}
+	id, err := uuid.Parse(appID)
 	if err != nil {
-		return nil, err
+		writeError(w, http.StatusBadRequest, "Invalid app ID")
+		return
 	}
 
-	// Parse log lines (Docker multiplexed stream format)
-	var logs []string
-	for len(content) > 8 {
-		// Header: 8 bytes [STREAM_TYPE, 0, 0, 0, SIZE1, SIZE2, SIZE3, SIZE4]
-		size := int(content[4])<<24 | int(content[5])<<16 | int(content[6])<<8 | int(content[7])
-		if size <= 0 || len(content) < 8+size {
-			break
+	params := r.URL.Query()
+	query := domain.AppLogQuery{
+		AppID:  id,
+		Search: params.Get("q"),
+		Limit:  defaultLogSearchLimit,
+	}
+	if query.From, err = parseLogSince(params.Get("from")); err != nil {
+		writeError(w, http.StatusBadRequest, "from must be an RFC3339 timestamp or a duration like 2h")
+		return
+	}
+	if query.To, err = parseLogSince(params.Get("to")); err != nil {
+		writeError(w, http.StatusBadRequest, "to must be an RFC3339 timestamp or a duration like 2h")
+		return
+	}
+	if !query.From.IsZero() && !query.To.IsZero() && !query.From.Before(query.To) {
+		writeError(w, http.StatusBadRequest, "from must be before to")
+		return
+	}
+	switch stream := params.Get("stream"); stream {
+	case "", string(docker.LogStreamStdout), string(docker.LogStreamStderr):
+		query.Stream = stream
+	default:
+		writeError(w, http.StatusBadRequest, "stream must be stdout or stderr")
+		return
+	}
+	if limit := params.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, "limit must be a positive number")
+			return
 		}
-		logs = append(logs, string(content[8:8+size]))
-		content = content[8+size:]
+		query.Limit = min(n, maxLogSearchLimit)
+	}
+
+	entries, err := h.logStore.Search(r.Context(), query)
+	if err != nil {
+		h.logger.Error("Failed to search app logs", zap.String("app_id", appID), zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "Failed to search logs")
+		return
 	}
 
-	return logs, nil
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"logs":      entries,
+		"source":    "history",
+		"count":     len(entries),
+		"limit":     query.Limit,
+		"truncated": len(entries) == query.Limit,
+	})
 }
 
-// GetBuildLogs returns logs for a build
+// GetBuildLogs returns a page of a build's archived log.
+// Query params: after, the number of the last line already read (default 0), and limit.
+// next_after continues from the returned page. With format=text the rest of the log is
+// streamed as plain text instead, however long it is.
 func (h *LogHandler) GetBuildLogs(w http.ResponseWriter, r *http.Request) {
-	buildID := chi.URLParam(r, "buildId")
-	if buildID == "" {
-		writeError(w, http.StatusBadRequest, "Build ID required")
+	buildID, err := uuid.Parse(chi.URLParam(r, "buildId"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid build ID")
+		return
+	}
+	if h.buildLogs == nil {
+		writeError(w, http.StatusNotImplemented, "Build log archive is not enabled")
+		return
+	}
+
+	build, err := h.builds.GetByID(r.Context(), buildID)
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		h.logger.Error("Failed to get build", zap.String("build_id", buildID.String()), zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "Failed to get build")
+		return
+	}
+	if build == nil || build.AppID.String() != chi.URLParam(r, "appId") {
+		writeError(w, http.StatusNotFound, "Build not found")
 		return
 	}
 
-	// Parse UUID
-	_, err := uuid.Parse(buildID)
+	params := r.URL.Query()
+	after := 0
+	if v := params.Get("after"); v != "" {
+		if after, err = strconv.Atoi(v); err != nil || after < 0 {
+			writeError(w, http.StatusBadRequest, "after must be a line number")
+			return
+		}
+	}
+	limit := defaultBuildLogLimit
+	if v := params.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, "limit must be a positive number")
+			return
+		}
+		limit = min(n, maxBuildLogLimit)
+	}
+
+	switch params.Get("format") {
+	case "", "json":
+	case "text":
+		h.streamBuildLog(w, r, buildID, after)
+		return
+	default:
+		writeError(w, http.StatusBadRequest, "format must be json or text")
+		return
+	}
+
+	// One line past the page tells whether there are more
+	lines, err := h.buildLogs.List(r.Context(), buildID, after, limit+1)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "Invalid build ID")
+		h.logger.Error("Failed to list build log", zap.String("build_id", buildID.String()), zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "Failed to get build logs")
 		return
 	}
+	hasMore := len(lines) > limit
+	if hasMore {
+		lines = lines[:limit]
+	}
+	nextAfter := after
+	if len(lines) > 0 {
+		nextAfter = lines[len(lines)-1].Line
+	}
 
-	// In production, fetch from build_logs table
-	// For now, return empty logs
-	writeJSON(w, http.StatusOK, map[string]interface{}{
-		"build_id": buildID,
-		"logs":     []string{},
-		"message":  "Build logs available via WebSocket during build",
+	writeJSON(w, http.StatusOK, BuildLogPage{
+		BuildID:   buildID,
+		Status:    build.Status,
+		Lines:     lines,
+		Count:     len(lines),
+		NextAfter: nextAfter,
+		HasMore:   hasMore,
 	})
 }
 
+// streamBuildLog writes a build's archived log after a line number as plain text,
+// reading and flushing it a page at a time so large logs aren't held in memory
+func (h *LogHandler) streamBuildLog(w http.ResponseWriter, r *http.Request, buildID uuid.UUID, after int) {
+	flusher, _ := w.(http.Flusher)
+	started := false
+
+	for {
+		lines, err := h.buildLogs.List(r.Context(), buildID, after, maxBuildLogLimit)
+		if err != nil {
+			h.logger.Error("Failed to list build log", zap.String("build_id", buildID.String()), zap.Error(err))
+			if !started {
+				writeError(w, http.StatusInternalServerError, "Failed to get build logs")
+			}
+			return
+		}
+		if !started {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.WriteHeader(http.StatusOK)
+			started = true
+		}
+		for _, line := range lines {
+			if _, err := fmt.Fprintln(w, line.Content); err != nil {
+				return
+			}
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if len(lines) < maxBuildLogLimit {
+			return
+		}
+		after = lines[len(lines)-1].Line
+	}
+}
+
 // StreamBuildLogs streams build logs via WebSocket
 func (h *LogHandler) StreamBuildLogs(w http.ResponseWriter, r *http.Request) {
 	buildID := chi.URLParam(r, "buildId")
 	if buildID == "" {
-		http.Error(w, "Build ID required", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, "Build ID required")
+		return
+	}
+	user, ok := h.wsAuth.AuthorizeBuild(w, r, buildID)
+	if !ok {
 		return
 	}
 
 	// Upgrade to WebSocket
-	conn, err := logUpgrader.Upgrade(w, r, nil)
+	conn, err := h.wsAuth.Upgrade(w, r)
 	if err != nil {
 		h.logger.Error("WebSocket upgrade failed", zap.Error(err))
 		return
@@ -311,6 +713,7 @@ func (h *LogHandler) StreamBuildLogs(w http.ResponseWriter, r *http.Request) {
 
 	// Create WebSocket client and subscribe to build logs
 	client := ws.NewClient(h.wsHub, conn)
+	client.SetAuthorizer(h.wsAuth.TopicAuthorizer(user))
 	h.wsHub.Register(client)
 
 	// Subscribe to build logs topic
@@ -326,3 +729,65 @@ func (h *LogHandler) StreamBuildLogs(w http.ResponseWriter, r *http.Request) {
 	go client.WritePump()
 	go client.ReadPump()
 }
+
+// pullTopic is the WebSocket topic carrying image pull progress for an app's deployments
+func pullTopic(appID string) string {
+	return "pull:" + appID
+}
+
+// BroadcastPullProgress publishes deploy image pull progress to the app's pull topic
+func (h *LogHandler) BroadcastPullProgress(appID uuid.UUID, progress docker.PullProgress) {
+	broadcastPullProgress(h.wsHub, pullTopic(appID.String()), progress)
+}
+
+// StreamPullProgress streams image pull progress for an app's deployments via WebSocket
+func (h *LogHandler) StreamPullProgress(w http.ResponseWriter, r *http.Request) {
+	appID := chi.URLParam(r, "appId")
+	if _, err := uuid.Parse(appID); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid app ID")
+		return
+	}
+	user, ok := h.wsAuth.AuthorizeApp(w, r, appID)
+	if !ok {
+		return
+	}
+
+	conn, err := h.wsAuth.Upgrade(w, r)
+	if err != nil {
+		h.logger.Error("WebSocket upgrade failed", zap.Error(err))
+		return
+	}
+
+	client := ws.NewClient(h.wsHub, conn)
+	client.SetAuthorizer(h.wsAuth.TopicAuthorizer(user))
+	h.wsHub.Register(client)
+	h.wsHub.Subscribe(client, pullTopic(appID))
+
+	h.logger.Debug("Client subscribed to pull progress",
+		zap.String("app_id", appID),
+		zap.String("client_id", client.ID.String()),
+	)
+
+	go client.WritePump()
+	go client.ReadPump()
+}
+
+// appEventsTopic is the WebSocket topic carrying an app's deployment events
+func appEventsTopic(appID string) string {
+	return "app:" + appID + ":events"
+}
+
+// BroadcastDeployment publishes a deployment status change to the app's events topic.
+// It is published even without listeners so the topic's replay covers clients that are
+// reconnecting.
+func (h *LogHandler) BroadcastDeployment(deployment *domain.Deployment) {
+	h.wsHub.Publish(appEventsTopic(deployment.AppID.String()), "deployment", deployment)
+}
+
+// broadcastPullProgress sends a pull_progress message to a topic if anyone is listening
+func broadcastPullProgress(hub *ws.Hub, topic string, progress docker.PullProgress) {
+	if !hub.HasListeners(topic) {
+		return
+	}
+	hub.Publish(topic, "pull_progress", progress)
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/services/maintenance"
+)
+
+// maintenanceRunTimeout bounds a manually triggered maintenance pass
+const maintenanceRunTimeout = 5 * time.Minute
+
+// MaintenanceHandler handles admin database maintenance endpoints
+type MaintenanceHandler struct {
+	service *maintenance.Service
+	logger  *zap.Logger
+}
+
+// NewMaintenanceHandler creates a new maintenance handler
+func NewMaintenanceHandler(service *maintenance.Service, logger *zap.Logger) *MaintenanceHandler {
+	return &MaintenanceHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// Report returns the most recent maintenance report
+func (h *MaintenanceHandler) Report(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	report := h.service.LastReport()
+	if report == nil {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"message": "Maintenance has not run yet",
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
+
+// Run triggers a maintenance pass and returns its report
+func (h *MaintenanceHandler) Run(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	// Detach from the request so a client disconnect doesn't abort deletes midway
+	ctx, cancel := context.WithTimeout(context.Background(), maintenanceRunTimeout)
+	defer cancel()
+
+	report, err := h.service.Run(ctx, "manual")
+	if errors.Is(err, maintenance.ErrNoDatabase) {
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	h.logger.Info("Manual maintenance triggered", zap.String("user_id", GetUserFromContext(r.Context()).ID.String()))
+	writeJSON(w, http.StatusOK, report)
+}
+
+// requireAdmin writes 403 and returns false unless the request is from an admin
+func requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	user := GetUserFromContext(r.Context())
+	if user == nil || !user.IsAdmin() {
+		writeError(w, http.StatusForbidden, "Admin privileges required")
+		return false
+	}
+	return true
+}
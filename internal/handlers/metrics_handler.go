@@ -8,24 +8,35 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/nanopaas/nanopaas/internal/infrastructure/docker"
+	"github.com/nanopaas/nanopaas/internal/middleware"
 	"github.com/nanopaas/nanopaas/internal/services/builder"
+	"github.com/nanopaas/nanopaas/internal/services/github"
 	"github.com/nanopaas/nanopaas/internal/services/orchestrator"
+	"github.com/nanopaas/nanopaas/internal/services/router"
 	ws "github.com/nanopaas/nanopaas/pkg/websocket"
 )
 
 // MetricsHandler handles Prometheus-compatible metrics endpoints
 type MetricsHandler struct {
-	dockerClient *docker.Client
+	dockerClient docker.ContainerRuntime
 	orchestrator *orchestrator.Orchestrator
 	builder      *builder.Builder
 	wsHub        *ws.Hub
 	logger       *zap.Logger
 	startTime    time.Time
+	deprecations *middleware.DeprecationTracker
+	router       router.Router
+	githubLimits GitHubRateLimits
+}
+
+// GitHubRateLimits reports the GitHub API quotas last seen
+type GitHubRateLimits interface {
+	RateLimitStats() github.RateLimitStats
 }
 
 // NewMetricsHandler creates a new metrics handler
 func NewMetricsHandler(
-	dockerClient *docker.Client,
+	dockerClient docker.ContainerRuntime,
 	orchestrator *orchestrator.Orchestrator,
 	builder *builder.Builder,
 	wsHub *ws.Hub,
@@ -41,6 +52,21 @@ func NewMetricsHandler(
 	}
 }
 
+// SetDeprecationTracker sets the source of deprecated API call counts
+func (h *MetricsHandler) SetDeprecationTracker(tracker *middleware.DeprecationTracker) {
+	h.deprecations = tracker
+}
+
+// SetRouter sets the router whose configuration updates are reported
+func (h *MetricsHandler) SetRouter(rtr router.Router) {
+	h.router = rtr
+}
+
+// SetGitHubRateLimits sets the source of GitHub API quota metrics
+func (h *MetricsHandler) SetGitHubRateLimits(limits GitHubRateLimits) {
+	h.githubLimits = limits
+}
+
 // Metrics returns Prometheus-compatible metrics
 func (h *MetricsHandler) Metrics(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
@@ -105,6 +131,144 @@ func (h *MetricsHandler) Metrics(w http.ResponseWriter, r *http.Request) {
 			w.Write([]byte(metric.name + " " + ftoa(v) + "\n"))
 		}
 	}
+
+	if h.builder != nil {
+		writeWorkspaceMetrics(w, h.builder.WorkspaceMetrics())
+	}
+	if h.deprecations != nil {
+		writeDeprecationMetrics(w, h.deprecations.Usage())
+	}
+	if h.router != nil {
+		writeRouterConfigMetrics(w, h.router.ConfigStats())
+	}
+	if h.wsHub != nil {
+		writeWebSocketMetrics(w, h.wsHub.Stats())
+	}
+	if h.githubLimits != nil {
+		writeGitHubRateLimitMetrics(w, h.githubLimits.RateLimitStats())
+	}
+}
+
+// writeGitHubRateLimitMetrics writes the GitHub API quota left, labelled by resource, and
+// how often GitHub limited requests. Alert before nanopaas_github_ratelimit_remaining
+// reaches zero: webhooks, repository listings and deployment reports all share it.
+func writeGitHubRateLimitMetrics(w http.ResponseWriter, stats github.RateLimitStats) {
+	gauges := []struct {
+		name  string
+		help  string
+		value func(github.RateLimit) string
+	}{
+		{"nanopaas_github_ratelimit_limit", "GitHub API requests allowed per window", func(l github.RateLimit) string { return itoa(l.Limit) }},
+		{"nanopaas_github_ratelimit_remaining", "GitHub API requests left in the window", func(l github.RateLimit) string { return itoa(l.Remaining) }},
+		{"nanopaas_github_ratelimit_reset_timestamp_seconds", "When the GitHub API window resets, in Unix seconds", func(l github.RateLimit) string { return itoa64(l.Reset.Unix()) }},
+	}
+	for _, gauge := range gauges {
+		if len(stats.Resources) == 0 {
+			break
+		}
+		w.Write([]byte("# HELP " + gauge.name + " " + gauge.help + "\n"))
+		w.Write([]byte("# TYPE " + gauge.name + " gauge\n"))
+		for _, limit := range stats.Resources {
+			w.Write([]byte(gauge.name + "{resource=\"" + limit.Resource + "\"} " + gauge.value(limit) + "\n"))
+		}
+	}
+
+	counters := []struct {
+		name  string
+		help  string
+		value int64
+	}{
+		{"nanopaas_github_rate_limited_total", "GitHub API responses that were rate limited", stats.Limited},
+		{"nanopaas_github_rate_limit_retries_total", "GitHub API requests retried after a rate limit", stats.Retries},
+	}
+	for _, counter := range counters {
+		w.Write([]byte("# HELP " + counter.name + " " + counter.help + "\n"))
+		w.Write([]byte("# TYPE " + counter.name + " counter\n"))
+		w.Write([]byte(counter.name + " " + itoa64(counter.value) + "\n"))
+	}
+}
+
+// writeWebSocketMetrics writes hub backpressure counters. Steadily rising drops mean
+// clients can't keep up with their topics.
+func writeWebSocketMetrics(w http.ResponseWriter, stats ws.HubStats) {
+	metrics := []struct {
+		name  string
+		help  string
+		mtype string
+		value string
+	}{
+		{"nanopaas_websocket_topics", "Number of WebSocket topics with subscribers", "gauge", itoa(stats.Topics)},
+		{"nanopaas_websocket_dropped_messages_total", "WebSocket messages dropped because a client's buffer was full", "counter", itoa64(stats.DroppedMessages)},
+		{"nanopaas_websocket_slow_disconnects_total", "WebSocket clients disconnected for falling behind", "counter", itoa64(stats.SlowClientsDisconnected)},
+	}
+
+	for _, metric := range metrics {
+		w.Write([]byte("# HELP " + metric.name + " " + metric.help + "\n"))
+		w.Write([]byte("# TYPE " + metric.name + " " + metric.mtype + "\n"))
+		w.Write([]byte(metric.name + " " + metric.value + "\n"))
+	}
+}
+
+// writeRouterConfigMetrics writes proxy configuration update counters. A rejected
+// configuration leaves the previous one live, so alert on nanopaas_router_config_valid.
+func writeRouterConfigMetrics(w http.ResponseWriter, stats router.ConfigStats) {
+	valid := 1
+	if stats.LastError != "" {
+		valid = 0
+	}
+	metrics := []struct {
+		name  string
+		help  string
+		mtype string
+		value string
+	}{
+		{"nanopaas_router_config_applied_total", "Router configurations applied", "counter", itoa64(stats.Applied)},
+		{"nanopaas_router_config_rejected_total", "Router configurations rejected by validation or the proxy", "counter", itoa64(stats.Rejected)},
+		{"nanopaas_router_config_valid", "Whether the latest router configuration was applied", "gauge", itoa(valid)},
+	}
+
+	for _, metric := range metrics {
+		w.Write([]byte("# HELP " + metric.name + " " + metric.help + "\n"))
+		w.Write([]byte("# TYPE " + metric.name + " " + metric.mtype + "\n"))
+		w.Write([]byte(metric.name + " " + metric.value + "\n"))
+	}
+}
+
+// writeDeprecationMetrics writes call counts for each deprecated API route
+func writeDeprecationMetrics(w http.ResponseWriter, usage []middleware.DeprecationUsage) {
+	if len(usage) == 0 {
+		return
+	}
+	w.Write([]byte("# HELP nanopaas_deprecated_api_calls_total Calls to deprecated API routes\n"))
+	w.Write([]byte("# TYPE nanopaas_deprecated_api_calls_total counter\n"))
+	for _, u := range usage {
+		label := "{method=\"" + u.Method + "\",pattern=\"" + u.Pattern + "\"}"
+		w.Write([]byte("nanopaas_deprecated_api_calls_total" + label + " " + itoa64(u.Calls) + "\n"))
+	}
+}
+
+// writeWorkspaceMetrics writes build workspace driver counters labelled by driver
+func writeWorkspaceMetrics(w http.ResponseWriter, ws builder.WorkspaceMetrics) {
+	label := "{driver=\"" + ws.Driver + "\"}"
+	metrics := []struct {
+		name  string
+		help  string
+		mtype string
+		value string
+	}{
+		{"nanopaas_build_workspaces_created_total", "Build workspaces provisioned", "counter", itoa64(ws.Created)},
+		{"nanopaas_build_workspaces_failed_total", "Build workspaces that failed to provision", "counter", itoa64(ws.Failed)},
+		{"nanopaas_build_workspaces_active", "Build workspaces currently provisioned", "gauge", itoa64(ws.Active)},
+		{"nanopaas_build_workspace_release_failures_total", "Build workspaces that failed to tear down", "counter", itoa64(ws.ReleaseFailed)},
+		{"nanopaas_build_workspace_provision_seconds_total", "Time spent provisioning build workspaces", "counter", ftoa(ws.ProvisionSeconds)},
+		{"nanopaas_build_workspace_bytes_total", "Bytes held by build workspaces when released", "counter", itoa64(ws.BytesUsed)},
+	}
+
+	for _, metric := range metrics {
+		w.Write([]byte("# HELP " + metric.name + " " + metric.help + "\n"))
+		w.Write([]byte("# TYPE " + metric.name + " " + metric.mtype + "\n"))
+		w.Write([]byte(metric.name + label + " " + metric.value + "\n"))
+	}
 }
 
 // Stats returns JSON-formatted stats (for dashboard)
@@ -122,14 +286,23 @@ func (h *MetricsHandler) Stats(w http.ResponseWriter, r *http.Request) {
 		buildQueueLen = h.builder.QueueLength()
 	}
 
+	var wsStats *ws.HubStats
 	if h.wsHub != nil {
 		wsClients = h.wsHub.ClientCount()
+		stats := h.wsHub.Stats()
+		wsStats = &stats
 	}
 
 	if h.orchestrator != nil {
 		deployments = len(h.orchestrator.ListDeployments())
 	}
 
+	var workspaces *builder.WorkspaceMetrics
+	if h.builder != nil {
+		ws := h.builder.WorkspaceMetrics()
+		workspaces = &ws
+	}
+
 	stats := map[string]interface{}{
 		"uptime_seconds":    time.Since(h.startTime).Seconds(),
 		"uptime_human":      time.Since(h.startTime).String(),
@@ -139,7 +312,9 @@ func (h *MetricsHandler) Stats(w http.ResponseWriter, r *http.Request) {
 		"gc_runs":           m.NumGC,
 		"builds_active":     activeBuilds,
 		"builds_queued":     buildQueueLen,
+		"build_workspaces":  workspaces,
 		"websocket_clients": wsClients,
+		"websocket":         wsStats,
 		"deployments":       deployments,
 		"go_version":        runtime.Version(),
 		"num_cpu":           runtime.NumCPU(),
@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+	"github.com/nanopaas/nanopaas/internal/services/notify"
+)
+
+// NotificationChannelStore persists notification channels
+type NotificationChannelStore interface {
+	Create(ctx context.Context, c *domain.NotificationChannel) error
+	Update(ctx context.Context, c *domain.NotificationChannel) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	Get(ctx context.Context, id uuid.UUID) (*domain.NotificationChannel, error)
+	List(ctx context.Context, appID *uuid.UUID) ([]*domain.NotificationChannel, error)
+}
+
+// NotificationChannelHandler manages the Slack, Discord and webhook channels that
+// receive app events. Routes under /apps/{appId} manage an app's channels; the others
+// manage global channels, which receive every app's events and are admin only.
+type NotificationChannelHandler struct {
+	store      NotificationChannelStore
+	dispatcher *notify.Dispatcher
+	apps       notify.AppSource
+	logger     *zap.Logger
+}
+
+// CreateNotificationChannelRequest represents a request to add a notification channel
+type CreateNotificationChannelRequest struct {
+	Name   string                     `json:"name"`
+	Kind   domain.ChannelKind         `json:"kind"` // slack, discord or webhook
+	URL    string                     `json:"url"`
+	Secret string                     `json:"secret,omitempty"` // webhook only: signs payloads
+	Events []domain.NotificationEvent `json:"events,omitempty"` // empty subscribes to all events
+}
+
+// UpdateNotificationChannelRequest represents a request to change a notification channel
+type UpdateNotificationChannelRequest struct {
+	Name    string                      `json:"name,omitempty"`
+	Events  *[]domain.NotificationEvent `json:"events,omitempty"`
+	Enabled *bool                       `json:"enabled,omitempty"`
+}
+
+// NotificationChannelResponse is a channel with its URL redacted
+type NotificationChannelResponse struct {
+	*domain.NotificationChannel
+	URL    string `json:"url"`
+	Signed bool   `json:"signed"` // payloads carry an HMAC signature
+}
+
+// NewNotificationChannelHandler creates a new notification channel handler
+func NewNotificationChannelHandler(store NotificationChannelStore, dispatcher *notify.Dispatcher, apps notify.AppSource, logger *zap.Logger) *NotificationChannelHandler {
+	return &NotificationChannelHandler{
+		store:      store,
+		dispatcher: dispatcher,
+		apps:       apps,
+		logger:     logger,
+	}
+}
+
+// List returns the app's or the global notification channels
+func (h *NotificationChannelHandler) List(w http.ResponseWriter, r *http.Request) {
+	appID, ok := h.scope(w, r)
+	if !ok {
+		return
+	}
+	channels, err := h.store.List(r.Context(), appID)
+	if err != nil {
+		h.logger.Error("Failed to list notification channels", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "Failed to list notification channels")
+		return
+	}
+
+	responses := make([]NotificationChannelResponse, 0, len(channels))
+	for _, c := range channels {
+		responses = append(responses, channelResponse(c))
+	}
+	writeJSON(w, http.StatusOK, responses)
+}
+
+// Create adds a notification channel
+func (h *NotificationChannelHandler) Create(w http.ResponseWriter, r *http.Request) {
+	appID, ok := h.scope(w, r)
+	if !ok {
+		return
+	}
+	var req CreateNotificationChannelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user := GetUserFromContext(r.Context())
+	channel := domain.NewNotificationChannel(appID, req.Name, req.Kind, req.URL, req.Events, user.ID)
+	if err := channel.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Secret != "" {
+		if req.Kind != domain.ChannelWebhook {
+			writeError(w, http.StatusBadRequest, "secret is only supported for webhook channels")
+			return
+		}
+		channel.Secret = req.Secret
+	}
+
+	if err := h.store.Create(r.Context(), channel); err != nil {
+		h.logger.Error("Failed to store notification channel", zap.Error(err))
+		writeDomainError(w, err, "Failed to create notification channel")
+		return
+	}
+
+	h.logger.Info("Notification channel created",
+		zap.String("channel_id", channel.ID.String()),
+		zap.String("kind", string(channel.Kind)),
+		zap.Bool("global", appID == nil),
+	)
+	writeJSON(w, http.StatusCreated, channelResponse(channel))
+}
+
+// Update renames a channel, changes its events, or enables or disables it
+func (h *NotificationChannelHandler) Update(w http.ResponseWriter, r *http.Request) {
+	channel, ok := h.channel(w, r)
+	if !ok {
+		return
+	}
+	var req UpdateNotificationChannelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.Name != "" {
+		channel.Name = req.Name
+	}
+	if req.Events != nil {
+		channel.Events = *req.Events
+	}
+	if req.Enabled != nil {
+		channel.Enabled = *req.Enabled
+	}
+	if err := channel.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.store.Update(r.Context(), channel); err != nil {
+		h.logger.Error("Failed to update notification channel", zap.Error(err))
+		writeDomainError(w, err, "Failed to update notification channel")
+		return
+	}
+	writeJSON(w, http.StatusOK, channelResponse(channel))
+}
+
+// Delete removes a notification channel
+func (h *NotificationChannelHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	channel, ok := h.channel(w, r)
+	if !ok {
+		return
+	}
+	if err := h.store.Delete(r.Context(), channel.ID); err != nil {
+		h.logger.Error("Failed to delete notification channel", zap.Error(err))
+		writeDomainError(w, err, "Failed to delete notification channel")
+		return
+	}
+	h.dispatcher.Forget(channel.ID)
+
+	h.logger.Info("Notification channel deleted", zap.String("channel_id", channel.ID.String()))
+	writeJSON(w, http.StatusOK, map[string]string{
+		"message": "Notification channel deleted",
+	})
+}
+
+// Test posts a test event to a channel and returns the delivery
+func (h *NotificationChannelHandler) Test(w http.ResponseWriter, r *http.Request) {
+	channel, ok := h.channel(w, r)
+	if !ok {
+		return
+	}
+	var app *domain.App
+	if channel.AppID != nil {
+		app, _ = h.apps.FindApp(*channel.AppID)
+	}
+	writeJSON(w, http.StatusOK, h.dispatcher.Test(channel, app))
+}
+
+// Deliveries returns a channel's recent deliveries, newest first
+func (h *NotificationChannelHandler) Deliveries(w http.ResponseWriter, r *http.Request) {
+	channel, ok := h.channel(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, h.dispatcher.Deliveries(channel.ID))
+}
+
+// scope returns the app whose channels the request manages, or nil for global channels,
+// which only admins may manage. App access is checked by RequireAppAccess.
+func (h *NotificationChannelHandler) scope(w http.ResponseWriter, r *http.Request) (*uuid.UUID, bool) {
+	param := chi.URLParam(r, "appId")
+	if param == "" {
+		if user := GetUserFromContext(r.Context()); user == nil || !user.IsAdmin() {
+			writeError(w, http.StatusForbidden, "Admin access required")
+			return nil, false
+		}
+		return nil, true
+	}
+	appID, err := uuid.Parse(param)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid app ID")
+		return nil, false
+	}
+	return &appID, true
+}
+
+// channel returns the channel in the URL if it belongs to the request's scope
+func (h *NotificationChannelHandler) channel(w http.ResponseWriter, r *http.Request) (*domain.NotificationChannel, bool) {
+	appID, ok := h.scope(w, r)
+	if !ok {
+		return nil, false
+	}
+	channelID, err := uuid.Parse(chi.URLParam(r, "channelId"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid channel ID")
+		return nil, false
+	}
+	channel, err := h.store.Get(r.Context(), channelID)
+	if err != nil || (channel.AppID == nil) != (appID == nil) || (appID != nil && *channel.AppID != *appID) {
+		writeError(w, http.StatusNotFound, "Notification channel not found")
+		return nil, false
+	}
+	return channel, true
+}
+
+func channelResponse(c *domain.NotificationChannel) NotificationChannelResponse {
+	return NotificationChannelResponse{
+		NotificationChannel: c,
+		URL:                 c.RedactedURL(),
+		Signed:              c.Secret != "",
+	}
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+	"github.com/nanopaas/nanopaas/internal/services/notify"
+	ws "github.com/nanopaas/nanopaas/pkg/websocket"
+)
+
+// Default and maximum page sizes for the notification inbox
+const (
+	defaultNotificationLimit = 50
+	maxNotificationLimit     = 200
+)
+
+// NotificationStore interface for reading and updating a user's notification inbox
+type NotificationStore interface {
+	ListForUser(ctx context.Context, userID uuid.UUID, unreadOnly bool, limit int) ([]*domain.Notification, error)
+	CountUnread(ctx context.Context, userID uuid.UUID) (int64, error)
+	MarkRead(ctx context.Context, userID, id uuid.UUID) (bool, error)
+	MarkAllRead(ctx context.Context, userID uuid.UUID) (int64, error)
+}
+
+// NotificationHandler handles the notification inbox and push stream
+type NotificationHandler struct {
+	store  NotificationStore
+	wsAuth *WSAuthenticator
+	wsHub  *ws.Hub
+	logger *zap.Logger
+}
+
+// NewNotificationHandler creates a new notification handler
+func NewNotificationHandler(store NotificationStore, wsAuth *WSAuthenticator, wsHub *ws.Hub, logger *zap.Logger) *NotificationHandler {
+	return &NotificationHandler{
+		store:  store,
+		wsAuth: wsAuth,
+		wsHub:  wsHub,
+		logger: logger,
+	}
+}
+
+// List returns the current user's notifications, newest first
+// Query params: unread=true to only return unread notifications, limit (default 50, max 200)
+func (h *NotificationHandler) List(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Not authenticated")
+		return
+	}
+
+	limit := defaultNotificationLimit
+	if l := r.URL.Query().Get("limit"); l != "" {
+		parsed, err := strconv.Atoi(l)
+		if err != nil || parsed <= 0 {
+			writeError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		if parsed > maxNotificationLimit {
+			parsed = maxNotificationLimit
+		}
+		limit = parsed
+	}
+	unreadOnly := r.URL.Query().Get("unread") == "true"
+
+	notifications, err := h.store.ListForUser(r.Context(), user.ID, unreadOnly, limit)
+	if err != nil {
+		h.logger.Error("Failed to list notifications", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "Failed to list notifications")
+		return
+	}
+
+	unread, err := h.store.CountUnread(r.Context(), user.ID)
+	if err != nil {
+		h.logger.Error("Failed to count notifications", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "Failed to list notifications")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"notifications": notifications,
+		"unread_count":  unread,
+	})
+}
+
+// MarkRead marks one of the current user's notifications read
+func (h *NotificationHandler) MarkRead(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Not authenticated")
+		return
+	}
+
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid notification ID")
+		return
+	}
+
+	found, err := h.store.MarkRead(r.Context(), user.ID, id)
+	if err != nil {
+		h.logger.Error("Failed to mark notification read", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "Failed to update notification")
+		return
+	}
+	if !found {
+		writeError(w, http.StatusNotFound, "Notification not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Notification marked read"})
+}
+
+// MarkAllRead marks all of the current user's notifications read
+func (h *NotificationHandler) MarkAllRead(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Not authenticated")
+		return
+	}
+
+	updated, err := h.store.MarkAllRead(r.Context(), user.ID)
+	if err != nil {
+		h.logger.Error("Failed to mark notifications read", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "Failed to update notifications")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Notifications marked read",
+		"updated": updated,
+	})
+}
+
+// Stream pushes the user's notifications over WebSocket as they are created
+func (h *NotificationHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.wsAuth.Authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	conn, err := h.wsAuth.Upgrade(w, r)
+	if err != nil {
+		h.logger.Error("WebSocket upgrade failed", zap.Error(err))
+		return
+	}
+
+	client := ws.NewClient(h.wsHub, conn)
+	client.SetAuthorizer(h.wsAuth.TopicAuthorizer(user))
+	h.wsHub.Register(client)
+	h.wsHub.Subscribe(client, notify.UserTopic(user.ID))
+
+	h.logger.Debug("Client subscribed to notifications",
+		zap.String("user_id", user.ID.String()),
+		zap.String("client_id", client.ID.String()),
+	)
+
+	go client.WritePump()
+	go client.ReadPump()
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+	"github.com/nanopaas/nanopaas/internal/services/auth"
+	"github.com/nanopaas/nanopaas/internal/services/oidc"
+)
+
+// Note: AuthHandler and NewAuthHandler are defined in auth_handler.go
+// This file adds single sign-on through an OpenID Connect provider to the existing AuthHandler
+
+// oidcCookie holds the state, nonce and PKCE verifier of a sign-in in progress
+const oidcCookie = "oidc_auth"
+
+// AuthProvidersResponse lists the ways users can sign in, for the login page
+type AuthProvidersResponse struct {
+	GitHub   bool              `json:"github"`
+	Password bool              `json:"password"`
+	Signup   bool              `json:"signup"`
+	OIDC     *OIDCProviderInfo `json:"oidc,omitempty"`
+}
+
+// OIDCProviderInfo describes the single sign-on provider
+type OIDCProviderInfo struct {
+	Name     string `json:"name"`
+	LoginURL string `json:"login_url"`
+}
+
+// SetOIDCProvider enables single sign-on through provider
+func (h *AuthHandler) SetOIDCProvider(provider *oidc.Provider) {
+	h.oidc = provider
+}
+
+// Providers lists the enabled sign-in methods
+func (h *AuthHandler) Providers(w http.ResponseWriter, r *http.Request) {
+	resp := AuthProvidersResponse{GitHub: h.githubService.OAuthConfigured()}
+	resp.Password, resp.Signup = h.authService.PasswordLoginEnabled()
+	if h.oidc != nil {
+		resp.OIDC = &OIDCProviderInfo{
+			Name:     h.oidc.Name(),
+			LoginURL: strings.TrimSuffix(r.URL.Path, "/providers") + "/oidc",
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// OIDCLogin redirects to the identity provider, remembering the sign-in's state, nonce
+// and PKCE verifier in a short-lived cookie
+func (h *AuthHandler) OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	if h.oidc == nil {
+		writeError(w, http.StatusNotFound, "Single sign-on is not enabled")
+		return
+	}
+
+	req, err := oidc.NewAuthRequest()
+	if err != nil {
+		h.logger.Error("Failed to start OIDC sign-in", zap.Error(err))
+		h.redirectWithError(w, r, "auth_failed", "Failed to start sign-in")
+		return
+	}
+	authURL, err := h.oidc.AuthURL(r.Context(), req)
+	if err != nil {
+		h.logger.Error("Failed to reach OIDC provider", zap.Error(err))
+		h.redirectWithError(w, r, "provider_unavailable", "Identity provider is unavailable")
+		return
+	}
+
+	h.setStateCookie(w, oidcCookie, req.State+"."+req.Nonce+"."+req.Verifier)
+	http.Redirect(w, r, authURL, http.StatusTemporaryRedirect)
+}
+
+// OIDCCallback handles the identity provider's redirect back with an authorization code
+func (h *AuthHandler) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	if h.oidc == nil {
+		writeError(w, http.StatusNotFound, "Single sign-on is not enabled")
+		return
+	}
+
+	query := r.URL.Query()
+	if e := query.Get("error"); e != "" {
+		h.logger.Info("OIDC sign-in refused by provider", zap.String("error", e), zap.String("description", query.Get("error_description")))
+		h.redirectWithError(w, r, "access_denied", "Sign-in was cancelled or refused")
+		return
+	}
+	code := query.Get("code")
+	if code == "" {
+		h.redirectWithError(w, r, "missing_code", "Authorization code not provided")
+		return
+	}
+
+	// Verify state against the signed cookie set by OIDCLogin
+	value, ok := h.readStateCookie(w, r, oidcCookie)
+	parts := []string{}
+	if ok {
+		parts = strings.Split(value, ".")
+	}
+	if len(parts) != 3 || parts[0] != query.Get("state") {
+		h.redirectWithError(w, r, "invalid_state", "Invalid state parameter")
+		return
+	}
+
+	identity, err := h.oidc.Exchange(r.Context(), code, &oidc.AuthRequest{State: parts[0], Nonce: parts[1], Verifier: parts[2]})
+	if err != nil {
+		h.logger.Error("Failed to complete OIDC sign-in", zap.Error(err))
+		h.redirectWithError(w, r, "exchange_failed", "Failed to authenticate with the identity provider")
+		return
+	}
+
+	ctx := auth.WithClientInfo(r.Context(), auth.ClientInfo{IP: r.RemoteAddr, UserAgent: r.UserAgent(), Provider: domain.LoginProviderOIDC})
+	user, tokens, err := h.authService.AuthenticateOIDC(ctx, identity)
+	if h.redirectTwoFactor(w, r, err) {
+		return
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrOIDCEmailRequired):
+			h.redirectWithError(w, r, "email_required", "The identity provider did not share your email address")
+		case errors.Is(err, auth.ErrEmailTaken):
+			h.redirectWithError(w, r, "email_taken", "An account with this email already exists")
+		case errors.Is(err, auth.ErrUserDeactivated):
+			h.redirectWithError(w, r, "account_deactivated", "This account has been deactivated")
+		default:
+			h.logger.Error("Failed to authenticate OIDC user", zap.Error(err))
+			h.redirectWithError(w, r, "auth_failed", "Failed to authenticate")
+		}
+		return
+	}
+
+	h.logger.Info("User authenticated via OIDC",
+		zap.String("user_id", user.ID.String()),
+		zap.String("role", string(user.Role)),
+	)
+
+	h.redirectSignedIn(w, r, tokens)
+}
@@ -0,0 +1,374 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+	"github.com/nanopaas/nanopaas/internal/middleware"
+	"github.com/nanopaas/nanopaas/internal/services/auth"
+	"github.com/nanopaas/nanopaas/internal/services/github"
+	"github.com/nanopaas/nanopaas/internal/services/gitprovider"
+	"github.com/nanopaas/nanopaas/internal/services/manifest"
+	"github.com/nanopaas/nanopaas/internal/services/templates"
+	"github.com/nanopaas/nanopaas/internal/version"
+	"github.com/nanopaas/nanopaas/pkg/openapi"
+)
+
+// apiBody documents the JSON bodies of an operation
+type apiBody struct {
+	Request  interface{}
+	Response interface{}
+	Status   int
+	List     bool // Response is one item of a paged list, see writeList
+}
+
+// apiBodies maps handler methods ("AppHandler.Create") to the bodies they accept and
+// return. Routes come from the router, so an operation missing here is still listed,
+// just without body schemas. Add an entry alongside new handlers.
+var apiBodies = map[string]apiBody{
+	"AuthHandler.GetCurrentUser":            {Response: domain.User{}},
+	"AppHandler.List":                       {Response: AppResponse{}, List: true},
+	"AppHandler.Create":                     {Request: CreateAppRequest{}, Response: AppResponse{}, Status: http.StatusCreated},
+	"AppHandler.Get":                        {Response: AppResponse{}},
+	"AppHandler.Update":                     {Request: UpdateAppRequest{}, Response: AppResponse{}},
+	"AppHandler.Deploy":                     {Request: DeployRequest{}},
+	"AppHandler.Scale":                      {Request: ScaleRequest{}},
+	"AppHandler.ConnectRepository":          {Request: ConnectRepoRequest{}, Response: AppResponse{}},
+	"AppHandler.DisconnectRepository":       {Response: AppResponse{}},
+	"AppHandler.SetEnvVars":                 {Request: map[string]string{}},
+	"AppHandler.ListSecrets":                {Response: []SecretResponse{}},
+	"AppHandler.SetSecrets":                 {Request: map[string]string{}},
+	"AppHandler.ListDeployments":            {Response: domain.Deployment{}, List: true},
+	"AppHandler.Changelog":                  {Response: ChangelogEntry{}, List: true},
+	"AppHandler.ListPendingApprovals":       {Response: []PendingApprovalResponse{}},
+	"AppHandler.RejectDeployment":           {Request: RejectDeploymentRequest{}, Response: domain.Deployment{}},
+	"AppHandler.ImportCompose":              {Request: ComposeImportRequest{}, Response: ComposeImportResponse{}, Status: http.StatusCreated},
+	"AppHandler.ExportManifest":             {Response: manifest.Manifest{}},
+	"AppHandler.ApplyManifest":              {Request: manifest.Manifest{}, Response: ManifestApplyResponse{}},
+	"AppHandler.ListTemplates":              {Response: []templates.Template{}},
+	"AppHandler.Overview":                   {Response: OverviewResponse{}},
+	"AppHandler.CreateFromTemplate":         {Request: CreateFromTemplateRequest{}, Response: CreateFromTemplateResponse{}, Status: http.StatusAccepted},
+	"AppHandler.SetCORS":                    {Request: domain.CORSPolicy{}},
+	"AppHandler.SetHSTS":                    {Request: domain.HSTSPolicy{}},
+	"AppHandler.SetStickySessions":          {Request: StickySessionsRequest{}},
+	"AppHandler.SetMaintenance":             {Request: MaintenanceRequest{}},
+	"AppHandler.SetNetworkRoute":            {Request: NetworkRouteRequest{}},
+	"AppHandler.GetEffectiveConfig":         {Response: domain.EffectiveConfig{}},
+	"AppHandler.GetRouting":                 {Response: RoutingResponse{}},
+	"AppHandler.SetRouting":                 {Request: RoutingRequest{}, Response: RoutingResponse{}},
+	"AppHandler.Protect":                    {Request: ProtectAppRequest{}, Response: ProtectAppResponse{}},
+	"AppHandler.Unprotect":                  {Response: ProtectAppResponse{}},
+	"AppHandler.Pin":                        {Request: PinDeploymentRequest{}, Response: domain.DeploymentPin{}},
+	"AppHandler.OpenIncident":               {Request: OpenIncidentRequest{}, Response: domain.Incident{}, Status: http.StatusCreated},
+	"AppHandler.GetIncident":                {Response: domain.Incident{}},
+	"AppHandler.AddIncidentNote":            {Request: IncidentEventRequest{}, Status: http.StatusCreated},
+	"AppHandler.ResolveIncident":            {Request: IncidentEventRequest{}, Response: domain.Incident{}},
+	"AppHandler.AddDomain":                  {Request: AddDomainRequest{}, Response: CustomDomainResponse{}, Status: http.StatusCreated},
+	"AppHandler.VerifyDomain":               {Response: CustomDomainResponse{}},
+	"BuildHandler.List":                     {Response: BuildResponse{}, List: true},
+	"BuildHandler.Create":                   {Request: CreateBuildRequest{}, Response: BuildResponse{}, Status: http.StatusCreated},
+	"BuildHandler.Get":                      {Response: BuildResponse{}},
+	"LogHandler.GetBuildLogs":               {Response: BuildLogPage{}},
+	"ContainerHandler.List":                 {Response: ContainerResponse{}, List: true},
+	"ContainerHandler.Create":               {Request: CreateContainerRequest{}, Status: http.StatusCreated},
+	"ContainerHandler.Get":                  {Response: ContainerResponse{}},
+	"GitHubHandler.CreateWebhook":           {Request: WebhookRequest{}, Response: github.Webhook{}, Status: http.StatusCreated},
+	"GitHubHandler.ListWebhooks":            {Response: []github.Webhook{}},
+	"ImageHandler.List":                     {Response: []ImageResponse{}},
+	"ImageHandler.Get":                      {Response: ImageDetailResponse{}},
+	"PromotionHandler.Promote":              {Request: PromoteImageRequest{}, Response: domain.ImagePromotion{}, Status: http.StatusCreated},
+	"PromotionHandler.List":                 {Response: []domain.ImagePromotion{}},
+	"SystemHandler.Prune":                   {Request: PruneRequest{}},
+	"CertificateHandler.Issue":              {Request: IssueCertificateRequest{}, Response: CertificateResponse{}},
+	"CertificateHandler.Renew":              {Response: CertificateResponse{}},
+	"NotificationChannelHandler.List":       {Response: []NotificationChannelResponse{}},
+	"NotificationChannelHandler.Create":     {Request: CreateNotificationChannelRequest{}, Response: NotificationChannelResponse{}, Status: http.StatusCreated},
+	"NotificationChannelHandler.Update":     {Request: UpdateNotificationChannelRequest{}, Response: NotificationChannelResponse{}},
+	"NotificationChannelHandler.Test":       {Response: domain.NotificationDelivery{}},
+	"NotificationChannelHandler.Deliveries": {Response: []domain.NotificationDelivery{}},
+	"AppHandler.CreateProject":              {Request: CreateProjectRequest{}, Response: ProjectResponse{}, Status: http.StatusCreated},
+	"AppHandler.GetProject":                 {Response: ProjectDashboardResponse{}},
+	"AppHandler.UpdateProject":              {Request: UpdateProjectRequest{}, Response: ProjectResponse{}},
+	"AppHandler.SetProjectEnvVars":          {Request: map[string]string{}},
+	"AppHandler.AddProjectApp":              {Request: ProjectAppRequest{}, Response: AppResponse{}},
+	"AppHandler.RemoveProjectApp":           {Response: AppResponse{}},
+	"AuthHandler.ListAPIKeys":               {Response: []domain.APIKey{}},
+	"AuthHandler.CreateAPIKey":              {Request: CreateAPIKeyRequest{}, Response: CreateAPIKeyResponse{}, Status: http.StatusCreated},
+	"AuthHandler.ListSessions":              {Response: []SessionResponse{}},
+	"AuthHandler.ListLogins":                {Response: domain.LoginEvent{}, List: true},
+	"AuthHandler.Signup":                    {Request: SignupRequest{}, Response: SignupResponse{}, Status: http.StatusCreated},
+	"AuthHandler.Login":                     {Request: LoginRequest{}, Response: LoginResponse{}},
+	"AuthHandler.VerifyEmail":               {Request: VerifyEmailRequest{}},
+	"AuthHandler.ResendVerification":        {Request: EmailRequest{}, Status: http.StatusAccepted},
+	"AuthHandler.ForgotPassword":            {Request: EmailRequest{}, Status: http.StatusAccepted},
+	"AuthHandler.ResetPassword":             {Request: ResetPasswordRequest{}},
+	"AuthHandler.ChangePassword":            {Request: ChangePasswordRequest{}},
+	"AuthHandler.Providers":                 {Response: AuthProvidersResponse{}},
+	"AuthHandler.EnrollTwoFactor":           {Response: TwoFactorEnrollmentResponse{}},
+	"AuthHandler.ConfirmTwoFactor":          {Request: TwoFactorCodeRequest{}, Response: RecoveryCodesResponse{}},
+	"AuthHandler.DisableTwoFactor":          {Request: TwoFactorCodeRequest{}},
+	"AuthHandler.RegenerateRecoveryCodes":   {Request: TwoFactorCodeRequest{}, Response: RecoveryCodesResponse{}},
+	"AuthHandler.VerifyTwoFactor":           {Request: TwoFactorVerifyRequest{}, Response: LoginResponse{}},
+	"AppHandler.AddCollaborator":            {Request: AddCollaboratorRequest{}, Response: domain.AppCollaborator{}, Status: http.StatusCreated},
+	"AppHandler.UpdateCollaborator":         {Request: UpdateCollaboratorRequest{}, Response: domain.AppCollaborator{}},
+	"AppHandler.ListDeployTokens":           {Response: []domain.DeployToken{}},
+	"AppHandler.CreateDeployToken":          {Request: CreateDeployTokenRequest{}, Response: CreateDeployTokenResponse{}, Status: http.StatusCreated},
+	"GitHubHandler.GetApp":                  {Response: GitHubAppResponse{}},
+	"GitHubHandler.ListInstallations":       {Response: []github.Installation{}},
+	"GitHubHandler.ListInstallationRepos":   {Response: InstallationReposResponse{}},
+	"GitHubHandler.GetAppWebhook":           {Response: github.AppWebhookConfig{}},
+	"GitHubHandler.UpdateAppWebhook":        {Request: UpdateAppWebhookRequest{}, Response: github.AppWebhookConfig{}},
+	"GitProviderHandler.ListProviders":      {Response: []GitProviderResponse{}},
+	"GitProviderHandler.SetToken":           {Request: GitTokenRequest{}, Response: []GitProviderResponse{}},
+	"GitProviderHandler.ListRepositories":   {Response: []gitprovider.Repository{}},
+	"GitProviderHandler.GetRepository":      {Response: gitprovider.Repository{}},
+	"GitProviderHandler.ListBranches":       {Response: []gitprovider.Branch{}},
+	"GitProviderHandler.ListWebhooks":       {Response: []gitprovider.Webhook{}},
+	"GitProviderHandler.CreateWebhook":      {Request: GitWebhookRequest{}, Response: gitprovider.Webhook{}, Status: http.StatusCreated},
+	"AuthHandler.JWKS":                      {Response: auth.JWKS{}},
+	"UserHandler.List":                      {Response: domain.User{}, List: true},
+	"UserHandler.Get":                       {Response: domain.User{}},
+	"UserHandler.UpdateRole":                {Request: UpdateUserRoleRequest{}, Response: domain.User{}},
+	"UserHandler.Deactivate":                {Response: domain.User{}},
+	"UserHandler.Reactivate":                {Response: domain.User{}},
+	"UserHandler.Delete":                    {Response: DeleteUserResponse{}},
+	"UserHandler.ResetTwoFactor":            {Response: domain.User{}},
+	"UserHandler.ListLogins":                {Response: domain.LoginEvent{}, List: true},
+	"AppHandler.Clone":                      {Request: CloneAppRequest{}, Response: AppResponse{}, Status: http.StatusCreated},
+	"AppHandler.DeployProject":              {Status: http.StatusAccepted},
+}
+
+// listQueryParams documents the query parameters read by parseListParams
+var listQueryParams = []openapi.Parameter{
+	openapi.QueryParam("limit", "integer", "Page size, at most 500"),
+	openapi.QueryParam("offset", "integer", "Items to skip"),
+	openapi.QueryParam("status", "string", "Exact status to match"),
+	openapi.QueryParam("name", "string", "Case-insensitive substring to match"),
+	openapi.QueryParam("sort", "string", "Field to sort by, prefixed with - for descending order"),
+}
+
+// idempotencyKeyParam documents the header read by Idempotency.Middleware
+var idempotencyKeyParam = openapi.Parameter{
+	Name:        IdempotencyHeader,
+	In:          "header",
+	Description: "Retry-safe key; repeating it replays the first response",
+	Schema:      &openapi.Schema{Type: "string"},
+}
+
+// OpenAPIHandler serves an OpenAPI document generated from the API's routes
+type OpenAPIHandler struct {
+	routes chi.Routes
+	logger *zap.Logger
+
+	mu    sync.Mutex
+	specs map[int]*openapi.Document // by API version, built on first request
+}
+
+// NewOpenAPIHandler creates a handler documenting the routes mounted under /api/vN on routes
+func NewOpenAPIHandler(routes chi.Routes, logger *zap.Logger) *OpenAPIHandler {
+	return &OpenAPIHandler{
+		routes: routes,
+		logger: logger,
+		specs:  make(map[int]*openapi.Document),
+	}
+}
+
+// Spec returns the OpenAPI document of the API version serving the request
+func (h *OpenAPIHandler) Spec(w http.ResponseWriter, r *http.Request) {
+	apiVersion := middleware.APIVersionFromContext(r.Context())
+
+	h.mu.Lock()
+	doc, ok := h.specs[apiVersion]
+	if !ok {
+		var err error
+		doc, err = h.build(apiVersion)
+		if err != nil {
+			h.mu.Unlock()
+			h.logger.Error("Failed to build OpenAPI document", zap.Error(err))
+			writeError(w, http.StatusInternalServerError, "Failed to build OpenAPI document")
+			return
+		}
+		h.specs[apiVersion] = doc
+	}
+	h.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, doc)
+}
+
+// build walks the router and documents every route under the version's prefix
+func (h *OpenAPIHandler) build(apiVersion int) (*openapi.Document, error) {
+	prefix := fmt.Sprintf("/api/v%d", apiVersion)
+	doc := openapi.NewDocument(openapi.Info{
+		Title:       "NanoPaaS API",
+		Version:     version.Get().Version,
+		Description: "Build, deploy and operate containerized apps.",
+	}, prefix)
+	doc.SetErrorType(ErrorResponse{})
+
+	deprecated := make(map[string]bool)
+	for _, d := range DeprecatedRoutes {
+		deprecated[d.Method+" "+d.Pattern] = true
+	}
+
+	err := chi.Walk(h.routes, func(method, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		pattern, ok := strings.CutPrefix(route, prefix)
+		if !ok || pattern == "/openapi.json" || pattern == "/docs" {
+			return nil
+		}
+		typeName, methodName := handlerName(handler)
+
+		rt := openapi.Route{
+			Method:      method,
+			Pattern:     pattern,
+			OperationID: operationID(typeName, methodName),
+			Summary:     summary(typeName, methodName),
+			Tag:         strings.Split(strings.TrimPrefix(pattern, "/"), "/")[0],
+			Secured:     hasMiddleware(middlewares, "handlers.AuthMiddleware."),
+			Deprecated:  deprecated[method+" "+route],
+		}
+		if body, ok := apiBodies[typeName+"."+methodName]; ok {
+			rt.Request, rt.Response, rt.Status = body.Request, body.Response, body.Status
+			if body.List {
+				rt.Response = listSchema(doc, apiVersion, body.Response)
+				rt.Params = listQueryParams
+			}
+		}
+		if hasMiddleware(middlewares, "handlers.(*Idempotency).Middleware") {
+			rt.Params = append(rt.Params, idempotencyKeyParam)
+		}
+		doc.AddRoute(rt)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// listSchema describes what writeList returns for one item type
+func listSchema(doc *openapi.Document, apiVersion int, item interface{}) *openapi.Schema {
+	items := openapi.ArrayOf(doc.Schema(item))
+	if apiVersion == 1 {
+		return items
+	}
+	return &openapi.Schema{
+		Type: "object",
+		Properties: map[string]*openapi.Schema{
+			"items":  items,
+			"total":  {Type: "integer"},
+			"limit":  {Type: "integer"},
+			"offset": {Type: "integer"},
+		},
+		Required: []string{"items", "total", "limit", "offset"},
+	}
+}
+
+// handlerName returns the receiver type and method of a method-value handler, e.g.
+// "AppHandler" and "Create" for appHandler.Create
+func handlerName(handler http.Handler) (string, string) {
+	v := reflect.ValueOf(handler)
+	if v.Kind() != reflect.Func {
+		return "", ""
+	}
+	fn := runtime.FuncForPC(v.Pointer())
+	if fn == nil {
+		return "", ""
+	}
+	name := strings.TrimSuffix(fn.Name(), "-fm")
+	name = name[strings.LastIndex(name, "/")+1:]
+	parts := strings.Split(name, ".")
+	if len(parts) < 3 {
+		return "", parts[len(parts)-1]
+	}
+	return strings.Trim(parts[1], "(*)"), parts[2]
+}
+
+// hasMiddleware reports whether a route's middlewares include a function whose name
+// contains name, e.g. "handlers.AuthMiddleware." for the closures AuthMiddleware returns
+func hasMiddleware(middlewares []func(http.Handler) http.Handler, name string) bool {
+	for _, mw := range middlewares {
+		if fn := runtime.FuncForPC(reflect.ValueOf(mw).Pointer()); fn != nil &&
+			strings.Contains(fn.Name(), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// operationID names an operation after its handler, e.g. appCreate for AppHandler.Create
+func operationID(typeName, methodName string) string {
+	id := strings.TrimSuffix(typeName, "Handler") + methodName
+	if id == "" {
+		return "operation"
+	}
+	return strings.ToLower(id[:1]) + id[1:]
+}
+
+// summary describes an operation by its handler method, naming the resource for bare
+// verbs, e.g. "Set CORS" or "List apps" for AppHandler.List
+func summary(typeName, methodName string) string {
+	text := humanize(methodName)
+	if strings.Contains(text, " ") || typeName == "" {
+		return text
+	}
+	resource := strings.ToLower(humanize(strings.TrimSuffix(typeName, "Handler")))
+	if methodName == "List" {
+		resource += "s"
+	}
+	return text + " " + resource
+}
+
+// humanize turns a method name into a sentence, e.g. SetCORS into "Set CORS"
+func humanize(name string) string {
+	var words []string
+	runes := []rune(name)
+	start := 0
+	for i := 1; i <= len(runes); i++ {
+		boundary := i == len(runes) ||
+			(unicode.IsUpper(runes[i]) && unicode.IsLower(runes[i-1])) ||
+			(unicode.IsUpper(runes[i]) && i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))
+		if !boundary {
+			continue
+		}
+		word := string(runes[start:i])
+		if len(words) > 0 && strings.ToUpper(word) != word {
+			word = strings.ToLower(word)
+		}
+		words = append(words, word)
+		start = i
+	}
+	return strings.Join(words, " ")
+}
+
+// swaggerUIPage loads Swagger UI from a CDN and points it at the version's document
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>NanoPaaS API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: "%s/openapi.json", dom_id: "#swagger-ui" });
+  </script>
+</body>
+</html>
+`
+
+// Docs serves Swagger UI for the API version serving the request
+func (h *OpenAPIHandler) Docs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, swaggerUIPage, fmt.Sprintf("/api/v%d", middleware.APIVersionFromContext(r.Context())))
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/nanopaas/nanopaas/internal/middleware"
+)
+
+// Page sizes for list endpoints
+const (
+	defaultListLimit = 50
+	maxListLimit     = 500
+)
+
+// listParams holds the pagination, filtering and sorting parameters of a list request
+type listParams struct {
+	Limit  int // 0 returns every item
+	Offset int
+	Sort   string
+	Desc   bool
+	Status string
+	Name   string // case-insensitive substring match
+}
+
+// ListResponse is the envelope list endpoints return from API v2
+type ListResponse struct {
+	Items  interface{} `json:"items"`
+	Total  int         `json:"total"`
+	Limit  int         `json:"limit"`
+	Offset int         `json:"offset"`
+}
+
+// parseListParams reads limit, offset, status, name and sort from the query. sort is one
+// of sortable, prefixed with "-" for descending order, and defaults to defaultSort.
+// API v1 returned unbounded lists, so it only pages when a limit is given.
+func parseListParams(r *http.Request, sortable []string, defaultSort string) (listParams, error) {
+	q := r.URL.Query()
+	p := listParams{
+		Status: q.Get("status"),
+		Name:   strings.ToLower(strings.TrimSpace(q.Get("name"))),
+	}
+	if middleware.APIVersionFromContext(r.Context()) != 1 {
+		p.Limit = defaultListLimit
+	}
+
+	if l := q.Get("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit <= 0 {
+			return p, fmt.Errorf("limit must be a positive integer")
+		}
+		p.Limit = min(limit, maxListLimit)
+	}
+	if o := q.Get("offset"); o != "" {
+		offset, err := strconv.Atoi(o)
+		if err != nil || offset < 0 {
+			return p, fmt.Errorf("offset must be a non-negative integer")
+		}
+		p.Offset = offset
+	}
+
+	sort := q.Get("sort")
+	if sort == "" {
+		sort = defaultSort
+	}
+	p.Sort, p.Desc = strings.TrimPrefix(sort, "-"), strings.HasPrefix(sort, "-")
+	if !slices.Contains(sortable, p.Sort) {
+		return p, fmt.Errorf("sort must be one of: %s", strings.Join(sortable, ", "))
+	}
+	return p, nil
+}
+
+// matchesName reports whether name contains the name filter
+func (p listParams) matchesName(name string) bool {
+	return p.Name == "" || strings.Contains(strings.ToLower(name), p.Name)
+}
+
+// sortAndPage sorts items by the requested key and returns the requested page and the
+// total before paging. compare holds a comparison for each sortable key.
+func sortAndPage[T any](items []T, p listParams, compare map[string]func(a, b T) int) ([]T, int) {
+	if cmp, ok := compare[p.Sort]; ok {
+		slices.SortStableFunc(items, func(a, b T) int {
+			if p.Desc {
+				return cmp(b, a)
+			}
+			return cmp(a, b)
+		})
+	}
+
+	total := len(items)
+	if p.Offset >= total {
+		return items[:0], total
+	}
+	items = items[p.Offset:]
+	if p.Limit > 0 && len(items) > p.Limit {
+		items = items[:p.Limit]
+	}
+	return items, total
+}
+
+// writeList writes a page of items. API v1 keeps its bare array body and reports the
+// total in X-Total-Count; later versions use ListResponse.
+func writeList(w http.ResponseWriter, r *http.Request, items interface{}, total int, p listParams) {
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	if middleware.APIVersionFromContext(r.Context()) == 1 {
+		writeJSON(w, http.StatusOK, items)
+		return
+	}
+	writeJSON(w, http.StatusOK, ListResponse{
+		Items:  items,
+		Total:  total,
+		Limit:  p.Limit,
+		Offset: p.Offset,
+	})
+}
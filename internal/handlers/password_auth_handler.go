@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+	"github.com/nanopaas/nanopaas/internal/services/auth"
+)
+
+// Note: AuthHandler and NewAuthHandler are defined in auth_handler.go
+// This file adds email/password accounts to the existing AuthHandler
+
+// SignupRequest represents a request to create an email/password account
+type SignupRequest struct {
+	Email    string `json:"email"`
+	Name     string `json:"name,omitempty"` // defaults to the part of the email before @
+	Password string `json:"password"`
+}
+
+// LoginRequest represents an email/password sign-in
+type LoginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// LoginResponse returns the signed-in user with their tokens. Users with two-factor
+// authentication get TwoFactorToken instead, to send with a code to /auth/2fa/verify
+// within five minutes.
+type LoginResponse struct {
+	*auth.TokenPair
+	User *domain.User `json:"user,omitempty"`
+
+	TwoFactorRequired bool   `json:"two_factor_required,omitempty"`
+	TwoFactorToken    string `json:"two_factor_token,omitempty"`
+}
+
+// SignupResponse returns the new, not yet verified account
+type SignupResponse struct {
+	Message string       `json:"message"`
+	User    *domain.User `json:"user"`
+}
+
+// EmailRequest names the account a verification or reset email is sent to
+type EmailRequest struct {
+	Email string `json:"email"`
+}
+
+// VerifyEmailRequest carries the token from a verification email
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+// ResetPasswordRequest carries the token from a reset email and the new password
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// ChangePasswordRequest sets a signed-in user's password. CurrentPassword is required
+// when the user already has one.
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password,omitempty"`
+	NewPassword     string `json:"new_password"`
+}
+
+// Signup creates an email/password account and emails a verification link
+func (h *AuthHandler) Signup(w http.ResponseWriter, r *http.Request) {
+	var req SignupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user, err := h.authService.Signup(r.Context(), req.Email, req.Name, req.Password)
+	if err != nil {
+		h.writePasswordAuthError(w, err, "Failed to sign up")
+		return
+	}
+	writeJSON(w, http.StatusCreated, SignupResponse{
+		Message: "Account created; check your email to verify it",
+		User:    user,
+	})
+}
+
+// Login signs in with email and password
+func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
+	var req LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	ctx := auth.WithClientInfo(r.Context(), auth.ClientInfo{IP: r.RemoteAddr, UserAgent: r.UserAgent(), Provider: domain.LoginProviderPassword})
+	user, tokens, err := h.authService.Login(ctx, req.Email, req.Password)
+	if twoFactorLoginResponse(w, err) {
+		return
+	}
+	if err != nil {
+		h.writePasswordAuthError(w, err, "Failed to sign in")
+		return
+	}
+	if h.cookies.Session {
+		h.setSessionCookies(w, tokens)
+	}
+	writeJSON(w, http.StatusOK, LoginResponse{TokenPair: tokens, User: user})
+}
+
+// VerifyEmail verifies an email address with the token from a verification email
+func (h *AuthHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req VerifyEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		writeError(w, http.StatusBadRequest, "Token is required")
+		return
+	}
+
+	if _, err := h.authService.VerifyEmail(r.Context(), req.Token); err != nil {
+		h.writePasswordAuthError(w, err, "Failed to verify email")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"message": "Email verified; you can now sign in",
+	})
+}
+
+// ResendVerification emails a new verification link. The response is the same whether
+// or not the address has an account.
+func (h *AuthHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	var req EmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
+		writeError(w, http.StatusBadRequest, "Email is required")
+		return
+	}
+
+	if err := h.authService.ResendVerification(r.Context(), req.Email); err != nil {
+		h.writePasswordAuthError(w, err, "Failed to send verification email")
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]string{
+		"message": "If the address has an unverified account, a new link is on its way",
+	})
+}
+
+// ForgotPassword emails a password reset link. The response is the same whether or not
+// the address has an account.
+func (h *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req EmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
+		writeError(w, http.StatusBadRequest, "Email is required")
+		return
+	}
+
+	if err := h.authService.RequestPasswordReset(r.Context(), req.Email); err != nil {
+		h.writePasswordAuthError(w, err, "Failed to send reset email")
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]string{
+		"message": "If the address has an account, a reset link is on its way",
+	})
+}
+
+// ResetPassword sets a new password with the token from a reset email, signing the user
+// out everywhere
+func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Token == "" {
+		writeError(w, http.StatusBadRequest, "Token is required")
+		return
+	}
+
+	if err := h.authService.ResetPassword(r.Context(), req.Token, req.Password); err != nil {
+		h.writePasswordAuthError(w, err, "Failed to reset password")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"message": "Password reset; sign in with your new password",
+	})
+}
+
+// ChangePassword sets the signed-in user's password
+func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	user, ok := requireSessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	current := h.authService.SessionIDFromToken(sessionToken(r))
+	if err := h.authService.ChangePassword(r.Context(), user, req.CurrentPassword, req.NewPassword, current); err != nil {
+		if errors.Is(err, auth.ErrInvalidCredentials) {
+			writeError(w, http.StatusForbidden, "Current password is incorrect")
+			return
+		}
+		h.writePasswordAuthError(w, err, "Failed to change password")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"message": "Password changed",
+	})
+}
+
+// writePasswordAuthError maps email/password errors to responses
+func (h *AuthHandler) writePasswordAuthError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, auth.ErrPasswordLoginDisabled):
+		writeError(w, http.StatusNotFound, "Password sign-in is not enabled")
+	case errors.Is(err, auth.ErrSignupDisabled):
+		writeError(w, http.StatusForbidden, "Signup is disabled; ask an admin for an account")
+	case errors.Is(err, auth.ErrInvalidCredentials):
+		writeError(w, http.StatusUnauthorized, "Invalid email or password")
+	case errors.Is(err, auth.ErrUserDeactivated):
+		writeError(w, http.StatusForbidden, "This account has been deactivated")
+	case errors.Is(err, auth.ErrEmailNotVerified):
+		writeError(w, http.StatusForbidden, "Verify your email address before signing in")
+	case errors.Is(err, auth.ErrInvalidEmail):
+		writeError(w, http.StatusBadRequest, "Invalid email address")
+	case errors.Is(err, auth.ErrInvalidUserToken):
+		writeError(w, http.StatusBadRequest, "This link is invalid or has expired")
+	case errors.Is(err, auth.ErrWeakPassword):
+		writeError(w, http.StatusBadRequest, "Password must be 8 to 72 characters long")
+	case errors.Is(err, auth.ErrEmailTaken):
+		writeError(w, http.StatusConflict, "An account with this email already exists; sign in or reset its password")
+	default:
+		h.logger.Error(message, zap.Error(err))
+		writeError(w, http.StatusInternalServerError, message)
+	}
+}
@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+	"github.com/nanopaas/nanopaas/internal/infrastructure/docker"
+)
+
+// PromotionStore interface for persisting image promotions
+type PromotionStore interface {
+	Create(ctx context.Context, p *domain.ImagePromotion) error
+	// List returns the newest promotions of the apps with the given slugs, or of every
+	// app when appSlugs is nil
+	List(ctx context.Context, appSlugs []string, environment string, limit int) ([]*domain.ImagePromotion, error)
+}
+
+// ManagedAppLister lists the apps a user can manage
+type ManagedAppLister interface {
+	ManagedApps(ctx context.Context, user *domain.User) []*domain.App
+}
+
+// PromotionHandler handles image promotion endpoints
+type PromotionHandler struct {
+	dockerClient docker.ContainerRuntime
+	store        PromotionStore
+	registry     string
+	registryAuth string
+	apps         ManagedAppLister
+	logger       *zap.Logger
+}
+
+// PromoteImageRequest represents a request to promote an image to an environment
+type PromoteImageRequest struct {
+	Environment string `json:"environment"`
+	Push        *bool  `json:"push,omitempty"` // defaults to true when a registry is configured
+}
+
+// NewPromotionHandler creates a new promotion handler
+// registry may be empty, in which case promoted tags stay local
+func NewPromotionHandler(dockerClient docker.ContainerRuntime, store PromotionStore, registry, registryAuth string, logger *zap.Logger) *PromotionHandler {
+	return &PromotionHandler{
+		dockerClient: dockerClient,
+		store:        store,
+		registry:     registry,
+		registryAuth: registryAuth,
+		logger:       logger,
+	}
+}
+
+// SetManagedAppLister sets where the apps a user can manage are looked up. Users may only
+// promote and list the images of their apps; without it, only admins may.
+func (h *PromotionHandler) SetManagedAppLister(apps ManagedAppLister) {
+	h.apps = apps
+}
+
+// managedSlugs returns the slugs of the apps the user can manage, or nil for admins,
+// who can manage every app
+func (h *PromotionHandler) managedSlugs(r *http.Request) map[string]bool {
+	user := GetUserFromContext(r.Context())
+	if user != nil && user.IsAdmin() {
+		return nil
+	}
+	slugs := make(map[string]bool)
+	if user == nil || h.apps == nil {
+		return slugs
+	}
+	for _, app := range h.apps.ManagedApps(r.Context(), user) {
+		slugs[app.Slug] = true
+	}
+	return slugs
+}
+
+// Promote retags an existing image for an environment and optionally pushes it
+func (h *PromotionHandler) Promote(w http.ResponseWriter, r *http.Request) {
+	imageRef := chi.URLParam(r, "id")
+	if imageRef == "" {
+		writeError(w, http.StatusBadRequest, "Image ID is required")
+		return
+	}
+
+	var req PromoteImageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if !domain.IsValidEnvironment(req.Environment) {
+		writeError(w, http.StatusBadRequest, "environment must be lowercase alphanumeric with dashes (max 32 chars)")
+		return
+	}
+
+	push := h.registry != ""
+	if req.Push != nil {
+		push = *req.Push
+	}
+	if push && h.registry == "" {
+		writeError(w, http.StatusBadRequest, "No registry is configured for pushing")
+		return
+	}
+
+	info, err := h.dockerClient.InspectImage(r.Context(), imageRef)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Image not found")
+		return
+	}
+
+	var labels map[string]string
+	if info.Config != nil {
+		labels = info.Config.Labels
+	}
+	appSlug := labels["nanopaas.app.slug"]
+	if labels["built-by"] != "nanopaas" || appSlug == "" {
+		writeError(w, http.StatusBadRequest, "Only images built by NanoPaaS can be promoted")
+		return
+	}
+	if managed := h.managedSlugs(r); managed != nil && !managed[appSlug] {
+		writeError(w, http.StatusNotFound, "Image not found")
+		return
+	}
+
+	// Tag by content-addressed ID so the exact tested bytes are promoted
+	targetTag := "nanopaas/" + appSlug + ":" + req.Environment
+	if push {
+		targetTag = h.registry + "/" + targetTag
+	}
+	if err := h.dockerClient.TagImage(r.Context(), info.ID, targetTag); err != nil {
+		h.logger.Error("Failed to tag image", zap.Error(err), zap.String("image", info.ID))
+		writeError(w, http.StatusInternalServerError, "Failed to tag image")
+		return
+	}
+
+	var promotedBy uuid.UUID
+	if user := GetUserFromContext(r.Context()); user != nil {
+		promotedBy = user.ID
+	}
+
+	promotion := domain.NewImagePromotion(appSlug, info.ID, imageRef, targetTag, req.Environment, promotedBy)
+	promotion.BuildID = labels["nanopaas.build.id"]
+
+	if push {
+		digest, err := h.dockerClient.PushImage(r.Context(), targetTag, h.registryAuth)
+		if err != nil {
+			h.logger.Error("Failed to push promoted image", zap.Error(err), zap.String("tag", targetTag))
+			writeError(w, http.StatusBadGateway, "Image was tagged but pushing to the registry failed")
+			return
+		}
+		promotion.Pushed = true
+		promotion.Digest = digest
+	}
+
+	if err := h.store.Create(r.Context(), promotion); err != nil {
+		h.logger.Error("Failed to record promotion", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "Image was promoted but recording the promotion failed")
+		return
+	}
+
+	h.logger.Info("Image promoted",
+		zap.String("image", info.ID),
+		zap.String("target_tag", targetTag),
+		zap.String("environment", req.Environment),
+		zap.Bool("pushed", promotion.Pushed),
+	)
+
+	writeJSON(w, http.StatusCreated, promotion)
+}
+
+// List returns recorded promotions of the apps the user can manage, filterable by
+// app_slug and environment
+func (h *PromotionHandler) List(w http.ResponseWriter, r *http.Request) {
+	limit := 50
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 500 {
+			limit = parsed
+		}
+	}
+
+	var slugs []string
+	managed := h.managedSlugs(r)
+	if slug := r.URL.Query().Get("app_slug"); slug != "" {
+		slugs = []string{}
+		if managed == nil || managed[slug] {
+			slugs = append(slugs, slug)
+		}
+	} else if managed != nil {
+		slugs = make([]string, 0, len(managed))
+		for slug := range managed {
+			slugs = append(slugs, slug)
+		}
+	}
+	if slugs != nil && len(slugs) == 0 {
+		writeJSON(w, http.StatusOK, []*domain.ImagePromotion{})
+		return
+	}
+
+	promotions, err := h.store.List(r.Context(), slugs, r.URL.Query().Get("environment"), limit)
+	if err != nil {
+		h.logger.Error("Failed to list promotions", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "Failed to list promotions")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, promotions)
+}
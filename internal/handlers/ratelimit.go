@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// RateLimitStore counts requests against a budget shared by every API replica
+type RateLimitStore interface {
+	// CheckRateLimit reports whether a request is allowed and, when it is not, how
+	// long until the budget resets
+	CheckRateLimit(ctx context.Context, key string, limit int, window time.Duration) (bool, time.Duration, error)
+}
+
+// RateLimitPolicy is the request budget of a route group
+type RateLimitPolicy struct {
+	Requests int
+	Window   time.Duration
+}
+
+// RateLimiter limits API requests per user, or per client IP for requests without one
+type RateLimiter struct {
+	store  RateLimitStore
+	logger *zap.Logger
+}
+
+// NewRateLimiter creates a rate limiter backed by store; a nil store disables limiting
+func NewRateLimiter(store RateLimitStore, logger *zap.Logger) *RateLimiter {
+	return &RateLimiter{store: store, logger: logger}
+}
+
+// Limit holds each client of a route group to policy. Mount it after AuthMiddleware so
+// requests count against their user; group names the budget so groups do not share one.
+// Rejected requests get 429 with Retry-After.
+func (l *RateLimiter) Limit(group string, policy RateLimitPolicy) func(http.Handler) http.Handler {
+	limit := strconv.Itoa(policy.Requests)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if l.store == nil || policy.Requests <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			allowed, retryAfter, err := l.store.CheckRateLimit(r.Context(), group+":"+rateLimitClient(r), policy.Requests, policy.Window)
+			if err != nil {
+				// An unreachable store should not take the API down with it
+				l.logger.Warn("Rate limit check failed", zap.String("group", group), zap.Error(err))
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("X-RateLimit-Limit", limit)
+			if !allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+				writeError(w, http.StatusTooManyRequests, "Rate limit exceeded")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// rateLimitClient identifies who a request counts against: its user, or its client IP
+func rateLimitClient(r *http.Request) string {
+	if user := GetUserFromContext(r.Context()); user != nil {
+		return "user:" + user.ID.String()
+	}
+	// RemoteAddr already holds the client address set by middleware.RealIP
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// RoleDenial is the detail of a 403 from RequireRole
+type RoleDenial struct {
+	Permission domain.Permission `json:"permission"`
+	Role       domain.UserRole   `json:"role"`
+}
+
+// RequireRole rejects requests from users whose role lacks the permission the request
+// needs. It is mounted after AuthMiddleware:
+//   - RequireRole(UserRoleMember) guards app routes: viewers may read, members may also
+//     change the apps they manage.
+//   - RequireRole(UserRoleAdmin) guards containers, the host and platform settings.
+func RequireRole(role domain.UserRole) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := GetUserFromContext(r.Context())
+			if user == nil {
+				writeError(w, http.StatusUnauthorized, "Not authenticated")
+				return
+			}
+			permission := requiredPermission(role, r.Method)
+			if !user.Role.Can(permission) {
+				writeAPIError(w, &APIError{
+					Status:  http.StatusForbidden,
+					Code:    CodeForbidden,
+					Message: "Missing permission " + string(permission) + " (role " + string(user.Role) + ")",
+					Details: RoleDenial{Permission: permission, Role: user.Role},
+				})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requiredPermission returns the permission a request needs on routes guarded for role.
+// Reads need only the read half of the role's permissions.
+func requiredPermission(role domain.UserRole, method string) domain.Permission {
+	read := method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+	switch {
+	case role == domain.UserRoleAdmin && read:
+		return domain.PermissionSystemRead
+	case role == domain.UserRoleAdmin:
+		return domain.PermissionSystemWrite
+	case role == domain.UserRoleViewer || read:
+		return domain.PermissionAppsRead
+	default:
+		return domain.PermissionAppsWrite
+	}
+}
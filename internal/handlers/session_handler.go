@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+	"github.com/nanopaas/nanopaas/internal/services/auth"
+)
+
+// Note: AuthHandler and NewAuthHandler are defined in auth_handler.go
+// This file adds listing and revoking sign-in sessions to the existing AuthHandler
+
+// SessionResponse represents a session in API responses
+type SessionResponse struct {
+	*domain.Session
+	Current bool `json:"current"` // the session of the requesting token
+}
+
+// ListSessions returns the user's active sessions
+func (h *AuthHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	user, ok := requireSessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	sessions, err := h.authService.ListSessions(r.Context(), user.ID)
+	if err != nil {
+		h.writeSessionError(w, err, "Failed to list sessions")
+		return
+	}
+
+	current := h.authService.SessionIDFromToken(bearerToken(r))
+	response := make([]SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, SessionResponse{Session: session, Current: session.ID == current})
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// RevokeSession signs the user out of one session
+func (h *AuthHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	user, ok := requireSessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.authService.RevokeSession(r.Context(), user.ID, chi.URLParam(r, "sessionId")); err != nil {
+		h.writeSessionError(w, err, "Failed to revoke session")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"message": "Session revoked",
+	})
+}
+
+// RevokeAllSessions signs the user out everywhere, including the requesting session
+func (h *AuthHandler) RevokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	user, ok := requireSessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	revoked, err := h.authService.RevokeAllSessions(r.Context(), user.ID)
+	if err != nil {
+		h.writeSessionError(w, err, "Failed to revoke sessions")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "All sessions revoked",
+		"revoked": revoked,
+	})
+}
+
+// ListLogins returns a page of the user's sign-in attempts, newest first
+func (h *AuthHandler) ListLogins(w http.ResponseWriter, r *http.Request) {
+	user, ok := requireSessionUser(w, r)
+	if !ok {
+		return
+	}
+	writeLoginEvents(w, r, h.authService, h.logger, user.ID)
+}
+
+// writeLoginEvents writes a page of a user's sign-in attempts
+func writeLoginEvents(w http.ResponseWriter, r *http.Request, authService *auth.Service, logger *zap.Logger, userID uuid.UUID) {
+	p, err := parseListParams(r, []string{"created_at"}, "-created_at")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit := p.Limit
+	if limit == 0 {
+		limit = defaultListLimit
+	}
+
+	events, total, err := authService.ListLoginEvents(r.Context(), userID, limit, p.Offset)
+	if err != nil {
+		logger.Error("Failed to list login events", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "Failed to list login events")
+		return
+	}
+	writeList(w, r, events, total, p)
+}
+
+func (h *AuthHandler) writeSessionError(w http.ResponseWriter, err error, message string) {
+	if errors.Is(err, auth.ErrSessionsDisabled) {
+		writeError(w, http.StatusServiceUnavailable, "Session tracking is not enabled")
+		return
+	}
+	if !errors.Is(err, domain.ErrNotFound) {
+		h.logger.Error(message, zap.Error(err))
+	}
+	writeDomainError(w, err, message)
+}
+
+// bearerToken returns the token of the Authorization header, if any
+func bearerToken(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "bearer") {
+		return ""
+	}
+	return token
+}
+
+// sessionToken returns the access token a request signed in with, from the
+// Authorization header or the session cookie
+func sessionToken(r *http.Request) string {
+	if token := bearerToken(r); token != "" {
+		return token
+	}
+	return cookieToken(r, accessTokenCookie)
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	ws "github.com/nanopaas/nanopaas/pkg/websocket"
+)
+
+// Server-Sent Events carry the same envelopes as the WebSocket topics, for networks that
+// block WebSockets. Each event's id is a UNIX nanosecond time; a reconnecting EventSource
+// sends the last one back in Last-Event-ID and the stream resumes after it.
+const (
+	sseRetry     = 3 * time.Second  // reconnect delay suggested to clients
+	sseKeepalive = 30 * time.Second // comment sent on idle streams so proxies keep them open
+)
+
+// IsEventStream reports whether a request asks for Server-Sent Events
+func IsEventStream(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// sseStream writes hub envelopes as Server-Sent Events
+type sseStream struct {
+	w           http.ResponseWriter
+	rc          *http.ResponseController
+	topic       string
+	resumeAfter int64 // events at or before this id were already received
+}
+
+// newSSEStream starts an event stream response, resuming after the given event id
+func newSSEStream(w http.ResponseWriter, topic string, resumeAfter int64) (*sseStream, error) {
+	s := &sseStream{
+		w:           w,
+		rc:          http.NewResponseController(w),
+		topic:       topic,
+		resumeAfter: resumeAfter,
+	}
+	// Streams outlive the server's write timeout
+	s.rc.SetWriteDeadline(time.Time{})
+
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no") // disable nginx response buffering
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", sseRetry.Milliseconds())
+	return s, s.rc.Flush()
+}
+
+// lastEventID reads the resume point from the Last-Event-ID header or last_event_id param
+func lastEventID(r *http.Request) (int64, error) {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("last_event_id")
+	}
+	if value == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id < 0 {
+		return 0, fmt.Errorf("invalid Last-Event-ID")
+	}
+	return id, nil
+}
+
+// Send writes a message in the hub's envelope
+func (s *sseStream) Send(messageType string, payload interface{}) error {
+	data, err := ws.NewEnvelope(s.topic, messageType, payload)
+	if err != nil {
+		return err
+	}
+	return s.writeEnvelope(data)
+}
+
+// sendError writes an error message
+func (s *sseStream) sendError(message string) {
+	s.Send("error", map[string]string{"error": message})
+}
+
+// writeEnvelope writes an encoded envelope as one event, skipping events the client
+// received before reconnecting
+func (s *sseStream) writeEnvelope(data []byte) error {
+	id := envelopeEventID(data)
+	if id != 0 && id <= s.resumeAfter {
+		return nil
+	}
+	var err error
+	if id != 0 {
+		_, err = fmt.Fprintf(s.w, "id: %d\ndata: %s\n\n", id, data)
+	} else {
+		_, err = fmt.Fprintf(s.w, "data: %s\n\n", data)
+	}
+	return err
+}
+
+// pump forwards the client's hub messages until the request ends or the hub drops the client
+func (s *sseStream) pump(r *http.Request, client *ws.Client) {
+	defer client.Hub.Unregister(client)
+
+	keepalive := time.NewTicker(sseKeepalive)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case data, ok := <-client.Send:
+			if !ok {
+				return
+			}
+			if err := s.writeEnvelope(data); err != nil {
+				return
+			}
+			// Write whatever else is queued before flushing
+			for n := len(client.Send); n > 0; n-- {
+				data, ok := <-client.Send
+				if !ok {
+					return
+				}
+				if err := s.writeEnvelope(data); err != nil {
+					return
+				}
+			}
+		case <-keepalive.C:
+			if _, err := fmt.Fprint(s.w, ": keepalive\n\n"); err != nil {
+				return
+			}
+		}
+		if err := s.rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// envelopeEventID returns the event id of an envelope: the line's own time for log lines,
+// so resumed log streams line up with container output, else the envelope's time
+func envelopeEventID(data []byte) int64 {
+	var env struct {
+		Type      string    `json:"type"`
+		Timestamp time.Time `json:"timestamp"`
+		Payload   struct {
+			Timestamp time.Time `json:"timestamp"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(data, &env); err != nil {
+		// Non-object payloads fail above; fall back to the envelope alone
+		var plain ws.Envelope
+		if json.Unmarshal(data, &plain) != nil {
+			return 0
+		}
+		return plain.Timestamp.UnixNano()
+	}
+	if env.Type == "log" && !env.Payload.Timestamp.IsZero() {
+		return env.Payload.Timestamp.UnixNano()
+	}
+	return env.Timestamp.UnixNano()
+}
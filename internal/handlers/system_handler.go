@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/infrastructure/docker"
+)
+
+// pruneTimeout bounds a prune, which can take a while on hosts with many images
+const pruneTimeout = 10 * time.Minute
+
+// SystemHandler handles container host disk usage and cleanup endpoints
+type SystemHandler struct {
+	dockerClient docker.ContainerRuntime
+	appLister    AppLister
+	logger       *zap.Logger
+}
+
+// PruneRequest selects what to prune; an empty body prunes stopped containers,
+// dangling images, unused networks and build cache, but never volumes
+type PruneRequest struct {
+	Containers *bool  `json:"containers,omitempty"`
+	Images     *bool  `json:"images,omitempty"`
+	AllImages  bool   `json:"all_images,omitempty"` // unused tagged images too; app images are kept for rollback
+	Volumes    bool   `json:"volumes,omitempty"`
+	Networks   *bool  `json:"networks,omitempty"`
+	BuildCache *bool  `json:"build_cache,omitempty"`
+	Until      string `json:"until,omitempty"` // only prune objects older than this duration, e.g. "24h"
+}
+
+// NewSystemHandler creates a new system handler
+func NewSystemHandler(dockerClient docker.ContainerRuntime, logger *zap.Logger) *SystemHandler {
+	return &SystemHandler{
+		dockerClient: dockerClient,
+		logger:       logger,
+	}
+}
+
+// SetAppLister sets the app lister used to protect app images from pruning
+func (h *SystemHandler) SetAppLister(lister AppLister) {
+	h.appLister = lister
+}
+
+// DiskUsage reports space used by images, containers, volumes and build cache
+func (h *SystemHandler) DiskUsage(w http.ResponseWriter, r *http.Request) {
+	usage, err := h.dockerClient.DiskUsage(r.Context())
+	if err != nil {
+		h.logger.Error("Failed to get disk usage", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "Failed to get disk usage")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, usage)
+}
+
+// Prune removes unused Docker objects to reclaim disk space
+func (h *SystemHandler) Prune(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	var req PruneRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	opts := docker.PruneOptions{
+		Containers: boolOrDefault(req.Containers, true),
+		Images:     boolOrDefault(req.Images, true) || req.AllImages,
+		AllImages:  req.AllImages,
+		Volumes:    req.Volumes,
+		Networks:   boolOrDefault(req.Networks, true),
+		BuildCache: boolOrDefault(req.BuildCache, true),
+	}
+	if req.Until != "" {
+		until, err := time.ParseDuration(req.Until)
+		if err != nil || until < 0 {
+			writeError(w, http.StatusBadRequest, "until must be a duration such as 24h")
+			return
+		}
+		opts.Until = until
+	}
+
+	// Keep current and previous app images so deploys and rollbacks still work
+	if opts.AllImages && h.appLister != nil {
+		for _, app := range h.appLister.ListApps() {
+			if app.CurrentImageID != "" {
+				opts.KeepImages = append(opts.KeepImages, app.CurrentImageID)
+			}
+			if app.PreviousImageID != "" {
+				opts.KeepImages = append(opts.KeepImages, app.PreviousImageID)
+			}
+		}
+	}
+
+	// Detach from the request so a client disconnect doesn't abort the prune midway
+	ctx, cancel := context.WithTimeout(context.Background(), pruneTimeout)
+	defer cancel()
+
+	report, err := h.dockerClient.Prune(ctx, opts)
+	if err != nil {
+		h.logger.Error("Prune failed", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "Prune failed")
+		return
+	}
+
+	h.logger.Info("Docker prune triggered",
+		zap.String("user_id", GetUserFromContext(r.Context()).ID.String()),
+		zap.Uint64("space_reclaimed", report.SpaceReclaimed),
+	)
+	writeJSON(w, http.StatusOK, report)
+}
+
+// boolOrDefault returns *b, or def when b is unset
+func boolOrDefault(b *bool, def bool) bool {
+	if b == nil {
+		return def
+	}
+	return *b
+}
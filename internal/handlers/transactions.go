@@ -0,0 +1,17 @@
+package handlers
+
+import "context"
+
+// Transactor runs units of work whose store writes commit or roll back together
+type Transactor interface {
+	InTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// inTx runs fn as a unit of work of tx. Without a transactor, fn's writes are not
+// grouped.
+func inTx(ctx context.Context, tx Transactor, fn func(ctx context.Context) error) error {
+	if tx == nil {
+		return fn(ctx)
+	}
+	return tx.InTx(ctx, fn)
+}
@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/services/auth"
+)
+
+// Note: AuthHandler and NewAuthHandler are defined in auth_handler.go
+// This file adds TOTP two-factor authentication to the existing AuthHandler
+
+// TwoFactorEnrollmentResponse carries a new TOTP secret. OTPAuthURL is the content of
+// the QR code authenticator apps scan; Secret is for typing in by hand.
+type TwoFactorEnrollmentResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURL string `json:"otpauth_url"`
+}
+
+// TwoFactorCodeRequest carries a code from the user's authenticator app or, where
+// accepted, a recovery code
+type TwoFactorCodeRequest struct {
+	Code string `json:"code"`
+}
+
+// TwoFactorVerifyRequest answers the challenge a sign-in returned
+type TwoFactorVerifyRequest struct {
+	TwoFactorToken string `json:"two_factor_token"`
+	Code           string `json:"code"` // TOTP code or recovery code
+}
+
+// RecoveryCodesResponse returns recovery codes, which are shown only once
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// EnrollTwoFactor starts two-factor enrollment, returning the secret to add to an
+// authenticator app
+func (h *AuthHandler) EnrollTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, ok := requireSessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	secret, otpauthURL, err := h.authService.StartTwoFactorEnrollment(r.Context(), user)
+	if err != nil {
+		h.writeTwoFactorError(w, err, "Failed to start two-factor enrollment")
+		return
+	}
+	writeJSON(w, http.StatusOK, TwoFactorEnrollmentResponse{Secret: secret, OTPAuthURL: otpauthURL})
+}
+
+// ConfirmTwoFactor enables two-factor authentication with a first code from the app
+func (h *AuthHandler) ConfirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, ok := requireSessionUser(w, r)
+	if !ok {
+		return
+	}
+	var req TwoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		writeError(w, http.StatusBadRequest, "Code is required")
+		return
+	}
+
+	codes, err := h.authService.ConfirmTwoFactor(r.Context(), user, req.Code)
+	if err != nil {
+		h.writeTwoFactorError(w, err, "Failed to enable two-factor authentication")
+		return
+	}
+	writeJSON(w, http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DisableTwoFactor turns two-factor authentication off
+func (h *AuthHandler) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, ok := requireSessionUser(w, r)
+	if !ok {
+		return
+	}
+	var req TwoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		writeError(w, http.StatusBadRequest, "Code is required")
+		return
+	}
+
+	if err := h.authService.DisableTwoFactor(r.Context(), user, req.Code); err != nil {
+		h.writeTwoFactorError(w, err, "Failed to disable two-factor authentication")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"message": "Two-factor authentication disabled",
+	})
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes
+func (h *AuthHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	user, ok := requireSessionUser(w, r)
+	if !ok {
+		return
+	}
+	var req TwoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		writeError(w, http.StatusBadRequest, "Code is required")
+		return
+	}
+
+	codes, err := h.authService.RegenerateRecoveryCodes(r.Context(), user, req.Code)
+	if err != nil {
+		h.writeTwoFactorError(w, err, "Failed to regenerate recovery codes")
+		return
+	}
+	writeJSON(w, http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// VerifyTwoFactor finishes a sign-in with a TOTP or recovery code and returns the tokens
+func (h *AuthHandler) VerifyTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var req TwoFactorVerifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.TwoFactorToken == "" || req.Code == "" {
+		writeError(w, http.StatusBadRequest, "two_factor_token and code are required")
+		return
+	}
+
+	ctx := auth.WithClientInfo(r.Context(), auth.ClientInfo{IP: r.RemoteAddr, UserAgent: r.UserAgent()})
+	user, tokens, err := h.authService.VerifyTwoFactor(ctx, req.TwoFactorToken, req.Code)
+	if err != nil {
+		h.writeTwoFactorError(w, err, "Failed to verify two-factor code")
+		return
+	}
+	if h.cookies.Session {
+		h.setSessionCookies(w, tokens)
+	}
+	writeJSON(w, http.StatusOK, LoginResponse{TokenPair: tokens, User: user})
+}
+
+// writeTwoFactorError maps two-factor errors to responses
+func (h *AuthHandler) writeTwoFactorError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, auth.ErrInvalidTwoFactorCode):
+		writeError(w, http.StatusUnauthorized, "Invalid two-factor code")
+	case errors.Is(err, auth.ErrInvalidToken), errors.Is(err, auth.ErrExpiredToken), errors.Is(err, auth.ErrRevokedToken):
+		writeError(w, http.StatusUnauthorized, "Sign-in expired; sign in again")
+	case errors.Is(err, auth.ErrUserDeactivated):
+		writeError(w, http.StatusForbidden, "This account has been deactivated")
+	case errors.Is(err, auth.ErrTwoFactorEnabled):
+		writeError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+	case errors.Is(err, auth.ErrTwoFactorNotEnabled), errors.Is(err, auth.ErrTwoFactorNotEnrolled):
+		writeError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, auth.ErrTwoFactorPolicy):
+		writeError(w, http.StatusForbidden, "Two-factor authentication is required for every user and can't be disabled")
+	default:
+		h.logger.Error(message, zap.Error(err))
+		writeError(w, http.StatusInternalServerError, message)
+	}
+}
+
+// twoFactorLoginResponse writes the challenge a sign-in returned instead of tokens,
+// returning false for any other error
+func twoFactorLoginResponse(w http.ResponseWriter, err error) bool {
+	var challenge *auth.TwoFactorChallenge
+	if !errors.As(err, &challenge) {
+		return false
+	}
+	writeJSON(w, http.StatusOK, LoginResponse{
+		TwoFactorRequired: true,
+		TwoFactorToken:    challenge.Token,
+	})
+	return true
+}
+
+// redirectTwoFactor sends a browser sign-in that needs a second factor back to the
+// frontend with the challenge, returning false for any other error
+func (h *AuthHandler) redirectTwoFactor(w http.ResponseWriter, r *http.Request, err error) bool {
+	var challenge *auth.TwoFactorChallenge
+	if !errors.As(err, &challenge) {
+		return false
+	}
+	redirectURL := h.frontendURL + "/auth/callback?two_factor_token=" + url.QueryEscape(challenge.Token)
+	http.Redirect(w, r, redirectURL, http.StatusTemporaryRedirect)
+	return true
+}
+
+// requireTwoFactorEnrollment turns away users the two-factor policy blocks until they
+// enroll
+func requireTwoFactorEnrollment(authService *auth.Service, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user := GetUserFromContext(r.Context()); user != nil && authService.NeedsTwoFactorEnrollment(user) {
+			writeAPIError(w, &APIError{
+				Status:  http.StatusForbidden,
+				Code:    CodeTwoFactorEnrollment,
+				Message: auth.ErrTwoFactorEnrollmentRequired.Error(),
+			})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+	"github.com/nanopaas/nanopaas/internal/services/auth"
+)
+
+// userSortKeys are the keys the admin user list can be sorted by
+var userSortKeys = []string{"created_at", "email", "name", "last_login_at"}
+
+// UserHandler handles admin user management endpoints
+type UserHandler struct {
+	authService *auth.Service
+	owners      OwnerReassigner
+	logger      *zap.Logger
+}
+
+// OwnerReassigner updates loaded resources when a deleted user's are reassigned
+type OwnerReassigner interface {
+	ReassignOwner(from, to uuid.UUID)
+}
+
+// UpdateUserRoleRequest represents a request to change a user's role
+type UpdateUserRoleRequest struct {
+	Role domain.UserRole `json:"role"` // admin, member or viewer
+}
+
+// DeleteUserResponse reports who took over a deleted user's apps
+type DeleteUserResponse struct {
+	Message      string       `json:"message"`
+	ReassignedTo *domain.User `json:"reassigned_to"`
+}
+
+// NewUserHandler creates a new user handler
+func NewUserHandler(authService *auth.Service, logger *zap.Logger) *UserHandler {
+	return &UserHandler{
+		authService: authService,
+		logger:      logger,
+	}
+}
+
+// SetOwnerReassigner sets what is told when a deleted user's apps change owner
+func (h *UserHandler) SetOwnerReassigner(owners OwnerReassigner) {
+	h.owners = owners
+}
+
+// List returns a page of users. name matches names and emails, role and status
+// (active or deactivated) filter, and sort orders by created_at, email, name or
+// last_login_at.
+func (h *UserHandler) List(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	p, err := parseListParams(r, userSortKeys, "-created_at")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if p.Status != "" && p.Status != "active" && p.Status != "deactivated" {
+		writeError(w, http.StatusBadRequest, "status must be active or deactivated")
+		return
+	}
+	role := domain.UserRole(r.URL.Query().Get("role"))
+	if role != "" && !role.IsValid() {
+		writeError(w, http.StatusBadRequest, auth.ErrInvalidRole.Error())
+		return
+	}
+
+	users, total, err := h.authService.ListUsers(r.Context(), domain.UserQuery{
+		Search: p.Name,
+		Role:   role,
+		Status: p.Status,
+		Sort:   p.Sort,
+		Desc:   p.Desc,
+		Limit:  p.Limit,
+		Offset: p.Offset,
+	})
+	if err != nil {
+		h.logger.Error("Failed to list users", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "Failed to list users")
+		return
+	}
+	writeList(w, r, users, total, p)
+}
+
+// Get returns a user
+func (h *UserHandler) Get(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	id, ok := parseUserID(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := h.authService.GetUser(r.Context(), id)
+	if err != nil {
+		h.writeUserError(w, err, "Failed to get user")
+		return
+	}
+	writeJSON(w, http.StatusOK, user)
+}
+
+// UpdateRole changes a user's role
+func (h *UserHandler) UpdateRole(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	id, ok := parseUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req UpdateUserRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user, err := h.authService.SetUserRole(r.Context(), GetUserFromContext(r.Context()), id, req.Role)
+	if err != nil {
+		h.writeUserError(w, err, "Failed to change role")
+		return
+	}
+	writeJSON(w, http.StatusOK, user)
+}
+
+// Deactivate stops a user from signing in and signs them out everywhere
+func (h *UserHandler) Deactivate(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	id, ok := parseUserID(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := h.authService.DeactivateUser(r.Context(), GetUserFromContext(r.Context()), id)
+	if err != nil {
+		h.writeUserError(w, err, "Failed to deactivate user")
+		return
+	}
+	writeJSON(w, http.StatusOK, user)
+}
+
+// Reactivate lets a deactivated user sign in again
+func (h *UserHandler) Reactivate(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	id, ok := parseUserID(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := h.authService.ReactivateUser(r.Context(), GetUserFromContext(r.Context()), id)
+	if err != nil {
+		h.writeUserError(w, err, "Failed to reactivate user")
+		return
+	}
+	writeJSON(w, http.StatusOK, user)
+}
+
+// Delete deletes a user. Their apps, projects and teams go to the user named by the
+// reassign_to query parameter, or to the admin making the request.
+func (h *UserHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	id, ok := parseUserID(w, r)
+	if !ok {
+		return
+	}
+	var reassignTo uuid.UUID
+	if v := r.URL.Query().Get("reassign_to"); v != "" {
+		var err error
+		if reassignTo, err = uuid.Parse(v); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid reassign_to user ID")
+			return
+		}
+	}
+
+	newOwner, err := h.authService.DeleteUser(r.Context(), GetUserFromContext(r.Context()), id, reassignTo)
+	if err != nil {
+		h.writeUserError(w, err, "Failed to delete user")
+		return
+	}
+	if h.owners != nil {
+		h.owners.ReassignOwner(id, newOwner.ID)
+	}
+	writeJSON(w, http.StatusOK, DeleteUserResponse{
+		Message:      "User deleted; their apps, projects and teams were reassigned",
+		ReassignedTo: newOwner,
+	})
+}
+
+// ResetTwoFactor turns off a user's two-factor authentication, for users who lost their
+// device and recovery codes
+func (h *UserHandler) ResetTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	id, ok := parseUserID(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := h.authService.ResetTwoFactor(r.Context(), GetUserFromContext(r.Context()), id)
+	if err != nil {
+		h.writeUserError(w, err, "Failed to reset two-factor authentication")
+		return
+	}
+	writeJSON(w, http.StatusOK, user)
+}
+
+// ListLogins returns a page of a user's sign-in attempts, newest first
+func (h *UserHandler) ListLogins(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	id, ok := parseUserID(w, r)
+	if !ok {
+		return
+	}
+	writeLoginEvents(w, r, h.authService, h.logger, id)
+}
+
+// writeUserError maps user management errors to responses
+func (h *UserHandler) writeUserError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, auth.ErrInvalidRole), errors.Is(err, auth.ErrInvalidNewOwner), errors.Is(err, auth.ErrSelfAdminister):
+		writeError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, auth.ErrLastAdmin):
+		writeError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, domain.ErrNotFound):
+		writeError(w, http.StatusNotFound, "User not found")
+	default:
+		h.logger.Error(message, zap.Error(err))
+		writeError(w, http.StatusInternalServerError, message)
+	}
+}
+
+// parseUserID reads the userId URL parameter, writing 400 when it is not a UUID
+func parseUserID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	id, err := uuid.Parse(chi.URLParam(r, "userId"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid user ID")
+		return uuid.Nil, false
+	}
+	return id, true
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// Note: WebhookHandler and NewWebhookHandler are defined in webhook_handler.go
+// This file verifies per-app webhook deliveries with the apps' own secrets
+
+// WebhookSecretOpener decrypts apps' sealed webhook secrets
+type WebhookSecretOpener interface {
+	Open(sealed, additionalData []byte) ([]byte, error)
+}
+
+// SetWebhookSecretOpener enables verifying deliveries with apps' own webhook secrets.
+// Without it, deliveries for apps that have one are refused.
+func (h *WebhookHandler) SetWebhookSecretOpener(opener WebhookSecretOpener) {
+	h.secretOpener = opener
+}
+
+// verifyAppDelivery checks a delivery for app with verify, passing the app's own webhook
+// secret. Apps without one, and unknown apps, are checked with "", which verify treats
+// as the provider's global secret. Once an app has its own secret the global one no
+// longer passes for it, so a leaked global secret can't trigger its builds: pushes to
+// the global webhook, which are only signed with the global secret, skip it too.
+func (h *WebhookHandler) verifyAppDelivery(app *domain.App, verify func(secret string) bool) bool {
+	if app == nil || len(app.WebhookSecret) == 0 {
+		return verify("")
+	}
+	if h.secretOpener == nil {
+		h.logger.Warn("Can't verify webhook with the app's secret: SECRETS_MASTER_KEY is not set",
+			zap.String("app_id", app.ID.String()))
+		return false
+	}
+	secret, err := h.secretOpener.Open(app.WebhookSecret, domain.WebhookSecretAAD(app.ID))
+	if err != nil {
+		h.logger.Error("Failed to open app webhook secret", zap.String("app_id", app.ID.String()), zap.Error(err))
+		return false
+	}
+	return verify(string(secret))
+}
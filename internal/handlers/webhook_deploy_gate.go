@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+	"github.com/nanopaas/nanopaas/internal/services/github"
+	"github.com/nanopaas/nanopaas/internal/services/gitprovider"
+)
+
+// Note: WebhookHandler and NewWebhookHandler are defined in webhook_handler.go
+// This file holds auto-deploys of apps that require checks until GitHub reports them passed
+
+const (
+	// deployGateInterval is how often pending checks are polled
+	deployGateInterval = 30 * time.Second
+
+	// deployGateTimeout is how long a built commit waits for its checks to pass
+	deployGateTimeout = 30 * time.Minute
+)
+
+// errChecksNotVerifiable is returned when an app requires checks GitHub can't be asked about
+var errChecksNotVerifiable = errors.New("required checks can't be verified without GitHub access to the repository")
+
+// awaitDeployGate returns once a built push may deploy. Apps that require checks wait
+// for the commit's status checks to pass: those branch protection requires on the
+// tracked branch, or every reported check when the branch isn't protected. Branch pushes
+// must then still be on the tracked branch, so commits dropped by a force-push are not
+// deployed. It returns why the deploy must not happen, including when a check fails or
+// checks are still pending after deployGateTimeout.
+func (h *WebhookHandler) awaitDeployGate(app *domain.App, trigger domain.DeployTrigger, ref string, event *gitprovider.PushEvent) error {
+	if !app.RequireChecks {
+		return nil
+	}
+	owner, repo, ok := strings.Cut(event.Repository, "/")
+	if h.github == nil || !ok {
+		return errChecksNotVerifiable
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), deployGateTimeout)
+	defer cancel()
+
+	token, err := h.githubToken(ctx, app, owner, repo)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errChecksNotVerifiable, err)
+	}
+	commit := event.After
+	if commit == "" {
+		commit = ref // releases name a tag, not a commit
+	}
+	branch := app.GitBranch
+	if branch == "" && trigger == domain.DeployTriggerBranch {
+		branch = ref
+	}
+
+	var required []string
+	if branch != "" {
+		if required, err = h.github.RequiredStatusChecks(ctx, token, owner, repo, branch); err != nil {
+			return err
+		}
+	}
+
+	for {
+		pending, err := h.pendingChecks(ctx, token, owner, repo, commit, required)
+		if err != nil {
+			return err
+		}
+		if len(pending) == 0 {
+			break
+		}
+		h.logger.Debug("Auto-deploy waiting for checks",
+			zap.String("app_id", app.ID.String()),
+			zap.String("commit", commit),
+			zap.Strings("pending", pending),
+		)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("checks still pending after %s: %s", deployGateTimeout, strings.Join(pending, ", "))
+		case <-time.After(deployGateInterval):
+		}
+	}
+
+	if trigger == domain.DeployTriggerBranch && branch != "" {
+		onBranch, err := h.github.CommitOnBranch(ctx, token, owner, repo, branch, commit)
+		if err != nil {
+			return err
+		}
+		if !onBranch {
+			return fmt.Errorf("commit %s is no longer on %s", shortSHA(commit), branch)
+		}
+	}
+	return nil
+}
+
+// pendingChecks returns the required checks not yet passed on commit, or every pending
+// check when none are required. It fails once any of them has failed.
+func (h *WebhookHandler) pendingChecks(ctx context.Context, token, owner, repo, commit string, required []string) ([]string, error) {
+	checks, err := h.github.CommitChecks(ctx, token, owner, repo, commit)
+	if err != nil {
+		return nil, err
+	}
+	if len(required) == 0 {
+		for name := range checks {
+			required = append(required, name)
+		}
+		sort.Strings(required)
+	}
+
+	var pending []string
+	for _, name := range required {
+		switch checks[name] {
+		case github.CheckSuccess:
+		case github.CheckFailure:
+			return nil, fmt.Errorf("check %q failed", name)
+		default:
+			// Required checks that haven't reported yet are pending too
+			pending = append(pending, name)
+		}
+	}
+	return pending, nil
+}
+
+// shortSHA abbreviates a commit SHA for messages
+func shortSHA(sha string) string {
+	if len(sha) > 8 {
+		return sha[:8]
+	}
+	return sha
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+	"github.com/nanopaas/nanopaas/internal/services/gitprovider"
+)
+
+// Note: WebhookHandler and NewWebhookHandler are defined in webhook_handler.go
+// This file adds per-app push webhooks from git providers other than GitHub
+
+// SetGitProviders sets the providers whose webhooks HandleProviderForApp accepts. Builds
+// clone with the app owner's token for the provider.
+func (h *WebhookHandler) SetGitProviders(providers *gitprovider.Registry) {
+	h.providers = providers
+}
+
+// HandleProviderForApp handles a git provider's push webhooks for a specific app
+func (h *WebhookHandler) HandleProviderForApp(w http.ResponseWriter, r *http.Request) {
+	if h.providers == nil {
+		writeError(w, http.StatusServiceUnavailable, "Git providers are not enabled")
+		return
+	}
+	provider, err := h.providers.Get(chi.URLParam(r, "provider"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Unknown git provider")
+		return
+	}
+	appUUID, err := uuid.Parse(chi.URLParam(r, "appId"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid app ID")
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Failed to read request body")
+		return
+	}
+	app, err := h.appRepo.GetByID(r.Context(), appUUID)
+	if err != nil {
+		app = nil
+	}
+	verified := h.verifyAppDelivery(app, func(secret string) bool {
+		return provider.VerifyWebhook(r.Header, body, secret)
+	})
+	if !verified {
+		h.logger.Warn("Invalid webhook token", zap.String("provider", provider.Name()))
+		writeError(w, http.StatusUnauthorized, "Invalid signature")
+		return
+	}
+
+	event, err := provider.ParsePushEvent(r.Header, body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid payload")
+		return
+	}
+	if event == nil {
+		writeJSON(w, http.StatusOK, map[string]string{"message": "Event processed"})
+		return
+	}
+	if app == nil {
+		writeError(w, http.StatusNotFound, "App not found")
+		return
+	}
+	h.triggerPushBuild(w, r, app, provider.Name(), event)
+}
+
+// authenticatedCloneURL returns the clone URL of a push with the app owner's token for
+// the provider, or "" to clone without one. GitHub builds are authenticated by the
+// builder's clone authenticator instead.
+func (h *WebhookHandler) authenticatedCloneURL(ctx context.Context, app *domain.App, provider, cloneURL string) string {
+	if provider == domain.GitProviderGitHub || h.providers == nil {
+		return ""
+	}
+	p, err := h.providers.Get(provider)
+	if err != nil {
+		return ""
+	}
+
+	token, err := h.providers.Token(ctx, app.OwnerID, provider)
+	if errors.Is(err, gitprovider.ErrNotConnected) {
+		return "" // public repositories clone without credentials
+	}
+	if err == nil {
+		var authURL string
+		if authURL, err = p.AuthenticatedCloneURL(cloneURL, token); err == nil {
+			return authURL
+		}
+	}
+	h.logger.Warn("Failed to authenticate clone, cloning without credentials",
+		zap.String("app_id", app.ID.String()),
+		zap.String("provider", provider),
+		zap.Error(err),
+	)
+	return ""
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+	"github.com/nanopaas/nanopaas/internal/services/github"
+)
+
+// Note: WebhookHandler and NewWebhookHandler are defined in webhook_handler.go
+// This file mirrors webhook-triggered deploys in the GitHub Deployments API
+
+// githubReportTimeout bounds each call reporting a deploy to GitHub
+const githubReportTimeout = 30 * time.Second
+
+// githubDeployment mirrors one webhook-triggered deploy as a GitHub deployment in the
+// app's environment. Reports are made in order on their own goroutines, so builds and
+// webhooks never wait on GitHub, and failures are only logged. A nil githubDeployment
+// reports nothing.
+type githubDeployment struct {
+	h           *WebhookHandler
+	owner, repo string
+	token       string
+	id          int64
+	created     chan struct{} // closed once the deployment exists, or failed to
+}
+
+// SetGitHubDeployments reports webhook-triggered deploys to the repositories they came
+// from. Calls use the GitHub App's installation token, or the app owner's OAuth token
+// when the App is not installed on the repository.
+func (h *WebhookHandler) SetGitHubDeployments(gh *github.Service, users UserLookup) {
+	h.github = gh
+	h.users = users
+}
+
+// startGitHubDeployment creates a GitHub deployment of sha to the app's environment and
+// marks it in progress. It returns nil when reporting is off.
+func (h *WebhookHandler) startGitHubDeployment(app *domain.App, repoFullName, sha string) *githubDeployment {
+	owner, repo, ok := strings.Cut(repoFullName, "/")
+	if h.github == nil || !ok || sha == "" {
+		return nil
+	}
+	d := &githubDeployment{h: h, owner: owner, repo: repo, created: make(chan struct{})}
+
+	go func() {
+		defer close(d.created)
+		ctx, cancel := context.WithTimeout(context.Background(), githubReportTimeout)
+		defer cancel()
+
+		token, err := h.githubToken(ctx, app, owner, repo)
+		if err != nil {
+			h.logger.Debug("Not reporting deploy to GitHub", zap.String("app_id", app.ID.String()), zap.Error(err))
+			return
+		}
+		id, err := h.github.CreateDeployment(ctx, token, owner, repo, sha, app.Slug, "Deploying "+app.Name+" on NanoPaaS")
+		if err != nil {
+			h.logger.Warn("Failed to create GitHub deployment", zap.String("app_id", app.ID.String()), zap.Error(err))
+			return
+		}
+		d.token, d.id = token, id
+		d.report(ctx, github.DeploymentInProgress, "", "Building")
+	}()
+	return d
+}
+
+// deployed reports the outcome of deploying the build
+func (d *githubDeployment) deployed(url string, err error) {
+	switch {
+	case err == nil:
+		d.finish(github.DeploymentSuccess, url, "Deployed")
+	case errors.Is(err, ErrAwaitingApproval):
+		d.finish(github.DeploymentPending, "", "Waiting for approval in NanoPaaS")
+	default:
+		d.finish(github.DeploymentFailure, "", "Deploy failed: "+err.Error())
+	}
+}
+
+// finish reports the final state of the deployment once it has been created
+func (d *githubDeployment) finish(state, environmentURL, description string) {
+	if d == nil {
+		return
+	}
+	go func() {
+		<-d.created
+		if d.id == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), githubReportTimeout)
+		defer cancel()
+		d.report(ctx, state, environmentURL, description)
+	}()
+}
+
+// report sets the deployment's state
+func (d *githubDeployment) report(ctx context.Context, state, environmentURL, description string) {
+	if err := d.h.github.CreateDeploymentStatus(ctx, d.token, d.owner, d.repo, d.id, state, environmentURL, description); err != nil {
+		d.h.logger.Warn("Failed to report deploy to GitHub",
+			zap.String("repo", d.owner+"/"+d.repo),
+			zap.String("state", state),
+			zap.Error(err),
+		)
+	}
+}
+
+// githubToken returns the token a deploy is reported with: the GitHub App's installation
+// token for the repository, or else the app owner's OAuth token
+func (h *WebhookHandler) githubToken(ctx context.Context, app *domain.App, owner, repo string) (string, error) {
+	if h.github.AppConfigured() {
+		token, err := h.github.RepoToken(ctx, owner, repo)
+		if err == nil {
+			return token, nil
+		}
+		if !errors.Is(err, github.ErrNotInstalled) {
+			return "", err
+		}
+	}
+	if h.users == nil {
+		return "", errGitHubNotConnected
+	}
+	appOwner, err := h.users.GetByID(ctx, app.OwnerID)
+	if err != nil {
+		return "", err
+	}
+	if appOwner.GitHubToken == "" {
+		return "", errGitHubNotConnected
+	}
+	return appOwner.GitHubToken, nil
+}
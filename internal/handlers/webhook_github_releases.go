@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+	"github.com/nanopaas/nanopaas/internal/services/gitprovider"
+)
+
+// Note: WebhookHandler and NewWebhookHandler are defined in webhook_handler.go
+// This file adds deploys of published GitHub releases to the existing WebhookHandler
+
+// GitHubReleaseEvent represents a GitHub release webhook payload
+type GitHubReleaseEvent struct {
+	Action  string `json:"action"`
+	Release struct {
+		TagName    string `json:"tag_name"`
+		Name       string `json:"name"`
+		HTMLURL    string `json:"html_url"`
+		Draft      bool   `json:"draft"`
+		Prerelease bool   `json:"prerelease"`
+		Author     struct {
+			Login string `json:"login"`
+		} `json:"author"`
+	} `json:"release"`
+	Repository struct {
+		FullName string `json:"full_name"`
+		CloneURL string `json:"clone_url"`
+	} `json:"repository"`
+}
+
+// handleReleaseForApp builds and deploys the tag of a published release when the app
+// deploys on releases. Other release actions are ignored.
+func (h *WebhookHandler) handleReleaseForApp(w http.ResponseWriter, r *http.Request, app *domain.App, body []byte) {
+	var event GitHubReleaseEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid payload")
+		return
+	}
+	if event.Action != "published" || event.Release.Draft || event.Release.TagName == "" {
+		writeJSON(w, http.StatusOK, map[string]string{"message": "Event processed"})
+		return
+	}
+
+	h.triggerBuild(w, r, app, domain.GitProviderGitHub, domain.DeployTriggerRelease, event.Release.TagName, &gitprovider.PushEvent{
+		Ref:        "refs/tags/" + event.Release.TagName,
+		Repository: event.Repository.FullName,
+		CloneURL:   event.Repository.CloneURL,
+		Pusher:     event.Release.Author.Login,
+		HeadCommit: gitprovider.Commit{Message: event.Release.Name, URL: event.Release.HTMLURL},
+	})
+}
@@ -1,10 +1,12 @@
 package handlers
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"strings"
@@ -14,23 +16,54 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/nanopaas/nanopaas/internal/domain"
-	"github.com/nanopaas/nanopaas/internal/repository/postgres"
 	"github.com/nanopaas/nanopaas/internal/services/builder"
+	"github.com/nanopaas/nanopaas/internal/services/github"
+	"github.com/nanopaas/nanopaas/internal/services/gitprovider"
 )
 
+// BuildDeployer deploys the images of webhook-triggered builds, returning the app's URL
+type BuildDeployer interface {
+	DeployBuild(appID uuid.UUID, build *domain.Build, imageTag string) (string, error)
+}
+
+// WebhookApps looks up the apps webhook events deploy
+type WebhookApps interface {
+	GetByID(ctx context.Context, id uuid.UUID) (*domain.App, error)
+	ListAutoDeployByRepo(ctx context.Context, repoKey string) ([]*domain.App, error)
+}
+
+// BuildRecorder records the builds webhook events start
+type BuildRecorder interface {
+	Create(ctx context.Context, build *domain.Build) error
+}
+
 // WebhookHandler handles GitHub webhook events
 type WebhookHandler struct {
-	appRepo     *postgres.AppRepository
-	buildRepo   *postgres.BuildRepository
+	appRepo     WebhookApps
+	buildRepo   BuildRecorder
 	builder     *builder.Builder
 	webhookSecret string
 	logger      *zap.Logger
+	deployer    BuildDeployer
+
+	// Mirrors deploys in the GitHub Deployments API, set by SetGitHubDeployments
+	github *github.Service
+	users  UserLookup
+
+	// Providers of per-app webhooks other than GitHub, set by SetGitProviders
+	providers *gitprovider.Registry
+
+	// Opens apps' own webhook secrets, set by SetWebhookSecretOpener
+	secretOpener WebhookSecretOpener
+
+	// Records builds and queues them together, set by SetTransactor
+	tx Transactor
 }
 
 // NewWebhookHandler creates a new webhook handler
 func NewWebhookHandler(
-	appRepo *postgres.AppRepository,
-	buildRepo *postgres.BuildRepository,
+	appRepo WebhookApps,
+	buildRepo BuildRecorder,
 	builder *builder.Builder,
 	webhookSecret string,
 	logger *zap.Logger,
@@ -44,6 +77,16 @@ func NewWebhookHandler(
 	}
 }
 
+// SetTransactor sets what makes recording a build and queueing it succeed or fail together
+func (h *WebhookHandler) SetTransactor(tx Transactor) {
+	h.tx = tx
+}
+
+// SetBuildDeployer sets what deploys successful auto-deploy builds
+func (h *WebhookHandler) SetBuildDeployer(deployer BuildDeployer) {
+	h.deployer = deployer
+}
+
 // GitHubPushEvent represents a GitHub push webhook payload
 type GitHubPushEvent struct {
 	Ref        string `json:"ref"`
@@ -68,6 +111,7 @@ type GitHubPushEvent struct {
 	Commits []struct {
 		ID      string `json:"id"`
 		Message string `json:"message"`
+		URL     string `json:"url"`
 		Author  struct {
 			Name  string `json:"name"`
 			Email string `json:"email"`
@@ -76,7 +120,12 @@ type GitHubPushEvent struct {
 	HeadCommit struct {
 		ID      string `json:"id"`
 		Message string `json:"message"`
+		URL     string `json:"url"`
+		Author  struct {
+			Name string `json:"name"`
+		} `json:"author"`
 	} `json:"head_commit"`
+	Compare string `json:"compare"`
 }
 
 // HandleGitHub handles incoming GitHub webhooks
@@ -89,14 +138,18 @@ func (h *WebhookHandler) HandleGitHub(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Verify signature
-	if h.webhookSecret != "" {
-		signature := r.Header.Get("X-Hub-Signature-256")
-		if !h.verifySignature(body, signature) {
-			h.logger.Warn("Invalid webhook signature")
-			writeError(w, http.StatusUnauthorized, "Invalid signature")
-			return
-		}
+	// Verify signature. Deliveries here can build any app tracking the pushed repository,
+	// so without a secret to verify them with they are refused.
+	if h.webhookSecret == "" {
+		h.logger.Warn("Refusing global webhook delivery: GITHUB_WEBHOOK_SECRET is not set")
+		writeError(w, http.StatusUnauthorized, "Webhook deliveries can't be verified: GITHUB_WEBHOOK_SECRET is not set")
+		return
+	}
+	signature := r.Header.Get("X-Hub-Signature-256")
+	if !h.verifySignature(body, signature, h.webhookSecret) {
+		h.logger.Warn("Invalid webhook signature")
+		writeError(w, http.StatusUnauthorized, "Invalid signature")
+		return
 	}
 
 	// Get event type
@@ -110,7 +163,7 @@ func (h *WebhookHandler) HandleGitHub(w http.ResponseWriter, r *http.Request) {
 
 	switch eventType {
 	case "push":
-		h.handlePushEvent(w, body)
+		h.handlePushEvent(w, r, body)
 	case "pull_request":
 		h.handlePullRequestEvent(w, body)
 	case "ping":
@@ -137,16 +190,27 @@ func (h *WebhookHandler) HandleGitHubForApp(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	// Verify signature
-	if h.webhookSecret != "" {
-		signature := r.Header.Get("X-Hub-Signature-256")
-		if !h.verifySignature(body, signature) {
-			writeError(w, http.StatusUnauthorized, "Invalid signature")
-			return
+	// Verify signature, with the app's own secret when it has one
+	app, err := h.appRepo.GetByID(r.Context(), appUUID)
+	if err != nil {
+		app = nil
+	}
+	verified := h.verifyAppDelivery(app, func(secret string) bool {
+		if secret == "" {
+			secret = h.webhookSecret
 		}
+		return secret == "" || h.verifySignature(body, r.Header.Get("X-Hub-Signature-256"), secret)
+	})
+	if !verified {
+		writeError(w, http.StatusUnauthorized, "Invalid signature")
+		return
 	}
 
 	eventType := r.Header.Get("X-GitHub-Event")
+	if (eventType == "push" || eventType == "release") && app == nil {
+		writeError(w, http.StatusNotFound, "App not found")
+		return
+	}
 
 	if eventType == "push" {
 		var event GitHubPushEvent
@@ -155,75 +219,223 @@ func (h *WebhookHandler) HandleGitHubForApp(w http.ResponseWriter, r *http.Reque
 			return
 		}
 
-		// Get app
-		app, err := h.appRepo.GetByID(r.Context(), appUUID)
-		if err != nil || app == nil {
-			writeError(w, http.StatusNotFound, "App not found")
-			return
-		}
+		h.triggerPushBuild(w, r, app, domain.GitProviderGitHub, githubPushEvent(&event))
+		return
+	}
+	if eventType == "release" {
+		h.handleReleaseForApp(w, r, app, body)
+		return
+	}
 
-		// Check if auto-deploy is enabled
-		if !app.AutoDeploy {
-			h.logger.Debug("Auto-deploy disabled for app", zap.String("app_id", appID))
-			writeJSON(w, http.StatusOK, map[string]string{"message": "Auto-deploy disabled"})
-			return
-		}
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Event processed"})
+}
 
-		// Check branch
-		branch := strings.TrimPrefix(event.Ref, "refs/heads/")
-		if app.GitBranch != "" && app.GitBranch != branch {
-			h.logger.Debug("Push to non-tracked branch",
-				zap.String("pushed_branch", branch),
-				zap.String("tracked_branch", app.GitBranch),
-			)
-			writeJSON(w, http.StatusOK, map[string]string{"message": "Branch not tracked"})
-			return
-		}
+// triggerPushBuild builds and deploys the pushed commit when the app auto-deploys the
+// pushed branch or tag
+func (h *WebhookHandler) triggerPushBuild(w http.ResponseWriter, r *http.Request, app *domain.App, provider string, event *gitprovider.PushEvent) {
+	if event.Deleted() {
+		writeJSON(w, http.StatusOK, map[string]string{"message": "Ref deleted"})
+		return
+	}
+	trigger, ref := pushTrigger(event.Ref)
+	h.triggerBuild(w, r, app, provider, trigger, ref, event)
+}
 
-		// Trigger build
-		build := domain.NewBuild(app.ID, domain.BuildSourceGit)
-		build.SourceURL = event.Repository.CloneURL
-		build.GitRef = branch
+// pushTrigger returns the deploy trigger a push of a ref like refs/heads/main fires, and
+// the branch or tag pushed
+func pushTrigger(ref string) (domain.DeployTrigger, string) {
+	if tag, ok := strings.CutPrefix(ref, "refs/tags/"); ok {
+		return domain.DeployTriggerTag, tag
+	}
+	return domain.DeployTriggerBranch, strings.TrimPrefix(ref, "refs/heads/")
+}
 
-		if err := h.buildRepo.Create(r.Context(), build); err != nil {
-			h.logger.Error("Failed to create build", zap.Error(err))
-			writeError(w, http.StatusInternalServerError, "Failed to create build")
-			return
-		}
+// triggerBuild builds and deploys ref, a branch or a tag, when the app auto-deploys on
+// trigger
+func (h *WebhookHandler) triggerBuild(w http.ResponseWriter, r *http.Request, app *domain.App, provider string, trigger domain.DeployTrigger, ref string, event *gitprovider.PushEvent) {
+	build, err := h.autoDeploy(r.Context(), app, provider, trigger, ref, event)
+	var skipped *autoDeploySkipped
+	var pinned *domain.PinnedError
+	switch {
+	case errors.As(err, &skipped):
+		writeJSON(w, http.StatusOK, map[string]string{"message": skipped.reason})
+	case errors.As(err, &pinned):
+		writePinConflict(w, pinned)
+	case errors.Is(err, errWrongProvider):
+		writeError(w, http.StatusBadRequest, "App does not build from "+provider)
+	case errors.Is(err, errBuildQueueFull):
+		writeError(w, http.StatusServiceUnavailable, "Build queue full")
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "Failed to create build")
+	default:
+		writeJSON(w, http.StatusAccepted, map[string]interface{}{
+			"message":  "Build triggered",
+			"build_id": build.ID.String(),
+			"commit":   event.HeadCommit.ID,
+		})
+	}
+}
 
-		// Submit to builder
-		resultChan := make(chan builder.BuildResult, 1)
-		job := &builder.BuildJob{
-			Build:      build,
-			AppSlug:    app.Slug,
-			SourceURL:  event.Repository.CloneURL,
-			ResultChan: resultChan,
-		}
+// recordPushCommits records a push's head commit, commits and compare URL on the build
+// of it
+func recordPushCommits(build *domain.Build, event *gitprovider.PushEvent) {
+	build.GitCommit = event.HeadCommit.ID
+	if build.GitCommit == "" {
+		build.GitCommit = event.After
+	}
+	build.CommitMessage = event.HeadCommit.Message
+	build.CommitAuthor = event.HeadCommit.Author
+	build.CompareURL = event.CompareURL
+	for _, c := range event.Commits {
+		build.Commits = append(build.Commits, domain.Commit{SHA: c.ID, Message: c.Message, Author: c.Author, URL: c.URL})
+	}
+}
 
-		if err := h.builder.SubmitBuild(job); err != nil {
-			h.logger.Error("Failed to submit build", zap.Error(err))
-			writeError(w, http.StatusServiceUnavailable, "Build queue full")
-			return
-		}
+// autoDeploySkipped is returned by autoDeploy when the event doesn't deploy the app
+type autoDeploySkipped struct {
+	reason string
+}
+
+func (e *autoDeploySkipped) Error() string {
+	return e.reason
+}
+
+// Errors returned by autoDeploy
+var (
+	errWrongProvider  = errors.New("app does not build from the provider")
+	errBuildQueueFull = errors.New("build queue full")
+)
+
+// autoDeploy submits a build of ref, a branch or a tag, deployed once it succeeds, when
+// the app auto-deploys on trigger. It returns *autoDeploySkipped when the app doesn't
+// deploy on the event, and *domain.PinnedError when its deployment is pinned.
+func (h *WebhookHandler) autoDeploy(ctx context.Context, app *domain.App, provider string, trigger domain.DeployTrigger, ref string, event *gitprovider.PushEvent) (*domain.Build, error) {
+	appID := app.ID.String()
+	if app.GitProvider != "" && app.GitProvider != provider {
+		return nil, errWrongProvider
+	}
+
+	// Check if auto-deploy is enabled
+	if !app.AutoDeploy {
+		h.logger.Debug("Auto-deploy disabled for app", zap.String("app_id", appID))
+		return nil, &autoDeploySkipped{"Auto-deploy disabled"}
+	}
 
-		h.logger.Info("Auto-deploy triggered",
+	// Pinned deployments are not replaced by auto-deploys
+	var pinned *domain.PinnedError
+	if err := app.CheckAutomaticAction(); errors.As(err, &pinned) {
+		h.logger.Info("Auto-deploy blocked by deployment pin",
 			zap.String("app_id", appID),
-			zap.String("build_id", build.ID.String()),
-			zap.String("commit", event.HeadCommit.ID[:8]),
+			zap.String("reason", pinned.Pin.Reason),
 		)
+		return nil, pinned
+	}
 
-		writeJSON(w, http.StatusAccepted, map[string]interface{}{
-			"message":  "Build triggered",
-			"build_id": build.ID.String(),
-			"commit":   event.HeadCommit.ID,
-		})
-		return
+	if app.EffectiveDeployTrigger() != trigger {
+		h.logger.Debug("Event does not trigger deploys",
+			zap.String("app_id", appID),
+			zap.String("event", string(trigger)),
+			zap.String("deploy_trigger", string(app.EffectiveDeployTrigger())),
+		)
+		return nil, &autoDeploySkipped{"App deploys on " + string(app.EffectiveDeployTrigger()) + " events"}
 	}
 
-	writeJSON(w, http.StatusOK, map[string]string{"message": "Event processed"})
+	// Check branch or tag
+	if trigger == domain.DeployTriggerBranch && app.GitBranch != "" && app.GitBranch != ref {
+		h.logger.Debug("Push to non-tracked branch",
+			zap.String("pushed_branch", ref),
+			zap.String("tracked_branch", app.GitBranch),
+		)
+		return nil, &autoDeploySkipped{"Branch not tracked"}
+	}
+	if trigger != domain.DeployTriggerBranch && !app.MatchesTag(ref) {
+		h.logger.Debug("Tag does not match tag pattern",
+			zap.String("tag", ref),
+			zap.String("tag_pattern", app.TagPattern),
+		)
+		return nil, &autoDeploySkipped{"Tag not matched"}
+	}
+
+	// Trigger build
+	build := domain.NewBuild(app.ID, domain.BuildSourceGit)
+	build.SourceURL = event.CloneURL
+	build.GitRef = ref
+	if trigger != domain.DeployTriggerBranch {
+		build.GitTag = ref
+	}
+	recordPushCommits(build, event)
+
+	// Submit to builder
+	var report *githubDeployment
+	if provider == domain.GitProviderGitHub {
+		deployRef := event.After
+		if deployRef == "" {
+			deployRef = ref // releases name a tag, not a commit
+		}
+		report = h.startGitHubDeployment(app, event.Repository, deployRef)
+	}
+	resultChan := make(chan builder.BuildResult, 1)
+	job := &builder.BuildJob{
+		Build:            build,
+		AppSlug:          app.Slug,
+		SourceURL:        event.CloneURL,
+		AuthenticatedURL: h.authenticatedCloneURL(ctx, app, provider, event.CloneURL),
+		ResultChan:       resultChan,
+		OnFailure: func(err error) {
+			report.finish(github.DeploymentFailure, "", "Build failed")
+		},
+	}
+	if h.deployer != nil {
+		job.OnSuccess = func(imageID, imageTag string) {
+			if err := h.awaitDeployGate(app, trigger, ref, event); err != nil {
+				h.logger.Warn("Auto-deploy held back by required checks",
+					zap.String("app_id", appID),
+					zap.String("build_id", build.ID.String()),
+					zap.Error(err),
+				)
+				report.finish(github.DeploymentFailure, "", "Not deployed: "+err.Error())
+				return
+			}
+			url, err := h.deployer.DeployBuild(app.ID, build, imageTag)
+			report.deployed(url, err)
+		}
+	}
+
+	// Record the build and queue it together, so a full queue leaves no build behind
+	// that never runs
+	var submitErr error
+	err := inTx(ctx, h.tx, func(ctx context.Context) error {
+		if err := h.buildRepo.Create(ctx, build); err != nil {
+			return err
+		}
+		submitErr = h.builder.SubmitBuild(job)
+		return submitErr
+	})
+	if submitErr != nil {
+		h.logger.Error("Failed to submit build", zap.Error(submitErr))
+		report.finish(github.DeploymentError, "", "Build queue full")
+		return nil, errBuildQueueFull
+	}
+	if err != nil {
+		// Including a failed commit after queueing, in which case the build runs unrecorded
+		h.logger.Error("Failed to create build", zap.Error(err))
+		report.finish(github.DeploymentError, "", "Failed to record build")
+		return nil, err
+	}
+
+	h.logger.Info("Auto-deploy triggered",
+		zap.String("app_id", appID),
+		zap.String("provider", provider),
+		zap.String("build_id", build.ID.String()),
+		zap.String("ref", ref),
+		zap.String("commit", shortSHA(event.HeadCommit.ID)),
+	)
+	return build, nil
 }
 
-func (h *WebhookHandler) handlePushEvent(w http.ResponseWriter, body []byte) {
+// handlePushEvent builds and deploys every app that auto-deploys from the pushed
+// repository, so apps don't each need their own webhook
+func (h *WebhookHandler) handlePushEvent(w http.ResponseWriter, r *http.Request, body []byte) {
 	var event GitHubPushEvent
 	if err := json.Unmarshal(body, &event); err != nil {
 		h.logger.Error("Failed to parse push event", zap.Error(err))
@@ -238,15 +450,77 @@ func (h *WebhookHandler) handlePushEvent(w http.ResponseWriter, body []byte) {
 	)
 
 	// Find apps tracking this repository
-	// In production, query database for apps with matching git_repo_url
-	
+	apps, err := h.appRepo.ListAutoDeployByRepo(r.Context(), domain.GitRepoKey(event.Repository.CloneURL))
+	if err != nil {
+		h.logger.Error("Failed to find apps for push", zap.String("repo", event.Repository.FullName), zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "Failed to find apps")
+		return
+	}
+
+	push := githubPushEvent(&event)
+	trigger, ref := pushTrigger(event.Ref)
+
+	builds := make([]PushBuildResult, 0)
+	if !push.Deleted() {
+		for _, app := range apps {
+			// The global secret doesn't pass for apps with their own
+			if len(app.WebhookSecret) > 0 {
+				continue
+			}
+			result := PushBuildResult{AppID: app.ID.String()}
+			build, err := h.autoDeploy(r.Context(), app, domain.GitProviderGitHub, trigger, ref, push)
+			var skipped *autoDeploySkipped
+			switch {
+			case errors.As(err, &skipped):
+				continue // apps tracking other branches or tags
+			case err != nil:
+				result.Error = err.Error()
+			default:
+				result.BuildID = build.ID.String()
+			}
+			builds = append(builds, result)
+		}
+	}
+
 	writeJSON(w, http.StatusOK, map[string]interface{}{
 		"message":    "Push event received",
 		"repository": event.Repository.FullName,
 		"ref":        event.Ref,
+		"builds":     builds,
 	})
 }
 
+// PushBuildResult is the build a push to the global webhook started for an app, or why
+// it couldn't
+type PushBuildResult struct {
+	AppID   string `json:"app_id"`
+	BuildID string `json:"build_id,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// githubPushEvent converts a GitHub push payload
+func githubPushEvent(event *GitHubPushEvent) *gitprovider.PushEvent {
+	push := &gitprovider.PushEvent{
+		Ref:        event.Ref,
+		Before:     event.Before,
+		After:      event.After,
+		Repository: event.Repository.FullName,
+		CloneURL:   event.Repository.CloneURL,
+		Pusher:     event.Pusher.Name,
+		HeadCommit: gitprovider.Commit{
+			ID:      event.HeadCommit.ID,
+			Message: event.HeadCommit.Message,
+			URL:     event.HeadCommit.URL,
+			Author:  event.HeadCommit.Author.Name,
+		},
+		CompareURL: event.Compare,
+	}
+	for _, c := range event.Commits {
+		push.Commits = append(push.Commits, gitprovider.Commit{ID: c.ID, Message: c.Message, URL: c.URL, Author: c.Author.Name})
+	}
+	return push
+}
+
 func (h *WebhookHandler) handlePullRequestEvent(w http.ResponseWriter, body []byte) {
 	var event struct {
 		Action      string `json:"action"`
@@ -318,7 +592,8 @@ func (h *WebhookHandler) handlePingEvent(w http.ResponseWriter, body []byte) {
 	})
 }
 
-func (h *WebhookHandler) verifySignature(payload []byte, signature string) bool {
+// verifySignature checks a GitHub delivery's X-Hub-Signature-256 against secret
+func (h *WebhookHandler) verifySignature(payload []byte, signature, secret string) bool {
 	if signature == "" {
 		return false
 	}
@@ -327,7 +602,7 @@ func (h *WebhookHandler) verifySignature(payload []byte, signature string) bool
 	signature = strings.TrimPrefix(signature, "sha256=")
 
 	// Calculate expected signature
-	mac := hmac.New(sha256.New, []byte(h.webhookSecret))
+	mac := hmac.New(sha256.New, []byte(secret))
 	mac.Write(payload)
 	expectedMAC := hex.EncodeToString(mac.Sum(nil))
 
@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+	"github.com/nanopaas/nanopaas/internal/services/notify"
+	ws "github.com/nanopaas/nanopaas/pkg/websocket"
+)
+
+// Browsers cannot set headers on WebSocket requests. Besides ?token=, clients may offer the
+// access token as a subprotocol, e.g. new WebSocket(url, ["nanopaas", "bearer.<token>"]),
+// which keeps it out of URLs and access logs. The server always selects "nanopaas" so the
+// token is never echoed back.
+const (
+	wsProtocol            = "nanopaas"
+	wsTokenProtocolPrefix = "bearer."
+)
+
+// Reasons a topic is refused
+var (
+	errWSAppNotFound  = errors.New("app not found")
+	errWSAccessDenied = errors.New("access denied")
+)
+
+// TokenAuthenticator resolves the user for an access token
+type TokenAuthenticator interface {
+	GetUserFromToken(ctx context.Context, token string) (*domain.User, error)
+}
+
+// AppFinder looks up apps so streams can be authorized by the app they belong to, with
+// the access rules of the app's read-only REST requests
+type AppFinder interface {
+	FindApp(appID uuid.UUID) (*domain.App, bool)
+	CanReadApp(ctx context.Context, user *domain.User, app *domain.App) bool
+}
+
+// BuildFinder looks up builds so build streams can be authorized by the app they belong to
+type BuildFinder interface {
+	GetBuildStatus(buildID uuid.UUID) (*domain.Build, bool)
+}
+
+// WSAuthenticator authenticates WebSocket upgrades, checks their origin and authorizes the
+// topics they subscribe to
+type WSAuthenticator struct {
+	auth     TokenAuthenticator
+	apps     AppFinder
+	builds   BuildFinder
+	origins  []string
+	upgrader websocket.Upgrader
+	logger   *zap.Logger
+}
+
+// NewWSAuthenticator creates a WebSocket authenticator accepting the given browser origins
+// ("*" allows any). Requests from the API's own host are always accepted.
+func NewWSAuthenticator(auth TokenAuthenticator, allowedOrigins []string, logger *zap.Logger) *WSAuthenticator {
+	a := &WSAuthenticator{
+		auth:    auth,
+		origins: allowedOrigins,
+		logger:  logger,
+	}
+	a.upgrader = websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		Subprotocols:    []string{wsProtocol},
+		CheckOrigin:     a.checkOrigin,
+	}
+	return a
+}
+
+// SetAppFinder sets the app lookup used to authorize app streams
+func (a *WSAuthenticator) SetAppFinder(apps AppFinder) {
+	a.apps = apps
+}
+
+// SetBuildFinder sets the build lookup used to authorize build streams
+func (a *WSAuthenticator) SetBuildFinder(builds BuildFinder) {
+	a.builds = builds
+}
+
+// Authenticate returns the user a WebSocket request's access token belongs to, writing
+// 401 when the token is missing or invalid
+func (a *WSAuthenticator) Authenticate(w http.ResponseWriter, r *http.Request) (*domain.User, bool) {
+	token := wsToken(r)
+	if token == "" {
+		writeError(w, http.StatusUnauthorized, "Missing access token")
+		return nil, false
+	}
+
+	user, err := a.auth.GetUserFromToken(r.Context(), token)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "Invalid or expired token")
+		return nil, false
+	}
+	return user, true
+}
+
+// AuthorizeApp authenticates the request and checks the user may access the app
+func (a *WSAuthenticator) AuthorizeApp(w http.ResponseWriter, r *http.Request, appID string) (*domain.User, bool) {
+	id, err := uuid.Parse(appID)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid app ID")
+		return nil, false
+	}
+	user, ok := a.Authenticate(w, r)
+	if !ok {
+		return nil, false
+	}
+	if !a.canAccessApp(r.Context(), w, user, id) {
+		return nil, false
+	}
+	return user, true
+}
+
+// AuthorizeBuild authenticates the request and checks the user may access the app the
+// build belongs to
+func (a *WSAuthenticator) AuthorizeBuild(w http.ResponseWriter, r *http.Request, buildID string) (*domain.User, bool) {
+	id, err := uuid.Parse(buildID)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid build ID")
+		return nil, false
+	}
+	user, ok := a.Authenticate(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	var build *domain.Build
+	if a.builds != nil {
+		build, _ = a.builds.GetBuildStatus(id)
+	}
+	if build == nil {
+		writeError(w, http.StatusNotFound, "Build not found")
+		return nil, false
+	}
+	if !a.canAccessApp(r.Context(), w, user, build.AppID) {
+		return nil, false
+	}
+	return user, true
+}
+
+// AuthorizeContainer authenticates the request and checks the user may access the app
+// owning a container, identified by its labels. Containers outside NanoPaaS are only
+// streamed to admins.
+func (a *WSAuthenticator) AuthorizeContainer(w http.ResponseWriter, r *http.Request, labels map[string]string) (*domain.User, bool) {
+	user, ok := a.Authenticate(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	appID, err := uuid.Parse(labels["nanopaas.app.id"])
+	if err != nil {
+		if !user.IsAdmin() {
+			writeError(w, http.StatusForbidden, "Access denied")
+			return nil, false
+		}
+		return user, true
+	}
+	if !a.canAccessApp(r.Context(), w, user, appID) {
+		return nil, false
+	}
+	return user, true
+}
+
+// Upgrade upgrades an authorized request to a WebSocket connection
+func (a *WSAuthenticator) Upgrade(w http.ResponseWriter, r *http.Request) (*websocket.Conn, error) {
+	return a.upgrader.Upgrade(w, r, nil)
+}
+
+// canAccessApp checks the user may read the app, writing 404 or 403 when not
+func (a *WSAuthenticator) canAccessApp(ctx context.Context, w http.ResponseWriter, user *domain.User, appID uuid.UUID) bool {
+	switch err := a.authorizeApp(ctx, user, appID); {
+	case errors.Is(err, errWSAppNotFound):
+		writeError(w, http.StatusNotFound, "App not found")
+		return false
+	case err != nil:
+		writeError(w, http.StatusForbidden, "Access denied")
+		return false
+	}
+	return true
+}
+
+// authorizeApp checks the user may read the app: manage it or collaborate on it
+func (a *WSAuthenticator) authorizeApp(ctx context.Context, user *domain.User, appID uuid.UUID) error {
+	var app *domain.App
+	if a.apps != nil {
+		app, _ = a.apps.FindApp(appID)
+	}
+	if app == nil {
+		return errWSAppNotFound
+	}
+	if !a.apps.CanReadApp(ctx, user, app) {
+		a.logger.Warn("WebSocket access denied",
+			zap.String("user_id", user.ID.String()),
+			zap.String("app_id", appID.String()),
+		)
+		return errWSAccessDenied
+	}
+	return nil
+}
+
+// TopicAuthorizer returns the check for topics a user subscribes to over an open
+// connection. Topics are "build:<buildId>", "pull:<appId>", "app:<appId>[:<stream>]"
+// and "user:<userId>:notifications", the latter only for the user's own ID.
+func (a *WSAuthenticator) TopicAuthorizer(user *domain.User) ws.TopicAuthorizer {
+	return func(topic string) error {
+		kind, rest, _ := strings.Cut(topic, ":")
+		id, _, _ := strings.Cut(rest, ":")
+		parsed, err := uuid.Parse(id)
+		if err != nil {
+			return fmt.Errorf("unknown topic %q", topic)
+		}
+
+		switch kind {
+		case "app", "pull":
+			return a.authorizeApp(context.Background(), user, parsed)
+		case "build":
+			var build *domain.Build
+			if a.builds != nil {
+				build, _ = a.builds.GetBuildStatus(parsed)
+			}
+			if build == nil {
+				return errors.New("build not found")
+			}
+			return a.authorizeApp(context.Background(), user, build.AppID)
+		case "user":
+			if topic != notify.UserTopic(user.ID) {
+				return errWSAccessDenied
+			}
+			return nil
+		}
+		return fmt.Errorf("unknown topic %q", topic)
+	}
+}
+
+// checkOrigin accepts requests without an Origin header (non-browser clients), from the
+// API's own host, or from an allowed origin
+func (a *WSAuthenticator) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, allowed := range a.origins {
+		if allowed == "*" || strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+	a.logger.Debug("WebSocket origin rejected", zap.String("origin", origin))
+	return false
+}
+
+// wsToken reads the access token from a bearer subprotocol, the Authorization header,
+// the token query param or a cookie session. Cookies are safe here because upgrades are
+// checked against the allowed origins.
+func wsToken(r *http.Request) string {
+	for _, protocol := range websocket.Subprotocols(r) {
+		if strings.HasPrefix(protocol, wsTokenProtocolPrefix) {
+			return strings.TrimPrefix(protocol, wsTokenProtocolPrefix)
+		}
+	}
+	if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		return strings.TrimPrefix(header, "Bearer ")
+	}
+	if token := r.URL.Query().Get("token"); token != "" {
+		return token
+	}
+	return cookieToken(r, accessTokenCookie)
+}
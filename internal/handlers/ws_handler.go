@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"net/http"
+
+	"go.uber.org/zap"
+
+	ws "github.com/nanopaas/nanopaas/pkg/websocket"
+)
+
+// WSHandler serves the multiplexed WebSocket, where one connection subscribes to any
+// topics its user may read
+type WSHandler struct {
+	wsHub  *ws.Hub
+	wsAuth *WSAuthenticator
+	logger *zap.Logger
+}
+
+// NewWSHandler creates a new multiplexed WebSocket handler
+func NewWSHandler(wsHub *ws.Hub, wsAuth *WSAuthenticator, logger *zap.Logger) *WSHandler {
+	return &WSHandler{
+		wsHub:  wsHub,
+		wsAuth: wsAuth,
+		logger: logger,
+	}
+}
+
+// Connect opens a connection without topics; the client picks them with
+// {"action": "subscribe", "topic": ...} messages
+func (h *WSHandler) Connect(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.wsAuth.Authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	conn, err := h.wsAuth.Upgrade(w, r)
+	if err != nil {
+		h.logger.Error("WebSocket upgrade failed", zap.Error(err))
+		return
+	}
+
+	client := ws.NewClient(h.wsHub, conn)
+	client.SetAuthorizer(h.wsAuth.TopicAuthorizer(user))
+	h.wsHub.Register(client)
+
+	h.logger.Debug("Client connected to multiplexed stream",
+		zap.String("user_id", user.ID.String()),
+		zap.String("client_id", client.ID.String()),
+	)
+
+	go client.WritePump()
+	go client.ReadPump()
+}
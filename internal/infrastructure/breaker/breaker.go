@@ -0,0 +1,168 @@
+// Package breaker tracks whether a dependency such as PostgreSQL or Redis is reachable,
+// so requests that need it can be refused quickly while it is down instead of each one
+// waiting on timeouts and failing with an opaque error.
+package breaker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// probeTimeout bounds each probe of the dependency
+const probeTimeout = 5 * time.Second
+
+// failureThreshold is how many probes in a row must fail before the breaker opens, so a
+// single slow probe doesn't shed load
+const failureThreshold = 2
+
+// State is whether requests needing the dependency are let through
+type State string
+
+// Breaker states
+const (
+	Closed State = "closed" // the dependency is up; requests go through
+	Open   State = "open"   // the dependency is down; requests are refused
+)
+
+// Status describes a breaker's state and the probes behind it
+type Status struct {
+	Name      string    `json:"name"`
+	State     State     `json:"state"`
+	Since     time.Time `json:"since"` // when the breaker last opened or closed
+	CheckedAt time.Time `json:"checked_at"`
+	Failures  int       `json:"consecutive_failures"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// Breaker probes a dependency in the background. It opens after failureThreshold probes
+// in a row fail and closes on the first probe that succeeds; while open, the dependency
+// is probed every interval just the same, so recovery is noticed within one interval.
+type Breaker struct {
+	name     string
+	probe    func(ctx context.Context) error
+	interval time.Duration
+	logger   *zap.Logger
+
+	mu     sync.RWMutex
+	status Status
+
+	kick chan struct{}
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New creates a closed breaker for a dependency, probed with probe every interval
+func New(name string, probe func(ctx context.Context) error, interval time.Duration, logger *zap.Logger) *Breaker {
+	now := time.Now().UTC()
+	return &Breaker{
+		name:     name,
+		probe:    probe,
+		interval: interval,
+		logger:   logger,
+		status:   Status{Name: name, State: Closed, Since: now, CheckedAt: now},
+		kick:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start probes the dependency every interval
+func (b *Breaker) Start() {
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		ticker := time.NewTicker(b.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-b.kick:
+			case <-b.stop:
+				return
+			}
+			b.Check(context.Background())
+		}
+	}()
+}
+
+// Stop stops the background probes
+func (b *Breaker) Stop() {
+	close(b.stop)
+	b.wg.Wait()
+}
+
+// Name returns the name of the dependency
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+// Interval returns how often the dependency is probed
+func (b *Breaker) Interval() time.Duration {
+	return b.interval
+}
+
+// Allow reports whether requests needing the dependency should go through
+func (b *Breaker) Allow() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.status.State == Closed
+}
+
+// Status returns the breaker's state and the result of the last probe
+func (b *Breaker) Status() Status {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.status
+}
+
+// CheckSoon asks for a probe ahead of the next interval, e.g. after a request failed.
+// Requests made while one is already pending are merged.
+func (b *Breaker) CheckSoon() {
+	select {
+	case b.kick <- struct{}{}:
+	default:
+	}
+}
+
+// Check probes the dependency and records the result, opening or closing the breaker
+func (b *Breaker) Check(ctx context.Context) bool {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	err := b.probe(ctx)
+
+	now := time.Now().UTC()
+	b.mu.Lock()
+	was := b.status.State
+	b.status.CheckedAt = now
+	b.status.LastError = ""
+	if err != nil {
+		b.status.Failures++
+		b.status.LastError = err.Error()
+		if b.status.Failures >= failureThreshold {
+			b.status.State = Open
+		}
+	} else {
+		b.status.Failures = 0
+		b.status.State = Closed
+	}
+	if b.status.State != was {
+		b.status.Since = now
+	}
+	state := b.status.State
+	b.mu.Unlock()
+
+	switch {
+	case was == Closed && state == Open:
+		b.logger.Error("Dependency unreachable, shedding requests that need it",
+			zap.String("dependency", b.name),
+			zap.Error(err),
+		)
+	case was == Open && state == Closed:
+		b.logger.Info("Dependency reachable again, serving requests", zap.String("dependency", b.name))
+	case err != nil && state == Closed:
+		b.logger.Warn("Dependency probe failed", zap.String("dependency", b.name), zap.Error(err))
+	}
+	return err == nil
+}
@@ -0,0 +1,149 @@
+package docker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// pingTimeout bounds each availability probe
+const pingTimeout = 5 * time.Second
+
+// AvailabilityStatus describes whether the daemon is reachable and since when
+type AvailabilityStatus struct {
+	Available bool      `json:"available"`
+	Since     time.Time `json:"since"` // when the daemon last went down or came back
+	CheckedAt time.Time `json:"checked_at"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// Availability pings the daemon in the background so requests can be refused quickly
+// while it is down, and runs recovery hooks when it comes back
+type Availability struct {
+	runtime  ContainerRuntime
+	interval time.Duration
+	logger   *zap.Logger
+
+	mu     sync.RWMutex
+	status AvailabilityStatus
+
+	onRecovered func()
+	kick        chan struct{}
+	stop        chan struct{}
+	wg          sync.WaitGroup
+}
+
+// NewAvailability creates a daemon availability monitor. It assumes the daemon is
+// reachable until a probe says otherwise.
+func NewAvailability(runtime ContainerRuntime, interval time.Duration, logger *zap.Logger) *Availability {
+	now := time.Now().UTC()
+	return &Availability{
+		runtime:  runtime,
+		interval: interval,
+		logger:   logger,
+		status:   AvailabilityStatus{Available: true, Since: now, CheckedAt: now},
+		kick:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+	}
+}
+
+// SetRecoveredHandler sets a function run each time the daemon comes back after an
+// outage. Call before Start.
+func (a *Availability) SetRecoveredHandler(fn func()) {
+	a.onRecovered = fn
+}
+
+// Start probes the daemon immediately and then every interval
+func (a *Availability) Start() {
+	a.Check(context.Background())
+
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		ticker := time.NewTicker(a.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-a.kick:
+			case <-a.stop:
+				return
+			}
+			a.Check(context.Background())
+		}
+	}()
+}
+
+// Stop stops the background probes
+func (a *Availability) Stop() {
+	close(a.stop)
+	a.wg.Wait()
+}
+
+// Available reports whether the daemon answered the last probe
+func (a *Availability) Available() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.status.Available
+}
+
+// Interval returns how often the daemon is probed
+func (a *Availability) Interval() time.Duration {
+	return a.interval
+}
+
+// Status returns the result of the last probe
+func (a *Availability) Status() AvailabilityStatus {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.status
+}
+
+// CheckSoon asks for a probe ahead of the next interval, e.g. after a request failed.
+// Requests made while one is already pending are merged.
+func (a *Availability) CheckSoon() {
+	select {
+	case a.kick <- struct{}{}:
+	default:
+	}
+}
+
+// Check pings the daemon and records the result, running the recovery hook when the
+// daemon is back after an outage
+func (a *Availability) Check(ctx context.Context) bool {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+	err := a.runtime.Ping(ctx)
+
+	now := time.Now().UTC()
+	a.mu.Lock()
+	was := a.status.Available
+	a.status.CheckedAt = now
+	a.status.Available = err == nil
+	a.status.LastError = ""
+	if err != nil {
+		a.status.LastError = err.Error()
+	}
+	if was != a.status.Available {
+		a.status.Since = now
+	}
+	a.mu.Unlock()
+
+	switch {
+	case was && err != nil:
+		a.logger.Error("Container runtime unreachable, container operations disabled",
+			zap.String("runtime", a.runtime.Name()),
+			zap.Error(err),
+		)
+	case !was && err == nil:
+		a.logger.Info("Container runtime reachable again, container operations enabled",
+			zap.String("runtime", a.runtime.Name()),
+		)
+		if a.onRecovered != nil {
+			go a.onRecovered()
+		}
+	}
+	return err == nil
+}
@@ -0,0 +1,127 @@
+package docker
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/pkg/jsonmessage"
+)
+
+// BuildLogLine is one complete line of build output
+type BuildLogLine struct {
+	Step        int    `json:"step,omitempty"` // Dockerfile step the line belongs to, 0 before the first
+	TotalSteps  int    `json:"total_steps,omitempty"`
+	Instruction string `json:"instruction,omitempty"` // e.g. "RUN npm ci"
+	Content     string `json:"content"`
+	Error       bool   `json:"error,omitempty"` // the build failed with this message
+}
+
+// BuildLogFunc receives build output a line at a time
+type BuildLogFunc func(BuildLogLine)
+
+var (
+	// stepPattern matches the classic builder's step headers, e.g. "Step 3/7 : RUN npm ci"
+	stepPattern = regexp.MustCompile(`^Step (\d+)/(\d+) : (.*)$`)
+
+	// ansiPattern matches terminal escape sequences; sgrPattern the color and style ones
+	ansiPattern = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]|\x1b[@-Z\\-_]`)
+	sgrPattern  = regexp.MustCompile(`^\x1b\[[0-9;]*m$`)
+)
+
+// buildLineWriter turns Docker's build message stream into complete lines. The "stream"
+// field arrives in arbitrary chunks, so text is buffered until a newline.
+type buildLineWriter struct {
+	fn        BuildLogFunc
+	stripANSI bool
+
+	buf         strings.Builder
+	step        int
+	totalSteps  int
+	instruction string
+}
+
+func newBuildLineWriter(fn BuildLogFunc, stripANSI bool) *buildLineWriter {
+	return &buildLineWriter{fn: fn, stripANSI: stripANSI}
+}
+
+// message handles one decoded build message
+func (w *buildLineWriter) message(msg *jsonmessage.JSONMessage) {
+	switch {
+	case msg.Error != nil:
+		w.flush()
+		w.emit(msg.Error.Message, true)
+	case msg.Stream != "":
+		w.write(msg.Stream)
+	case msg.Status != "" && msg.Progress == nil:
+		// Progress bars are left out; the status changes around them are kept
+		w.flush()
+		if msg.ID != "" {
+			w.emit(msg.ID+": "+msg.Status, false)
+		} else {
+			w.emit(msg.Status, false)
+		}
+	}
+}
+
+// write buffers stream text and emits each completed line
+func (w *buildLineWriter) write(text string) {
+	for {
+		i := strings.IndexByte(text, '\n')
+		if i < 0 {
+			w.buf.WriteString(text)
+			return
+		}
+		w.buf.WriteString(text[:i])
+		w.flush()
+		text = text[i+1:]
+	}
+}
+
+// flush emits the buffered partial line, if any
+func (w *buildLineWriter) flush() {
+	if w.buf.Len() == 0 {
+		return
+	}
+	line := w.buf.String()
+	w.buf.Reset()
+	w.emit(line, false)
+}
+
+// emit normalizes a line, tracks the current step and passes the line on
+func (w *buildLineWriter) emit(line string, isError bool) {
+	line = w.normalize(line)
+	if strings.TrimSpace(line) == "" && !isError {
+		return
+	}
+
+	if m := stepPattern.FindStringSubmatch(line); m != nil {
+		w.step, _ = strconv.Atoi(m[1])
+		w.totalSteps, _ = strconv.Atoi(m[2])
+		w.instruction = m[3]
+	}
+	if w.fn != nil {
+		w.fn(BuildLogLine{
+			Step:        w.step,
+			TotalSteps:  w.totalSteps,
+			Instruction: w.instruction,
+			Content:     line,
+			Error:       isError,
+		})
+	}
+}
+
+// normalize keeps what a terminal would finally show on the line: text after the last
+// carriage return, without cursor movement. Colors are kept unless stripping is enabled.
+func (w *buildLineWriter) normalize(line string) string {
+	line = strings.TrimRight(line, "\r")
+	if i := strings.LastIndexByte(line, '\r'); i >= 0 {
+		line = line[i+1:]
+	}
+	return ansiPattern.ReplaceAllStringFunc(line, func(seq string) string {
+		if !w.stripANSI && sgrPattern.MatchString(seq) {
+			return seq
+		}
+		return ""
+	})
+}
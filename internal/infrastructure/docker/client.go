@@ -2,6 +2,7 @@ package docker
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"runtime"
@@ -11,10 +12,13 @@ import (
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/jsonmessage"
 	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/docker/go-connections/nat"
+	"github.com/docker/go-units"
 	"go.uber.org/zap"
 )
 
@@ -24,6 +28,7 @@ type Client struct {
 	logger          *zap.Logger
 	containerPrefix string
 	defaultNetwork  string
+	tls             TLSOptions
 	mu              sync.RWMutex
 }
 
@@ -48,12 +53,16 @@ type BuildOptions struct {
 	BuildArgs      map[string]*string
 	NoCache        bool
 	Pull           bool
+	Labels         map[string]string // added to the default built-by/built-at labels
+	Progress       PullProgressFunc  // receives base image pull progress instead of the log callback
+	StripANSI      bool              // drop color codes from build output; cursor movement is always dropped
 }
 
 // ContainerOptions holds options for creating a container
 type ContainerOptions struct {
 	Name         string
 	Image        string
+	Cmd          []string // overrides the image command when set
 	Env          []string
 	Labels       map[string]string
 	ExposedPorts []string
@@ -64,10 +73,18 @@ type ContainerOptions struct {
 	User         string
 	ReadOnly     bool
 	Privileged   bool
+	CapAdd       []string          // capabilities added on top of NET_BIND_SERVICE
+	NoFileLimit  int64             // nofile ulimit (soft and hard), 0 keeps the daemon default
+	Tmpfs        map[string]string // mount path -> tmpfs options
+	ShmSize      int64             // /dev/shm size in bytes, 0 keeps the daemon default
+	Sysctls      map[string]string
+	Binds        []string // named volumes as name:/path[:ro]
+	Aliases      []string // extra DNS names on the default network
 }
 
 // NewClient creates a new Docker client wrapper
-func NewClient(host, apiVersion, containerPrefix, defaultNetwork string, logger *zap.Logger) (*Client, error) {
+// Remote tcp:// daemons should be secured with tlsOpts (same layout as DOCKER_CERT_PATH)
+func NewClient(host, apiVersion, containerPrefix, defaultNetwork string, tlsOpts TLSOptions, logger *zap.Logger) (*Client, error) {
 	opts := []client.Opt{
 		client.WithAPIVersionNegotiation(),
 	}
@@ -87,6 +104,19 @@ func NewClient(host, apiVersion, containerPrefix, defaultNetwork string, logger
 		opts = append(opts, client.WithVersion(apiVersion))
 	}
 
+	if tlsOpts.Enabled() {
+		tlsClientOptions, err := tlsClientOpts(tlsOpts)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, tlsClientOptions...)
+		if !tlsOpts.Verify {
+			logger.Warn("Docker TLS is enabled without server verification; set DOCKER_TLS_VERIFY=true")
+		}
+	} else if isRemoteHost(host) {
+		logger.Warn("Connecting to a remote Docker daemon without TLS", zap.String("host", host))
+	}
+
 	cli, err := client.NewClientWithOpts(opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create docker client: %w", err)
@@ -97,9 +127,15 @@ func NewClient(host, apiVersion, containerPrefix, defaultNetwork string, logger
 		logger:          logger,
 		containerPrefix: containerPrefix,
 		defaultNetwork:  defaultNetwork,
+		tls:             tlsOpts,
 	}, nil
 }
 
+// Name returns the runtime identifier
+func (c *Client) Name() string {
+	return RuntimeDocker
+}
+
 // Ping checks if the Docker daemon is responsive
 func (c *Client) Ping(ctx context.Context) error {
 	_, err := c.cli.Ping(ctx)
@@ -204,6 +240,7 @@ func (c *Client) CreateContainer(ctx context.Context, opts ContainerOptions) (st
 	// Container configuration
 	config := &container.Config{
 		Image:        opts.Image,
+		Cmd:          opts.Cmd,
 		Env:          opts.Env,
 		Labels:       opts.Labels,
 		ExposedPorts: exposedPorts,
@@ -213,6 +250,8 @@ func (c *Client) CreateContainer(ctx context.Context, opts ContainerOptions) (st
 	// Restart policy
 	restartPolicy := container.RestartPolicy{}
 	switch opts.RestartPolicy {
+	case "no":
+		restartPolicy = container.RestartPolicy{Name: "no"}
 	case "always":
 		restartPolicy = container.RestartPolicy{Name: "always"}
 	case "on-failure":
@@ -235,7 +274,16 @@ func (c *Client) CreateContainer(ctx context.Context, opts ContainerOptions) (st
 		Privileged:     opts.Privileged,
 		SecurityOpt:    []string{"no-new-privileges:true"},
 		CapDrop:        []string{"ALL"},
-		CapAdd:         []string{"NET_BIND_SERVICE"},
+		CapAdd:         append([]string{"NET_BIND_SERVICE"}, opts.CapAdd...),
+		Tmpfs:          opts.Tmpfs,
+		ShmSize:        opts.ShmSize,
+		Sysctls:        opts.Sysctls,
+		Binds:          opts.Binds,
+	}
+	if opts.NoFileLimit > 0 {
+		hostConfig.Resources.Ulimits = []*units.Ulimit{
+			{Name: "nofile", Soft: opts.NoFileLimit, Hard: opts.NoFileLimit},
+		}
 	}
 
 	// Network configuration
@@ -244,7 +292,7 @@ func (c *Client) CreateContainer(ctx context.Context, opts ContainerOptions) (st
 		hostConfig.NetworkMode = container.NetworkMode(opts.NetworkMode)
 	} else if c.defaultNetwork != "" {
 		networkConfig.EndpointsConfig = map[string]*network.EndpointSettings{
-			c.defaultNetwork: {},
+			c.defaultNetwork: {Aliases: opts.Aliases},
 		}
 	}
 
@@ -322,19 +370,52 @@ func (c *Client) InspectContainer(ctx context.Context, containerID string) (type
 	return info, nil
 }
 
+// ContainerIP returns a container's IP address, preferring the default network
+func (c *Client) ContainerIP(ctx context.Context, containerID string) (string, error) {
+	info, err := c.InspectContainer(ctx, containerID)
+	if err != nil {
+		return "", err
+	}
+	if info.NetworkSettings == nil {
+		return "", fmt.Errorf("container %s has no network settings", ShortID(containerID))
+	}
+
+	if c.defaultNetwork != "" {
+		if endpoint, ok := info.NetworkSettings.Networks[c.defaultNetwork]; ok && endpoint.IPAddress != "" {
+			return endpoint.IPAddress, nil
+		}
+	}
+	for _, endpoint := range info.NetworkSettings.Networks {
+		if endpoint.IPAddress != "" {
+			return endpoint.IPAddress, nil
+		}
+	}
+
+	return "", fmt.Errorf("container %s has no IP address", ShortID(containerID))
+}
+
 // GetContainerLogs streams container logs
 func (c *Client) GetContainerLogs(ctx context.Context, containerID string, follow bool, tail string) (io.ReadCloser, error) {
+	return c.containerLogs(ctx, containerID, LogOptions{Follow: follow, Tail: tail})
+}
+
+// containerLogs opens the raw multiplexed log stream with timestamps
+func (c *Client) containerLogs(ctx context.Context, containerID string, opts LogOptions) (io.ReadCloser, error) {
 	options := container.LogsOptions{
 		ShowStdout: true,
 		ShowStderr: true,
-		Follow:     follow,
+		Follow:     opts.Follow,
 		Timestamps: true,
-		Tail:       tail,
+		Tail:       opts.Tail,
+	}
+	if !opts.Since.IsZero() {
+		// Docker accepts fractional UNIX timestamps, which keeps sub-second resume points exact
+		options.Since = fmt.Sprintf("%d.%09d", opts.Since.Unix(), opts.Since.Nanosecond())
 	}
 
 	logs, err := c.cli.ContainerLogs(ctx, containerID, options)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get logs for container %s: %w", containerID[:12], err)
+		return nil, fmt.Errorf("failed to get logs for container %s: %w", ShortID(containerID), err)
 	}
 	return logs, nil
 }
@@ -351,6 +432,74 @@ func (c *Client) StreamContainerLogs(ctx context.Context, containerID string, st
 	return err
 }
 
+// AttachAndStart attaches to a created container, starts it and copies its demultiplexed
+// stdout and stderr to the given writers until the container exits or ctx is cancelled
+func (c *Client) AttachAndStart(ctx context.Context, containerID string, stdout, stderr io.Writer) error {
+	resp, err := c.cli.ContainerAttach(ctx, containerID, container.AttachOptions{
+		Stream: true,
+		Stdout: true,
+		Stderr: true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to attach to container %s: %w", ShortID(containerID), err)
+	}
+	defer resp.Close()
+
+	if err := c.StartContainer(ctx, containerID); err != nil {
+		return err
+	}
+
+	// Closing the hijacked connection unblocks StdCopy when ctx is cancelled
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			resp.Close()
+		case <-done:
+		}
+	}()
+
+	_, err = stdcopy.StdCopy(stdout, stderr, resp.Reader)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// CopyToContainer extracts a tar archive into a directory inside a container
+func (c *Client) CopyToContainer(ctx context.Context, containerID, dstDir string, content io.Reader) error {
+	err := c.cli.CopyToContainer(ctx, containerID, dstDir, content, types.CopyToContainerOptions{
+		AllowOverwriteDirWithFile: false,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to copy to container %s: %w", ShortID(containerID), err)
+	}
+	c.logger.Info("Copied files to container",
+		zap.String("id", ShortID(containerID)),
+		zap.String("path", dstDir),
+	)
+	return nil
+}
+
+// CopyFromContainer returns a tar archive of a path inside a container along with its stat info
+func (c *Client) CopyFromContainer(ctx context.Context, containerID, srcPath string) (io.ReadCloser, types.ContainerPathStat, error) {
+	reader, stat, err := c.cli.CopyFromContainer(ctx, containerID, srcPath)
+	if err != nil {
+		return nil, types.ContainerPathStat{}, fmt.Errorf("failed to copy from container %s: %w", ShortID(containerID), err)
+	}
+	return reader, stat, nil
+}
+
+// StatContainerPath returns stat info for a path inside a container
+func (c *Client) StatContainerPath(ctx context.Context, containerID, path string) (types.ContainerPathStat, error) {
+	stat, err := c.cli.ContainerStatPath(ctx, containerID, path)
+	if err != nil {
+		return types.ContainerPathStat{}, fmt.Errorf("failed to stat %s in container %s: %w", path, ShortID(containerID), err)
+	}
+	return stat, nil
+}
+
 // BuildImage builds a Docker image from a build context
 func (c *Client) BuildImage(ctx context.Context, buildContext io.Reader, opts BuildOptions) (string, error) {
 	buildOptions := types.ImageBuildOptions{
@@ -360,10 +509,7 @@ func (c *Client) BuildImage(ctx context.Context, buildContext io.Reader, opts Bu
 		NoCache:    opts.NoCache,
 		PullParent: opts.Pull,
 		Remove:     true,
-		Labels: map[string]string{
-			"built-by": "nanopaas",
-			"built-at": time.Now().UTC().Format(time.RFC3339),
-		},
+		Labels:     buildLabels(opts.Labels),
 	}
 
 	resp, err := c.cli.ImageBuild(ctx, buildContext, buildOptions)
@@ -385,8 +531,8 @@ func (c *Client) BuildImage(ctx context.Context, buildContext io.Reader, opts Bu
 	return "", nil
 }
 
-// BuildImageWithLogs builds an image and streams logs via a callback
-func (c *Client) BuildImageWithLogs(ctx context.Context, buildContext io.Reader, opts BuildOptions, logCallback func(string)) (string, error) {
+// BuildImageWithLogs builds an image and streams its output a line at a time via a callback
+func (c *Client) BuildImageWithLogs(ctx context.Context, buildContext io.Reader, opts BuildOptions, logCallback BuildLogFunc) (string, error) {
 	buildOptions := types.ImageBuildOptions{
 		Tags:       opts.Tags,
 		Dockerfile: opts.DockerfilePath,
@@ -394,10 +540,7 @@ func (c *Client) BuildImageWithLogs(ctx context.Context, buildContext io.Reader,
 		NoCache:    opts.NoCache,
 		PullParent: opts.Pull,
 		Remove:     true,
-		Labels: map[string]string{
-			"built-by": "nanopaas",
-			"built-at": time.Now().UTC().Format(time.RFC3339),
-		},
+		Labels:     buildLabels(opts.Labels),
 	}
 
 	resp, err := c.cli.ImageBuild(ctx, buildContext, buildOptions)
@@ -406,19 +549,8 @@ func (c *Client) BuildImageWithLogs(ctx context.Context, buildContext io.Reader,
 	}
 	defer resp.Body.Close()
 
-	// Stream build output line by line
-	buf := make([]byte, 4096)
-	for {
-		n, readErr := resp.Body.Read(buf)
-		if n > 0 && logCallback != nil {
-			logCallback(string(buf[:n]))
-		}
-		if readErr == io.EOF {
-			break
-		}
-		if readErr != nil {
-			return "", fmt.Errorf("error reading build output: %w", readErr)
-		}
+	if err := forwardBuildOutput(resp.Body, newBuildLineWriter(logCallback, opts.StripANSI), opts.Progress); err != nil {
+		return "", err
 	}
 
 	if len(opts.Tags) > 0 {
@@ -428,22 +560,65 @@ func (c *Client) BuildImageWithLogs(ctx context.Context, buildContext io.Reader,
 	return "", nil
 }
 
+// buildLabels merges caller labels with the labels every NanoPaaS image carries
+func buildLabels(extra map[string]string) map[string]string {
+	labels := make(map[string]string, len(extra)+2)
+	for k, v := range extra {
+		labels[k] = v
+	}
+	labels["built-by"] = "nanopaas"
+	labels["built-at"] = time.Now().UTC().Format(time.RFC3339)
+	return labels
+}
+
 // PullImage pulls an image from a registry
 func (c *Client) PullImage(ctx context.Context, imageName string) error {
-	reader, err := c.cli.ImagePull(ctx, imageName, types.ImagePullOptions{})
+	return c.PullImageWithProgress(ctx, imageName, nil)
+}
+
+// TagImage adds a tag to an existing image without rebuilding it
+func (c *Client) TagImage(ctx context.Context, source, target string) error {
+	if err := c.cli.ImageTag(ctx, source, target); err != nil {
+		return fmt.Errorf("failed to tag image %s as %s: %w", source, target, err)
+	}
+	c.logger.Info("Image tagged", zap.String("source", source), zap.String("target", target))
+	return nil
+}
+
+// PushImage pushes an image reference to its registry and returns the pushed digest
+func (c *Client) PushImage(ctx context.Context, ref, registryAuth string) (string, error) {
+	reader, err := c.cli.ImagePush(ctx, ref, types.ImagePushOptions{RegistryAuth: registryAuth})
 	if err != nil {
-		return fmt.Errorf("failed to pull image %s: %w", imageName, err)
+		return "", fmt.Errorf("failed to push image %s: %w", ref, err)
 	}
 	defer reader.Close()
 
-	// Consume the output
-	_, err = io.Copy(io.Discard, reader)
-	if err != nil {
-		return fmt.Errorf("error reading pull output: %w", err)
+	// Push errors are reported in the progress stream rather than the HTTP status
+	var digest string
+	decoder := json.NewDecoder(reader)
+	for {
+		var msg jsonmessage.JSONMessage
+		if err := decoder.Decode(&msg); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return "", fmt.Errorf("error reading push output: %w", err)
+		}
+		if msg.Error != nil {
+			return "", fmt.Errorf("failed to push image %s: %s", ref, msg.Error.Message)
+		}
+		if msg.Aux != nil {
+			var aux struct {
+				Digest string `json:"Digest"`
+			}
+			if json.Unmarshal(*msg.Aux, &aux) == nil && aux.Digest != "" {
+				digest = aux.Digest
+			}
+		}
 	}
 
-	c.logger.Info("Image pulled", zap.String("image", imageName))
-	return nil
+	c.logger.Info("Image pushed", zap.String("image", ref), zap.String("digest", digest))
+	return digest, nil
 }
 
 // RemoveImage removes an image
@@ -474,6 +649,24 @@ func (c *Client) ListImages(ctx context.Context) ([]types.ImageSummary, error) {
 	return images, nil
 }
 
+// InspectImage returns low-level information about an image
+func (c *Client) InspectImage(ctx context.Context, imageID string) (types.ImageInspect, error) {
+	info, _, err := c.cli.ImageInspectWithRaw(ctx, imageID)
+	if err != nil {
+		return types.ImageInspect{}, fmt.Errorf("failed to inspect image %s: %w", imageID, err)
+	}
+	return info, nil
+}
+
+// ImageHistory returns the layer history of an image, newest layer first
+func (c *Client) ImageHistory(ctx context.Context, imageID string) ([]image.HistoryResponseItem, error) {
+	history, err := c.cli.ImageHistory(ctx, imageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get history for image %s: %w", imageID, err)
+	}
+	return history, nil
+}
+
 // EnsureNetwork creates the default network if it doesn't exist
 func (c *Client) EnsureNetwork(ctx context.Context) error {
 	if c.defaultNetwork == "" {
@@ -544,3 +737,11 @@ func (c *Client) HealthCheck(ctx context.Context, containerID string) (bool, err
 func (c *Client) Close() error {
 	return c.cli.Close()
 }
+
+// ShortID truncates a container ID to its 12-character short form
+func ShortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
@@ -0,0 +1,142 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// LogStream identifies which output stream a log line came from
+type LogStream string
+
+const (
+	LogStreamStdout LogStream = "stdout"
+	LogStreamStderr LogStream = "stderr"
+)
+
+// maxLogLineSize bounds a single buffered log line; longer lines are split
+const maxLogLineSize = 64 * 1024
+
+// LogLine is a single demultiplexed container log line
+type LogLine struct {
+	Stream    LogStream `json:"stream"`
+	Timestamp time.Time `json:"timestamp"`
+	Message   string    `json:"message"`
+}
+
+// LogOptions selects which container log lines to read
+type LogOptions struct {
+	Follow bool
+	Tail   string    // number of lines or "all"
+	Since  time.Time // only lines at or after this time; zero reads from the start
+}
+
+// StreamLogLines reads container logs and calls fn for each complete line in order.
+// Docker's multiplexed framing is handled with stdcopy; TTY containers are read raw as stdout.
+// Returning an error from fn stops the stream.
+func (c *Client) StreamLogLines(ctx context.Context, containerID string, opts LogOptions, fn func(LogLine) error) error {
+	info, err := c.InspectContainer(ctx, containerID)
+	if err != nil {
+		return err
+	}
+
+	reader, err := c.containerLogs(ctx, containerID, opts)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	stdout := &logLineWriter{stream: LogStreamStdout, fn: fn}
+	stderr := &logLineWriter{stream: LogStreamStderr, fn: fn}
+
+	if info.Config != nil && info.Config.Tty {
+		_, err = io.Copy(stdout, reader)
+	} else {
+		_, err = stdcopy.StdCopy(stdout, stderr, reader)
+	}
+
+	// Emit any trailing partial lines
+	if flushErr := stdout.flush(); err == nil {
+		err = flushErr
+	}
+	if flushErr := stderr.flush(); err == nil {
+		err = flushErr
+	}
+
+	if err != nil && ctx.Err() == nil {
+		return fmt.Errorf("failed to read logs for container %s: %w", ShortID(containerID), err)
+	}
+	return nil
+}
+
+// ContainerLogLines returns a container's log lines without following
+func (c *Client) ContainerLogLines(ctx context.Context, containerID string, opts LogOptions) ([]LogLine, error) {
+	opts.Follow = false
+
+	var lines []LogLine
+	err := c.StreamLogLines(ctx, containerID, opts, func(line LogLine) error {
+		lines = append(lines, line)
+		return nil
+	})
+	return lines, err
+}
+
+// logLineWriter buffers stream output and emits complete, timestamp-parsed lines
+type logLineWriter struct {
+	stream LogStream
+	fn     func(LogLine) error
+	buf    bytes.Buffer
+}
+
+func (w *logLineWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+
+	for {
+		idx := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if idx < 0 {
+			if w.buf.Len() > maxLogLineSize {
+				if err := w.emit(string(w.buf.Next(maxLogLineSize))); err != nil {
+					return 0, err
+				}
+				continue
+			}
+			return len(p), nil
+		}
+
+		line := string(w.buf.Next(idx + 1))
+		if err := w.emit(strings.TrimRight(line, "\r\n")); err != nil {
+			return 0, err
+		}
+	}
+}
+
+func (w *logLineWriter) flush() error {
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	line := w.buf.String()
+	w.buf.Reset()
+	return w.emit(line)
+}
+
+// emit parses the RFC3339Nano timestamp Docker prefixes to each line and calls fn
+func (w *logLineWriter) emit(raw string) error {
+	line := LogLine{Stream: w.stream, Message: raw}
+
+	if ts, msg, found := strings.Cut(raw, " "); found {
+		if parsed, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+			line.Timestamp = parsed
+			line.Message = msg
+		}
+	}
+	if line.Timestamp.IsZero() {
+		line.Timestamp = time.Now().UTC()
+	}
+
+	return w.fn(line)
+}
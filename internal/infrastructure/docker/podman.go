@@ -0,0 +1,100 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// podmanRootSocket is the system service socket used by rootful Podman
+	podmanRootSocket = "/run/podman/podman.sock"
+
+	// defaultRegistry qualifies short image names, which Podman may refuse to resolve
+	defaultRegistry = "docker.io"
+)
+
+// PodmanClient talks to Podman through its Docker-compatible API service.
+// It reuses Client for everything the compat API handles identically and
+// overrides the operations where Podman behaves differently.
+type PodmanClient struct {
+	*Client
+}
+
+// NewPodmanClient creates a client for the Podman API service.
+// An empty host uses the rootless socket under XDG_RUNTIME_DIR if present, else the rootful socket.
+func NewPodmanClient(host, containerPrefix, defaultNetwork string, tlsOpts TLSOptions, logger *zap.Logger) (*PodmanClient, error) {
+	if host == "" {
+		host = "unix://" + podmanSocketPath()
+	}
+
+	// Podman's compat API reports an older Docker API version than the
+	// configured default, so always negotiate instead of pinning one
+	c, err := NewClient(host, "", containerPrefix, defaultNetwork, tlsOpts, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create podman client: %w", err)
+	}
+
+	logger.Info("Using Podman container runtime", zap.String("host", host))
+	return &PodmanClient{Client: c}, nil
+}
+
+// Name returns the runtime identifier
+func (p *PodmanClient) Name() string {
+	return RuntimePodman
+}
+
+// Ping checks if the Podman service is responsive
+func (p *PodmanClient) Ping(ctx context.Context) error {
+	if _, err := p.cli.Ping(ctx); err != nil {
+		return fmt.Errorf("podman service not responding (is podman.socket enabled?): %w", err)
+	}
+	return nil
+}
+
+// ConnectionStatus reports the Podman service connection
+func (p *PodmanClient) ConnectionStatus(ctx context.Context) ConnectionStatus {
+	status := p.Client.ConnectionStatus(ctx)
+	status.Runtime = p.Name()
+	return status
+}
+
+// PullImage pulls an image, fully qualifying short names first.
+// Podman resolves short names through registries.conf and fails when no
+// unqualified-search registry is configured, unlike Docker which assumes Docker Hub.
+func (p *PodmanClient) PullImage(ctx context.Context, imageName string) error {
+	return p.Client.PullImage(ctx, qualifyImageName(imageName))
+}
+
+// PullImageWithProgress pulls an image with progress, fully qualifying short names first
+func (p *PodmanClient) PullImageWithProgress(ctx context.Context, imageName string, fn PullProgressFunc) error {
+	return p.Client.PullImageWithProgress(ctx, qualifyImageName(imageName), fn)
+}
+
+// podmanSocketPath returns the Podman API socket for the current user
+func podmanSocketPath() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" && os.Geteuid() != 0 {
+		rootless := filepath.Join(dir, "podman", "podman.sock")
+		if _, err := os.Stat(rootless); err == nil {
+			return rootless
+		}
+	}
+	return podmanRootSocket
+}
+
+// qualifyImageName prefixes Docker Hub to image names without a registry host
+// e.g. "nginx" -> "docker.io/library/nginx", "user/app:1" -> "docker.io/user/app:1"
+func qualifyImageName(name string) string {
+	first, _, hasSlash := strings.Cut(name, "/")
+	if hasSlash && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		return name
+	}
+	if !hasSlash {
+		return defaultRegistry + "/library/" + name
+	}
+	return defaultRegistry + "/" + name
+}
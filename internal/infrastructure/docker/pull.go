@@ -0,0 +1,250 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"go.uber.org/zap"
+)
+
+// PullProgress is a snapshot of an image pull, reported as layers download
+type PullProgress struct {
+	Image      string  `json:"image,omitempty"`
+	LayerID    string  `json:"layer_id,omitempty"`
+	Status     string  `json:"status"`
+	Current    int64   `json:"current,omitempty"` // bytes of this layer downloaded
+	Total      int64   `json:"total,omitempty"`   // size of this layer, 0 if not yet known
+	Percent    float64 `json:"percent"`           // this layer's download percentage
+	Layers     int     `json:"layers"`
+	LayersDone int     `json:"layers_done"`
+	Overall    float64 `json:"overall_percent"` // bytes downloaded across layers of known size
+	Done       bool    `json:"done,omitempty"`
+}
+
+// PullProgressFunc receives pull progress updates
+type PullProgressFunc func(PullProgress)
+
+// pullLayer is the tracked state of one layer
+type pullLayer struct {
+	current     int64
+	total       int64
+	done        bool
+	lastPercent float64
+	lastStatus  string
+}
+
+// pullTracker aggregates per-layer progress messages and throttles updates so a
+// subscriber sees each layer move roughly one percent at a time
+type pullTracker struct {
+	image  string
+	layers map[string]*pullLayer
+	order  []string
+	fn     PullProgressFunc
+}
+
+func newPullTracker(image string, fn PullProgressFunc) *pullTracker {
+	return &pullTracker{
+		image:  image,
+		layers: make(map[string]*pullLayer),
+		fn:     fn,
+	}
+}
+
+// isPullMessage reports whether a progress stream message describes a layer pull
+func isPullMessage(msg *jsonmessage.JSONMessage) bool {
+	if msg.ID == "" || msg.Stream != "" {
+		return false
+	}
+	switch {
+	case msg.Progress != nil && msg.Progress.Total > 0,
+		strings.HasPrefix(msg.Status, "Pulling fs layer"),
+		strings.HasPrefix(msg.Status, "Waiting"),
+		strings.HasPrefix(msg.Status, "Downloading"),
+		strings.HasPrefix(msg.Status, "Verifying Checksum"),
+		strings.HasPrefix(msg.Status, "Download complete"),
+		strings.HasPrefix(msg.Status, "Extracting"),
+		strings.HasPrefix(msg.Status, "Pull complete"),
+		strings.HasPrefix(msg.Status, "Already exists"):
+		return true
+	}
+	return false
+}
+
+// observe records a layer message and reports it if it is a meaningful change
+func (t *pullTracker) observe(msg *jsonmessage.JSONMessage) {
+	layer, ok := t.layers[msg.ID]
+	if !ok {
+		layer = &pullLayer{}
+		t.layers[msg.ID] = layer
+		t.order = append(t.order, msg.ID)
+	}
+
+	status := msg.Status
+	switch {
+	case strings.HasPrefix(status, "Downloading") && msg.Progress != nil:
+		layer.current = msg.Progress.Current
+		if msg.Progress.Total > 0 {
+			layer.total = msg.Progress.Total
+		}
+	case strings.HasPrefix(status, "Download complete"), strings.HasPrefix(status, "Verifying Checksum"):
+		layer.current = layer.total
+	case strings.HasPrefix(status, "Pull complete"), strings.HasPrefix(status, "Already exists"):
+		layer.current = layer.total
+		layer.done = true
+	}
+
+	percent := layerPercent(layer)
+	if status == layer.lastStatus && percent-layer.lastPercent < 1 && !layer.done {
+		return
+	}
+	layer.lastStatus = status
+	layer.lastPercent = percent
+
+	t.report(PullProgress{
+		LayerID: msg.ID,
+		Status:  status,
+		Current: layer.current,
+		Total:   layer.total,
+		Percent: percent,
+	})
+}
+
+// finish reports the pull as complete
+func (t *pullTracker) finish(status string) {
+	for _, layer := range t.layers {
+		layer.current = layer.total
+		layer.done = true
+	}
+	t.report(PullProgress{Status: status, Done: true})
+}
+
+// report fills in the aggregate fields and invokes the callback
+func (t *pullTracker) report(p PullProgress) {
+	if t.fn == nil {
+		return
+	}
+	var current, total int64
+	for _, id := range t.order {
+		layer := t.layers[id]
+		if layer.done {
+			p.LayersDone++
+		}
+		if layer.total > 0 {
+			current += layer.current
+			total += layer.total
+		}
+	}
+	p.Image = t.image
+	p.Layers = len(t.order)
+	switch {
+	case p.Done:
+		p.Overall = 100
+	case total > 0:
+		p.Overall = roundPercent(float64(current) / float64(total) * 100)
+	}
+	t.fn(p)
+}
+
+func layerPercent(layer *pullLayer) float64 {
+	if layer.done {
+		return 100
+	}
+	if layer.total <= 0 {
+		return 0
+	}
+	return roundPercent(float64(layer.current) / float64(layer.total) * 100)
+}
+
+// roundPercent rounds to one decimal place
+func roundPercent(p float64) float64 {
+	return float64(int64(p*10+0.5)) / 10
+}
+
+// PullImageWithProgress pulls an image, reporting layer download progress to fn
+func (c *Client) PullImageWithProgress(ctx context.Context, imageName string, fn PullProgressFunc) error {
+	reader, err := c.cli.ImagePull(ctx, imageName, types.ImagePullOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to pull image %s: %w", imageName, err)
+	}
+	defer reader.Close()
+
+	// Pull errors are reported in the progress stream rather than the HTTP status
+	tracker := newPullTracker(imageName, fn)
+	var final string
+	decoder := json.NewDecoder(reader)
+	for {
+		var msg jsonmessage.JSONMessage
+		if err := decoder.Decode(&msg); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return fmt.Errorf("error reading pull output: %w", err)
+		}
+		if msg.Error != nil {
+			return fmt.Errorf("failed to pull image %s: %s", imageName, msg.Error.Message)
+		}
+		if isPullMessage(&msg) {
+			tracker.observe(&msg)
+		} else if msg.Status != "" {
+			final = msg.Status
+		}
+	}
+	tracker.finish(final)
+
+	c.logger.Info("Image pulled",
+		zap.String("image", imageName),
+		zap.Int("layers", len(tracker.order)),
+	)
+	return nil
+}
+
+// ImageExists reports whether an image is present locally
+func (c *Client) ImageExists(ctx context.Context, imageRef string) (bool, error) {
+	_, _, err := c.cli.ImageInspectWithRaw(ctx, imageRef)
+	if err == nil {
+		return true, nil
+	}
+	if errdefs.IsNotFound(err) {
+		return false, nil
+	}
+	return false, fmt.Errorf("failed to inspect image %s: %w", imageRef, err)
+}
+
+// forwardBuildOutput decodes a build's JSON progress stream, sending base image pull
+// progress to fn when set and everything else to the line writer. A build error in the
+// stream is returned.
+func forwardBuildOutput(body io.Reader, lines *buildLineWriter, fn PullProgressFunc) error {
+	tracker := newPullTracker("", fn)
+	decoder := json.NewDecoder(body)
+	var buildErr error
+	for {
+		var msg jsonmessage.JSONMessage
+		if err := decoder.Decode(&msg); err != nil {
+			if err == io.EOF {
+				break
+			}
+			lines.flush()
+			return fmt.Errorf("error reading build output: %w", err)
+		}
+
+		if fn != nil && isPullMessage(&msg) {
+			tracker.observe(&msg)
+			continue
+		}
+		lines.message(&msg)
+		if msg.Error != nil && buildErr == nil {
+			buildErr = fmt.Errorf("build failed: %s", msg.Error.Message)
+		}
+	}
+	lines.flush()
+	if len(tracker.order) > 0 {
+		tracker.finish("Base image pulled")
+	}
+	return buildErr
+}
@@ -0,0 +1,91 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"go.uber.org/zap"
+)
+
+// Supported container runtimes
+const (
+	RuntimeDocker = "docker"
+	RuntimePodman = "podman"
+)
+
+// ContainerRuntime is the container engine API NanoPaaS depends on.
+// Client talks to Docker; PodmanClient adapts it to Podman's Docker-compatible API.
+type ContainerRuntime interface {
+	// Name returns the runtime identifier ("docker" or "podman")
+	Name() string
+	Ping(ctx context.Context) error
+	Info(ctx context.Context) (types.Info, error)
+	ConnectionStatus(ctx context.Context) ConnectionStatus
+	Close() error
+
+	// Containers
+	ListContainers(ctx context.Context, all bool) ([]ContainerInfo, error)
+	CreateContainer(ctx context.Context, opts ContainerOptions) (string, error)
+	StartContainer(ctx context.Context, containerID string) error
+	StopContainer(ctx context.Context, containerID string, timeout *int) error
+	RestartContainer(ctx context.Context, containerID string, timeout *int) error
+	RemoveContainer(ctx context.Context, containerID string, force bool) error
+	InspectContainer(ctx context.Context, containerID string) (types.ContainerJSON, error)
+	ContainerStats(ctx context.Context, containerID string) (*ContainerStats, error)
+	ContainerIP(ctx context.Context, containerID string) (string, error)
+	WaitForContainer(ctx context.Context, containerID string, condition container.WaitCondition) error
+	HealthCheck(ctx context.Context, containerID string) (bool, error)
+	AttachAndStart(ctx context.Context, containerID string, stdout, stderr io.Writer) error
+
+	// Logs
+	GetContainerLogs(ctx context.Context, containerID string, follow bool, tail string) (io.ReadCloser, error)
+	StreamContainerLogs(ctx context.Context, containerID string, stdout, stderr io.Writer) error
+	StreamLogLines(ctx context.Context, containerID string, opts LogOptions, fn func(LogLine) error) error
+	ContainerLogLines(ctx context.Context, containerID string, opts LogOptions) ([]LogLine, error)
+
+	// Files
+	CopyToContainer(ctx context.Context, containerID, dstDir string, content io.Reader) error
+	CopyFromContainer(ctx context.Context, containerID, srcPath string) (io.ReadCloser, types.ContainerPathStat, error)
+	StatContainerPath(ctx context.Context, containerID, path string) (types.ContainerPathStat, error)
+
+	// Images
+	BuildImage(ctx context.Context, buildContext io.Reader, opts BuildOptions) (string, error)
+	BuildImageWithLogs(ctx context.Context, buildContext io.Reader, opts BuildOptions, logCallback BuildLogFunc) (string, error)
+	PullImage(ctx context.Context, imageName string) error
+	PullImageWithProgress(ctx context.Context, imageName string, fn PullProgressFunc) error
+	ImageExists(ctx context.Context, imageRef string) (bool, error)
+	TagImage(ctx context.Context, source, target string) error
+	PushImage(ctx context.Context, ref, registryAuth string) (string, error)
+	RemoveImage(ctx context.Context, imageID string, force bool) error
+	ListImages(ctx context.Context) ([]types.ImageSummary, error)
+	InspectImage(ctx context.Context, imageID string) (types.ImageInspect, error)
+	ImageHistory(ctx context.Context, imageID string) ([]image.HistoryResponseItem, error)
+
+	// Networks
+	EnsureNetwork(ctx context.Context) error
+
+	// System
+	DiskUsage(ctx context.Context) (*DiskUsage, error)
+	Prune(ctx context.Context, opts PruneOptions) (*PruneReport, error)
+}
+
+var (
+	_ ContainerRuntime = (*Client)(nil)
+	_ ContainerRuntime = (*PodmanClient)(nil)
+)
+
+// NewRuntime creates a client for the named container runtime
+func NewRuntime(name, host, apiVersion, containerPrefix, defaultNetwork string, tlsOpts TLSOptions, logger *zap.Logger) (ContainerRuntime, error) {
+	switch name {
+	case "", RuntimeDocker:
+		return NewClient(host, apiVersion, containerPrefix, defaultNetwork, tlsOpts, logger)
+	case RuntimePodman:
+		return NewPodmanClient(host, containerPrefix, defaultNetwork, tlsOpts, logger)
+	default:
+		return nil, fmt.Errorf("unsupported container runtime %q (expected docker or podman)", name)
+	}
+}
@@ -0,0 +1,79 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/docker/docker/api/types"
+)
+
+// ContainerStats is a single resource usage sample for a container
+type ContainerStats struct {
+	CPUPercent    float64 `json:"cpu_percent"`
+	MemoryUsage   uint64  `json:"memory_usage"`
+	MemoryLimit   uint64  `json:"memory_limit"`
+	MemoryPercent float64 `json:"memory_percent"`
+	NetworkRx     uint64  `json:"network_rx_bytes"`
+	NetworkTx     uint64  `json:"network_tx_bytes"`
+	PIDs          uint64  `json:"pids"`
+}
+
+// ContainerStats takes a one-shot resource usage sample of a running container
+func (c *Client) ContainerStats(ctx context.Context, containerID string) (*ContainerStats, error) {
+	resp, err := c.cli.ContainerStatsOneShot(ctx, containerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stats for container %s: %w", ShortID(containerID), err)
+	}
+	defer resp.Body.Close()
+
+	var raw types.StatsJSON
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("failed to decode stats for container %s: %w", ShortID(containerID), err)
+	}
+
+	stats := &ContainerStats{
+		CPUPercent:  cpuPercent(&raw),
+		MemoryUsage: memoryUsage(&raw.MemoryStats),
+		MemoryLimit: raw.MemoryStats.Limit,
+		PIDs:        raw.PidsStats.Current,
+	}
+	if stats.MemoryLimit > 0 {
+		stats.MemoryPercent = roundPercent(float64(stats.MemoryUsage) / float64(stats.MemoryLimit) * 100)
+	}
+	for _, network := range raw.Networks {
+		stats.NetworkRx += network.RxBytes
+		stats.NetworkTx += network.TxBytes
+	}
+	return stats, nil
+}
+
+// cpuPercent computes usage the way `docker stats` does, from the delta between the
+// sample and the pre-sample the daemon includes with it
+func cpuPercent(s *types.StatsJSON) float64 {
+	cpuDelta := float64(s.CPUStats.CPUUsage.TotalUsage) - float64(s.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(s.CPUStats.SystemUsage) - float64(s.PreCPUStats.SystemUsage)
+	if cpuDelta <= 0 || systemDelta <= 0 {
+		return 0
+	}
+	cpus := float64(s.CPUStats.OnlineCPUs)
+	if cpus == 0 {
+		cpus = float64(len(s.CPUStats.CPUUsage.PercpuUsage))
+	}
+	if cpus == 0 {
+		cpus = 1
+	}
+	return roundPercent(cpuDelta / systemDelta * cpus * 100)
+}
+
+// memoryUsage excludes page cache, matching `docker stats`
+func memoryUsage(m *types.MemoryStats) uint64 {
+	cache := m.Stats["inactive_file"] // cgroup v2
+	if v, ok := m.Stats["total_inactive_file"]; ok {
+		cache = v // cgroup v1
+	}
+	if cache > m.Usage {
+		return m.Usage
+	}
+	return m.Usage - cache
+}
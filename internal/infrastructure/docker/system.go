@@ -0,0 +1,253 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"go.uber.org/zap"
+)
+
+// managedContainerLabel marks containers owned by the orchestrator, which prune leaves alone
+const managedContainerLabel = "nanopaas.app.id"
+
+// DiskUsageCategory summarizes the space used by one kind of object
+type DiskUsageCategory struct {
+	Count       int   `json:"count"`
+	Active      int   `json:"active"`
+	Size        int64 `json:"size"`
+	Reclaimable int64 `json:"reclaimable"`
+}
+
+// DiskUsage is the equivalent of `docker system df`
+type DiskUsage struct {
+	Images           DiskUsageCategory `json:"images"`
+	Containers       DiskUsageCategory `json:"containers"`
+	Volumes          DiskUsageCategory `json:"volumes"`
+	BuildCache       DiskUsageCategory `json:"build_cache"`
+	TotalSize        int64             `json:"total_size"`
+	TotalReclaimable int64             `json:"total_reclaimable"`
+}
+
+// PruneOptions selects what to prune
+type PruneOptions struct {
+	Containers bool
+	Images     bool
+	AllImages  bool     // remove all unused images instead of only dangling ones
+	KeepImages []string // image IDs or references never removed by AllImages
+	Volumes    bool     // unused anonymous volumes
+	Networks   bool
+	BuildCache bool
+	Until      time.Duration // only prune objects older than this; 0 prunes regardless of age
+}
+
+// PruneReport summarizes a prune
+type PruneReport struct {
+	ContainersDeleted int      `json:"containers_deleted"`
+	ImagesDeleted     int      `json:"images_deleted"`
+	VolumesDeleted    int      `json:"volumes_deleted"`
+	NetworksDeleted   int      `json:"networks_deleted"`
+	BuildCacheDeleted int      `json:"build_cache_deleted"`
+	SpaceReclaimed    uint64   `json:"space_reclaimed"`
+	Errors            []string `json:"errors,omitempty"`
+}
+
+// DiskUsage reports space used by images, containers, volumes and build cache
+func (c *Client) DiskUsage(ctx context.Context) (*DiskUsage, error) {
+	du, err := c.cli.DiskUsage(ctx, types.DiskUsageOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get disk usage: %w", err)
+	}
+
+	usage := &DiskUsage{}
+
+	// Image layers are shared, so the total is LayersSize and only the
+	// unique part of images used by containers is not reclaimable
+	usage.Images.Size = du.LayersSize
+	var imagesInUse int64
+	for _, img := range du.Images {
+		usage.Images.Count++
+		if img.Containers > 0 {
+			usage.Images.Active++
+			unique := img.Size
+			if img.SharedSize > 0 {
+				unique -= img.SharedSize
+			}
+			imagesInUse += unique
+		}
+	}
+	usage.Images.Reclaimable = max(du.LayersSize-imagesInUse, 0)
+
+	for _, ctr := range du.Containers {
+		usage.Containers.Count++
+		usage.Containers.Size += ctr.SizeRw
+		if ctr.State == "running" {
+			usage.Containers.Active++
+		} else {
+			usage.Containers.Reclaimable += ctr.SizeRw
+		}
+	}
+
+	for _, vol := range du.Volumes {
+		usage.Volumes.Count++
+		if vol.UsageData == nil || vol.UsageData.Size < 0 {
+			continue
+		}
+		usage.Volumes.Size += vol.UsageData.Size
+		if vol.UsageData.RefCount > 0 {
+			usage.Volumes.Active++
+		} else {
+			usage.Volumes.Reclaimable += vol.UsageData.Size
+		}
+	}
+
+	for _, bc := range du.BuildCache {
+		usage.BuildCache.Count++
+		usage.BuildCache.Size += bc.Size
+		if bc.InUse {
+			usage.BuildCache.Active++
+		} else if !bc.Shared {
+			usage.BuildCache.Reclaimable += bc.Size
+		}
+	}
+
+	for _, cat := range []DiskUsageCategory{usage.Images, usage.Containers, usage.Volumes, usage.BuildCache} {
+		usage.TotalSize += cat.Size
+		usage.TotalReclaimable += cat.Reclaimable
+	}
+
+	return usage, nil
+}
+
+// Prune removes unused objects. Containers managed by NanoPaaS and the platform network
+// are always kept; individual failures are recorded in the report.
+func (c *Client) Prune(ctx context.Context, opts PruneOptions) (*PruneReport, error) {
+	report := &PruneReport{}
+
+	untilArgs := func() filters.Args {
+		args := filters.NewArgs()
+		if opts.Until > 0 {
+			args.Add("until", opts.Until.String())
+		}
+		return args
+	}
+
+	if opts.Containers {
+		args := untilArgs()
+		args.Add("label!", managedContainerLabel)
+		res, err := c.cli.ContainersPrune(ctx, args)
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("containers: %v", err))
+		} else {
+			report.ContainersDeleted = len(res.ContainersDeleted)
+			report.SpaceReclaimed += res.SpaceReclaimed
+		}
+	}
+
+	if opts.Images {
+		if opts.AllImages {
+			c.pruneUnusedImages(ctx, opts, report)
+		} else {
+			args := untilArgs()
+			args.Add("dangling", "true")
+			res, err := c.cli.ImagesPrune(ctx, args)
+			if err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("images: %v", err))
+			} else {
+				report.ImagesDeleted = len(res.ImagesDeleted)
+				report.SpaceReclaimed += res.SpaceReclaimed
+			}
+		}
+	}
+
+	if opts.Volumes {
+		res, err := c.cli.VolumesPrune(ctx, filters.NewArgs())
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("volumes: %v", err))
+		} else {
+			report.VolumesDeleted = len(res.VolumesDeleted)
+			report.SpaceReclaimed += res.SpaceReclaimed
+		}
+	}
+
+	if opts.Networks {
+		args := untilArgs()
+		args.Add("label!", "managed-by=nanopaas")
+		res, err := c.cli.NetworksPrune(ctx, args)
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("networks: %v", err))
+		} else {
+			report.NetworksDeleted = len(res.NetworksDeleted)
+		}
+	}
+
+	if opts.BuildCache {
+		res, err := c.cli.BuildCachePrune(ctx, types.BuildCachePruneOptions{All: true, Filters: untilArgs()})
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("build cache: %v", err))
+		} else {
+			report.BuildCacheDeleted = len(res.CachesDeleted)
+			report.SpaceReclaimed += res.SpaceReclaimed
+		}
+	}
+
+	c.logger.Info("Docker prune completed",
+		zap.Int("containers", report.ContainersDeleted),
+		zap.Int("images", report.ImagesDeleted),
+		zap.Int("volumes", report.VolumesDeleted),
+		zap.Int("networks", report.NetworksDeleted),
+		zap.Int("build_cache", report.BuildCacheDeleted),
+		zap.Uint64("space_reclaimed", report.SpaceReclaimed),
+		zap.Int("errors", len(report.Errors)),
+	)
+
+	return report, nil
+}
+
+// pruneUnusedImages removes images without containers one by one so KeepImages
+// (e.g. rollback targets) survive, which the daemon's prune filters cannot express
+func (c *Client) pruneUnusedImages(ctx context.Context, opts PruneOptions, report *PruneReport) {
+	du, err := c.cli.DiskUsage(ctx, types.DiskUsageOptions{Types: []types.DiskUsageObject{types.ImageObject}})
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("images: %v", err))
+		return
+	}
+
+	keep := make(map[string]bool, len(opts.KeepImages))
+	for _, ref := range opts.KeepImages {
+		keep[ref] = true
+	}
+	cutoff := time.Now().Add(-opts.Until)
+
+	for _, img := range du.Images {
+		if img.Containers > 0 || keep[img.ID] {
+			continue
+		}
+		if opts.Until > 0 && time.Unix(img.Created, 0).After(cutoff) {
+			continue
+		}
+		kept := false
+		for _, tag := range img.RepoTags {
+			if keep[tag] {
+				kept = true
+				break
+			}
+		}
+		if kept {
+			continue
+		}
+
+		if _, err := c.cli.ImageRemove(ctx, img.ID, types.ImageRemoveOptions{PruneChildren: true}); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("image %s: %v", ShortID(img.ID), err))
+			continue
+		}
+		report.ImagesDeleted++
+		unique := img.Size
+		if img.SharedSize > 0 {
+			unique -= img.SharedSize
+		}
+		report.SpaceReclaimed += uint64(max(unique, 0))
+	}
+}
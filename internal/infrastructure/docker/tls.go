@@ -0,0 +1,128 @@
+package docker
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/docker/go-connections/tlsconfig"
+)
+
+// TLSOptions configures TLS for a remote (tcp://) daemon.
+// CertPath follows the Docker CLI layout: ca.pem, cert.pem and key.pem.
+type TLSOptions struct {
+	Verify   bool   // verify the daemon certificate against ca.pem
+	CertPath string // directory holding the certificates; empty disables TLS
+}
+
+// Enabled reports whether TLS should be used
+func (o TLSOptions) Enabled() bool {
+	return o.Verify || o.CertPath != ""
+}
+
+// ConnectionStatus describes the client's connection to the daemon
+type ConnectionStatus struct {
+	Runtime       string     `json:"runtime"`
+	Host          string     `json:"host"`
+	Remote        bool       `json:"remote"`
+	TLS           bool       `json:"tls"`
+	TLSVerified   bool       `json:"tls_verified"`
+	CertExpiresAt *time.Time `json:"cert_expires_at,omitempty"`
+	Reachable     bool       `json:"reachable"`
+	LatencyMS     int64      `json:"latency_ms"`
+	ServerVersion string     `json:"server_version,omitempty"`
+	APIVersion    string     `json:"api_version,omitempty"`
+	Error         string     `json:"error,omitempty"`
+}
+
+// tlsClientOpts builds client options for a TLS connection to the daemon
+func tlsClientOpts(opts TLSOptions) ([]client.Opt, error) {
+	if opts.CertPath == "" {
+		return nil, fmt.Errorf("DOCKER_CERT_PATH is required when DOCKER_TLS_VERIFY is set")
+	}
+
+	tlsConfig, err := tlsconfig.Client(tlsconfig.Options{
+		CAFile:             filepath.Join(opts.CertPath, "ca.pem"),
+		CertFile:           filepath.Join(opts.CertPath, "cert.pem"),
+		KeyFile:            filepath.Join(opts.CertPath, "key.pem"),
+		InsecureSkipVerify: !opts.Verify,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load docker TLS certificates: %w", err)
+	}
+
+	// The SDK switches to https when the transport carries a TLS config
+	httpClient := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig:     tlsConfig,
+			TLSHandshakeTimeout: 10 * time.Second,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: client.CheckRedirect,
+	}
+
+	return []client.Opt{client.WithHTTPClient(httpClient)}, nil
+}
+
+// certExpiry returns the NotAfter time of the client certificate in certPath
+func certExpiry(certPath string) (*time.Time, error) {
+	data, err := os.ReadFile(filepath.Join(certPath, "cert.pem"))
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data in cert.pem")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	expires := cert.NotAfter.UTC()
+	return &expires, nil
+}
+
+// isRemoteHost reports whether the daemon is reached over the network
+func isRemoteHost(host string) bool {
+	return strings.HasPrefix(host, "tcp://") || strings.HasPrefix(host, "https://") || strings.HasPrefix(host, "http://")
+}
+
+// ConnectionStatus pings the daemon and reports transport, TLS and latency details
+func (c *Client) ConnectionStatus(ctx context.Context) ConnectionStatus {
+	status := ConnectionStatus{
+		Runtime:     c.Name(),
+		Host:        c.cli.DaemonHost(),
+		Remote:      isRemoteHost(c.cli.DaemonHost()),
+		TLS:         c.tls.Enabled(),
+		TLSVerified: c.tls.Verify,
+	}
+
+	if status.TLS {
+		if expires, err := certExpiry(c.tls.CertPath); err == nil {
+			status.CertExpiresAt = expires
+		}
+	}
+
+	start := time.Now()
+	ping, err := c.cli.Ping(ctx)
+	status.LatencyMS = time.Since(start).Milliseconds()
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	status.Reachable = true
+	status.APIVersion = ping.APIVersion
+
+	if version, err := c.cli.ServerVersion(ctx); err == nil {
+		status.ServerVersion = version.Version
+	}
+
+	return status
+}
@@ -28,7 +28,7 @@ func Logger(logger *zap.Logger) func(http.Handler) http.Handler {
 				zap.Duration("duration", duration),
 				zap.String("ip", getClientIP(r)),
 				zap.String("user_agent", r.UserAgent()),
-				zap.String("request_id", w.Header().Get("X-Request-ID")),
+				zap.String("request_id", w.Header().Get(RequestIDHeader)),
 			)
 		})
 	}
@@ -0,0 +1,186 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// APIVersionHeader reports the API version that served a request
+const APIVersionHeader = "API-Version"
+
+type apiVersionKey struct{}
+
+// APIVersion records the version of a versioned route group in the request context
+// and response headers. Mount it on each /api/vN group.
+func APIVersion(version int) func(http.Handler) http.Handler {
+	value := strconv.Itoa(version)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(APIVersionHeader, value)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiVersionKey{}, version)))
+		})
+	}
+}
+
+// APIVersionFromContext returns the API version serving the request, or 0 outside a versioned group
+func APIVersionFromContext(ctx context.Context) int {
+	version, _ := ctx.Value(apiVersionKey{}).(int)
+	return version
+}
+
+// Deprecation marks a route as deprecated
+type Deprecation struct {
+	Method    string    // "" matches every method
+	Pattern   string    // chi route pattern, e.g. /api/v1/apps/{appId}/incident
+	Since     time.Time // sent as the Deprecation header
+	Sunset    time.Time // zero when no removal date is set
+	Successor string    // replacement route, sent as a successor-version link
+	Note      string
+}
+
+// DeprecationUsage counts calls to a deprecated route since startup
+type DeprecationUsage struct {
+	Method       string     `json:"method"`
+	Pattern      string     `json:"pattern"`
+	Since        time.Time  `json:"deprecated_since"`
+	Sunset       *time.Time `json:"sunset,omitempty"`
+	Successor    string     `json:"successor,omitempty"`
+	Note         string     `json:"note,omitempty"`
+	Calls        int64      `json:"calls"`
+	LastCalledAt *time.Time `json:"last_called_at,omitempty"`
+	LastClient   string     `json:"last_client,omitempty"` // user agent of the most recent caller
+}
+
+// DeprecationTracker adds Deprecation, Sunset and Link headers to deprecated routes and
+// counts their calls so removals can be planned
+type DeprecationTracker struct {
+	routes chi.Routes
+	logger *zap.Logger
+
+	mu    sync.Mutex
+	usage map[string]*DeprecationUsage // by "METHOD pattern"
+	table map[string]Deprecation
+}
+
+// NewDeprecationTracker creates a tracker matching requests against routes
+func NewDeprecationTracker(routes chi.Routes, deprecations []Deprecation, logger *zap.Logger) *DeprecationTracker {
+	t := &DeprecationTracker{
+		routes: routes,
+		logger: logger,
+		usage:  make(map[string]*DeprecationUsage),
+		table:  make(map[string]Deprecation),
+	}
+	for _, d := range deprecations {
+		t.table[deprecationKey(d.Method, d.Pattern)] = d
+	}
+	return t
+}
+
+// Middleware must be mounted ahead of the routes it covers
+func (t *DeprecationTracker) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(t.table) > 0 {
+			if d, ok := t.match(r); ok {
+				t.record(d, r)
+				setDeprecationHeaders(w.Header(), d)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Usage returns call counts for every deprecated route, most called first
+func (t *DeprecationTracker) Usage() []DeprecationUsage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	usage := make([]DeprecationUsage, 0, len(t.table))
+	for key, d := range t.table {
+		entry := DeprecationUsage{
+			Method:    d.Method,
+			Pattern:   d.Pattern,
+			Since:     d.Since,
+			Successor: d.Successor,
+			Note:      d.Note,
+		}
+		if !d.Sunset.IsZero() {
+			sunset := d.Sunset
+			entry.Sunset = &sunset
+		}
+		if counted, ok := t.usage[key]; ok {
+			entry.Calls = counted.Calls
+			entry.LastCalledAt = counted.LastCalledAt
+			entry.LastClient = counted.LastClient
+		}
+		usage = append(usage, entry)
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].Calls != usage[j].Calls {
+			return usage[i].Calls > usage[j].Calls
+		}
+		return usage[i].Pattern < usage[j].Pattern
+	})
+	return usage
+}
+
+// match resolves the request's route pattern and looks it up in the table
+func (t *DeprecationTracker) match(r *http.Request) (Deprecation, bool) {
+	rctx := chi.NewRouteContext()
+	if !t.routes.Match(rctx, r.Method, r.URL.Path) {
+		return Deprecation{}, false
+	}
+	pattern := rctx.RoutePattern()
+	if d, ok := t.table[deprecationKey(r.Method, pattern)]; ok {
+		return d, true
+	}
+	d, ok := t.table[deprecationKey("", pattern)]
+	return d, ok
+}
+
+func (t *DeprecationTracker) record(d Deprecation, r *http.Request) {
+	now := time.Now().UTC()
+	key := deprecationKey(d.Method, d.Pattern)
+
+	t.mu.Lock()
+	entry, ok := t.usage[key]
+	if !ok {
+		entry = &DeprecationUsage{}
+		t.usage[key] = entry
+	}
+	entry.Calls++
+	entry.LastCalledAt = &now
+	entry.LastClient = r.UserAgent()
+	first := entry.Calls == 1
+	t.mu.Unlock()
+
+	if first {
+		t.logger.Warn("Deprecated API route called",
+			zap.String("method", r.Method),
+			zap.String("pattern", d.Pattern),
+			zap.String("user_agent", r.UserAgent()),
+		)
+	}
+}
+
+// setDeprecationHeaders follows RFC 9745 (Deprecation) and RFC 8594 (Sunset)
+func setDeprecationHeaders(h http.Header, d Deprecation) {
+	h.Set("Deprecation", "@"+strconv.FormatInt(d.Since.Unix(), 10))
+	if !d.Sunset.IsZero() {
+		h.Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+	}
+	if d.Successor != "" {
+		h.Add("Link", "<"+d.Successor+`>; rel="successor-version"`)
+	}
+}
+
+func deprecationKey(method, pattern string) string {
+	return strings.ToUpper(method) + " " + pattern
+}
@@ -0,0 +1,90 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// APIKeyRepository keeps API keys in memory
+type APIKeyRepository struct {
+	store *Store
+}
+
+// NewAPIKeyRepository creates a new API key repository
+func NewAPIKeyRepository(store *Store) *APIKeyRepository {
+	return &APIKeyRepository{store: store}
+}
+
+// Create stores a new API key. Key hashes are unique.
+func (r *APIKeyRepository) Create(ctx context.Context, key *domain.APIKey) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, other := range r.store.apiKeys {
+		if other.ID == key.ID || other.Hash == key.Hash {
+			return fmt.Errorf("api key %w", domain.ErrConflict)
+		}
+	}
+	r.store.apiKeys[key.ID] = clone(key)
+	return nil
+}
+
+// GetByHash retrieves an API key by the hash of the key
+func (r *APIKeyRepository) GetByHash(ctx context.Context, hash string) (*domain.APIKey, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	for _, key := range r.store.apiKeys {
+		if key.Hash == hash {
+			return clone(key), nil
+		}
+	}
+	return nil, fmt.Errorf("api key %w", domain.ErrNotFound)
+}
+
+// ListByUser returns a user's API keys, newest first
+func (r *APIKeyRepository) ListByUser(ctx context.Context, userID uuid.UUID) ([]*domain.APIKey, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var keys []*domain.APIKey
+	for _, key := range r.store.apiKeys {
+		if key.UserID == userID {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.After(keys[j].CreatedAt)
+	})
+	return cloneAll(keys), nil
+}
+
+// Delete removes one of a user's API keys
+func (r *APIKeyRepository) Delete(ctx context.Context, userID, id uuid.UUID) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	key, ok := r.store.apiKeys[id]
+	if !ok || key.UserID != userID {
+		return fmt.Errorf("api key %w", domain.ErrNotFound)
+	}
+	delete(r.store.apiKeys, id)
+	return nil
+}
+
+// TouchLastUsed records when an API key was last used
+func (r *APIKeyRepository) TouchLastUsed(ctx context.Context, id uuid.UUID, at time.Time) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if key, ok := r.store.apiKeys[id]; ok {
+		key.LastUsedAt = &at
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// AppCollaboratorRepository keeps app collaborator grants in memory
+type AppCollaboratorRepository struct {
+	store *Store
+}
+
+// NewAppCollaboratorRepository creates a new app collaborator repository
+func NewAppCollaboratorRepository(store *Store) *AppCollaboratorRepository {
+	return &AppCollaboratorRepository{store: store}
+}
+
+// Create stores a new collaborator grant. A user has one grant per app.
+func (r *AppCollaboratorRepository) Create(ctx context.Context, c *domain.AppCollaborator) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	key := collaboratorKey{appID: c.AppID, userID: c.UserID}
+	if _, ok := r.store.collaborators[key]; ok {
+		return fmt.Errorf("app collaborator %w", domain.ErrConflict)
+	}
+	stored := clone(c)
+	stored.Email, stored.Name = "", ""
+	r.store.collaborators[key] = stored
+	return nil
+}
+
+// Update saves a collaborator's permission
+func (r *AppCollaboratorRepository) Update(ctx context.Context, c *domain.AppCollaborator) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stored, ok := r.store.collaborators[collaboratorKey{appID: c.AppID, userID: c.UserID}]
+	if !ok {
+		return fmt.Errorf("app collaborator %w", domain.ErrNotFound)
+	}
+	stored.Permission = c.Permission
+	stored.UpdatedAt = c.UpdatedAt
+	return nil
+}
+
+// Delete removes a user's access to an app
+func (r *AppCollaboratorRepository) Delete(ctx context.Context, appID, userID uuid.UUID) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	key := collaboratorKey{appID: appID, userID: userID}
+	if _, ok := r.store.collaborators[key]; !ok {
+		return fmt.Errorf("app collaborator %w", domain.ErrNotFound)
+	}
+	delete(r.store.collaborators, key)
+	return nil
+}
+
+// Get returns a user's grant on an app
+func (r *AppCollaboratorRepository) Get(ctx context.Context, appID, userID uuid.UUID) (*domain.AppCollaborator, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	c, ok := r.withUser(r.store.collaborators[collaboratorKey{appID: appID, userID: userID}])
+	if !ok {
+		return nil, fmt.Errorf("app collaborator %w", domain.ErrNotFound)
+	}
+	return c, nil
+}
+
+// ListForApp returns an app's collaborators, oldest first
+func (r *AppCollaboratorRepository) ListForApp(ctx context.Context, appID uuid.UUID) ([]*domain.AppCollaborator, error) {
+	return r.list(func(c *domain.AppCollaborator) bool { return c.AppID == appID }), nil
+}
+
+// ListForUser returns the apps a user collaborates on
+func (r *AppCollaboratorRepository) ListForUser(ctx context.Context, userID uuid.UUID) ([]*domain.AppCollaborator, error) {
+	return r.list(func(c *domain.AppCollaborator) bool { return c.UserID == userID }), nil
+}
+
+// list returns the collaborators matching a condition, oldest first
+func (r *AppCollaboratorRepository) list(match func(*domain.AppCollaborator) bool) []*domain.AppCollaborator {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	collaborators := make([]*domain.AppCollaborator, 0)
+	for _, stored := range r.store.collaborators {
+		if !match(stored) {
+			continue
+		}
+		if c, ok := r.withUser(stored); ok {
+			collaborators = append(collaborators, c)
+		}
+	}
+	sort.Slice(collaborators, func(i, j int) bool {
+		return collaborators[i].CreatedAt.Before(collaborators[j].CreatedAt)
+	})
+	return collaborators
+}
+
+// withUser returns a copy of a grant with the collaborator's email and name filled in.
+// Grants of deleted users are dropped, as the database does.
+func (r *AppCollaboratorRepository) withUser(stored *domain.AppCollaborator) (*domain.AppCollaborator, bool) {
+	if stored == nil {
+		return nil, false
+	}
+	user, ok := r.store.users[stored.UserID]
+	if !ok {
+		return nil, false
+	}
+	c := clone(stored)
+	c.Email = user.Email
+	c.Name = user.Name
+	return c, true
+}
@@ -0,0 +1,115 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// AppLogRepository keeps shipped container logs in memory
+type AppLogRepository struct {
+	store *Store
+}
+
+// NewAppLogRepository creates a new app log repository
+func NewAppLogRepository(store *Store) *AppLogRepository {
+	return &AppLogRepository{store: store}
+}
+
+// Insert stores a batch of log lines
+func (r *AppLogRepository) Insert(ctx context.Context, entries []domain.AppLogEntry) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.store.appLogs = append(r.store.appLogs, entries...)
+	return nil
+}
+
+// EnsurePartition does nothing; lines are not partitioned in memory
+func (r *AppLogRepository) EnsurePartition(ctx context.Context, day time.Time) error {
+	return nil
+}
+
+// DropPartitionsBefore drops the lines of the UTC days ending at or before the cutoff
+// and returns how many days were dropped
+func (r *AppLogRepository) DropPartitionsBefore(ctx context.Context, cutoff time.Time) (int, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	dropped := make(map[time.Time]bool)
+	kept := r.store.appLogs[:0]
+	for _, e := range r.store.appLogs {
+		day := e.Timestamp.UTC().Truncate(24 * time.Hour)
+		if !day.AddDate(0, 0, 1).After(cutoff) {
+			dropped[day] = true
+			continue
+		}
+		kept = append(kept, e)
+	}
+	r.store.appLogs = kept
+	return len(dropped), nil
+}
+
+// Search returns an app's stored log lines matching the query, newest first
+func (r *AppLogRepository) Search(ctx context.Context, q domain.AppLogQuery) ([]domain.AppLogEntry, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	search := strings.ToLower(q.Search)
+	entries := make([]domain.AppLogEntry, 0)
+	for _, e := range r.store.appLogs {
+		switch {
+		case e.AppID != q.AppID,
+			!q.From.IsZero() && e.Timestamp.Before(q.From),
+			!q.To.IsZero() && !e.Timestamp.Before(q.To),
+			q.Stream != "" && e.Stream != q.Stream,
+			search != "" && !strings.Contains(strings.ToLower(e.Message), search):
+			continue
+		}
+		entries = append(entries, e)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Timestamp.After(entries[j].Timestamp)
+	})
+	return page(entries, q.Limit, 0), nil
+}
+
+// ShippedUntil returns the time of the newest line stored for a container, zero if none was
+func (r *AppLogRepository) ShippedUntil(ctx context.Context, containerID string) (time.Time, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	return r.store.appLogCursors[containerID], nil
+}
+
+// AdvanceCursors records the newest line stored for each container. Cursors never move back.
+func (r *AppLogRepository) AdvanceCursors(ctx context.Context, cursors map[string]time.Time) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for containerID, until := range cursors {
+		if until.After(r.store.appLogCursors[containerID]) {
+			r.store.appLogCursors[containerID] = until
+		}
+	}
+	return nil
+}
+
+// DropCursorsBefore forgets the containers whose newest stored line is older than the
+// cutoff and returns how many were forgotten
+func (r *AppLogRepository) DropCursorsBefore(ctx context.Context, cutoff time.Time) (int, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	dropped := 0
+	for containerID, until := range r.store.appLogCursors {
+		if until.Before(cutoff) {
+			delete(r.store.appLogCursors, containerID)
+			dropped++
+		}
+	}
+	return dropped, nil
+}
@@ -0,0 +1,239 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/google/uuid"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// AppRepository keeps apps in memory
+type AppRepository struct {
+	store *Store
+}
+
+// NewAppRepository creates a new app repository
+func NewAppRepository(store *Store) *AppRepository {
+	return &AppRepository{store: store}
+}
+
+// Create stores a new app. Slugs are unique.
+func (r *AppRepository) Create(ctx context.Context, app *domain.App) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.apps[app.ID]; ok || r.slugTaken(app) {
+		return fmt.Errorf("app %w", domain.ErrConflict)
+	}
+	r.store.apps[app.ID] = r.stored(app)
+	return nil
+}
+
+// stored returns the copy of an app the store keeps, with its deploy trigger resolved
+// as the database column is
+func (r *AppRepository) stored(app *domain.App) *domain.App {
+	c := clone(app)
+	c.DeployTrigger = app.EffectiveDeployTrigger()
+	return c
+}
+
+// slugTaken reports whether another app has the app's slug
+func (r *AppRepository) slugTaken(app *domain.App) bool {
+	for _, other := range r.store.apps {
+		if other.ID != app.ID && other.Slug == app.Slug {
+			return true
+		}
+	}
+	return false
+}
+
+// GetByID retrieves an app by ID
+func (r *AppRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.App, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	app, ok := r.store.apps[id]
+	if !ok {
+		return nil, fmt.Errorf("app %w", domain.ErrNotFound)
+	}
+	return clone(app), nil
+}
+
+// Update updates an app
+func (r *AppRepository) Update(ctx context.Context, app *domain.App) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.apps[app.ID]; !ok {
+		return fmt.Errorf("app %w", domain.ErrNotFound)
+	}
+	if r.slugTaken(app) {
+		return fmt.Errorf("app %w", domain.ErrConflict)
+	}
+	r.store.apps[app.ID] = r.stored(app)
+	return nil
+}
+
+// Delete deletes an app with its builds and their logs, tokens, incidents, domains,
+// grants, channels and shipped logs, as the database's foreign keys do
+func (r *AppRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.apps[id]; !ok {
+		return fmt.Errorf("app %w", domain.ErrNotFound)
+	}
+	delete(r.store.apps, id)
+
+	for buildID, b := range r.store.builds {
+		if b.AppID == id {
+			delete(r.store.builds, buildID)
+			delete(r.store.buildLogs, buildID)
+		}
+	}
+	deleteWhere(r.store.deployTokens, func(t *domain.DeployToken) bool { return t.AppID == id })
+	deleteWhere(r.store.incidents, func(inc *domain.Incident) bool { return inc.AppID == id })
+	deleteWhere(r.store.customDomains, func(d *domain.CustomDomain) bool { return d.AppID == id })
+	deleteWhere(r.store.collaborators, func(c *domain.AppCollaborator) bool { return c.AppID == id })
+	deleteWhere(r.store.notificationChannels, func(c *domain.NotificationChannel) bool {
+		return c.AppID != nil && *c.AppID == id
+	})
+	logs := r.store.appLogs[:0]
+	for _, e := range r.store.appLogs {
+		if e.AppID != id {
+			logs = append(logs, e)
+		}
+	}
+	r.store.appLogs = logs
+	return nil
+}
+
+// SetEnvVars sets environment variables in place, leaving the app's other variables
+// and fields as they are in the store
+func (r *AppRepository) SetEnvVars(ctx context.Context, id uuid.UUID, envVars map[string]string) error {
+	return r.updateEnv(id, func(env map[string]string) {
+		for k, v := range envVars {
+			env[k] = v
+		}
+	})
+}
+
+// DeleteEnvVar removes one environment variable in place
+func (r *AppRepository) DeleteEnvVar(ctx context.Context, id uuid.UUID, key string) error {
+	return r.updateEnv(id, func(env map[string]string) { delete(env, key) })
+}
+
+// EnsureWebhookSecret stores a sealed webhook secret for an app that has none, and
+// returns the secret the app keeps: this one, or the one stored first
+func (r *AppRepository) EnsureWebhookSecret(ctx context.Context, id uuid.UUID, sealed []byte) ([]byte, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	app, ok := r.store.apps[id]
+	if !ok {
+		return nil, fmt.Errorf("app %w", domain.ErrNotFound)
+	}
+	if len(app.WebhookSecret) == 0 {
+		app.WebhookSecret = slices.Clone(sealed)
+		app.UpdatedAt = now()
+	}
+	return slices.Clone(app.WebhookSecret), nil
+}
+
+// updateEnv changes a stored app's environment variables
+func (r *AppRepository) updateEnv(id uuid.UUID, change func(env map[string]string)) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	app, ok := r.store.apps[id]
+	if !ok {
+		return fmt.Errorf("app %w", domain.ErrNotFound)
+	}
+	if app.EnvVars == nil {
+		app.EnvVars = make(map[string]string)
+	}
+	change(app.EnvVars)
+	app.UpdatedAt = now()
+	return nil
+}
+
+// ListByLabel returns the apps with a label, oldest first. An empty value matches the
+// label with any value.
+func (r *AppRepository) ListByLabel(ctx context.Context, key, value string) ([]*domain.App, error) {
+	return r.list(func(app *domain.App) bool {
+		v, ok := app.Labels[key]
+		return ok && (value == "" || v == value)
+	}), nil
+}
+
+// ListOverview returns every app with its latest build, by name. Deployments aren't
+// kept in memory, so none has a latest deployment.
+func (r *AppRepository) ListOverview(ctx context.Context) ([]*domain.AppOverview, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	latest := make(map[uuid.UUID]*domain.Build)
+	for _, b := range r.store.builds {
+		if l := latest[b.AppID]; l == nil || b.CreatedAt.After(l.CreatedAt) {
+			latest[b.AppID] = b
+		}
+	}
+
+	overview := make([]*domain.AppOverview, 0, len(r.store.apps))
+	for _, app := range r.store.apps {
+		o := &domain.AppOverview{
+			AppID:          app.ID,
+			Name:           app.Name,
+			Slug:           app.Slug,
+			Status:         app.Status,
+			Replicas:       app.Replicas,
+			TargetReplicas: app.TargetReplicas,
+			UpdatedAt:      app.UpdatedAt,
+		}
+		if b := latest[app.ID]; b != nil {
+			o.LatestBuild = clone(b).Summary()
+		}
+		overview = append(overview, o)
+	}
+	sort.Slice(overview, func(i, j int) bool {
+		if overview[i].Name != overview[j].Name {
+			return overview[i].Name < overview[j].Name
+		}
+		return overview[i].AppID.String() < overview[j].AppID.String()
+	})
+	return overview, nil
+}
+
+// ListAutoDeployByRepo returns the apps that auto-deploy from a repository, oldest
+// first. repoKey is the repository's domain.GitRepoKey.
+func (r *AppRepository) ListAutoDeployByRepo(ctx context.Context, repoKey string) ([]*domain.App, error) {
+	return r.list(func(app *domain.App) bool {
+		return app.AutoDeploy && repoKey != "" && domain.GitRepoKey(app.GitRepoURL) == repoKey
+	}), nil
+}
+
+// ListAll retrieves every app, oldest first
+func (r *AppRepository) ListAll(ctx context.Context) ([]*domain.App, error) {
+	return r.list(func(*domain.App) bool { return true }), nil
+}
+
+// list returns the apps matching a condition, oldest first
+func (r *AppRepository) list(match func(*domain.App) bool) []*domain.App {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var apps []*domain.App
+	for _, app := range r.store.apps {
+		if match(app) {
+			apps = append(apps, app)
+		}
+	}
+	sort.Slice(apps, func(i, j int) bool {
+		return apps[i].CreatedAt.Before(apps[j].CreatedAt)
+	})
+	return cloneAll(apps)
+}
@@ -0,0 +1,53 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// BuildLogRepository keeps the full logs of builds in memory
+type BuildLogRepository struct {
+	store *Store
+}
+
+// NewBuildLogRepository creates a new build log repository
+func NewBuildLogRepository(store *Store) *BuildLogRepository {
+	return &BuildLogRepository{store: store}
+}
+
+// Append stores a chunk of a build's log; the build must be stored
+func (r *BuildLogRepository) Append(ctx context.Context, buildID uuid.UUID, lines []domain.BuildLogLine) error {
+	if len(lines) == 0 {
+		return nil
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.builds[buildID]; !ok {
+		return fmt.Errorf("build %w", domain.ErrNotFound)
+	}
+	r.store.buildLogs[buildID] = append(r.store.buildLogs[buildID], lines...)
+	return nil
+}
+
+// List returns up to limit lines of a build's log after the given line number, in order
+func (r *BuildLogRepository) List(ctx context.Context, buildID uuid.UUID, after, limit int) ([]domain.BuildLogLine, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	lines := []domain.BuildLogLine{}
+	for _, l := range r.store.buildLogs[buildID] {
+		if len(lines) == limit {
+			break
+		}
+		if l.Line > after {
+			lines = append(lines, l)
+		}
+	}
+	return lines, nil
+}
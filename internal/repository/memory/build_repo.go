@@ -0,0 +1,83 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// BuildRepository keeps builds in memory
+type BuildRepository struct {
+	store *Store
+}
+
+// NewBuildRepository creates a new build repository
+func NewBuildRepository(store *Store) *BuildRepository {
+	return &BuildRepository{store: store}
+}
+
+// Create stores a new build
+func (r *BuildRepository) Create(ctx context.Context, build *domain.Build) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.builds[build.ID]; ok {
+		return fmt.Errorf("build %w", domain.ErrConflict)
+	}
+	r.store.builds[build.ID] = clone(build)
+	return nil
+}
+
+// Save stores a build, or updates its status, image and outcome if it is already stored
+func (r *BuildRepository) Save(ctx context.Context, build *domain.Build) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	saved := clone(build)
+	if current, ok := r.store.builds[build.ID]; ok {
+		updated := clone(current)
+		updated.Status = saved.Status
+		updated.GitCommit = saved.GitCommit
+		updated.ImageTag = saved.ImageTag
+		updated.ImageID = saved.ImageID
+		updated.ErrorMessage = saved.ErrorMessage
+		updated.StartedAt = saved.StartedAt
+		updated.CompletedAt = saved.CompletedAt
+		saved = updated
+	}
+	r.store.builds[build.ID] = saved
+	return nil
+}
+
+// GetByID retrieves a build by ID
+func (r *BuildRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Build, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	build, ok := r.store.builds[id]
+	if !ok {
+		return nil, fmt.Errorf("build %w", domain.ErrNotFound)
+	}
+	return clone(build), nil
+}
+
+// ListByApp retrieves a page of an app's builds, newest first
+func (r *BuildRepository) ListByApp(ctx context.Context, appID uuid.UUID, limit, offset int) ([]*domain.Build, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var builds []*domain.Build
+	for _, build := range r.store.builds {
+		if build.AppID == appID {
+			builds = append(builds, build)
+		}
+	}
+	sort.Slice(builds, func(i, j int) bool {
+		return builds[i].CreatedAt.After(builds[j].CreatedAt)
+	})
+	return cloneAll(page(builds, limit, offset)), nil
+}
@@ -0,0 +1,86 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// CertificateRepository keeps ACME accounts and issued certificates in memory
+type CertificateRepository struct {
+	store *Store
+}
+
+// NewCertificateRepository creates a new certificate repository
+func NewCertificateRepository(store *Store) *CertificateRepository {
+	return &CertificateRepository{store: store}
+}
+
+// GetAccount retrieves the ACME account registered for an email, or nil if none exists
+func (r *CertificateRepository) GetAccount(ctx context.Context, email string) (*domain.ACMEAccount, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+	return clone(r.store.acmeAccounts[email]), nil
+}
+
+// SaveAccount stores an ACME account
+func (r *CertificateRepository) SaveAccount(ctx context.Context, account *domain.ACMEAccount) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	saved := clone(account)
+	if existing, ok := r.store.acmeAccounts[account.Email]; ok {
+		saved.CreatedAt = existing.CreatedAt
+	}
+	r.store.acmeAccounts[account.Email] = saved
+	return nil
+}
+
+// GetCertificate retrieves a certificate by primary domain, or nil if none exists
+func (r *CertificateRepository) GetCertificate(ctx context.Context, primary string) (*domain.Certificate, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+	return clone(r.store.certificates[primary]), nil
+}
+
+// SaveCertificate stores a newly issued or renewed certificate
+func (r *CertificateRepository) SaveCertificate(ctx context.Context, cert *domain.Certificate) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	saved := clone(cert)
+	if existing, ok := r.store.certificates[cert.Domain]; ok {
+		saved.CreatedAt = existing.CreatedAt
+	}
+	r.store.certificates[cert.Domain] = saved
+	return nil
+}
+
+// ListCertificates returns every stored certificate, soonest to expire first
+func (r *CertificateRepository) ListCertificates(ctx context.Context) ([]*domain.Certificate, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	certs := make([]*domain.Certificate, 0, len(r.store.certificates))
+	for _, cert := range r.store.certificates {
+		certs = append(certs, cert)
+	}
+	sort.Slice(certs, func(i, j int) bool {
+		return certs[i].NotAfter.Before(certs[j].NotAfter)
+	})
+	return cloneAll(certs), nil
+}
+
+// DeleteCertificate removes a certificate by primary domain
+func (r *CertificateRepository) DeleteCertificate(ctx context.Context, primary string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.certificates[primary]; !ok {
+		return fmt.Errorf("certificate %w", domain.ErrNotFound)
+	}
+	delete(r.store.certificates, primary)
+	return nil
+}
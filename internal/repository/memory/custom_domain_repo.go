@@ -0,0 +1,91 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// CustomDomainRepository keeps apps' custom domains in memory
+type CustomDomainRepository struct {
+	store *Store
+}
+
+// NewCustomDomainRepository creates a new custom domain repository
+func NewCustomDomainRepository(store *Store) *CustomDomainRepository {
+	return &CustomDomainRepository{store: store}
+}
+
+// Create stores a new custom domain. A host name already verified, or already claimed
+// by the same app, fails with domain.ErrConflict.
+func (r *CustomDomainRepository) Create(ctx context.Context, d *domain.CustomDomain) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, other := range r.store.customDomains {
+		if other.ID == d.ID || strings.EqualFold(other.Hostname, d.Hostname) && (other.AppID == d.AppID || other.IsVerified()) {
+			return fmt.Errorf("custom domain %w", domain.ErrConflict)
+		}
+	}
+	r.store.customDomains[d.ID] = clone(d)
+	return nil
+}
+
+// Update saves a custom domain's verification state. Verifying a host name another app
+// has verified fails with domain.ErrConflict.
+func (r *CustomDomainRepository) Update(ctx context.Context, d *domain.CustomDomain) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stored, ok := r.store.customDomains[d.ID]
+	if !ok {
+		return fmt.Errorf("custom domain %w", domain.ErrNotFound)
+	}
+	if d.IsVerified() {
+		for _, other := range r.store.customDomains {
+			if other.ID != d.ID && other.IsVerified() && strings.EqualFold(other.Hostname, stored.Hostname) {
+				return fmt.Errorf("custom domain %w", domain.ErrConflict)
+			}
+		}
+	}
+	updated := clone(d)
+	stored.Status = updated.Status
+	stored.LastError = updated.LastError
+	stored.LastCheckedAt = updated.LastCheckedAt
+	stored.VerifiedAt = updated.VerifiedAt
+	return nil
+}
+
+// Delete removes a custom domain
+func (r *CustomDomainRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.customDomains[id]; !ok {
+		return fmt.Errorf("custom domain %w", domain.ErrNotFound)
+	}
+	delete(r.store.customDomains, id)
+	return nil
+}
+
+// ListForApp returns an app's custom domains, oldest first
+func (r *CustomDomainRepository) ListForApp(ctx context.Context, appID uuid.UUID) ([]*domain.CustomDomain, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var domains []*domain.CustomDomain
+	for _, d := range r.store.customDomains {
+		if d.AppID == appID {
+			domains = append(domains, d)
+		}
+	}
+	sort.Slice(domains, func(i, j int) bool {
+		return domains[i].CreatedAt.Before(domains[j].CreatedAt)
+	})
+	return cloneAll(domains), nil
+}
@@ -0,0 +1,90 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// DeployTokenRepository keeps deploy tokens in memory
+type DeployTokenRepository struct {
+	store *Store
+}
+
+// NewDeployTokenRepository creates a new deploy token repository
+func NewDeployTokenRepository(store *Store) *DeployTokenRepository {
+	return &DeployTokenRepository{store: store}
+}
+
+// Create stores a new deploy token. Token hashes are unique.
+func (r *DeployTokenRepository) Create(ctx context.Context, t *domain.DeployToken) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, other := range r.store.deployTokens {
+		if other.ID == t.ID || other.Hash == t.Hash {
+			return fmt.Errorf("deploy token %w", domain.ErrConflict)
+		}
+	}
+	r.store.deployTokens[t.ID] = clone(t)
+	return nil
+}
+
+// GetByHash retrieves a deploy token by the hash of the token
+func (r *DeployTokenRepository) GetByHash(ctx context.Context, hash string) (*domain.DeployToken, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	for _, t := range r.store.deployTokens {
+		if t.Hash == hash {
+			return clone(t), nil
+		}
+	}
+	return nil, fmt.Errorf("deploy token %w", domain.ErrNotFound)
+}
+
+// ListByApp returns an app's deploy tokens, newest first
+func (r *DeployTokenRepository) ListByApp(ctx context.Context, appID uuid.UUID) ([]*domain.DeployToken, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var tokens []*domain.DeployToken
+	for _, t := range r.store.deployTokens {
+		if t.AppID == appID {
+			tokens = append(tokens, t)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].CreatedAt.After(tokens[j].CreatedAt)
+	})
+	return cloneAll(tokens), nil
+}
+
+// Delete removes one of an app's deploy tokens
+func (r *DeployTokenRepository) Delete(ctx context.Context, appID, id uuid.UUID) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	t, ok := r.store.deployTokens[id]
+	if !ok || t.AppID != appID {
+		return fmt.Errorf("deploy token %w", domain.ErrNotFound)
+	}
+	delete(r.store.deployTokens, id)
+	return nil
+}
+
+// TouchLastUsed records when a deploy token was last used
+func (r *DeployTokenRepository) TouchLastUsed(ctx context.Context, id uuid.UUID, at time.Time) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if t, ok := r.store.deployTokens[id]; ok {
+		t.LastUsedAt = &at
+	}
+	return nil
+}
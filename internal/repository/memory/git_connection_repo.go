@@ -0,0 +1,77 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// GitConnectionRepository keeps users' git provider connections in memory
+type GitConnectionRepository struct {
+	store *Store
+}
+
+// NewGitConnectionRepository creates a new git connection repository
+func NewGitConnectionRepository(store *Store) *GitConnectionRepository {
+	return &GitConnectionRepository{store: store}
+}
+
+// Save creates or replaces a user's connection to a provider
+func (r *GitConnectionRepository) Save(ctx context.Context, conn *domain.GitConnection) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	key := gitConnectionKey{userID: conn.UserID, provider: conn.Provider}
+	saved := clone(conn)
+	if existing, ok := r.store.gitConnections[key]; ok {
+		saved.CreatedAt = existing.CreatedAt
+	}
+	r.store.gitConnections[key] = saved
+	return nil
+}
+
+// Get returns a user's connection to a provider
+func (r *GitConnectionRepository) Get(ctx context.Context, userID uuid.UUID, provider string) (*domain.GitConnection, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	conn, ok := r.store.gitConnections[gitConnectionKey{userID: userID, provider: provider}]
+	if !ok {
+		return nil, fmt.Errorf("git connection %w", domain.ErrNotFound)
+	}
+	return clone(conn), nil
+}
+
+// ListByUser returns a user's connections
+func (r *GitConnectionRepository) ListByUser(ctx context.Context, userID uuid.UUID) ([]*domain.GitConnection, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var conns []*domain.GitConnection
+	for key, conn := range r.store.gitConnections {
+		if key.userID == userID {
+			conns = append(conns, conn)
+		}
+	}
+	sort.Slice(conns, func(i, j int) bool {
+		return conns[i].Provider < conns[j].Provider
+	})
+	return cloneAll(conns), nil
+}
+
+// Delete removes a user's connection to a provider
+func (r *GitConnectionRepository) Delete(ctx context.Context, userID uuid.UUID, provider string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	key := gitConnectionKey{userID: userID, provider: provider}
+	if _, ok := r.store.gitConnections[key]; !ok {
+		return fmt.Errorf("git connection %w", domain.ErrNotFound)
+	}
+	delete(r.store.gitConnections, key)
+	return nil
+}
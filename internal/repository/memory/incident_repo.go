@@ -0,0 +1,84 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// IncidentRepository keeps incidents in memory
+type IncidentRepository struct {
+	store *Store
+}
+
+// NewIncidentRepository creates a new incident repository
+func NewIncidentRepository(store *Store) *IncidentRepository {
+	return &IncidentRepository{store: store}
+}
+
+// Create stores a new incident. An app has at most one open incident.
+func (r *IncidentRepository) Create(ctx context.Context, inc *domain.Incident) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, other := range r.store.incidents {
+		if other.ID == inc.ID || (inc.Status == domain.IncidentStatusOpen && other.AppID == inc.AppID && other.Status == domain.IncidentStatusOpen) {
+			return fmt.Errorf("incident %w", domain.ErrConflict)
+		}
+	}
+	r.store.incidents[inc.ID] = clone(inc)
+	return nil
+}
+
+// Update saves an incident's status, diagnostics and timeline
+func (r *IncidentRepository) Update(ctx context.Context, inc *domain.Incident) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stored, ok := r.store.incidents[inc.ID]
+	if !ok {
+		return fmt.Errorf("incident %w", domain.ErrNotFound)
+	}
+	updated := clone(inc)
+	stored.Status = updated.Status
+	stored.Lockdown = updated.Lockdown
+	stored.PinnedDeploymentID = updated.PinnedDeploymentID
+	stored.Diagnostics = updated.Diagnostics
+	stored.Timeline = updated.Timeline
+	stored.ResolvedBy = updated.ResolvedBy
+	stored.ResolvedAt = updated.ResolvedAt
+	return nil
+}
+
+// GetByID retrieves an incident by ID
+func (r *IncidentRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Incident, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	inc, ok := r.store.incidents[id]
+	if !ok {
+		return nil, fmt.Errorf("incident %w", domain.ErrNotFound)
+	}
+	return clone(inc), nil
+}
+
+// ListForApp returns an app's incidents, newest first
+func (r *IncidentRepository) ListForApp(ctx context.Context, appID uuid.UUID, limit int) ([]*domain.Incident, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var incidents []*domain.Incident
+	for _, inc := range r.store.incidents {
+		if inc.AppID == appID {
+			incidents = append(incidents, inc)
+		}
+	}
+	sort.Slice(incidents, func(i, j int) bool {
+		return incidents[i].OpenedAt.After(incidents[j].OpenedAt)
+	})
+	return cloneAll(page(incidents, limit, 0)), nil
+}
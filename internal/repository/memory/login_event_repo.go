@@ -0,0 +1,46 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/google/uuid"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// LoginEventRepository keeps the sign-in audit trail in memory
+type LoginEventRepository struct {
+	store *Store
+}
+
+// NewLoginEventRepository creates a new login event repository
+func NewLoginEventRepository(store *Store) *LoginEventRepository {
+	return &LoginEventRepository{store: store}
+}
+
+// Create records a sign-in attempt
+func (r *LoginEventRepository) Create(ctx context.Context, e *domain.LoginEvent) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.store.loginEvents = append(r.store.loginEvents, clone(e))
+	return nil
+}
+
+// ListByUser returns a page of a user's login events, newest first, and how many there are
+func (r *LoginEventRepository) ListByUser(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*domain.LoginEvent, int, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var events []*domain.LoginEvent
+	for _, e := range r.store.loginEvents {
+		if e.UserID == userID {
+			events = append(events, e)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].CreatedAt.After(events[j].CreatedAt)
+	})
+	return cloneAll(page(events, limit, offset)), len(events), nil
+}
@@ -0,0 +1,106 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// NotificationChannelRepository keeps outgoing notification channels in memory
+type NotificationChannelRepository struct {
+	store *Store
+}
+
+// NewNotificationChannelRepository creates a new notification channel repository
+func NewNotificationChannelRepository(store *Store) *NotificationChannelRepository {
+	return &NotificationChannelRepository{store: store}
+}
+
+// Create stores a new notification channel
+func (r *NotificationChannelRepository) Create(ctx context.Context, c *domain.NotificationChannel) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.notificationChannels[c.ID]; ok {
+		return fmt.Errorf("notification channel %w", domain.ErrConflict)
+	}
+	r.store.notificationChannels[c.ID] = clone(c)
+	return nil
+}
+
+// Update saves a channel's name, events and enabled flag
+func (r *NotificationChannelRepository) Update(ctx context.Context, c *domain.NotificationChannel) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stored, ok := r.store.notificationChannels[c.ID]
+	if !ok {
+		return fmt.Errorf("notification channel %w", domain.ErrNotFound)
+	}
+	stored.Name = c.Name
+	stored.Events = append([]domain.NotificationEvent(nil), c.Events...)
+	stored.Enabled = c.Enabled
+	return nil
+}
+
+// Delete removes a notification channel
+func (r *NotificationChannelRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.notificationChannels[id]; !ok {
+		return fmt.Errorf("notification channel %w", domain.ErrNotFound)
+	}
+	delete(r.store.notificationChannels, id)
+	return nil
+}
+
+// Get returns a notification channel by ID
+func (r *NotificationChannelRepository) Get(ctx context.Context, id uuid.UUID) (*domain.NotificationChannel, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	c, ok := r.store.notificationChannels[id]
+	if !ok {
+		return nil, fmt.Errorf("notification channel %w", domain.ErrNotFound)
+	}
+	return clone(c), nil
+}
+
+// List returns an app's own channels, or the global channels when appID is nil, oldest first
+func (r *NotificationChannelRepository) List(ctx context.Context, appID *uuid.UUID) ([]*domain.NotificationChannel, error) {
+	return r.list(func(c *domain.NotificationChannel) bool {
+		if appID == nil || c.AppID == nil {
+			return appID == nil && c.AppID == nil
+		}
+		return *c.AppID == *appID
+	}), nil
+}
+
+// ListForApp returns the channels that receive an app's events: its own and the global ones
+func (r *NotificationChannelRepository) ListForApp(ctx context.Context, appID uuid.UUID) ([]*domain.NotificationChannel, error) {
+	return r.list(func(c *domain.NotificationChannel) bool {
+		return c.AppID == nil || *c.AppID == appID
+	}), nil
+}
+
+// list returns the channels matching a condition, oldest first
+func (r *NotificationChannelRepository) list(match func(*domain.NotificationChannel) bool) []*domain.NotificationChannel {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var channels []*domain.NotificationChannel
+	for _, c := range r.store.notificationChannels {
+		if match(c) {
+			channels = append(channels, c)
+		}
+	}
+	sort.Slice(channels, func(i, j int) bool {
+		return channels[i].CreatedAt.Before(channels[j].CreatedAt)
+	})
+	return cloneAll(channels)
+}
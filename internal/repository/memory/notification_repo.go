@@ -0,0 +1,92 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/google/uuid"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// NotificationRepository keeps users' notification inboxes in memory
+type NotificationRepository struct {
+	store *Store
+}
+
+// NewNotificationRepository creates a new notification repository
+func NewNotificationRepository(store *Store) *NotificationRepository {
+	return &NotificationRepository{store: store}
+}
+
+// Create stores a notification
+func (r *NotificationRepository) Create(ctx context.Context, n *domain.Notification) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.store.notifications[n.ID] = clone(n)
+	return nil
+}
+
+// ListForUser returns a user's notifications, newest first
+func (r *NotificationRepository) ListForUser(ctx context.Context, userID uuid.UUID, unreadOnly bool, limit int) ([]*domain.Notification, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var notifications []*domain.Notification
+	for _, n := range r.store.notifications {
+		if n.UserID == userID && (!unreadOnly || n.ReadAt == nil) {
+			notifications = append(notifications, n)
+		}
+	}
+	sort.Slice(notifications, func(i, j int) bool {
+		return notifications[i].CreatedAt.After(notifications[j].CreatedAt)
+	})
+	return cloneAll(page(notifications, limit, 0)), nil
+}
+
+// CountUnread returns the number of unread notifications for a user
+func (r *NotificationRepository) CountUnread(ctx context.Context, userID uuid.UUID) (int64, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var count int64
+	for _, n := range r.store.notifications {
+		if n.UserID == userID && n.ReadAt == nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// MarkRead marks one of a user's notifications read, reporting whether it exists
+func (r *NotificationRepository) MarkRead(ctx context.Context, userID, id uuid.UUID) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	n, ok := r.store.notifications[id]
+	if !ok || n.UserID != userID {
+		return false, nil
+	}
+	if n.ReadAt == nil {
+		readAt := now()
+		n.ReadAt = &readAt
+	}
+	return true, nil
+}
+
+// MarkAllRead marks all of a user's notifications read and returns how many changed
+func (r *NotificationRepository) MarkAllRead(ctx context.Context, userID uuid.UUID) (int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	readAt := now()
+	var count int64
+	for _, n := range r.store.notifications {
+		if n.UserID == userID && n.ReadAt == nil {
+			n.ReadAt = &readAt
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,107 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// ProjectRepository keeps projects in memory
+type ProjectRepository struct {
+	store *Store
+}
+
+// NewProjectRepository creates a new project repository
+func NewProjectRepository(store *Store) *ProjectRepository {
+	return &ProjectRepository{store: store}
+}
+
+// Create stores a new project. Slugs are unique.
+func (r *ProjectRepository) Create(ctx context.Context, p *domain.Project) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, other := range r.store.projects {
+		if other.ID == p.ID || other.Slug == p.Slug {
+			return fmt.Errorf("project %w", domain.ErrConflict)
+		}
+	}
+	r.store.projects[p.ID] = clone(p)
+	return nil
+}
+
+// GetByID retrieves a project by ID
+func (r *ProjectRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Project, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	p, ok := r.store.projects[id]
+	if !ok {
+		return nil, fmt.Errorf("project %w", domain.ErrNotFound)
+	}
+	p = clone(p)
+	if p.EnvVars == nil {
+		p.EnvVars = make(map[string]string)
+	}
+	return p, nil
+}
+
+// Update saves a project's name, description, shared env vars and team
+func (r *ProjectRepository) Update(ctx context.Context, p *domain.Project) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stored, ok := r.store.projects[p.ID]
+	if !ok {
+		return fmt.Errorf("project %w", domain.ErrNotFound)
+	}
+	updated := clone(p)
+	stored.Name = updated.Name
+	stored.Description = updated.Description
+	stored.EnvVars = updated.EnvVars
+	stored.TeamID = updated.TeamID
+	stored.UpdatedAt = updated.UpdatedAt
+	return nil
+}
+
+// Delete removes a project; its apps leave it
+func (r *ProjectRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.projects[id]; !ok {
+		return fmt.Errorf("project %w", domain.ErrNotFound)
+	}
+	delete(r.store.projects, id)
+	for _, app := range r.store.apps {
+		if app.ProjectID != nil && *app.ProjectID == id {
+			app.ProjectID = nil
+		}
+	}
+	return nil
+}
+
+// ListAll returns every project, oldest first
+func (r *ProjectRepository) ListAll(ctx context.Context) ([]*domain.Project, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	projects := make([]*domain.Project, 0, len(r.store.projects))
+	for _, p := range r.store.projects {
+		projects = append(projects, p)
+	}
+	sort.Slice(projects, func(i, j int) bool {
+		return projects[i].CreatedAt.Before(projects[j].CreatedAt)
+	})
+	projects = cloneAll(projects)
+	for _, p := range projects {
+		if p.EnvVars == nil {
+			p.EnvVars = make(map[string]string)
+		}
+	}
+	return projects, nil
+}
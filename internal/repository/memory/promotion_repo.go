@@ -0,0 +1,45 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sort"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// PromotionRepository keeps the image promotion history in memory
+type PromotionRepository struct {
+	store *Store
+}
+
+// NewPromotionRepository creates a new promotion repository
+func NewPromotionRepository(store *Store) *PromotionRepository {
+	return &PromotionRepository{store: store}
+}
+
+// Create records a new image promotion
+func (r *PromotionRepository) Create(ctx context.Context, p *domain.ImagePromotion) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.store.promotions = append(r.store.promotions, clone(p))
+	return nil
+}
+
+// List returns promotions, optionally filtered by app slugs and environment, newest first
+func (r *PromotionRepository) List(ctx context.Context, appSlugs []string, environment string, limit int) ([]*domain.ImagePromotion, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var promotions []*domain.ImagePromotion
+	for _, p := range r.store.promotions {
+		if (appSlugs == nil || slices.Contains(appSlugs, p.AppSlug)) && (environment == "" || p.Environment == environment) {
+			promotions = append(promotions, p)
+		}
+	}
+	sort.SliceStable(promotions, func(i, j int) bool {
+		return promotions[i].CreatedAt.After(promotions[j].CreatedAt)
+	})
+	return cloneAll(page(promotions, limit, 0)), nil
+}
@@ -0,0 +1,251 @@
+// Package memory implements the repositories in process memory, for running NanoPaaS
+// without PostgreSQL in development and for unit tests of the services built on them.
+//
+// Each repository mirrors its PostgreSQL counterpart: the same methods, orderings and
+// domain errors. Records are deep-copied on the way in and out, so callers can't change
+// stored data without saving it, just as with a database. Nothing survives a restart.
+package memory
+
+import (
+	"context"
+	"maps"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// Store holds the records of every in-memory repository. Repositories created on the
+// same Store see each other's records, as tables of one database do.
+type Store struct {
+	mu sync.RWMutex
+	tx sync.Mutex // serializes InTx
+	tables
+}
+
+// tables holds the records of a Store
+type tables struct {
+	users                map[uuid.UUID]*domain.User
+	apps                 map[uuid.UUID]*domain.App
+	builds               map[uuid.UUID]*domain.Build
+	buildLogs            map[uuid.UUID][]domain.BuildLogLine
+	apiKeys              map[uuid.UUID]*domain.APIKey
+	deployTokens         map[uuid.UUID]*domain.DeployToken
+	loginEvents          []*domain.LoginEvent
+	userTokens           map[uuid.UUID]*domain.UserToken
+	identities           map[uuid.UUID]*domain.UserIdentity
+	gitConnections       map[gitConnectionKey]*domain.GitConnection
+	acmeAccounts         map[string]*domain.ACMEAccount
+	certificates         map[string]*domain.Certificate
+	notifications        map[uuid.UUID]*domain.Notification
+	notificationChannels map[uuid.UUID]*domain.NotificationChannel
+	incidents            map[uuid.UUID]*domain.Incident
+	customDomains        map[uuid.UUID]*domain.CustomDomain
+	projects             map[uuid.UUID]*domain.Project
+	collaborators        map[collaboratorKey]*domain.AppCollaborator
+	promotions           []*domain.ImagePromotion
+	appLogs              []domain.AppLogEntry
+	appLogCursors        map[string]time.Time
+}
+
+// gitConnectionKey identifies a user's connection to a provider
+type gitConnectionKey struct {
+	userID   uuid.UUID
+	provider string
+}
+
+// collaboratorKey identifies a user's grant on an app
+type collaboratorKey struct {
+	appID  uuid.UUID
+	userID uuid.UUID
+}
+
+// NewStore creates an empty store
+func NewStore() *Store {
+	return &Store{tables: tables{
+		users:                make(map[uuid.UUID]*domain.User),
+		apps:                 make(map[uuid.UUID]*domain.App),
+		builds:               make(map[uuid.UUID]*domain.Build),
+		buildLogs:            make(map[uuid.UUID][]domain.BuildLogLine),
+		apiKeys:              make(map[uuid.UUID]*domain.APIKey),
+		deployTokens:         make(map[uuid.UUID]*domain.DeployToken),
+		userTokens:           make(map[uuid.UUID]*domain.UserToken),
+		identities:           make(map[uuid.UUID]*domain.UserIdentity),
+		gitConnections:       make(map[gitConnectionKey]*domain.GitConnection),
+		acmeAccounts:         make(map[string]*domain.ACMEAccount),
+		certificates:         make(map[string]*domain.Certificate),
+		notifications:        make(map[uuid.UUID]*domain.Notification),
+		notificationChannels: make(map[uuid.UUID]*domain.NotificationChannel),
+		incidents:            make(map[uuid.UUID]*domain.Incident),
+		customDomains:        make(map[uuid.UUID]*domain.CustomDomain),
+		projects:             make(map[uuid.UUID]*domain.Project),
+		collaborators:        make(map[collaboratorKey]*domain.AppCollaborator),
+		appLogCursors:        make(map[string]time.Time),
+	}}
+}
+
+// InTx runs fn as a unit of work: when fn returns an error, every record is restored
+// to what it was before fn ran. Units of work run one at a time but are not isolated
+// from other writes, which a rollback also undoes; that is enough for development and
+// tests, not for concurrent users.
+func (s *Store) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	s.tx.Lock()
+	defer s.tx.Unlock()
+
+	s.mu.RLock()
+	snapshot := s.tables.clone()
+	s.mu.RUnlock()
+
+	if err := fn(ctx); err != nil {
+		s.mu.Lock()
+		s.tables = snapshot
+		s.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// clone deep-copies every table
+func (t *tables) clone() tables {
+	return tables{
+		users:                cloneMap(t.users),
+		apps:                 cloneMap(t.apps),
+		builds:               cloneMap(t.builds),
+		buildLogs:            cloneLogs(t.buildLogs),
+		apiKeys:              cloneMap(t.apiKeys),
+		deployTokens:         cloneMap(t.deployTokens),
+		loginEvents:          cloneAll(t.loginEvents),
+		userTokens:           cloneMap(t.userTokens),
+		identities:           cloneMap(t.identities),
+		gitConnections:       cloneMap(t.gitConnections),
+		acmeAccounts:         cloneMap(t.acmeAccounts),
+		certificates:         cloneMap(t.certificates),
+		notifications:        cloneMap(t.notifications),
+		notificationChannels: cloneMap(t.notificationChannels),
+		incidents:            cloneMap(t.incidents),
+		customDomains:        cloneMap(t.customDomains),
+		projects:             cloneMap(t.projects),
+		collaborators:        cloneMap(t.collaborators),
+		promotions:           cloneAll(t.promotions),
+		appLogs:              append([]domain.AppLogEntry(nil), t.appLogs...),
+		appLogCursors:        maps.Clone(t.appLogCursors),
+	}
+}
+
+// clone deep-copies a record, so the store and its callers never share maps, slices or
+// pointers
+func clone[T any](v *T) *T {
+	if v == nil {
+		return nil
+	}
+	c := new(T)
+	reflect.ValueOf(c).Elem().Set(copyValue(reflect.ValueOf(v).Elem()))
+	return c
+}
+
+// cloneAll deep-copies records in order, returning an empty rather than nil slice
+func cloneAll[T any](vs []*T) []*T {
+	out := make([]*T, len(vs))
+	for i, v := range vs {
+		out[i] = clone(v)
+	}
+	return out
+}
+
+// cloneMap deep-copies the records of a table
+func cloneMap[K comparable, T any](m map[K]*T) map[K]*T {
+	out := make(map[K]*T, len(m))
+	for k, v := range m {
+		out[k] = clone(v)
+	}
+	return out
+}
+
+// cloneLogs copies the build logs table; lines hold no references
+func cloneLogs(m map[uuid.UUID][]domain.BuildLogLine) map[uuid.UUID][]domain.BuildLogLine {
+	out := make(map[uuid.UUID][]domain.BuildLogLine, len(m))
+	for k, lines := range m {
+		out[k] = append([]domain.BuildLogLine(nil), lines...)
+	}
+	return out
+}
+
+// deleteWhere deletes the records of a table matching a condition
+func deleteWhere[K comparable, T any](m map[K]*T, match func(*T) bool) {
+	for k, v := range m {
+		if match(v) {
+			delete(m, k)
+		}
+	}
+}
+
+// copyValue returns a deep copy of v. Unexported struct fields, such as time.Time's,
+// are copied by value.
+func copyValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type().Elem())
+		c.Elem().Set(copyValue(v.Elem()))
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(iter.Key(), copyValue(iter.Value()))
+		}
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(copyValue(v.Index(i)))
+		}
+		return c
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if c.Field(i).CanSet() {
+				c.Field(i).Set(copyValue(v.Field(i)))
+			}
+		}
+		return c
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(copyValue(v.Elem()))
+		return c
+	default:
+		return v
+	}
+}
+
+// now returns the current time as the database would store it
+func now() time.Time {
+	return time.Now().UTC()
+}
+
+// page returns the items of a LIMIT/OFFSET page; limit 0 means no limit
+func page[T any](items []T, limit, offset int) []T {
+	if offset >= len(items) {
+		return items[:0]
+	}
+	items = items[max(offset, 0):]
+	if limit > 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
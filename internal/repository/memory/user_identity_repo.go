@@ -0,0 +1,47 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// UserIdentityRepository keeps OpenID Connect account links in memory
+type UserIdentityRepository struct {
+	store *Store
+}
+
+// NewUserIdentityRepository creates a new user identity repository
+func NewUserIdentityRepository(store *Store) *UserIdentityRepository {
+	return &UserIdentityRepository{store: store}
+}
+
+// Create links a provider account to a user. Each account links to one user.
+func (r *UserIdentityRepository) Create(ctx context.Context, identity *domain.UserIdentity) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, other := range r.store.identities {
+		if other.ID == identity.ID || (other.Issuer == identity.Issuer && other.Subject == identity.Subject) {
+			return fmt.Errorf("identity %w", domain.ErrConflict)
+		}
+	}
+	r.store.identities[identity.ID] = clone(identity)
+	return nil
+}
+
+// GetUserID returns the user linked to a provider account
+func (r *UserIdentityRepository) GetUserID(ctx context.Context, issuer, subject string) (uuid.UUID, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	for _, identity := range r.store.identities {
+		if identity.Issuer == issuer && identity.Subject == subject {
+			return identity.UserID, nil
+		}
+	}
+	return uuid.Nil, fmt.Errorf("identity %w", domain.ErrNotFound)
+}
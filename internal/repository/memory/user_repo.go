@@ -0,0 +1,260 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// UserRepository keeps users in memory
+type UserRepository struct {
+	store *Store
+}
+
+// NewUserRepository creates a new user repository
+func NewUserRepository(store *Store) *UserRepository {
+	return &UserRepository{store: store}
+}
+
+// Create stores a new user. Emails and GitHub IDs are unique.
+func (r *UserRepository) Create(ctx context.Context, user *domain.User) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.users[user.ID]; ok || r.conflicts(user) {
+		return fmt.Errorf("user %w", domain.ErrConflict)
+	}
+	r.store.users[user.ID] = clone(user)
+	return nil
+}
+
+// conflicts reports whether another user has the user's email or GitHub ID
+func (r *UserRepository) conflicts(user *domain.User) bool {
+	for _, other := range r.store.users {
+		if other.ID == user.ID {
+			continue
+		}
+		if other.Email == user.Email || (user.GitHubID != 0 && other.GitHubID == user.GitHubID) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetByID retrieves a user by ID
+func (r *UserRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.User, error) {
+	return r.getBy(func(u *domain.User) bool { return u.ID == id })
+}
+
+// GetByEmail retrieves a user by email, ignoring case
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
+	return r.getBy(func(u *domain.User) bool { return strings.EqualFold(u.Email, email) })
+}
+
+// GetByGitHubID retrieves a user by GitHub ID
+func (r *UserRepository) GetByGitHubID(ctx context.Context, githubID int64) (*domain.User, error) {
+	return r.getBy(func(u *domain.User) bool { return u.GitHubID == githubID })
+}
+
+// getBy retrieves the oldest user matching a condition
+func (r *UserRepository) getBy(match func(*domain.User) bool) (*domain.User, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var found *domain.User
+	for _, u := range r.store.users {
+		if match(u) && (found == nil || u.CreatedAt.Before(found.CreatedAt)) {
+			found = u
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("user %w", domain.ErrNotFound)
+	}
+	return clone(found), nil
+}
+
+// Update updates a user. The TOTP step is only moved by AdvanceTOTPStep, so a stale
+// copy of the user can't roll it back.
+func (r *UserRepository) Update(ctx context.Context, user *domain.User) error {
+	user.UpdatedAt = now()
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	current, ok := r.store.users[user.ID]
+	if !ok {
+		return fmt.Errorf("user %w", domain.ErrNotFound)
+	}
+	if r.conflicts(user) {
+		return fmt.Errorf("user %w", domain.ErrConflict)
+	}
+	updated := clone(user)
+	updated.TOTPLastStep = current.TOTPLastStep
+	updated.CreatedAt = current.CreatedAt
+	r.store.users[user.ID] = updated
+	return nil
+}
+
+// Delete deletes a user
+func (r *UserRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.users[id]; !ok {
+		return fmt.Errorf("user %w", domain.ErrNotFound)
+	}
+	delete(r.store.users, id)
+	return nil
+}
+
+// AdvanceTOTPStep records the time step of an accepted code. It returns false when a
+// code from that step or a later one was already accepted, i.e. the code is replayed.
+func (r *UserRepository) AdvanceTOTPStep(ctx context.Context, id uuid.UUID, step int64) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	user, ok := r.store.users[id]
+	if !ok || user.TOTPLastStep >= step {
+		return false, nil
+	}
+	user.TOTPLastStep = step
+	return true, nil
+}
+
+// ConsumeRecoveryCode removes a recovery code hash from the user's unused codes. It
+// returns false when the user has no such code.
+func (r *UserRepository) ConsumeRecoveryCode(ctx context.Context, id uuid.UUID, hash string) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	user, ok := r.store.users[id]
+	if !ok {
+		return false, nil
+	}
+	for i, code := range user.RecoveryCodes {
+		if code == hash {
+			user.RecoveryCodes = append(user.RecoveryCodes[:i:i], user.RecoveryCodes[i+1:]...)
+			user.UpdatedAt = now()
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// DeleteAndReassign deletes a user, handing the apps and projects they own to another
+// user first so deleting the account doesn't delete them
+func (r *UserRepository) DeleteAndReassign(ctx context.Context, id, newOwnerID uuid.UUID) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.users[id]; !ok {
+		return fmt.Errorf("user %w", domain.ErrNotFound)
+	}
+	updatedAt := now()
+	for _, app := range r.store.apps {
+		if app.OwnerID == id {
+			app.OwnerID = newOwnerID
+			app.UpdatedAt = updatedAt
+		}
+	}
+	for _, project := range r.store.projects {
+		if project.OwnerID == id {
+			project.OwnerID = newOwnerID
+			project.UpdatedAt = updatedAt
+		}
+	}
+	delete(r.store.users, id)
+	return nil
+}
+
+// Search returns a page of users matching the query and the number of matches
+func (r *UserRepository) Search(ctx context.Context, q domain.UserQuery) ([]*domain.User, int, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	search := strings.ToLower(q.Search)
+	var matches []*domain.User
+	for _, u := range r.store.users {
+		if search != "" && !strings.Contains(strings.ToLower(u.Name), search) && !strings.Contains(strings.ToLower(u.Email), search) {
+			continue
+		}
+		if q.Role != "" && u.Role != q.Role {
+			continue
+		}
+		if (q.Status == "active" && u.DeactivatedAt != nil) || (q.Status == "deactivated" && u.DeactivatedAt == nil) {
+			continue
+		}
+		matches = append(matches, u)
+	}
+
+	compare := userSortKeys[q.Sort]
+	if compare == nil {
+		compare = userSortKeys["created_at"]
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if q.Desc {
+			a, b = b, a
+		}
+		if c := compare(a, b); c != 0 {
+			return c < 0
+		}
+		return matches[i].ID.String() < matches[j].ID.String()
+	})
+
+	total := len(matches)
+	matches = page(matches, q.Limit, q.Offset)
+	return cloneAll(matches), total, nil
+}
+
+// userSortKeys compares users by each UserQuery sort key. Unset times sort first.
+var userSortKeys = map[string]func(a, b *domain.User) int{
+	"created_at": func(a, b *domain.User) int { return a.CreatedAt.Compare(b.CreatedAt) },
+	"email": func(a, b *domain.User) int {
+		return strings.Compare(strings.ToLower(a.Email), strings.ToLower(b.Email))
+	},
+	"name": func(a, b *domain.User) int {
+		return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	},
+	"last_login_at": func(a, b *domain.User) int { return compareTimes(a.LastLoginAt, b.LastLoginAt) },
+}
+
+// compareTimes orders optional times, nil first
+func compareTimes(a, b *time.Time) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	return a.Compare(*b)
+}
+
+// CountActiveAdmins returns the number of admins who can sign in
+func (r *UserRepository) CountActiveAdmins(ctx context.Context) (int64, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var count int64
+	for _, u := range r.store.users {
+		if u.Role == domain.UserRoleAdmin && u.DeactivatedAt == nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Count returns the total number of users
+func (r *UserRepository) Count(ctx context.Context) (int64, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+	return int64(len(r.store.users)), nil
+}
@@ -0,0 +1,56 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// UserTokenRepository keeps one-time email tokens in memory
+type UserTokenRepository struct {
+	store *Store
+}
+
+// NewUserTokenRepository creates a new user token repository
+func NewUserTokenRepository(store *Store) *UserTokenRepository {
+	return &UserTokenRepository{store: store}
+}
+
+// Create stores a new token
+func (r *UserTokenRepository) Create(ctx context.Context, token *domain.UserToken) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.store.userTokens[token.ID] = clone(token)
+	return nil
+}
+
+// Consume deletes and returns an unexpired token, so each token works once
+func (r *UserTokenRepository) Consume(ctx context.Context, purpose domain.UserTokenPurpose, hash string) (*domain.UserToken, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for id, token := range r.store.userTokens {
+		if token.TokenHash == hash && token.Purpose == purpose && token.ExpiresAt.After(now()) {
+			delete(r.store.userTokens, id)
+			return token, nil
+		}
+	}
+	return nil, fmt.Errorf("token %w", domain.ErrNotFound)
+}
+
+// DeleteForUser deletes a user's tokens for a purpose, and any expired tokens
+func (r *UserTokenRepository) DeleteForUser(ctx context.Context, userID uuid.UUID, purpose domain.UserTokenPurpose) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for id, token := range r.store.userTokens {
+		if (token.UserID == userID && token.Purpose == purpose) || !token.ExpiresAt.After(now()) {
+			delete(r.store.userTokens, id)
+		}
+	}
+	return nil
+}
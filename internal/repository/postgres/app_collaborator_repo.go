@@ -0,0 +1,169 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// appCollaboratorColumns lists the columns read by scanAppCollaborator, in scan order
+const appCollaboratorColumns = `c.app_id, c.user_id, c.permission, c.granted_by, c.created_at, c.updated_at, u.email, u.name`
+
+// AppCollaboratorRepository handles app collaborator persistence in PostgreSQL
+type AppCollaboratorRepository struct {
+	pool   *pgxpool.Pool
+	logger *zap.Logger
+}
+
+// NewAppCollaboratorRepository creates a new app collaborator repository
+func NewAppCollaboratorRepository(pool *pgxpool.Pool, logger *zap.Logger) *AppCollaboratorRepository {
+	return &AppCollaboratorRepository{
+		pool:   pool,
+		logger: logger,
+	}
+}
+
+// Create stores a new collaborator grant
+func (r *AppCollaboratorRepository) Create(ctx context.Context, c *domain.AppCollaborator) error {
+	query := `
+		INSERT INTO app_collaborators (app_id, user_id, permission, granted_by, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	_, err := dbFor(ctx, r.pool).Exec(ctx, query,
+		c.AppID,
+		c.UserID,
+		string(c.Permission),
+		c.GrantedBy,
+		c.CreatedAt,
+		c.UpdatedAt,
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("app collaborator %w", domain.ErrConflict)
+		}
+		return fmt.Errorf("failed to create app collaborator: %w", err)
+	}
+
+	r.logger.Debug("App collaborator added",
+		zap.String("app_id", c.AppID.String()),
+		zap.String("user_id", c.UserID.String()),
+	)
+	return nil
+}
+
+// Update saves a collaborator's permission
+func (r *AppCollaboratorRepository) Update(ctx context.Context, c *domain.AppCollaborator) error {
+	query := `
+		UPDATE app_collaborators SET
+			permission = $3,
+			updated_at = $4
+		WHERE app_id = $1 AND user_id = $2
+	`
+
+	result, err := dbFor(ctx, r.pool).Exec(ctx, query, c.AppID, c.UserID, string(c.Permission), c.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to update app collaborator: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("app collaborator %w", domain.ErrNotFound)
+	}
+	return nil
+}
+
+// Delete removes a user's access to an app
+func (r *AppCollaboratorRepository) Delete(ctx context.Context, appID, userID uuid.UUID) error {
+	result, err := dbFor(ctx, r.pool).Exec(ctx, `DELETE FROM app_collaborators WHERE app_id = $1 AND user_id = $2`, appID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete app collaborator: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("app collaborator %w", domain.ErrNotFound)
+	}
+	return nil
+}
+
+// Get returns a user's grant on an app
+func (r *AppCollaboratorRepository) Get(ctx context.Context, appID, userID uuid.UUID) (*domain.AppCollaborator, error) {
+	query := `
+		SELECT ` + appCollaboratorColumns + `
+		FROM app_collaborators c JOIN users u ON u.id = c.user_id
+		WHERE c.app_id = $1 AND c.user_id = $2
+	`
+
+	c, err := scanAppCollaborator(dbFor(ctx, r.pool).QueryRow(ctx, query, appID, userID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("app collaborator %w", domain.ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get app collaborator: %w", err)
+	}
+	return c, nil
+}
+
+// ListForApp returns an app's collaborators, oldest first
+func (r *AppCollaboratorRepository) ListForApp(ctx context.Context, appID uuid.UUID) ([]*domain.AppCollaborator, error) {
+	return r.list(ctx, `c.app_id = $1`, appID)
+}
+
+// ListForUser returns the apps a user collaborates on
+func (r *AppCollaboratorRepository) ListForUser(ctx context.Context, userID uuid.UUID) ([]*domain.AppCollaborator, error) {
+	return r.list(ctx, `c.user_id = $1`, userID)
+}
+
+// list returns the collaborators matching a condition on one ID
+func (r *AppCollaboratorRepository) list(ctx context.Context, where string, id uuid.UUID) ([]*domain.AppCollaborator, error) {
+	query := `
+		SELECT ` + appCollaboratorColumns + `
+		FROM app_collaborators c JOIN users u ON u.id = c.user_id
+		WHERE ` + where + `
+		ORDER BY c.created_at
+	`
+
+	rows, err := dbFor(ctx, r.pool).Query(ctx, query, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list app collaborators: %w", err)
+	}
+	defer rows.Close()
+
+	collaborators := make([]*domain.AppCollaborator, 0)
+	for rows.Next() {
+		c, err := scanAppCollaborator(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan app collaborator: %w", err)
+		}
+		collaborators = append(collaborators, c)
+	}
+
+	return collaborators, rows.Err()
+}
+
+// scanAppCollaborator scans a row selected with appCollaboratorColumns into an AppCollaborator
+func scanAppCollaborator(row pgx.Row) (*domain.AppCollaborator, error) {
+	c := &domain.AppCollaborator{}
+	var permission string
+
+	err := row.Scan(
+		&c.AppID,
+		&c.UserID,
+		&permission,
+		&c.GrantedBy,
+		&c.CreatedAt,
+		&c.UpdatedAt,
+		&c.Email,
+		&c.Name,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	c.Permission = domain.CollaboratorPermission(permission)
+	return c, nil
+}
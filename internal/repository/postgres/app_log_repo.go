@@ -0,0 +1,223 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// appLogPartitionLayout names the daily partitions of app_logs, e.g. app_logs_20240131
+const appLogPartitionLayout = "app_logs_20060102"
+
+// AppLogRepository stores shipped container logs in the partitioned app_logs table
+type AppLogRepository struct {
+	pool   *pgxpool.Pool
+	logger *zap.Logger
+}
+
+// NewAppLogRepository creates a new app log repository
+func NewAppLogRepository(pool *pgxpool.Pool, logger *zap.Logger) *AppLogRepository {
+	return &AppLogRepository{
+		pool:   pool,
+		logger: logger,
+	}
+}
+
+// Insert stores a batch of log lines; their days' partitions must exist. A batch with a
+// line Postgres refuses fails with domain.ErrInvalid.
+func (r *AppLogRepository) Insert(ctx context.Context, entries []domain.AppLogEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	rows := make([][]interface{}, len(entries))
+	for i, e := range entries {
+		rows[i] = []interface{}{e.AppID, e.ContainerID, e.ContainerName, e.Replica, e.Stream, e.Message, e.Timestamp}
+	}
+	_, err := dbFor(ctx, r.pool).CopyFrom(ctx,
+		pgx.Identifier{"app_logs"},
+		[]string{"app_id", "container_id", "container_name", "replica", "stream", "message", "logged_at"},
+		pgx.CopyFromRows(rows),
+	)
+	if isRejectedRow(err) {
+		return fmt.Errorf("app logs %w: %w", domain.ErrInvalid, err)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to insert app logs: %w", err)
+	}
+	return nil
+}
+
+// EnsurePartition creates the partition holding the given UTC day if it is missing
+func (r *AppLogRepository) EnsurePartition(ctx context.Context, day time.Time) error {
+	start := day.UTC().Truncate(24 * time.Hour)
+	end := start.AddDate(0, 0, 1)
+	query := fmt.Sprintf(
+		`CREATE TABLE IF NOT EXISTS %s PARTITION OF app_logs FOR VALUES FROM ('%s') TO ('%s')`,
+		pgx.Identifier{start.Format(appLogPartitionLayout)}.Sanitize(),
+		start.Format(time.RFC3339),
+		end.Format(time.RFC3339),
+	)
+	if _, err := dbFor(ctx, r.pool).Exec(ctx, query); err != nil {
+		return fmt.Errorf("failed to create app log partition: %w", err)
+	}
+	return nil
+}
+
+// DropPartitionsBefore drops the daily partitions ending at or before the cutoff and
+// returns how many were dropped
+func (r *AppLogRepository) DropPartitionsBefore(ctx context.Context, cutoff time.Time) (int, error) {
+	query := `
+		SELECT c.relname FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		JOIN pg_class p ON p.oid = i.inhparent
+		WHERE p.relname = 'app_logs'
+	`
+	rows, err := dbFor(ctx, r.pool).Query(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list app log partitions: %w", err)
+	}
+	var expired []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan app log partition: %w", err)
+		}
+		day, err := time.Parse(appLogPartitionLayout, name)
+		if err != nil {
+			continue // not one of ours
+		}
+		if !day.AddDate(0, 0, 1).After(cutoff) {
+			expired = append(expired, name)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to list app log partitions: %w", err)
+	}
+
+	for i, name := range expired {
+		if _, err := dbFor(ctx, r.pool).Exec(ctx, "DROP TABLE IF EXISTS "+pgx.Identifier{name}.Sanitize()); err != nil {
+			return i, fmt.Errorf("failed to drop app log partition %s: %w", name, err)
+		}
+		r.logger.Info("Dropped expired app log partition", zap.String("partition", name))
+	}
+	return len(expired), nil
+}
+
+// Search returns an app's stored log lines matching the query, newest first
+func (r *AppLogRepository) Search(ctx context.Context, q domain.AppLogQuery) ([]domain.AppLogEntry, error) {
+	conditions := []string{"app_id = $1"}
+	args := []interface{}{q.AppID}
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if !q.From.IsZero() {
+		add("logged_at >= $%d", q.From)
+	}
+	if !q.To.IsZero() {
+		add("logged_at < $%d", q.To)
+	}
+	if q.Stream != "" {
+		add("stream = $%d", q.Stream)
+	}
+	if q.Search != "" {
+		add(`message ILIKE $%d ESCAPE '\'`, "%"+escapeLike(q.Search)+"%")
+	}
+	args = append(args, q.Limit)
+
+	query := fmt.Sprintf(`
+		SELECT app_id, container_id, container_name, replica, stream, message, logged_at
+		FROM app_logs
+		WHERE %s
+		ORDER BY logged_at DESC
+		LIMIT $%d
+	`, strings.Join(conditions, " AND "), len(args))
+
+	rows, err := dbFor(ctx, r.pool).Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search app logs: %w", err)
+	}
+	defer rows.Close()
+
+	entries := make([]domain.AppLogEntry, 0)
+	for rows.Next() {
+		var e domain.AppLogEntry
+		if err := rows.Scan(&e.AppID, &e.ContainerID, &e.ContainerName, &e.Replica, &e.Stream, &e.Message, &e.Timestamp); err != nil {
+			return nil, fmt.Errorf("failed to scan app log: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to search app logs: %w", err)
+	}
+	return entries, nil
+}
+
+// ShippedUntil returns the time of the newest line stored for a container, zero if none
+// was. Containers shipped before cursors were kept fall back to their newest stored line.
+func (r *AppLogRepository) ShippedUntil(ctx context.Context, containerID string) (time.Time, error) {
+	var until *time.Time
+	err := dbFor(ctx, r.pool).QueryRow(ctx, `
+		SELECT COALESCE(
+			(SELECT shipped_until FROM app_log_cursors WHERE container_id = $1),
+			(SELECT max(logged_at) FROM app_logs WHERE container_id = $1)
+		)
+	`, containerID).Scan(&until)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get app log cursor: %w", err)
+	}
+	if until == nil {
+		return time.Time{}, nil
+	}
+	return *until, nil
+}
+
+// AdvanceCursors records the newest line stored for each container. Cursors never move back.
+func (r *AppLogRepository) AdvanceCursors(ctx context.Context, cursors map[string]time.Time) error {
+	if len(cursors) == 0 {
+		return nil
+	}
+
+	containerIDs := make([]string, 0, len(cursors))
+	untils := make([]time.Time, 0, len(cursors))
+	for containerID, until := range cursors {
+		containerIDs = append(containerIDs, containerID)
+		untils = append(untils, until)
+	}
+	_, err := dbFor(ctx, r.pool).Exec(ctx, `
+		INSERT INTO app_log_cursors (container_id, shipped_until, updated_at)
+		SELECT container_id, shipped_until, NOW() FROM unnest($1::text[], $2::timestamptz[]) AS c(container_id, shipped_until)
+		ON CONFLICT (container_id) DO UPDATE SET
+			shipped_until = GREATEST(app_log_cursors.shipped_until, EXCLUDED.shipped_until),
+			updated_at = NOW()
+	`, containerIDs, untils)
+	if err != nil {
+		return fmt.Errorf("failed to advance app log cursors: %w", err)
+	}
+	return nil
+}
+
+// DropCursorsBefore forgets the containers whose newest stored line is older than the
+// cutoff and returns how many were forgotten
+func (r *AppLogRepository) DropCursorsBefore(ctx context.Context, cutoff time.Time) (int, error) {
+	tag, err := dbFor(ctx, r.pool).Exec(ctx, `DELETE FROM app_log_cursors WHERE shipped_until < $1`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to drop app log cursors: %w", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// escapeLike escapes the LIKE wildcards in a search term
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -13,6 +13,15 @@ import (
 	"github.com/nanopaas/nanopaas/internal/domain"
 )
 
+// appColumns lists the columns read by scanApp, in scan order
+const appColumns = `id, name, slug, description, status, env_vars, labels,
+			current_image_id, previous_image_id, replicas, target_replicas,
+			memory_limit, cpu_quota, restart_policy, nofile_limit, tmpfs, shm_size, sysctls,
+			command, volumes, network_aliases, smoke_checks, cors, basic_auth_user, basic_auth_hash, subdomain, exposed_port, internal_port,
+			streaming_mode, stream_idle_timeout, last_exit, pin, lockdown, custom_domains, routing, hsts, sticky_sessions, maintenance_page, network_route, secrets,
+			env_restart_policy, pending_restart_since, git_repo_url, git_branch, auto_deploy, require_approval, github_webhook, git_provider, deploy_trigger, tag_pattern, webhook_secret, require_checks,
+			created_at, updated_at, started_at, stopped_at, owner_id, team_id, project_id`
+
 // AppRepository handles app persistence in PostgreSQL
 type AppRepository struct {
 	pool   *pgxpool.Pool
@@ -33,36 +42,83 @@ func (r *AppRepository) Create(ctx context.Context, app *domain.App) error {
 		INSERT INTO apps (
 			id, name, slug, description, status, env_vars, labels,
 			current_image_id, previous_image_id, replicas, target_replicas,
-			memory_limit, cpu_quota, subdomain, exposed_port, internal_port,
-			created_at, updated_at, owner_id
+			memory_limit, cpu_quota, restart_policy, nofile_limit, tmpfs, shm_size, sysctls,
+			command, volumes, network_aliases, smoke_checks, cors, basic_auth_user, basic_auth_hash, subdomain, exposed_port, internal_port,
+			streaming_mode, stream_idle_timeout, last_exit, pin, lockdown, custom_domains, routing, hsts, sticky_sessions, maintenance_page, network_route, secrets,
+			env_restart_policy, pending_restart_since, git_repo_url, git_branch, auto_deploy, require_approval, github_webhook, git_provider, deploy_trigger, tag_pattern, git_repo_key, webhook_secret, require_checks,
+			created_at, updated_at, owner_id, team_id, project_id
 		) VALUES (
-			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18,
+			$19, $20, $21, $22, $23, $24, $25, $26, $27, $28, $29, $30, $31, $32, $33,
+			$34, $35, $36, $37, $38, $39, $40, $41, $42, $43, $44, $45, $46, $47, $48, $49, $50, $51, $52, $53, $54, $55, $56, $57, $58
 		)
 	`
 
-	_, err := r.pool.Exec(ctx, query,
+	_, err := dbFor(ctx, r.pool).Exec(ctx, query,
 		app.ID,
 		app.Name,
 		app.Slug,
 		app.Description,
 		string(app.Status),
-		app.EnvVars,
-		app.Labels,
+		jsonObject(app.EnvVars),
+		jsonObject(app.Labels),
 		app.CurrentImageID,
 		app.PreviousImageID,
 		app.Replicas,
 		app.TargetReplicas,
 		app.MemoryLimit,
 		app.CPUQuota,
+		app.RestartPolicy,
+		app.NoFileLimit,
+		jsonObject(app.Tmpfs),
+		app.ShmSize,
+		jsonObject(app.Sysctls),
+		app.Command,
+		app.Volumes,
+		app.NetworkAliases,
+		jsonArray(app.SmokeChecks),
+		app.CORS,
+		app.BasicAuthUser,
+		app.BasicAuthHash,
 		app.Subdomain,
 		app.ExposedPort,
 		app.InternalPort,
+		string(app.StreamingMode),
+		app.StreamIdleTimeout,
+		app.LastExit,
+		app.Pin,
+		app.Lockdown,
+		app.CustomDomains,
+		app.Routing,
+		app.HSTS,
+		app.StickySessions,
+		app.MaintenancePage,
+		app.NetworkRoute,
+		jsonObject(app.Secrets),
+		string(app.EnvRestartPolicy),
+		app.PendingRestartSince,
+		app.GitRepoURL,
+		app.GitBranch,
+		app.AutoDeploy,
+		app.RequireApproval,
+		app.GitHubWebhook,
+		app.GitProvider,
+		string(app.EffectiveDeployTrigger()),
+		app.TagPattern,
+		domain.GitRepoKey(app.GitRepoURL),
+		app.WebhookSecret,
+		app.RequireChecks,
 		app.CreatedAt,
 		app.UpdatedAt,
 		app.OwnerID,
+		app.TeamID,
+		app.ProjectID,
 	)
 
 	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("app %w", domain.ErrConflict)
+		}
 		return fmt.Errorf("failed to create app: %w", err)
 	}
 
@@ -73,123 +129,52 @@ func (r *AppRepository) Create(ctx context.Context, app *domain.App) error {
 // GetByID retrieves an app by ID
 func (r *AppRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.App, error) {
 	query := `
-		SELECT id, name, slug, description, status, env_vars, labels,
-			current_image_id, previous_image_id, replicas, target_replicas,
-			memory_limit, cpu_quota, subdomain, exposed_port, internal_port,
-			created_at, updated_at, started_at, stopped_at, owner_id
+		SELECT ` + appColumns + `
 		FROM apps
 		WHERE id = $1
 	`
 
-	app := &domain.App{}
-	var status string
-	var startedAt, stoppedAt *time.Time
-
-	err := r.pool.QueryRow(ctx, query, id).Scan(
-		&app.ID,
-		&app.Name,
-		&app.Slug,
-		&app.Description,
-		&status,
-		&app.EnvVars,
-		&app.Labels,
-		&app.CurrentImageID,
-		&app.PreviousImageID,
-		&app.Replicas,
-		&app.TargetReplicas,
-		&app.MemoryLimit,
-		&app.CPUQuota,
-		&app.Subdomain,
-		&app.ExposedPort,
-		&app.InternalPort,
-		&app.CreatedAt,
-		&app.UpdatedAt,
-		&startedAt,
-		&stoppedAt,
-		&app.OwnerID,
-	)
-
+	app, err := scanApp(dbFor(ctx, r.pool).QueryRow(ctx, query, id))
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			return nil, fmt.Errorf("app not found")
+			return nil, fmt.Errorf("app %w", domain.ErrNotFound)
 		}
 		return nil, fmt.Errorf("failed to get app: %w", err)
 	}
 
-	app.Status = domain.AppStatus(status)
-	app.StartedAt = startedAt
-	app.StoppedAt = stoppedAt
-
 	return app, nil
 }
 
 // GetBySlug retrieves an app by slug
 func (r *AppRepository) GetBySlug(ctx context.Context, slug string) (*domain.App, error) {
 	query := `
-		SELECT id, name, slug, description, status, env_vars, labels,
-			current_image_id, previous_image_id, replicas, target_replicas,
-			memory_limit, cpu_quota, subdomain, exposed_port, internal_port,
-			created_at, updated_at, started_at, stopped_at, owner_id
+		SELECT ` + appColumns + `
 		FROM apps
 		WHERE slug = $1
 	`
 
-	app := &domain.App{}
-	var status string
-	var startedAt, stoppedAt *time.Time
-
-	err := r.pool.QueryRow(ctx, query, slug).Scan(
-		&app.ID,
-		&app.Name,
-		&app.Slug,
-		&app.Description,
-		&status,
-		&app.EnvVars,
-		&app.Labels,
-		&app.CurrentImageID,
-		&app.PreviousImageID,
-		&app.Replicas,
-		&app.TargetReplicas,
-		&app.MemoryLimit,
-		&app.CPUQuota,
-		&app.Subdomain,
-		&app.ExposedPort,
-		&app.InternalPort,
-		&app.CreatedAt,
-		&app.UpdatedAt,
-		&startedAt,
-		&stoppedAt,
-		&app.OwnerID,
-	)
-
+	app, err := scanApp(dbFor(ctx, r.pool).QueryRow(ctx, query, slug))
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			return nil, fmt.Errorf("app not found")
+			return nil, fmt.Errorf("app %w", domain.ErrNotFound)
 		}
 		return nil, fmt.Errorf("failed to get app: %w", err)
 	}
 
-	app.Status = domain.AppStatus(status)
-	app.StartedAt = startedAt
-	app.StoppedAt = stoppedAt
-
 	return app, nil
 }
 
 // List retrieves all apps for an owner
 func (r *AppRepository) List(ctx context.Context, ownerID uuid.UUID, limit, offset int) ([]*domain.App, error) {
 	query := `
-		SELECT id, name, slug, description, status, env_vars, labels,
-			current_image_id, previous_image_id, replicas, target_replicas,
-			memory_limit, cpu_quota, subdomain, exposed_port, internal_port,
-			created_at, updated_at, started_at, stopped_at, owner_id
+		SELECT ` + appColumns + `
 		FROM apps
 		WHERE owner_id = $1
 		ORDER BY created_at DESC
 		LIMIT $2 OFFSET $3
 	`
 
-	rows, err := r.pool.Query(ctx, query, ownerID, limit, offset)
+	rows, err := dbFor(ctx, r.pool).Query(ctx, query, ownerID, limit, offset)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list apps: %w", err)
 	}
@@ -197,42 +182,11 @@ func (r *AppRepository) List(ctx context.Context, ownerID uuid.UUID, limit, offs
 
 	var apps []*domain.App
 	for rows.Next() {
-		app := &domain.App{}
-		var status string
-		var startedAt, stoppedAt *time.Time
-
-		err := rows.Scan(
-			&app.ID,
-			&app.Name,
-			&app.Slug,
-			&app.Description,
-			&status,
-			&app.EnvVars,
-			&app.Labels,
-			&app.CurrentImageID,
-			&app.PreviousImageID,
-			&app.Replicas,
-			&app.TargetReplicas,
-			&app.MemoryLimit,
-			&app.CPUQuota,
-			&app.Subdomain,
-			&app.ExposedPort,
-			&app.InternalPort,
-			&app.CreatedAt,
-			&app.UpdatedAt,
-			&startedAt,
-			&stoppedAt,
-			&app.OwnerID,
-		)
-
+		app, err := scanApp(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan app: %w", err)
 		}
 
-		app.Status = domain.AppStatus(status)
-		app.StartedAt = startedAt
-		app.StoppedAt = stoppedAt
-
 		apps = append(apps, app)
 	}
 
@@ -259,17 +213,56 @@ func (r *AppRepository) Update(ctx context.Context, app *domain.App) error {
 			internal_port = $15,
 			updated_at = $16,
 			started_at = $17,
-			stopped_at = $18
+			stopped_at = $18,
+			restart_policy = $19,
+			nofile_limit = $20,
+			tmpfs = $21,
+			shm_size = $22,
+			sysctls = $23,
+			smoke_checks = $24,
+			team_id = $25,
+			cors = $26,
+			basic_auth_user = $27,
+			basic_auth_hash = $28,
+			streaming_mode = $29,
+			stream_idle_timeout = $30,
+			last_exit = $31,
+			pin = $32,
+			command = $33,
+			volumes = $34,
+			network_aliases = $35,
+			lockdown = $36,
+			custom_domains = $37,
+			routing = $38,
+			hsts = $39,
+			sticky_sessions = $40,
+			maintenance_page = $41,
+			network_route = $42,
+			secrets = $43,
+			env_restart_policy = $44,
+			pending_restart_since = $45,
+			git_repo_url = $46,
+			git_branch = $47,
+			auto_deploy = $48,
+			require_approval = $49,
+			project_id = $50,
+			github_webhook = $51,
+			git_provider = $52,
+			deploy_trigger = $53,
+			tag_pattern = $54,
+			git_repo_key = $55,
+			webhook_secret = $56,
+			require_checks = $57
 		WHERE id = $1
 	`
 
-	result, err := r.pool.Exec(ctx, query,
+	result, err := dbFor(ctx, r.pool).Exec(ctx, query,
 		app.ID,
 		app.Name,
 		app.Description,
 		string(app.Status),
-		app.EnvVars,
-		app.Labels,
+		jsonObject(app.EnvVars),
+		jsonObject(app.Labels),
 		app.CurrentImageID,
 		app.PreviousImageID,
 		app.Replicas,
@@ -282,6 +275,45 @@ func (r *AppRepository) Update(ctx context.Context, app *domain.App) error {
 		app.UpdatedAt,
 		app.StartedAt,
 		app.StoppedAt,
+		app.RestartPolicy,
+		app.NoFileLimit,
+		jsonObject(app.Tmpfs),
+		app.ShmSize,
+		jsonObject(app.Sysctls),
+		jsonArray(app.SmokeChecks),
+		app.TeamID,
+		app.CORS,
+		app.BasicAuthUser,
+		app.BasicAuthHash,
+		string(app.StreamingMode),
+		app.StreamIdleTimeout,
+		app.LastExit,
+		app.Pin,
+		app.Command,
+		app.Volumes,
+		app.NetworkAliases,
+		app.Lockdown,
+		app.CustomDomains,
+		app.Routing,
+		app.HSTS,
+		app.StickySessions,
+		app.MaintenancePage,
+		app.NetworkRoute,
+		jsonObject(app.Secrets),
+		string(app.EnvRestartPolicy),
+		app.PendingRestartSince,
+		app.GitRepoURL,
+		app.GitBranch,
+		app.AutoDeploy,
+		app.RequireApproval,
+		app.ProjectID,
+		app.GitHubWebhook,
+		app.GitProvider,
+		string(app.EffectiveDeployTrigger()),
+		app.TagPattern,
+		domain.GitRepoKey(app.GitRepoURL),
+		app.WebhookSecret,
+		app.RequireChecks,
 	)
 
 	if err != nil {
@@ -289,24 +321,34 @@ func (r *AppRepository) Update(ctx context.Context, app *domain.App) error {
 	}
 
 	if result.RowsAffected() == 0 {
-		return fmt.Errorf("app not found")
+		return fmt.Errorf("app %w", domain.ErrNotFound)
 	}
 
 	r.logger.Debug("App updated", zap.String("app_id", app.ID.String()))
 	return nil
 }
 
-// Delete deletes an app
+// Delete deletes an app along with its shipped logs, which app_logs doesn't cascade to.
+// Its other rows go with it by foreign key.
 func (r *AppRepository) Delete(ctx context.Context, id uuid.UUID) error {
-	query := `DELETE FROM apps WHERE id = $1`
-
-	result, err := r.pool.Exec(ctx, query, id)
+	tx, err := dbFor(ctx, r.pool).Begin(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to delete app: %w", err)
 	}
+	defer tx.Rollback(ctx)
 
+	if _, err := tx.Exec(ctx, `DELETE FROM app_logs WHERE app_id = $1`, id); err != nil {
+		return fmt.Errorf("failed to delete app logs: %w", err)
+	}
+	result, err := tx.Exec(ctx, `DELETE FROM apps WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete app: %w", err)
+	}
 	if result.RowsAffected() == 0 {
-		return fmt.Errorf("app not found")
+		return fmt.Errorf("app %w", domain.ErrNotFound)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to delete app: %w", err)
 	}
 
 	r.logger.Debug("App deleted", zap.String("app_id", id.String()))
@@ -317,13 +359,13 @@ func (r *AppRepository) Delete(ctx context.Context, id uuid.UUID) error {
 func (r *AppRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status domain.AppStatus) error {
 	query := `UPDATE apps SET status = $2, updated_at = $3 WHERE id = $1`
 
-	result, err := r.pool.Exec(ctx, query, id, string(status), time.Now().UTC())
+	result, err := dbFor(ctx, r.pool).Exec(ctx, query, id, string(status), time.Now().UTC())
 	if err != nil {
 		return fmt.Errorf("failed to update status: %w", err)
 	}
 
 	if result.RowsAffected() == 0 {
-		return fmt.Errorf("app not found")
+		return fmt.Errorf("app %w", domain.ErrNotFound)
 	}
 
 	return nil
@@ -333,24 +375,205 @@ func (r *AppRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status d
 func (r *AppRepository) UpdateEnvVars(ctx context.Context, id uuid.UUID, envVars map[string]string) error {
 	query := `UPDATE apps SET env_vars = $2, updated_at = $3 WHERE id = $1`
 
-	result, err := r.pool.Exec(ctx, query, id, envVars, time.Now().UTC())
+	result, err := dbFor(ctx, r.pool).Exec(ctx, query, id, jsonObject(envVars), time.Now().UTC())
 	if err != nil {
 		return fmt.Errorf("failed to update env vars: %w", err)
 	}
 
 	if result.RowsAffected() == 0 {
-		return fmt.Errorf("app not found")
+		return fmt.Errorf("app %w", domain.ErrNotFound)
+	}
+
+	return nil
+}
+
+// SetEnvVars sets environment variables in place, leaving the app's other variables
+// and columns as they are in the database
+func (r *AppRepository) SetEnvVars(ctx context.Context, id uuid.UUID, envVars map[string]string) error {
+	query := `UPDATE apps SET env_vars = env_vars || $2::jsonb, updated_at = $3 WHERE id = $1`
+
+	result, err := dbFor(ctx, r.pool).Exec(ctx, query, id, jsonObject(envVars), time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to set env vars: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("app %w", domain.ErrNotFound)
 	}
 
 	return nil
 }
 
+// DeleteEnvVar removes one environment variable in place
+func (r *AppRepository) DeleteEnvVar(ctx context.Context, id uuid.UUID, key string) error {
+	query := `UPDATE apps SET env_vars = env_vars - $2::text, updated_at = $3 WHERE id = $1`
+
+	result, err := dbFor(ctx, r.pool).Exec(ctx, query, id, key, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to delete env var: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("app %w", domain.ErrNotFound)
+	}
+
+	return nil
+}
+
+// EnsureWebhookSecret stores a sealed webhook secret for an app that has none, and
+// returns the secret the app keeps: this one, or the one stored first
+func (r *AppRepository) EnsureWebhookSecret(ctx context.Context, id uuid.UUID, sealed []byte) ([]byte, error) {
+	query := `UPDATE apps SET webhook_secret = $2, updated_at = $3 WHERE id = $1 AND webhook_secret IS NULL`
+
+	result, err := dbFor(ctx, r.pool).Exec(ctx, query, id, sealed, time.Now().UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to set webhook secret: %w", err)
+	}
+	if result.RowsAffected() == 1 {
+		return sealed, nil
+	}
+
+	// Another request stored one first, or the app is gone
+	var stored []byte
+	err = dbFor(ctx, r.pool).QueryRow(ctx, `SELECT webhook_secret FROM apps WHERE id = $1`, id).Scan(&stored)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("app %w", domain.ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get webhook secret: %w", err)
+	}
+	return stored, nil
+}
+
+// ListByLabel returns the apps with a label, oldest first. An empty value matches the
+// label with any value.
+func (r *AppRepository) ListByLabel(ctx context.Context, key, value string) ([]*domain.App, error) {
+	condition, args := `labels @> jsonb_build_object($1::text, $2::text)`, []interface{}{key, value}
+	if value == "" {
+		condition, args = `labels ? $1`, []interface{}{key}
+	}
+	query := `
+		SELECT ` + appColumns + `
+		FROM apps
+		WHERE ` + condition + `
+		ORDER BY created_at
+	`
+
+	rows, err := dbFor(ctx, r.pool).Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list apps by label: %w", err)
+	}
+	defer rows.Close()
+
+	var apps []*domain.App
+	for rows.Next() {
+		app, err := scanApp(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan app: %w", err)
+		}
+
+		apps = append(apps, app)
+	}
+
+	return apps, rows.Err()
+}
+
+// ListOverview returns every app with its latest build and deployment, by name, in one
+// query
+func (r *AppRepository) ListOverview(ctx context.Context) ([]*domain.AppOverview, error) {
+	query := `
+		SELECT a.id, a.name, a.slug, a.status, a.replicas, a.target_replicas, a.updated_at,
+			   b.id, b.status, COALESCE(b.git_commit, ''), COALESCE(b.image_tag, ''), b.created_at, b.completed_at,
+			   d.id, d.status, d.image_id, d.current_replicas, d.created_at, d.completed_at
+		FROM apps a
+		LEFT JOIN LATERAL (
+			SELECT id, status, git_commit, image_tag, created_at, completed_at
+			FROM builds
+			WHERE app_id = a.id
+			ORDER BY created_at DESC
+			LIMIT 1
+		) b ON true
+		LEFT JOIN LATERAL (
+			SELECT id, status, image_id, current_replicas, created_at, completed_at
+			FROM deployments
+			WHERE app_id = a.id
+			ORDER BY created_at DESC
+			LIMIT 1
+		) d ON true
+		ORDER BY a.name, a.id
+	`
+
+	rows, err := dbFor(ctx, r.pool).Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list app overview: %w", err)
+	}
+	defer rows.Close()
+
+	var overview []*domain.AppOverview
+	for rows.Next() {
+		o := &domain.AppOverview{}
+		var status string
+		var buildID, deploymentID *uuid.UUID
+		var buildStatus, deploymentStatus, deploymentImage *string
+		var buildCreatedAt, deploymentCreatedAt *time.Time
+		var deploymentReplicas *int
+		build := &domain.BuildSummary{}
+		deployment := &domain.DeploymentSummary{}
+
+		err := rows.Scan(
+			&o.AppID, &o.Name, &o.Slug, &status, &o.Replicas, &o.TargetReplicas, &o.UpdatedAt,
+			&buildID, &buildStatus, &build.GitCommit, &build.ImageTag, &buildCreatedAt, &build.CompletedAt,
+			&deploymentID, &deploymentStatus, &deploymentImage, &deploymentReplicas, &deploymentCreatedAt, &deployment.CompletedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan app overview: %w", err)
+		}
+		o.Status = domain.AppStatus(status)
+
+		if buildID != nil {
+			build.ID = *buildID
+			build.Status = domain.BuildStatus(*buildStatus)
+			build.CreatedAt = *buildCreatedAt
+			o.LatestBuild = build
+		}
+		if deploymentID != nil {
+			deployment.ID = *deploymentID
+			deployment.Status = domain.DeploymentStatus(*deploymentStatus)
+			deployment.ImageID = *deploymentImage
+			deployment.Replicas = *deploymentReplicas
+			deployment.CreatedAt = *deploymentCreatedAt
+			o.LatestDeployment = deployment
+		}
+		overview = append(overview, o)
+	}
+
+	return overview, rows.Err()
+}
+
+// jsonObject returns a map for a JSONB object column, which holds an empty object
+// rather than null
+func jsonObject[V any](m map[string]V) map[string]V {
+	if m == nil {
+		return map[string]V{}
+	}
+	return m
+}
+
+// jsonArray returns a slice for a JSONB array column, which holds an empty array rather
+// than null
+func jsonArray[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
+
 // CountByOwner returns the number of apps for an owner
 func (r *AppRepository) CountByOwner(ctx context.Context, ownerID uuid.UUID) (int64, error) {
 	query := `SELECT COUNT(*) FROM apps WHERE owner_id = $1`
 
 	var count int64
-	err := r.pool.QueryRow(ctx, query, ownerID).Scan(&count)
+	err := dbFor(ctx, r.pool).QueryRow(ctx, query, ownerID).Scan(&count)
 	if err != nil {
 		return 0, fmt.Errorf("failed to count apps: %w", err)
 	}
@@ -361,16 +584,13 @@ func (r *AppRepository) CountByOwner(ctx context.Context, ownerID uuid.UUID) (in
 // ListRunning returns all running apps
 func (r *AppRepository) ListRunning(ctx context.Context) ([]*domain.App, error) {
 	query := `
-		SELECT id, name, slug, description, status, env_vars, labels,
-			current_image_id, previous_image_id, replicas, target_replicas,
-			memory_limit, cpu_quota, subdomain, exposed_port, internal_port,
-			created_at, updated_at, started_at, stopped_at, owner_id
+		SELECT ` + appColumns + `
 		FROM apps
 		WHERE status = 'running'
 		ORDER BY created_at DESC
 	`
 
-	rows, err := r.pool.Query(ctx, query)
+	rows, err := dbFor(ctx, r.pool).Query(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list running apps: %w", err)
 	}
@@ -378,44 +598,159 @@ func (r *AppRepository) ListRunning(ctx context.Context) ([]*domain.App, error)
 
 	var apps []*domain.App
 	for rows.Next() {
-		app := &domain.App{}
-		var status string
-		var startedAt, stoppedAt *time.Time
+		app, err := scanApp(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan app: %w", err)
+		}
 
-		err := rows.Scan(
-			&app.ID,
-			&app.Name,
-			&app.Slug,
-			&app.Description,
-			&status,
-			&app.EnvVars,
-			&app.Labels,
-			&app.CurrentImageID,
-			&app.PreviousImageID,
-			&app.Replicas,
-			&app.TargetReplicas,
-			&app.MemoryLimit,
-			&app.CPUQuota,
-			&app.Subdomain,
-			&app.ExposedPort,
-			&app.InternalPort,
-			&app.CreatedAt,
-			&app.UpdatedAt,
-			&startedAt,
-			&stoppedAt,
-			&app.OwnerID,
-		)
+		apps = append(apps, app)
+	}
+
+	return apps, nil
+}
+
+// ListAutoDeployByRepo returns the apps that auto-deploy from a repository, oldest
+// first. repoKey is the repository's domain.GitRepoKey.
+func (r *AppRepository) ListAutoDeployByRepo(ctx context.Context, repoKey string) ([]*domain.App, error) {
+	query := `
+		SELECT ` + appColumns + `
+		FROM apps
+		WHERE git_repo_key = $1 AND auto_deploy
+		ORDER BY created_at
+	`
+
+	rows, err := dbFor(ctx, r.pool).Query(ctx, query, repoKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list apps by repository: %w", err)
+	}
+	defer rows.Close()
 
+	var apps []*domain.App
+	for rows.Next() {
+		app, err := scanApp(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan app: %w", err)
 		}
 
-		app.Status = domain.AppStatus(status)
-		app.StartedAt = startedAt
-		app.StoppedAt = stoppedAt
+		apps = append(apps, app)
+	}
+
+	return apps, rows.Err()
+}
+
+// ListAll retrieves every app, oldest first
+func (r *AppRepository) ListAll(ctx context.Context) ([]*domain.App, error) {
+	query := `
+		SELECT ` + appColumns + `
+		FROM apps
+		ORDER BY created_at
+	`
+
+	rows, err := dbFor(ctx, r.pool).Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list apps: %w", err)
+	}
+	defer rows.Close()
+
+	var apps []*domain.App
+	for rows.Next() {
+		app, err := scanApp(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan app: %w", err)
+		}
 
 		apps = append(apps, app)
 	}
 
-	return apps, nil
+	return apps, rows.Err()
+}
+
+// scanApp scans a row selected with appColumns into an App
+func scanApp(row pgx.Row) (*domain.App, error) {
+	app := &domain.App{}
+	var status, streamingMode, envRestartPolicy, deployTrigger string
+	var startedAt, stoppedAt *time.Time
+	var gitRepoURL, gitBranch *string
+	var autoDeploy *bool
+
+	err := row.Scan(
+		&app.ID,
+		&app.Name,
+		&app.Slug,
+		&app.Description,
+		&status,
+		&app.EnvVars,
+		&app.Labels,
+		&app.CurrentImageID,
+		&app.PreviousImageID,
+		&app.Replicas,
+		&app.TargetReplicas,
+		&app.MemoryLimit,
+		&app.CPUQuota,
+		&app.RestartPolicy,
+		&app.NoFileLimit,
+		&app.Tmpfs,
+		&app.ShmSize,
+		&app.Sysctls,
+		&app.Command,
+		&app.Volumes,
+		&app.NetworkAliases,
+		&app.SmokeChecks,
+		&app.CORS,
+		&app.BasicAuthUser,
+		&app.BasicAuthHash,
+		&app.Subdomain,
+		&app.ExposedPort,
+		&app.InternalPort,
+		&streamingMode,
+		&app.StreamIdleTimeout,
+		&app.LastExit,
+		&app.Pin,
+		&app.Lockdown,
+		&app.CustomDomains,
+		&app.Routing,
+		&app.HSTS,
+		&app.StickySessions,
+		&app.MaintenancePage,
+		&app.NetworkRoute,
+		&app.Secrets,
+		&envRestartPolicy,
+		&app.PendingRestartSince,
+		&gitRepoURL,
+		&gitBranch,
+		&autoDeploy,
+		&app.RequireApproval,
+		&app.GitHubWebhook,
+		&app.GitProvider,
+		&deployTrigger,
+		&app.TagPattern,
+		&app.WebhookSecret,
+		&app.RequireChecks,
+		&app.CreatedAt,
+		&app.UpdatedAt,
+		&startedAt,
+		&stoppedAt,
+		&app.OwnerID,
+		&app.TeamID,
+		&app.ProjectID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	app.Status = domain.AppStatus(status)
+	app.StreamingMode = domain.StreamingMode(streamingMode)
+	app.EnvRestartPolicy = domain.EnvRestartPolicy(envRestartPolicy)
+	app.DeployTrigger = domain.DeployTrigger(deployTrigger)
+	if gitRepoURL != nil {
+		app.GitRepoURL = *gitRepoURL
+	}
+	if gitBranch != nil {
+		app.GitBranch = *gitBranch
+	}
+	app.AutoDeploy = autoDeploy != nil && *autoDeploy
+	app.StartedAt = startedAt
+	app.StoppedAt = stoppedAt
+
+	return app, nil
 }
@@ -0,0 +1,77 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// BuildLogRepository stores the full logs of builds in the build_logs table
+type BuildLogRepository struct {
+	pool   *pgxpool.Pool
+	logger *zap.Logger
+}
+
+// NewBuildLogRepository creates a new build log repository
+func NewBuildLogRepository(pool *pgxpool.Pool, logger *zap.Logger) *BuildLogRepository {
+	return &BuildLogRepository{
+		pool:   pool,
+		logger: logger,
+	}
+}
+
+// Append stores a chunk of a build's log; the build must be stored
+func (r *BuildLogRepository) Append(ctx context.Context, buildID uuid.UUID, lines []domain.BuildLogLine) error {
+	if len(lines) == 0 {
+		return nil
+	}
+
+	rows := make([][]interface{}, len(lines))
+	for i, l := range lines {
+		rows[i] = []interface{}{buildID, l.Line, l.Content, l.Timestamp}
+	}
+	_, err := dbFor(ctx, r.pool).CopyFrom(ctx,
+		pgx.Identifier{"build_logs"},
+		[]string{"build_id", "line_number", "content", "timestamp"},
+		pgx.CopyFromRows(rows),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to append build log: %w", err)
+	}
+	return nil
+}
+
+// List returns up to limit lines of a build's log after the given line number, in order
+func (r *BuildLogRepository) List(ctx context.Context, buildID uuid.UUID, after, limit int) ([]domain.BuildLogLine, error) {
+	query := `
+		SELECT line_number, content, timestamp
+		FROM build_logs
+		WHERE build_id = $1 AND line_number > $2
+		ORDER BY line_number
+		LIMIT $3
+	`
+	rows, err := dbFor(ctx, r.pool).Query(ctx, query, buildID, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list build log: %w", err)
+	}
+	defer rows.Close()
+
+	lines := []domain.BuildLogLine{}
+	for rows.Next() {
+		var l domain.BuildLogLine
+		if err := rows.Scan(&l.Line, &l.Content, &l.Timestamp); err != nil {
+			return nil, fmt.Errorf("failed to scan build log line: %w", err)
+		}
+		lines = append(lines, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list build log: %w", err)
+	}
+	return lines, nil
+}
@@ -30,18 +30,25 @@ func NewBuildRepository(pool *pgxpool.Pool, logger *zap.Logger) *BuildRepository
 func (r *BuildRepository) Create(ctx context.Context, build *domain.Build) error {
 	query := `
 		INSERT INTO builds (
-			id, app_id, status, source, source_url, git_ref,
+			id, app_id, status, source, source_url, git_ref, git_tag,
+			git_commit, commit_message, commit_author, compare_url, commits,
 			dockerfile_path, image_tag, build_args, created_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
 	`
 
-	_, err := r.pool.Exec(ctx, query,
+	_, err := dbFor(ctx, r.pool).Exec(ctx, query,
 		build.ID,
 		build.AppID,
 		string(build.Status),
 		string(build.Source),
 		build.SourceURL,
 		build.GitRef,
+		build.GitTag,
+		build.GitCommit,
+		build.CommitMessage,
+		build.CommitAuthor,
+		build.CompareURL,
+		buildCommits(build.Commits),
 		build.DockerfilePath,
 		build.ImageTag,
 		build.BuildArgs,
@@ -59,10 +66,73 @@ func (r *BuildRepository) Create(ctx context.Context, build *domain.Build) error
 	return nil
 }
 
+// Save stores a build, or updates its status, image and outcome if it is already stored
+func (r *BuildRepository) Save(ctx context.Context, build *domain.Build) error {
+	query := `
+		INSERT INTO builds (
+			id, app_id, status, source, source_url, git_ref, git_tag,
+			git_commit, commit_message, commit_author, compare_url, commits,
+			dockerfile_path, image_tag, image_id, build_args, error_message,
+			created_at, started_at, completed_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)
+		ON CONFLICT (id) DO UPDATE SET
+			status = EXCLUDED.status,
+			git_commit = EXCLUDED.git_commit,
+			image_tag = EXCLUDED.image_tag,
+			image_id = EXCLUDED.image_id,
+			error_message = EXCLUDED.error_message,
+			started_at = EXCLUDED.started_at,
+			completed_at = EXCLUDED.completed_at
+	`
+
+	_, err := dbFor(ctx, r.pool).Exec(ctx, query,
+		build.ID,
+		build.AppID,
+		string(build.Status),
+		string(build.Source),
+		build.SourceURL,
+		build.GitRef,
+		build.GitTag,
+		build.GitCommit,
+		build.CommitMessage,
+		build.CommitAuthor,
+		build.CompareURL,
+		buildCommits(build.Commits),
+		build.DockerfilePath,
+		build.ImageTag,
+		build.ImageID,
+		build.BuildArgs,
+		build.ErrorMessage,
+		build.CreatedAt,
+		build.StartedAt,
+		build.CompletedAt,
+	)
+
+	if err != nil {
+		r.logger.Error("Failed to save build",
+			zap.String("build_id", build.ID.String()),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	return nil
+}
+
+// buildCommits returns a build's commits for the commits column, which holds an empty
+// array rather than null
+func buildCommits(commits []domain.Commit) []domain.Commit {
+	if commits == nil {
+		return []domain.Commit{}
+	}
+	return commits
+}
+
 // GetByID retrieves a build by ID
 func (r *BuildRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Build, error) {
 	query := `
-		SELECT id, app_id, status, source, source_url, git_ref,
+		SELECT id, app_id, status, source, source_url, git_ref, git_tag,
+			   COALESCE(git_commit, ''), commit_message, commit_author, compare_url, commits,
 			   dockerfile_path, image_tag, image_id, build_args,
 			   error_message, created_at, started_at, completed_at
 		FROM builds
@@ -73,13 +143,19 @@ func (r *BuildRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Bu
 	var startedAt, completedAt *time.Time
 	var buildArgs map[string]string
 
-	err := r.pool.QueryRow(ctx, query, id).Scan(
+	err := dbFor(ctx, r.pool).QueryRow(ctx, query, id).Scan(
 		&build.ID,
 		&build.AppID,
 		&build.Status,
 		&build.Source,
 		&build.SourceURL,
 		&build.GitRef,
+		&build.GitTag,
+		&build.GitCommit,
+		&build.CommitMessage,
+		&build.CommitAuthor,
+		&build.CompareURL,
+		&build.Commits,
 		&build.DockerfilePath,
 		&build.ImageTag,
 		&build.ImageID,
@@ -108,7 +184,8 @@ func (r *BuildRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Bu
 // ListByApp retrieves all builds for an app
 func (r *BuildRepository) ListByApp(ctx context.Context, appID uuid.UUID, limit, offset int) ([]*domain.Build, error) {
 	query := `
-		SELECT id, app_id, status, source, source_url, git_ref,
+		SELECT id, app_id, status, source, source_url, git_ref, git_tag,
+			   COALESCE(git_commit, ''), commit_message, commit_author, compare_url, commits,
 			   dockerfile_path, image_tag, image_id, build_args,
 			   error_message, created_at, started_at, completed_at
 		FROM builds
@@ -117,7 +194,7 @@ func (r *BuildRepository) ListByApp(ctx context.Context, appID uuid.UUID, limit,
 		LIMIT $2 OFFSET $3
 	`
 
-	rows, err := r.pool.Query(ctx, query, appID, limit, offset)
+	rows, err := dbFor(ctx, r.pool).Query(ctx, query, appID, limit, offset)
 	if err != nil {
 		r.logger.Error("Failed to list builds", zap.Error(err))
 		return nil, err
@@ -137,6 +214,12 @@ func (r *BuildRepository) ListByApp(ctx context.Context, appID uuid.UUID, limit,
 			&build.Source,
 			&build.SourceURL,
 			&build.GitRef,
+			&build.GitTag,
+			&build.GitCommit,
+			&build.CommitMessage,
+			&build.CommitAuthor,
+			&build.CompareURL,
+			&build.Commits,
 			&build.DockerfilePath,
 			&build.ImageTag,
 			&build.ImageID,
@@ -163,7 +246,7 @@ func (r *BuildRepository) ListByApp(ctx context.Context, appID uuid.UUID, limit,
 // UpdateStatus updates the status of a build
 func (r *BuildRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status domain.BuildStatus) error {
 	query := `UPDATE builds SET status = $2 WHERE id = $1`
-	_, err := r.pool.Exec(ctx, query, id, string(status))
+	_, err := dbFor(ctx, r.pool).Exec(ctx, query, id, string(status))
 	if err != nil {
 		r.logger.Error("Failed to update build status", zap.Error(err))
 	}
@@ -173,7 +256,7 @@ func (r *BuildRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status
 // SetStarted marks a build as started
 func (r *BuildRepository) SetStarted(ctx context.Context, id uuid.UUID) error {
 	query := `UPDATE builds SET status = 'running', started_at = NOW() WHERE id = $1`
-	_, err := r.pool.Exec(ctx, query, id)
+	_, err := dbFor(ctx, r.pool).Exec(ctx, query, id)
 	if err != nil {
 		r.logger.Error("Failed to set build started", zap.Error(err))
 	}
@@ -187,7 +270,7 @@ func (r *BuildRepository) SetCompleted(ctx context.Context, id uuid.UUID, imageI
 		SET status = 'success', image_id = $2, image_tag = $3, completed_at = NOW()
 		WHERE id = $1
 	`
-	_, err := r.pool.Exec(ctx, query, id, imageID, imageTag)
+	_, err := dbFor(ctx, r.pool).Exec(ctx, query, id, imageID, imageTag)
 	if err != nil {
 		r.logger.Error("Failed to set build completed", zap.Error(err))
 	}
@@ -201,7 +284,7 @@ func (r *BuildRepository) SetFailed(ctx context.Context, id uuid.UUID, errorMess
 		SET status = 'failed', error_message = $2, completed_at = NOW()
 		WHERE id = $1
 	`
-	_, err := r.pool.Exec(ctx, query, id, errorMessage)
+	_, err := dbFor(ctx, r.pool).Exec(ctx, query, id, errorMessage)
 	if err != nil {
 		r.logger.Error("Failed to set build failed", zap.Error(err))
 	}
@@ -211,7 +294,8 @@ func (r *BuildRepository) SetFailed(ctx context.Context, id uuid.UUID, errorMess
 // GetLatestSuccessful gets the latest successful build for an app
 func (r *BuildRepository) GetLatestSuccessful(ctx context.Context, appID uuid.UUID) (*domain.Build, error) {
 	query := `
-		SELECT id, app_id, status, source, source_url, git_ref,
+		SELECT id, app_id, status, source, source_url, git_ref, git_tag,
+			   COALESCE(git_commit, ''), commit_message, commit_author, compare_url, commits,
 			   dockerfile_path, image_tag, image_id, build_args,
 			   error_message, created_at, started_at, completed_at
 		FROM builds
@@ -224,13 +308,19 @@ func (r *BuildRepository) GetLatestSuccessful(ctx context.Context, appID uuid.UU
 	var startedAt, completedAt *time.Time
 	var buildArgs map[string]string
 
-	err := r.pool.QueryRow(ctx, query, appID).Scan(
+	err := dbFor(ctx, r.pool).QueryRow(ctx, query, appID).Scan(
 		&build.ID,
 		&build.AppID,
 		&build.Status,
 		&build.Source,
 		&build.SourceURL,
 		&build.GitRef,
+		&build.GitTag,
+		&build.GitCommit,
+		&build.CommitMessage,
+		&build.CommitAuthor,
+		&build.CompareURL,
+		&build.Commits,
 		&build.DockerfilePath,
 		&build.ImageTag,
 		&build.ImageID,
@@ -259,7 +349,7 @@ func (r *BuildRepository) GetLatestSuccessful(ctx context.Context, appID uuid.UU
 // CountByApp counts builds for an app
 func (r *BuildRepository) CountByApp(ctx context.Context, appID uuid.UUID) (int64, error) {
 	var count int64
-	err := r.pool.QueryRow(ctx, "SELECT COUNT(*) FROM builds WHERE app_id = $1", appID).Scan(&count)
+	err := dbFor(ctx, r.pool).QueryRow(ctx, "SELECT COUNT(*) FROM builds WHERE app_id = $1", appID).Scan(&count)
 	if err != nil {
 		r.logger.Error("Failed to count builds", zap.Error(err))
 		return 0, err
@@ -269,7 +359,7 @@ func (r *BuildRepository) CountByApp(ctx context.Context, appID uuid.UUID) (int6
 
 // Delete deletes a build
 func (r *BuildRepository) Delete(ctx context.Context, id uuid.UUID) error {
-	_, err := r.pool.Exec(ctx, "DELETE FROM builds WHERE id = $1", id)
+	_, err := dbFor(ctx, r.pool).Exec(ctx, "DELETE FROM builds WHERE id = $1", id)
 	if err != nil {
 		r.logger.Error("Failed to delete build", zap.Error(err))
 	}
@@ -0,0 +1,168 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// certificateColumns lists the columns read by scanCertificate, in scan order
+const certificateColumns = `domain, domains, cert_pem, key_pem, issuer, not_before, not_after, created_at, updated_at`
+
+// CertificateRepository handles ACME account and certificate persistence in PostgreSQL
+type CertificateRepository struct {
+	pool   *pgxpool.Pool
+	logger *zap.Logger
+}
+
+// NewCertificateRepository creates a new certificate repository
+func NewCertificateRepository(pool *pgxpool.Pool, logger *zap.Logger) *CertificateRepository {
+	return &CertificateRepository{
+		pool:   pool,
+		logger: logger,
+	}
+}
+
+// GetAccount retrieves the ACME account for an email, or nil if none is registered
+func (r *CertificateRepository) GetAccount(ctx context.Context, email string) (*domain.ACMEAccount, error) {
+	query := `SELECT email, key_pem, uri, created_at FROM acme_accounts WHERE email = $1`
+
+	account := &domain.ACMEAccount{}
+	var keyPEM string
+	err := dbFor(ctx, r.pool).QueryRow(ctx, query, email).Scan(&account.Email, &keyPEM, &account.URI, &account.CreatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get ACME account: %w", err)
+	}
+	account.KeyPEM = []byte(keyPEM)
+	return account, nil
+}
+
+// SaveAccount stores an ACME account
+func (r *CertificateRepository) SaveAccount(ctx context.Context, account *domain.ACMEAccount) error {
+	query := `
+		INSERT INTO acme_accounts (email, key_pem, uri, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (email) DO UPDATE SET key_pem = EXCLUDED.key_pem, uri = EXCLUDED.uri
+	`
+
+	_, err := dbFor(ctx, r.pool).Exec(ctx, query, account.Email, string(account.KeyPEM), account.URI, account.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save ACME account: %w", err)
+	}
+	return nil
+}
+
+// GetCertificate retrieves a certificate by primary domain, or nil if none exists
+func (r *CertificateRepository) GetCertificate(ctx context.Context, primary string) (*domain.Certificate, error) {
+	query := `SELECT ` + certificateColumns + ` FROM certificates WHERE domain = $1`
+
+	cert, err := scanCertificate(dbFor(ctx, r.pool).QueryRow(ctx, query, primary))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get certificate: %w", err)
+	}
+	return cert, nil
+}
+
+// SaveCertificate stores a newly issued or renewed certificate
+func (r *CertificateRepository) SaveCertificate(ctx context.Context, cert *domain.Certificate) error {
+	query := `
+		INSERT INTO certificates (` + certificateColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (domain) DO UPDATE SET
+			domains = EXCLUDED.domains,
+			cert_pem = EXCLUDED.cert_pem,
+			key_pem = EXCLUDED.key_pem,
+			issuer = EXCLUDED.issuer,
+			not_before = EXCLUDED.not_before,
+			not_after = EXCLUDED.not_after,
+			updated_at = EXCLUDED.updated_at
+	`
+
+	_, err := dbFor(ctx, r.pool).Exec(ctx, query,
+		cert.Domain,
+		cert.Domains,
+		string(cert.CertPEM),
+		string(cert.KeyPEM),
+		cert.Issuer,
+		cert.NotBefore,
+		cert.NotAfter,
+		cert.CreatedAt,
+		cert.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save certificate: %w", err)
+	}
+
+	r.logger.Debug("Certificate saved", zap.String("domain", cert.Domain))
+	return nil
+}
+
+// ListCertificates returns every stored certificate
+func (r *CertificateRepository) ListCertificates(ctx context.Context) ([]*domain.Certificate, error) {
+	query := `SELECT ` + certificateColumns + ` FROM certificates ORDER BY not_after`
+
+	rows, err := dbFor(ctx, r.pool).Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list certificates: %w", err)
+	}
+	defer rows.Close()
+
+	certs := make([]*domain.Certificate, 0)
+	for rows.Next() {
+		cert, err := scanCertificate(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+
+	return certs, rows.Err()
+}
+
+// DeleteCertificate removes a certificate by primary domain
+func (r *CertificateRepository) DeleteCertificate(ctx context.Context, primary string) error {
+	result, err := dbFor(ctx, r.pool).Exec(ctx, `DELETE FROM certificates WHERE domain = $1`, primary)
+	if err != nil {
+		return fmt.Errorf("failed to delete certificate: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("certificate %w", domain.ErrNotFound)
+	}
+	return nil
+}
+
+// scanCertificate scans a row selected with certificateColumns into a Certificate
+func scanCertificate(row pgx.Row) (*domain.Certificate, error) {
+	cert := &domain.Certificate{}
+	var certPEM, keyPEM string
+
+	err := row.Scan(
+		&cert.Domain,
+		&cert.Domains,
+		&certPEM,
+		&keyPEM,
+		&cert.Issuer,
+		&cert.NotBefore,
+		&cert.NotAfter,
+		&cert.CreatedAt,
+		&cert.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	cert.CertPEM = []byte(certPEM)
+	cert.KeyPEM = []byte(keyPEM)
+	return cert, nil
+}
@@ -0,0 +1,138 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// changesChannel is the channel the change triggers of migration 046 notify on
+const changesChannel = "nanopaas_changes"
+
+// Reconnect backoff of the change listener after its connection fails
+const (
+	changeRetryMin = time.Second
+	changeRetryMax = 30 * time.Second
+)
+
+// Change is a row written by any instance, as notified by the database
+type Change struct {
+	Table  string    `json:"table"` // apps or projects
+	ID     uuid.UUID `json:"id"`
+	Origin string    `json:"origin"` // application_name of the writing connection
+}
+
+// ChangeListener relays changes to apps and projects made by other NanoPaaS instances
+// sharing the database, so each instance can refresh what it caches in memory. It holds
+// one connection of its own, taken out of the pool, for LISTEN.
+//
+// The pool's connections must set application_name to the listener's origin; changes
+// carrying it were made by this instance and are skipped.
+type ChangeListener struct {
+	pool   *pgxpool.Pool
+	origin string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	logger *zap.Logger
+}
+
+// NewChangeListener creates a listener for the changes of other instances; Start
+// connects it
+func NewChangeListener(pool *pgxpool.Pool, origin string, logger *zap.Logger) *ChangeListener {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &ChangeListener{
+		pool:   pool,
+		origin: origin,
+		ctx:    ctx,
+		cancel: cancel,
+		logger: logger,
+	}
+}
+
+// Start listens in the background, calling onChange for each change made elsewhere.
+// Notifications sent while the connection is down are lost, so after reconnecting it
+// calls resync, which should reload everything the changes would have refreshed.
+// Both run on the listener's goroutine, one at a time.
+func (l *ChangeListener) Start(onChange func(ctx context.Context, change Change), resync func(ctx context.Context)) {
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+
+		backoff := changeRetryMin
+		connected := false
+		for l.ctx.Err() == nil {
+			err := l.listen(func() {
+				if connected {
+					l.logger.Info("Change listener reconnected, resyncing")
+					resync(l.ctx)
+				}
+				connected = true
+				backoff = changeRetryMin
+			}, onChange)
+			if l.ctx.Err() != nil {
+				return
+			}
+
+			l.logger.Warn("Change listener disconnected", zap.Duration("retry_in", backoff), zap.Error(err))
+			select {
+			case <-l.ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, changeRetryMax)
+		}
+	}()
+
+	l.logger.Info("Listening for changes from other instances", zap.String("instance", l.origin))
+}
+
+// Stop stops listening and waits for the listener to exit
+func (l *ChangeListener) Stop() {
+	l.cancel()
+	l.wg.Wait()
+}
+
+// listen runs LISTEN on a dedicated connection and relays notifications until the
+// connection fails or the listener stops. listening runs once LISTEN has succeeded.
+func (l *ChangeListener) listen(listening func(), onChange func(ctx context.Context, change Change)) error {
+	poolConn, err := l.pool.Acquire(l.ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	// A LISTENing connection must not go back to the pool for queries to reuse
+	conn := poolConn.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(l.ctx, "LISTEN "+changesChannel); err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+	listening()
+
+	for {
+		notification, err := conn.WaitForNotification(l.ctx)
+		if err != nil {
+			return err
+		}
+
+		var change Change
+		if err := json.Unmarshal([]byte(notification.Payload), &change); err != nil {
+			l.logger.Warn("Ignoring malformed change notification",
+				zap.String("payload", notification.Payload),
+				zap.Error(err),
+			)
+			continue
+		}
+		if change.Origin == l.origin {
+			continue
+		}
+		onChange(l.ctx, change)
+	}
+}
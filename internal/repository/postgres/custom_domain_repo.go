@@ -0,0 +1,155 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// customDomainColumns lists the columns read by scanCustomDomain, in scan order
+const customDomainColumns = `id, app_id, hostname, status, token, txt_record, last_error, last_checked_at, verified_at, created_at`
+
+// CustomDomainRepository handles custom domain persistence in PostgreSQL
+type CustomDomainRepository struct {
+	pool   *pgxpool.Pool
+	logger *zap.Logger
+}
+
+// NewCustomDomainRepository creates a new custom domain repository
+func NewCustomDomainRepository(pool *pgxpool.Pool, logger *zap.Logger) *CustomDomainRepository {
+	return &CustomDomainRepository{
+		pool:   pool,
+		logger: logger,
+	}
+}
+
+// Create stores a new custom domain. A host name already verified, or already claimed
+// by the same app, fails with domain.ErrConflict.
+func (r *CustomDomainRepository) Create(ctx context.Context, d *domain.CustomDomain) error {
+	query := `
+		INSERT INTO custom_domains (` + customDomainColumns + `)
+		SELECT $1::uuid, $2::uuid, $3::varchar, $4::varchar, $5::varchar, $6::varchar, $7::text, $8::timestamptz, $9::timestamptz, $10::timestamptz
+		WHERE NOT EXISTS (SELECT 1 FROM custom_domains WHERE hostname = $3 AND status = 'verified')
+	`
+
+	result, err := dbFor(ctx, r.pool).Exec(ctx, query,
+		d.ID,
+		d.AppID,
+		d.Hostname,
+		string(d.Status),
+		d.Token,
+		d.TXTRecord,
+		d.LastError,
+		d.LastCheckedAt,
+		d.VerifiedAt,
+		d.CreatedAt,
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("custom domain %w", domain.ErrConflict)
+		}
+		return fmt.Errorf("failed to create custom domain: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("custom domain %w", domain.ErrConflict)
+	}
+
+	r.logger.Debug("Custom domain created", zap.String("hostname", d.Hostname))
+	return nil
+}
+
+// Update saves a custom domain's verification state. Verifying a host name another app
+// has verified fails with domain.ErrConflict.
+func (r *CustomDomainRepository) Update(ctx context.Context, d *domain.CustomDomain) error {
+	query := `
+		UPDATE custom_domains SET
+			status = $2,
+			last_error = $3,
+			last_checked_at = $4,
+			verified_at = $5
+		WHERE id = $1
+	`
+
+	result, err := dbFor(ctx, r.pool).Exec(ctx, query,
+		d.ID,
+		string(d.Status),
+		d.LastError,
+		d.LastCheckedAt,
+		d.VerifiedAt,
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("custom domain %w", domain.ErrConflict)
+		}
+		return fmt.Errorf("failed to update custom domain: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("custom domain %w", domain.ErrNotFound)
+	}
+	return nil
+}
+
+// Delete removes a custom domain
+func (r *CustomDomainRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	result, err := dbFor(ctx, r.pool).Exec(ctx, `DELETE FROM custom_domains WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete custom domain: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("custom domain %w", domain.ErrNotFound)
+	}
+	return nil
+}
+
+// ListForApp returns an app's custom domains, oldest first
+func (r *CustomDomainRepository) ListForApp(ctx context.Context, appID uuid.UUID) ([]*domain.CustomDomain, error) {
+	query := `SELECT ` + customDomainColumns + ` FROM custom_domains WHERE app_id = $1 ORDER BY created_at`
+
+	rows, err := dbFor(ctx, r.pool).Query(ctx, query, appID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list custom domains: %w", err)
+	}
+	defer rows.Close()
+
+	domains := make([]*domain.CustomDomain, 0)
+	for rows.Next() {
+		d, err := scanCustomDomain(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan custom domain: %w", err)
+		}
+		domains = append(domains, d)
+	}
+
+	return domains, rows.Err()
+}
+
+// scanCustomDomain scans a row selected with customDomainColumns into a CustomDomain
+func scanCustomDomain(row pgx.Row) (*domain.CustomDomain, error) {
+	d := &domain.CustomDomain{}
+	var status string
+
+	err := row.Scan(
+		&d.ID,
+		&d.AppID,
+		&d.Hostname,
+		&status,
+		&d.Token,
+		&d.TXTRecord,
+		&d.LastError,
+		&d.LastCheckedAt,
+		&d.VerifiedAt,
+		&d.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	d.Status = domain.CustomDomainStatus(status)
+	return d, nil
+}
@@ -0,0 +1,139 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// deployTokenColumns lists the columns read by scanDeployToken, in scan order
+const deployTokenColumns = `id, app_id, name, prefix, token_hash, created_by, expires_at, last_used_at, created_at`
+
+// DeployTokenRepository handles deploy token persistence in PostgreSQL
+type DeployTokenRepository struct {
+	pool   *pgxpool.Pool
+	logger *zap.Logger
+}
+
+// NewDeployTokenRepository creates a new deploy token repository
+func NewDeployTokenRepository(pool *pgxpool.Pool, logger *zap.Logger) *DeployTokenRepository {
+	return &DeployTokenRepository{
+		pool:   pool,
+		logger: logger,
+	}
+}
+
+// Create stores a new deploy token
+func (r *DeployTokenRepository) Create(ctx context.Context, t *domain.DeployToken) error {
+	query := `
+		INSERT INTO deploy_tokens (` + deployTokenColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+
+	_, err := dbFor(ctx, r.pool).Exec(ctx, query,
+		t.ID,
+		t.AppID,
+		t.Name,
+		t.Prefix,
+		t.Hash,
+		t.CreatedBy,
+		t.ExpiresAt,
+		t.LastUsedAt,
+		t.CreatedAt,
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("deploy token %w", domain.ErrConflict)
+		}
+		return fmt.Errorf("failed to create deploy token: %w", err)
+	}
+
+	r.logger.Debug("Deploy token created", zap.String("token_id", t.ID.String()))
+	return nil
+}
+
+// GetByHash retrieves a deploy token by the hash of the token
+func (r *DeployTokenRepository) GetByHash(ctx context.Context, hash string) (*domain.DeployToken, error) {
+	query := `SELECT ` + deployTokenColumns + ` FROM deploy_tokens WHERE token_hash = $1`
+
+	t, err := scanDeployToken(dbFor(ctx, r.pool).QueryRow(ctx, query, hash))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("deploy token %w", domain.ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get deploy token: %w", err)
+	}
+	return t, nil
+}
+
+// ListByApp returns an app's deploy tokens, newest first
+func (r *DeployTokenRepository) ListByApp(ctx context.Context, appID uuid.UUID) ([]*domain.DeployToken, error) {
+	query := `SELECT ` + deployTokenColumns + ` FROM deploy_tokens WHERE app_id = $1 ORDER BY created_at DESC`
+
+	rows, err := dbFor(ctx, r.pool).Query(ctx, query, appID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deploy tokens: %w", err)
+	}
+	defer rows.Close()
+
+	tokens := make([]*domain.DeployToken, 0)
+	for rows.Next() {
+		t, err := scanDeployToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan deploy token: %w", err)
+		}
+		tokens = append(tokens, t)
+	}
+
+	return tokens, rows.Err()
+}
+
+// Delete removes one of an app's deploy tokens
+func (r *DeployTokenRepository) Delete(ctx context.Context, appID, id uuid.UUID) error {
+	result, err := dbFor(ctx, r.pool).Exec(ctx, `DELETE FROM deploy_tokens WHERE id = $1 AND app_id = $2`, id, appID)
+	if err != nil {
+		return fmt.Errorf("failed to delete deploy token: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("deploy token %w", domain.ErrNotFound)
+	}
+	return nil
+}
+
+// TouchLastUsed records when a deploy token was last used
+func (r *DeployTokenRepository) TouchLastUsed(ctx context.Context, id uuid.UUID, at time.Time) error {
+	_, err := dbFor(ctx, r.pool).Exec(ctx, `UPDATE deploy_tokens SET last_used_at = $2 WHERE id = $1`, id, at)
+	if err != nil {
+		return fmt.Errorf("failed to update deploy token: %w", err)
+	}
+	return nil
+}
+
+// scanDeployToken scans a row selected with deployTokenColumns into a DeployToken
+func scanDeployToken(row pgx.Row) (*domain.DeployToken, error) {
+	t := &domain.DeployToken{}
+
+	err := row.Scan(
+		&t.ID,
+		&t.AppID,
+		&t.Name,
+		&t.Prefix,
+		&t.Hash,
+		&t.CreatedBy,
+		&t.ExpiresAt,
+		&t.LastUsedAt,
+		&t.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
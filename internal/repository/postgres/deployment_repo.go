@@ -7,7 +7,6 @@ import (
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/lib/pq"
 	"go.uber.org/zap"
 
 	"github.com/nanopaas/nanopaas/internal/domain"
@@ -36,14 +35,14 @@ func (r *DeploymentRepository) Create(ctx context.Context, deployment *domain.De
 		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 	`
 
-	_, err := r.pool.Exec(ctx, query,
+	_, err := dbFor(ctx, r.pool).Exec(ctx, query,
 		deployment.ID,
 		deployment.AppID,
 		deployment.BuildID,
 		deployment.ImageID,
 		string(deployment.Status),
 		deployment.Replicas,
-		pq.Array(deployment.ContainerIDs),
+		deployment.ContainerIDs,
 		deployment.CreatedAt,
 	)
 
@@ -63,7 +62,7 @@ func (r *DeploymentRepository) GetByID(ctx context.Context, id uuid.UUID) (*doma
 	query := `
 		SELECT id, app_id, build_id, image_id, status,
 			   target_replicas, current_replicas, container_ids,
-			   error_message, created_at, started_at, completed_at
+			   error_message, last_exit, pinned, created_at, started_at, completed_at
 		FROM deployments
 		WHERE id = $1
 	`
@@ -73,7 +72,7 @@ func (r *DeploymentRepository) GetByID(ctx context.Context, id uuid.UUID) (*doma
 	var containerIDs []string
 	var targetReplicas, currentReplicas int
 
-	err := r.pool.QueryRow(ctx, query, id).Scan(
+	err := dbFor(ctx, r.pool).QueryRow(ctx, query, id).Scan(
 		&deployment.ID,
 		&deployment.AppID,
 		&deployment.BuildID,
@@ -81,8 +80,10 @@ func (r *DeploymentRepository) GetByID(ctx context.Context, id uuid.UUID) (*doma
 		&deployment.Status,
 		&targetReplicas,
 		&currentReplicas,
-		pq.Array(&containerIDs),
+		&containerIDs,
 		&deployment.ErrorMessage,
+		&deployment.LastExit,
+		&deployment.Pinned,
 		&deployment.CreatedAt,
 		&startedAt,
 		&completedAt,
@@ -109,14 +110,14 @@ func (r *DeploymentRepository) ListByApp(ctx context.Context, appID uuid.UUID, l
 	query := `
 		SELECT id, app_id, build_id, image_id, status,
 			   target_replicas, current_replicas, container_ids,
-			   error_message, created_at, started_at, completed_at
+			   error_message, last_exit, pinned, created_at, started_at, completed_at
 		FROM deployments
 		WHERE app_id = $1
 		ORDER BY created_at DESC
 		LIMIT $2 OFFSET $3
 	`
 
-	rows, err := r.pool.Query(ctx, query, appID, limit, offset)
+	rows, err := dbFor(ctx, r.pool).Query(ctx, query, appID, limit, offset)
 	if err != nil {
 		r.logger.Error("Failed to list deployments", zap.Error(err))
 		return nil, err
@@ -138,8 +139,10 @@ func (r *DeploymentRepository) ListByApp(ctx context.Context, appID uuid.UUID, l
 			&deployment.Status,
 			&targetReplicas,
 			&currentReplicas,
-			pq.Array(&containerIDs),
+			&containerIDs,
 			&deployment.ErrorMessage,
+			&deployment.LastExit,
+			&deployment.Pinned,
 			&deployment.CreatedAt,
 			&startedAt,
 			&completedAt,
@@ -164,7 +167,7 @@ func (r *DeploymentRepository) GetActive(ctx context.Context, appID uuid.UUID) (
 	query := `
 		SELECT id, app_id, build_id, image_id, status,
 			   target_replicas, current_replicas, container_ids,
-			   error_message, created_at, started_at, completed_at
+			   error_message, last_exit, pinned, created_at, started_at, completed_at
 		FROM deployments
 		WHERE app_id = $1 AND status IN ('running', 'pending', 'deploying')
 		ORDER BY created_at DESC
@@ -176,7 +179,7 @@ func (r *DeploymentRepository) GetActive(ctx context.Context, appID uuid.UUID) (
 	var containerIDs []string
 	var targetReplicas, currentReplicas int
 
-	err := r.pool.QueryRow(ctx, query, appID).Scan(
+	err := dbFor(ctx, r.pool).QueryRow(ctx, query, appID).Scan(
 		&deployment.ID,
 		&deployment.AppID,
 		&deployment.BuildID,
@@ -184,8 +187,10 @@ func (r *DeploymentRepository) GetActive(ctx context.Context, appID uuid.UUID) (
 		&deployment.Status,
 		&targetReplicas,
 		&currentReplicas,
-		pq.Array(&containerIDs),
+		&containerIDs,
 		&deployment.ErrorMessage,
+		&deployment.LastExit,
+		&deployment.Pinned,
 		&deployment.CreatedAt,
 		&startedAt,
 		&completedAt,
@@ -210,7 +215,7 @@ func (r *DeploymentRepository) GetActive(ctx context.Context, appID uuid.UUID) (
 // UpdateStatus updates the status of a deployment
 func (r *DeploymentRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status domain.DeploymentStatus) error {
 	query := `UPDATE deployments SET status = $2 WHERE id = $1`
-	_, err := r.pool.Exec(ctx, query, id, string(status))
+	_, err := dbFor(ctx, r.pool).Exec(ctx, query, id, string(status))
 	if err != nil {
 		r.logger.Error("Failed to update deployment status", zap.Error(err))
 	}
@@ -220,7 +225,7 @@ func (r *DeploymentRepository) UpdateStatus(ctx context.Context, id uuid.UUID, s
 // SetStarted marks a deployment as started
 func (r *DeploymentRepository) SetStarted(ctx context.Context, id uuid.UUID) error {
 	query := `UPDATE deployments SET status = 'deploying', started_at = NOW() WHERE id = $1`
-	_, err := r.pool.Exec(ctx, query, id)
+	_, err := dbFor(ctx, r.pool).Exec(ctx, query, id)
 	if err != nil {
 		r.logger.Error("Failed to set deployment started", zap.Error(err))
 	}
@@ -234,7 +239,7 @@ func (r *DeploymentRepository) SetCompleted(ctx context.Context, id uuid.UUID, c
 		SET status = 'running', container_ids = $2, current_replicas = $3, completed_at = NOW()
 		WHERE id = $1
 	`
-	_, err := r.pool.Exec(ctx, query, id, pq.Array(containerIDs), len(containerIDs))
+	_, err := dbFor(ctx, r.pool).Exec(ctx, query, id, containerIDs, len(containerIDs))
 	if err != nil {
 		r.logger.Error("Failed to set deployment completed", zap.Error(err))
 	}
@@ -248,17 +253,37 @@ func (r *DeploymentRepository) SetFailed(ctx context.Context, id uuid.UUID, erro
 		SET status = 'failed', error_message = $2, completed_at = NOW()
 		WHERE id = $1
 	`
-	_, err := r.pool.Exec(ctx, query, id, errorMessage)
+	_, err := dbFor(ctx, r.pool).Exec(ctx, query, id, errorMessage)
 	if err != nil {
 		r.logger.Error("Failed to set deployment failed", zap.Error(err))
 	}
 	return err
 }
 
+// SetLastExit records the most recent container exit for a deployment
+func (r *DeploymentRepository) SetLastExit(ctx context.Context, id uuid.UUID, exit *domain.ContainerExit) error {
+	query := `UPDATE deployments SET last_exit = $2 WHERE id = $1`
+	_, err := dbFor(ctx, r.pool).Exec(ctx, query, id, exit)
+	if err != nil {
+		r.logger.Error("Failed to set deployment last exit", zap.Error(err))
+	}
+	return err
+}
+
+// SetPinned pins or unpins a deployment
+func (r *DeploymentRepository) SetPinned(ctx context.Context, id uuid.UUID, pinned bool) error {
+	query := `UPDATE deployments SET pinned = $2 WHERE id = $1`
+	_, err := dbFor(ctx, r.pool).Exec(ctx, query, id, pinned)
+	if err != nil {
+		r.logger.Error("Failed to set deployment pinned", zap.Error(err))
+	}
+	return err
+}
+
 // SetStopped marks a deployment as stopped
 func (r *DeploymentRepository) SetStopped(ctx context.Context, id uuid.UUID) error {
 	query := `UPDATE deployments SET status = 'stopped', current_replicas = 0, completed_at = NOW() WHERE id = $1`
-	_, err := r.pool.Exec(ctx, query, id)
+	_, err := dbFor(ctx, r.pool).Exec(ctx, query, id)
 	if err != nil {
 		r.logger.Error("Failed to set deployment stopped", zap.Error(err))
 	}
@@ -268,7 +293,7 @@ func (r *DeploymentRepository) SetStopped(ctx context.Context, id uuid.UUID) err
 // CountByApp counts deployments for an app
 func (r *DeploymentRepository) CountByApp(ctx context.Context, appID uuid.UUID) (int64, error) {
 	var count int64
-	err := r.pool.QueryRow(ctx, "SELECT COUNT(*) FROM deployments WHERE app_id = $1", appID).Scan(&count)
+	err := dbFor(ctx, r.pool).QueryRow(ctx, "SELECT COUNT(*) FROM deployments WHERE app_id = $1", appID).Scan(&count)
 	if err != nil {
 		r.logger.Error("Failed to count deployments", zap.Error(err))
 		return 0, err
@@ -278,7 +303,7 @@ func (r *DeploymentRepository) CountByApp(ctx context.Context, appID uuid.UUID)
 
 // Delete deletes a deployment
 func (r *DeploymentRepository) Delete(ctx context.Context, id uuid.UUID) error {
-	_, err := r.pool.Exec(ctx, "DELETE FROM deployments WHERE id = $1", id)
+	_, err := dbFor(ctx, r.pool).Exec(ctx, "DELETE FROM deployments WHERE id = $1", id)
 	if err != nil {
 		r.logger.Error("Failed to delete deployment", zap.Error(err))
 	}
@@ -292,7 +317,7 @@ func (r *DeploymentRepository) StopAllForApp(ctx context.Context, appID uuid.UUI
 		SET status = 'stopped', current_replicas = 0, completed_at = NOW()
 		WHERE app_id = $1 AND status IN ('running', 'pending', 'deploying')
 	`
-	_, err := r.pool.Exec(ctx, query, appID)
+	_, err := dbFor(ctx, r.pool).Exec(ctx, query, appID)
 	if err != nil {
 		r.logger.Error("Failed to stop all deployments for app", zap.Error(err))
 	}
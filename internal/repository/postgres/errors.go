@@ -0,0 +1,24 @@
+package postgres
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// uniqueViolation is the SQLSTATE Postgres reports when an insert hits a unique constraint
+const uniqueViolation = "23505"
+
+// isUniqueViolation reports whether err came from a unique constraint
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation
+}
+
+// isRejectedRow reports whether err is Postgres refusing a row's values: a data exception,
+// such as a NUL byte in text, or a constraint the row breaks, such as having no partition
+func isRejectedRow(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && (strings.HasPrefix(pgErr.Code, "22") || strings.HasPrefix(pgErr.Code, "23"))
+}
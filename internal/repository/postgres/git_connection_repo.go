@@ -0,0 +1,128 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// gitConnectionColumns lists the columns scanGitConnection reads, in order
+const gitConnectionColumns = `user_id, provider, username, token, refresh_token, expires_at, created_at, updated_at`
+
+// GitConnectionRepository handles users' git provider tokens
+type GitConnectionRepository struct {
+	pool   *pgxpool.Pool
+	logger *zap.Logger
+}
+
+// NewGitConnectionRepository creates a new git connection repository
+func NewGitConnectionRepository(pool *pgxpool.Pool, logger *zap.Logger) *GitConnectionRepository {
+	return &GitConnectionRepository{
+		pool:   pool,
+		logger: logger,
+	}
+}
+
+// Save stores a user's connection to a provider, replacing any earlier one
+func (r *GitConnectionRepository) Save(ctx context.Context, conn *domain.GitConnection) error {
+	query := `
+		INSERT INTO git_connections (user_id, provider, username, token, refresh_token, expires_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (user_id, provider) DO UPDATE SET
+			username = EXCLUDED.username,
+			token = EXCLUDED.token,
+			refresh_token = EXCLUDED.refresh_token,
+			expires_at = EXCLUDED.expires_at,
+			updated_at = EXCLUDED.updated_at
+	`
+
+	_, err := dbFor(ctx, r.pool).Exec(ctx, query,
+		conn.UserID,
+		conn.Provider,
+		conn.Username,
+		conn.Token,
+		conn.RefreshToken,
+		conn.ExpiresAt,
+		conn.CreatedAt,
+		conn.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save git connection: %w", err)
+	}
+
+	r.logger.Debug("Git connection saved", zap.String("user_id", conn.UserID.String()), zap.String("provider", conn.Provider))
+	return nil
+}
+
+// Get returns a user's connection to a provider
+func (r *GitConnectionRepository) Get(ctx context.Context, userID uuid.UUID, provider string) (*domain.GitConnection, error) {
+	query := `SELECT ` + gitConnectionColumns + ` FROM git_connections WHERE user_id = $1 AND provider = $2`
+
+	conn, err := scanGitConnection(dbFor(ctx, r.pool).QueryRow(ctx, query, userID, provider))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("git connection %w", domain.ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get git connection: %w", err)
+	}
+	return conn, nil
+}
+
+// ListByUser returns a user's connections
+func (r *GitConnectionRepository) ListByUser(ctx context.Context, userID uuid.UUID) ([]*domain.GitConnection, error) {
+	query := `SELECT ` + gitConnectionColumns + ` FROM git_connections WHERE user_id = $1 ORDER BY provider`
+
+	rows, err := dbFor(ctx, r.pool).Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list git connections: %w", err)
+	}
+	defer rows.Close()
+
+	var conns []*domain.GitConnection
+	for rows.Next() {
+		conn, err := scanGitConnection(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan git connection: %w", err)
+		}
+		conns = append(conns, conn)
+	}
+	return conns, rows.Err()
+}
+
+// Delete removes a user's connection to a provider
+func (r *GitConnectionRepository) Delete(ctx context.Context, userID uuid.UUID, provider string) error {
+	result, err := dbFor(ctx, r.pool).Exec(ctx, `DELETE FROM git_connections WHERE user_id = $1 AND provider = $2`, userID, provider)
+	if err != nil {
+		return fmt.Errorf("failed to delete git connection: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("git connection %w", domain.ErrNotFound)
+	}
+	return nil
+}
+
+// scanGitConnection scans a row selected with gitConnectionColumns
+func scanGitConnection(row pgx.Row) (*domain.GitConnection, error) {
+	conn := &domain.GitConnection{}
+	err := row.Scan(
+		&conn.UserID,
+		&conn.Provider,
+		&conn.Username,
+		&conn.Token,
+		&conn.RefreshToken,
+		&conn.ExpiresAt,
+		&conn.CreatedAt,
+		&conn.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
@@ -0,0 +1,163 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// incidentColumns lists the columns read by scanIncident, in scan order
+const incidentColumns = `id, app_id, title, status, lockdown, pinned_deployment_id, diagnostics, timeline,
+			opened_by, opened_at, resolved_by, resolved_at`
+
+// IncidentRepository handles incident persistence in PostgreSQL
+type IncidentRepository struct {
+	pool   *pgxpool.Pool
+	logger *zap.Logger
+}
+
+// NewIncidentRepository creates a new incident repository
+func NewIncidentRepository(pool *pgxpool.Pool, logger *zap.Logger) *IncidentRepository {
+	return &IncidentRepository{
+		pool:   pool,
+		logger: logger,
+	}
+}
+
+// Create stores a new incident
+func (r *IncidentRepository) Create(ctx context.Context, inc *domain.Incident) error {
+	query := `
+		INSERT INTO incidents (` + incidentColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	`
+
+	_, err := dbFor(ctx, r.pool).Exec(ctx, query,
+		inc.ID,
+		inc.AppID,
+		inc.Title,
+		string(inc.Status),
+		string(inc.Lockdown),
+		inc.PinnedDeploymentID,
+		inc.Diagnostics,
+		inc.Timeline,
+		inc.OpenedBy,
+		inc.OpenedAt,
+		inc.ResolvedBy,
+		inc.ResolvedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create incident: %w", err)
+	}
+
+	r.logger.Debug("Incident created", zap.String("incident_id", inc.ID.String()))
+	return nil
+}
+
+// Update saves an incident's status, diagnostics and timeline
+func (r *IncidentRepository) Update(ctx context.Context, inc *domain.Incident) error {
+	query := `
+		UPDATE incidents SET
+			status = $2,
+			lockdown = $3,
+			pinned_deployment_id = $4,
+			diagnostics = $5,
+			timeline = $6,
+			resolved_by = $7,
+			resolved_at = $8
+		WHERE id = $1
+	`
+
+	result, err := dbFor(ctx, r.pool).Exec(ctx, query,
+		inc.ID,
+		string(inc.Status),
+		string(inc.Lockdown),
+		inc.PinnedDeploymentID,
+		inc.Diagnostics,
+		inc.Timeline,
+		inc.ResolvedBy,
+		inc.ResolvedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update incident: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("incident %w", domain.ErrNotFound)
+	}
+	return nil
+}
+
+// GetByID retrieves an incident by ID
+func (r *IncidentRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Incident, error) {
+	query := `SELECT ` + incidentColumns + ` FROM incidents WHERE id = $1`
+
+	inc, err := scanIncident(dbFor(ctx, r.pool).QueryRow(ctx, query, id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("incident %w", domain.ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get incident: %w", err)
+	}
+	return inc, nil
+}
+
+// ListForApp returns an app's incidents, newest first
+func (r *IncidentRepository) ListForApp(ctx context.Context, appID uuid.UUID, limit int) ([]*domain.Incident, error) {
+	query := `
+		SELECT ` + incidentColumns + `
+		FROM incidents
+		WHERE app_id = $1
+		ORDER BY opened_at DESC
+		LIMIT $2
+	`
+
+	rows, err := dbFor(ctx, r.pool).Query(ctx, query, appID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list incidents: %w", err)
+	}
+	defer rows.Close()
+
+	incidents := make([]*domain.Incident, 0)
+	for rows.Next() {
+		inc, err := scanIncident(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan incident: %w", err)
+		}
+		incidents = append(incidents, inc)
+	}
+
+	return incidents, rows.Err()
+}
+
+// scanIncident scans a row selected with incidentColumns into an Incident
+func scanIncident(row pgx.Row) (*domain.Incident, error) {
+	inc := &domain.Incident{}
+	var status, lockdown string
+
+	err := row.Scan(
+		&inc.ID,
+		&inc.AppID,
+		&inc.Title,
+		&status,
+		&lockdown,
+		&inc.PinnedDeploymentID,
+		&inc.Diagnostics,
+		&inc.Timeline,
+		&inc.OpenedBy,
+		&inc.OpenedAt,
+		&inc.ResolvedBy,
+		&inc.ResolvedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	inc.Status = domain.IncidentStatus(status)
+	inc.Lockdown = domain.LockdownMode(lockdown)
+	return inc, nil
+}
@@ -0,0 +1,110 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// loginEventColumns lists the columns read by scanLoginEvent, in scan order
+const loginEventColumns = `id, user_id, provider, success, reason, session_id, ip, user_agent, created_at`
+
+// LoginEventRepository handles the sign-in audit trail in PostgreSQL
+type LoginEventRepository struct {
+	pool   *pgxpool.Pool
+	logger *zap.Logger
+}
+
+// NewLoginEventRepository creates a new login event repository
+func NewLoginEventRepository(pool *pgxpool.Pool, logger *zap.Logger) *LoginEventRepository {
+	return &LoginEventRepository{
+		pool:   pool,
+		logger: logger,
+	}
+}
+
+// Create stores a login event
+func (r *LoginEventRepository) Create(ctx context.Context, e *domain.LoginEvent) error {
+	query := `
+		INSERT INTO login_events (` + loginEventColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+
+	_, err := dbFor(ctx, r.pool).Exec(ctx, query,
+		e.ID,
+		e.UserID,
+		string(e.Provider),
+		e.Success,
+		e.Reason,
+		e.SessionID,
+		e.IP,
+		e.UserAgent,
+		e.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create login event: %w", err)
+	}
+	return nil
+}
+
+// ListByUser returns a page of a user's login events, newest first, and how many there are
+func (r *LoginEventRepository) ListByUser(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*domain.LoginEvent, int, error) {
+	var total int
+	if err := dbFor(ctx, r.pool).QueryRow(ctx, `SELECT COUNT(*) FROM login_events WHERE user_id = $1`, userID).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count login events: %w", err)
+	}
+
+	query := `
+		SELECT ` + loginEventColumns + ` FROM login_events
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	rows, err := dbFor(ctx, r.pool).Query(ctx, query, userID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list login events: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]*domain.LoginEvent, 0)
+	for rows.Next() {
+		e, err := scanLoginEvent(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan login event: %w", err)
+		}
+		events = append(events, e)
+	}
+
+	return events, total, rows.Err()
+}
+
+// scanLoginEvent scans a row selected with loginEventColumns into a LoginEvent
+func scanLoginEvent(row pgx.Row) (*domain.LoginEvent, error) {
+	e := &domain.LoginEvent{}
+	var provider string
+
+	err := row.Scan(
+		&e.ID,
+		&e.UserID,
+		&provider,
+		&e.Success,
+		&e.Reason,
+		&e.SessionID,
+		&e.IP,
+		&e.UserAgent,
+		&e.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	e.Provider = domain.LoginProvider(provider)
+	return e, nil
+}
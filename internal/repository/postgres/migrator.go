@@ -0,0 +1,227 @@
+package postgres
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// migrationLockID is the advisory lock held while migrating, so replicas starting
+// together apply each migration once
+const migrationLockID int64 = 0x6e616e6f70616173 // "nanopaas"
+
+// Migration is one schema migration file
+type Migration struct {
+	Version  int
+	Name     string
+	Checksum string // sha256 of the file
+	SQL      string
+}
+
+// MigrationStatus is a migration and whether it has been applied
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+	Modified  bool // the file changed after it was applied
+}
+
+// Migrator applies the schema migrations in files to the database
+type Migrator struct {
+	pool   *pgxpool.Pool
+	files  fs.FS
+	logger *zap.Logger
+}
+
+// NewMigrator creates a migrator for the NNN_name.sql files at the root of files
+func NewMigrator(pool *pgxpool.Pool, files fs.FS, logger *zap.Logger) *Migrator {
+	return &Migrator{
+		pool:   pool,
+		files:  files,
+		logger: logger,
+	}
+}
+
+// Migrations returns the migration files in version order
+func (m *Migrator) Migrations() ([]Migration, error) {
+	names, err := fs.Glob(m.files, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make([]Migration, 0, len(names))
+	seen := make(map[int]string)
+	for _, name := range names {
+		prefix, _, ok := strings.Cut(name, "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: name must start with a version, e.g. 001_description.sql", name)
+		}
+		if other, dup := seen[version]; dup {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", other, name, version)
+		}
+		seen[version] = name
+
+		data, err := fs.ReadFile(m.files, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", name, err)
+		}
+		sum := sha256.Sum256(data)
+		migrations = append(migrations, Migration{
+			Version:  version,
+			Name:     strings.TrimSuffix(path.Base(name), ".sql"),
+			Checksum: hex.EncodeToString(sum[:]),
+			SQL:      string(data),
+		})
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Up applies every pending migration in version order, each in its own transaction,
+// and returns how many were applied. A failed migration is rolled back and stops the run.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	migrations, err := m.Migrations()
+	if err != nil {
+		return 0, err
+	}
+
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		return 0, fmt.Errorf("failed to lock migrations: %w", err)
+	}
+	defer func() {
+		// The lock is released with the session if this fails
+		if _, err := conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockID); err != nil {
+			m.logger.Warn("Failed to unlock migrations", zap.Error(err))
+		}
+	}()
+
+	if err := ensureMigrationTable(ctx, conn.Exec); err != nil {
+		return 0, err
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, migration := range migrations {
+		if status, ok := applied[migration.Version]; ok {
+			if status.Checksum != migration.Checksum {
+				m.logger.Warn("Applied migration has changed",
+					zap.Int("version", migration.Version),
+					zap.String("name", migration.Name),
+				)
+			}
+			continue
+		}
+
+		start := time.Now()
+		tx, err := conn.Begin(ctx)
+		if err != nil {
+			return count, fmt.Errorf("failed to begin migration %s: %w", migration.Name, err)
+		}
+		// Without arguments, the file runs as one multi-statement query
+		if _, err := tx.Exec(ctx, migration.SQL); err != nil {
+			tx.Rollback(ctx)
+			return count, fmt.Errorf("migration %s failed: %w", migration.Name, err)
+		}
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)
+		`, migration.Version, migration.Name, migration.Checksum); err != nil {
+			tx.Rollback(ctx)
+			return count, fmt.Errorf("failed to record migration %s: %w", migration.Name, err)
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return count, fmt.Errorf("failed to commit migration %s: %w", migration.Name, err)
+		}
+
+		count++
+		m.logger.Info("Applied migration",
+			zap.Int("version", migration.Version),
+			zap.String("name", migration.Name),
+			zap.Duration("duration", time.Since(start)),
+		)
+	}
+	return count, nil
+}
+
+// Status returns every migration file and when it was applied
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	migrations, err := m.Migrations()
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureMigrationTable(ctx, m.pool.Exec); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, len(migrations))
+	for i, migration := range migrations {
+		statuses[i].Migration = migration
+		if status, ok := applied[migration.Version]; ok {
+			statuses[i].AppliedAt = status.AppliedAt
+			statuses[i].Modified = status.Checksum != migration.Checksum
+		}
+	}
+	return statuses, nil
+}
+
+// applied returns the recorded migrations by version
+func (m *Migrator) applied(ctx context.Context) (map[int]MigrationStatus, error) {
+	rows, err := m.pool.Query(ctx, `SELECT version, name, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]MigrationStatus)
+	for rows.Next() {
+		var status MigrationStatus
+		var appliedAt time.Time
+		if err := rows.Scan(&status.Version, &status.Name, &status.Checksum, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan applied migration: %w", err)
+		}
+		status.AppliedAt = &appliedAt
+		applied[status.Version] = status
+	}
+	return applied, rows.Err()
+}
+
+// ensureMigrationTable creates the table recording applied migrations
+func ensureMigrationTable(ctx context.Context, exec func(context.Context, string, ...any) (pgconn.CommandTag, error)) error {
+	_, err := exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			checksum TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	return nil
+}
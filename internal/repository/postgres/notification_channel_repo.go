@@ -0,0 +1,167 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// notificationChannelColumns lists the columns read by scanNotificationChannel, in scan order
+const notificationChannelColumns = `id, app_id, name, kind, url, secret, events, enabled, created_by, created_at`
+
+// NotificationChannelRepository handles notification channel persistence in PostgreSQL
+type NotificationChannelRepository struct {
+	pool   *pgxpool.Pool
+	logger *zap.Logger
+}
+
+// NewNotificationChannelRepository creates a new notification channel repository
+func NewNotificationChannelRepository(pool *pgxpool.Pool, logger *zap.Logger) *NotificationChannelRepository {
+	return &NotificationChannelRepository{
+		pool:   pool,
+		logger: logger,
+	}
+}
+
+// Create stores a new notification channel
+func (r *NotificationChannelRepository) Create(ctx context.Context, c *domain.NotificationChannel) error {
+	query := `
+		INSERT INTO notification_channels (` + notificationChannelColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`
+
+	_, err := dbFor(ctx, r.pool).Exec(ctx, query,
+		c.ID,
+		c.AppID,
+		c.Name,
+		string(c.Kind),
+		c.URL,
+		c.Secret,
+		eventStrings(c.Events),
+		c.Enabled,
+		c.CreatedBy,
+		c.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create notification channel: %w", err)
+	}
+
+	r.logger.Debug("Notification channel created", zap.String("channel_id", c.ID.String()))
+	return nil
+}
+
+// Update saves a channel's name, events and enabled flag
+func (r *NotificationChannelRepository) Update(ctx context.Context, c *domain.NotificationChannel) error {
+	query := `UPDATE notification_channels SET name = $2, events = $3, enabled = $4 WHERE id = $1`
+
+	result, err := dbFor(ctx, r.pool).Exec(ctx, query, c.ID, c.Name, eventStrings(c.Events), c.Enabled)
+	if err != nil {
+		return fmt.Errorf("failed to update notification channel: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("notification channel %w", domain.ErrNotFound)
+	}
+	return nil
+}
+
+// Delete removes a notification channel
+func (r *NotificationChannelRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	result, err := dbFor(ctx, r.pool).Exec(ctx, `DELETE FROM notification_channels WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete notification channel: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("notification channel %w", domain.ErrNotFound)
+	}
+	return nil
+}
+
+// Get returns a notification channel by ID
+func (r *NotificationChannelRepository) Get(ctx context.Context, id uuid.UUID) (*domain.NotificationChannel, error) {
+	query := `SELECT ` + notificationChannelColumns + ` FROM notification_channels WHERE id = $1`
+
+	c, err := scanNotificationChannel(dbFor(ctx, r.pool).QueryRow(ctx, query, id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("notification channel %w", domain.ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get notification channel: %w", err)
+	}
+	return c, nil
+}
+
+// List returns an app's own channels, or the global channels when appID is nil, oldest first
+func (r *NotificationChannelRepository) List(ctx context.Context, appID *uuid.UUID) ([]*domain.NotificationChannel, error) {
+	query := `SELECT ` + notificationChannelColumns + ` FROM notification_channels
+		WHERE app_id IS NOT DISTINCT FROM $1 ORDER BY created_at`
+	return r.list(ctx, query, appID)
+}
+
+// ListForApp returns the channels that receive an app's events: its own and the global ones
+func (r *NotificationChannelRepository) ListForApp(ctx context.Context, appID uuid.UUID) ([]*domain.NotificationChannel, error) {
+	query := `SELECT ` + notificationChannelColumns + ` FROM notification_channels
+		WHERE app_id IS NULL OR app_id = $1 ORDER BY created_at`
+	return r.list(ctx, query, appID)
+}
+
+func (r *NotificationChannelRepository) list(ctx context.Context, query string, args ...interface{}) ([]*domain.NotificationChannel, error) {
+	rows, err := dbFor(ctx, r.pool).Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notification channels: %w", err)
+	}
+	defer rows.Close()
+
+	channels := make([]*domain.NotificationChannel, 0)
+	for rows.Next() {
+		c, err := scanNotificationChannel(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan notification channel: %w", err)
+		}
+		channels = append(channels, c)
+	}
+
+	return channels, rows.Err()
+}
+
+// scanNotificationChannel scans a row selected with notificationChannelColumns into a NotificationChannel
+func scanNotificationChannel(row pgx.Row) (*domain.NotificationChannel, error) {
+	c := &domain.NotificationChannel{}
+	var kind string
+	var events []string
+
+	err := row.Scan(
+		&c.ID,
+		&c.AppID,
+		&c.Name,
+		&kind,
+		&c.URL,
+		&c.Secret,
+		&events,
+		&c.Enabled,
+		&c.CreatedBy,
+		&c.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	c.Kind = domain.ChannelKind(kind)
+	for _, e := range events {
+		c.Events = append(c.Events, domain.NotificationEvent(e))
+	}
+	return c, nil
+}
+
+func eventStrings(events []domain.NotificationEvent) []string {
+	strs := make([]string, len(events))
+	for i, e := range events {
+		strs[i] = string(e)
+	}
+	return strs
+}
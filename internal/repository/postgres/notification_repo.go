@@ -0,0 +1,121 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// NotificationRepository handles notification persistence in PostgreSQL
+type NotificationRepository struct {
+	pool   *pgxpool.Pool
+	logger *zap.Logger
+}
+
+// NewNotificationRepository creates a new notification repository
+func NewNotificationRepository(pool *pgxpool.Pool, logger *zap.Logger) *NotificationRepository {
+	return &NotificationRepository{
+		pool:   pool,
+		logger: logger,
+	}
+}
+
+// Create stores a new notification
+func (r *NotificationRepository) Create(ctx context.Context, n *domain.Notification) error {
+	query := `
+		INSERT INTO notifications (id, user_id, kind, title, body, link, data, read_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+
+	_, err := dbFor(ctx, r.pool).Exec(ctx, query,
+		n.ID,
+		n.UserID,
+		string(n.Kind),
+		n.Title,
+		n.Body,
+		n.Link,
+		n.Data,
+		n.ReadAt,
+		n.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create notification: %w", err)
+	}
+	return nil
+}
+
+// ListForUser returns a user's notifications, newest first
+func (r *NotificationRepository) ListForUser(ctx context.Context, userID uuid.UUID, unreadOnly bool, limit int) ([]*domain.Notification, error) {
+	query := `
+		SELECT id, user_id, kind, title, body, link, data, read_at, created_at
+		FROM notifications
+		WHERE user_id = $1 AND (NOT $2 OR read_at IS NULL)
+		ORDER BY created_at DESC
+		LIMIT $3
+	`
+
+	rows, err := dbFor(ctx, r.pool).Query(ctx, query, userID, unreadOnly, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notifications: %w", err)
+	}
+	defer rows.Close()
+
+	notifications := make([]*domain.Notification, 0)
+	for rows.Next() {
+		n := &domain.Notification{}
+		var kind string
+		err := rows.Scan(
+			&n.ID,
+			&n.UserID,
+			&kind,
+			&n.Title,
+			&n.Body,
+			&n.Link,
+			&n.Data,
+			&n.ReadAt,
+			&n.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan notification: %w", err)
+		}
+		n.Kind = domain.NotificationKind(kind)
+		notifications = append(notifications, n)
+	}
+
+	return notifications, rows.Err()
+}
+
+// CountUnread returns the number of unread notifications for a user
+func (r *NotificationRepository) CountUnread(ctx context.Context, userID uuid.UUID) (int64, error) {
+	var count int64
+	query := `SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL`
+	if err := dbFor(ctx, r.pool).QueryRow(ctx, query, userID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count notifications: %w", err)
+	}
+	return count, nil
+}
+
+// MarkRead marks one of a user's notifications read, reporting whether it exists
+func (r *NotificationRepository) MarkRead(ctx context.Context, userID, id uuid.UUID) (bool, error) {
+	query := `UPDATE notifications SET read_at = COALESCE(read_at, NOW()) WHERE id = $1 AND user_id = $2`
+	result, err := dbFor(ctx, r.pool).Exec(ctx, query, id, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to mark notification read: %w", err)
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// MarkAllRead marks all of a user's notifications read and returns how many changed
+func (r *NotificationRepository) MarkAllRead(ctx context.Context, userID uuid.UUID) (int64, error) {
+	query := `UPDATE notifications SET read_at = NOW() WHERE user_id = $1 AND read_at IS NULL`
+	result, err := dbFor(ctx, r.pool).Exec(ctx, query, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark notifications read: %w", err)
+	}
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,154 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// projectColumns lists the columns read by scanProject, in scan order
+const projectColumns = `id, name, slug, description, env_vars, owner_id, team_id, created_at, updated_at`
+
+// ProjectRepository handles project persistence in PostgreSQL
+type ProjectRepository struct {
+	pool   *pgxpool.Pool
+	logger *zap.Logger
+}
+
+// NewProjectRepository creates a new project repository
+func NewProjectRepository(pool *pgxpool.Pool, logger *zap.Logger) *ProjectRepository {
+	return &ProjectRepository{
+		pool:   pool,
+		logger: logger,
+	}
+}
+
+// Create stores a new project
+func (r *ProjectRepository) Create(ctx context.Context, p *domain.Project) error {
+	query := `
+		INSERT INTO projects (` + projectColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+
+	_, err := dbFor(ctx, r.pool).Exec(ctx, query,
+		p.ID,
+		p.Name,
+		p.Slug,
+		p.Description,
+		p.EnvVars,
+		p.OwnerID,
+		p.TeamID,
+		p.CreatedAt,
+		p.UpdatedAt,
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("project %w", domain.ErrConflict)
+		}
+		return fmt.Errorf("failed to create project: %w", err)
+	}
+
+	r.logger.Debug("Project created", zap.String("project_id", p.ID.String()))
+	return nil
+}
+
+// GetByID retrieves a project by ID
+func (r *ProjectRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Project, error) {
+	query := `SELECT ` + projectColumns + ` FROM projects WHERE id = $1`
+
+	p, err := scanProject(dbFor(ctx, r.pool).QueryRow(ctx, query, id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("project %w", domain.ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get project: %w", err)
+	}
+	return p, nil
+}
+
+// Update saves a project's name, description, shared env vars and team
+func (r *ProjectRepository) Update(ctx context.Context, p *domain.Project) error {
+	query := `
+		UPDATE projects SET
+			name = $2,
+			description = $3,
+			env_vars = $4,
+			team_id = $5,
+			updated_at = $6
+		WHERE id = $1
+	`
+
+	result, err := dbFor(ctx, r.pool).Exec(ctx, query, p.ID, p.Name, p.Description, p.EnvVars, p.TeamID, p.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to update project: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("project %w", domain.ErrNotFound)
+	}
+	return nil
+}
+
+// Delete removes a project; its apps leave it
+func (r *ProjectRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	result, err := dbFor(ctx, r.pool).Exec(ctx, `DELETE FROM projects WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete project: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("project %w", domain.ErrNotFound)
+	}
+	return nil
+}
+
+// ListAll returns every project, oldest first
+func (r *ProjectRepository) ListAll(ctx context.Context) ([]*domain.Project, error) {
+	query := `SELECT ` + projectColumns + ` FROM projects ORDER BY created_at`
+
+	rows, err := dbFor(ctx, r.pool).Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list projects: %w", err)
+	}
+	defer rows.Close()
+
+	projects := make([]*domain.Project, 0)
+	for rows.Next() {
+		p, err := scanProject(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan project: %w", err)
+		}
+		projects = append(projects, p)
+	}
+
+	return projects, rows.Err()
+}
+
+// scanProject scans a row selected with projectColumns into a Project
+func scanProject(row pgx.Row) (*domain.Project, error) {
+	p := &domain.Project{}
+
+	err := row.Scan(
+		&p.ID,
+		&p.Name,
+		&p.Slug,
+		&p.Description,
+		&p.EnvVars,
+		&p.OwnerID,
+		&p.TeamID,
+		&p.CreatedAt,
+		&p.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.EnvVars == nil {
+		p.EnvVars = make(map[string]string)
+	}
+	return p, nil
+}
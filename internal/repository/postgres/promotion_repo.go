@@ -0,0 +1,101 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// PromotionRepository handles image promotion persistence in PostgreSQL
+type PromotionRepository struct {
+	pool   *pgxpool.Pool
+	logger *zap.Logger
+}
+
+// NewPromotionRepository creates a new promotion repository
+func NewPromotionRepository(pool *pgxpool.Pool, logger *zap.Logger) *PromotionRepository {
+	return &PromotionRepository{
+		pool:   pool,
+		logger: logger,
+	}
+}
+
+// Create records a new image promotion
+func (r *PromotionRepository) Create(ctx context.Context, p *domain.ImagePromotion) error {
+	query := `
+		INSERT INTO image_promotions (
+			id, app_slug, build_id, image_id, source_ref, target_tag,
+			environment, pushed, digest, promoted_by, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`
+
+	_, err := dbFor(ctx, r.pool).Exec(ctx, query,
+		p.ID,
+		p.AppSlug,
+		p.BuildID,
+		p.ImageID,
+		p.SourceRef,
+		p.TargetTag,
+		p.Environment,
+		p.Pushed,
+		p.Digest,
+		p.PromotedBy,
+		p.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create promotion: %w", err)
+	}
+
+	r.logger.Debug("Promotion recorded",
+		zap.String("promotion_id", p.ID.String()),
+		zap.String("target_tag", p.TargetTag),
+	)
+	return nil
+}
+
+// List returns promotions, optionally filtered by app slugs and environment, newest first
+func (r *PromotionRepository) List(ctx context.Context, appSlugs []string, environment string, limit int) ([]*domain.ImagePromotion, error) {
+	query := `
+		SELECT id, app_slug, COALESCE(build_id, ''), image_id, source_ref, target_tag,
+			environment, pushed, COALESCE(digest, ''), promoted_by, created_at
+		FROM image_promotions
+		WHERE ($1::text[] IS NULL OR app_slug = ANY($1))
+			AND ($2 = '' OR environment = $2)
+		ORDER BY created_at DESC
+		LIMIT $3
+	`
+
+	rows, err := dbFor(ctx, r.pool).Query(ctx, query, appSlugs, environment, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list promotions: %w", err)
+	}
+	defer rows.Close()
+
+	promotions := make([]*domain.ImagePromotion, 0)
+	for rows.Next() {
+		p := &domain.ImagePromotion{}
+		err := rows.Scan(
+			&p.ID,
+			&p.AppSlug,
+			&p.BuildID,
+			&p.ImageID,
+			&p.SourceRef,
+			&p.TargetTag,
+			&p.Environment,
+			&p.Pushed,
+			&p.Digest,
+			&p.PromotedBy,
+			&p.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan promotion: %w", err)
+		}
+		promotions = append(promotions, p)
+	}
+
+	return promotions, rows.Err()
+}
@@ -0,0 +1,112 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Attempts of a query that failed before reaching the database, and the wait before the
+// first retry, doubled for each later one
+const (
+	retryAttempts = 3
+	retryBackoff  = 100 * time.Millisecond
+)
+
+// retryingPool runs queries on the pool, retrying those that failed before anything was
+// sent to the database: no connection could be made, or the connection broke before the
+// query went out. A query that may have run is never retried, so writes don't apply
+// twice; nor is one inside a transaction, whose connection is gone once it breaks.
+type retryingPool struct {
+	pool *pgxpool.Pool
+}
+
+func (p retryingPool) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	var tag pgconn.CommandTag
+	err := retry(ctx, func() (err error) {
+		tag, err = p.pool.Exec(ctx, sql, args...)
+		return err
+	})
+	return tag, err
+}
+
+func (p retryingPool) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	var rows pgx.Rows
+	err := retry(ctx, func() (err error) {
+		rows, err = p.pool.Query(ctx, sql, args...)
+		return err
+	})
+	return rows, err
+}
+
+func (p retryingPool) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	rows, err := p.Query(ctx, sql, args...)
+	return &retriedRow{rows: rows, err: err}
+}
+
+// CopyFrom is not retried: the rows it read before failing can't be read again
+func (p retryingPool) CopyFrom(ctx context.Context, table pgx.Identifier, columns []string, rows pgx.CopyFromSource) (int64, error) {
+	return p.pool.CopyFrom(ctx, table, columns, rows)
+}
+
+func (p retryingPool) Begin(ctx context.Context) (pgx.Tx, error) {
+	var tx pgx.Tx
+	err := retry(ctx, func() (err error) {
+		tx, err = p.pool.Begin(ctx)
+		return err
+	})
+	return tx, err
+}
+
+// retry runs fn until it succeeds, fails in a way retrying can't fix, or has been tried
+// retryAttempts times
+func retry(ctx context.Context, fn func() error) error {
+	backoff := retryBackoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt == retryAttempts || !retryable(err) || ctx.Err() != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// retryable reports whether an error happened before a query was sent
+func retryable(err error) bool {
+	var connectErr *pgconn.ConnectError
+	return errors.As(err, &connectErr) || pgconn.SafeToRetry(err)
+}
+
+// retriedRow is the row of a retried QueryRow, scanned as pgx scans its own
+type retriedRow struct {
+	rows pgx.Rows
+	err  error
+}
+
+func (r *retriedRow) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	defer r.rows.Close()
+
+	if !r.rows.Next() {
+		if err := r.rows.Err(); err != nil {
+			return err
+		}
+		return pgx.ErrNoRows
+	}
+	if err := r.rows.Scan(dest...); err != nil {
+		return err
+	}
+	r.rows.Close()
+	return r.rows.Err()
+}
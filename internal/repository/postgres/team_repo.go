@@ -0,0 +1,50 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// TeamRepository handles team membership lookups in PostgreSQL
+type TeamRepository struct {
+	pool   *pgxpool.Pool
+	logger *zap.Logger
+}
+
+// NewTeamRepository creates a new team repository
+func NewTeamRepository(pool *pgxpool.Pool, logger *zap.Logger) *TeamRepository {
+	return &TeamRepository{
+		pool:   pool,
+		logger: logger,
+	}
+}
+
+// TeamIDsForUser returns the teams a user owns or is a member of
+func (r *TeamRepository) TeamIDsForUser(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	query := `
+		SELECT team_id FROM team_members WHERE user_id = $1
+		UNION
+		SELECT id FROM teams WHERE owner_id = $1
+	`
+
+	rows, err := dbFor(ctx, r.pool).Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user teams: %w", err)
+	}
+	defer rows.Close()
+
+	var teamIDs []uuid.UUID
+	for rows.Next() {
+		var teamID uuid.UUID
+		if err := rows.Scan(&teamID); err != nil {
+			return nil, fmt.Errorf("failed to scan team ID: %w", err)
+		}
+		teamIDs = append(teamIDs, teamID)
+	}
+
+	return teamIDs, rows.Err()
+}
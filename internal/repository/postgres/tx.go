@@ -0,0 +1,62 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// querier runs queries: the pool, or the transaction a context carries
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	CopyFrom(ctx context.Context, table pgx.Identifier, columns []string, rows pgx.CopyFromSource) (int64, error)
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// txKey is the context key of the transaction started by Transactor.InTx
+type txKey struct{}
+
+// dbFor returns what a repository runs a query on: the transaction of the context, so
+// the query commits or rolls back with it, or else the pool, retrying queries that
+// couldn't reach the database
+func dbFor(ctx context.Context, pool *pgxpool.Pool) querier {
+	if tx, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return tx
+	}
+	return retryingPool{pool: pool}
+}
+
+// Transactor runs units of work in PostgreSQL transactions
+type Transactor struct {
+	pool *pgxpool.Pool
+}
+
+// NewTransactor creates a new transactor
+func NewTransactor(pool *pgxpool.Pool) *Transactor {
+	return &Transactor{pool: pool}
+}
+
+// InTx runs fn in a transaction. Repository calls made with the context fn is given join
+// it, so their writes commit together when fn returns nil and roll back when it returns
+// an error or panics. Inside another InTx, fn runs in a savepoint of the outer transaction.
+// A transaction is one connection, so fn must not query from several goroutines at once.
+func (t *Transactor) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	tx, err := dbFor(ctx, t.pool).Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(context.Background()) // no-op after commit
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
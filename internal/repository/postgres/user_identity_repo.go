@@ -0,0 +1,67 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// UserIdentityRepository handles links between users and OpenID Connect accounts
+type UserIdentityRepository struct {
+	pool   *pgxpool.Pool
+	logger *zap.Logger
+}
+
+// NewUserIdentityRepository creates a new user identity repository
+func NewUserIdentityRepository(pool *pgxpool.Pool, logger *zap.Logger) *UserIdentityRepository {
+	return &UserIdentityRepository{
+		pool:   pool,
+		logger: logger,
+	}
+}
+
+// Create links a user to a provider account
+func (r *UserIdentityRepository) Create(ctx context.Context, identity *domain.UserIdentity) error {
+	query := `
+		INSERT INTO user_identities (id, user_id, issuer, subject, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+
+	_, err := dbFor(ctx, r.pool).Exec(ctx, query,
+		identity.ID,
+		identity.UserID,
+		identity.Issuer,
+		identity.Subject,
+		identity.CreatedAt,
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("identity %w", domain.ErrConflict)
+		}
+		return fmt.Errorf("failed to create user identity: %w", err)
+	}
+
+	r.logger.Debug("User identity linked", zap.String("user_id", identity.UserID.String()), zap.String("issuer", identity.Issuer))
+	return nil
+}
+
+// GetUserID returns the user linked to a provider account
+func (r *UserIdentityRepository) GetUserID(ctx context.Context, issuer, subject string) (uuid.UUID, error) {
+	query := `SELECT user_id FROM user_identities WHERE issuer = $1 AND subject = $2`
+
+	var userID uuid.UUID
+	if err := dbFor(ctx, r.pool).QueryRow(ctx, query, issuer, subject).Scan(&userID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return uuid.Nil, fmt.Errorf("identity %w", domain.ErrNotFound)
+		}
+		return uuid.Nil, fmt.Errorf("failed to get user identity: %w", err)
+	}
+	return userID, nil
+}
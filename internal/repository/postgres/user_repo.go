@@ -2,7 +2,9 @@ package postgres
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -27,18 +29,61 @@ func NewUserRepository(pool *pgxpool.Pool, logger *zap.Logger) *UserRepository {
 	}
 }
 
+// userColumns are the columns scanUser reads. GitHub fields are NULL for accounts that
+// only sign in with a password, and the password hash for GitHub-only accounts.
+const userColumns = `id, email, name, COALESCE(avatar_url, ''), COALESCE(github_id, 0),
+	COALESCE(github_login, ''), COALESCE(github_token, ''), COALESCE(password_hash, ''),
+	role, email_verified, last_login_at, deactivated_at, created_at, updated_at,
+	totp_enabled, COALESCE(totp_secret, ''), totp_last_step, totp_recovery_codes`
+
+// scanUser scans a row of userColumns
+func scanUser(row pgx.Row) (*domain.User, error) {
+	user := &domain.User{}
+	var role string
+
+	err := row.Scan(
+		&user.ID,
+		&user.Email,
+		&user.Name,
+		&user.AvatarURL,
+		&user.GitHubID,
+		&user.GitHubLogin,
+		&user.GitHubToken,
+		&user.PasswordHash,
+		&role,
+		&user.EmailVerified,
+		&user.LastLoginAt,
+		&user.DeactivatedAt,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+		&user.TwoFactorEnabled,
+		&user.TOTPSecret,
+		&user.TOTPLastStep,
+		&user.RecoveryCodes,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	user.Role = domain.UserRole(role)
+	return user, nil
+}
+
 // Create creates a new user in the database
 func (r *UserRepository) Create(ctx context.Context, user *domain.User) error {
 	query := `
 		INSERT INTO users (
-			id, email, name, avatar_url, github_id, github_login, github_token,
-			role, email_verified, last_login_at, created_at, updated_at
+			id, email, name, avatar_url, github_id, github_login, github_token, password_hash,
+			role, email_verified, last_login_at, deactivated_at, created_at, updated_at,
+			totp_enabled, totp_secret, totp_last_step, totp_recovery_codes
 		) VALUES (
-			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12
+			$1, $2, $3, $4, NULLIF($5, 0), NULLIF($6, ''), NULLIF($7, ''), NULLIF($8, ''),
+			$9, $10, $11, $12, $13, $14,
+			$15, NULLIF($16, ''), $17, $18
 		)
 	`
 
-	_, err := r.pool.Exec(ctx, query,
+	_, err := dbFor(ctx, r.pool).Exec(ctx, query,
 		user.ID,
 		user.Email,
 		user.Name,
@@ -46,14 +91,23 @@ func (r *UserRepository) Create(ctx context.Context, user *domain.User) error {
 		user.GitHubID,
 		user.GitHubLogin,
 		user.GitHubToken,
+		user.PasswordHash,
 		string(user.Role),
 		user.EmailVerified,
 		user.LastLoginAt,
+		user.DeactivatedAt,
 		user.CreatedAt,
 		user.UpdatedAt,
+		user.TwoFactorEnabled,
+		user.TOTPSecret,
+		user.TOTPLastStep,
+		recoveryCodes(user),
 	)
 
 	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("user %w", domain.ErrConflict)
+		}
 		return fmt.Errorf("failed to create user: %w", err)
 	}
 
@@ -63,119 +117,35 @@ func (r *UserRepository) Create(ctx context.Context, user *domain.User) error {
 
 // GetByID retrieves a user by ID
 func (r *UserRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.User, error) {
-	query := `
-		SELECT id, email, name, avatar_url, github_id, github_login, github_token,
-			role, email_verified, last_login_at, created_at, updated_at
-		FROM users
-		WHERE id = $1
-	`
-
-	user := &domain.User{}
-	var role string
-
-	err := r.pool.QueryRow(ctx, query, id).Scan(
-		&user.ID,
-		&user.Email,
-		&user.Name,
-		&user.AvatarURL,
-		&user.GitHubID,
-		&user.GitHubLogin,
-		&user.GitHubToken,
-		&role,
-		&user.EmailVerified,
-		&user.LastLoginAt,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
-
-	if err != nil {
-		if err == pgx.ErrNoRows {
-			return nil, fmt.Errorf("user not found")
-		}
-		return nil, fmt.Errorf("failed to get user: %w", err)
-	}
-
-	user.Role = domain.UserRole(role)
-	return user, nil
+	return r.getBy(ctx, "id = $1", id)
 }
 
-// GetByEmail retrieves a user by email
+// GetByEmail retrieves a user by email, ignoring case
 func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
-	query := `
-		SELECT id, email, name, avatar_url, github_id, github_login, github_token,
-			role, email_verified, last_login_at, created_at, updated_at
-		FROM users
-		WHERE email = $1
-	`
-
-	user := &domain.User{}
-	var role string
-
-	err := r.pool.QueryRow(ctx, query, email).Scan(
-		&user.ID,
-		&user.Email,
-		&user.Name,
-		&user.AvatarURL,
-		&user.GitHubID,
-		&user.GitHubLogin,
-		&user.GitHubToken,
-		&role,
-		&user.EmailVerified,
-		&user.LastLoginAt,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
-
-	if err != nil {
-		if err == pgx.ErrNoRows {
-			return nil, fmt.Errorf("user not found")
-		}
-		return nil, fmt.Errorf("failed to get user: %w", err)
-	}
-
-	user.Role = domain.UserRole(role)
-	return user, nil
+	return r.getBy(ctx, "LOWER(email) = LOWER($1)", email)
 }
 
 // GetByGitHubID retrieves a user by GitHub ID
 func (r *UserRepository) GetByGitHubID(ctx context.Context, githubID int64) (*domain.User, error) {
-	query := `
-		SELECT id, email, name, avatar_url, github_id, github_login, github_token,
-			role, email_verified, last_login_at, created_at, updated_at
-		FROM users
-		WHERE github_id = $1
-	`
+	return r.getBy(ctx, "github_id = $1", githubID)
+}
 
-	user := &domain.User{}
-	var role string
-
-	err := r.pool.QueryRow(ctx, query, githubID).Scan(
-		&user.ID,
-		&user.Email,
-		&user.Name,
-		&user.AvatarURL,
-		&user.GitHubID,
-		&user.GitHubLogin,
-		&user.GitHubToken,
-		&role,
-		&user.EmailVerified,
-		&user.LastLoginAt,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+// getBy retrieves the user matching a condition
+func (r *UserRepository) getBy(ctx context.Context, where string, arg interface{}) (*domain.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE ` + where + ` ORDER BY created_at LIMIT 1`
 
+	user, err := scanUser(dbFor(ctx, r.pool).QueryRow(ctx, query, arg))
 	if err != nil {
-		if err == pgx.ErrNoRows {
-			return nil, fmt.Errorf("user not found")
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("user %w", domain.ErrNotFound)
 		}
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
-
-	user.Role = domain.UserRole(role)
 	return user, nil
 }
 
-// Update updates a user
+// Update updates a user. totp_last_step is only moved by AdvanceTOTPStep, so a stale
+// copy of the user can't roll it back.
 func (r *UserRepository) Update(ctx context.Context, user *domain.User) error {
 	user.UpdatedAt = time.Now().UTC()
 
@@ -184,17 +154,22 @@ func (r *UserRepository) Update(ctx context.Context, user *domain.User) error {
 			email = $2,
 			name = $3,
 			avatar_url = $4,
-			github_id = $5,
-			github_login = $6,
-			github_token = $7,
-			role = $8,
-			email_verified = $9,
-			last_login_at = $10,
-			updated_at = $11
+			github_id = NULLIF($5, 0),
+			github_login = NULLIF($6, ''),
+			github_token = NULLIF($7, ''),
+			password_hash = NULLIF($8, ''),
+			role = $9,
+			email_verified = $10,
+			last_login_at = $11,
+			deactivated_at = $12,
+			updated_at = $13,
+			totp_enabled = $14,
+			totp_secret = NULLIF($15, ''),
+			totp_recovery_codes = $16
 		WHERE id = $1
 	`
 
-	result, err := r.pool.Exec(ctx, query,
+	result, err := dbFor(ctx, r.pool).Exec(ctx, query,
 		user.ID,
 		user.Email,
 		user.Name,
@@ -202,18 +177,26 @@ func (r *UserRepository) Update(ctx context.Context, user *domain.User) error {
 		user.GitHubID,
 		user.GitHubLogin,
 		user.GitHubToken,
+		user.PasswordHash,
 		string(user.Role),
 		user.EmailVerified,
 		user.LastLoginAt,
+		user.DeactivatedAt,
 		user.UpdatedAt,
+		user.TwoFactorEnabled,
+		user.TOTPSecret,
+		recoveryCodes(user),
 	)
 
 	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("user %w", domain.ErrConflict)
+		}
 		return fmt.Errorf("failed to update user: %w", err)
 	}
 
 	if result.RowsAffected() == 0 {
-		return fmt.Errorf("user not found")
+		return fmt.Errorf("user %w", domain.ErrNotFound)
 	}
 
 	r.logger.Debug("User updated", zap.String("user_id", user.ID.String()))
@@ -224,30 +207,182 @@ func (r *UserRepository) Update(ctx context.Context, user *domain.User) error {
 func (r *UserRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	query := `DELETE FROM users WHERE id = $1`
 
-	result, err := r.pool.Exec(ctx, query, id)
+	result, err := dbFor(ctx, r.pool).Exec(ctx, query, id)
 	if err != nil {
 		return fmt.Errorf("failed to delete user: %w", err)
 	}
 
 	if result.RowsAffected() == 0 {
-		return fmt.Errorf("user not found")
+		return fmt.Errorf("user %w", domain.ErrNotFound)
 	}
 
 	r.logger.Debug("User deleted", zap.String("user_id", id.String()))
 	return nil
 }
 
-// List retrieves all users with pagination
-func (r *UserRepository) List(ctx context.Context, limit, offset int) ([]*domain.User, error) {
+// recoveryCodes returns the user's recovery code hashes, empty rather than nil for the
+// NOT NULL column
+func recoveryCodes(user *domain.User) []string {
+	if user.RecoveryCodes == nil {
+		return []string{}
+	}
+	return user.RecoveryCodes
+}
+
+// AdvanceTOTPStep records the time step of an accepted code. It returns false when a
+// code from that step or a later one was already accepted, i.e. the code is replayed.
+func (r *UserRepository) AdvanceTOTPStep(ctx context.Context, id uuid.UUID, step int64) (bool, error) {
+	query := `UPDATE users SET totp_last_step = $2 WHERE id = $1 AND totp_last_step < $2`
+
+	result, err := dbFor(ctx, r.pool).Exec(ctx, query, id, step)
+	if err != nil {
+		return false, fmt.Errorf("failed to record TOTP step: %w", err)
+	}
+	return result.RowsAffected() == 1, nil
+}
+
+// ConsumeRecoveryCode removes a recovery code hash from the user's unused codes. It
+// returns false when the user has no such code.
+func (r *UserRepository) ConsumeRecoveryCode(ctx context.Context, id uuid.UUID, hash string) (bool, error) {
 	query := `
-		SELECT id, email, name, avatar_url, github_id, github_login, github_token,
-			role, email_verified, last_login_at, created_at, updated_at
-		FROM users
-		ORDER BY created_at DESC
-		LIMIT $1 OFFSET $2
+		UPDATE users SET totp_recovery_codes = array_remove(totp_recovery_codes, $2), updated_at = NOW()
+		WHERE id = $1 AND $2 = ANY(totp_recovery_codes)
 	`
 
-	rows, err := r.pool.Query(ctx, query, limit, offset)
+	result, err := dbFor(ctx, r.pool).Exec(ctx, query, id, hash)
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	return result.RowsAffected() == 1, nil
+}
+
+// DeleteAndReassign deletes a user, handing the apps, projects and teams they own to
+// another user first so deleting the account doesn't delete them
+func (r *UserRepository) DeleteAndReassign(ctx context.Context, id, newOwnerID uuid.UUID) error {
+	tx, err := dbFor(ctx, r.pool).Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	for _, table := range []string{"apps", "projects", "teams"} {
+		query := `UPDATE ` + table + ` SET owner_id = $2, updated_at = NOW() WHERE owner_id = $1`
+		if _, err := tx.Exec(ctx, query, id, newOwnerID); err != nil {
+			return fmt.Errorf("failed to reassign %s: %w", table, err)
+		}
+	}
+	// The new owner joins the teams they now own, unless they are already a member
+	_, err = tx.Exec(ctx, `
+		INSERT INTO team_members (team_id, user_id, role)
+		SELECT id, $1, 'owner' FROM teams WHERE owner_id = $1
+		ON CONFLICT (team_id, user_id) DO UPDATE SET role = 'owner'
+	`, newOwnerID)
+	if err != nil {
+		return fmt.Errorf("failed to reassign team membership: %w", err)
+	}
+
+	result, err := tx.Exec(ctx, `DELETE FROM users WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("user %w", domain.ErrNotFound)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+
+	r.logger.Debug("User deleted", zap.String("user_id", id.String()), zap.String("new_owner_id", newOwnerID.String()))
+	return nil
+}
+
+// Search returns a page of users matching the query and the number of matches
+func (r *UserRepository) Search(ctx context.Context, q domain.UserQuery) ([]*domain.User, int, error) {
+	conditions := []string{"TRUE"}
+	var args []interface{}
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if q.Search != "" {
+		add(`(name ILIKE $%[1]d ESCAPE '\' OR email ILIKE $%[1]d ESCAPE '\')`, "%"+escapeLike(q.Search)+"%")
+	}
+	if q.Role != "" {
+		add("role = $%d", string(q.Role))
+	}
+	switch q.Status {
+	case "active":
+		conditions = append(conditions, "deactivated_at IS NULL")
+	case "deactivated":
+		conditions = append(conditions, "deactivated_at IS NOT NULL")
+	}
+	where := strings.Join(conditions, " AND ")
+
+	var total int
+	if err := dbFor(ctx, r.pool).QueryRow(ctx, `SELECT COUNT(*) FROM users WHERE `+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	column, ok := userSortColumns[q.Sort]
+	if !ok {
+		column = "created_at"
+	}
+	direction := "ASC NULLS FIRST"
+	if q.Desc {
+		direction = "DESC NULLS LAST"
+	}
+	query := fmt.Sprintf(`SELECT %s FROM users WHERE %s ORDER BY %s %s, id`, userColumns, where, column, direction)
+	if q.Limit > 0 {
+		args = append(args, q.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+	args = append(args, q.Offset)
+	query += fmt.Sprintf(" OFFSET $%d", len(args))
+
+	rows, err := dbFor(ctx, r.pool).Query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list users: %w", err)
+	}
+	defer rows.Close()
+
+	users := make([]*domain.User, 0)
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to list users: %w", err)
+	}
+	return users, total, nil
+}
+
+// userSortColumns maps UserQuery sort keys to columns
+var userSortColumns = map[string]string{
+	"created_at":    "created_at",
+	"email":         "LOWER(email)",
+	"name":          "LOWER(name)",
+	"last_login_at": "last_login_at",
+}
+
+// CountActiveAdmins returns the number of admins who can sign in
+func (r *UserRepository) CountActiveAdmins(ctx context.Context) (int64, error) {
+	query := `SELECT COUNT(*) FROM users WHERE role = $1 AND deactivated_at IS NULL`
+
+	var count int64
+	if err := dbFor(ctx, r.pool).QueryRow(ctx, query, string(domain.UserRoleAdmin)).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count admins: %w", err)
+	}
+	return count, nil
+}
+
+// List retrieves all users with pagination
+func (r *UserRepository) List(ctx context.Context, limit, offset int) ([]*domain.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users ORDER BY created_at DESC LIMIT $1 OFFSET $2`
+
+	rows, err := dbFor(ctx, r.pool).Query(ctx, query, limit, offset)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list users: %w", err)
 	}
@@ -255,33 +390,14 @@ func (r *UserRepository) List(ctx context.Context, limit, offset int) ([]*domain
 
 	var users []*domain.User
 	for rows.Next() {
-		user := &domain.User{}
-		var role string
-
-		err := rows.Scan(
-			&user.ID,
-			&user.Email,
-			&user.Name,
-			&user.AvatarURL,
-			&user.GitHubID,
-			&user.GitHubLogin,
-			&user.GitHubToken,
-			&role,
-			&user.EmailVerified,
-			&user.LastLoginAt,
-			&user.CreatedAt,
-			&user.UpdatedAt,
-		)
-
+		user, err := scanUser(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan user: %w", err)
 		}
-
-		user.Role = domain.UserRole(role)
 		users = append(users, user)
 	}
 
-	return users, nil
+	return users, rows.Err()
 }
 
 // Count returns the total number of users
@@ -289,7 +405,7 @@ func (r *UserRepository) Count(ctx context.Context) (int64, error) {
 	query := `SELECT COUNT(*) FROM users`
 
 	var count int64
-	err := r.pool.QueryRow(ctx, query).Scan(&count)
+	err := dbFor(ctx, r.pool).QueryRow(ctx, query).Scan(&count)
 	if err != nil {
 		return 0, fmt.Errorf("failed to count users: %w", err)
 	}
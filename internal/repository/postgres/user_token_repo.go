@@ -0,0 +1,100 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// userTokenColumns lists the columns read by scanUserToken, in scan order
+const userTokenColumns = `id, user_id, purpose, token_hash, expires_at, created_at`
+
+// UserTokenRepository handles one-time email verification and password reset tokens
+type UserTokenRepository struct {
+	pool   *pgxpool.Pool
+	logger *zap.Logger
+}
+
+// NewUserTokenRepository creates a new user token repository
+func NewUserTokenRepository(pool *pgxpool.Pool, logger *zap.Logger) *UserTokenRepository {
+	return &UserTokenRepository{
+		pool:   pool,
+		logger: logger,
+	}
+}
+
+// Create stores a new token
+func (r *UserTokenRepository) Create(ctx context.Context, token *domain.UserToken) error {
+	query := `
+		INSERT INTO user_tokens (` + userTokenColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	_, err := dbFor(ctx, r.pool).Exec(ctx, query,
+		token.ID,
+		token.UserID,
+		string(token.Purpose),
+		token.TokenHash,
+		token.ExpiresAt,
+		token.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create user token: %w", err)
+	}
+	return nil
+}
+
+// Consume deletes and returns an unexpired token, so each token works once
+func (r *UserTokenRepository) Consume(ctx context.Context, purpose domain.UserTokenPurpose, hash string) (*domain.UserToken, error) {
+	query := `
+		DELETE FROM user_tokens
+		WHERE token_hash = $1 AND purpose = $2 AND expires_at > NOW()
+		RETURNING ` + userTokenColumns
+
+	token, err := scanUserToken(dbFor(ctx, r.pool).QueryRow(ctx, query, hash, string(purpose)))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("token %w", domain.ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to consume user token: %w", err)
+	}
+	return token, nil
+}
+
+// DeleteForUser deletes a user's tokens for a purpose, and any expired tokens
+func (r *UserTokenRepository) DeleteForUser(ctx context.Context, userID uuid.UUID, purpose domain.UserTokenPurpose) error {
+	query := `DELETE FROM user_tokens WHERE (user_id = $1 AND purpose = $2) OR expires_at <= NOW()`
+
+	if _, err := dbFor(ctx, r.pool).Exec(ctx, query, userID, string(purpose)); err != nil {
+		return fmt.Errorf("failed to delete user tokens: %w", err)
+	}
+	return nil
+}
+
+// scanUserToken scans a row of userTokenColumns
+func scanUserToken(row pgx.Row) (*domain.UserToken, error) {
+	token := &domain.UserToken{}
+	var purpose string
+
+	err := row.Scan(
+		&token.ID,
+		&token.UserID,
+		&purpose,
+		&token.TokenHash,
+		&token.ExpiresAt,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	token.Purpose = domain.UserTokenPurpose(purpose)
+	return token, nil
+}
@@ -0,0 +1,66 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/nanopaas/nanopaas/internal/infrastructure/breaker"
+)
+
+// ErrUnavailable is returned instead of sending a command while Redis is known to be down
+var ErrUnavailable = errors.New("redis unavailable")
+
+// SetBreaker makes commands fail at once with ErrUnavailable while the breaker is open,
+// rather than each waiting out its dial timeout and retries. Every feature built on Redis
+// already carries on without it. Commands failing on the network ask for an early probe.
+// PING always goes through, so the breaker can probe with Ping.
+func (c *Client) SetBreaker(b *breaker.Breaker) {
+	c.rdb.AddHook(breakerHook{breaker: b})
+}
+
+// breakerHook gates commands on a breaker
+type breakerHook struct {
+	breaker *breaker.Breaker
+}
+
+func (h breakerHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h breakerHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if cmd.Name() != "ping" && !h.breaker.Allow() {
+			cmd.SetErr(ErrUnavailable)
+			return ErrUnavailable
+		}
+		err := next(ctx, cmd)
+		h.observe(err)
+		return err
+	}
+}
+
+func (h breakerHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		if !h.breaker.Allow() {
+			for _, cmd := range cmds {
+				cmd.SetErr(ErrUnavailable)
+			}
+			return ErrUnavailable
+		}
+		err := next(ctx, cmds)
+		h.observe(err)
+		return err
+	}
+}
+
+// observe asks for an early probe when a command failed to reach Redis
+func (h breakerHook) observe(err error) {
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.EOF) {
+		h.breaker.CheckSoon()
+	}
+}
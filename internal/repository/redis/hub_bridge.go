@@ -0,0 +1,163 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	ws "github.com/nanopaas/nanopaas/pkg/websocket"
+)
+
+// hubChannelPrefix prefixes the pub/sub channel of each hub topic
+const hubChannelPrefix = "nanopaas:ws:"
+
+// Messages waiting to be published; broadcasts are dropped when Redis falls this far behind
+const hubBridgeBuffer = 1024
+
+// bridgeMessage is a hub broadcast as sent between instances
+type bridgeMessage struct {
+	Origin   uuid.UUID       `json:"origin"`
+	Type     string          `json:"type"`
+	Envelope json.RawMessage `json:"envelope"`
+}
+
+// HubBridge relays WebSocket hub broadcasts between NanoPaaS instances over Redis pub/sub,
+// publishing each topic on its own channel. Every instance delivers its own broadcasts
+// locally and skips them when they come back from Redis.
+type HubBridge struct {
+	client   *Client
+	hub      *ws.Hub
+	origin   uuid.UUID
+	outbound chan *ws.Message
+	dropped  atomic.Int64
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	logger *zap.Logger
+}
+
+// NewHubBridge creates a bridge for a hub; Start connects it
+func NewHubBridge(client *Client, hub *ws.Hub, logger *zap.Logger) *HubBridge {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &HubBridge{
+		client:   client,
+		hub:      hub,
+		origin:   uuid.New(),
+		outbound: make(chan *ws.Message, hubBridgeBuffer),
+		ctx:      ctx,
+		cancel:   cancel,
+		logger:   logger,
+	}
+}
+
+// Start subscribes to the other instances' broadcasts and relays this hub's
+func (b *HubBridge) Start() error {
+	pubsub := b.client.rdb.PSubscribe(b.ctx, hubChannelPrefix+"*")
+	// Wait for the subscription so no broadcast is missed once Start returns
+	if _, err := pubsub.Receive(b.ctx); err != nil {
+		pubsub.Close()
+		return err
+	}
+	b.hub.SetBridge(b)
+
+	b.wg.Add(2)
+	go b.receive(pubsub.Channel())
+	go b.publish()
+
+	go func() {
+		<-b.ctx.Done()
+		pubsub.Close()
+	}()
+
+	b.logger.Info("WebSocket hub bridged through Redis", zap.String("instance", b.origin.String()))
+	return nil
+}
+
+// Stop stops relaying and waits for the bridge goroutines to exit
+func (b *HubBridge) Stop() {
+	b.cancel()
+	b.wg.Wait()
+}
+
+// Publish queues a broadcast for the other instances without blocking the broadcaster
+func (b *HubBridge) Publish(message *ws.Message) {
+	select {
+	case b.outbound <- message:
+	default:
+		if b.dropped.Add(1)%100 == 1 {
+			b.logger.Warn("Redis hub bridge is behind, dropping broadcasts",
+				zap.Int64("dropped", b.dropped.Load()),
+			)
+		}
+	}
+}
+
+// Dropped returns the number of broadcasts that could not be queued for other instances
+func (b *HubBridge) Dropped() int64 {
+	return b.dropped.Load()
+}
+
+// publish sends queued broadcasts to Redis
+func (b *HubBridge) publish() {
+	defer b.wg.Done()
+
+	for {
+		select {
+		case <-b.ctx.Done():
+			return
+		case message := <-b.outbound:
+			data, err := json.Marshal(bridgeMessage{
+				Origin:   b.origin,
+				Type:     message.Type,
+				Envelope: message.Payload,
+			})
+			if err != nil {
+				continue
+			}
+
+			ctx, cancel := context.WithTimeout(b.ctx, 5*time.Second)
+			err = b.client.rdb.Publish(ctx, hubChannelPrefix+message.Topic, data).Err()
+			cancel()
+			if err != nil && b.ctx.Err() == nil {
+				b.logger.Warn("Failed to publish broadcast to Redis",
+					zap.String("topic", message.Topic),
+					zap.Error(err),
+				)
+			}
+		}
+	}
+}
+
+// receive delivers other instances' broadcasts to this hub's clients
+func (b *HubBridge) receive(messages <-chan *redis.Message) {
+	defer b.wg.Done()
+
+	for {
+		select {
+		case <-b.ctx.Done():
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			var relayed bridgeMessage
+			if err := json.Unmarshal([]byte(msg.Payload), &relayed); err != nil {
+				b.logger.Debug("Ignoring malformed hub broadcast", zap.String("channel", msg.Channel))
+				continue
+			}
+			if relayed.Origin == b.origin {
+				continue // already delivered locally
+			}
+			topic := strings.TrimPrefix(msg.Channel, hubChannelPrefix)
+			b.hub.BroadcastLocal(topic, relayed.Type, relayed.Envelope)
+		}
+	}
+}
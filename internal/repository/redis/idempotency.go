@@ -0,0 +1,72 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// idempotencyKeyPrefix prefixes the Redis key of each idempotency key
+const idempotencyKeyPrefix = "nanopaas:idempotency:"
+
+// idempotencyPending marks a key whose first request is still running. The marker
+// expires sooner than snapshots so a crashed request does not block its key for long.
+const (
+	idempotencyPending    = "pending"
+	idempotencyPendingTTL = 15 * time.Minute
+)
+
+// IdempotencyStore keeps response snapshots of requests sent with an Idempotency-Key
+type IdempotencyStore struct {
+	client *Client
+	ttl    time.Duration
+}
+
+// NewIdempotencyStore creates a store keeping snapshots for ttl
+func NewIdempotencyStore(client *Client, ttl time.Duration) *IdempotencyStore {
+	return &IdempotencyStore{client: client, ttl: ttl}
+}
+
+// Reserve claims a key for a new request. It returns false with the stored snapshot,
+// nil while the first request is still running, when the key was already used.
+func (s *IdempotencyStore) Reserve(ctx context.Context, key string) (bool, []byte, error) {
+	ok, err := s.client.rdb.SetNX(ctx, idempotencyKeyPrefix+key, idempotencyPending, min(s.ttl, idempotencyPendingTTL)).Result()
+	if err != nil {
+		return false, nil, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+	if ok {
+		return true, nil, nil
+	}
+
+	data, err := s.client.rdb.Get(ctx, idempotencyKeyPrefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		// Released or expired in between; the caller may retry
+		return false, nil, nil
+	}
+	if err != nil {
+		return false, nil, fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+	if string(data) == idempotencyPending {
+		return false, nil, nil
+	}
+	return false, data, nil
+}
+
+// Save stores the snapshot of a reserved key's response
+func (s *IdempotencyStore) Save(ctx context.Context, key string, snapshot []byte) error {
+	if err := s.client.rdb.Set(ctx, idempotencyKeyPrefix+key, snapshot, s.ttl).Err(); err != nil {
+		return fmt.Errorf("failed to save idempotency key: %w", err)
+	}
+	return nil
+}
+
+// Release frees a reserved key so the request can be retried
+func (s *IdempotencyStore) Release(ctx context.Context, key string) error {
+	if err := s.client.rdb.Del(ctx, idempotencyKeyPrefix+key).Err(); err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
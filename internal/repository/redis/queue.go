@@ -39,6 +39,11 @@ func NewClient(host string, port int, password string, db int, logger *zap.Logge
 		Addr:     fmt.Sprintf("%s:%d", host, port),
 		Password: password,
 		DB:       db,
+
+		// Retry commands that hit a broken connection twice, backing off 100-200ms
+		MaxRetries:      2,
+		MinRetryBackoff: 100 * time.Millisecond,
+		MaxRetryBackoff: 200 * time.Millisecond,
 	})
 
 	// Test connection
@@ -268,20 +273,25 @@ func (c *Client) ReleaseLock(ctx context.Context, key string) error {
 
 // --- Rate Limiting ---
 
-// CheckRateLimit checks if an action is rate limited
-func (c *Client) CheckRateLimit(ctx context.Context, key string, limit int, window time.Duration) (bool, error) {
-	rateLimitKey := fmt.Sprintf("ratelimit:%s", key)
+// CheckRateLimit counts an action against a budget of limit per fixed window. It reports
+// whether the action is allowed and, when it is not, how long until the window resets.
+func (c *Client) CheckRateLimit(ctx context.Context, key string, limit int, window time.Duration) (bool, time.Duration, error) {
+	now := time.Now()
+	windowStart := now.Truncate(window)
+	rateLimitKey := fmt.Sprintf("ratelimit:%s:%d", key, windowStart.Unix())
 
 	pipe := c.rdb.Pipeline()
 	incr := pipe.Incr(ctx, rateLimitKey)
 	pipe.Expire(ctx, rateLimitKey, window)
 	_, err := pipe.Exec(ctx)
 	if err != nil {
-		return false, fmt.Errorf("rate limit check failed: %w", err)
+		return false, 0, fmt.Errorf("rate limit check failed: %w", err)
 	}
 
-	count := incr.Val()
-	return count <= int64(limit), nil
+	if incr.Val() <= int64(limit) {
+		return true, 0, nil
+	}
+	return false, windowStart.Add(window).Sub(now), nil
 }
 
 // --- Caching ---
@@ -0,0 +1,45 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// repoCacheKeyPrefix prefixes the Redis key of each cached repository listing
+const repoCacheKeyPrefix = "nanopaas:repos:"
+
+// RepoCache keeps users' git repository listings for a short while, sparing the git
+// provider a request each time a repository picker opens
+type RepoCache struct {
+	client *Client
+	ttl    time.Duration
+}
+
+// NewRepoCache creates a cache keeping listings for ttl
+func NewRepoCache(client *Client, ttl time.Duration) *RepoCache {
+	return &RepoCache{client: client, ttl: ttl}
+}
+
+// Get returns a cached listing, or nil when there is none
+func (c *RepoCache) Get(ctx context.Context, key string) ([]byte, error) {
+	data, err := c.client.rdb.Get(ctx, repoCacheKeyPrefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cached repositories: %w", err)
+	}
+	return data, nil
+}
+
+// Set caches a listing
+func (c *RepoCache) Set(ctx context.Context, key string, data []byte) error {
+	if err := c.client.rdb.Set(ctx, repoCacheKeyPrefix+key, data, c.ttl).Err(); err != nil {
+		return fmt.Errorf("failed to cache repositories: %w", err)
+	}
+	return nil
+}
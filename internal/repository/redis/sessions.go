@@ -0,0 +1,135 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// Key prefixes of the session store. Sessions are kept until their refresh token
+// expires; revoked token and session IDs until the tokens carrying them expire.
+const (
+	sessionKeyPrefix      = "nanopaas:session:"
+	userSessionsKeyPrefix = "nanopaas:user-sessions:"
+	revokedKeyPrefix      = "nanopaas:revoked:"
+)
+
+// SessionStore tracks sign-in sessions and the blacklist of revoked tokens
+type SessionStore struct {
+	client *Client
+}
+
+// NewSessionStore creates a session store
+func NewSessionStore(client *Client) *SessionStore {
+	return &SessionStore{client: client}
+}
+
+// SaveSession creates or updates a session, keeping it until it expires
+func (s *SessionStore) SaveSession(ctx context.Context, session *domain.Session) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+	ttl := time.Until(session.ExpiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+
+	userKey := userSessionsKeyPrefix + session.UserID.String()
+	pipe := s.client.rdb.TxPipeline()
+	pipe.Set(ctx, sessionKeyPrefix+session.ID, data, ttl)
+	pipe.SAdd(ctx, userKey, session.ID)
+	pipe.ExpireGT(ctx, userKey, ttl)
+	pipe.ExpireNX(ctx, userKey, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	return nil
+}
+
+// GetSession returns a session, or nil if it expired or was revoked
+func (s *SessionStore) GetSession(ctx context.Context, id string) (*domain.Session, error) {
+	data, err := s.client.rdb.Get(ctx, sessionKeyPrefix+id).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	var session domain.Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("failed to decode session: %w", err)
+	}
+	return &session, nil
+}
+
+// ListSessions returns a user's active sessions, dropping expired ones from the index
+func (s *SessionStore) ListSessions(ctx context.Context, userID uuid.UUID) ([]*domain.Session, error) {
+	userKey := userSessionsKeyPrefix + userID.String()
+	ids, err := s.client.rdb.SMembers(ctx, userKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	sessions := make([]*domain.Session, 0, len(ids))
+	for _, id := range ids {
+		session, err := s.GetSession(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if session == nil {
+			s.client.rdb.SRem(ctx, userKey, id)
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
+
+// DeleteSession removes a session from a user's sessions
+func (s *SessionStore) DeleteSession(ctx context.Context, userID uuid.UUID, id string) error {
+	pipe := s.client.rdb.TxPipeline()
+	pipe.Del(ctx, sessionKeyPrefix+id)
+	pipe.SRem(ctx, userSessionsKeyPrefix+userID.String(), id)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return nil
+}
+
+// Revoke blacklists a token or session ID for ttl, the longest the tokens carrying it
+// stay valid
+func (s *SessionStore) Revoke(ctx context.Context, id string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+	if err := s.client.rdb.Set(ctx, revokedKeyPrefix+id, "1", ttl).Err(); err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+	return nil
+}
+
+// IsRevoked reports whether any of the given token or session IDs is blacklisted
+func (s *SessionStore) IsRevoked(ctx context.Context, ids ...string) (bool, error) {
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if id != "" {
+			keys = append(keys, revokedKeyPrefix+id)
+		}
+	}
+	if len(keys) == 0 {
+		return false, nil
+	}
+	n, err := s.client.rdb.Exists(ctx, keys...).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check revoked tokens: %w", err)
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,608 @@
+package acme
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/crypto/acme"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// Challenge types
+const (
+	ChallengeHTTP01 = "http-01"
+	ChallengeDNS01  = "dns-01"
+)
+
+// LetsEncryptURL is the production Let's Encrypt directory
+const LetsEncryptURL = acme.LetsEncryptURL
+
+// ChallengePathPrefix is where the CA fetches HTTP-01 challenge responses
+const ChallengePathPrefix = "/.well-known/acme-challenge/"
+
+// issueTimeout bounds a single certificate order
+const issueTimeout = 5 * time.Minute
+
+// Config holds ACME settings
+type Config struct {
+	Email        string
+	DirectoryURL string
+	Challenge    string // http-01 or dns-01
+
+	// Renew certificates expiring within RenewBefore, checked every CheckInterval
+	RenewBefore   time.Duration
+	CheckInterval time.Duration
+
+	// Wait after publishing a DNS-01 record before asking the CA to validate it
+	DNSPropagationWait time.Duration
+}
+
+// Store persists the ACME account and issued certificates
+type Store interface {
+	GetAccount(ctx context.Context, email string) (*domain.ACMEAccount, error) // nil if none
+	SaveAccount(ctx context.Context, account *domain.ACMEAccount) error
+	GetCertificate(ctx context.Context, primary string) (*domain.Certificate, error) // nil if none
+	SaveCertificate(ctx context.Context, cert *domain.Certificate) error
+	ListCertificates(ctx context.Context) ([]*domain.Certificate, error)
+	DeleteCertificate(ctx context.Context, primary string) error
+}
+
+// OrderStatus is the outcome of the most recent order for a certificate
+type OrderStatus struct {
+	Domains   []string  `json:"domains"`
+	State     string    `json:"state"` // pending, issued or failed
+	Error     string    `json:"error,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Manager issues and renews certificates and hands them to the router
+type Manager struct {
+	config Config
+	store  Store
+	dns    DNSProvider
+	logger *zap.Logger
+
+	clientMu sync.Mutex
+	client   *acme.Client
+
+	// HTTP-01 key authorizations by token, served from ChallengePathPrefix
+	tokens sync.Map
+
+	certsMu sync.RWMutex
+	certs   map[string]*domain.Certificate // by primary domain
+
+	ordersMu sync.Mutex
+	orders   map[string]*OrderStatus // by primary domain
+
+	onChange func(certs []*domain.Certificate)
+
+	queue  chan []string
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewManager creates a certificate manager. dns is required for DNS-01 and wildcard names.
+func NewManager(config Config, store Store, dns DNSProvider, logger *zap.Logger) (*Manager, error) {
+	if config.Email == "" {
+		return nil, fmt.Errorf("ACME email is required")
+	}
+	if config.DirectoryURL == "" {
+		config.DirectoryURL = LetsEncryptURL
+	}
+	switch config.Challenge {
+	case "":
+		config.Challenge = ChallengeHTTP01
+	case ChallengeHTTP01:
+	case ChallengeDNS01:
+		if dns == nil {
+			return nil, fmt.Errorf("dns-01 challenge requires a DNS provider")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported ACME challenge %q", config.Challenge)
+	}
+	if config.RenewBefore <= 0 {
+		config.RenewBefore = 30 * 24 * time.Hour
+	}
+	if config.CheckInterval <= 0 {
+		config.CheckInterval = 12 * time.Hour
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{
+		config: config,
+		store:  store,
+		dns:    dns,
+		logger: logger,
+		certs:  make(map[string]*domain.Certificate),
+		orders: make(map[string]*OrderStatus),
+		queue:  make(chan []string, 64),
+		ctx:    ctx,
+		cancel: cancel,
+	}, nil
+}
+
+// SetCertificatesChangedHandler sets a callback invoked with every current certificate
+// whenever one is issued, renewed or removed
+func (m *Manager) SetCertificatesChangedHandler(fn func(certs []*domain.Certificate)) {
+	m.onChange = fn
+}
+
+// Start loads stored certificates and begins issuing queued orders and renewing
+// certificates close to expiry
+func (m *Manager) Start(ctx context.Context) error {
+	stored, err := m.store.ListCertificates(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load certificates: %w", err)
+	}
+	m.certsMu.Lock()
+	for _, cert := range stored {
+		m.certs[cert.Domain] = cert
+	}
+	m.certsMu.Unlock()
+	m.notify()
+
+	m.wg.Add(2)
+	go m.worker()
+	go m.renewLoop()
+
+	m.logger.Info("ACME certificate manager started",
+		zap.String("directory", m.config.DirectoryURL),
+		zap.String("challenge", m.config.Challenge),
+		zap.Int("certificates", len(stored)),
+	)
+	return nil
+}
+
+// Stop stops issuing and renewing certificates
+func (m *Manager) Stop() {
+	m.cancel()
+	m.wg.Wait()
+}
+
+// Request queues an order for the names unless a current certificate already covers them.
+// The first name is the certificate's primary domain.
+func (m *Manager) Request(domains ...string) {
+	domains = normalizeDomains(domains)
+	if len(domains) == 0 || m.covered(domains) {
+		return
+	}
+
+	m.ordersMu.Lock()
+	if status, ok := m.orders[domains[0]]; ok && status.State == "pending" {
+		m.ordersMu.Unlock()
+		return
+	}
+	m.orders[domains[0]] = &OrderStatus{Domains: domains, State: "pending", UpdatedAt: time.Now().UTC()}
+	m.ordersMu.Unlock()
+
+	select {
+	case m.queue <- domains:
+	default:
+		m.setOrderStatus(domains, fmt.Errorf("order queue is full"))
+	}
+}
+
+// Obtain orders a certificate for the names now, replacing any existing one
+func (m *Manager) Obtain(ctx context.Context, domains []string) (*domain.Certificate, error) {
+	domains = normalizeDomains(domains)
+	if len(domains) == 0 {
+		return nil, fmt.Errorf("at least one domain is required")
+	}
+	cert, err := m.issue(ctx, domains)
+	m.setOrderStatus(domains, err)
+	if err != nil {
+		return nil, err
+	}
+	return cert, nil
+}
+
+// Remove deletes a certificate and stops renewing it
+func (m *Manager) Remove(ctx context.Context, primary string) error {
+	primary = strings.ToLower(primary)
+	if err := m.store.DeleteCertificate(ctx, primary); err != nil {
+		return err
+	}
+	m.certsMu.Lock()
+	delete(m.certs, primary)
+	m.certsMu.Unlock()
+	m.notify()
+	return nil
+}
+
+// Certificates returns the managed certificates, soonest expiry first
+func (m *Manager) Certificates() []*domain.Certificate {
+	m.certsMu.RLock()
+	certs := make([]*domain.Certificate, 0, len(m.certs))
+	for _, cert := range m.certs {
+		certs = append(certs, cert)
+	}
+	m.certsMu.RUnlock()
+
+	sort.Slice(certs, func(i, j int) bool { return certs[i].NotAfter.Before(certs[j].NotAfter) })
+	return certs
+}
+
+// Orders returns the status of recent orders
+func (m *Manager) Orders() []OrderStatus {
+	m.ordersMu.Lock()
+	defer m.ordersMu.Unlock()
+
+	orders := make([]OrderStatus, 0, len(m.orders))
+	for _, status := range m.orders {
+		orders = append(orders, *status)
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].UpdatedAt.After(orders[j].UpdatedAt) })
+	return orders
+}
+
+// RenewBefore returns the renewal window
+func (m *Manager) RenewBefore() time.Duration {
+	return m.config.RenewBefore
+}
+
+// ServeHTTP answers HTTP-01 challenges
+func (m *Manager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.URL.Path, ChallengePathPrefix)
+	keyAuth, ok := m.tokens.Load(token)
+	if !ok || token == "" || strings.Contains(token, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte(keyAuth.(string)))
+}
+
+// worker issues queued orders one at a time so the CA's rate limits are not hit in bursts
+func (m *Manager) worker() {
+	defer m.wg.Done()
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case domains := <-m.queue:
+			ctx, cancel := context.WithTimeout(m.ctx, issueTimeout)
+			_, err := m.issue(ctx, domains)
+			cancel()
+			m.setOrderStatus(domains, err)
+		}
+	}
+}
+
+// renewLoop periodically queues certificates that are close to expiry
+func (m *Manager) renewLoop() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(m.config.CheckInterval)
+	defer ticker.Stop()
+
+	m.queueRenewals()
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			m.queueRenewals()
+		}
+	}
+}
+
+func (m *Manager) queueRenewals() {
+	for _, cert := range m.Certificates() {
+		if cert.NeedsRenewal(m.config.RenewBefore) {
+			m.logger.Info("Renewing certificate",
+				zap.String("domain", cert.Domain),
+				zap.Time("not_after", cert.NotAfter),
+			)
+			m.ordersMu.Lock()
+			m.orders[cert.Domain] = &OrderStatus{Domains: cert.Domains, State: "pending", UpdatedAt: time.Now().UTC()}
+			m.ordersMu.Unlock()
+			select {
+			case m.queue <- cert.Domains:
+			default:
+			}
+		}
+	}
+}
+
+// covered reports whether an existing certificate outside its renewal window covers every name
+func (m *Manager) covered(domains []string) bool {
+	m.certsMu.RLock()
+	defer m.certsMu.RUnlock()
+
+	for _, name := range domains {
+		found := false
+		for _, cert := range m.certs {
+			if cert.Covers(name) && !cert.NeedsRenewal(m.config.RenewBefore) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *Manager) setOrderStatus(domains []string, err error) {
+	status := &OrderStatus{Domains: domains, State: "issued", UpdatedAt: time.Now().UTC()}
+	if err != nil {
+		status.State = "failed"
+		status.Error = err.Error()
+		m.logger.Warn("Certificate order failed", zap.Strings("domains", domains), zap.Error(err))
+	}
+	m.ordersMu.Lock()
+	m.orders[domains[0]] = status
+	m.ordersMu.Unlock()
+}
+
+func (m *Manager) notify() {
+	if m.onChange != nil {
+		m.onChange(m.Certificates())
+	}
+}
+
+// issue runs a complete ACME order and stores the resulting certificate
+func (m *Manager) issue(ctx context.Context, domains []string) (*domain.Certificate, error) {
+	for _, name := range domains {
+		if strings.HasPrefix(name, "*.") && m.config.Challenge != ChallengeDNS01 {
+			return nil, fmt.Errorf("wildcard name %s requires the dns-01 challenge", name)
+		}
+	}
+
+	client, err := m.acmeClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := client.AuthorizeOrder(ctx, acme.DomainIDs(domains...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create order: %w", err)
+	}
+	for _, authzURL := range order.AuthzURLs {
+		if err := m.authorize(ctx, client, authzURL); err != nil {
+			return nil, err
+		}
+	}
+	order, err = client.WaitOrder(ctx, order.URI)
+	if err != nil {
+		return nil, fmt.Errorf("order did not become ready: %w", err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate certificate key: %w", err)
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: strings.TrimPrefix(domains[0], "*.")},
+		DNSNames: domains,
+	}, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CSR: %w", err)
+	}
+	chain, _, err := client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to finalize order: %w", err)
+	}
+
+	cert, err := buildCertificate(domains, chain, key)
+	if err != nil {
+		return nil, err
+	}
+	if existing, err := m.store.GetCertificate(ctx, cert.Domain); err == nil && existing != nil {
+		cert.CreatedAt = existing.CreatedAt
+	}
+	if err := m.store.SaveCertificate(ctx, cert); err != nil {
+		return nil, fmt.Errorf("failed to save certificate: %w", err)
+	}
+
+	m.certsMu.Lock()
+	m.certs[cert.Domain] = cert
+	m.certsMu.Unlock()
+	m.notify()
+
+	m.logger.Info("Certificate issued",
+		zap.Strings("domains", domains),
+		zap.Time("not_after", cert.NotAfter),
+	)
+	return cert, nil
+}
+
+// authorize completes one authorization with the configured challenge
+func (m *Manager) authorize(ctx context.Context, client *acme.Client, authzURL string) error {
+	authz, err := client.GetAuthorization(ctx, authzURL)
+	if err != nil {
+		return fmt.Errorf("failed to get authorization: %w", err)
+	}
+	if authz.Status == acme.StatusValid {
+		return nil
+	}
+
+	var chal *acme.Challenge
+	for _, c := range authz.Challenges {
+		if c.Type == m.config.Challenge {
+			chal = c
+			break
+		}
+	}
+	if chal == nil {
+		return fmt.Errorf("CA offered no %s challenge for %s", m.config.Challenge, authz.Identifier.Value)
+	}
+
+	switch chal.Type {
+	case ChallengeHTTP01:
+		keyAuth, err := client.HTTP01ChallengeResponse(chal.Token)
+		if err != nil {
+			return err
+		}
+		m.tokens.Store(chal.Token, keyAuth)
+		defer m.tokens.Delete(chal.Token)
+	case ChallengeDNS01:
+		value, err := client.DNS01ChallengeRecord(chal.Token)
+		if err != nil {
+			return err
+		}
+		fqdn := "_acme-challenge." + authz.Identifier.Value
+		if err := m.dns.Present(ctx, fqdn, value); err != nil {
+			return fmt.Errorf("failed to publish DNS record for %s: %w", authz.Identifier.Value, err)
+		}
+		defer func() {
+			if err := m.dns.CleanUp(context.Background(), fqdn, value); err != nil {
+				m.logger.Warn("Failed to remove DNS challenge record", zap.String("fqdn", fqdn), zap.Error(err))
+			}
+		}()
+		select {
+		case <-time.After(m.config.DNSPropagationWait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if _, err := client.Accept(ctx, chal); err != nil {
+		return fmt.Errorf("failed to accept challenge: %w", err)
+	}
+	if _, err := client.WaitAuthorization(ctx, authz.URI); err != nil {
+		return fmt.Errorf("validation failed for %s: %w", authz.Identifier.Value, err)
+	}
+	return nil
+}
+
+// acmeClient returns a client for the stored account, registering one the first time
+func (m *Manager) acmeClient(ctx context.Context) (*acme.Client, error) {
+	m.clientMu.Lock()
+	defer m.clientMu.Unlock()
+	if m.client != nil {
+		return m.client, nil
+	}
+
+	account, err := m.store.GetAccount(ctx, m.config.Email)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ACME account: %w", err)
+	}
+
+	var key crypto.Signer
+	if account != nil {
+		key, err = parseKey(account.KeyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse ACME account key: %w", err)
+		}
+	} else {
+		ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate ACME account key: %w", err)
+		}
+		key = ecKey
+	}
+
+	client := &acme.Client{Key: key, DirectoryURL: m.config.DirectoryURL}
+	registered, err := client.Register(ctx, &acme.Account{Contact: []string{"mailto:" + m.config.Email}}, acme.AcceptTOS)
+	if err != nil && !errors.Is(err, acme.ErrAccountAlreadyExists) {
+		return nil, fmt.Errorf("failed to register ACME account: %w", err)
+	}
+
+	if account == nil {
+		keyPEM, err := encodeKey(key)
+		if err != nil {
+			return nil, err
+		}
+		account = &domain.ACMEAccount{Email: m.config.Email, KeyPEM: keyPEM, CreatedAt: time.Now().UTC()}
+		if registered != nil {
+			account.URI = registered.URI
+		}
+		if err := m.store.SaveAccount(ctx, account); err != nil {
+			return nil, fmt.Errorf("failed to save ACME account: %w", err)
+		}
+		m.logger.Info("ACME account registered", zap.String("email", m.config.Email))
+	}
+
+	m.client = client
+	return client, nil
+}
+
+// buildCertificate encodes an issued chain and key for storage
+func buildCertificate(domains []string, chain [][]byte, key crypto.Signer) (*domain.Certificate, error) {
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("CA returned an empty certificate chain")
+	}
+	leaf, err := x509.ParseCertificate(chain[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse issued certificate: %w", err)
+	}
+
+	var certPEM []byte
+	for _, der := range chain {
+		certPEM = append(certPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+	keyPEM, err := encodeKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	return &domain.Certificate{
+		Domain:    domains[0],
+		Domains:   domains,
+		CertPEM:   certPEM,
+		KeyPEM:    keyPEM,
+		Issuer:    leaf.Issuer.CommonName,
+		NotBefore: leaf.NotBefore.UTC(),
+		NotAfter:  leaf.NotAfter.UTC(),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}, nil
+}
+
+func encodeKey(key crypto.Signer) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+func parseKey(keyPEM []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+	return signer, nil
+}
+
+// normalizeDomains lowercases names and drops duplicates, keeping the first as primary
+func normalizeDomains(domains []string) []string {
+	seen := make(map[string]bool, len(domains))
+	result := make([]string, 0, len(domains))
+	for _, name := range domains {
+		name = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		result = append(result, name)
+	}
+	return result
+}
@@ -0,0 +1,176 @@
+package acme
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// DNSProvider publishes and removes the TXT records used by DNS-01 challenges
+type DNSProvider interface {
+	Present(ctx context.Context, fqdn, value string) error
+	CleanUp(ctx context.Context, fqdn, value string) error
+}
+
+// NewDNSProvider creates a provider by name: "cloudflare" uses an API token, "exec" runs
+// a command as `<command> present|cleanup <fqdn> <value>`. An empty name returns nil.
+func NewDNSProvider(name, cloudflareToken, command string) (DNSProvider, error) {
+	switch name {
+	case "":
+		return nil, nil
+	case "cloudflare":
+		if cloudflareToken == "" {
+			return nil, fmt.Errorf("cloudflare DNS provider requires an API token")
+		}
+		return &CloudflareProvider{token: cloudflareToken, client: &http.Client{Timeout: 30 * time.Second}}, nil
+	case "exec":
+		if command == "" {
+			return nil, fmt.Errorf("exec DNS provider requires a command")
+		}
+		return &ExecProvider{command: command}, nil
+	}
+	return nil, fmt.Errorf("unsupported DNS provider %q", name)
+}
+
+// ExecProvider delegates record changes to an external command
+type ExecProvider struct {
+	command string
+}
+
+// Present runs `<command> present <fqdn> <value>`
+func (p *ExecProvider) Present(ctx context.Context, fqdn, value string) error {
+	return p.run(ctx, "present", fqdn, value)
+}
+
+// CleanUp runs `<command> cleanup <fqdn> <value>`
+func (p *ExecProvider) CleanUp(ctx context.Context, fqdn, value string) error {
+	return p.run(ctx, "cleanup", fqdn, value)
+}
+
+func (p *ExecProvider) run(ctx context.Context, action, fqdn, value string) error {
+	output, err := exec.CommandContext(ctx, p.command, action, fqdn, value).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %w: %s", p.command, action, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// cloudflareAPI is the Cloudflare v4 API base URL
+const cloudflareAPI = "https://api.cloudflare.com/client/v4"
+
+// CloudflareProvider manages TXT records through the Cloudflare API
+type CloudflareProvider struct {
+	token  string
+	client *http.Client
+}
+
+type cloudflareResponse struct {
+	Success bool `json:"success"`
+	Errors  []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+	Result json.RawMessage `json:"result"`
+}
+
+type cloudflareRecord struct {
+	ID      string `json:"id,omitempty"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	TTL     int    `json:"ttl"`
+}
+
+// Present creates the TXT record in the zone that contains fqdn
+func (p *CloudflareProvider) Present(ctx context.Context, fqdn, value string) error {
+	zoneID, err := p.zoneID(ctx, fqdn)
+	if err != nil {
+		return err
+	}
+	record := cloudflareRecord{Type: "TXT", Name: fqdn, Content: value, TTL: 120}
+	return p.do(ctx, http.MethodPost, "/zones/"+zoneID+"/dns_records", record, nil)
+}
+
+// CleanUp deletes the matching TXT record
+func (p *CloudflareProvider) CleanUp(ctx context.Context, fqdn, value string) error {
+	zoneID, err := p.zoneID(ctx, fqdn)
+	if err != nil {
+		return err
+	}
+	var records []cloudflareRecord
+	if err := p.do(ctx, http.MethodGet, "/zones/"+zoneID+"/dns_records?type=TXT&name="+fqdn, nil, &records); err != nil {
+		return err
+	}
+	for _, record := range records {
+		if record.Content == value || record.Content == `"`+value+`"` {
+			if err := p.do(ctx, http.MethodDelete, "/zones/"+zoneID+"/dns_records/"+record.ID, nil, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// zoneID finds the most specific zone containing fqdn
+func (p *CloudflareProvider) zoneID(ctx context.Context, fqdn string) (string, error) {
+	labels := strings.Split(strings.TrimSuffix(fqdn, "."), ".")
+	for i := 0; i < len(labels)-1; i++ {
+		var zones []struct {
+			ID string `json:"id"`
+		}
+		name := strings.Join(labels[i:], ".")
+		if err := p.do(ctx, http.MethodGet, "/zones?name="+name, nil, &zones); err != nil {
+			return "", err
+		}
+		if len(zones) > 0 {
+			return zones[0].ID, nil
+		}
+	}
+	return "", fmt.Errorf("no Cloudflare zone found for %s", fqdn)
+}
+
+func (p *CloudflareProvider) do(ctx context.Context, method, path string, body, result interface{}) error {
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, cloudflareAPI+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("cloudflare request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var decoded cloudflareResponse
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return fmt.Errorf("failed to decode cloudflare response: %w", err)
+	}
+	if !decoded.Success {
+		messages := make([]string, 0, len(decoded.Errors))
+		for _, e := range decoded.Errors {
+			messages = append(messages, e.Message)
+		}
+		return fmt.Errorf("cloudflare %s %s: %s", method, path, strings.Join(messages, "; "))
+	}
+	if result != nil {
+		return json.Unmarshal(decoded.Result, result)
+	}
+	return nil
+}
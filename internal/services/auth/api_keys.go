@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// ErrAPIKeysDisabled is returned when no API key repository is configured
+var ErrAPIKeysDisabled = errors.New("api keys are not enabled")
+
+// apiKeyTouchInterval limits how often a key's last use is written back
+const apiKeyTouchInterval = time.Minute
+
+// APIKeyRepository interface for API key persistence
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *domain.APIKey) error
+	GetByHash(ctx context.Context, hash string) (*domain.APIKey, error)
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]*domain.APIKey, error)
+	Delete(ctx context.Context, userID, id uuid.UUID) error
+	TouchLastUsed(ctx context.Context, id uuid.UUID, at time.Time) error
+}
+
+// SetAPIKeyRepository enables API key authentication
+func (s *Service) SetAPIKeyRepository(repo APIKeyRepository) {
+	s.apiKeys = repo
+}
+
+// IsAPIKey reports whether a bearer token is an API key rather than a JWT
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, domain.APIKeyPrefix)
+}
+
+// CreateAPIKey creates an API key for a user and returns it with the key itself, which
+// is not stored and cannot be retrieved again
+func (s *Service) CreateAPIKey(ctx context.Context, user *domain.User, name string, scope domain.APIKeyScope, expiresAt *time.Time) (*domain.APIKey, string, error) {
+	if s.apiKeys == nil {
+		return nil, "", ErrAPIKeysDisabled
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+	token := domain.APIKeyPrefix + hex.EncodeToString(secret)
+
+	key := domain.NewAPIKey(user.ID, name, token[:len(domain.APIKeyPrefix)+8], hashSecret(token), scope, expiresAt)
+	if err := s.apiKeys.Create(ctx, key); err != nil {
+		return nil, "", err
+	}
+
+	s.logger.Info("API key created",
+		zap.String("user_id", user.ID.String()),
+		zap.String("key_id", key.ID.String()),
+		zap.String("scope", string(scope)),
+	)
+	return key, token, nil
+}
+
+// ListAPIKeys returns a user's API keys
+func (s *Service) ListAPIKeys(ctx context.Context, userID uuid.UUID) ([]*domain.APIKey, error) {
+	if s.apiKeys == nil {
+		return []*domain.APIKey{}, nil
+	}
+	return s.apiKeys.ListByUser(ctx, userID)
+}
+
+// RevokeAPIKey deletes one of a user's API keys; requests using it fail immediately
+func (s *Service) RevokeAPIKey(ctx context.Context, userID, id uuid.UUID) error {
+	if s.apiKeys == nil {
+		return ErrAPIKeysDisabled
+	}
+	if err := s.apiKeys.Delete(ctx, userID, id); err != nil {
+		return err
+	}
+
+	s.logger.Info("API key revoked",
+		zap.String("user_id", userID.String()),
+		zap.String("key_id", id.String()),
+	)
+	return nil
+}
+
+// AuthenticateAPIKey resolves an API key to its key record and user
+func (s *Service) AuthenticateAPIKey(ctx context.Context, token string) (*domain.User, *domain.APIKey, error) {
+	if s.apiKeys == nil {
+		return nil, nil, ErrInvalidToken
+	}
+
+	key, err := s.apiKeys.GetByHash(ctx, hashSecret(token))
+	if err != nil {
+		return nil, nil, ErrInvalidToken
+	}
+	if key.IsExpired() {
+		return nil, nil, ErrExpiredToken
+	}
+
+	user, err := s.userRepo.GetByID(ctx, key.UserID)
+	if err != nil {
+		return nil, nil, ErrUserNotFound
+	}
+	if !user.IsActive() {
+		return nil, nil, ErrUserDeactivated
+	}
+
+	now := time.Now().UTC()
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > apiKeyTouchInterval {
+		if err := s.apiKeys.TouchLastUsed(ctx, key.ID, now); err != nil {
+			s.logger.Warn("Failed to record API key use", zap.String("key_id", key.ID.String()), zap.Error(err))
+		}
+		key.LastUsedAt = &now
+	}
+	return user, key, nil
+}
+
+// AuditAPIKeyRequest logs a request made with an API key, attributing it to the key and
+// its user. Read-only requests are logged at debug level.
+func (s *Service) AuditAPIKeyRequest(key *domain.APIKey, method, path string) {
+	log := s.logger.Info
+	if method == "GET" || method == "HEAD" || method == "OPTIONS" {
+		log = s.logger.Debug
+	}
+	log("API key request",
+		zap.String("user_id", key.UserID.String()),
+		zap.String("key_id", key.ID.String()),
+		zap.String("key_name", key.Name),
+		zap.String("scope", string(key.Scope)),
+		zap.String("method", method),
+		zap.String("path", path),
+	)
+}
+
+// hashSecret returns the hex SHA-256 of an API key, deploy token, emailed token or
+// recovery code. Keys and tokens carry 256 random bits, so a fast hash is enough to make
+// a leaked table useless.
+func hashSecret(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
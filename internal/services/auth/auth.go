@@ -11,14 +11,17 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/nanopaas/nanopaas/internal/domain"
+	"github.com/nanopaas/nanopaas/internal/services/mail"
 )
 
 var (
 	ErrInvalidToken     = errors.New("invalid token")
 	ErrExpiredToken     = errors.New("token expired")
 	ErrInvalidClaims    = errors.New("invalid claims")
-	ErrUserNotFound     = errors.New("user not found")
+	ErrUserNotFound     = fmt.Errorf("user %w", domain.ErrNotFound)
 	ErrUnauthorized     = errors.New("unauthorized")
+	ErrRevokedToken     = errors.New("token revoked")
+	ErrUserDeactivated  = errors.New("user account is deactivated")
 )
 
 // Config holds auth configuration
@@ -34,6 +37,8 @@ type Claims struct {
 	Email     string          `json:"email"`
 	Role      domain.UserRole `json:"role"`
 	TokenType string          `json:"token_type"`
+	SessionID string          `json:"sid,omitempty"`
+	Provider  string          `json:"provider,omitempty"` // of the sign-in a 2fa token finishes
 	jwt.RegisteredClaims
 }
 
@@ -53,13 +58,44 @@ type UserRepository interface {
 	GetByGitHubID(ctx context.Context, githubID int64) (*domain.User, error)
 	Update(ctx context.Context, user *domain.User) error
 	Delete(ctx context.Context, id uuid.UUID) error
+	DeleteAndReassign(ctx context.Context, id, newOwnerID uuid.UUID) error
+	Search(ctx context.Context, q domain.UserQuery) ([]*domain.User, int, error)
+	Count(ctx context.Context) (int64, error)
+	CountActiveAdmins(ctx context.Context) (int64, error)
+	AdvanceTOTPStep(ctx context.Context, id uuid.UUID, step int64) (bool, error)
+	ConsumeRecoveryCode(ctx context.Context, id uuid.UUID, hash string) (bool, error)
 }
 
 // Service handles authentication
 type Service struct {
 	config   Config
 	userRepo UserRepository
+	apiKeys  APIKeyRepository
+	sessions SessionStore
+	keys     *KeySet
 	logger   *zap.Logger
+
+	// Email/password accounts, enabled by SetPasswordLogin
+	userTokens   UserTokenRepository
+	mailer       mail.Sender
+	passwordOpts PasswordOptions
+
+	// OpenID Connect account links, enabled by SetIdentityRepository
+	identities IdentityRepository
+
+	// Emails promoted to admin when they sign in, set by SetBootstrapAdmins
+	adminEmails    []string
+	firstUserAdmin bool
+
+	// Two-factor authentication settings, set by SetTwoFactorPolicy
+	twoFactorIssuer   string
+	twoFactorRequired bool
+
+	// Per-app deploy tokens, enabled by SetDeployTokenRepository
+	deployTokens DeployTokenRepository
+
+	// Sign-in audit trail, enabled by SetLoginEventRepository
+	loginEvents LoginEventRepository
 }
 
 // NewService creates a new auth service
@@ -71,8 +107,45 @@ func NewService(config Config, userRepo UserRepository, logger *zap.Logger) *Ser
 	}
 }
 
-// GenerateTokens generates access and refresh tokens for a user
-func (s *Service) GenerateTokens(user *domain.User) (*TokenPair, error) {
+// GenerateTokens starts a new session for a user and issues its access and refresh
+// tokens. Users with two-factor authentication get a *TwoFactorChallenge error instead,
+// and the tokens once they answer it with VerifyTwoFactor.
+func (s *Service) GenerateTokens(ctx context.Context, user *domain.User) (*TokenPair, error) {
+	if !user.IsActive() {
+		s.recordLogin(ctx, user.ID, "", domain.LoginFailedDeactivated)
+		return nil, ErrUserDeactivated
+	}
+	if err := s.promoteConfiguredAdmin(ctx, user); err != nil {
+		return nil, err
+	}
+	if user.TwoFactorEnabled {
+		return nil, s.twoFactorChallenge(ctx, user)
+	}
+	return s.startSession(ctx, user)
+}
+
+// startSession starts a new session for a user, issues its tokens and records the
+// sign-in in the audit trail
+func (s *Service) startSession(ctx context.Context, user *domain.User) (*TokenPair, error) {
+	now := time.Now().UTC()
+	session := &domain.Session{
+		ID:        uuid.NewString(),
+		UserID:    user.ID,
+		CreatedAt: now,
+	}
+	if client, ok := ctx.Value(clientInfoKey{}).(ClientInfo); ok {
+		session.IP, session.UserAgent, session.Provider = client.IP, client.UserAgent, client.Provider
+	}
+	tokens, err := s.issueTokens(ctx, user, session)
+	if err != nil {
+		return nil, err
+	}
+	s.recordLogin(ctx, user.ID, session.ID, "")
+	return tokens, nil
+}
+
+// issueTokens issues a token pair in a session and records the session
+func (s *Service) issueTokens(ctx context.Context, user *domain.User, session *domain.Session) (*TokenPair, error) {
 	now := time.Now()
 	accessExpiry := now.Add(s.config.JWTExpiry)
 	refreshExpiry := now.Add(s.config.JWTRefreshExpiry)
@@ -83,7 +156,9 @@ func (s *Service) GenerateTokens(user *domain.User) (*TokenPair, error) {
 		Email:     user.Email,
 		Role:      user.Role,
 		TokenType: "access",
+		SessionID: session.ID,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
 			ExpiresAt: jwt.NewNumericDate(accessExpiry),
 			IssuedAt:  jwt.NewNumericDate(now),
 			NotBefore: jwt.NewNumericDate(now),
@@ -92,8 +167,7 @@ func (s *Service) GenerateTokens(user *domain.User) (*TokenPair, error) {
 		},
 	}
 
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims)
-	accessTokenString, err := accessToken.SignedString([]byte(s.config.JWTSecret))
+	accessTokenString, err := s.sign(accessClaims)
 	if err != nil {
 		return nil, fmt.Errorf("failed to sign access token: %w", err)
 	}
@@ -104,7 +178,9 @@ func (s *Service) GenerateTokens(user *domain.User) (*TokenPair, error) {
 		Email:     user.Email,
 		Role:      user.Role,
 		TokenType: "refresh",
+		SessionID: session.ID,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
 			ExpiresAt: jwt.NewNumericDate(refreshExpiry),
 			IssuedAt:  jwt.NewNumericDate(now),
 			NotBefore: jwt.NewNumericDate(now),
@@ -113,14 +189,18 @@ func (s *Service) GenerateTokens(user *domain.User) (*TokenPair, error) {
 		},
 	}
 
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
-	refreshTokenString, err := refreshToken.SignedString([]byte(s.config.JWTSecret))
+	refreshTokenString, err := s.sign(refreshClaims)
 	if err != nil {
 		return nil, fmt.Errorf("failed to sign refresh token: %w", err)
 	}
 
+	session.RefreshedAt = now.UTC()
+	session.ExpiresAt = refreshExpiry.UTC()
+	s.saveSession(ctx, session)
+
 	s.logger.Debug("Generated tokens for user",
 		zap.String("user_id", user.ID.String()),
+		zap.String("session_id", session.ID),
 		zap.Time("access_expires", accessExpiry),
 	)
 
@@ -134,12 +214,7 @@ func (s *Service) GenerateTokens(user *domain.User) (*TokenPair, error) {
 
 // ValidateToken validates a JWT token and returns claims
 func (s *Service) ValidateToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(s.config.JWTSecret), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, s.verificationKey)
 
 	if err != nil {
 		if errors.Is(err, jwt.ErrTokenExpired) {
@@ -156,7 +231,8 @@ func (s *Service) ValidateToken(tokenString string) (*Claims, error) {
 	return claims, nil
 }
 
-// RefreshTokens refreshes the token pair using a refresh token
+// RefreshTokens refreshes the token pair using a refresh token. The new pair stays in
+// the refresh token's session, and the refresh token itself is revoked.
 func (s *Service) RefreshTokens(ctx context.Context, refreshToken string) (*TokenPair, error) {
 	claims, err := s.ValidateToken(refreshToken)
 	if err != nil {
@@ -166,26 +242,48 @@ func (s *Service) RefreshTokens(ctx context.Context, refreshToken string) (*Toke
 	if claims.TokenType != "refresh" {
 		return nil, ErrInvalidToken
 	}
+	if s.isRevoked(ctx, claims) {
+		return nil, ErrRevokedToken
+	}
 
 	user, err := s.userRepo.GetByID(ctx, claims.UserID)
 	if err != nil {
 		return nil, ErrUserNotFound
 	}
+	if !user.IsActive() {
+		return nil, ErrUserDeactivated
+	}
+
+	session := s.refreshSession(ctx, claims)
+	s.revokeToken(ctx, claims)
+	return s.issueTokens(ctx, user, session)
+}
 
-	return s.GenerateTokens(user)
+// RefreshTokenTTL returns how long refresh tokens are valid
+func (s *Service) RefreshTokenTTL() time.Duration {
+	return s.config.JWTRefreshExpiry
 }
 
-// GetUserFromToken retrieves user from a valid token
+// GetUserFromToken retrieves user from a valid, unrevoked access token
 func (s *Service) GetUserFromToken(ctx context.Context, tokenString string) (*domain.User, error) {
 	claims, err := s.ValidateToken(tokenString)
 	if err != nil {
 		return nil, err
 	}
+	if claims.TokenType != "access" {
+		return nil, ErrInvalidToken
+	}
+	if s.isRevoked(ctx, claims) {
+		return nil, ErrRevokedToken
+	}
 
 	user, err := s.userRepo.GetByID(ctx, claims.UserID)
 	if err != nil {
 		return nil, ErrUserNotFound
 	}
+	if !user.IsActive() {
+		return nil, ErrUserDeactivated
+	}
 
 	return user, nil
 }
@@ -195,16 +293,26 @@ func (s *Service) AuthenticateGitHub(ctx context.Context, githubID int64, login,
 	// Check if user exists
 	user, err := s.userRepo.GetByGitHubID(ctx, githubID)
 	if err != nil {
-		// Create new user
-		user = domain.NewUserFromGitHub(githubID, login, email, name, avatarURL, token)
-		if err := s.userRepo.Create(ctx, user); err != nil {
-			return nil, nil, fmt.Errorf("failed to create user: %w", err)
+		// An email/password account with the same email gets GitHub linked to it
+		user, err = s.linkGitHub(ctx, githubID, login, email, avatarURL, token)
+		if err != nil {
+			return nil, nil, err
+		}
+		if user == nil {
+			// Create new user
+			user = domain.NewUserFromGitHub(githubID, login, email, name, avatarURL, token)
+			if err := s.createUser(ctx, user); err != nil {
+				return nil, nil, fmt.Errorf("failed to create user: %w", err)
+			}
+			s.logger.Info("New user created from GitHub",
+				zap.String("user_id", user.ID.String()),
+				zap.String("github_login", login),
+			)
 		}
-		s.logger.Info("New user created from GitHub",
-			zap.String("user_id", user.ID.String()),
-			zap.String("github_login", login),
-		)
 	} else {
+		// A new GitHub token signs out every other session of the user
+		tokenChanged := user.GitHubToken != "" && user.GitHubToken != token
+
 		// Update existing user
 		user.GitHubToken = token
 		user.AvatarURL = avatarURL
@@ -216,9 +324,14 @@ func (s *Service) AuthenticateGitHub(ctx context.Context, githubID int64, login,
 			zap.String("user_id", user.ID.String()),
 			zap.String("github_login", login),
 		)
+		if tokenChanged && s.sessions != nil {
+			if _, err := s.RevokeAllSessions(ctx, user.ID); err != nil {
+				s.logger.Warn("Failed to revoke sessions after GitHub token change", zap.String("user_id", user.ID.String()), zap.Error(err))
+			}
+		}
 	}
 
-	tokens, err := s.GenerateTokens(user)
+	tokens, err := s.GenerateTokens(ctx, user)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// ErrDeployTokensDisabled is returned when no deploy token repository is configured
+var ErrDeployTokensDisabled = errors.New("deploy tokens are not enabled")
+
+// DeployTokenRepository interface for deploy token persistence
+type DeployTokenRepository interface {
+	Create(ctx context.Context, t *domain.DeployToken) error
+	GetByHash(ctx context.Context, hash string) (*domain.DeployToken, error)
+	ListByApp(ctx context.Context, appID uuid.UUID) ([]*domain.DeployToken, error)
+	Delete(ctx context.Context, appID, id uuid.UUID) error
+	TouchLastUsed(ctx context.Context, id uuid.UUID, at time.Time) error
+}
+
+// SetDeployTokenRepository enables deploy token authentication
+func (s *Service) SetDeployTokenRepository(repo DeployTokenRepository) {
+	s.deployTokens = repo
+}
+
+// IsDeployToken reports whether a bearer token is a deploy token
+func IsDeployToken(token string) bool {
+	return strings.HasPrefix(token, domain.DeployTokenPrefix)
+}
+
+// CreateDeployToken creates a deploy token for an app, acting as user, and returns it
+// with the token itself, which is not stored and cannot be retrieved again
+func (s *Service) CreateDeployToken(ctx context.Context, user *domain.User, appID uuid.UUID, name string, expiresAt time.Time) (*domain.DeployToken, string, error) {
+	if s.deployTokens == nil {
+		return nil, "", ErrDeployTokensDisabled
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", fmt.Errorf("failed to generate deploy token: %w", err)
+	}
+	token := domain.DeployTokenPrefix + hex.EncodeToString(secret)
+
+	t := domain.NewDeployToken(appID, user.ID, name, token[:len(domain.DeployTokenPrefix)+8], hashSecret(token), expiresAt)
+	if err := s.deployTokens.Create(ctx, t); err != nil {
+		return nil, "", err
+	}
+
+	s.logger.Info("Deploy token created",
+		zap.String("user_id", user.ID.String()),
+		zap.String("app_id", appID.String()),
+		zap.String("token_id", t.ID.String()),
+		zap.Time("expires_at", expiresAt),
+	)
+	return t, token, nil
+}
+
+// ListDeployTokens returns an app's deploy tokens
+func (s *Service) ListDeployTokens(ctx context.Context, appID uuid.UUID) ([]*domain.DeployToken, error) {
+	if s.deployTokens == nil {
+		return []*domain.DeployToken{}, nil
+	}
+	return s.deployTokens.ListByApp(ctx, appID)
+}
+
+// RevokeDeployToken deletes one of an app's deploy tokens; requests using it fail
+// immediately
+func (s *Service) RevokeDeployToken(ctx context.Context, user *domain.User, appID, id uuid.UUID) error {
+	if s.deployTokens == nil {
+		return ErrDeployTokensDisabled
+	}
+	if err := s.deployTokens.Delete(ctx, appID, id); err != nil {
+		return err
+	}
+
+	s.logger.Info("Deploy token revoked",
+		zap.String("app_id", appID.String()),
+		zap.String("token_id", id.String()),
+		zap.String("by", user.ID.String()),
+	)
+	return nil
+}
+
+// AuthenticateDeployToken resolves a deploy token to its record and the user who
+// created it
+func (s *Service) AuthenticateDeployToken(ctx context.Context, token string) (*domain.User, *domain.DeployToken, error) {
+	if s.deployTokens == nil {
+		return nil, nil, ErrInvalidToken
+	}
+
+	t, err := s.deployTokens.GetByHash(ctx, hashSecret(token))
+	if err != nil {
+		return nil, nil, ErrInvalidToken
+	}
+	if t.IsExpired() {
+		return nil, nil, ErrExpiredToken
+	}
+
+	user, err := s.userRepo.GetByID(ctx, t.CreatedBy)
+	if err != nil {
+		return nil, nil, ErrUserNotFound
+	}
+	if !user.IsActive() {
+		return nil, nil, ErrUserDeactivated
+	}
+
+	now := time.Now().UTC()
+	if t.LastUsedAt == nil || now.Sub(*t.LastUsedAt) > apiKeyTouchInterval {
+		if err := s.deployTokens.TouchLastUsed(ctx, t.ID, now); err != nil {
+			s.logger.Warn("Failed to record deploy token use", zap.String("token_id", t.ID.String()), zap.Error(err))
+		}
+		t.LastUsedAt = &now
+	}
+	return user, t, nil
+}
+
+// AuditDeployTokenRequest logs a request made with a deploy token, attributing it to the
+// token and the user who created it. Status checks are logged at debug level.
+func (s *Service) AuditDeployTokenRequest(t *domain.DeployToken, method, path string) {
+	log := s.logger.Info
+	if method == "GET" || method == "HEAD" {
+		log = s.logger.Debug
+	}
+	log("Deploy token request",
+		zap.String("user_id", t.CreatedBy.String()),
+		zap.String("app_id", t.AppID.String()),
+		zap.String("token_id", t.ID.String()),
+		zap.String("token_name", t.Name),
+		zap.String("method", method),
+		zap.String("path", path),
+	)
+}
@@ -0,0 +1,448 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+)
+
+// Signing algorithms for access and refresh tokens
+const (
+	AlgorithmHS256 = "HS256" // shared JWT_SECRET, verifiable only by NanoPaaS
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+const (
+	rsaKeyBits = 2048
+
+	// keyReloadInterval is how often the key directory is reread for keys another
+	// replica added
+	keyReloadInterval = 10 * time.Second
+)
+
+// SigningKey is a private key tokens are signed with; its public half verifies them
+type SigningKey struct {
+	ID        string
+	Method    jwt.SigningMethod
+	Private   crypto.Signer
+	CreatedAt time.Time
+}
+
+// JWK is a public key in JSON Web Key format
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	N         string `json:"n,omitempty"`   // RSA modulus
+	E         string `json:"e,omitempty"`   // RSA exponent
+	Curve     string `json:"crv,omitempty"` // OKP curve
+	X         string `json:"x,omitempty"`   // OKP public key
+}
+
+// JWKS is a JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// KeySet holds the keys tokens are signed and verified with. The newest key signs; older
+// keys keep verifying the tokens they signed until they are pruned. Keys are PEM files in
+// a directory shared by all replicas, named by key ID.
+type KeySet struct {
+	algorithm string
+	dir       string
+	logger    *zap.Logger
+
+	mu         sync.RWMutex
+	keys       map[string]*SigningKey
+	active     *SigningKey
+	lastReload time.Time
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewKeySet loads the keys for algorithm from dir, generating a first key when there is
+// none. Without a directory the key lives in memory, so tokens don't survive a restart
+// and replicas can't verify each other's tokens.
+func NewKeySet(algorithm, dir string, logger *zap.Logger) (*KeySet, error) {
+	if algorithm != AlgorithmRS256 && algorithm != AlgorithmEdDSA {
+		return nil, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+	ks := &KeySet{
+		algorithm: algorithm,
+		dir:       dir,
+		logger:    logger,
+		keys:      make(map[string]*SigningKey),
+		stop:      make(chan struct{}),
+	}
+
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, fmt.Errorf("failed to create key directory: %w", err)
+		}
+		if err := ks.reload(); err != nil {
+			return nil, err
+		}
+	} else {
+		logger.Warn("JWT_KEYS_DIR is not set; signing with an in-memory key, tokens end with the process")
+	}
+
+	if ks.Active() == nil {
+		if _, err := ks.Rotate(0); err != nil {
+			return nil, err
+		}
+	}
+	return ks, nil
+}
+
+// Algorithm returns the signing algorithm
+func (ks *KeySet) Algorithm() string {
+	return ks.algorithm
+}
+
+// Active returns the key new tokens are signed with
+func (ks *KeySet) Active() *SigningKey {
+	ks.reloadIfStale()
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	return ks.active
+}
+
+// Get returns the key with the given ID. A key missing from memory is looked up in the
+// key directory, since another replica may have rotated it in since the last reload.
+func (ks *KeySet) Get(id string) (*SigningKey, bool) {
+	ks.reloadIfStale()
+	ks.mu.RLock()
+	key, ok := ks.keys[id]
+	ks.mu.RUnlock()
+	if ok || ks.dir == "" || id == "" || strings.ContainsAny(id, `/\.`) {
+		return key, ok
+	}
+
+	key, err := readKey(filepath.Join(ks.dir, id+".pem"))
+	if err != nil || key.Method.Alg() != ks.algorithm {
+		return nil, false
+	}
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	if existing, ok := ks.keys[id]; ok {
+		return existing, true
+	}
+	ks.keys[id] = key
+	return key, true
+}
+
+// reloadIfStale rereads the key directory at most every keyReloadInterval, so replicas
+// pick up keys rotated by another replica
+func (ks *KeySet) reloadIfStale() {
+	if ks.dir == "" {
+		return
+	}
+	ks.mu.RLock()
+	stale := time.Since(ks.lastReload) > keyReloadInterval
+	ks.mu.RUnlock()
+	if !stale {
+		return
+	}
+	if err := ks.reload(); err != nil {
+		ks.logger.Warn("Failed to reload signing keys", zap.Error(err))
+		ks.mu.Lock()
+		ks.lastReload = time.Now()
+		ks.mu.Unlock()
+	}
+}
+
+// Rotate generates a new signing key and makes it active. Keys superseded more than
+// retention ago are deleted; 0 keeps them all.
+func (ks *KeySet) Rotate(retention time.Duration) (*SigningKey, error) {
+	key, err := generateKey(ks.algorithm)
+	if err != nil {
+		return nil, err
+	}
+	if ks.dir != "" {
+		if err := writeKey(filepath.Join(ks.dir, key.ID+".pem"), key.Private); err != nil {
+			return nil, err
+		}
+	}
+
+	ks.mu.Lock()
+	ks.keys[key.ID] = key
+	ks.active = key
+	ks.mu.Unlock()
+
+	ks.logger.Info("JWT signing key rotated", zap.String("kid", key.ID), zap.String("alg", ks.algorithm))
+	if retention > 0 {
+		ks.prune(retention)
+	}
+	return key, nil
+}
+
+// Start rotates the signing key every interval, deleting keys superseded more than
+// retention ago. Run it on one replica only when replicas share the key directory.
+func (ks *KeySet) Start(interval, retention time.Duration) {
+	ks.wg.Add(1)
+	go func() {
+		defer ks.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := ks.Rotate(retention); err != nil {
+					ks.logger.Error("Failed to rotate JWT signing key", zap.Error(err))
+				}
+			case <-ks.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops key rotation
+func (ks *KeySet) Stop() {
+	close(ks.stop)
+	ks.wg.Wait()
+}
+
+// JWKS returns the public keys of every key that may still verify tokens
+func (ks *KeySet) JWKS() JWKS {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	set := JWKS{Keys: make([]JWK, 0, len(ks.keys))}
+	for _, key := range ks.sortedKeys() {
+		jwk := JWK{Use: "sig", Algorithm: key.Method.Alg(), KeyID: key.ID}
+		switch pub := key.Private.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+// reload reads every key of the set's algorithm from the key directory; the newest is active
+func (ks *KeySet) reload() error {
+	entries, err := os.ReadDir(ks.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read key directory: %w", err)
+	}
+
+	keys := make(map[string]*SigningKey)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".pem") {
+			continue
+		}
+		path := filepath.Join(ks.dir, entry.Name())
+		key, err := readKey(path)
+		if err != nil {
+			ks.logger.Warn("Skipping unreadable signing key", zap.String("path", path), zap.Error(err))
+			continue
+		}
+		if key.Method.Alg() != ks.algorithm {
+			continue
+		}
+		keys[key.ID] = key
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.lastReload = time.Now()
+	if len(keys) == 0 {
+		return nil // keep signing with the keys in memory
+	}
+	ks.keys = keys
+	ks.active = nil
+	for _, key := range ks.keys {
+		if ks.active == nil || key.CreatedAt.After(ks.active.CreatedAt) {
+			ks.active = key
+		}
+	}
+	return nil
+}
+
+// prune deletes keys whose successor became active more than retention ago
+func (ks *KeySet) prune(retention time.Duration) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	keys := ks.sortedKeys()
+	for i := 0; i < len(keys)-1; i++ {
+		if time.Since(keys[i+1].CreatedAt) <= retention {
+			continue
+		}
+		delete(ks.keys, keys[i].ID)
+		if ks.dir != "" {
+			if err := os.Remove(filepath.Join(ks.dir, keys[i].ID+".pem")); err != nil && !os.IsNotExist(err) {
+				ks.logger.Warn("Failed to delete retired signing key", zap.String("kid", keys[i].ID), zap.Error(err))
+			}
+		}
+		ks.logger.Info("JWT signing key retired", zap.String("kid", keys[i].ID))
+	}
+}
+
+// sortedKeys returns the keys oldest first; callers hold the lock
+func (ks *KeySet) sortedKeys() []*SigningKey {
+	keys := make([]*SigningKey, 0, len(ks.keys))
+	for _, key := range ks.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt) })
+	return keys
+}
+
+// generateKey creates a key named after its creation time, so names sort by age
+func generateKey(algorithm string) (*SigningKey, error) {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, fmt.Errorf("failed to generate key id: %w", err)
+	}
+	now := time.Now().UTC()
+	key := &SigningKey{
+		ID:        now.Format("20060102T150405Z") + "-" + hex.EncodeToString(suffix),
+		CreatedAt: now,
+	}
+
+	switch algorithm {
+	case AlgorithmRS256:
+		private, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate RSA key: %w", err)
+		}
+		key.Method, key.Private = jwt.SigningMethodRS256, private
+	case AlgorithmEdDSA:
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate Ed25519 key: %w", err)
+		}
+		key.Method, key.Private = jwt.SigningMethodEdDSA, private
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+	return key, nil
+}
+
+// readKey reads a PKCS#8 or PKCS#1 PEM private key; its file name is its key ID
+func readKey(path string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block")
+	}
+
+	var private interface{}
+	if block.Type == "RSA PRIVATE KEY" {
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	} else {
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &SigningKey{ID: strings.TrimSuffix(filepath.Base(path), ".pem")}
+	switch private := private.(type) {
+	case *rsa.PrivateKey:
+		key.Method, key.Private = jwt.SigningMethodRS256, private
+	case ed25519.PrivateKey:
+		key.Method, key.Private = jwt.SigningMethodEdDSA, private
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", private)
+	}
+	if info, err := os.Stat(path); err == nil {
+		key.CreatedAt = info.ModTime()
+	}
+	return key, nil
+}
+
+// writeKey writes a private key as a PKCS#8 PEM file readable only by its owner. The file
+// is renamed into place so other replicas never read it half written.
+func writeKey(path string, private crypto.Signer) error {
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return fmt.Errorf("failed to encode signing key: %w", err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write signing key: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write signing key: %w", err)
+	}
+	return nil
+}
+
+// SetKeySet signs tokens with asymmetric keys instead of JWT_SECRET. Tokens signed
+// with the secret stop being accepted.
+func (s *Service) SetKeySet(keys *KeySet) {
+	s.keys = keys
+}
+
+// JWKS returns the public keys tokens can be verified with; empty for HS256
+func (s *Service) JWKS() JWKS {
+	if s.keys == nil {
+		return JWKS{Keys: []JWK{}}
+	}
+	return s.keys.JWKS()
+}
+
+// sign signs claims with the active key, naming it in the kid header
+func (s *Service) sign(claims *Claims) (string, error) {
+	if s.keys == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(s.config.JWTSecret))
+	}
+	key := s.keys.Active()
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.Private)
+}
+
+// verificationKey returns the key a token's signature is checked with, refusing
+// algorithms other than the configured one
+func (s *Service) verificationKey(token *jwt.Token) (interface{}, error) {
+	if s.keys == nil {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(s.config.JWTSecret), nil
+	}
+
+	if token.Method.Alg() != s.keys.Algorithm() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	kid, _ := token.Header["kid"].(string)
+	key, ok := s.keys.Get(kid)
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key.Private.Public(), nil
+}
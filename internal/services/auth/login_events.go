@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// LoginEventRepository interface for the sign-in audit trail
+type LoginEventRepository interface {
+	Create(ctx context.Context, e *domain.LoginEvent) error
+	ListByUser(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*domain.LoginEvent, int, error)
+}
+
+// SetLoginEventRepository enables the sign-in audit trail
+func (s *Service) SetLoginEventRepository(repo LoginEventRepository) {
+	s.loginEvents = repo
+}
+
+// ListLoginEvents returns a page of a user's sign-in attempts, newest first, and how
+// many there are
+func (s *Service) ListLoginEvents(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*domain.LoginEvent, int, error) {
+	if s.loginEvents == nil {
+		return []*domain.LoginEvent{}, 0, nil
+	}
+	return s.loginEvents.ListByUser(ctx, userID, limit, offset)
+}
+
+// recordLogin adds a sign-in attempt to the audit trail, with the client attached to
+// ctx. An empty reason records a successful sign-in. Failures to record are logged
+// rather than failing the sign-in.
+func (s *Service) recordLogin(ctx context.Context, userID uuid.UUID, sessionID, reason string) {
+	if s.loginEvents == nil {
+		return
+	}
+	client, _ := ctx.Value(clientInfoKey{}).(ClientInfo)
+	e := &domain.LoginEvent{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Provider:  client.Provider,
+		Success:   reason == "",
+		Reason:    reason,
+		SessionID: sessionID,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.loginEvents.Create(ctx, e); err != nil {
+		s.logger.Warn("Failed to record login event", zap.String("user_id", userID.String()), zap.Error(err))
+	}
+}
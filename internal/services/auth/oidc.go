@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+	"github.com/nanopaas/nanopaas/internal/services/oidc"
+)
+
+var (
+	ErrOIDCDisabled      = errors.New("single sign-on is not enabled")
+	ErrOIDCEmailRequired = errors.New("the identity provider did not share an email address")
+)
+
+// IdentityRepository interface for OpenID Connect account links
+type IdentityRepository interface {
+	Create(ctx context.Context, identity *domain.UserIdentity) error
+	GetUserID(ctx context.Context, issuer, subject string) (uuid.UUID, error)
+}
+
+// SetIdentityRepository enables single sign-on through an OpenID Connect provider
+func (s *Service) SetIdentityRepository(repo IdentityRepository) {
+	s.identities = repo
+}
+
+// AuthenticateOIDC signs in the user an OpenID Connect provider vouched for. A first
+// sign-in links the provider account to the user with the same verified email, or
+// creates one. Roles mapped from the provider's claims replace the user's role on
+// every sign-in.
+func (s *Service) AuthenticateOIDC(ctx context.Context, id *oidc.Identity) (*domain.User, *TokenPair, error) {
+	if s.identities == nil {
+		return nil, nil, ErrOIDCDisabled
+	}
+
+	user, err := s.oidcUser(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if id.Role != "" && id.Role != user.Role {
+		s.logger.Info("User role changed by identity provider",
+			zap.String("user_id", user.ID.String()),
+			zap.String("from", string(user.Role)),
+			zap.String("to", string(id.Role)),
+		)
+		user.Role = id.Role
+	}
+	if user.AvatarURL == "" {
+		user.AvatarURL = id.Picture
+	}
+	user.UpdateLastLogin()
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, nil, fmt.Errorf("failed to update user: %w", err)
+	}
+	s.logger.Info("User logged in via OIDC",
+		zap.String("user_id", user.ID.String()),
+		zap.String("issuer", id.Issuer),
+	)
+
+	tokens, err := s.GenerateTokens(ctx, user)
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, tokens, nil
+}
+
+// oidcUser returns the user linked to a provider account, linking or creating one
+func (s *Service) oidcUser(ctx context.Context, id *oidc.Identity) (*domain.User, error) {
+	userID, err := s.identities.GetUserID(ctx, id.Issuer, id.Subject)
+	if err == nil {
+		user, err := s.userRepo.GetByID(ctx, userID)
+		if err != nil {
+			return nil, ErrUserNotFound
+		}
+		return user, nil
+	}
+	if !errors.Is(err, domain.ErrNotFound) {
+		return nil, err
+	}
+	if id.Email == "" {
+		return nil, ErrOIDCEmailRequired
+	}
+
+	user, err := s.userRepo.GetByEmail(ctx, id.Email)
+	switch {
+	case err == nil && !id.EmailVerified:
+		// Linking on an unverified email would let anyone claim the account
+		return nil, ErrEmailTaken
+	case err == nil:
+		if !user.EmailVerified {
+			// As with GitHub, a password set before the address was proven is dropped
+			user.SetPasswordHash("")
+			user.EmailVerified = true
+		}
+	default:
+		user = domain.NewUser(id.Email, id.Name)
+		user.EmailVerified = id.EmailVerified
+		user.AvatarURL = id.Picture
+		if id.Role != "" {
+			user.Role = id.Role
+		}
+		if err := s.createUser(ctx, user); err != nil {
+			return nil, fmt.Errorf("failed to create user: %w", err)
+		}
+		s.logger.Info("New user created from OIDC",
+			zap.String("user_id", user.ID.String()),
+			zap.String("issuer", id.Issuer),
+		)
+	}
+
+	if err := s.identities.Create(ctx, domain.NewUserIdentity(user.ID, id.Issuer, id.Subject)); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
@@ -0,0 +1,411 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	netmail "net/mail"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+	"github.com/nanopaas/nanopaas/internal/services/mail"
+)
+
+var (
+	ErrPasswordLoginDisabled = errors.New("password sign-in is not enabled")
+	ErrSignupDisabled        = errors.New("signup is disabled")
+	ErrInvalidCredentials    = errors.New("invalid email or password")
+	ErrEmailNotVerified      = errors.New("email address is not verified")
+	ErrInvalidEmail          = errors.New("invalid email address")
+	ErrEmailTaken            = fmt.Errorf("email %w", domain.ErrConflict)
+	ErrInvalidUserToken      = errors.New("invalid or expired link")
+	ErrWeakPassword          = fmt.Errorf("password must be %d to %d characters long", minPasswordLength, maxPasswordLength)
+)
+
+const (
+	// Passwords are bcrypt-hashed, which reads at most 72 bytes
+	minPasswordLength = 8
+	maxPasswordLength = 72
+
+	verificationTokenTTL = 48 * time.Hour
+	resetTokenTTL        = time.Hour
+
+	// emailTimeout bounds sending one email in the background
+	emailTimeout = time.Minute
+)
+
+// UserTokenRepository interface for one-time email token persistence
+type UserTokenRepository interface {
+	Create(ctx context.Context, token *domain.UserToken) error
+	Consume(ctx context.Context, purpose domain.UserTokenPurpose, hash string) (*domain.UserToken, error)
+	DeleteForUser(ctx context.Context, userID uuid.UUID, purpose domain.UserTokenPurpose) error
+}
+
+// PasswordOptions configures email/password accounts
+type PasswordOptions struct {
+	Signup      bool   // anyone may create an account; otherwise only existing users set passwords
+	FrontendURL string // base of the links in verification and reset emails
+}
+
+// SetPasswordLogin enables email/password accounts. Verification and reset emails go
+// through sender.
+func (s *Service) SetPasswordLogin(tokens UserTokenRepository, sender mail.Sender, opts PasswordOptions) {
+	s.userTokens = tokens
+	s.mailer = sender
+	s.passwordOpts = opts
+}
+
+// PasswordLoginEnabled reports whether users can sign in, and sign up, with a password
+func (s *Service) PasswordLoginEnabled() (login, signup bool) {
+	return s.userTokens != nil, s.userTokens != nil && s.passwordOpts.Signup
+}
+
+// Signup creates an account that signs in with email and password, and emails a link
+// to verify the address. The account cannot sign in until it is verified.
+func (s *Service) Signup(ctx context.Context, email, name, password string) (*domain.User, error) {
+	if s.userTokens == nil {
+		return nil, ErrPasswordLoginDisabled
+	}
+	if !s.passwordOpts.Signup {
+		return nil, ErrSignupDisabled
+	}
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	if err := validatePassword(password); err != nil {
+		return nil, err
+	}
+	if _, err := s.userRepo.GetByEmail(ctx, email); err == nil {
+		return nil, ErrEmailTaken
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	if name = strings.TrimSpace(name); name == "" {
+		name = email[:strings.Index(email, "@")]
+	}
+	user := domain.NewUser(email, name)
+	user.SetPasswordHash(hash)
+	if err := s.createUser(ctx, user); err != nil {
+		if errors.Is(err, domain.ErrConflict) {
+			return nil, ErrEmailTaken
+		}
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	s.logger.Info("New user signed up with email", zap.String("user_id", user.ID.String()))
+	if err := s.sendVerification(ctx, user); err != nil {
+		s.logger.Warn("Failed to send verification email", zap.String("user_id", user.ID.String()), zap.Error(err))
+	}
+	return user, nil
+}
+
+// Login signs a user in with email and password
+func (s *Service) Login(ctx context.Context, email, password string) (*domain.User, *TokenPair, error) {
+	if s.userTokens == nil {
+		return nil, nil, ErrPasswordLoginDisabled
+	}
+
+	user, err := s.userRepo.GetByEmail(ctx, strings.TrimSpace(email))
+	if err != nil || !user.HasPassword() {
+		// Spend the time of a real comparison so unknown emails can't be told apart
+		bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(password))
+		return nil, nil, ErrInvalidCredentials
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		s.logger.Info("Failed password sign-in", zap.String("user_id", user.ID.String()))
+		s.recordLogin(ctx, user.ID, "", domain.LoginFailedPassword)
+		return nil, nil, ErrInvalidCredentials
+	}
+	if !user.EmailVerified {
+		return nil, nil, ErrEmailNotVerified
+	}
+
+	user.UpdateLastLogin()
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, nil, fmt.Errorf("failed to update user: %w", err)
+	}
+	s.logger.Info("User logged in with password", zap.String("user_id", user.ID.String()))
+
+	tokens, err := s.GenerateTokens(ctx, user)
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, tokens, nil
+}
+
+// VerifyEmail marks a user's email verified with a token from a verification email
+func (s *Service) VerifyEmail(ctx context.Context, token string) (*domain.User, error) {
+	user, err := s.consumeUserToken(ctx, domain.UserTokenEmailVerification, token)
+	if err != nil {
+		return nil, err
+	}
+	if !user.EmailVerified {
+		user.EmailVerified = true
+		if err := s.userRepo.Update(ctx, user); err != nil {
+			return nil, fmt.Errorf("failed to update user: %w", err)
+		}
+		s.logger.Info("Email verified", zap.String("user_id", user.ID.String()))
+	}
+	return user, nil
+}
+
+// ResendVerification emails a new verification link. Unknown and already verified
+// addresses are ignored, so callers can't tell which accounts exist.
+func (s *Service) ResendVerification(ctx context.Context, email string) error {
+	if s.userTokens == nil {
+		return ErrPasswordLoginDisabled
+	}
+	user, err := s.userRepo.GetByEmail(ctx, strings.TrimSpace(email))
+	if err != nil || user.EmailVerified || !user.HasPassword() {
+		return nil
+	}
+	return s.sendVerification(ctx, user)
+}
+
+// RequestPasswordReset emails a link to set a new password. GitHub-only accounts can use
+// it to add a password. Unknown addresses are ignored, so callers can't tell which
+// accounts exist.
+func (s *Service) RequestPasswordReset(ctx context.Context, email string) error {
+	if s.userTokens == nil {
+		return ErrPasswordLoginDisabled
+	}
+	user, err := s.userRepo.GetByEmail(ctx, strings.TrimSpace(email))
+	if err != nil {
+		return nil
+	}
+
+	token, err := s.issueUserToken(ctx, user, domain.UserTokenPasswordReset, resetTokenTTL)
+	if err != nil {
+		return err
+	}
+	s.sendEmail(mail.Message{
+		To:      user.Email,
+		Subject: "Reset your NanoPaaS password",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password of your NanoPaaS account. "+
+			"Open this link within an hour to choose a new one:\n\n%s\n\n"+
+			"If it wasn't you, ignore this email; your password stays the same.\n",
+			user.Name, s.emailLink("/auth/reset-password", token)),
+	})
+	s.logger.Info("Password reset requested", zap.String("user_id", user.ID.String()))
+	return nil
+}
+
+// ResetPassword sets a new password with a token from a reset email and signs the user
+// out of every session. The reset proves the user owns the address, so it also verifies it.
+func (s *Service) ResetPassword(ctx context.Context, token, password string) error {
+	if err := validatePassword(password); err != nil {
+		return err
+	}
+	user, err := s.consumeUserToken(ctx, domain.UserTokenPasswordReset, token)
+	if err != nil {
+		return err
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	user.SetPasswordHash(hash)
+	user.EmailVerified = true
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+	s.logger.Info("Password reset", zap.String("user_id", user.ID.String()))
+
+	if s.sessions != nil {
+		if _, err := s.RevokeAllSessions(ctx, user.ID); err != nil {
+			s.logger.Warn("Failed to revoke sessions after password reset", zap.String("user_id", user.ID.String()), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// ChangePassword sets a signed-in user's password. Users who already have one must
+// confirm it; GitHub-only users add a password this way. Every session but the one
+// making the change, named by sessionID, is signed out.
+func (s *Service) ChangePassword(ctx context.Context, user *domain.User, current, password, sessionID string) error {
+	if s.userTokens == nil {
+		return ErrPasswordLoginDisabled
+	}
+	if user.HasPassword() && bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(current)) != nil {
+		return ErrInvalidCredentials
+	}
+	if err := validatePassword(password); err != nil {
+		return err
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	user.SetPasswordHash(hash)
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+	s.logger.Info("Password changed", zap.String("user_id", user.ID.String()))
+
+	if s.sessions != nil {
+		if _, err := s.RevokeOtherSessions(ctx, user.ID, sessionID); err != nil {
+			s.logger.Warn("Failed to revoke sessions after password change", zap.String("user_id", user.ID.String()), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// linkGitHub links a GitHub account to the account with the same email, returning nil
+// when there is none. GitHub has verified the address, so an unverified account loses
+// its password: whoever set it may not own the address.
+func (s *Service) linkGitHub(ctx context.Context, githubID int64, login, email, avatarURL, token string) (*domain.User, error) {
+	if email == "" {
+		return nil, nil
+	}
+	user, err := s.userRepo.GetByEmail(ctx, email)
+	if err != nil || user.GitHubID != 0 {
+		return nil, nil
+	}
+
+	if !user.EmailVerified {
+		user.SetPasswordHash("")
+		user.EmailVerified = true
+	}
+	user.GitHubID = githubID
+	user.GitHubLogin = login
+	user.GitHubToken = token
+	if user.AvatarURL == "" {
+		user.AvatarURL = avatarURL
+	}
+	user.UpdateLastLogin()
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, fmt.Errorf("failed to link GitHub account: %w", err)
+	}
+
+	s.logger.Info("GitHub account linked to existing user",
+		zap.String("user_id", user.ID.String()),
+		zap.String("github_login", login),
+	)
+	return user, nil
+}
+
+// sendVerification emails a user a link to verify their address
+func (s *Service) sendVerification(ctx context.Context, user *domain.User) error {
+	token, err := s.issueUserToken(ctx, user, domain.UserTokenEmailVerification, verificationTokenTTL)
+	if err != nil {
+		return err
+	}
+	s.sendEmail(mail.Message{
+		To:      user.Email,
+		Subject: "Verify your email for NanoPaaS",
+		Body: fmt.Sprintf("Hi %s,\n\nOpen this link within 48 hours to verify your email address "+
+			"and finish setting up your NanoPaaS account:\n\n%s\n\n"+
+			"If you didn't sign up, ignore this email.\n",
+			user.Name, s.emailLink("/auth/verify-email", token)),
+	})
+	return nil
+}
+
+// issueUserToken creates a one-time token for a user, replacing earlier ones for the
+// same purpose
+func (s *Service) issueUserToken(ctx context.Context, user *domain.User, purpose domain.UserTokenPurpose, ttl time.Duration) (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	token := hex.EncodeToString(secret)
+
+	if err := s.userTokens.DeleteForUser(ctx, user.ID, purpose); err != nil {
+		return "", err
+	}
+	if err := s.userTokens.Create(ctx, domain.NewUserToken(user.ID, purpose, hashSecret(token), ttl)); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// consumeUserToken redeems a one-time token and returns its user
+func (s *Service) consumeUserToken(ctx context.Context, purpose domain.UserTokenPurpose, token string) (*domain.User, error) {
+	if s.userTokens == nil {
+		return nil, ErrPasswordLoginDisabled
+	}
+	record, err := s.userTokens.Consume(ctx, purpose, hashSecret(token))
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return nil, ErrInvalidUserToken
+		}
+		return nil, err
+	}
+	user, err := s.userRepo.GetByID(ctx, record.UserID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
+// emailLink returns the frontend link carrying a token
+func (s *Service) emailLink(path, token string) string {
+	return strings.TrimSuffix(s.passwordOpts.FrontendURL, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+// sendEmail sends a message in the background, so response times don't reveal whether
+// an address has an account
+func (s *Service) sendEmail(msg mail.Message) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), emailTimeout)
+		defer cancel()
+		if err := s.mailer.Send(ctx, msg); err != nil {
+			s.logger.Error("Failed to send email", zap.String("subject", msg.Subject), zap.Error(err))
+		}
+	}()
+}
+
+// normalizeEmail validates a bare email address and lowercases it
+func normalizeEmail(email string) (string, error) {
+	addr, err := netmail.ParseAddress(strings.TrimSpace(email))
+	if err != nil || addr.Name != "" || addr.Address != strings.TrimSpace(email) {
+		return "", ErrInvalidEmail
+	}
+	return strings.ToLower(addr.Address), nil
+}
+
+// validatePassword checks a new password's length
+func validatePassword(password string) error {
+	if len(password) < minPasswordLength || len(password) > maxPasswordLength {
+		return ErrWeakPassword
+	}
+	return nil
+}
+
+// hashPassword returns the bcrypt hash of a password
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     []byte
+)
+
+// dummyPasswordHash returns a hash no password matches, compared against when an email
+// has no password so failed sign-ins take the same time either way
+func dummyPasswordHash() []byte {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte(uuid.NewString()), bcrypt.DefaultCost)
+	})
+	return dummyHash
+}
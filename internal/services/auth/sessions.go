@@ -0,0 +1,225 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// ErrSessionsDisabled is returned when no session store is configured
+var ErrSessionsDisabled = errors.New("session tracking is not enabled")
+
+// SessionStore tracks sign-in sessions and blacklists revoked token and session IDs
+type SessionStore interface {
+	SaveSession(ctx context.Context, session *domain.Session) error
+	GetSession(ctx context.Context, id string) (*domain.Session, error)
+	ListSessions(ctx context.Context, userID uuid.UUID) ([]*domain.Session, error)
+	DeleteSession(ctx context.Context, userID uuid.UUID, id string) error
+	Revoke(ctx context.Context, id string, ttl time.Duration) error
+	IsRevoked(ctx context.Context, ids ...string) (bool, error)
+}
+
+// ClientInfo describes the client signing in and how, recorded on its session and in
+// the sign-in audit trail
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+	Provider  domain.LoginProvider
+}
+
+type clientInfoKey struct{}
+
+// WithClientInfo attaches the signing-in client to ctx for the session GenerateTokens starts
+func WithClientInfo(ctx context.Context, client ClientInfo) context.Context {
+	return context.WithValue(ctx, clientInfoKey{}, client)
+}
+
+// SetSessionStore enables session tracking, so logout and revocation invalidate tokens
+// before they expire
+func (s *Service) SetSessionStore(store SessionStore) {
+	s.sessions = store
+}
+
+// ListSessions returns a user's active sessions, most recently refreshed first
+func (s *Service) ListSessions(ctx context.Context, userID uuid.UUID) ([]*domain.Session, error) {
+	if s.sessions == nil {
+		return nil, ErrSessionsDisabled
+	}
+	sessions, err := s.sessions.ListSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].RefreshedAt.After(sessions[j].RefreshedAt)
+	})
+	return sessions, nil
+}
+
+// RevokeSession signs a user out of one session, invalidating all of its tokens
+func (s *Service) RevokeSession(ctx context.Context, userID uuid.UUID, sessionID string) error {
+	if s.sessions == nil {
+		return ErrSessionsDisabled
+	}
+	session, err := s.sessions.GetSession(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if session == nil || session.UserID != userID {
+		return fmt.Errorf("session %w", domain.ErrNotFound)
+	}
+	return s.revokeSession(ctx, userID, sessionID)
+}
+
+// RevokeAllSessions signs a user out everywhere and returns how many sessions ended
+func (s *Service) RevokeAllSessions(ctx context.Context, userID uuid.UUID) (int, error) {
+	revoked, err := s.revokeSessionsExcept(ctx, userID, "")
+	if err != nil {
+		return 0, err
+	}
+
+	s.logger.Info("All sessions revoked",
+		zap.String("user_id", userID.String()),
+		zap.Int("sessions", revoked),
+	)
+	return revoked, nil
+}
+
+// RevokeOtherSessions signs a user out of every session but keep and returns how many
+// sessions ended
+func (s *Service) RevokeOtherSessions(ctx context.Context, userID uuid.UUID, keep string) (int, error) {
+	revoked, err := s.revokeSessionsExcept(ctx, userID, keep)
+	if err != nil {
+		return 0, err
+	}
+
+	s.logger.Info("Other sessions revoked",
+		zap.String("user_id", userID.String()),
+		zap.String("kept_session_id", keep),
+		zap.Int("sessions", revoked),
+	)
+	return revoked, nil
+}
+
+// revokeSessionsExcept revokes a user's sessions other than keep, which may be ""
+func (s *Service) revokeSessionsExcept(ctx context.Context, userID uuid.UUID, keep string) (int, error) {
+	if s.sessions == nil {
+		return 0, ErrSessionsDisabled
+	}
+	sessions, err := s.sessions.ListSessions(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	revoked := 0
+	for _, session := range sessions {
+		if session.ID == keep {
+			continue
+		}
+		if err := s.revokeSession(ctx, userID, session.ID); err != nil {
+			return 0, err
+		}
+		revoked++
+	}
+	return revoked, nil
+}
+
+// Logout ends the session of an access or refresh token. Tokens issued without a
+// session are revoked individually.
+func (s *Service) Logout(ctx context.Context, token string) error {
+	if s.sessions == nil {
+		return nil
+	}
+	claims, err := s.ValidateToken(token)
+	if err != nil {
+		return err
+	}
+	if claims.SessionID == "" {
+		s.revokeToken(ctx, claims)
+		return nil
+	}
+	return s.revokeSession(ctx, claims.UserID, claims.SessionID)
+}
+
+// SessionIDFromToken returns the session a valid token belongs to
+func (s *Service) SessionIDFromToken(token string) string {
+	claims, err := s.ValidateToken(token)
+	if err != nil {
+		return ""
+	}
+	return claims.SessionID
+}
+
+// revokeSession blacklists a session ID for as long as any of its tokens can live
+func (s *Service) revokeSession(ctx context.Context, userID uuid.UUID, sessionID string) error {
+	if err := s.sessions.Revoke(ctx, sessionID, max(s.config.JWTExpiry, s.config.JWTRefreshExpiry)); err != nil {
+		return err
+	}
+	if err := s.sessions.DeleteSession(ctx, userID, sessionID); err != nil {
+		return err
+	}
+	s.logger.Info("Session revoked",
+		zap.String("user_id", userID.String()),
+		zap.String("session_id", sessionID),
+	)
+	return nil
+}
+
+// revokeToken blacklists a single token until it expires
+func (s *Service) revokeToken(ctx context.Context, claims *Claims) {
+	if s.sessions == nil || claims.ID == "" || claims.ExpiresAt == nil {
+		return
+	}
+	if err := s.sessions.Revoke(ctx, claims.ID, time.Until(claims.ExpiresAt.Time)); err != nil {
+		s.logger.Warn("Failed to revoke token", zap.String("user_id", claims.UserID.String()), zap.Error(err))
+	}
+}
+
+// isRevoked checks a token and its session against the blacklist. When the store cannot
+// be reached, tokens are accepted until it is back.
+func (s *Service) isRevoked(ctx context.Context, claims *Claims) bool {
+	if s.sessions == nil {
+		return false
+	}
+	revoked, err := s.sessions.IsRevoked(ctx, claims.ID, claims.SessionID)
+	if err != nil {
+		s.logger.Warn("Failed to check token revocation", zap.Error(err))
+		return false
+	}
+	return revoked
+}
+
+// refreshSession returns the session a refresh token belongs to, starting a new one for
+// tokens issued before sessions were tracked
+func (s *Service) refreshSession(ctx context.Context, claims *Claims) *domain.Session {
+	if s.sessions != nil && claims.SessionID != "" {
+		session, err := s.sessions.GetSession(ctx, claims.SessionID)
+		if err != nil {
+			s.logger.Warn("Failed to load session", zap.String("session_id", claims.SessionID), zap.Error(err))
+		}
+		if session != nil {
+			return session
+		}
+	}
+	id := claims.SessionID
+	if id == "" {
+		id = uuid.NewString()
+	}
+	return &domain.Session{ID: id, UserID: claims.UserID, CreatedAt: time.Now().UTC()}
+}
+
+// saveSession records a session, logging rather than failing sign-in since the tokens
+// stay valid without it
+func (s *Service) saveSession(ctx context.Context, session *domain.Session) {
+	if s.sessions == nil {
+		return
+	}
+	if err := s.sessions.SaveSession(ctx, session); err != nil {
+		s.logger.Warn("Failed to save session", zap.String("session_id", session.ID), zap.Error(err))
+	}
+}
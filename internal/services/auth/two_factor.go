@@ -0,0 +1,305 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+	"github.com/nanopaas/nanopaas/internal/services/totp"
+)
+
+const (
+	// twoFactorChallengeTTL is how long a user has to enter their code after signing in
+	twoFactorChallengeTTL = 5 * time.Minute
+
+	// recoveryCodeCount recovery codes are issued at a time, each recoveryCodeLength
+	// base32 characters long
+	recoveryCodeCount  = 10
+	recoveryCodeLength = 10
+)
+
+var (
+	ErrTwoFactorEnabled            = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled         = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorNotEnrolled        = errors.New("start two-factor enrollment first")
+	ErrTwoFactorPolicy             = errors.New("two-factor authentication is required for every user")
+	ErrTwoFactorEnrollmentRequired = errors.New("set up two-factor authentication to continue")
+	ErrInvalidTwoFactorCode        = errors.New("invalid two-factor code")
+)
+
+// TwoFactorChallenge is returned instead of tokens when a user signs in with two-factor
+// authentication enabled. Token identifies the sign-in to VerifyTwoFactor.
+type TwoFactorChallenge struct {
+	Token     string
+	ExpiresAt time.Time
+}
+
+func (c *TwoFactorChallenge) Error() string {
+	return "two-factor authentication required"
+}
+
+// SetTwoFactorPolicy sets the issuer name authenticator apps show, and whether every
+// user must enroll before they can use the API
+func (s *Service) SetTwoFactorPolicy(issuer string, required bool) {
+	s.twoFactorIssuer = issuer
+	s.twoFactorRequired = required
+}
+
+// TwoFactorRequired reports whether every user must enroll in two-factor authentication
+func (s *Service) TwoFactorRequired() bool {
+	return s.twoFactorRequired
+}
+
+// NeedsTwoFactorEnrollment reports whether the policy blocks the user until they enroll
+func (s *Service) NeedsTwoFactorEnrollment(user *domain.User) bool {
+	return s.twoFactorRequired && !user.TwoFactorEnabled
+}
+
+// StartTwoFactorEnrollment gives the user a new TOTP secret and the otpauth:// URL to
+// show as a QR code. The secret takes effect once ConfirmTwoFactor checks a code.
+func (s *Service) StartTwoFactorEnrollment(ctx context.Context, user *domain.User) (secret, url string, err error) {
+	if user.TwoFactorEnabled {
+		return "", "", ErrTwoFactorEnabled
+	}
+	secret, err = totp.GenerateSecret()
+	if err != nil {
+		return "", "", err
+	}
+	user.TOTPSecret = secret
+	user.UpdatedAt = time.Now().UTC()
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return "", "", err
+	}
+
+	issuer := s.twoFactorIssuer
+	if issuer == "" {
+		issuer = "NanoPaaS"
+	}
+	return secret, totp.URL(issuer, user.Email, secret), nil
+}
+
+// ConfirmTwoFactor enables two-factor authentication once the user proves their app
+// produces codes, and returns their recovery codes. They are shown this once.
+func (s *Service) ConfirmTwoFactor(ctx context.Context, user *domain.User, code string) ([]string, error) {
+	if user.TwoFactorEnabled {
+		return nil, ErrTwoFactorEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+	if err := s.checkTOTP(ctx, user, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	user.TwoFactorEnabled = true
+	user.RecoveryCodes = hashes
+	user.UpdatedAt = time.Now().UTC()
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+	s.logger.Info("Two-factor authentication enabled", zap.String("user_id", user.ID.String()))
+	return codes, nil
+}
+
+// DisableTwoFactor turns two-factor authentication off with a current code
+func (s *Service) DisableTwoFactor(ctx context.Context, user *domain.User, code string) error {
+	if !user.TwoFactorEnabled {
+		return ErrTwoFactorNotEnabled
+	}
+	if s.twoFactorRequired {
+		return ErrTwoFactorPolicy
+	}
+	if err := s.checkSecondFactor(ctx, user, code); err != nil {
+		return err
+	}
+
+	user.DisableTwoFactor()
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return err
+	}
+	s.logger.Info("Two-factor authentication disabled", zap.String("user_id", user.ID.String()))
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes with new ones
+func (s *Service) RegenerateRecoveryCodes(ctx context.Context, user *domain.User, code string) ([]string, error) {
+	if !user.TwoFactorEnabled {
+		return nil, ErrTwoFactorNotEnabled
+	}
+	if err := s.checkSecondFactor(ctx, user, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	user.RecoveryCodes = hashes
+	user.UpdatedAt = time.Now().UTC()
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+	s.logger.Info("Recovery codes regenerated", zap.String("user_id", user.ID.String()))
+	return codes, nil
+}
+
+// ResetTwoFactor turns off a user's two-factor authentication for an admin, e.g. after
+// the user lost their device and recovery codes. The user is signed out everywhere.
+func (s *Service) ResetTwoFactor(ctx context.Context, actor *domain.User, id uuid.UUID) (*domain.User, error) {
+	user, err := s.userRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TwoFactorEnabled && user.TOTPSecret == "" {
+		return user, nil
+	}
+
+	user.DisableTwoFactor()
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+	s.logger.Info("Two-factor authentication reset",
+		zap.String("user_id", user.ID.String()),
+		zap.String("by", actor.ID.String()),
+	)
+	s.endSessions(ctx, user.ID)
+	return user, nil
+}
+
+// VerifyTwoFactor finishes a sign-in answered with a TOTP or recovery code, starting
+// the session GenerateTokens held back
+func (s *Service) VerifyTwoFactor(ctx context.Context, challenge, code string) (*domain.User, *TokenPair, error) {
+	claims, err := s.ValidateToken(challenge)
+	if err != nil {
+		return nil, nil, err
+	}
+	if claims.TokenType != "2fa" {
+		return nil, nil, ErrInvalidToken
+	}
+	if s.isRevoked(ctx, claims) {
+		return nil, nil, ErrRevokedToken
+	}
+
+	user, err := s.userRepo.GetByID(ctx, claims.UserID)
+	if err != nil {
+		return nil, nil, ErrUserNotFound
+	}
+	if !user.IsActive() {
+		return nil, nil, ErrUserDeactivated
+	}
+	if !user.TwoFactorEnabled {
+		// Reset by an admin since the challenge was issued; sign in again
+		return nil, nil, ErrInvalidToken
+	}
+	client, _ := ctx.Value(clientInfoKey{}).(ClientInfo)
+	client.Provider = domain.LoginProvider(claims.Provider)
+	ctx = WithClientInfo(ctx, client)
+	if err := s.checkSecondFactor(ctx, user, code); err != nil {
+		if errors.Is(err, ErrInvalidTwoFactorCode) {
+			s.recordLogin(ctx, user.ID, "", domain.LoginFailedTwoFactor)
+		}
+		return nil, nil, err
+	}
+
+	s.revokeToken(ctx, claims)
+	tokens, err := s.startSession(ctx, user)
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, tokens, nil
+}
+
+// twoFactorChallenge issues the short-lived token a sign-in is finished with,
+// remembering the provider the user signed in with for the audit trail
+func (s *Service) twoFactorChallenge(ctx context.Context, user *domain.User) error {
+	client, _ := ctx.Value(clientInfoKey{}).(ClientInfo)
+	now := time.Now()
+	expiresAt := now.Add(twoFactorChallengeTTL)
+	token, err := s.sign(&Claims{
+		UserID:    user.ID,
+		TokenType: "2fa",
+		Provider:  string(client.Provider),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			Issuer:    "nanopaas",
+			Subject:   user.ID.String(),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return &TwoFactorChallenge{Token: token, ExpiresAt: expiresAt.UTC()}
+}
+
+// checkSecondFactor accepts a TOTP code or an unused recovery code, using it up
+func (s *Service) checkSecondFactor(ctx context.Context, user *domain.User, code string) error {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) == totp.Digits {
+		return s.checkTOTP(ctx, user, code)
+	}
+
+	normalized := strings.ToLower(strings.ReplaceAll(code, "-", ""))
+	if len(normalized) != recoveryCodeLength {
+		return ErrInvalidTwoFactorCode
+	}
+	ok, err := s.userRepo.ConsumeRecoveryCode(ctx, user.ID, hashSecret(normalized))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		s.logger.Info("Invalid recovery code", zap.String("user_id", user.ID.String()))
+		return ErrInvalidTwoFactorCode
+	}
+	s.logger.Info("Recovery code used", zap.String("user_id", user.ID.String()))
+	return nil
+}
+
+// checkTOTP accepts a code from the user's authenticator app that hasn't been used yet
+func (s *Service) checkTOTP(ctx context.Context, user *domain.User, code string) error {
+	step, ok := totp.Validate(user.TOTPSecret, code, time.Now())
+	if !ok {
+		s.logger.Info("Invalid two-factor code", zap.String("user_id", user.ID.String()))
+		return ErrInvalidTwoFactorCode
+	}
+	fresh, err := s.userRepo.AdvanceTOTPStep(ctx, user.ID, step)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ErrInvalidTwoFactorCode
+	}
+	user.TOTPLastStep = step
+	return nil
+}
+
+// generateRecoveryCodes returns new recovery codes formatted for display, and the
+// hashes stored in their place. The TOTP secret is stored beside them, so a slower hash
+// would not protect the account any better if the database leaked.
+func generateRecoveryCodes() (codes, hashes []string, err error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, recoveryCodeLength*5/8)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(encoding.EncodeToString(b))
+		codes = append(codes, code[:recoveryCodeLength/2]+"-"+code[recoveryCodeLength/2:])
+		hashes = append(hashes, hashSecret(code))
+	}
+	return codes, hashes, nil
+}
@@ -0,0 +1,226 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+var (
+	ErrInvalidRole     = errors.New("role must be admin, member or viewer")
+	ErrLastAdmin       = errors.New("the last active admin cannot be demoted, deactivated or deleted")
+	ErrSelfAdminister  = errors.New("admins cannot deactivate or delete their own account")
+	ErrInvalidNewOwner = errors.New("apps must be reassigned to another, active user")
+)
+
+// SetBootstrapAdmins promotes users to admin without an existing admin's help. Users
+// whose verified email is in emails become admins when they sign in; with firstUser,
+// the first account created on an empty install does too.
+func (s *Service) SetBootstrapAdmins(emails []string, firstUser bool) {
+	s.adminEmails = nil
+	for _, email := range emails {
+		if email = strings.ToLower(strings.TrimSpace(email)); email != "" {
+			s.adminEmails = append(s.adminEmails, email)
+		}
+	}
+	s.firstUserAdmin = firstUser
+}
+
+// createUser stores a new user, making them admin when they are the first
+func (s *Service) createUser(ctx context.Context, user *domain.User) error {
+	if s.firstUserAdmin {
+		count, err := s.userRepo.Count(ctx)
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			user.Role = domain.UserRoleAdmin
+			s.logger.Info("First user is made admin", zap.String("user_id", user.ID.String()))
+		}
+	}
+	return s.userRepo.Create(ctx, user)
+}
+
+// promoteConfiguredAdmin makes a signing-in user admin when their verified email is
+// one of the configured admin emails
+func (s *Service) promoteConfiguredAdmin(ctx context.Context, user *domain.User) error {
+	if user.IsAdmin() || !user.EmailVerified {
+		return nil
+	}
+	email := strings.ToLower(user.Email)
+	for _, admin := range s.adminEmails {
+		if admin != email {
+			continue
+		}
+		user.Role = domain.UserRoleAdmin
+		if err := s.userRepo.Update(ctx, user); err != nil {
+			return fmt.Errorf("failed to promote user: %w", err)
+		}
+		s.logger.Info("User promoted to admin by configured email", zap.String("user_id", user.ID.String()))
+		return nil
+	}
+	return nil
+}
+
+// ListUsers returns a page of users and the number of users matching the query
+func (s *Service) ListUsers(ctx context.Context, q domain.UserQuery) ([]*domain.User, int, error) {
+	return s.userRepo.Search(ctx, q)
+}
+
+// GetUser returns a user by ID
+func (s *Service) GetUser(ctx context.Context, id uuid.UUID) (*domain.User, error) {
+	return s.userRepo.GetByID(ctx, id)
+}
+
+// SetUserRole changes a user's role. Their signed-in sessions pick the new role up
+// with their next request.
+func (s *Service) SetUserRole(ctx context.Context, actor *domain.User, id uuid.UUID, role domain.UserRole) (*domain.User, error) {
+	if !role.IsValid() {
+		return nil, ErrInvalidRole
+	}
+	user, err := s.userRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if user.Role == role {
+		return user, nil
+	}
+	if user.IsAdmin() && user.IsActive() {
+		if err := s.ensureAnotherAdmin(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	from := user.Role
+	user.Role = role
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+	s.logger.Info("User role changed",
+		zap.String("user_id", user.ID.String()),
+		zap.String("from", string(from)),
+		zap.String("to", string(role)),
+		zap.String("by", actor.ID.String()),
+	)
+	return user, nil
+}
+
+// DeactivateUser stops a user from signing in and ends their sessions. Their apps keep
+// running.
+func (s *Service) DeactivateUser(ctx context.Context, actor *domain.User, id uuid.UUID) (*domain.User, error) {
+	if actor.ID == id {
+		return nil, ErrSelfAdminister
+	}
+	user, err := s.userRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !user.IsActive() {
+		return user, nil
+	}
+	if user.IsAdmin() {
+		if err := s.ensureAnotherAdmin(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	user.Deactivate()
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+	s.logger.Info("User deactivated", zap.String("user_id", user.ID.String()), zap.String("by", actor.ID.String()))
+	s.endSessions(ctx, user.ID)
+	return user, nil
+}
+
+// ReactivateUser lets a deactivated user sign in again
+func (s *Service) ReactivateUser(ctx context.Context, actor *domain.User, id uuid.UUID) (*domain.User, error) {
+	user, err := s.userRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if user.IsActive() {
+		return user, nil
+	}
+
+	user.Reactivate()
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+	s.logger.Info("User reactivated", zap.String("user_id", user.ID.String()), zap.String("by", actor.ID.String()))
+	return user, nil
+}
+
+// DeleteUser deletes a user, handing their apps, projects and teams to newOwnerID, or
+// to the admin deleting them when it is uuid.Nil
+func (s *Service) DeleteUser(ctx context.Context, actor *domain.User, id, newOwnerID uuid.UUID) (*domain.User, error) {
+	if actor.ID == id {
+		return nil, ErrSelfAdminister
+	}
+	if newOwnerID == uuid.Nil {
+		newOwnerID = actor.ID
+	}
+	if newOwnerID == id {
+		return nil, ErrInvalidNewOwner
+	}
+
+	user, err := s.userRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	newOwner, err := s.userRepo.GetByID(ctx, newOwnerID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, ErrInvalidNewOwner
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !newOwner.IsActive() {
+		return nil, ErrInvalidNewOwner
+	}
+	if user.IsAdmin() && user.IsActive() {
+		if err := s.ensureAnotherAdmin(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	// End sessions first; once the user is gone their tokens fail to resolve anyway
+	s.endSessions(ctx, user.ID)
+	if err := s.userRepo.DeleteAndReassign(ctx, user.ID, newOwner.ID); err != nil {
+		return nil, err
+	}
+	s.logger.Info("User deleted",
+		zap.String("user_id", user.ID.String()),
+		zap.String("new_owner_id", newOwner.ID.String()),
+		zap.String("by", actor.ID.String()),
+	)
+	return newOwner, nil
+}
+
+// ensureAnotherAdmin fails when only one active admin is left
+func (s *Service) ensureAnotherAdmin(ctx context.Context) error {
+	admins, err := s.userRepo.CountActiveAdmins(ctx)
+	if err != nil {
+		return err
+	}
+	if admins <= 1 {
+		return ErrLastAdmin
+	}
+	return nil
+}
+
+// endSessions revokes a user's sessions when sessions are tracked
+func (s *Service) endSessions(ctx context.Context, userID uuid.UUID) {
+	if s.sessions == nil {
+		return
+	}
+	if _, err := s.RevokeAllSessions(ctx, userID); err != nil {
+		s.logger.Warn("Failed to revoke sessions", zap.String("user_id", userID.String()), zap.Error(err))
+	}
+}
@@ -0,0 +1,128 @@
+package builder
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// BuildStore keeps builds after the builder has forgotten them
+type BuildStore interface {
+	Save(ctx context.Context, build *domain.Build) error
+}
+
+// BuildLogStore keeps the full logs of builds
+type BuildLogStore interface {
+	Append(ctx context.Context, buildID uuid.UUID, lines []domain.BuildLogLine) error
+}
+
+// Build log lines written to the archive at once, and how long a write may take
+const (
+	archiveChunkLines = 500
+	archiveTimeout    = 10 * time.Second
+)
+
+// SetArchive sets where builds and their full logs are kept. Each build is saved when
+// it starts and when it finishes, and its log is written in chunks as it runs, so logs
+// outlive the builder's in-memory tails. Call before submitting builds.
+func (b *Builder) SetArchive(builds BuildStore, logs BuildLogStore) {
+	b.archiveBuilds = builds
+	b.archiveLogs = logs
+}
+
+// logArchiver writes a running build's log to the archive. A nil archiver archives
+// nothing. Archive errors are logged and never fail the build; after one, the rest of
+// the log is dropped rather than stored with a gap.
+type logArchiver struct {
+	builds  BuildStore
+	logs    BuildLogStore
+	logger  *zap.Logger
+	buildID uuid.UUID
+
+	mu      sync.Mutex
+	lines   int // lines written so far, numbering the next one
+	pending []domain.BuildLogLine
+	failed  bool
+}
+
+// startArchive saves a starting build and returns the archiver of its log, or nil when
+// no archive is set
+func (b *Builder) startArchive(build *domain.Build) *logArchiver {
+	if b.archiveBuilds == nil || b.archiveLogs == nil {
+		return nil
+	}
+	a := &logArchiver{
+		builds:  b.archiveBuilds,
+		logs:    b.archiveLogs,
+		logger:  b.logger.With(zap.String("build_id", build.ID.String())),
+		buildID: build.ID,
+	}
+	if err := a.save(build); err != nil {
+		a.failed = true // its log lines would have no build to belong to
+	}
+	return a
+}
+
+// write adds a line to the log, writing a chunk to the archive once enough are pending
+func (a *logArchiver) write(content string) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.failed {
+		return
+	}
+	a.lines++
+	a.pending = append(a.pending, domain.BuildLogLine{
+		Line:      a.lines,
+		Content:   content,
+		Timestamp: time.Now().UTC(),
+	})
+	if len(a.pending) >= archiveChunkLines {
+		a.flush()
+	}
+}
+
+// finish writes the rest of the log and saves the build's outcome
+func (a *logArchiver) finish(build *domain.Build) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	if !a.failed {
+		a.flush()
+	}
+	a.mu.Unlock()
+	a.save(build)
+}
+
+// flush writes the pending lines; a.mu must be held
+func (a *logArchiver) flush() {
+	if len(a.pending) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), archiveTimeout)
+	defer cancel()
+	if err := a.logs.Append(ctx, a.buildID, a.pending); err != nil {
+		a.logger.Error("Failed to archive build log, dropping the rest", zap.Error(err))
+		a.failed = true
+	}
+	a.pending = nil
+}
+
+// save stores the build as it is now
+func (a *logArchiver) save(build *domain.Build) error {
+	ctx, cancel := context.WithTimeout(context.Background(), archiveTimeout)
+	defer cancel()
+	err := a.builds.Save(ctx, build)
+	if err != nil {
+		a.logger.Error("Failed to archive build", zap.Error(err))
+	}
+	return err
+}
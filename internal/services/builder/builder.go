@@ -26,6 +26,7 @@ type BuilderConfig struct {
 	WorkDir         string
 	MaxBuildTime    time.Duration
 	CleanupOnFinish bool
+	StripANSI       bool // Remove color codes from build output
 }
 
 // DefaultBuilderConfig returns default configuration
@@ -40,13 +41,21 @@ func DefaultBuilderConfig() BuilderConfig {
 
 // BuildJob represents a build job in the queue
 type BuildJob struct {
-	Build       *domain.Build
-	AppSlug     string
-	SourceData  io.Reader // For gzip source
-	SourceURL   string    // For git/url source
-	ResultChan  chan BuildResult
-	LogCallback func(string)
-	OnSuccess   func(imageID, imageTag string) // Called when build succeeds
+	Build          *domain.Build
+	AppSlug        string
+	SourceData     io.Reader // For gzip source
+	SourceURL      string    // For git/url source
+	ResultChan     chan BuildResult
+	LogCallback    docker.BuildLogFunc
+	OnPullProgress docker.PullProgressFunc        // Receives base image pull progress; pull output goes to the log when unset
+	OnSuccess      func(imageID, imageTag string) // Called when build succeeds
+	OnFailure      func(err error)                // Called when build fails
+
+	// Cloned instead of SourceURL when set, so credentials stay out of build logs
+	AuthenticatedURL string
+
+	history *buildLogRecorder
+	archive *logArchiver
 }
 
 // BuildResult holds the result of a build
@@ -61,7 +70,7 @@ type BuildResult struct {
 // Builder is the main build service that manages build workers
 type Builder struct {
 	config       BuilderConfig
-	dockerClient *docker.Client
+	dockerClient docker.ContainerRuntime
 	logger       *zap.Logger
 
 	jobQueue chan *BuildJob
@@ -72,10 +81,51 @@ type Builder struct {
 	// Active builds tracking
 	activeBuilds   map[uuid.UUID]*BuildJob
 	activeBuildsMu sync.RWMutex
+
+	// Completed build durations per app, used for build-minute accounting
+	buildTimes   map[uuid.UUID][]buildTime
+	buildTimesMu sync.RWMutex
+
+	// Log tails of each app's most recent builds, kept for diagnostic bundles
+	buildLogs   map[uuid.UUID][]*buildLogRecorder
+	buildLogsMu sync.RWMutex
+
+	// Each app's most recently submitted builds, oldest first, for build listings
+	appBuilds   map[uuid.UUID][]*domain.Build
+	appBuildsMu sync.RWMutex
+
+	// Provisions build workspaces; plain directories under WorkDir unless replaced
+	workspaces       WorkspaceDriver
+	workspaceMetrics *workspaceMetrics
+
+	// Called with every build once it succeeds or fails
+	onBuildFinished func(build *domain.Build)
+
+	// Adds credentials to git clone URLs, set by SetCloneAuthenticator
+	cloneAuth CloneAuthenticator
+
+	// Keep builds and their full logs, set by SetArchive
+	archiveBuilds BuildStore
+	archiveLogs   BuildLogStore
 }
 
+// CloneAuthenticator adds credentials to the URL of a git repository before it is
+// cloned, returning URLs it has none for unchanged
+type CloneAuthenticator interface {
+	AuthenticatedCloneURL(ctx context.Context, url string) (string, error)
+}
+
+// buildTime records when a build finished and how long it ran
+type buildTime struct {
+	completedAt time.Time
+	duration    time.Duration
+}
+
+// buildTimeRetention bounds how long completed build durations are kept
+const buildTimeRetention = 31 * 24 * time.Hour
+
 // NewBuilder creates a new Builder service
-func NewBuilder(config BuilderConfig, dockerClient *docker.Client, logger *zap.Logger) *Builder {
+func NewBuilder(config BuilderConfig, dockerClient docker.ContainerRuntime, logger *zap.Logger) *Builder {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	b := &Builder{
@@ -86,6 +136,12 @@ func NewBuilder(config BuilderConfig, dockerClient *docker.Client, logger *zap.L
 		ctx:          ctx,
 		cancel:       cancel,
 		activeBuilds: make(map[uuid.UUID]*BuildJob),
+		buildTimes:   make(map[uuid.UUID][]buildTime),
+		buildLogs:    make(map[uuid.UUID][]*buildLogRecorder),
+		appBuilds:    make(map[uuid.UUID][]*domain.Build),
+
+		workspaces:       &dirDriver{root: config.WorkDir},
+		workspaceMetrics: &workspaceMetrics{metrics: WorkspaceMetrics{Driver: WorkspaceDriverDir}},
 	}
 
 	// Start workers
@@ -102,6 +158,25 @@ func NewBuilder(config BuilderConfig, dockerClient *docker.Client, logger *zap.L
 	return b
 }
 
+// SetWorkspaceDriver replaces the build workspace driver. Call before submitting builds.
+func (b *Builder) SetWorkspaceDriver(driver WorkspaceDriver) {
+	b.workspaces = driver
+	b.workspaceMetrics = &workspaceMetrics{metrics: WorkspaceMetrics{Driver: driver.Name()}}
+	b.logger.Info("Build workspace driver set", zap.String("driver", driver.Name()))
+}
+
+// SetBuildFinishedHandler sets a function called with every build once it succeeds or
+// fails, alongside the job's own callbacks. Call before submitting builds.
+func (b *Builder) SetBuildFinishedHandler(fn func(build *domain.Build)) {
+	b.onBuildFinished = fn
+}
+
+// SetCloneAuthenticator sets what adds credentials to git clone URLs, so private
+// repositories can be built. Call before submitting builds.
+func (b *Builder) SetCloneAuthenticator(auth CloneAuthenticator) {
+	b.cloneAuth = auth
+}
+
 // Stop gracefully stops the builder service, waiting for in-progress builds to complete
 func (b *Builder) Stop() {
 	b.logger.Info("Stopping builder service...")
@@ -128,6 +203,7 @@ func (b *Builder) SubmitBuild(job *BuildJob) error {
 	// Submit to queue
 	select {
 	case b.jobQueue <- job:
+		b.recordAppBuild(job.Build)
 		b.logger.Info("Build job submitted",
 			zap.String("build_id", job.Build.ID.String()),
 			zap.String("app", job.AppSlug),
@@ -202,10 +278,22 @@ func (b *Builder) processJob(workerID int, job *BuildJob) {
 	ctx, cancel := context.WithTimeout(b.ctx, b.config.MaxBuildTime)
 	defer cancel()
 
-	// Log callback helper
-	log := func(msg string) {
+	// Retain the log tail for diagnostics and archive the full log alongside any live
+	// subscriber
+	job.history = b.startBuildLog(build)
+	job.archive = b.startArchive(build)
+	logCallback := func(line docker.BuildLogLine) {
+		job.history.write(line.Content)
+		job.archive.write(line.Content)
 		if job.LogCallback != nil {
-			job.LogCallback(msg)
+			job.LogCallback(line)
+		}
+	}
+
+	// Log callback helper for NanoPaaS's own messages
+	log := func(msg string) {
+		for _, line := range strings.Split(strings.TrimRight(msg, "\n"), "\n") {
+			logCallback(docker.BuildLogLine{Content: line})
 		}
 		b.logger.Debug("Build log", zap.String("build_id", build.ID.String()), zap.String("msg", msg))
 	}
@@ -213,14 +301,15 @@ func (b *Builder) processJob(workerID int, job *BuildJob) {
 	log(fmt.Sprintf("[NanoPaaS] Build %s started\n", build.ID.String()[:8]))
 
 	// Prepare build directory
-	buildDir, err := b.prepareBuildDir(job, log)
+	workspace, err := b.prepareBuildDir(ctx, job, log)
 	if err != nil {
 		b.finishBuild(job, "", "", err, time.Since(startTime))
 		return
 	}
+	buildDir := workspace.Path
 
 	if b.config.CleanupOnFinish {
-		defer os.RemoveAll(buildDir)
+		defer b.releaseWorkspace(workspace)
 	}
 
 	// Detect Dockerfile
@@ -235,7 +324,11 @@ func (b *Builder) processJob(workerID int, job *BuildJob) {
 	log(fmt.Sprintf("[NanoPaaS] Building image: %s\n", imageTag))
 
 	// Build the image
-	imageID, err := b.buildImage(ctx, buildDir, dockerfilePath, imageTag, job.LogCallback)
+	labels := map[string]string{
+		"nanopaas.app.slug": job.AppSlug,
+		"nanopaas.build.id": build.ID.String(),
+	}
+	imageID, err := b.buildImage(ctx, buildDir, dockerfilePath, imageTag, labels, logCallback, job.OnPullProgress)
 	if err != nil {
 		b.finishBuild(job, "", "", err, time.Since(startTime))
 		return
@@ -245,38 +338,59 @@ func (b *Builder) processJob(workerID int, job *BuildJob) {
 	b.finishBuild(job, imageID, imageTag, nil, time.Since(startTime))
 }
 
-// prepareBuildDir prepares the build directory from the source
-func (b *Builder) prepareBuildDir(job *BuildJob, log func(string)) (string, error) {
-	// Create unique build directory
-	buildDir := filepath.Join(b.config.WorkDir, "nanopaas-build-"+job.Build.ID.String()[:8])
-	if err := os.MkdirAll(buildDir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create build directory: %w", err)
+// prepareBuildDir provisions a workspace and fills it from the source
+func (b *Builder) prepareBuildDir(ctx context.Context, job *BuildJob, log func(string)) (*Workspace, error) {
+	// Create unique build workspace
+	workspace, err := b.createWorkspace(ctx, "nanopaas-build-"+job.Build.ID.String()[:8])
+	if err != nil {
+		return nil, err
+	}
+	if err := b.fillBuildDir(job, workspace.Path, log); err != nil {
+		b.releaseWorkspace(workspace)
+		return nil, err
 	}
+	return workspace, nil
+}
+
+// fillBuildDir unpacks, clones or downloads the source into the build directory
+func (b *Builder) fillBuildDir(job *BuildJob, buildDir string, log func(string)) error {
 
 	switch job.Build.Source {
 	case domain.BuildSourceGzip:
 		log("[NanoPaaS] Extracting gzipped source...\n")
 		if err := b.extractGzip(job.SourceData, buildDir); err != nil {
-			return "", fmt.Errorf("failed to extract source: %w", err)
+			return fmt.Errorf("failed to extract source: %w", err)
 		}
 
 	case domain.BuildSourceGit:
 		log(fmt.Sprintf("[NanoPaaS] Cloning repository: %s\n", job.SourceURL))
-		if err := b.cloneGitRepo(job.SourceURL, job.Build.GitRef, buildDir); err != nil {
-			return "", fmt.Errorf("failed to clone repository: %w", err)
+		cloneURL := job.SourceURL
+		if job.AuthenticatedURL != "" {
+			cloneURL = job.AuthenticatedURL
+		} else if b.cloneAuth != nil {
+			authURL, err := b.cloneAuth.AuthenticatedCloneURL(b.ctx, job.SourceURL)
+			if err != nil {
+				log(fmt.Sprintf("[NanoPaaS] Could not get repository credentials, cloning without them: %v\n", err))
+			} else {
+				cloneURL = authURL
+			}
+		}
+		if err := b.cloneGitRepo(cloneURL, job.Build.GitRef, buildDir); err != nil {
+			// Keep credentials out of build logs and errors
+			return fmt.Errorf("failed to clone repository: %s", strings.ReplaceAll(err.Error(), cloneURL, job.SourceURL))
 		}
 
 	case domain.BuildSourceURL:
 		log(fmt.Sprintf("[NanoPaaS] Downloading source from: %s\n", job.SourceURL))
 		if err := b.downloadSource(job.SourceURL, buildDir); err != nil {
-			return "", fmt.Errorf("failed to download source: %w", err)
+			return fmt.Errorf("failed to download source: %w", err)
 		}
 
 	default:
-		return "", fmt.Errorf("unsupported source type: %s", job.Build.Source)
+		return fmt.Errorf("unsupported source type: %s", job.Build.Source)
 	}
 
-	return buildDir, nil
+	return nil
 }
 
 // extractGzip extracts a gzipped tar archive to the destination
@@ -516,7 +630,7 @@ CMD ["ruby", "app.rb"]
 }
 
 // buildImage builds a Docker image from the build directory
-func (b *Builder) buildImage(ctx context.Context, buildDir, dockerfilePath, imageTag string, logCallback func(string)) (string, error) {
+func (b *Builder) buildImage(ctx context.Context, buildDir, dockerfilePath, imageTag string, labels map[string]string, logCallback docker.BuildLogFunc, onPullProgress docker.PullProgressFunc) (string, error) {
 	// Create tar archive of build context
 	tarPath := buildDir + ".tar"
 	if err := b.createTarArchive(buildDir, tarPath); err != nil {
@@ -537,6 +651,9 @@ func (b *Builder) buildImage(ctx context.Context, buildDir, dockerfilePath, imag
 		DockerfilePath: dockerfilePath,
 		NoCache:        false,
 		Pull:           true,
+		Labels:         labels,
+		Progress:       onPullProgress,
+		StripANSI:      b.config.StripANSI,
 	}
 
 	// Build with log streaming
@@ -612,6 +729,9 @@ func (b *Builder) finishBuild(job *BuildJob, imageID, imageTag string, err error
 			zap.Error(err),
 			zap.Duration("duration", duration),
 		)
+		if job.OnFailure != nil {
+			go job.OnFailure(err)
+		}
 	} else {
 		build.Succeed(imageID, imageTag)
 		b.logger.Info("Build succeeded",
@@ -625,6 +745,16 @@ func (b *Builder) finishBuild(job *BuildJob, imageID, imageTag string, err error
 		}
 	}
 
+	if b.onBuildFinished != nil {
+		go b.onBuildFinished(build)
+	}
+
+	b.recordBuildTime(build.AppID, duration)
+	if job.history != nil {
+		job.history.finish(build)
+	}
+	job.archive.finish(build)
+
 	// Remove from active builds
 	b.activeBuildsMu.Lock()
 	delete(b.activeBuilds, build.ID)
@@ -648,6 +778,37 @@ func (b *Builder) finishBuild(job *BuildJob, imageID, imageTag string, err error
 	}
 }
 
+// recordBuildTime stores a finished build's duration and drops entries past retention
+func (b *Builder) recordBuildTime(appID uuid.UUID, duration time.Duration) {
+	now := time.Now().UTC()
+	cutoff := now.Add(-buildTimeRetention)
+
+	b.buildTimesMu.Lock()
+	defer b.buildTimesMu.Unlock()
+
+	kept := b.buildTimes[appID][:0]
+	for _, bt := range b.buildTimes[appID] {
+		if bt.completedAt.After(cutoff) {
+			kept = append(kept, bt)
+		}
+	}
+	b.buildTimes[appID] = append(kept, buildTime{completedAt: now, duration: duration})
+}
+
+// BuildTimeSince returns the total build time for an app since the given time
+func (b *Builder) BuildTimeSince(appID uuid.UUID, since time.Time) time.Duration {
+	b.buildTimesMu.RLock()
+	defer b.buildTimesMu.RUnlock()
+
+	var total time.Duration
+	for _, bt := range b.buildTimes[appID] {
+		if bt.completedAt.After(since) {
+			total += bt.duration
+		}
+	}
+	return total
+}
+
 // Shutdown gracefully shuts down the builder
 func (b *Builder) Shutdown() {
 	b.logger.Info("Shutting down builder service...")
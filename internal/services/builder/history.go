@@ -0,0 +1,118 @@
+package builder
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// Retention of finished build logs, kept for diagnostics, and of builds listed per app
+const (
+	buildLogHistoryPerApp = 5
+	buildLogHistoryLines  = 1000
+	buildHistoryPerApp    = 100
+)
+
+// BuildLog is the retained log of a recent build
+type BuildLog struct {
+	BuildID    uuid.UUID          `json:"build_id"`
+	Status     domain.BuildStatus `json:"status"`
+	ImageTag   string             `json:"image_tag,omitempty"`
+	Error      string             `json:"error,omitempty"`
+	StartedAt  time.Time          `json:"started_at"`
+	FinishedAt *time.Time         `json:"finished_at,omitempty"`
+	Lines      []string           `json:"-"`
+	Truncated  bool               `json:"truncated,omitempty"` // earlier lines were dropped
+}
+
+// buildLogRecorder keeps the tail of a running build's log
+type buildLogRecorder struct {
+	mu  sync.Mutex
+	log *BuildLog
+}
+
+func (rec *buildLogRecorder) write(line string) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.log.Lines = append(rec.log.Lines, line)
+	if extra := len(rec.log.Lines) - buildLogHistoryLines; extra > 0 {
+		rec.log.Lines = append([]string(nil), rec.log.Lines[extra:]...)
+		rec.log.Truncated = true
+	}
+}
+
+// startBuildLog begins retaining a build's log, dropping the app's oldest retained build
+func (b *Builder) startBuildLog(build *domain.Build) *buildLogRecorder {
+	rec := &buildLogRecorder{log: &BuildLog{
+		BuildID:   build.ID,
+		Status:    build.Status,
+		StartedAt: time.Now().UTC(),
+	}}
+
+	b.buildLogsMu.Lock()
+	logs := append(b.buildLogs[build.AppID], rec)
+	if len(logs) > buildLogHistoryPerApp {
+		logs = logs[len(logs)-buildLogHistoryPerApp:]
+	}
+	b.buildLogs[build.AppID] = logs
+	b.buildLogsMu.Unlock()
+
+	return rec
+}
+
+// finish records the build's outcome
+func (rec *buildLogRecorder) finish(build *domain.Build) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	now := time.Now().UTC()
+	rec.log.Status = build.Status
+	rec.log.ImageTag = build.ImageTag
+	rec.log.Error = build.ErrorMessage
+	rec.log.FinishedAt = &now
+}
+
+// RecentBuildLogs returns copies of an app's retained build logs, newest first
+func (b *Builder) RecentBuildLogs(appID uuid.UUID) []BuildLog {
+	b.buildLogsMu.RLock()
+	recs := b.buildLogs[appID]
+	b.buildLogsMu.RUnlock()
+
+	logs := make([]BuildLog, 0, len(recs))
+	for i := len(recs) - 1; i >= 0; i-- {
+		recs[i].mu.Lock()
+		log := *recs[i].log
+		log.Lines = append([]string(nil), log.Lines...)
+		recs[i].mu.Unlock()
+		logs = append(logs, log)
+	}
+	return logs
+}
+
+// recordAppBuild adds a submitted build to its app's build list, dropping the oldest
+// once buildHistoryPerApp builds are retained
+func (b *Builder) recordAppBuild(build *domain.Build) {
+	b.appBuildsMu.Lock()
+	defer b.appBuildsMu.Unlock()
+	builds := append(b.appBuilds[build.AppID], build)
+	if len(builds) > buildHistoryPerApp {
+		builds = append([]*domain.Build(nil), builds[len(builds)-buildHistoryPerApp:]...)
+	}
+	b.appBuilds[build.AppID] = builds
+}
+
+// AppBuilds returns copies of an app's retained builds, newest first
+func (b *Builder) AppBuilds(appID uuid.UUID) []*domain.Build {
+	b.appBuildsMu.RLock()
+	defer b.appBuildsMu.RUnlock()
+
+	recs := b.appBuilds[appID]
+	builds := make([]*domain.Build, 0, len(recs))
+	for i := len(recs) - 1; i >= 0; i-- {
+		build := *recs[i]
+		builds = append(builds, &build)
+	}
+	return builds
+}
@@ -0,0 +1,340 @@
+package builder
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	units "github.com/docker/go-units"
+	"go.uber.org/zap"
+)
+
+// Workspace driver names
+const (
+	WorkspaceDriverDir   = "dir"   // plain directory under the work dir
+	WorkspaceDriverTmpfs = "tmpfs" // memory-backed mount per build
+	WorkspaceDriverZFS   = "zfs"   // ZFS dataset per build
+	WorkspaceDriverQuota = "quota" // directory with an XFS project quota, as used for overlay2 quotas
+)
+
+// WorkspaceConfig selects and tunes the build workspace driver
+type WorkspaceConfig struct {
+	Driver string
+	Root   string // directory workspaces are created under; defaults to the builder work dir
+	Quota  string // size limit per workspace, e.g. "2g"; required for tmpfs and quota
+
+	ZFSDataset string // parent dataset for the zfs driver, e.g. "tank/nanopaas-builds"
+}
+
+// Workspace is the directory a build's source is unpacked into
+type Workspace struct {
+	Path   string
+	handle string // driver-specific: mount point, dataset or quota project ID
+}
+
+// WorkspaceDriver provisions and tears down build workspaces
+type WorkspaceDriver interface {
+	Name() string
+	Create(ctx context.Context, name string) (*Workspace, error)
+	Release(ctx context.Context, ws *Workspace) error
+}
+
+// WorkspaceMetrics are cumulative counters for the active workspace driver
+type WorkspaceMetrics struct {
+	Driver           string  `json:"driver"`
+	Created          int64   `json:"created"`
+	Failed           int64   `json:"failed"`
+	Active           int64   `json:"active"`
+	ReleaseFailed    int64   `json:"release_failed"`
+	ProvisionSeconds float64 `json:"provision_seconds"` // total time spent creating workspaces
+	BytesUsed        int64   `json:"bytes_used"`        // total size of released workspaces
+}
+
+// NewWorkspaceDriver creates the configured driver, defaulting to plain directories
+func NewWorkspaceDriver(config WorkspaceConfig, workDir string, logger *zap.Logger) (WorkspaceDriver, error) {
+	root := config.Root
+	if root == "" {
+		root = workDir
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create workspace root: %w", err)
+	}
+
+	var quota int64
+	if config.Quota != "" {
+		q, err := units.RAMInBytes(config.Quota)
+		if err != nil || q <= 0 {
+			return nil, fmt.Errorf("invalid workspace quota %q", config.Quota)
+		}
+		quota = q
+	}
+
+	switch config.Driver {
+	case "", WorkspaceDriverDir:
+		return &dirDriver{root: root}, nil
+	case WorkspaceDriverTmpfs:
+		if quota == 0 {
+			return nil, fmt.Errorf("tmpfs workspaces require a quota")
+		}
+		return &tmpfsDriver{root: root, size: quota}, nil
+	case WorkspaceDriverZFS:
+		if config.ZFSDataset == "" {
+			return nil, fmt.Errorf("zfs workspaces require a parent dataset")
+		}
+		return &zfsDriver{root: root, dataset: config.ZFSDataset, quota: quota}, nil
+	case WorkspaceDriverQuota:
+		if quota == 0 {
+			return nil, fmt.Errorf("quota workspaces require a quota")
+		}
+		mount, err := mountPoint(root)
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("Build workspaces use XFS project quotas", zap.String("filesystem", mount))
+		return &quotaDriver{root: root, mount: mount, quota: quota}, nil
+	}
+	return nil, fmt.Errorf("unsupported workspace driver %q", config.Driver)
+}
+
+// dirDriver creates plain directories
+type dirDriver struct {
+	root string
+}
+
+func (d *dirDriver) Name() string { return WorkspaceDriverDir }
+
+func (d *dirDriver) Create(ctx context.Context, name string) (*Workspace, error) {
+	path := filepath.Join(d.root, name)
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, err
+	}
+	return &Workspace{Path: path}, nil
+}
+
+func (d *dirDriver) Release(ctx context.Context, ws *Workspace) error {
+	return os.RemoveAll(ws.Path)
+}
+
+// tmpfsDriver mounts a size-limited tmpfs per build. Requires CAP_SYS_ADMIN.
+type tmpfsDriver struct {
+	root string
+	size int64
+}
+
+func (d *tmpfsDriver) Name() string { return WorkspaceDriverTmpfs }
+
+func (d *tmpfsDriver) Create(ctx context.Context, name string) (*Workspace, error) {
+	path := filepath.Join(d.root, name)
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, err
+	}
+	if err := run(ctx, "mount", "-t", "tmpfs", "-o", "size="+strconv.FormatInt(d.size, 10)+",mode=0755", "tmpfs", path); err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	return &Workspace{Path: path, handle: path}, nil
+}
+
+func (d *tmpfsDriver) Release(ctx context.Context, ws *Workspace) error {
+	if err := run(ctx, "umount", ws.handle); err != nil {
+		return err
+	}
+	return os.Remove(ws.Path)
+}
+
+// zfsDriver creates a child dataset per build, optionally with a quota
+type zfsDriver struct {
+	root    string
+	dataset string
+	quota   int64
+}
+
+func (d *zfsDriver) Name() string { return WorkspaceDriverZFS }
+
+func (d *zfsDriver) Create(ctx context.Context, name string) (*Workspace, error) {
+	path := filepath.Join(d.root, name)
+	dataset := d.dataset + "/" + name
+	args := []string{"create", "-o", "mountpoint=" + path, "-o", "compression=lz4"}
+	if d.quota > 0 {
+		args = append(args, "-o", "quota="+strconv.FormatInt(d.quota, 10))
+	}
+	if err := run(ctx, "zfs", append(args, dataset)...); err != nil {
+		return nil, err
+	}
+	return &Workspace{Path: path, handle: dataset}, nil
+}
+
+func (d *zfsDriver) Release(ctx context.Context, ws *Workspace) error {
+	return run(ctx, "zfs", "destroy", "-r", ws.handle)
+}
+
+// quotaDriver limits each workspace directory with an XFS project quota. The root must be
+// on an XFS filesystem mounted with prjquota, the same setup overlay2 storage quotas need.
+type quotaDriver struct {
+	root  string
+	mount string
+	quota int64
+
+	nextID atomic.Uint32
+}
+
+// quotaProjectBase keeps build project IDs clear of IDs assigned by hand or by Docker
+const quotaProjectBase = 1 << 20
+
+func (d *quotaDriver) Name() string { return WorkspaceDriverQuota }
+
+func (d *quotaDriver) Create(ctx context.Context, name string) (*Workspace, error) {
+	path := filepath.Join(d.root, name)
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, err
+	}
+	id := strconv.FormatUint(uint64(quotaProjectBase+d.nextID.Add(1)), 10)
+	err := run(ctx, "xfs_quota", "-x",
+		"-c", fmt.Sprintf("project -s -p %s %s", path, id),
+		"-c", fmt.Sprintf("limit -p bhard=%d %s", d.quota, id),
+		d.mount,
+	)
+	if err != nil {
+		os.RemoveAll(path)
+		return nil, err
+	}
+	return &Workspace{Path: path, handle: id}, nil
+}
+
+func (d *quotaDriver) Release(ctx context.Context, ws *Workspace) error {
+	if err := os.RemoveAll(ws.Path); err != nil {
+		return err
+	}
+	return run(ctx, "xfs_quota", "-x", "-c", fmt.Sprintf("limit -p bhard=0 %s", ws.handle), d.mount)
+}
+
+// mountPoint returns the mount point of the filesystem holding path
+func mountPoint(path string) (string, error) {
+	data, err := os.ReadFile("/proc/self/mounts")
+	if err != nil {
+		return "", fmt.Errorf("failed to read mounts: %w", err)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	best := ""
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		mount := fields[1]
+		if (abs == mount || strings.HasPrefix(abs, strings.TrimSuffix(mount, "/")+"/")) && len(mount) > len(best) {
+			best = mount
+		}
+	}
+	if best == "" {
+		return "", fmt.Errorf("no filesystem found for %s", path)
+	}
+	return best, nil
+}
+
+// run executes a command, including its output in the error
+func run(ctx context.Context, name string, args ...string) error {
+	output, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s failed: %s: %w", name, strings.TrimSpace(string(output)), err)
+	}
+	return nil
+}
+
+// workspaceMetrics counts workspace operations for the active driver
+type workspaceMetrics struct {
+	mu      sync.Mutex
+	metrics WorkspaceMetrics
+}
+
+func (m *workspaceMetrics) created(elapsed time.Duration) {
+	m.mu.Lock()
+	m.metrics.Created++
+	m.metrics.Active++
+	m.metrics.ProvisionSeconds += elapsed.Seconds()
+	m.mu.Unlock()
+}
+
+func (m *workspaceMetrics) failed() {
+	m.mu.Lock()
+	m.metrics.Failed++
+	m.mu.Unlock()
+}
+
+func (m *workspaceMetrics) released(bytes int64, err error) {
+	m.mu.Lock()
+	m.metrics.Active--
+	m.metrics.BytesUsed += bytes
+	if err != nil {
+		m.metrics.ReleaseFailed++
+	}
+	m.mu.Unlock()
+}
+
+func (m *workspaceMetrics) snapshot() WorkspaceMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.metrics
+}
+
+// createWorkspace provisions a workspace for a build and records metrics
+func (b *Builder) createWorkspace(ctx context.Context, name string) (*Workspace, error) {
+	start := time.Now()
+	ws, err := b.workspaces.Create(ctx, name)
+	if err != nil {
+		b.workspaceMetrics.failed()
+		return nil, fmt.Errorf("failed to create %s workspace: %w", b.workspaces.Name(), err)
+	}
+	b.workspaceMetrics.created(time.Since(start))
+	return ws, nil
+}
+
+// releaseWorkspace tears a workspace down, measuring how much it held first
+func (b *Builder) releaseWorkspace(ws *Workspace) {
+	size := dirSize(ws.Path)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	err := b.workspaces.Release(ctx, ws)
+	b.workspaceMetrics.released(size, err)
+	if err != nil {
+		b.logger.Warn("Failed to release build workspace",
+			zap.String("driver", b.workspaces.Name()),
+			zap.String("path", ws.Path),
+			zap.Error(err),
+		)
+	}
+}
+
+// WorkspaceMetrics returns counters for the build workspace driver
+func (b *Builder) WorkspaceMetrics() WorkspaceMetrics {
+	return b.workspaceMetrics.snapshot()
+}
+
+// dirSize sums the size of regular files under path
+func dirSize(path string) int64 {
+	var size int64
+	filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
@@ -0,0 +1,406 @@
+package compose
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	units "github.com/docker/go-units"
+	"gopkg.in/yaml.v3"
+)
+
+// File is the subset of the Compose specification that NanoPaaS can import
+type File struct {
+	Services map[string]Service `yaml:"services"`
+	Volumes  map[string]Volume  `yaml:"volumes"`
+}
+
+// Volume is a top-level named volume declaration
+type Volume struct {
+	External bool   `yaml:"external"`
+	Name     string `yaml:"name"`
+}
+
+// Service is one compose service. Keys NanoPaaS cannot honour are collected in
+// Ignored so the importer can warn about them instead of failing.
+type Service struct {
+	Image       string
+	Build       bool
+	Command     []string
+	Environment map[string]string
+	Ports       []Port
+	Volumes     []Mount
+	DependsOn   []string
+	Restart     string
+	MemoryLimit int64   // bytes, from mem_limit or deploy.resources.limits.memory
+	CPUs        float64 // from cpus or deploy.resources.limits.cpus
+	Replicas    int     // from deploy.replicas, 0 if unset
+	Labels      map[string]string
+
+	Ignored  []string // keys that are not supported and were skipped
+	Warnings []string // values that were only partly understood
+}
+
+// Port is a container port from the ports list
+type Port struct {
+	Target    int
+	Published string
+	Protocol  string
+}
+
+// Mount is a volume, bind or tmpfs entry from a service's volumes list
+type Mount struct {
+	Type     string // volume, bind or tmpfs
+	Source   string // volume name or host path; empty for anonymous volumes
+	Target   string
+	ReadOnly bool
+	Size     string // tmpfs size, from tmpfs.size; empty when unset
+}
+
+// interpolationPattern matches ${VAR}, ${VAR:-default}, ${VAR-default} and $VAR
+var interpolationPattern = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)(?:(:?-)([^}]*))?\}|\$([A-Za-z_][A-Za-z0-9_]*)`)
+
+// Parse parses a compose file after substituting variables. Unset variables without a
+// default become empty strings and are returned so the caller can report them.
+func Parse(data []byte, variables map[string]string) (*File, []string, error) {
+	var missing []string
+	seen := make(map[string]bool)
+	interpolated := interpolationPattern.ReplaceAllStringFunc(string(data), func(match string) string {
+		if match == "$$" {
+			return "$"
+		}
+		parts := interpolationPattern.FindStringSubmatch(match)
+		name, op, def := parts[1], parts[2], parts[3]
+		if name == "" {
+			name = parts[4]
+		}
+		value, ok := variables[name]
+		switch {
+		case ok && (value != "" || op != ":-"):
+			return value
+		case op != "":
+			return def
+		}
+		if !seen[name] {
+			seen[name] = true
+			missing = append(missing, name)
+		}
+		return ""
+	})
+
+	var f File
+	if err := yaml.Unmarshal([]byte(interpolated), &f); err != nil {
+		return nil, nil, fmt.Errorf("invalid compose file: %w", err)
+	}
+	if len(f.Services) == 0 {
+		return nil, nil, fmt.Errorf("compose file defines no services")
+	}
+	return &f, missing, nil
+}
+
+// UnmarshalYAML decodes a service, accepting both the short and long syntax of each key
+func (s *Service) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: service must be a mapping", node.Line)
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i].Value, node.Content[i+1]
+		var err error
+		switch key {
+		case "image":
+			s.Image = value.Value
+		case "build":
+			s.Build = true
+		case "command":
+			s.Command, err = decodeCommand(value)
+		case "environment":
+			s.Environment, err = s.decodeMapping(value, "environment")
+		case "labels":
+			s.Labels, err = s.decodeMapping(value, "labels")
+		case "ports":
+			s.Ports, err = s.decodePorts(value)
+		case "volumes":
+			s.Volumes, err = decodeMounts(value)
+		case "depends_on":
+			s.DependsOn, err = decodeDependsOn(value)
+		case "restart":
+			s.Restart = value.Value
+		case "mem_limit":
+			s.MemoryLimit, err = decodeBytes(value)
+		case "cpus":
+			s.CPUs, err = strconv.ParseFloat(value.Value, 64)
+		case "deploy":
+			err = s.decodeDeploy(value)
+		case "container_name", "hostname", "networks", "expose", "healthcheck", "stop_grace_period":
+			// Superseded by NanoPaaS naming, the shared app network and its own health checks
+		default:
+			s.Ignored = append(s.Ignored, key)
+		}
+		if err != nil {
+			return fmt.Errorf("line %d: %s: %w", value.Line, key, err)
+		}
+	}
+	return nil
+}
+
+// decodeCommand accepts a shell-form string or an exec-form list
+func decodeCommand(node *yaml.Node) ([]string, error) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if node.Value == "" {
+			return nil, nil
+		}
+		return []string{"/bin/sh", "-c", node.Value}, nil
+	case yaml.SequenceNode:
+		var cmd []string
+		if err := node.Decode(&cmd); err != nil {
+			return nil, err
+		}
+		return cmd, nil
+	}
+	return nil, fmt.Errorf("must be a string or a list")
+}
+
+// decodeMapping accepts KEY: value mappings and KEY=value lists. Entries without a
+// value would be read from the host environment, which the importer cannot see.
+func (s *Service) decodeMapping(node *yaml.Node, key string) (map[string]string, error) {
+	result := make(map[string]string)
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			name, value := node.Content[i].Value, node.Content[i+1]
+			if value.Tag == "!!null" {
+				s.Warnings = append(s.Warnings, fmt.Sprintf("%s %s has no value and was skipped", key, name))
+				continue
+			}
+			result[name] = value.Value
+		}
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			name, value, ok := strings.Cut(item.Value, "=")
+			if !ok {
+				s.Warnings = append(s.Warnings, fmt.Sprintf("%s %s has no value and was skipped", key, name))
+				continue
+			}
+			result[name] = value
+		}
+	default:
+		return nil, fmt.Errorf("must be a mapping or a list")
+	}
+	return result, nil
+}
+
+// decodePorts accepts "[ip:][published:]target[/protocol]" strings and long-syntax mappings
+func (s *Service) decodePorts(node *yaml.Node) ([]Port, error) {
+	if node.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("must be a list")
+	}
+
+	var ports []Port
+	for _, item := range node.Content {
+		var port Port
+		if item.Kind == yaml.MappingNode {
+			var long struct {
+				Target    int    `yaml:"target"`
+				Published string `yaml:"published"`
+				Protocol  string `yaml:"protocol"`
+			}
+			if err := item.Decode(&long); err != nil {
+				return nil, err
+			}
+			port = Port{Target: long.Target, Published: long.Published, Protocol: long.Protocol}
+		} else {
+			spec, protocol, _ := strings.Cut(item.Value, "/")
+			parts := strings.Split(spec, ":")
+			target := parts[len(parts)-1]
+			if len(parts) > 1 {
+				port.Published = parts[len(parts)-2]
+			}
+			if first, _, isRange := strings.Cut(target, "-"); isRange {
+				s.Warnings = append(s.Warnings, fmt.Sprintf("port range %s is not supported; using %s", target, first))
+				target = first
+			}
+			n, err := strconv.Atoi(target)
+			if err != nil {
+				return nil, fmt.Errorf("invalid port %q", item.Value)
+			}
+			port.Target = n
+			port.Protocol = protocol
+		}
+
+		if port.Target <= 0 || port.Target > 65535 {
+			return nil, fmt.Errorf("invalid container port %d", port.Target)
+		}
+		if port.Protocol == "" {
+			port.Protocol = "tcp"
+		}
+		ports = append(ports, port)
+	}
+	return ports, nil
+}
+
+// decodeMounts accepts "[source:]target[:mode]" strings and long-syntax mappings
+func decodeMounts(node *yaml.Node) ([]Mount, error) {
+	if node.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("must be a list")
+	}
+
+	var mounts []Mount
+	for _, item := range node.Content {
+		var m Mount
+		if item.Kind == yaml.MappingNode {
+			var long struct {
+				Type     string `yaml:"type"`
+				Source   string `yaml:"source"`
+				Target   string `yaml:"target"`
+				ReadOnly bool   `yaml:"read_only"`
+				Tmpfs    struct {
+					Size string `yaml:"size"`
+				} `yaml:"tmpfs"`
+			}
+			if err := item.Decode(&long); err != nil {
+				return nil, err
+			}
+			m = Mount{Type: long.Type, Source: long.Source, Target: long.Target, ReadOnly: long.ReadOnly, Size: long.Tmpfs.Size}
+		} else {
+			parts := strings.Split(item.Value, ":")
+			switch len(parts) {
+			case 1:
+				m.Target = parts[0]
+			case 2:
+				m.Source, m.Target = parts[0], parts[1]
+			default:
+				m.Source, m.Target = parts[0], parts[1]
+				m.ReadOnly = strings.Contains(parts[2], "ro")
+			}
+			m.Type = "volume"
+			if strings.HasPrefix(m.Source, "/") || strings.HasPrefix(m.Source, ".") || strings.HasPrefix(m.Source, "~") {
+				m.Type = "bind"
+			}
+		}
+
+		if m.Type == "" {
+			m.Type = "volume"
+		}
+		if m.Target == "" {
+			return nil, fmt.Errorf("volume %q has no target path", item.Value)
+		}
+		mounts = append(mounts, m)
+	}
+	return mounts, nil
+}
+
+// decodeDependsOn accepts a list of service names or a mapping of name to condition
+func decodeDependsOn(node *yaml.Node) ([]string, error) {
+	var deps []string
+	switch node.Kind {
+	case yaml.SequenceNode:
+		if err := node.Decode(&deps); err != nil {
+			return nil, err
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			deps = append(deps, node.Content[i].Value)
+		}
+	default:
+		return nil, fmt.Errorf("must be a list or a mapping")
+	}
+	return deps, nil
+}
+
+// decodeDeploy reads replicas and resource limits from the deploy section
+func (s *Service) decodeDeploy(node *yaml.Node) error {
+	var deploy struct {
+		Replicas  *int `yaml:"replicas"`
+		Resources struct {
+			Limits struct {
+				CPUs   string    `yaml:"cpus"`
+				Memory yaml.Node `yaml:"memory"`
+			} `yaml:"limits"`
+		} `yaml:"resources"`
+	}
+	if err := node.Decode(&deploy); err != nil {
+		return err
+	}
+
+	if deploy.Replicas != nil {
+		s.Replicas = *deploy.Replicas
+	}
+	if cpus := deploy.Resources.Limits.CPUs; cpus != "" {
+		n, err := strconv.ParseFloat(cpus, 64)
+		if err != nil {
+			return fmt.Errorf("invalid cpus %q", cpus)
+		}
+		s.CPUs = n
+	}
+	if deploy.Resources.Limits.Memory.Value != "" {
+		n, err := decodeBytes(&deploy.Resources.Limits.Memory)
+		if err != nil {
+			return err
+		}
+		s.MemoryLimit = n
+	}
+	return nil
+}
+
+// decodeBytes parses a byte count such as 536870912, "512m" or "1gb"
+func decodeBytes(node *yaml.Node) (int64, error) {
+	n, err := units.RAMInBytes(node.Value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", node.Value)
+	}
+	return n, nil
+}
+
+// Order returns service names so that every service comes after the services it depends on
+func (f *File) Order() ([]string, error) {
+	names := make([]string, 0, len(f.Services))
+	for name := range f.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(names))
+	order := make([]string, 0, len(names))
+
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("dependency cycle: %s", strings.Join(append(path, name), " -> "))
+		}
+		state[name] = visiting
+
+		deps := append([]string(nil), f.Services[name].DependsOn...)
+		sort.Strings(deps)
+		for _, dep := range deps {
+			if _, ok := f.Services[dep]; !ok {
+				return fmt.Errorf("service %s depends on undefined service %s", name, dep)
+			}
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+
+		state[name] = visited
+		order = append(order, name)
+		return nil
+	}
+
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
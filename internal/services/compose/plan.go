@@ -0,0 +1,254 @@
+package compose
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// StackLabel is the app label grouping apps imported from the same compose file
+const StackLabel = "nanopaas.stack"
+
+// maxReplicas matches the limit enforced by the scale endpoint
+const maxReplicas = 10
+
+// cpuPeriod is the CFS period CPU quotas are expressed against
+const cpuPeriod = 100000
+
+// defaultTmpfsSize sizes tmpfs mounts that set no size, since apps' mounts need one
+const defaultTmpfsSize = "64m"
+
+// ImportOptions controls how a compose file maps onto apps
+type ImportOptions struct {
+	// Stack groups the apps: slugs become <stack>-<service> and every app carries the
+	// nanopaas.stack label. Empty imports standalone apps.
+	Stack   string
+	OwnerID uuid.UUID
+	// AllowExternalVolumes lets services mount external volumes, which reach outside the
+	// apps' own scope; only admins may import them
+	AllowExternalVolumes bool
+}
+
+// Plan is the set of apps a compose file will create, in dependency order
+type Plan struct {
+	Stack    string         `json:"stack,omitempty"`
+	Services []*ServicePlan `json:"services"`
+	Skipped  []Skipped      `json:"skipped,omitempty"`
+}
+
+// ServicePlan is the app created for one compose service
+type ServicePlan struct {
+	Service   string      `json:"service"`
+	App       *domain.App `json:"-"`
+	DependsOn []string    `json:"depends_on,omitempty"`
+	Public    bool        `json:"public"` // has published ports and gets a route
+	Warnings  []string    `json:"warnings,omitempty"`
+}
+
+// Skipped is a service that cannot be imported
+type Skipped struct {
+	Service string `json:"service"`
+	Reason  string `json:"reason"`
+}
+
+// BuildPlan maps each service onto a new app
+func BuildPlan(f *File, opts ImportOptions) (*Plan, error) {
+	order, err := f.Order()
+	if err != nil {
+		return nil, err
+	}
+
+	stack := slugPart(opts.Stack)
+	if opts.Stack != "" && stack == "" {
+		return nil, fmt.Errorf("stack name %q has no usable characters", opts.Stack)
+	}
+
+	plan := &Plan{Stack: stack}
+	users := volumeUsers(f)
+	skipped := make(map[string]bool)
+	slugs := make(map[string]string)
+	for _, name := range order {
+		svc := f.Services[name]
+		if svc.Image == "" {
+			reason := "no image specified"
+			if svc.Build {
+				reason = "build is not supported by compose import; build the image with the builds API and deploy it to the created app"
+			}
+			plan.Skipped = append(plan.Skipped, Skipped{Service: name, Reason: reason})
+			skipped[name] = true
+			continue
+		}
+
+		sp, err := planService(f, name, svc, stack, opts)
+		if err != nil {
+			return nil, fmt.Errorf("service %s: %w", name, err)
+		}
+		if other, dup := slugs[sp.App.Slug]; dup {
+			return nil, fmt.Errorf("services %s and %s both map to app slug %s", other, name, sp.App.Slug)
+		}
+		slugs[sp.App.Slug] = name
+		for _, dep := range svc.DependsOn {
+			if skipped[dep] {
+				sp.Warnings = append(sp.Warnings, fmt.Sprintf("depends on %s, which was skipped", dep))
+			}
+		}
+		for _, v := range sp.App.Volumes {
+			if !v.External && len(users[v.Name]) > 1 {
+				sp.Warnings = append(sp.Warnings, fmt.Sprintf("volume %s is not shared with the other services mounting it; each app has its own copy", v.Name))
+			}
+		}
+		plan.Services = append(plan.Services, sp)
+	}
+
+	if len(plan.Services) == 0 {
+		return nil, fmt.Errorf("no importable services: every service was skipped")
+	}
+	return plan, nil
+}
+
+// planService builds the app for a single service
+func planService(f *File, name string, svc Service, stack string, opts ImportOptions) (*ServicePlan, error) {
+	slug := slugPart(name)
+	if slug == "" {
+		return nil, fmt.Errorf("service name has no usable characters")
+	}
+	if stack != "" {
+		slug = stack + "-" + slug
+	}
+
+	app := domain.NewApp(name, slug, opts.OwnerID)
+	app.CurrentImageID = svc.Image
+	app.Command = svc.Command
+	sp := &ServicePlan{Service: name, App: app, DependsOn: svc.DependsOn}
+	sp.Warnings = append(sp.Warnings, svc.Warnings...)
+	for _, key := range svc.Ignored {
+		sp.Warnings = append(sp.Warnings, fmt.Sprintf("%s is not supported and was ignored", key))
+	}
+
+	for k, v := range svc.Environment {
+		app.SetEnvVar(k, v)
+	}
+	for k, v := range svc.Labels {
+		app.Labels[k] = v
+	}
+	if stack != "" {
+		app.Labels[StackLabel] = stack
+	}
+
+	// Other services reach this one by its compose name on the shared app network
+	if alias := strings.ToLower(name); alias == slugPart(name) {
+		app.NetworkAliases = []string{alias}
+	} else {
+		sp.Warnings = append(sp.Warnings, fmt.Sprintf("service name is not a valid hostname; other services must use %s", slug))
+	}
+
+	// Only published ports are routed; everything else stays on the internal network
+	app.ExposedPort = 0
+	for _, port := range svc.Ports {
+		if port.Protocol != "tcp" {
+			sp.Warnings = append(sp.Warnings, fmt.Sprintf("%s port %d is not supported and was ignored", port.Protocol, port.Target))
+			continue
+		}
+		if app.ExposedPort == 0 {
+			app.ExposedPort = port.Target
+			sp.Public = true
+			continue
+		}
+		sp.Warnings = append(sp.Warnings, fmt.Sprintf("only one port is routed; port %d is reachable on the app network only", port.Target))
+	}
+
+	for _, m := range svc.Volumes {
+		switch m.Type {
+		case "volume":
+			name, external := volumeName(f, m.Source, len(app.Volumes))
+			if external && !opts.AllowExternalVolumes {
+				return nil, fmt.Errorf("volume %s is external; only admins can mount external volumes", name)
+			}
+			app.Volumes = append(app.Volumes, domain.VolumeMount{
+				Name:     name,
+				Target:   m.Target,
+				ReadOnly: m.ReadOnly,
+				External: external,
+			})
+		case "tmpfs":
+			if app.Tmpfs == nil {
+				app.Tmpfs = make(map[string]string)
+			}
+			size := m.Size
+			if size == "" {
+				size = defaultTmpfsSize
+				sp.Warnings = append(sp.Warnings, fmt.Sprintf("tmpfs mount %s has no size; using %s", m.Target, defaultTmpfsSize))
+			}
+			app.Tmpfs[m.Target] = "size=" + size
+		default:
+			sp.Warnings = append(sp.Warnings, fmt.Sprintf("%s mount %s is not supported; use a named volume", m.Type, m.Target))
+		}
+	}
+
+	if svc.Restart != "" {
+		policy, _, _ := strings.Cut(svc.Restart, ":")
+		app.RestartPolicy = policy
+	}
+	if svc.MemoryLimit > 0 {
+		app.MemoryLimit = svc.MemoryLimit
+	}
+	if svc.CPUs > 0 {
+		app.CPUQuota = int64(svc.CPUs * cpuPeriod)
+	}
+	switch {
+	case svc.Replicas > maxReplicas:
+		sp.Warnings = append(sp.Warnings, fmt.Sprintf("replicas capped at %d", maxReplicas))
+		app.TargetReplicas = maxReplicas
+	case svc.Replicas > 0:
+		app.TargetReplicas = svc.Replicas
+	}
+
+	if err := app.ValidateRuntimeOptions(); err != nil {
+		return nil, err
+	}
+	return sp, nil
+}
+
+// volumeName names the volume a mount uses and reports whether it is external. Other
+// volumes are scoped to the app when it is deployed, so their names only need to be
+// unique within it.
+func volumeName(f *File, source string, index int) (string, bool) {
+	if source == "" {
+		return fmt.Sprintf("anon%d", index), false
+	}
+	v := f.Volumes[source]
+	if v.External && v.Name != "" {
+		return v.Name, true
+	}
+	return source, v.External
+}
+
+// volumeUsers lists the services mounting each named volume
+func volumeUsers(f *File) map[string][]string {
+	users := make(map[string][]string)
+	for name, svc := range f.Services {
+		for _, m := range svc.Volumes {
+			if m.Type == "volume" && m.Source != "" {
+				users[m.Source] = append(users[m.Source], name)
+			}
+		}
+	}
+	return users
+}
+
+// slugPart lowercases a name and replaces characters not allowed in slugs with dashes
+func slugPart(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9'):
+			b.WriteRune(r)
+		case b.Len() > 0 && !strings.HasSuffix(b.String(), "-"):
+			b.WriteByte('-')
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
@@ -0,0 +1,130 @@
+package cost
+
+import (
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+const (
+	// HoursPerMonth is the average number of hours in a month
+	HoursPerMonth = 730
+
+	// cpuPeriod is the CFS period CPUQuota is expressed against (100ms)
+	cpuPeriod = 100000
+
+	// buildWindow is the lookback used to project monthly build minutes
+	buildWindow = 30 * 24 * time.Hour
+)
+
+// Rates holds the admin-configured prices used for estimates
+type Rates struct {
+	Currency     string
+	MemoryGBHour float64
+	VCPUHour     float64
+	BuildMinute  float64
+}
+
+// BuildTimeSource reports how long an app spent building
+type BuildTimeSource interface {
+	BuildTimeSince(appID uuid.UUID, since time.Time) time.Duration
+}
+
+// AppEstimate is the estimated monthly cost of an app
+type AppEstimate struct {
+	AppID        string  `json:"app_id"`
+	AppSlug      string  `json:"app_slug"`
+	Currency     string  `json:"currency"`
+	Replicas     int     `json:"replicas"`
+	MemoryGB     float64 `json:"memory_gb_per_replica"`
+	VCPUs        float64 `json:"vcpus_per_replica"`
+	BuildMinutes float64 `json:"build_minutes"`
+	MemoryCost   float64 `json:"memory_cost"`
+	CPUCost      float64 `json:"cpu_cost"`
+	BuildCost    float64 `json:"build_cost"`
+	MonthlyTotal float64 `json:"monthly_total"`
+}
+
+// TeamEstimate aggregates app estimates for a team
+type TeamEstimate struct {
+	TeamID       string        `json:"team_id"`
+	Currency     string        `json:"currency"`
+	Apps         []AppEstimate `json:"apps"`
+	MonthlyTotal float64       `json:"monthly_total"`
+}
+
+// Estimator computes cost estimates from resource reservations
+type Estimator struct {
+	rates  Rates
+	builds BuildTimeSource
+}
+
+// NewEstimator creates a new cost estimator; builds may be nil
+func NewEstimator(rates Rates, builds BuildTimeSource) *Estimator {
+	return &Estimator{
+		rates:  rates,
+		builds: builds,
+	}
+}
+
+// EstimateApp returns the monthly cost of an app based on its limits and replica count.
+// Apps that are not scheduled to run only accrue build cost.
+func (e *Estimator) EstimateApp(app *domain.App) AppEstimate {
+	replicas := app.TargetReplicas
+	if app.Status == domain.AppStatusCreated || app.Status == domain.AppStatusStopped {
+		replicas = 0
+	}
+
+	memoryGB := float64(app.MemoryLimit) / (1024 * 1024 * 1024)
+	vcpus := float64(app.CPUQuota) / cpuPeriod
+
+	var buildMinutes float64
+	if e.builds != nil {
+		buildMinutes = e.builds.BuildTimeSince(app.ID, time.Now().UTC().Add(-buildWindow)).Minutes()
+	}
+
+	est := AppEstimate{
+		AppID:        app.ID.String(),
+		AppSlug:      app.Slug,
+		Currency:     e.rates.Currency,
+		Replicas:     replicas,
+		MemoryGB:     round(memoryGB),
+		VCPUs:        round(vcpus),
+		BuildMinutes: round(buildMinutes),
+		MemoryCost:   round(memoryGB * float64(replicas) * HoursPerMonth * e.rates.MemoryGBHour),
+		CPUCost:      round(vcpus * float64(replicas) * HoursPerMonth * e.rates.VCPUHour),
+		BuildCost:    round(buildMinutes * e.rates.BuildMinute),
+	}
+	est.MonthlyTotal = round(est.MemoryCost + est.CPUCost + est.BuildCost)
+
+	return est
+}
+
+// EstimateTeam aggregates estimates for all apps belonging to a team
+func (e *Estimator) EstimateTeam(teamID uuid.UUID, apps []*domain.App) TeamEstimate {
+	result := TeamEstimate{
+		TeamID:   teamID.String(),
+		Currency: e.rates.Currency,
+		Apps:     []AppEstimate{},
+	}
+
+	for _, app := range apps {
+		if app.TeamID == nil || *app.TeamID != teamID {
+			continue
+		}
+		est := e.EstimateApp(app)
+		result.Apps = append(result.Apps, est)
+		result.MonthlyTotal += est.MonthlyTotal
+	}
+	result.MonthlyTotal = round(result.MonthlyTotal)
+
+	return result
+}
+
+// round rounds to cents
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
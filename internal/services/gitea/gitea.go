@@ -0,0 +1,383 @@
+// Package gitea lists repositories, installs push webhooks and clones from a Gitea or
+// Forgejo instance, authenticated with users' access tokens.
+package gitea
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+	"github.com/nanopaas/nanopaas/internal/services/gitprovider"
+)
+
+// Config holds Gitea configuration
+type Config struct {
+	BaseURL       string // e.g. https://gitea.example.com
+	WebhookSecret string // signs webhook deliveries in X-Gitea-Signature
+}
+
+// Service handles Gitea API interactions
+type Service struct {
+	config     Config
+	httpClient *http.Client
+	logger     *zap.Logger
+}
+
+// NewService creates a new Gitea service
+func NewService(config Config, logger *zap.Logger) *Service {
+	config.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+	return &Service{
+		config: config,
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		logger: logger,
+	}
+}
+
+// Name returns "gitea"
+func (s *Service) Name() string {
+	return domain.GitProviderGitea
+}
+
+// GetUser fetches the token's user
+func (s *Service) GetUser(ctx context.Context, accessToken string) (*gitprovider.User, error) {
+	var user struct {
+		ID       int64  `json:"id"`
+		Login    string `json:"login"`
+		FullName string `json:"full_name"`
+		Email    string `json:"email"`
+	}
+	if err := s.doJSON(ctx, "GET", "/user", accessToken, nil, &user); err != nil {
+		return nil, fmt.Errorf("failed to fetch user: %w", err)
+	}
+	return &gitprovider.User{ID: user.ID, Username: user.Login, Name: user.FullName, Email: user.Email}, nil
+}
+
+// repository is a Gitea repository as the API returns it
+type repository struct {
+	ID            int64     `json:"id"`
+	Name          string    `json:"name"`
+	FullName      string    `json:"full_name"`
+	Description   string    `json:"description"`
+	Private       bool      `json:"private"`
+	HTMLURL       string    `json:"html_url"`
+	CloneURL      string    `json:"clone_url"`
+	DefaultBranch string    `json:"default_branch"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+func (r *repository) repository() gitprovider.Repository {
+	return gitprovider.Repository{
+		ID:            r.ID,
+		Name:          r.Name,
+		FullName:      r.FullName,
+		Description:   r.Description,
+		Private:       r.Private,
+		HTMLURL:       r.HTMLURL,
+		CloneURL:      r.CloneURL,
+		DefaultBranch: r.DefaultBranch,
+		UpdatedAt:     r.UpdatedAt,
+	}
+}
+
+// ListRepositories lists the repositories the user owns or can access
+func (s *Service) ListRepositories(ctx context.Context, accessToken string, page, perPage int) ([]gitprovider.Repository, error) {
+	if perPage <= 0 {
+		perPage = 30
+	}
+	if page <= 0 {
+		page = 1
+	}
+
+	var repos []repository
+	path := fmt.Sprintf("/user/repos?limit=%d&page=%d", perPage, page)
+	if err := s.doJSON(ctx, "GET", path, accessToken, nil, &repos); err != nil {
+		return nil, fmt.Errorf("failed to fetch repos: %w", err)
+	}
+
+	result := make([]gitprovider.Repository, len(repos))
+	for i := range repos {
+		result[i] = repos[i].repository()
+	}
+	s.logger.Debug("Fetched Gitea repositories", zap.Int("count", len(result)))
+	return result, nil
+}
+
+// GetRepository fetches a repository
+func (s *Service) GetRepository(ctx context.Context, accessToken, owner, repo string) (*gitprovider.Repository, error) {
+	var r repository
+	if err := s.doJSON(ctx, "GET", repoPath(owner, repo), accessToken, nil, &r); err != nil {
+		return nil, fmt.Errorf("failed to fetch repo: %w", err)
+	}
+	result := r.repository()
+	return &result, nil
+}
+
+// ListBranches lists a repository's branches
+func (s *Service) ListBranches(ctx context.Context, accessToken, owner, repo string) ([]gitprovider.Branch, error) {
+	var branches []struct {
+		Name      string `json:"name"`
+		Protected bool   `json:"protected"`
+		Commit    struct {
+			ID string `json:"id"`
+		} `json:"commit"`
+	}
+	if err := s.doJSON(ctx, "GET", repoPath(owner, repo)+"/branches?limit=100", accessToken, nil, &branches); err != nil {
+		return nil, fmt.Errorf("failed to fetch branches: %w", err)
+	}
+
+	result := make([]gitprovider.Branch, len(branches))
+	for i, b := range branches {
+		result[i] = gitprovider.Branch{Name: b.Name, Protected: b.Protected, CommitSHA: b.Commit.ID}
+	}
+	return result, nil
+}
+
+// hook is a Gitea repository webhook as the API returns it
+type hook struct {
+	ID     int64 `json:"id"`
+	Active bool  `json:"active"`
+	Config struct {
+		URL string `json:"url"`
+	} `json:"config"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (h *hook) webhook() gitprovider.Webhook {
+	return gitprovider.Webhook{ID: h.ID, URL: h.Config.URL, Active: h.Active, CreatedAt: h.CreatedAt}
+}
+
+// CreateWebhook adds a push webhook to a repository, signed with secret or the
+// configured webhook secret
+func (s *Service) CreateWebhook(ctx context.Context, accessToken, owner, repo, webhookURL, secret string) (*gitprovider.Webhook, error) {
+	payload := map[string]interface{}{
+		"type":   "gitea",
+		"active": true,
+		"events": []string{"push"},
+		"config": map[string]string{
+			"url":          webhookURL,
+			"content_type": "json",
+			"secret":       s.webhookSecret(secret),
+		},
+	}
+	var h hook
+	if err := s.doJSON(ctx, "POST", repoPath(owner, repo)+"/hooks", accessToken, payload, &h); err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	s.logger.Info("Created webhook for Gitea repository",
+		zap.String("repo", owner+"/"+repo),
+		zap.Int64("hook_id", h.ID),
+	)
+	result := h.webhook()
+	return &result, nil
+}
+
+// ListWebhooks lists a repository's webhooks
+func (s *Service) ListWebhooks(ctx context.Context, accessToken, owner, repo string) ([]gitprovider.Webhook, error) {
+	var hooks []hook
+	if err := s.doJSON(ctx, "GET", repoPath(owner, repo)+"/hooks", accessToken, nil, &hooks); err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+	result := make([]gitprovider.Webhook, len(hooks))
+	for i := range hooks {
+		result[i] = hooks[i].webhook()
+	}
+	return result, nil
+}
+
+// DeleteWebhook deletes a repository webhook. Hooks that no longer exist are treated as
+// deleted.
+func (s *Service) DeleteWebhook(ctx context.Context, accessToken, owner, repo string, hookID int64) error {
+	err := s.doJSON(ctx, "DELETE", fmt.Sprintf("%s/hooks/%d", repoPath(owner, repo), hookID), accessToken, nil, nil)
+	var status *statusError
+	if errors.As(err, &status) && status.code == http.StatusNotFound {
+		err = nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+
+	s.logger.Info("Deleted webhook from Gitea repository",
+		zap.String("repo", owner+"/"+repo),
+		zap.Int64("hook_id", hookID),
+	)
+	return nil
+}
+
+// VerifyWebhook checks a delivery's HMAC-SHA256 signature, sent hex-encoded in
+// X-Gitea-Signature (X-Forgejo-Signature on Forgejo), made with secret or the configured
+// webhook secret when it is empty
+func (s *Service) VerifyWebhook(header http.Header, payload []byte, secret string) bool {
+	secret = s.webhookSecret(secret)
+	if secret == "" {
+		return true // No secret configured, skip verification
+	}
+	signature := header.Get("X-Gitea-Signature")
+	if signature == "" {
+		signature = header.Get("X-Forgejo-Signature")
+	}
+	if signature == "" {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	expected := hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(signature), []byte(expected))
+}
+
+// webhookSecret returns secret, or the configured webhook secret when it is empty
+func (s *Service) webhookSecret(secret string) string {
+	if secret != "" {
+		return secret
+	}
+	return s.config.WebhookSecret
+}
+
+// ParsePushEvent parses push deliveries, named by X-Gitea-Event (X-Forgejo-Event on
+// Forgejo)
+func (s *Service) ParsePushEvent(header http.Header, payload []byte) (*gitprovider.PushEvent, error) {
+	event := header.Get("X-Gitea-Event")
+	if event == "" {
+		event = header.Get("X-Forgejo-Event")
+	}
+	if event != "push" {
+		return nil, nil
+	}
+
+	type commit struct {
+		ID      string `json:"id"`
+		Message string `json:"message"`
+		URL     string `json:"url"`
+		Author  struct {
+			Name string `json:"name"`
+		} `json:"author"`
+	}
+	var push struct {
+		Ref        string     `json:"ref"`
+		Before     string     `json:"before"`
+		After      string     `json:"after"`
+		Repository repository `json:"repository"`
+		Pusher     struct {
+			Login string `json:"login"`
+		} `json:"pusher"`
+		Commits    []commit `json:"commits"`
+		HeadCommit *commit  `json:"head_commit"` // missing on older Gitea releases
+		CompareURL string   `json:"compare_url"`
+	}
+	if err := json.Unmarshal(payload, &push); err != nil {
+		return nil, fmt.Errorf("failed to parse push event: %w", err)
+	}
+
+	head := push.HeadCommit
+	commits := make([]gitprovider.Commit, 0, len(push.Commits))
+	for i, c := range push.Commits {
+		if head == nil && c.ID == push.After {
+			head = &push.Commits[i]
+		}
+		commits = append(commits, gitprovider.Commit{ID: c.ID, Message: c.Message, URL: c.URL, Author: c.Author.Name})
+	}
+	if len(commits) > 1 && commits[0].ID == push.After {
+		slices.Reverse(commits) // Gitea lists the newest first
+	}
+	result := &gitprovider.PushEvent{
+		Ref:        push.Ref,
+		Before:     push.Before,
+		After:      push.After,
+		Repository: push.Repository.FullName,
+		CloneURL:   push.Repository.CloneURL,
+		Pusher:     push.Pusher.Login,
+		HeadCommit: gitprovider.Commit{ID: push.After},
+		Commits:    commits,
+		CompareURL: push.CompareURL,
+	}
+	if head != nil {
+		result.HeadCommit = gitprovider.Commit{ID: head.ID, Message: head.Message, URL: head.URL, Author: head.Author.Name}
+	}
+	return result, nil
+}
+
+// AuthenticatedCloneURL adds an access token to a clone URL. Gitea accepts tokens as
+// the basic auth username.
+func (s *Service) AuthenticatedCloneURL(cloneURL, accessToken string) (string, error) {
+	u, err := url.Parse(cloneURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return "", fmt.Errorf("invalid clone URL %q: must be an http or https URL", cloneURL)
+	}
+	u.User = url.User(accessToken)
+	return u.String(), nil
+}
+
+// repoPath returns the API path of a repository
+func repoPath(owner, repo string) string {
+	return "/repos/" + url.PathEscape(owner) + "/" + url.PathEscape(repo)
+}
+
+// statusError is an unexpected response status from the Gitea API
+type statusError struct {
+	code int
+	body string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("gitea returned status %d: %s", e.code, e.body)
+}
+
+// doJSON makes a Gitea API request to a path under /api/v1 with an access token,
+// sending body and decoding the response into out as JSON
+func (s *Service) doJSON(ctx context.Context, method, path, token string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, s.config.BaseURL+"/api/v1"+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "token "+token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return &statusError{code: resp.StatusCode, body: string(respBody)}
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+var _ gitprovider.Provider = (*Service)(nil)
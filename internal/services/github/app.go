@@ -0,0 +1,370 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+)
+
+// ErrAppNotConfigured is returned by GitHub App calls when no App is set up
+var ErrAppNotConfigured = errors.New("GitHub App is not configured")
+
+// ErrNotInstalled is returned when the GitHub App is not installed on a repository
+var ErrNotInstalled = errors.New("GitHub App is not installed on this repository")
+
+// Installation tokens live an hour; they are renewed once less than
+// installationTokenMargin is left. A repository's installation is looked up again
+// after repoInstallationTTL, so installs and uninstalls are picked up.
+const (
+	installationTokenMargin = 5 * time.Minute
+	repoInstallationTTL     = 10 * time.Minute
+)
+
+// AppConfig holds GitHub App credentials
+type AppConfig struct {
+	ID         int64
+	Slug       string // the App's URL name, for its install link
+	PrivateKey []byte // PEM private key generated in the App's settings
+}
+
+// Installation represents an installation of the GitHub App on a user or organization
+type Installation struct {
+	ID      int64 `json:"id"`
+	Account struct {
+		Login     string `json:"login"`
+		Type      string `json:"type"` // User or Organization
+		AvatarURL string `json:"avatar_url"`
+	} `json:"account"`
+	RepositorySelection string    `json:"repository_selection"` // all or selected
+	HTMLURL             string    `json:"html_url"`
+	CreatedAt           time.Time `json:"created_at"`
+}
+
+// AppWebhookConfig is where GitHub delivers the App's webhooks, for every repository
+// it is installed on
+type AppWebhookConfig struct {
+	URL         string `json:"url"`
+	ContentType string `json:"content_type"`
+	Secret      string `json:"secret,omitempty"`
+	InsecureSSL string `json:"insecure_ssl"`
+}
+
+// githubApp holds the App's key and the installation tokens minted with it
+type githubApp struct {
+	id   int64
+	slug string
+	key  *rsa.PrivateKey
+
+	mu     sync.Mutex
+	tokens map[int64]installationToken
+	repos  map[string]repoInstallation // keyed by lowercase owner/repo
+}
+
+// installationToken is a cached installation access token
+type installationToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// repoInstallation is a cached lookup of the installation covering a repository
+type repoInstallation struct {
+	id        int64 // 0 when the App is not installed
+	checkedAt time.Time
+}
+
+// SetApp enables GitHub App authentication. Repository calls and clones then use
+// short-lived installation tokens instead of users' OAuth tokens.
+func (s *Service) SetApp(config AppConfig) error {
+	key, err := jwt.ParseRSAPrivateKeyFromPEM(config.PrivateKey)
+	if err != nil {
+		return fmt.Errorf("failed to parse GitHub App private key: %w", err)
+	}
+	s.app = &githubApp{
+		id:     config.ID,
+		slug:   config.Slug,
+		key:    key,
+		tokens: make(map[int64]installationToken),
+		repos:  make(map[string]repoInstallation),
+	}
+	s.logger.Info("GitHub App configured", zap.Int64("app_id", config.ID))
+	return nil
+}
+
+// AppConfigured reports whether a GitHub App is set up
+func (s *Service) AppConfigured() bool {
+	return s.app != nil
+}
+
+// InstallURL returns the page users install the GitHub App from, or "" when the App's
+// slug is not configured
+func (s *Service) InstallURL() string {
+	if s.app == nil || s.app.slug == "" {
+		return ""
+	}
+	return "https://github.com/apps/" + s.app.slug + "/installations/new"
+}
+
+// ListInstallations lists the accounts the GitHub App is installed on
+func (s *Service) ListInstallations(ctx context.Context) ([]Installation, error) {
+	token, err := s.appJWT()
+	if err != nil {
+		return nil, err
+	}
+	var installations []Installation
+	if err := s.doJSON(ctx, "GET", "https://api.github.com/app/installations?per_page=100", token, nil, &installations); err != nil {
+		return nil, fmt.Errorf("failed to list installations: %w", err)
+	}
+	return installations, nil
+}
+
+// ListInstallationRepositories lists a page of the repositories an installation can
+// access, and how many there are
+func (s *Service) ListInstallationRepositories(ctx context.Context, installationID int64, page, perPage int) ([]Repository, int, error) {
+	if perPage <= 0 {
+		perPage = 30
+	}
+	if page <= 0 {
+		page = 1
+	}
+	token, err := s.InstallationToken(ctx, installationID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var result struct {
+		TotalCount   int          `json:"total_count"`
+		Repositories []Repository `json:"repositories"`
+	}
+	url := fmt.Sprintf("https://api.github.com/installation/repositories?per_page=%d&page=%d", perPage, page)
+	if err := s.doJSON(ctx, "GET", url, token, nil, &result); err != nil {
+		return nil, 0, fmt.Errorf("failed to list installation repositories: %w", err)
+	}
+	return result.Repositories, result.TotalCount, nil
+}
+
+// InstallationToken returns an access token for an installation, minting a new one
+// when the cached token is close to expiring
+func (s *Service) InstallationToken(ctx context.Context, installationID int64) (string, error) {
+	if s.app == nil {
+		return "", ErrAppNotConfigured
+	}
+	s.app.mu.Lock()
+	cached, ok := s.app.tokens[installationID]
+	s.app.mu.Unlock()
+	if ok && time.Until(cached.ExpiresAt) > installationTokenMargin {
+		return cached.Token, nil
+	}
+
+	appToken, err := s.appJWT()
+	if err != nil {
+		return "", err
+	}
+	var token installationToken
+	url := fmt.Sprintf("https://api.github.com/app/installations/%d/access_tokens", installationID)
+	if err := s.doJSON(ctx, "POST", url, appToken, nil, &token); err != nil {
+		return "", fmt.Errorf("failed to create installation token: %w", err)
+	}
+
+	s.app.mu.Lock()
+	s.app.tokens[installationID] = token
+	s.app.mu.Unlock()
+	s.logger.Debug("Minted GitHub App installation token", zap.Int64("installation_id", installationID))
+	return token.Token, nil
+}
+
+// RepoToken returns an installation token that can access a repository, or
+// ErrNotInstalled when the App is not installed on it
+func (s *Service) RepoToken(ctx context.Context, owner, repo string) (string, error) {
+	id, err := s.repoInstallation(ctx, owner, repo)
+	if err != nil {
+		return "", err
+	}
+	return s.InstallationToken(ctx, id)
+}
+
+// AuthenticatedCloneURL adds installation token credentials to the HTTPS clone URL of a
+// GitHub repository the App is installed on. Other URLs, and all URLs when no App is
+// configured, are returned unchanged.
+func (s *Service) AuthenticatedCloneURL(ctx context.Context, cloneURL string) (string, error) {
+	if s.app == nil {
+		return cloneURL, nil
+	}
+	u, err := url.Parse(cloneURL)
+	if err != nil || u.Scheme != "https" || !strings.EqualFold(u.Host, "github.com") || u.User != nil {
+		return cloneURL, nil
+	}
+	parts := strings.Split(strings.Trim(strings.TrimSuffix(u.Path, ".git"), "/"), "/")
+	if len(parts) != 2 {
+		return cloneURL, nil
+	}
+
+	token, err := s.RepoToken(ctx, parts[0], parts[1])
+	if errors.Is(err, ErrNotInstalled) {
+		return cloneURL, nil // public repositories clone without credentials
+	}
+	if err != nil {
+		return "", err
+	}
+	u.User = url.UserPassword("x-access-token", token)
+	return u.String(), nil
+}
+
+// GetAppWebhookConfig returns where the App's webhooks are delivered. GitHub never
+// returns the secret.
+func (s *Service) GetAppWebhookConfig(ctx context.Context) (*AppWebhookConfig, error) {
+	token, err := s.appJWT()
+	if err != nil {
+		return nil, err
+	}
+	var config AppWebhookConfig
+	if err := s.doJSON(ctx, "GET", "https://api.github.com/app/hook/config", token, nil, &config); err != nil {
+		return nil, fmt.Errorf("failed to get App webhook config: %w", err)
+	}
+	return &config, nil
+}
+
+// UpdateAppWebhookConfig points the App's webhooks at webhookURL, signed with the
+// configured webhook secret so deliveries verify
+func (s *Service) UpdateAppWebhookConfig(ctx context.Context, webhookURL string) (*AppWebhookConfig, error) {
+	token, err := s.appJWT()
+	if err != nil {
+		return nil, err
+	}
+	update := AppWebhookConfig{
+		URL:         webhookURL,
+		ContentType: "json",
+		Secret:      s.config.WebhookSecret,
+		InsecureSSL: "0",
+	}
+	var config AppWebhookConfig
+	if err := s.doJSON(ctx, "PATCH", "https://api.github.com/app/hook/config", token, update, &config); err != nil {
+		return nil, fmt.Errorf("failed to update App webhook config: %w", err)
+	}
+	s.logger.Info("Updated GitHub App webhook", zap.String("url", webhookURL))
+	return &config, nil
+}
+
+// repoInstallation returns the ID of the installation covering a repository
+func (s *Service) repoInstallation(ctx context.Context, owner, repo string) (int64, error) {
+	if s.app == nil {
+		return 0, ErrAppNotConfigured
+	}
+	key := strings.ToLower(owner + "/" + repo)
+	s.app.mu.Lock()
+	cached, ok := s.app.repos[key]
+	s.app.mu.Unlock()
+	if ok && time.Since(cached.checkedAt) < repoInstallationTTL {
+		if cached.id == 0 {
+			return 0, ErrNotInstalled
+		}
+		return cached.id, nil
+	}
+
+	token, err := s.appJWT()
+	if err != nil {
+		return 0, err
+	}
+	var installation Installation
+	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/installation", owner, repo)
+	err = s.doJSON(ctx, "GET", url, token, nil, &installation)
+	var status *statusError
+	if errors.As(err, &status) && status.code == http.StatusNotFound {
+		installation.ID, err = 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up installation: %w", err)
+	}
+
+	s.app.mu.Lock()
+	s.app.repos[key] = repoInstallation{id: installation.ID, checkedAt: time.Now()}
+	s.app.mu.Unlock()
+	if installation.ID == 0 {
+		return 0, ErrNotInstalled
+	}
+	return installation.ID, nil
+}
+
+// appJWT signs the short-lived JWT the App authenticates as itself with. It is
+// backdated a minute to allow for clock drift, and GitHub rejects expiries over ten
+// minutes away.
+func (s *Service) appJWT() (string, error) {
+	if s.app == nil {
+		return "", ErrAppNotConfigured
+	}
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.RegisteredClaims{
+		Issuer:    strconv.FormatInt(s.app.id, 10),
+		IssuedAt:  jwt.NewNumericDate(now.Add(-time.Minute)),
+		ExpiresAt: jwt.NewNumericDate(now.Add(9 * time.Minute)),
+	})
+	signed, err := token.SignedString(s.app.key)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign GitHub App JWT: %w", err)
+	}
+	return signed, nil
+}
+
+// statusError is an unexpected response status from the GitHub API
+type statusError struct {
+	code int
+	body string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("github returned status %d: %s", e.code, e.body)
+}
+
+// doJSON makes a GitHub API request with a bearer token, sending body and decoding the
+// response into out as JSON
+func (s *Service) doJSON(ctx context.Context, method, url, token string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return &statusError{code: resp.StatusCode, body: string(respBody)}
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package github
+
+import (
+	"context"
+	"fmt"
+)
+
+// States of a commit's status checks, as returned by CommitChecks
+const (
+	CheckPending = "pending"
+	CheckSuccess = "success"
+	CheckFailure = "failure"
+)
+
+// CommitOnBranch reports whether sha is on branch: its head or one of its ancestors.
+// Commits a force-push has dropped from the branch are not.
+func (s *Service) CommitOnBranch(ctx context.Context, accessToken, owner, repo, branch, sha string) (bool, error) {
+	var comparison struct {
+		Status string `json:"status"` // of sha relative to branch: identical, behind, ahead or diverged
+	}
+	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/compare/%s...%s", owner, repo, branch, sha)
+	if err := s.doJSON(ctx, "GET", url, accessToken, nil, &comparison); err != nil {
+		return false, fmt.Errorf("failed to compare commits: %w", err)
+	}
+	return comparison.Status == "identical" || comparison.Status == "behind", nil
+}
+
+// RequiredStatusChecks returns the status checks branch protection requires to pass on
+// branch. It returns none for unprotected branches.
+func (s *Service) RequiredStatusChecks(ctx context.Context, accessToken, owner, repo, branch string) ([]string, error) {
+	var b struct {
+		Protection struct {
+			RequiredStatusChecks struct {
+				Contexts []string `json:"contexts"`
+				Checks   []struct {
+					Context string `json:"context"`
+				} `json:"checks"`
+			} `json:"required_status_checks"`
+		} `json:"protection"`
+	}
+	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/branches/%s", owner, repo, branch)
+	if err := s.doJSON(ctx, "GET", url, accessToken, nil, &b); err != nil {
+		return nil, fmt.Errorf("failed to get branch protection: %w", err)
+	}
+
+	required := b.Protection.RequiredStatusChecks
+	seen := make(map[string]bool)
+	var checks []string
+	for _, name := range required.Contexts {
+		if !seen[name] {
+			seen[name] = true
+			checks = append(checks, name)
+		}
+	}
+	for _, check := range required.Checks {
+		if !seen[check.Context] {
+			seen[check.Context] = true
+			checks = append(checks, check.Context)
+		}
+	}
+	return checks, nil
+}
+
+// CommitChecks returns the state of each commit status and check run reported on sha,
+// by status context or check run name. A name reported by both takes the worse state.
+func (s *Service) CommitChecks(ctx context.Context, accessToken, owner, repo, sha string) (map[string]string, error) {
+	var combined struct {
+		Statuses []struct {
+			Context string `json:"context"`
+			State   string `json:"state"` // error, failure, pending or success
+		} `json:"statuses"`
+	}
+	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/commits/%s/status?per_page=100", owner, repo, sha)
+	if err := s.doJSON(ctx, "GET", url, accessToken, nil, &combined); err != nil {
+		return nil, fmt.Errorf("failed to get commit statuses: %w", err)
+	}
+
+	var runs struct {
+		CheckRuns []struct {
+			Name       string `json:"name"`
+			Status     string `json:"status"`     // queued, in_progress or completed
+			Conclusion string `json:"conclusion"` // set once completed
+		} `json:"check_runs"`
+	}
+	url = fmt.Sprintf("https://api.github.com/repos/%s/%s/commits/%s/check-runs?per_page=100", owner, repo, sha)
+	if err := s.doJSON(ctx, "GET", url, accessToken, nil, &runs); err != nil {
+		return nil, fmt.Errorf("failed to get check runs: %w", err)
+	}
+
+	checks := make(map[string]string)
+	record := func(name, state string) {
+		if current, ok := checks[name]; !ok || checkSeverity[state] > checkSeverity[current] {
+			checks[name] = state
+		}
+	}
+	for _, status := range combined.Statuses {
+		switch status.State {
+		case "success":
+			record(status.Context, CheckSuccess)
+		case "pending":
+			record(status.Context, CheckPending)
+		default:
+			record(status.Context, CheckFailure)
+		}
+	}
+	for _, run := range runs.CheckRuns {
+		switch {
+		case run.Status != "completed":
+			record(run.Name, CheckPending)
+		case run.Conclusion == "success" || run.Conclusion == "neutral" || run.Conclusion == "skipped":
+			record(run.Name, CheckSuccess)
+		default:
+			record(run.Name, CheckFailure)
+		}
+	}
+	return checks, nil
+}
+
+// checkSeverity orders check states from best to worst
+var checkSeverity = map[string]int{CheckSuccess: 0, CheckPending: 1, CheckFailure: 2}
@@ -0,0 +1,74 @@
+package github
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// Deployment states reported to GitHub
+const (
+	DeploymentPending    = "pending"
+	DeploymentInProgress = "in_progress"
+	DeploymentSuccess    = "success"
+	DeploymentFailure    = "failure"
+	DeploymentError      = "error"
+)
+
+// maxStatusDescription is the longest deployment status description GitHub accepts
+const maxStatusDescription = 140
+
+// CreateDeployment records a deployment of ref to environment on a repository and
+// returns its ID. Commit status checks are not required, since NanoPaaS deploys what
+// it built.
+func (s *Service) CreateDeployment(ctx context.Context, accessToken, owner, repo, ref, environment, description string) (int64, error) {
+	payload := map[string]interface{}{
+		"ref":               ref,
+		"environment":       environment,
+		"description":       truncateDescription(description),
+		"auto_merge":        false,
+		"required_contexts": []string{},
+	}
+	var deployment struct {
+		ID int64 `json:"id"`
+	}
+	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/deployments", owner, repo)
+	if err := s.doJSON(ctx, "POST", url, accessToken, payload, &deployment); err != nil {
+		return 0, fmt.Errorf("failed to create deployment: %w", err)
+	}
+
+	s.logger.Debug("Created GitHub deployment",
+		zap.String("repo", fmt.Sprintf("%s/%s", owner, repo)),
+		zap.String("environment", environment),
+		zap.Int64("deployment_id", deployment.ID),
+	)
+	return deployment.ID, nil
+}
+
+// CreateDeploymentStatus sets the state of a deployment. environmentURL is shown on the
+// repository's Environments tab and may be empty. A successful deployment marks earlier
+// ones to the same environment inactive.
+func (s *Service) CreateDeploymentStatus(ctx context.Context, accessToken, owner, repo string, deploymentID int64, state, environmentURL, description string) error {
+	payload := map[string]interface{}{
+		"state":         state,
+		"description":   truncateDescription(description),
+		"auto_inactive": true,
+	}
+	if environmentURL != "" {
+		payload["environment_url"] = environmentURL
+	}
+	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/deployments/%d/statuses", owner, repo, deploymentID)
+	if err := s.doJSON(ctx, "POST", url, accessToken, payload, nil); err != nil {
+		return fmt.Errorf("failed to create deployment status: %w", err)
+	}
+	return nil
+}
+
+// truncateDescription shortens a description to the length GitHub accepts
+func truncateDescription(description string) string {
+	if len(description) <= maxStatusDescription {
+		return description
+	}
+	return description[:maxStatusDescription-3] + "..."
+}
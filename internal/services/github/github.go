@@ -6,6 +6,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -92,6 +93,12 @@ type Service struct {
 	config     Config
 	httpClient *http.Client
 	logger     *zap.Logger
+
+	// GitHub App credentials, set by SetApp
+	app *githubApp
+
+	// Quotas reported by GitHub's responses
+	limits rateLimiter
 }
 
 // NewService creates a new GitHub service
@@ -105,6 +112,11 @@ func NewService(config Config, logger *zap.Logger) *Service {
 	}
 }
 
+// OAuthConfigured reports whether GitHub sign-in has client credentials
+func (s *Service) OAuthConfigured() bool {
+	return s.config.ClientID != "" && s.config.ClientSecret != ""
+}
+
 // GetAuthURL returns the GitHub OAuth authorization URL
 func (s *Service) GetAuthURL(state string) string {
 	params := url.Values{
@@ -133,7 +145,7 @@ func (s *Service) ExchangeCode(ctx context.Context, code string) (*AccessTokenRe
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Set("Accept", "application/json")
 
-	resp, err := s.httpClient.Do(req)
+	resp, err := s.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to exchange code: %w", err)
 	}
@@ -164,7 +176,7 @@ func (s *Service) GetUser(ctx context.Context, accessToken string) (*User, error
 	req.Header.Set("Accept", "application/vnd.github+json")
 	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
 
-	resp, err := s.httpClient.Do(req)
+	resp, err := s.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch user: %w", err)
 	}
@@ -202,7 +214,7 @@ func (s *Service) GetPrimaryEmail(ctx context.Context, accessToken string) (stri
 	req.Header.Set("Authorization", "Bearer "+accessToken)
 	req.Header.Set("Accept", "application/vnd.github+json")
 
-	resp, err := s.httpClient.Do(req)
+	resp, err := s.do(req)
 	if err != nil {
 		return "", fmt.Errorf("failed to fetch emails: %w", err)
 	}
@@ -246,7 +258,7 @@ func (s *Service) ListRepositories(ctx context.Context, accessToken string, page
 	req.Header.Set("Accept", "application/vnd.github+json")
 	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
 
-	resp, err := s.httpClient.Do(req)
+	resp, err := s.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch repos: %w", err)
 	}
@@ -266,6 +278,60 @@ func (s *Service) ListRepositories(ctx context.Context, accessToken string, page
 	return repos, nil
 }
 
+// maxRepositoryPages bounds how many pages ListAllRepositories follows, 100 repositories
+// each
+const maxRepositoryPages = 20
+
+// ListAllRepositories lists every repository accessible to the user, following the
+// Link headers of GitHub's pages
+func (s *Service) ListAllRepositories(ctx context.Context, accessToken string) ([]Repository, error) {
+	var all []Repository
+	next := "https://api.github.com/user/repos?sort=updated&per_page=100"
+	for page := 0; next != "" && page < maxRepositoryPages; page++ {
+		req, err := http.NewRequestWithContext(ctx, "GET", next, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		req.Header.Set("Accept", "application/vnd.github+json")
+		req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+
+		resp, err := s.do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch repos: %w", err)
+		}
+		var repos []Repository
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			return nil, fmt.Errorf("github returned status %d: %s", resp.StatusCode, string(body))
+		}
+		err = json.NewDecoder(resp.Body).Decode(&repos)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode repos: %w", err)
+		}
+
+		all = append(all, repos...)
+		next = nextPageURL(resp.Header.Get("Link"))
+	}
+
+	s.logger.Debug("Fetched all repositories", zap.Int("count", len(all)))
+	return all, nil
+}
+
+// nextPageURL returns the rel="next" URL of a Link header, or ""
+func nextPageURL(link string) string {
+	for _, part := range strings.Split(link, ",") {
+		target, params, ok := strings.Cut(part, ";")
+		if !ok || !strings.Contains(params, `rel="next"`) {
+			continue
+		}
+		return strings.Trim(strings.TrimSpace(target), "<>")
+	}
+	return ""
+}
+
 // GetRepository fetches a specific repository
 func (s *Service) GetRepository(ctx context.Context, accessToken, owner, repo string) (*Repository, error) {
 	url := fmt.Sprintf("https://api.github.com/repos/%s/%s", owner, repo)
@@ -277,7 +343,7 @@ func (s *Service) GetRepository(ctx context.Context, accessToken, owner, repo st
 	req.Header.Set("Authorization", "Bearer "+accessToken)
 	req.Header.Set("Accept", "application/vnd.github+json")
 
-	resp, err := s.httpClient.Do(req)
+	resp, err := s.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch repo: %w", err)
 	}
@@ -318,7 +384,7 @@ func (s *Service) ListBranches(ctx context.Context, accessToken, owner, repo str
 	req.Header.Set("Accept", "application/vnd.github+json")
 	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
 
-	resp, err := s.httpClient.Do(req)
+	resp, err := s.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch branches: %w", err)
 	}
@@ -338,56 +404,109 @@ func (s *Service) ListBranches(ctx context.Context, accessToken, owner, repo str
 	return branches, nil
 }
 
-// CreateWebhook creates a webhook for a repository
-func (s *Service) CreateWebhook(ctx context.Context, accessToken, owner, repo, webhookURL string) error {
+// Webhook represents a repository webhook
+type Webhook struct {
+	ID     int64    `json:"id"`
+	Name   string   `json:"name"`
+	Active bool     `json:"active"`
+	Events []string `json:"events"`
+	Config struct {
+		URL         string `json:"url"`
+		ContentType string `json:"content_type"`
+	} `json:"config"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CreateWebhook creates a webhook for a repository, subscribed to pushes and releases.
+// Deliveries are signed with secret, or the configured webhook secret when it is empty.
+func (s *Service) CreateWebhook(ctx context.Context, accessToken, owner, repo, webhookURL, secret string) (*Webhook, error) {
 	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/hooks", owner, repo)
 
 	payload := map[string]interface{}{
 		"name":   "web",
 		"active": true,
-		"events": []string{"push"},
+		"events": []string{"push", "release"},
 		"config": map[string]interface{}{
 			"url":          webhookURL,
 			"content_type": "json",
-			"secret":       s.config.WebhookSecret,
+			"secret":       s.webhookSecret(secret),
 			"insecure_ssl": "0",
 		},
 	}
 
 	body, err := json.Marshal(payload)
 	if err != nil {
-		return fmt.Errorf("failed to marshal webhook config: %w", err)
+		return nil, fmt.Errorf("failed to marshal webhook config: %w", err)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "POST", url, strings.NewReader(string(body)))
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Authorization", "Bearer "+accessToken)
 	req.Header.Set("Accept", "application/vnd.github+json")
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := s.httpClient.Do(req)
+	resp, err := s.do(req)
 	if err != nil {
-		return fmt.Errorf("failed to create webhook: %w", err)
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
 		respBody, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("github returned status %d: %s", resp.StatusCode, string(respBody))
+		return nil, fmt.Errorf("github returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	var hook Webhook
+	if err := json.NewDecoder(resp.Body).Decode(&hook); err != nil {
+		return nil, fmt.Errorf("failed to decode webhook: %w", err)
 	}
 
 	s.logger.Info("Created webhook for repository",
 		zap.String("repo", fmt.Sprintf("%s/%s", owner, repo)),
+		zap.Int64("hook_id", hook.ID),
+	)
+	return &hook, nil
+}
+
+// ListWebhooks lists the webhooks of a repository
+func (s *Service) ListWebhooks(ctx context.Context, accessToken, owner, repo string) ([]Webhook, error) {
+	var hooks []Webhook
+	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/hooks?per_page=100", owner, repo)
+	if err := s.doJSON(ctx, "GET", url, accessToken, nil, &hooks); err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+	return hooks, nil
+}
+
+// DeleteWebhook deletes a repository webhook. Hooks that no longer exist are treated
+// as deleted.
+func (s *Service) DeleteWebhook(ctx context.Context, accessToken, owner, repo string, hookID int64) error {
+	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/hooks/%d", owner, repo, hookID)
+	err := s.doJSON(ctx, "DELETE", url, accessToken, nil, nil)
+	var status *statusError
+	if errors.As(err, &status) && status.code == http.StatusNotFound {
+		err = nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+
+	s.logger.Info("Deleted webhook from repository",
+		zap.String("repo", fmt.Sprintf("%s/%s", owner, repo)),
+		zap.Int64("hook_id", hookID),
 	)
 	return nil
 }
 
-// VerifyWebhookSignature verifies a GitHub webhook signature
-func (s *Service) VerifyWebhookSignature(payload []byte, signature string) bool {
-	if s.config.WebhookSecret == "" {
+// VerifyWebhookSignature verifies a GitHub webhook signature made with secret, or with
+// the configured webhook secret when it is empty
+func (s *Service) VerifyWebhookSignature(payload []byte, signature, secret string) bool {
+	secret = s.webhookSecret(secret)
+	if secret == "" {
 		return true // No secret configured, skip verification
 	}
 
@@ -395,13 +514,21 @@ func (s *Service) VerifyWebhookSignature(payload []byte, signature string) bool
 		return false
 	}
 
-	mac := hmac.New(sha256.New, []byte(s.config.WebhookSecret))
+	mac := hmac.New(sha256.New, []byte(secret))
 	mac.Write(payload)
 	expectedSignature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
 
 	return hmac.Equal([]byte(signature), []byte(expectedSignature))
 }
 
+// webhookSecret returns secret, or the configured webhook secret when it is empty
+func (s *Service) webhookSecret(secret string) string {
+	if secret != "" {
+		return secret
+	}
+	return s.config.WebhookSecret
+}
+
 // ParsePushEvent parses a push event payload
 func (s *Service) ParsePushEvent(payload []byte) (*PushEvent, error) {
 	var event PushEvent
@@ -0,0 +1,241 @@
+package github
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// maxRateLimitRetries bounds how often a rate limited request is retried
+	maxRateLimitRetries = 3
+
+	// maxRateLimitWait is the longest a request waits to be retried. Limits that reset
+	// later fail with a RateLimitError instead of holding the caller.
+	maxRateLimitWait = 30 * time.Second
+
+	// secondaryRateLimitBackoff is the first wait after a secondary rate limit that names
+	// no Retry-After, doubled on each retry
+	secondaryRateLimitBackoff = 5 * time.Second
+
+	// lowRateLimitFraction is the share of a quota left when a warning is logged
+	lowRateLimitFraction = 0.1
+)
+
+// RateLimit is the quota GitHub last reported for a resource, such as core or search
+type RateLimit struct {
+	Resource  string    `json:"resource"`
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	Used      int       `json:"used"`
+	Reset     time.Time `json:"reset"`
+}
+
+// RateLimitStats are the quotas GitHub last reported and how often it limited requests
+type RateLimitStats struct {
+	Resources []RateLimit // sorted by resource
+	Limited   int64       // responses that were rate limited
+	Retries   int64       // requests retried after a rate limit
+}
+
+// RateLimitError is returned when GitHub rate limited a request and it could not be
+// retried in time. Requests may be made again after Reset.
+type RateLimitError struct {
+	Reset     time.Time
+	Secondary bool // a secondary (abuse) limit rather than an exhausted quota
+	Resource  string
+}
+
+func (e *RateLimitError) Error() string {
+	kind := "rate limit"
+	if e.Secondary {
+		kind = "secondary rate limit"
+	}
+	return fmt.Sprintf("github %s exceeded; retry after %s", kind, e.Reset.UTC().Format(time.RFC3339))
+}
+
+// RetryAfter returns how long until the request may be made again
+func (e *RateLimitError) RetryAfter() time.Duration {
+	wait := time.Until(e.Reset)
+	if wait < time.Second {
+		return time.Second
+	}
+	return wait
+}
+
+// rateLimiter tracks the quotas reported in GitHub's X-RateLimit headers
+type rateLimiter struct {
+	mu        sync.Mutex
+	resources map[string]RateLimit
+	limited   int64
+	retries   int64
+}
+
+// RateLimitStats returns the quotas GitHub last reported, for metrics. Quotas are per
+// token, so they reflect whichever token made the latest request for each resource.
+func (s *Service) RateLimitStats() RateLimitStats {
+	s.limits.mu.Lock()
+	defer s.limits.mu.Unlock()
+	stats := RateLimitStats{Limited: s.limits.limited, Retries: s.limits.retries}
+	for _, limit := range s.limits.resources {
+		stats.Resources = append(stats.Resources, limit)
+	}
+	sort.Slice(stats.Resources, func(i, j int) bool {
+		return stats.Resources[i].Resource < stats.Resources[j].Resource
+	})
+	return stats
+}
+
+// do sends a GitHub request, recording the quota it reports. Rate limited requests are
+// retried with backoff while the limit resets within maxRateLimitWait; otherwise a
+// *RateLimitError is returned.
+func (s *Service) do(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := s.httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		s.recordRateLimit(resp.Header)
+
+		limitErr := rateLimitError(resp, attempt)
+		if limitErr == nil {
+			return resp, nil
+		}
+		resp.Body.Close()
+
+		s.limits.mu.Lock()
+		s.limits.limited++
+		s.limits.mu.Unlock()
+
+		wait := max(time.Until(limitErr.Reset), 0)
+		canRewind := req.Body == nil || req.GetBody != nil
+		if attempt >= maxRateLimitRetries || wait > maxRateLimitWait || !canRewind {
+			s.logger.Warn("GitHub rate limit exceeded",
+				zap.String("url", req.URL.Path),
+				zap.Bool("secondary", limitErr.Secondary),
+				zap.Time("reset", limitErr.Reset),
+			)
+			return nil, limitErr
+		}
+
+		s.logger.Info("GitHub rate limited request, retrying",
+			zap.String("url", req.URL.Path),
+			zap.Bool("secondary", limitErr.Secondary),
+			zap.Duration("wait", wait),
+			zap.Int("attempt", attempt+1),
+		)
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("failed to rewind request body: %w", err)
+			}
+			req.Body = body
+		}
+		s.limits.mu.Lock()
+		s.limits.retries++
+		s.limits.mu.Unlock()
+	}
+}
+
+// recordRateLimit stores the quota reported by a response's X-RateLimit headers and
+// warns once it runs low
+func (s *Service) recordRateLimit(header http.Header) {
+	limit, err := strconv.Atoi(header.Get("X-RateLimit-Limit"))
+	if err != nil || limit <= 0 {
+		return
+	}
+	remaining, _ := strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	used, _ := strconv.Atoi(header.Get("X-RateLimit-Used"))
+	resource := header.Get("X-RateLimit-Resource")
+	if resource == "" {
+		resource = "core"
+	}
+	current := RateLimit{
+		Resource:  resource,
+		Limit:     limit,
+		Remaining: remaining,
+		Used:      used,
+		Reset:     rateLimitReset(header),
+	}
+
+	s.limits.mu.Lock()
+	previous, seen := s.limits.resources[resource]
+	if s.limits.resources == nil {
+		s.limits.resources = make(map[string]RateLimit)
+	}
+	s.limits.resources[resource] = current
+	s.limits.mu.Unlock()
+
+	// Warn when the quota crosses into its last tenth, not on every request after
+	low := int(float64(limit) * lowRateLimitFraction)
+	if remaining < low && (!seen || previous.Remaining >= low || previous.Reset.Before(current.Reset)) {
+		s.logger.Warn("GitHub rate limit running low",
+			zap.String("resource", resource),
+			zap.Int("remaining", remaining),
+			zap.Int("limit", limit),
+			zap.Time("reset", current.Reset),
+		)
+	}
+}
+
+// rateLimitError returns the limit a response reports, or nil when it wasn't rate
+// limited. GitHub reports limits as 429, or as 403 with the quota exhausted, a
+// Retry-After header or a message naming the limit.
+func rateLimitError(resp *http.Response, attempt int) *RateLimitError {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return nil
+	}
+	// Keep the body readable for callers reporting other 403s
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	resource := resp.Header.Get("X-RateLimit-Resource")
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		return &RateLimitError{
+			Reset:     time.Now().Add(time.Duration(seconds) * time.Second),
+			Secondary: true,
+			Resource:  resource,
+		}
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		reset := rateLimitReset(resp.Header)
+		if reset.IsZero() {
+			reset = time.Now().Add(time.Minute)
+		}
+		return &RateLimitError{Reset: reset.Add(time.Second), Resource: resource}
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || strings.Contains(strings.ToLower(string(body)), "rate limit") {
+		return &RateLimitError{
+			Reset:     time.Now().Add(secondaryRateLimitBackoff << attempt),
+			Secondary: true,
+			Resource:  resource,
+		}
+	}
+	return nil
+}
+
+// rateLimitReset parses X-RateLimit-Reset, in Unix seconds
+func rateLimitReset(header http.Header) time.Time {
+	seconds, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0)
+}
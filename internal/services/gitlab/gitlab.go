@@ -0,0 +1,452 @@
+// Package gitlab lists repositories, installs push webhooks and clones from GitLab.com
+// or a self-managed GitLab instance.
+package gitlab
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+	"github.com/nanopaas/nanopaas/internal/services/gitprovider"
+)
+
+// DefaultBaseURL is GitLab.com
+const DefaultBaseURL = "https://gitlab.com"
+
+// Config holds GitLab configuration
+type Config struct {
+	BaseURL       string // GitLab.com when empty
+	ClientID      string
+	ClientSecret  string
+	RedirectURI   string
+	WebhookSecret string // sent back by GitLab in X-Gitlab-Token
+	Scopes        []string
+}
+
+// DefaultConfig returns default GitLab config
+func DefaultConfig() Config {
+	return Config{
+		BaseURL: DefaultBaseURL,
+		Scopes:  []string{"api", "read_user"},
+	}
+}
+
+// Service handles GitLab API interactions
+type Service struct {
+	config     Config
+	httpClient *http.Client
+	logger     *zap.Logger
+}
+
+// NewService creates a new GitLab service
+func NewService(config Config, logger *zap.Logger) *Service {
+	if config.BaseURL == "" {
+		config.BaseURL = DefaultBaseURL
+	}
+	config.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+	return &Service{
+		config: config,
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		logger: logger,
+	}
+}
+
+// Name returns "gitlab"
+func (s *Service) Name() string {
+	return domain.GitProviderGitLab
+}
+
+// OAuthConfigured reports whether users can connect GitLab by OAuth
+func (s *Service) OAuthConfigured() bool {
+	return s.config.ClientID != "" && s.config.ClientSecret != ""
+}
+
+// GetAuthURL returns the GitLab OAuth authorization URL
+func (s *Service) GetAuthURL(state string) string {
+	params := url.Values{
+		"client_id":     {s.config.ClientID},
+		"redirect_uri":  {s.config.RedirectURI},
+		"response_type": {"code"},
+		"scope":         {strings.Join(s.config.Scopes, " ")},
+		"state":         {state},
+	}
+	return s.config.BaseURL + "/oauth/authorize?" + params.Encode()
+}
+
+// ExchangeCode exchanges an authorization code for an access token
+func (s *Service) ExchangeCode(ctx context.Context, code string) (*gitprovider.Token, error) {
+	return s.requestToken(ctx, url.Values{
+		"grant_type": {"authorization_code"},
+		"code":       {code},
+	})
+}
+
+// RefreshToken exchanges a refresh token for a new access token. GitLab's OAuth access
+// tokens expire after two hours.
+func (s *Service) RefreshToken(ctx context.Context, refreshToken string) (*gitprovider.Token, error) {
+	return s.requestToken(ctx, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+	})
+}
+
+// requestToken makes an OAuth token request
+func (s *Service) requestToken(ctx context.Context, data url.Values) (*gitprovider.Token, error) {
+	data.Set("client_id", s.config.ClientID)
+	data.Set("client_secret", s.config.ClientSecret)
+	data.Set("redirect_uri", s.config.RedirectURI)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", s.config.BaseURL+"/oauth/token", strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &statusError{code: resp.StatusCode, body: string(body)}
+	}
+
+	var result struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode token response: %w", err)
+	}
+
+	token := &gitprovider.Token{AccessToken: result.AccessToken, RefreshToken: result.RefreshToken}
+	if result.ExpiresIn > 0 {
+		expiresAt := time.Now().UTC().Add(time.Duration(result.ExpiresIn) * time.Second)
+		token.ExpiresAt = &expiresAt
+	}
+	return token, nil
+}
+
+// GetUser fetches the token's user
+func (s *Service) GetUser(ctx context.Context, accessToken string) (*gitprovider.User, error) {
+	var user struct {
+		ID          int64  `json:"id"`
+		Username    string `json:"username"`
+		Name        string `json:"name"`
+		Email       string `json:"email"`
+		PublicEmail string `json:"public_email"`
+	}
+	if err := s.doJSON(ctx, "GET", "/user", accessToken, nil, &user); err != nil {
+		return nil, fmt.Errorf("failed to fetch user: %w", err)
+	}
+	if user.Email == "" {
+		user.Email = user.PublicEmail
+	}
+	return &gitprovider.User{ID: user.ID, Username: user.Username, Name: user.Name, Email: user.Email}, nil
+}
+
+// project is a GitLab project as the API returns it
+type project struct {
+	ID                int64     `json:"id"`
+	Name              string    `json:"name"`
+	PathWithNamespace string    `json:"path_with_namespace"`
+	Description       string    `json:"description"`
+	Visibility        string    `json:"visibility"`
+	WebURL            string    `json:"web_url"`
+	HTTPURLToRepo     string    `json:"http_url_to_repo"`
+	DefaultBranch     string    `json:"default_branch"`
+	LastActivityAt    time.Time `json:"last_activity_at"`
+}
+
+func (p *project) repository() gitprovider.Repository {
+	return gitprovider.Repository{
+		ID:            p.ID,
+		Name:          p.Name,
+		FullName:      p.PathWithNamespace,
+		Description:   p.Description,
+		Private:       p.Visibility != "public",
+		HTMLURL:       p.WebURL,
+		CloneURL:      p.HTTPURLToRepo,
+		DefaultBranch: p.DefaultBranch,
+		UpdatedAt:     p.LastActivityAt,
+	}
+}
+
+// ListRepositories lists the projects the user is a member of, most recently active
+// first
+func (s *Service) ListRepositories(ctx context.Context, accessToken string, page, perPage int) ([]gitprovider.Repository, error) {
+	if perPage <= 0 {
+		perPage = 30
+	}
+	if page <= 0 {
+		page = 1
+	}
+
+	var projects []project
+	path := fmt.Sprintf("/projects?membership=true&order_by=last_activity_at&per_page=%d&page=%d", perPage, page)
+	if err := s.doJSON(ctx, "GET", path, accessToken, nil, &projects); err != nil {
+		return nil, fmt.Errorf("failed to fetch projects: %w", err)
+	}
+
+	repos := make([]gitprovider.Repository, len(projects))
+	for i := range projects {
+		repos[i] = projects[i].repository()
+	}
+	s.logger.Debug("Fetched GitLab projects", zap.Int("count", len(repos)))
+	return repos, nil
+}
+
+// GetRepository fetches a project
+func (s *Service) GetRepository(ctx context.Context, accessToken, owner, repo string) (*gitprovider.Repository, error) {
+	var p project
+	if err := s.doJSON(ctx, "GET", projectPath(owner, repo), accessToken, nil, &p); err != nil {
+		return nil, fmt.Errorf("failed to fetch project: %w", err)
+	}
+	r := p.repository()
+	return &r, nil
+}
+
+// ListBranches lists a project's branches
+func (s *Service) ListBranches(ctx context.Context, accessToken, owner, repo string) ([]gitprovider.Branch, error) {
+	var branches []struct {
+		Name      string `json:"name"`
+		Protected bool   `json:"protected"`
+		Commit    struct {
+			ID string `json:"id"`
+		} `json:"commit"`
+	}
+	if err := s.doJSON(ctx, "GET", projectPath(owner, repo)+"/repository/branches?per_page=100", accessToken, nil, &branches); err != nil {
+		return nil, fmt.Errorf("failed to fetch branches: %w", err)
+	}
+
+	result := make([]gitprovider.Branch, len(branches))
+	for i, b := range branches {
+		result[i] = gitprovider.Branch{Name: b.Name, Protected: b.Protected, CommitSHA: b.Commit.ID}
+	}
+	return result, nil
+}
+
+// hook is a GitLab project hook as the API returns it
+type hook struct {
+	ID        int64     `json:"id"`
+	URL       string    `json:"url"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (h *hook) webhook() gitprovider.Webhook {
+	return gitprovider.Webhook{ID: h.ID, URL: h.URL, Active: true, CreatedAt: h.CreatedAt}
+}
+
+// CreateWebhook adds a push and tag push hook to a project, carrying secret (or the
+// configured webhook secret) for GitLab to send back in X-Gitlab-Token
+func (s *Service) CreateWebhook(ctx context.Context, accessToken, owner, repo, webhookURL, secret string) (*gitprovider.Webhook, error) {
+	payload := map[string]interface{}{
+		"url":                     webhookURL,
+		"push_events":             true,
+		"tag_push_events":         true,
+		"token":                   s.webhookSecret(secret),
+		"enable_ssl_verification": true,
+	}
+	var h hook
+	if err := s.doJSON(ctx, "POST", projectPath(owner, repo)+"/hooks", accessToken, payload, &h); err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	s.logger.Info("Created webhook for GitLab project",
+		zap.String("repo", owner+"/"+repo),
+		zap.Int64("hook_id", h.ID),
+	)
+	result := h.webhook()
+	return &result, nil
+}
+
+// ListWebhooks lists a project's hooks
+func (s *Service) ListWebhooks(ctx context.Context, accessToken, owner, repo string) ([]gitprovider.Webhook, error) {
+	var hooks []hook
+	if err := s.doJSON(ctx, "GET", projectPath(owner, repo)+"/hooks?per_page=100", accessToken, nil, &hooks); err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+	result := make([]gitprovider.Webhook, len(hooks))
+	for i := range hooks {
+		result[i] = hooks[i].webhook()
+	}
+	return result, nil
+}
+
+// DeleteWebhook deletes a project hook. Hooks that no longer exist are treated as
+// deleted.
+func (s *Service) DeleteWebhook(ctx context.Context, accessToken, owner, repo string, hookID int64) error {
+	err := s.doJSON(ctx, "DELETE", fmt.Sprintf("%s/hooks/%d", projectPath(owner, repo), hookID), accessToken, nil, nil)
+	var status *statusError
+	if errors.As(err, &status) && status.code == http.StatusNotFound {
+		err = nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+
+	s.logger.Info("Deleted webhook from GitLab project",
+		zap.String("repo", owner+"/"+repo),
+		zap.Int64("hook_id", hookID),
+	)
+	return nil
+}
+
+// VerifyWebhook checks that a delivery's X-Gitlab-Token is secret, or the configured
+// webhook secret when it is empty
+func (s *Service) VerifyWebhook(header http.Header, payload []byte, secret string) bool {
+	secret = s.webhookSecret(secret)
+	if secret == "" {
+		return true // No secret configured, skip verification
+	}
+	token := header.Get("X-Gitlab-Token")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1
+}
+
+// webhookSecret returns secret, or the configured webhook secret when it is empty
+func (s *Service) webhookSecret(secret string) string {
+	if secret != "" {
+		return secret
+	}
+	return s.config.WebhookSecret
+}
+
+// ParsePushEvent parses Push Hook and Tag Push Hook deliveries
+func (s *Service) ParsePushEvent(header http.Header, payload []byte) (*gitprovider.PushEvent, error) {
+	if event := header.Get("X-Gitlab-Event"); event != "Push Hook" && event != "Tag Push Hook" {
+		return nil, nil
+	}
+
+	var event struct {
+		Ref         string `json:"ref"`
+		Before      string `json:"before"`
+		After       string `json:"after"`
+		CheckoutSHA string `json:"checkout_sha"`
+		UserName    string `json:"user_name"`
+		Project     struct {
+			PathWithNamespace string `json:"path_with_namespace"`
+			GitHTTPURL        string `json:"git_http_url"`
+			WebURL            string `json:"web_url"`
+		} `json:"project"`
+		Commits []struct {
+			ID      string `json:"id"`
+			Message string `json:"message"`
+			URL     string `json:"url"`
+			Author  struct {
+				Name string `json:"name"`
+			} `json:"author"`
+		} `json:"commits"`
+	}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("failed to parse push event: %w", err)
+	}
+
+	push := &gitprovider.PushEvent{
+		Ref:        event.Ref,
+		Before:     event.Before,
+		After:      event.After,
+		Repository: event.Project.PathWithNamespace,
+		CloneURL:   event.Project.GitHTTPURL,
+		Pusher:     event.UserName,
+		HeadCommit: gitprovider.Commit{ID: event.CheckoutSHA},
+	}
+	if push.HeadCommit.ID == "" {
+		push.HeadCommit.ID = event.After
+	}
+	for _, c := range event.Commits {
+		commit := gitprovider.Commit{ID: c.ID, Message: c.Message, URL: c.URL, Author: c.Author.Name}
+		if c.ID == push.HeadCommit.ID {
+			push.HeadCommit = commit
+		}
+		push.Commits = append(push.Commits, commit)
+	}
+	if event.Project.WebURL != "" && strings.Trim(event.Before, "0") != "" && !push.Deleted() {
+		push.CompareURL = event.Project.WebURL + "/-/compare/" + event.Before + "..." + event.After
+	}
+	return push, nil
+}
+
+// AuthenticatedCloneURL adds an OAuth or personal access token to a clone URL
+func (s *Service) AuthenticatedCloneURL(cloneURL, accessToken string) (string, error) {
+	u, err := url.Parse(cloneURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return "", fmt.Errorf("invalid clone URL %q: must be an http or https URL", cloneURL)
+	}
+	u.User = url.UserPassword("oauth2", accessToken)
+	return u.String(), nil
+}
+
+// projectPath returns the API path of a project, addressed by its URL-encoded full path
+func projectPath(owner, repo string) string {
+	return "/projects/" + url.PathEscape(owner+"/"+repo)
+}
+
+// statusError is an unexpected response status from the GitLab API
+type statusError struct {
+	code int
+	body string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("gitlab returned status %d: %s", e.code, e.body)
+}
+
+// doJSON makes a GitLab API request to a path under /api/v4 with a bearer token,
+// sending body and decoding the response into out as JSON
+func (s *Service) doJSON(ctx context.Context, method, path, token string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, s.config.BaseURL+"/api/v4"+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return &statusError{code: resp.StatusCode, body: string(respBody)}
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+var _ gitprovider.OAuthProvider = (*Service)(nil)
@@ -0,0 +1,161 @@
+package gitprovider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+	"github.com/nanopaas/nanopaas/internal/services/github"
+)
+
+// GitHub adapts the GitHub service to the Provider interface
+type GitHub struct {
+	svc *github.Service
+}
+
+// NewGitHub creates a provider backed by the GitHub service
+func NewGitHub(svc *github.Service) *GitHub {
+	return &GitHub{svc: svc}
+}
+
+// Name returns "github"
+func (g *GitHub) Name() string {
+	return domain.GitProviderGitHub
+}
+
+// GetUser fetches the token's user
+func (g *GitHub) GetUser(ctx context.Context, accessToken string) (*User, error) {
+	u, err := g.svc.GetUser(ctx, accessToken)
+	if err != nil {
+		return nil, err
+	}
+	return &User{ID: u.ID, Username: u.Login, Name: u.Name, Email: u.Email}, nil
+}
+
+// ListRepositories lists the repositories the user can access
+func (g *GitHub) ListRepositories(ctx context.Context, accessToken string, page, perPage int) ([]Repository, error) {
+	repos, err := g.svc.ListRepositories(ctx, accessToken, page, perPage)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Repository, len(repos))
+	for i := range repos {
+		result[i] = fromGitHubRepository(&repos[i])
+	}
+	return result, nil
+}
+
+// GetRepository fetches a repository
+func (g *GitHub) GetRepository(ctx context.Context, accessToken, owner, repo string) (*Repository, error) {
+	r, err := g.svc.GetRepository(ctx, accessToken, owner, repo)
+	if err != nil {
+		return nil, err
+	}
+	result := fromGitHubRepository(r)
+	return &result, nil
+}
+
+// ListBranches lists a repository's branches
+func (g *GitHub) ListBranches(ctx context.Context, accessToken, owner, repo string) ([]Branch, error) {
+	branches, err := g.svc.ListBranches(ctx, accessToken, owner, repo)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Branch, len(branches))
+	for i, b := range branches {
+		result[i] = Branch{Name: b.Name, Protected: b.Protected, CommitSHA: b.Commit.SHA}
+	}
+	return result, nil
+}
+
+// CreateWebhook installs a push webhook signed with secret, or the GitHub webhook secret
+func (g *GitHub) CreateWebhook(ctx context.Context, accessToken, owner, repo, webhookURL, secret string) (*Webhook, error) {
+	hook, err := g.svc.CreateWebhook(ctx, accessToken, owner, repo, webhookURL, secret)
+	if err != nil {
+		return nil, err
+	}
+	result := fromGitHubWebhook(hook)
+	return &result, nil
+}
+
+// ListWebhooks lists a repository's webhooks
+func (g *GitHub) ListWebhooks(ctx context.Context, accessToken, owner, repo string) ([]Webhook, error) {
+	hooks, err := g.svc.ListWebhooks(ctx, accessToken, owner, repo)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Webhook, len(hooks))
+	for i := range hooks {
+		result[i] = fromGitHubWebhook(&hooks[i])
+	}
+	return result, nil
+}
+
+// DeleteWebhook deletes a repository webhook
+func (g *GitHub) DeleteWebhook(ctx context.Context, accessToken, owner, repo string, hookID int64) error {
+	return g.svc.DeleteWebhook(ctx, accessToken, owner, repo, hookID)
+}
+
+// VerifyWebhook checks the X-Hub-Signature-256 signature of a delivery
+func (g *GitHub) VerifyWebhook(header http.Header, payload []byte, secret string) bool {
+	return g.svc.VerifyWebhookSignature(payload, header.Get("X-Hub-Signature-256"), secret)
+}
+
+// ParsePushEvent parses push deliveries, named by X-GitHub-Event
+func (g *GitHub) ParsePushEvent(header http.Header, payload []byte) (*PushEvent, error) {
+	if header.Get("X-GitHub-Event") != "push" {
+		return nil, nil
+	}
+	event, err := g.svc.ParsePushEvent(payload)
+	if err != nil {
+		return nil, err
+	}
+	return &PushEvent{
+		Ref:        event.Ref,
+		Before:     event.Before,
+		After:      event.After,
+		Repository: event.Repository.FullName,
+		CloneURL:   event.Repository.CloneURL,
+		Pusher:     event.Pusher.Name,
+		HeadCommit: Commit{
+			ID:      event.HeadCommit.ID,
+			Message: event.HeadCommit.Message,
+			URL:     event.HeadCommit.URL,
+			Author:  event.HeadCommit.Author.Name,
+		},
+	}, nil
+}
+
+// AuthenticatedCloneURL adds an OAuth or installation token to a clone URL
+func (g *GitHub) AuthenticatedCloneURL(cloneURL, accessToken string) (string, error) {
+	u, err := url.Parse(cloneURL)
+	if err != nil || u.Scheme != "https" {
+		return "", fmt.Errorf("invalid clone URL %q: must be an https URL", cloneURL)
+	}
+	u.User = url.UserPassword("x-access-token", accessToken)
+	return u.String(), nil
+}
+
+// fromGitHubRepository converts a GitHub repository
+func fromGitHubRepository(r *github.Repository) Repository {
+	return Repository{
+		ID:            r.ID,
+		Name:          r.Name,
+		FullName:      r.FullName,
+		Description:   r.Description,
+		Private:       r.Private,
+		HTMLURL:       r.HTMLURL,
+		CloneURL:      r.CloneURL,
+		DefaultBranch: r.DefaultBranch,
+		UpdatedAt:     r.UpdatedAt,
+	}
+}
+
+// fromGitHubWebhook converts a GitHub webhook
+func fromGitHubWebhook(h *github.Webhook) Webhook {
+	return Webhook{ID: h.ID, URL: h.Config.URL, Active: h.Active, CreatedAt: h.CreatedAt}
+}
+
+var _ Provider = (*GitHub)(nil)
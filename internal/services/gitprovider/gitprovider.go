@@ -0,0 +1,233 @@
+// Package gitprovider abstracts the git hosts NanoPaaS lists repositories from, installs
+// push webhooks on and clones from, so apps can build from GitHub, GitLab and others.
+package gitprovider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// Errors returned by the registry
+var (
+	ErrUnknownProvider = errors.New("unknown git provider")
+	ErrNotConnected    = errors.New("git provider not connected")
+)
+
+// User is the account a token belongs to
+type User struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+}
+
+// Repository is a repository on a git provider
+type Repository struct {
+	ID            int64     `json:"id"`
+	Name          string    `json:"name"`
+	FullName      string    `json:"full_name"` // owner/name
+	Description   string    `json:"description"`
+	Private       bool      `json:"private"`
+	HTMLURL       string    `json:"html_url"`
+	CloneURL      string    `json:"clone_url"`
+	DefaultBranch string    `json:"default_branch"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// Branch is a branch of a repository
+type Branch struct {
+	Name      string `json:"name"`
+	Protected bool   `json:"protected"`
+	CommitSHA string `json:"commit_sha"`
+}
+
+// Webhook is a push webhook on a repository
+type Webhook struct {
+	ID        int64     `json:"id"`
+	URL       string    `json:"url"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Commit is the commit a push moved a branch to
+type Commit struct {
+	ID      string `json:"id"`
+	Message string `json:"message"`
+	URL     string `json:"url"`
+	Author  string `json:"author"`
+}
+
+// PushEvent is a push delivered by a provider's webhook
+type PushEvent struct {
+	Ref        string   `json:"ref"` // refs/heads/<branch> or refs/tags/<tag>
+	Before     string   `json:"before"`
+	After      string   `json:"after"`
+	Repository string   `json:"repository"` // owner/name
+	CloneURL   string   `json:"clone_url"`
+	Pusher     string   `json:"pusher"`
+	HeadCommit Commit   `json:"head_commit"`
+	Commits    []Commit `json:"commits,omitempty"`     // pushed commits, oldest first
+	CompareURL string   `json:"compare_url,omitempty"` // diff of before...after on the provider
+}
+
+// Deleted reports whether the push deleted its branch or tag, which providers send as
+// an all-zero after SHA
+func (e *PushEvent) Deleted() bool {
+	return e.After != "" && strings.Trim(e.After, "0") == ""
+}
+
+// Provider is a git host apps build from. Calls act as the owner of accessToken.
+type Provider interface {
+	// Name is the provider's name in app settings and API paths, like "gitlab"
+	Name() string
+
+	GetUser(ctx context.Context, accessToken string) (*User, error)
+	ListRepositories(ctx context.Context, accessToken string, page, perPage int) ([]Repository, error)
+	GetRepository(ctx context.Context, accessToken, owner, repo string) (*Repository, error)
+	ListBranches(ctx context.Context, accessToken, owner, repo string) ([]Branch, error)
+
+	// CreateWebhook installs a push webhook delivering to webhookURL, authenticated with
+	// secret so VerifyWebhook accepts its deliveries. An empty secret uses the
+	// provider's configured webhook secret.
+	CreateWebhook(ctx context.Context, accessToken, owner, repo, webhookURL, secret string) (*Webhook, error)
+	ListWebhooks(ctx context.Context, accessToken, owner, repo string) ([]Webhook, error)
+	DeleteWebhook(ctx context.Context, accessToken, owner, repo string, hookID int64) error
+
+	// VerifyWebhook reports whether a delivery came from a webhook created by
+	// CreateWebhook with secret, or with the configured secret when secret is empty
+	VerifyWebhook(header http.Header, payload []byte, secret string) bool
+
+	// ParsePushEvent parses a webhook delivery, returning nil for events other than
+	// pushes
+	ParsePushEvent(header http.Header, payload []byte) (*PushEvent, error)
+
+	// AuthenticatedCloneURL adds accessToken to the HTTPS clone URL of a repository
+	AuthenticatedCloneURL(cloneURL, accessToken string) (string, error)
+}
+
+// Token is an OAuth access token
+type Token struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    *time.Time // nil when the token doesn't expire
+}
+
+// OAuthProvider is a provider users connect to by OAuth rather than only with a personal
+// access token
+type OAuthProvider interface {
+	Provider
+
+	OAuthConfigured() bool
+	GetAuthURL(state string) string
+	ExchangeCode(ctx context.Context, code string) (*Token, error)
+	RefreshToken(ctx context.Context, refreshToken string) (*Token, error)
+}
+
+// ConnectionStore persists users' tokens for providers
+type ConnectionStore interface {
+	Get(ctx context.Context, userID uuid.UUID, provider string) (*domain.GitConnection, error)
+	Save(ctx context.Context, conn *domain.GitConnection) error
+}
+
+// Registry holds the configured providers and hands out users' tokens for them
+type Registry struct {
+	providers   map[string]Provider
+	connections ConnectionStore
+	logger      *zap.Logger
+
+	// Serializes refreshes, since providers invalidate a refresh token once it is used
+	refreshMu sync.Mutex
+}
+
+// NewRegistry creates a registry reading tokens from connections
+func NewRegistry(connections ConnectionStore, logger *zap.Logger) *Registry {
+	return &Registry{
+		providers:   make(map[string]Provider),
+		connections: connections,
+		logger:      logger,
+	}
+}
+
+// Register adds a provider, replacing any with the same name
+func (r *Registry) Register(p Provider) {
+	r.providers[p.Name()] = p
+}
+
+// Get returns the provider with a name
+func (r *Registry) Get(name string) (Provider, error) {
+	p, ok := r.providers[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownProvider, name)
+	}
+	return p, nil
+}
+
+// Names returns the names of the registered providers, sorted
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Token returns a user's access token for a provider, refreshing an expired OAuth
+// token. It returns ErrNotConnected when the user has no connection to the provider.
+func (r *Registry) Token(ctx context.Context, userID uuid.UUID, name string) (string, error) {
+	conn, err := r.connections.Get(ctx, userID, name)
+	if errors.Is(err, domain.ErrNotFound) {
+		return "", ErrNotConnected
+	}
+	if err != nil {
+		return "", err
+	}
+	if !conn.Expired() {
+		return conn.Token, nil
+	}
+
+	p, err := r.Get(name)
+	if err != nil {
+		return "", err
+	}
+	oauth, ok := p.(OAuthProvider)
+	if !ok || conn.RefreshToken == "" {
+		return "", fmt.Errorf("%w: token expired", ErrNotConnected)
+	}
+
+	r.refreshMu.Lock()
+	defer r.refreshMu.Unlock()
+	// Another request may have refreshed the token while this one waited
+	if conn, err = r.connections.Get(ctx, userID, name); err != nil {
+		return "", err
+	}
+	if !conn.Expired() {
+		return conn.Token, nil
+	}
+
+	token, err := oauth.RefreshToken(ctx, conn.RefreshToken)
+	if err != nil {
+		return "", fmt.Errorf("failed to refresh %s token: %w", name, err)
+	}
+	conn.Token = token.AccessToken
+	conn.RefreshToken = token.RefreshToken
+	conn.ExpiresAt = token.ExpiresAt
+	conn.UpdatedAt = time.Now().UTC()
+	if err := r.connections.Save(ctx, conn); err != nil {
+		return "", err
+	}
+
+	r.logger.Debug("Refreshed git provider token", zap.String("provider", name), zap.String("user_id", userID.String()))
+	return conn.Token, nil
+}
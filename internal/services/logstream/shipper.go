@@ -0,0 +1,376 @@
+package logstream
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+	"github.com/nanopaas/nanopaas/internal/infrastructure/docker"
+)
+
+const (
+	// How often lines are flushed to the store, and the batch size that flushes early
+	shipFlushInterval = time.Second
+	shipBatchSize     = 500
+	// Lines waiting to be stored; lines are dropped when the store falls this far behind
+	shipBuffer = 10000
+	// How often partitions are created ahead and expired ones dropped
+	shipMaintenanceInterval = time.Hour
+	// Attempts at storing a batch before it is split to find the lines the store rejects,
+	// and the wait before the first retry, doubling after each
+	shipInsertAttempts = 3
+	shipRetryDelay     = time.Second
+)
+
+// LogStore persists shipped log lines in daily partitions, and a cursor per container
+// recording how far its lines were stored
+type LogStore interface {
+	Insert(ctx context.Context, entries []domain.AppLogEntry) error
+	EnsurePartition(ctx context.Context, day time.Time) error
+	DropPartitionsBefore(ctx context.Context, cutoff time.Time) (int, error)
+	ShippedUntil(ctx context.Context, containerID string) (time.Time, error)
+	AdvanceCursors(ctx context.Context, cursors map[string]time.Time) error
+	DropCursorsBefore(ctx context.Context, cutoff time.Time) (int, error)
+}
+
+// Shipper follows the logs of every running NanoPaaS container and stores them, so they
+// stay searchable after containers are removed on redeploy. Each container's cursor
+// lets a restart resume where shipping stopped. Lines older than the retention are
+// dropped a whole day at a time.
+type Shipper struct {
+	docker    docker.ContainerRuntime
+	store     LogStore
+	retention time.Duration
+	logger    *zap.Logger
+
+	mu         sync.Mutex
+	containers map[string]context.CancelFunc // followed container ID -> stream cancel
+	lines      chan domain.AppLogEntry
+	partitions map[time.Time]bool // UTC days known to have a partition; used by write only
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewShipper creates a log shipper keeping lines for the given number of days
+func NewShipper(dockerClient docker.ContainerRuntime, store LogStore, retentionDays int, logger *zap.Logger) *Shipper {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Shipper{
+		docker:     dockerClient,
+		store:      store,
+		retention:  time.Duration(retentionDays) * 24 * time.Hour,
+		logger:     logger,
+		containers: make(map[string]context.CancelFunc),
+		lines:      make(chan domain.AppLogEntry, shipBuffer),
+		partitions: make(map[time.Time]bool),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
+// Start prepares the partitions and begins following containers
+func (s *Shipper) Start() {
+	s.maintain()
+
+	s.wg.Add(2)
+	go s.run()
+	go s.write()
+}
+
+// Stop ends every stream, flushes pending lines and waits for the shipper to finish
+func (s *Shipper) Stop() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+func (s *Shipper) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	maintenance := time.NewTicker(shipMaintenanceInterval)
+	defer maintenance.Stop()
+
+	s.sync()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			s.sync()
+		case <-maintenance.C:
+			s.maintain()
+		}
+	}
+}
+
+// maintain creates today's and tomorrow's partitions ahead of the lines, and drops the
+// expired partitions and the cursors of containers with no line since
+func (s *Shipper) maintain() {
+	ctx, cancel := context.WithTimeout(s.ctx, time.Minute)
+	defer cancel()
+
+	now := time.Now().UTC()
+	for _, day := range []time.Time{now, now.AddDate(0, 0, 1)} {
+		if err := s.store.EnsurePartition(ctx, day); err != nil {
+			s.logger.Error("Failed to create app log partition", zap.Error(err))
+		}
+	}
+	if s.retention > 0 {
+		if _, err := s.store.DropPartitionsBefore(ctx, now.Add(-s.retention)); err != nil {
+			s.logger.Error("Failed to drop expired app logs", zap.Error(err))
+		}
+		if _, err := s.store.DropCursorsBefore(ctx, now.Add(-s.retention)); err != nil {
+			s.logger.Error("Failed to drop expired app log cursors", zap.Error(err))
+		}
+	}
+}
+
+// sync starts following containers that are not followed yet
+func (s *Shipper) sync() {
+	ctx, cancel := context.WithTimeout(s.ctx, 10*time.Second)
+	running, err := s.docker.ListContainers(ctx, false)
+	cancel()
+	if err != nil {
+		s.logger.Debug("Failed to list containers for log shipping", zap.Error(err))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, container := range running {
+		appID, err := uuid.Parse(container.Labels["nanopaas.app.id"])
+		if err != nil {
+			continue
+		}
+		if _, following := s.containers[container.ID]; following {
+			continue
+		}
+		ctx, cancel := context.WithCancel(s.ctx)
+		s.containers[container.ID] = cancel
+		s.wg.Add(1)
+		go s.follow(ctx, appID, container)
+	}
+}
+
+// follow queues a container's log lines until it exits, resuming after its cursor. A
+// container without one is read from its first line still within the retention.
+func (s *Shipper) follow(ctx context.Context, appID uuid.UUID, container docker.ContainerInfo) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.containers, container.ID)
+		s.mu.Unlock()
+	}()
+
+	since, err := s.store.ShippedUntil(ctx, shortID(container.ID))
+	if err != nil {
+		s.logger.Warn("Failed to resume log shipping", zap.String("container_id", shortID(container.ID)), zap.Error(err))
+		return
+	}
+	if !since.IsZero() {
+		since = since.Add(time.Microsecond) // Postgres keeps microseconds
+	}
+	if oldest := s.oldest(); since.Before(oldest) {
+		since = oldest
+	}
+
+	opts := docker.LogOptions{Follow: true, Tail: "all", Since: since}
+	err = s.docker.StreamLogLines(ctx, container.ID, opts, func(line docker.LogLine) error {
+		l := NewLine(appID.String(), container, line)
+		entry := domain.AppLogEntry{
+			AppID:         appID,
+			ContainerID:   l.ContainerID,
+			ContainerName: l.ContainerName,
+			Replica:       l.Replica,
+			Stream:        string(l.Stream),
+			Message:       l.Content,
+			Timestamp:     l.Timestamp,
+		}
+		select {
+		case s.lines <- entry:
+		default:
+			// The store is behind; losing lines beats stalling the container's output
+		}
+		return nil
+	})
+	if err != nil && ctx.Err() == nil {
+		s.logger.Debug("Log shipping stream ended",
+			zap.String("container_id", shortID(container.ID)),
+			zap.Error(err),
+		)
+	}
+}
+
+// write stores queued lines in batches
+func (s *Shipper) write() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(shipFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]domain.AppLogEntry, 0, shipBatchSize)
+	flush := func(ctx context.Context) {
+		if len(batch) == 0 {
+			return
+		}
+		s.persist(ctx, batch)
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			// Keep what was already read before shutting down
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			for {
+				select {
+				case entry := <-s.lines:
+					batch = append(batch, entry)
+					if len(batch) >= shipBatchSize {
+						flush(ctx)
+					}
+				default:
+					flush(ctx)
+					return
+				}
+			}
+		case entry := <-s.lines:
+			batch = append(batch, entry)
+			if len(batch) >= shipBatchSize {
+				flush(s.ctx)
+			}
+		case <-ticker.C:
+			flush(s.ctx)
+		}
+	}
+}
+
+// persist stores a batch and advances its containers' cursors past the lines stored.
+// Lines older than the retention are skipped, and the partitions of the rest are
+// created first, so lines from any day can be stored.
+func (s *Shipper) persist(ctx context.Context, batch []domain.AppLogEntry) {
+	cursors := make(map[string]time.Time)
+	oldest := s.oldest()
+	kept := make([]domain.AppLogEntry, 0, len(batch))
+	for _, entry := range batch {
+		if entry.Timestamp.Before(oldest) {
+			advance(cursors, entry)
+			continue
+		}
+		kept = append(kept, entry)
+	}
+
+	s.ensurePartitions(ctx, kept, oldest)
+	if dropped, err := s.insert(ctx, kept, cursors); dropped > 0 {
+		s.logger.Warn("Failed to store app logs", zap.Int("lines", dropped), zap.Error(err))
+	}
+	if err := s.store.AdvanceCursors(ctx, cursors); err != nil {
+		s.logger.Warn("Failed to save app log cursors", zap.Error(err))
+	}
+}
+
+// ensurePartitions creates the partitions of the lines' days that may be missing, and
+// forgets the days that maintain drops once they end before oldest
+func (s *Shipper) ensurePartitions(ctx context.Context, entries []domain.AppLogEntry, oldest time.Time) {
+	for day := range s.partitions {
+		if !day.AddDate(0, 0, 1).After(oldest) {
+			delete(s.partitions, day)
+		}
+	}
+
+	failed := make(map[time.Time]bool)
+	for _, entry := range entries {
+		day := entry.Timestamp.UTC().Truncate(24 * time.Hour)
+		if s.partitions[day] || failed[day] {
+			continue
+		}
+		if err := s.store.EnsurePartition(ctx, day); err != nil {
+			// The day's lines fail to insert and are dropped
+			s.logger.Error("Failed to create app log partition", zap.Time("day", day), zap.Error(err))
+			failed[day] = true
+			continue
+		}
+		s.partitions[day] = true
+	}
+}
+
+// insert stores lines, retrying a batch that failed for want of the store. A batch the
+// store refuses is split in halves instead, so the lines it rejects lose only themselves.
+// It returns how many lines were dropped and the last error.
+func (s *Shipper) insert(ctx context.Context, entries []domain.AppLogEntry, cursors map[string]time.Time) (int, error) {
+	if len(entries) == 0 {
+		return 0, nil
+	}
+
+	var err error
+	for attempt := 0; attempt < shipInsertAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return len(entries), err
+			case <-time.After(shipRetryDelay << (attempt - 1)):
+			}
+		}
+		err = s.store.Insert(ctx, entries)
+		switch {
+		case err == nil:
+			advance(cursors, entries...)
+			return 0, nil
+		case errors.Is(err, domain.ErrInvalid):
+			return s.split(ctx, entries, cursors, err)
+		}
+	}
+	return len(entries), err
+}
+
+// split stores each half of a batch the store refused, splitting the halves it refuses
+// again down to single lines, which are dropped
+func (s *Shipper) split(ctx context.Context, entries []domain.AppLogEntry, cursors map[string]time.Time, err error) (int, error) {
+	if len(entries) == 1 || ctx.Err() != nil {
+		return len(entries), err
+	}
+
+	half := len(entries) / 2
+	dropped := 0
+	for _, part := range [][]domain.AppLogEntry{entries[:half], entries[half:]} {
+		partErr := s.store.Insert(ctx, part)
+		switch {
+		case partErr == nil:
+			advance(cursors, part...)
+			continue
+		case errors.Is(partErr, domain.ErrInvalid):
+			var n int
+			n, partErr = s.split(ctx, part, cursors, partErr)
+			dropped += n
+		default:
+			dropped += len(part)
+		}
+		err = partErr
+	}
+	return dropped, err
+}
+
+// oldest returns the time of the oldest line kept, zero when lines are kept forever
+func (s *Shipper) oldest() time.Time {
+	if s.retention <= 0 {
+		return time.Time{}
+	}
+	return time.Now().UTC().Add(-s.retention)
+}
+
+// advance moves the cursors of the lines' containers up to their newest line
+func advance(cursors map[string]time.Time, entries ...domain.AppLogEntry) {
+	for _, entry := range entries {
+		if entry.Timestamp.After(cursors[entry.ContainerID]) {
+			cursors[entry.ContainerID] = entry.Timestamp
+		}
+	}
+}
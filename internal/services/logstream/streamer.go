@@ -0,0 +1,293 @@
+package logstream
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/infrastructure/docker"
+	"github.com/nanopaas/nanopaas/internal/services/orchestrator"
+	ws "github.com/nanopaas/nanopaas/pkg/websocket"
+)
+
+// pollInterval is how often watched apps are checked for new replicas and for
+// subscribers that went away
+const pollInterval = 2 * time.Second
+
+// Topic returns the hub topic carrying an app's container logs
+func Topic(appID uuid.UUID) string {
+	return "app:" + appID.String() + ":logs"
+}
+
+// appFromTopic returns the app of a log topic
+func appFromTopic(topic string) (uuid.UUID, bool) {
+	if !strings.HasPrefix(topic, "app:") || !strings.HasSuffix(topic, ":logs") {
+		return uuid.Nil, false
+	}
+	id, err := uuid.Parse(strings.TrimSuffix(strings.TrimPrefix(topic, "app:"), ":logs"))
+	return id, err == nil
+}
+
+// Line is the payload of a "log" message
+type Line struct {
+	AppID         string           `json:"app_id,omitempty"`
+	ContainerID   string           `json:"container_id"`
+	ContainerName string           `json:"container_name,omitempty"`
+	Replica       *int             `json:"replica,omitempty"`
+	Stream        docker.LogStream `json:"stream"`
+	Content       string           `json:"content"`
+	Timestamp     time.Time        `json:"timestamp"`
+}
+
+// NewLine tags a container log line with the app, container and replica it came from
+func NewLine(appID string, container docker.ContainerInfo, line docker.LogLine) Line {
+	l := Line{
+		AppID:         appID,
+		ContainerID:   shortID(container.ID),
+		ContainerName: strings.TrimPrefix(container.Name, "/"),
+		Stream:        line.Stream,
+		Content:       line.Message,
+		Timestamp:     line.Timestamp.UTC(),
+	}
+	if replica, err := strconv.Atoi(container.Labels["nanopaas.replica"]); err == nil {
+		l.Replica = &replica
+	}
+	return l
+}
+
+// ExitPayload describes why a container's log stream ended, nil if it is still running
+func ExitPayload(info types.ContainerJSON, appID string, containerName string) map[string]interface{} {
+	exit := orchestrator.ExitFromInspect(info)
+	if exit == nil {
+		return nil
+	}
+
+	var memoryLimit int64
+	if info.HostConfig != nil {
+		memoryLimit = info.HostConfig.Memory
+	}
+
+	payload := map[string]interface{}{
+		"container_id": exit.ContainerID,
+		"exit_code":    exit.ExitCode,
+		"oom_killed":   exit.OOMKilled,
+		"finished_at":  exit.FinishedAt.Format(time.RFC3339Nano),
+		"hint":         exit.Hint(memoryLimit),
+	}
+	if appID != "" {
+		payload["app_id"] = appID
+	}
+	if containerName != "" {
+		payload["container_name"] = strings.TrimPrefix(containerName, "/")
+	}
+	if exit.Error != "" {
+		payload["error_message"] = exit.Error
+	}
+	return payload
+}
+
+// appStream tracks the container log streams of one app
+type appStream struct {
+	startedAt  time.Time
+	containers map[string]context.CancelFunc // running container ID -> stream cancel
+	lastLine   map[string]time.Time          // container ID -> time of the last line sent
+}
+
+func newAppStream() *appStream {
+	return &appStream{
+		startedAt:  time.Now(),
+		containers: make(map[string]context.CancelFunc),
+		lastLine:   make(map[string]time.Time),
+	}
+}
+
+// Streamer follows the logs of every running container of the apps with subscribers and
+// publishes them to the apps' log topics. Replicas started later, e.g. by scaling up or a
+// redeploy, are picked up automatically; streams stop once an app has no subscribers.
+type Streamer struct {
+	docker docker.ContainerRuntime
+	hub    *ws.Hub
+	logger *zap.Logger
+
+	mu   sync.Mutex
+	apps map[uuid.UUID]*appStream
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	wake   chan struct{}
+}
+
+// NewStreamer creates a log streamer; Start begins following containers
+func NewStreamer(dockerClient docker.ContainerRuntime, hub *ws.Hub, logger *zap.Logger) *Streamer {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Streamer{
+		docker: dockerClient,
+		hub:    hub,
+		logger: logger,
+		apps:   make(map[uuid.UUID]*appStream),
+		ctx:    ctx,
+		cancel: cancel,
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// Start runs the loop that matches container streams to subscribed apps
+func (s *Streamer) Start() {
+	s.wg.Add(1)
+	go s.run()
+}
+
+// Stop ends every stream and waits for them to finish
+func (s *Streamer) Stop() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+// Watch starts streaming an app's containers right away instead of at the next poll;
+// call it after subscribing a client to the app's topic
+func (s *Streamer) Watch(appID uuid.UUID) {
+	s.mu.Lock()
+	if _, ok := s.apps[appID]; !ok {
+		s.apps[appID] = newAppStream()
+	}
+	s.mu.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Streamer) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+		s.sync()
+	}
+}
+
+// sync stops streams of apps nobody listens to and starts streams for new containers
+func (s *Streamer) sync() {
+	// Apps can also be subscribed to over the multiplexed connection
+	for _, topic := range s.hub.Topics() {
+		if appID, ok := appFromTopic(topic); ok {
+			s.mu.Lock()
+			if _, watched := s.apps[appID]; !watched {
+				s.apps[appID] = newAppStream()
+			}
+			s.mu.Unlock()
+		}
+	}
+
+	s.mu.Lock()
+	for appID, stream := range s.apps {
+		if s.hub.TopicClientCount(Topic(appID)) == 0 {
+			for _, cancel := range stream.containers {
+				cancel()
+			}
+			delete(s.apps, appID)
+		}
+	}
+	watched := len(s.apps)
+	s.mu.Unlock()
+	if watched == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, 10*time.Second)
+	running, err := s.docker.ListContainers(ctx, false)
+	cancel()
+	if err != nil {
+		s.logger.Debug("Failed to list containers for log streaming", zap.Error(err))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, container := range running {
+		appID, err := uuid.Parse(container.Labels["nanopaas.app.id"])
+		if err != nil {
+			continue
+		}
+		stream, ok := s.apps[appID]
+		if !ok {
+			continue
+		}
+		if _, streaming := stream.containers[container.ID]; streaming {
+			continue
+		}
+
+		// Subscribers read earlier lines of existing containers themselves; replicas
+		// started since are streamed from their first line
+		since, ok := stream.lastLine[container.ID]
+		if !ok && container.CreatedAt.Before(stream.startedAt) {
+			since = stream.startedAt
+		}
+		ctx, cancel := context.WithCancel(s.ctx)
+		stream.containers[container.ID] = cancel
+		s.wg.Add(1)
+		go s.follow(ctx, appID, stream, container, since)
+	}
+}
+
+// follow publishes a container's log lines until it exits or the app loses its subscribers
+func (s *Streamer) follow(ctx context.Context, appID uuid.UUID, stream *appStream, container docker.ContainerInfo, since time.Time) {
+	defer s.wg.Done()
+	topic := Topic(appID)
+
+	opts := docker.LogOptions{Follow: true, Tail: "all", Since: since}
+	err := s.docker.StreamLogLines(ctx, container.ID, opts, func(line docker.LogLine) error {
+		s.hub.PublishLocal(topic, "log", NewLine(appID.String(), container, line))
+
+		s.mu.Lock()
+		// Resume a restarted container just after the last line sent
+		stream.lastLine[container.ID] = line.Timestamp.Add(time.Nanosecond)
+		s.mu.Unlock()
+		return nil
+	})
+	if err != nil && ctx.Err() == nil {
+		s.logger.Debug("Log stream ended",
+			zap.String("container_id", shortID(container.ID)),
+			zap.Error(err),
+		)
+	}
+
+	s.mu.Lock()
+	delete(stream.containers, container.ID)
+	s.mu.Unlock()
+
+	if ctx.Err() != nil {
+		return
+	}
+	info, err := s.docker.InspectContainer(ctx, container.ID)
+	if err != nil {
+		return
+	}
+	if exit := ExitPayload(info, appID.String(), container.Name); exit != nil {
+		s.hub.PublishLocal(topic, "container_exit", exit)
+	}
+}
+
+// shortID returns the 12 character form of a container ID
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
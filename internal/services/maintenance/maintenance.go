@@ -0,0 +1,317 @@
+package maintenance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// Config holds retention and scheduling settings for database maintenance
+type Config struct {
+	Enabled  bool
+	Interval time.Duration
+
+	// Retention in days; 0 disables pruning for that category
+	BuildRetentionDays      int
+	DeploymentRetentionDays int
+	BuildLogRetentionDays   int
+	PromotionRetentionDays  int
+	LoginEventRetentionDays int
+
+	// KeepDeploymentsPerApp always keeps the most recent deployments of each app for rollbacks
+	KeepDeploymentsPerApp int
+}
+
+// Thresholds for vacuum/analyze hints
+const (
+	vacuumDeadRatio   = 0.2
+	vacuumMinDeadRows = 1000
+	analyzeModRatio   = 0.2
+)
+
+// TableStats holds statistics and maintenance hints for one table
+type TableStats struct {
+	Table           string     `json:"table"`
+	LiveRows        int64      `json:"live_rows"`
+	DeadRows        int64      `json:"dead_rows"`
+	DeadRatio       float64    `json:"dead_ratio"`
+	ModifiedRows    int64      `json:"modified_since_analyze"`
+	LastAutovacuum  *time.Time `json:"last_autovacuum,omitempty"`
+	LastAutoanalyze *time.Time `json:"last_autoanalyze,omitempty"`
+	Hint            string     `json:"hint,omitempty"`
+}
+
+// Report summarizes a maintenance run
+type Report struct {
+	Trigger    string           `json:"trigger"` // "scheduled" or "manual"
+	StartedAt  time.Time        `json:"started_at"`
+	FinishedAt time.Time        `json:"finished_at"`
+	Pruned     map[string]int64 `json:"pruned"`
+	Orphans    map[string]int64 `json:"orphans"`
+	Tables     []TableStats     `json:"tables"`
+	Errors     []string         `json:"errors,omitempty"`
+}
+
+// pruneTask deletes rows older than a retention cutoff
+type pruneTask struct {
+	name       string
+	days       func(Config) int
+	query      string // $1 is the cutoff time
+	keepRecent bool   // $2 is the number of recent rows kept per app
+}
+
+// pruneTasks run in order so dependent rows are removed before their parents
+var pruneTasks = []pruneTask{
+	{
+		name:  "build_logs",
+		days:  func(c Config) int { return c.BuildLogRetentionDays },
+		query: `DELETE FROM build_logs WHERE timestamp < $1`,
+	},
+	{
+		name:       "deployments",
+		days:       func(c Config) int { return c.DeploymentRetentionDays },
+		keepRecent: true,
+		query: `
+			DELETE FROM deployments d
+			USING (
+				SELECT id, ROW_NUMBER() OVER (PARTITION BY app_id ORDER BY created_at DESC) AS rank
+				FROM deployments
+			) ranked
+			WHERE d.id = ranked.id
+				AND ranked.rank > $2
+				AND d.created_at < $1
+				AND d.status NOT IN ('pending', 'deploying', 'running')
+				AND NOT EXISTS (SELECT 1 FROM apps a WHERE a.current_deployment_id = d.id)`,
+	},
+	{
+		name: "builds",
+		days: func(c Config) int { return c.BuildRetentionDays },
+		query: `
+			DELETE FROM builds b
+			WHERE b.created_at < $1
+				AND b.status NOT IN ('queued', 'pending', 'running')
+				AND NOT EXISTS (SELECT 1 FROM apps a WHERE a.current_build_id = b.id)
+				AND NOT EXISTS (SELECT 1 FROM deployments d WHERE d.build_id = b.id)`,
+	},
+	{
+		name:  "image_promotions",
+		days:  func(c Config) int { return c.PromotionRetentionDays },
+		query: `DELETE FROM image_promotions WHERE created_at < $1`,
+	},
+	{
+		name:  "login_events",
+		days:  func(c Config) int { return c.LoginEventRetentionDays },
+		query: `DELETE FROM login_events WHERE created_at < $1`,
+	},
+}
+
+// orphanChecks count rows whose parent no longer exists (possible where foreign keys are missing)
+var orphanChecks = map[string]string{
+	"apps_without_owner": `
+		SELECT COUNT(*) FROM apps a
+		WHERE NOT EXISTS (SELECT 1 FROM users u WHERE u.id = a.owner_id)`,
+	"deployments_without_build": `
+		SELECT COUNT(*) FROM deployments d
+		WHERE d.build_id IS NOT NULL
+			AND NOT EXISTS (SELECT 1 FROM builds b WHERE b.id = d.build_id)`,
+	"promotions_without_app": `
+		SELECT COUNT(*) FROM image_promotions p
+		WHERE NOT EXISTS (SELECT 1 FROM apps a WHERE a.slug = p.app_slug)`,
+	"builds_stuck_running": `
+		SELECT COUNT(*) FROM builds
+		WHERE status IN ('queued', 'pending', 'running') AND created_at < NOW() - INTERVAL '1 day'`,
+}
+
+// ErrNoDatabase is returned by Run when NanoPaaS runs without PostgreSQL
+var ErrNoDatabase = errors.New("maintenance needs a PostgreSQL database")
+
+// Service runs scheduled and on-demand database maintenance
+type Service struct {
+	pool   *pgxpool.Pool
+	config Config
+	logger *zap.Logger
+
+	runMu      sync.Mutex // serializes runs
+	reportMu   sync.RWMutex
+	lastReport *Report
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewService creates a new maintenance service. With a nil pool, as in the in-memory
+// dev mode, there is nothing to maintain and runs return ErrNoDatabase.
+func NewService(pool *pgxpool.Pool, config Config, logger *zap.Logger) *Service {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Service{
+		pool:   pool,
+		config: config,
+		logger: logger,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Start begins scheduled maintenance if enabled
+func (s *Service) Start() {
+	if s.pool == nil || !s.config.Enabled || s.config.Interval <= 0 {
+		s.logger.Info("Scheduled database maintenance disabled")
+		return
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(s.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := s.Run(s.ctx, "scheduled"); err != nil {
+					s.logger.Warn("Scheduled maintenance failed", zap.Error(err))
+				}
+			}
+		}
+	}()
+
+	s.logger.Info("Scheduled database maintenance started", zap.Duration("interval", s.config.Interval))
+}
+
+// Stop stops scheduled maintenance and waits for a running pass to finish
+func (s *Service) Stop() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+// LastReport returns the most recent maintenance report, or nil if none has run
+func (s *Service) LastReport() *Report {
+	s.reportMu.RLock()
+	defer s.reportMu.RUnlock()
+	return s.lastReport
+}
+
+// Run performs one maintenance pass: retention pruning, orphan detection and table statistics.
+// Individual task failures are recorded in the report rather than aborting the pass.
+func (s *Service) Run(ctx context.Context, trigger string) (*Report, error) {
+	if s.pool == nil {
+		return nil, ErrNoDatabase
+	}
+	if !s.runMu.TryLock() {
+		return nil, fmt.Errorf("maintenance is already running")
+	}
+	defer s.runMu.Unlock()
+
+	report := &Report{
+		Trigger:   trigger,
+		StartedAt: time.Now().UTC(),
+		Pruned:    make(map[string]int64),
+		Orphans:   make(map[string]int64),
+		Tables:    []TableStats{},
+	}
+
+	s.prune(ctx, report)
+	s.detectOrphans(ctx, report)
+
+	tables, err := s.tableStats(ctx)
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("table stats: %v", err))
+	} else {
+		report.Tables = tables
+	}
+
+	report.FinishedAt = time.Now().UTC()
+
+	s.reportMu.Lock()
+	s.lastReport = report
+	s.reportMu.Unlock()
+
+	s.logger.Info("Database maintenance completed",
+		zap.String("trigger", trigger),
+		zap.Any("pruned", report.Pruned),
+		zap.Any("orphans", report.Orphans),
+		zap.Int("errors", len(report.Errors)),
+		zap.Duration("duration", report.FinishedAt.Sub(report.StartedAt)),
+	)
+
+	return report, nil
+}
+
+// prune deletes rows past their retention period
+func (s *Service) prune(ctx context.Context, report *Report) {
+	for _, task := range pruneTasks {
+		days := task.days(s.config)
+		if days <= 0 {
+			continue
+		}
+
+		args := []interface{}{time.Now().UTC().AddDate(0, 0, -days)}
+		if task.keepRecent {
+			args = append(args, s.config.KeepDeploymentsPerApp)
+		}
+
+		result, err := s.pool.Exec(ctx, task.query, args...)
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("prune %s: %v", task.name, err))
+			continue
+		}
+		report.Pruned[task.name] = result.RowsAffected()
+	}
+}
+
+// detectOrphans counts rows referencing missing parents
+func (s *Service) detectOrphans(ctx context.Context, report *Report) {
+	for name, query := range orphanChecks {
+		var count int64
+		if err := s.pool.QueryRow(ctx, query).Scan(&count); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("orphans %s: %v", name, err))
+			continue
+		}
+		report.Orphans[name] = count
+	}
+}
+
+// tableStats reads pg_stat_user_tables and derives vacuum/analyze hints
+func (s *Service) tableStats(ctx context.Context) ([]TableStats, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT relname, n_live_tup, n_dead_tup, n_mod_since_analyze, last_autovacuum, last_autoanalyze
+		FROM pg_stat_user_tables
+		ORDER BY n_dead_tup DESC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query table stats: %w", err)
+	}
+	defer rows.Close()
+
+	var tables []TableStats
+	for rows.Next() {
+		var t TableStats
+		if err := rows.Scan(&t.Table, &t.LiveRows, &t.DeadRows, &t.ModifiedRows, &t.LastAutovacuum, &t.LastAutoanalyze); err != nil {
+			return nil, fmt.Errorf("failed to scan table stats: %w", err)
+		}
+
+		if total := t.LiveRows + t.DeadRows; total > 0 {
+			t.DeadRatio = float64(t.DeadRows) / float64(total)
+		}
+
+		switch {
+		case t.DeadRows >= vacuumMinDeadRows && t.DeadRatio >= vacuumDeadRatio:
+			t.Hint = fmt.Sprintf("VACUUM ANALYZE %s", t.Table)
+		case t.LiveRows > 0 && float64(t.ModifiedRows) >= analyzeModRatio*float64(t.LiveRows):
+			t.Hint = fmt.Sprintf("ANALYZE %s", t.Table)
+		}
+
+		tables = append(tables, t)
+	}
+
+	return tables, rows.Err()
+}
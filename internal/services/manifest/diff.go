@@ -0,0 +1,65 @@
+package manifest
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+)
+
+// Change is one field that differs between two manifests. Path is the dotted field name,
+// e.g. env.DATABASE_URL or resources.memory; From or To is nil when the field is unset.
+type Change struct {
+	Path string      `json:"path"`
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// Diff lists the fields that change from one manifest to another, sorted by path.
+// A nil from diffs against an empty manifest, listing every field to be set.
+func Diff(from, to *Manifest) []Change {
+	changes := []Change{}
+	diffValues("", toTree(from), toTree(to), &changes)
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// toTree flattens a manifest into the generic form of its JSON encoding
+func toTree(m *Manifest) map[string]interface{} {
+	tree := map[string]interface{}{}
+	if m == nil {
+		return tree
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return tree
+	}
+	json.Unmarshal(data, &tree)
+	return tree
+}
+
+// diffValues compares two values, descending into objects so a changed env var or
+// policy field is reported on its own. Lists are compared whole.
+func diffValues(path string, from, to interface{}, changes *[]Change) {
+	fromMap, fromIsMap := from.(map[string]interface{})
+	toMap, toIsMap := to.(map[string]interface{})
+	if fromIsMap && toIsMap || from == nil && toIsMap || fromIsMap && to == nil {
+		keys := make(map[string]bool)
+		for k := range fromMap {
+			keys[k] = true
+		}
+		for k := range toMap {
+			keys[k] = true
+		}
+		for k := range keys {
+			child := k
+			if path != "" {
+				child = path + "." + k
+			}
+			diffValues(child, fromMap[k], toMap[k], changes)
+		}
+		return
+	}
+	if !reflect.DeepEqual(from, to) {
+		*changes = append(*changes, Change{Path: path, From: from, To: to})
+	}
+}
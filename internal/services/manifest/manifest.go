@@ -0,0 +1,421 @@
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+
+	units "github.com/docker/go-units"
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// MaxReplicas matches the orchestrator's replica limit
+const MaxReplicas = 10
+
+// cpuPeriod is the CFS period CPUQuota is measured against
+const cpuPeriod = 100000
+
+// Manifest is the declarative configuration of an app (app.yaml). Fields left out of a
+// manifest take their defaults when it is applied, so a manifest describes the whole app.
+// Field names follow the API's JSON names; YAML is converted through JSON so the domain
+// policy types are shared with the API.
+type Manifest struct {
+	Name        string              `json:"name"`
+	Slug        string              `json:"slug,omitempty"`
+	Description string              `json:"description,omitempty"`
+	Replicas    *int                `json:"replicas,omitempty"`
+	Env         map[string]string   `json:"env,omitempty"`
+	Secrets     []string            `json:"secrets,omitempty"` // names only; values are set through the secrets API
+	Resources   Resources           `json:"resources"`
+	Runtime     Runtime             `json:"runtime"`
+	Build       Build               `json:"build"`
+	Routing     Routing             `json:"routing"`
+	SmokeChecks []domain.SmokeCheck `json:"smoke_checks,omitempty"`
+}
+
+// Resources are the app's container limits
+type Resources struct {
+	Memory string  `json:"memory,omitempty"` // e.g. 512MiB
+	CPUs   float64 `json:"cpus,omitempty"`   // e.g. 0.5
+}
+
+// Runtime holds container runtime options
+type Runtime struct {
+	RestartPolicy string               `json:"restart_policy,omitempty"`
+	Command       []string             `json:"command,omitempty"`
+	NoFileLimit   int64                `json:"nofile_limit,omitempty"`
+	ShmSize       string               `json:"shm_size,omitempty"`
+	Tmpfs         map[string]string    `json:"tmpfs,omitempty"`
+	Sysctls       map[string]string    `json:"sysctls,omitempty"`
+	Volumes       []domain.VolumeMount `json:"volumes,omitempty"`
+
+	// How env changes reach running containers: immediate, rolling or next_deploy
+	EnvRestartPolicy string `json:"env_restart_policy,omitempty"`
+}
+
+// Build is the app's git build source
+type Build struct {
+	GitProvider string `json:"git_provider,omitempty"` // github when empty
+	GitRepoURL  string `json:"git_repo_url,omitempty"`
+	GitBranch   string `json:"git_branch,omitempty"`
+	AutoDeploy  bool   `json:"auto_deploy,omitempty"`
+
+	// Auto-deploy on branch pushes, tag pushes or GitHub releases, branch when empty.
+	// Tags must match tag_pattern, a glob, when it is set.
+	DeployTrigger string `json:"deploy_trigger,omitempty"`
+	TagPattern    string `json:"tag_pattern,omitempty"`
+
+	// Auto-deploys wait for an admin or the app's owner to approve them
+	RequireApproval bool `json:"require_approval,omitempty"`
+
+	// Auto-deploys from GitHub wait for the commit's required status checks to pass
+	RequireChecks bool `json:"require_checks,omitempty"`
+}
+
+// Routing is how the router exposes the app
+type Routing struct {
+	Port              int                         `json:"port,omitempty"`
+	StreamingMode     domain.StreamingMode        `json:"streaming_mode,omitempty"`
+	StreamIdleTimeout int                         `json:"stream_idle_timeout,omitempty"`
+	IPAllowList       []string                    `json:"ip_allowlist,omitempty"`
+	RateLimit         *domain.RateLimit           `json:"rate_limit,omitempty"`
+	CORS              *domain.CORSPolicy          `json:"cors,omitempty"`
+	HSTS              *domain.HSTSPolicy          `json:"hsts,omitempty"`
+	StickySessions    *domain.StickySessionPolicy `json:"sticky_sessions,omitempty"`
+}
+
+// FromApp exports an app's configuration. Secret values, basic auth and operational
+// state such as pins, lockdowns and maintenance pages are not part of a manifest.
+func FromApp(app *domain.App) *Manifest {
+	replicas := app.TargetReplicas
+	m := &Manifest{
+		Name:        app.Name,
+		Slug:        app.Slug,
+		Description: app.Description,
+		Replicas:    &replicas,
+		Env:         maps.Clone(app.EnvVars),
+		Secrets:     app.SecretKeys(),
+		Resources: Resources{
+			Memory: formatBytes(app.MemoryLimit),
+			CPUs:   float64(app.CPUQuota) / cpuPeriod,
+		},
+		Runtime: Runtime{
+			RestartPolicy: app.RestartPolicy,
+			Command:       app.Command,
+			NoFileLimit:   app.NoFileLimit,
+			ShmSize:       formatBytes(app.ShmSize),
+			Tmpfs:         maps.Clone(app.Tmpfs),
+			Sysctls:       maps.Clone(app.Sysctls),
+			Volumes:       app.Volumes,
+
+			EnvRestartPolicy: string(app.EnvRestartPolicy),
+		},
+		Build: Build{
+			GitProvider: gitProvider(app.GitProvider),
+			GitRepoURL:  app.GitRepoURL,
+			GitBranch:   app.GitBranch,
+			AutoDeploy:  app.AutoDeploy,
+
+			DeployTrigger: deployTrigger(app.DeployTrigger),
+			TagPattern:    app.TagPattern,
+
+			RequireApproval: app.RequireApproval,
+			RequireChecks:   app.RequireChecks,
+		},
+		Routing: Routing{
+			Port:              app.ExposedPort,
+			StreamingMode:     app.StreamingMode,
+			StreamIdleTimeout: app.StreamIdleTimeout,
+			CORS:              app.CORS,
+			HSTS:              app.HSTS,
+			StickySessions:    app.StickySessions,
+		},
+		SmokeChecks: app.SmokeChecks,
+	}
+	if app.Routing != nil {
+		m.Routing.IPAllowList = app.Routing.IPAllowList
+		m.Routing.RateLimit = app.Routing.RateLimit
+	}
+	return m
+}
+
+// Parse reads a YAML or JSON manifest. Unknown fields are rejected so typos do not
+// silently fall back to defaults.
+func Parse(data []byte) (*Manifest, error) {
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	if raw == nil {
+		return nil, fmt.Errorf("manifest is empty")
+	}
+
+	// YAML reads DEBUG: true and PORT: 8080 as bool and int; the app wants strings
+	stringifyValues(raw, "env")
+	if runtime, ok := raw["runtime"].(map[string]interface{}); ok {
+		stringifyValues(runtime, "tmpfs")
+		stringifyValues(runtime, "sysctls")
+	}
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var m Manifest
+	if err := dec.Decode(&m); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	return &m, nil
+}
+
+// stringifyValues turns the scalar values of the map at key into strings
+func stringifyValues(parent map[string]interface{}, key string) {
+	values, ok := parent[key].(map[string]interface{})
+	if !ok {
+		return
+	}
+	for k, v := range values {
+		switch v := v.(type) {
+		case bool:
+			values[k] = strconv.FormatBool(v)
+		case int:
+			values[k] = strconv.Itoa(v)
+		case int64:
+			values[k] = strconv.FormatInt(v, 10)
+		case float64:
+			values[k] = strconv.FormatFloat(v, 'f', -1, 64)
+		case nil:
+			values[k] = ""
+		}
+	}
+}
+
+// YAML renders the manifest as block-style YAML in field order
+func (m *Manifest) YAML() ([]byte, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	// JSON is YAML, so decoding it into a node keeps the field order and the
+	// API's field names; only the flow style needs undoing
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+	clearStyle(&node)
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&node); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// clearStyle resets a node tree to the encoder's default block style
+func clearStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		clearStyle(child)
+	}
+}
+
+// Apply sets app's configuration to the manifest, using defaults for fields the manifest
+// leaves out, and validates the result. It replaces maps and policies rather than
+// editing them, so app may be a shallow copy of a live app.
+func (m *Manifest) Apply(app *domain.App) error {
+	if m.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if m.Slug != "" && app.Slug != "" && m.Slug != app.Slug {
+		return fmt.Errorf("slug %q does not match app %q", m.Slug, app.Slug)
+	}
+	defaults := domain.NewApp(m.Name, m.Slug, uuid.Nil)
+
+	app.Name = m.Name
+	app.Description = m.Description
+
+	app.TargetReplicas = defaults.TargetReplicas
+	if m.Replicas != nil {
+		if *m.Replicas < 0 || *m.Replicas > MaxReplicas {
+			return fmt.Errorf("replicas must be between 0 and %d", MaxReplicas)
+		}
+		app.TargetReplicas = *m.Replicas
+	}
+
+	app.EnvVars = make(map[string]string, len(m.Env))
+	for k, v := range m.Env {
+		app.EnvVars[k] = v
+	}
+
+	app.MemoryLimit = defaults.MemoryLimit
+	if m.Resources.Memory != "" {
+		memory, err := units.RAMInBytes(m.Resources.Memory)
+		if err != nil || memory <= 0 {
+			return fmt.Errorf("invalid resources.memory %q", m.Resources.Memory)
+		}
+		app.MemoryLimit = memory
+	}
+	app.CPUQuota = defaults.CPUQuota
+	if m.Resources.CPUs < 0 {
+		return fmt.Errorf("resources.cpus must be positive")
+	}
+	if m.Resources.CPUs > 0 {
+		app.CPUQuota = int64(math.Round(m.Resources.CPUs * cpuPeriod))
+	}
+
+	app.RestartPolicy = defaults.RestartPolicy
+	if m.Runtime.RestartPolicy != "" {
+		app.RestartPolicy = m.Runtime.RestartPolicy
+	}
+	app.Command = m.Runtime.Command
+	app.NoFileLimit = m.Runtime.NoFileLimit
+	app.ShmSize = 0
+	if m.Runtime.ShmSize != "" {
+		shm, err := units.RAMInBytes(m.Runtime.ShmSize)
+		if err != nil || shm < 0 {
+			return fmt.Errorf("invalid runtime.shm_size %q", m.Runtime.ShmSize)
+		}
+		app.ShmSize = shm
+	}
+	app.Tmpfs = maps.Clone(m.Runtime.Tmpfs)
+	app.Sysctls = maps.Clone(m.Runtime.Sysctls)
+	app.Volumes = slices.Clone(m.Runtime.Volumes)
+	if err := app.ValidateRuntimeOptions(); err != nil {
+		return err
+	}
+	policy, ok := domain.ParseEnvRestartPolicy(m.Runtime.EnvRestartPolicy)
+	if !ok {
+		return fmt.Errorf("runtime.env_restart_policy must be immediate, rolling or next_deploy")
+	}
+	app.EnvRestartPolicy = policy
+
+	app.GitProvider = domain.GitProviderGitHub
+	if m.Build.GitProvider != "" {
+		if !domain.ValidGitProvider(m.Build.GitProvider) {
+			return fmt.Errorf("build.git_provider %q is not a supported git provider", m.Build.GitProvider)
+		}
+		app.GitProvider = m.Build.GitProvider
+	}
+	app.GitRepoURL = m.Build.GitRepoURL
+	app.GitBranch = m.Build.GitBranch
+	app.AutoDeploy = m.Build.AutoDeploy
+	app.RequireApproval = m.Build.RequireApproval
+	if app.AutoDeploy && app.GitRepoURL == "" {
+		return fmt.Errorf("build.auto_deploy requires build.git_repo_url")
+	}
+	app.DeployTrigger = domain.DeployTrigger(m.Build.DeployTrigger)
+	app.TagPattern = m.Build.TagPattern
+	app.RequireChecks = m.Build.RequireChecks
+	if err := app.ValidateDeployTrigger(); err != nil {
+		return fmt.Errorf("build.%w", err)
+	}
+
+	app.ExposedPort = defaults.ExposedPort
+	if m.Routing.Port != 0 {
+		if m.Routing.Port < 1 || m.Routing.Port > 65535 {
+			return fmt.Errorf("routing.port must be between 1 and 65535")
+		}
+		app.ExposedPort = m.Routing.Port
+	}
+	app.StreamingMode = m.Routing.StreamingMode
+	app.StreamIdleTimeout = m.Routing.StreamIdleTimeout
+	if err := app.ValidateStreaming(); err != nil {
+		return err
+	}
+
+	app.Routing = nil
+	routing := &domain.RoutingPolicy{IPAllowList: m.Routing.IPAllowList}
+	if m.Routing.RateLimit != nil {
+		limit := *m.Routing.RateLimit
+		routing.RateLimit = &limit
+	}
+	if !routing.IsEmpty() {
+		routing.IPAllowList = append([]string(nil), routing.IPAllowList...)
+		routing.Normalize()
+		if err := routing.Validate(); err != nil {
+			return err
+		}
+		app.Routing = routing
+	}
+
+	app.CORS = nil
+	if m.Routing.CORS != nil {
+		cors := *m.Routing.CORS
+		cors.Normalize()
+		if err := cors.Validate(); err != nil {
+			return err
+		}
+		app.CORS = &cors
+	}
+	app.HSTS = nil
+	if m.Routing.HSTS != nil {
+		hsts := *m.Routing.HSTS
+		hsts.Normalize()
+		if err := hsts.Validate(); err != nil {
+			return err
+		}
+		app.HSTS = &hsts
+	}
+	app.StickySessions = nil
+	if m.Routing.StickySessions != nil {
+		sticky := *m.Routing.StickySessions
+		sticky.Normalize()
+		if err := sticky.Validate(); err != nil {
+			return err
+		}
+		app.StickySessions = &sticky
+	}
+
+	if err := domain.ValidateSmokeChecks(m.SmokeChecks); err != nil {
+		return err
+	}
+	app.SmokeChecks = m.SmokeChecks
+	return nil
+}
+
+// formatBytes renders a size in the largest binary unit that divides it exactly, so it
+// parses back to the same value
+func formatBytes(n int64) string {
+	if n == 0 {
+		return ""
+	}
+	for _, unit := range []struct {
+		size   int64
+		suffix string
+	}{{units.GiB, "GiB"}, {units.MiB, "MiB"}, {units.KiB, "KiB"}} {
+		if n%unit.size == 0 {
+			return strconv.FormatInt(n/unit.size, 10) + unit.suffix
+		}
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+// gitProvider returns the git provider a manifest names, left out for GitHub
+func gitProvider(name string) string {
+	if name == domain.GitProviderGitHub {
+		return ""
+	}
+	return name
+}
+
+// deployTrigger returns the manifest's deploy_trigger for an app, leaving out the default
+func deployTrigger(trigger domain.DeployTrigger) string {
+	if trigger == domain.DeployTriggerBranch {
+		return ""
+	}
+	return string(trigger)
+}
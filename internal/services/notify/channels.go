@@ -0,0 +1,399 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// Headers sent with generic webhook payloads
+const (
+	EventHeader     = "X-NanoPaaS-Event"
+	DeliveryHeader  = "X-NanoPaaS-Delivery"
+	SignatureHeader = "X-NanoPaaS-Signature" // sha256=<hex HMAC of the body>, when the channel has a secret
+)
+
+// retryDelays are the waits before each retry of a failed delivery
+var retryDelays = []time.Duration{5 * time.Second, 30 * time.Second, 2 * time.Minute}
+
+const (
+	// deliveriesPerChannel bounds the delivery history kept for each channel
+	deliveriesPerChannel = 50
+
+	// An app is crash-looping once its containers fail crashLoopExits times within crashLoopWindow
+	crashLoopExits  = 3
+	crashLoopWindow = 10 * time.Minute
+
+	// discordContentLimit is the longest message Discord accepts
+	discordContentLimit = 2000
+)
+
+// ChannelStore lists the notification channels that receive an app's events
+type ChannelStore interface {
+	ListForApp(ctx context.Context, appID uuid.UUID) ([]*domain.NotificationChannel, error)
+}
+
+// AppSource looks up the app an event belongs to
+type AppSource interface {
+	FindApp(appID uuid.UUID) (*domain.App, bool)
+}
+
+// Event is an app event posted to notification channels. Generic webhooks receive it
+// as JSON.
+type Event struct {
+	Kind      domain.NotificationEvent `json:"event"`
+	AppID     uuid.UUID                `json:"app_id"`
+	AppName   string                   `json:"app_name"`
+	AppSlug   string                   `json:"app_slug"`
+	Title     string                   `json:"title"`
+	Message   string                   `json:"message,omitempty"`
+	Data      map[string]string        `json:"data,omitempty"`
+	Timestamp time.Time                `json:"timestamp"`
+}
+
+// Dispatcher posts deploy, build and crash-loop events to Slack, Discord and generic
+// webhook channels, retrying failed deliveries in the background
+type Dispatcher struct {
+	store  ChannelStore
+	apps   AppSource
+	client *http.Client
+	logger *zap.Logger
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	// Recent deliveries per channel, oldest first
+	deliveries   map[uuid.UUID][]*domain.NotificationDelivery
+	deliveriesMu sync.RWMutex
+
+	// Recent failed container exits per app, for crash-loop detection
+	exits   map[uuid.UUID][]time.Time
+	exitsMu sync.Mutex
+}
+
+// NewDispatcher creates a new notification channel dispatcher
+func NewDispatcher(store ChannelStore, apps AppSource, logger *zap.Logger) *Dispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Dispatcher{
+		store:      store,
+		apps:       apps,
+		client:     newClient(10 * time.Second),
+		logger:     logger,
+		ctx:        ctx,
+		cancel:     cancel,
+		deliveries: make(map[uuid.UUID][]*domain.NotificationDelivery),
+		exits:      make(map[uuid.UUID][]time.Time),
+	}
+}
+
+// Stop abandons pending retries and waits for in-flight deliveries
+func (d *Dispatcher) Stop() {
+	d.cancel()
+	d.wg.Wait()
+}
+
+// DeploymentChanged sends deploy_succeeded and deploy_failed events
+func (d *Dispatcher) DeploymentChanged(deployment *domain.Deployment) {
+	switch deployment.Status {
+	case domain.DeploymentStatusSucceeded:
+		// Automatic rollbacks are reported through the deployment that failed
+		if deployment.RollbackReason != "" {
+			return
+		}
+		d.send(domain.EventDeploySucceeded, deployment.AppID, "Deployed %s", deployment.ImageID, map[string]string{
+			"deployment_id": deployment.ID.String(),
+			"image":         deployment.ImageID,
+		})
+	case domain.DeploymentStatusFailed, domain.DeploymentStatusRolledBack:
+		message := deployment.ErrorMessage
+		if deployment.Status == domain.DeploymentStatusRolledBack {
+			message = "Rolled back: " + deployment.RollbackReason
+		}
+		d.send(domain.EventDeployFailed, deployment.AppID, "Deployment of %s failed", message, map[string]string{
+			"deployment_id": deployment.ID.String(),
+			"image":         deployment.ImageID,
+			"status":        string(deployment.Status),
+		})
+	}
+}
+
+// BuildFinished sends build_failed events
+func (d *Dispatcher) BuildFinished(build *domain.Build) {
+	if build.Status != domain.BuildStatusFailed {
+		return
+	}
+	d.send(domain.EventBuildFailed, build.AppID, "Build failed for %s", build.ErrorMessage, map[string]string{
+		"build_id": build.ID.String(),
+		"git_ref":  build.GitRef,
+	})
+}
+
+// ContainerExited sends a crash_loop event once an app's containers keep failing. The
+// count starts over after each event, so a crash-looping app is reported at most once
+// per crashLoopExits failures.
+func (d *Dispatcher) ContainerExited(appID uuid.UUID, exit domain.ContainerExit) {
+	if !exit.Failed() {
+		return
+	}
+
+	d.exitsMu.Lock()
+	cutoff := time.Now().Add(-crashLoopWindow)
+	recent := d.exits[appID][:0]
+	for _, t := range d.exits[appID] {
+		if t.After(cutoff) {
+			recent = append(recent, t)
+		}
+	}
+	recent = append(recent, time.Now())
+	looping := len(recent) >= crashLoopExits
+	if looping {
+		recent = nil
+	}
+	d.exits[appID] = recent
+	d.exitsMu.Unlock()
+
+	if !looping {
+		return
+	}
+	var memoryLimit int64
+	if app, ok := d.apps.FindApp(appID); ok {
+		memoryLimit = app.MemoryLimit
+	}
+	d.send(domain.EventCrashLoop, appID, "%s is crash-looping",
+		fmt.Sprintf("%d container failures in %s. Last: %s", crashLoopExits, crashLoopWindow, exit.Hint(memoryLimit)),
+		map[string]string{
+			"container_id": exit.ContainerID,
+			"exit_code":    fmt.Sprint(exit.ExitCode),
+		})
+}
+
+// send posts an event to the channels that want it. titleFormat gets the app's slug.
+func (d *Dispatcher) send(kind domain.NotificationEvent, appID uuid.UUID, titleFormat, message string, data map[string]string) {
+	event := Event{
+		Kind:      kind,
+		AppID:     appID,
+		AppSlug:   appID.String(),
+		Message:   message,
+		Data:      data,
+		Timestamp: time.Now().UTC(),
+	}
+	if app, ok := d.apps.FindApp(appID); ok {
+		event.AppName = app.Name
+		event.AppSlug = app.Slug
+	}
+	event.Title = fmt.Sprintf(titleFormat, event.AppSlug)
+
+	channels, err := d.store.ListForApp(d.ctx, appID)
+	if err != nil {
+		d.logger.Error("Failed to list notification channels", zap.String("app_id", appID.String()), zap.Error(err))
+		return
+	}
+	for _, channel := range channels {
+		if !channel.Wants(kind) {
+			continue
+		}
+		delivery := d.record(channel, event)
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			d.deliver(channel, event, delivery)
+		}()
+	}
+}
+
+// Test posts a test event to a channel once, without retrying, and returns the delivery
+func (d *Dispatcher) Test(channel *domain.NotificationChannel, app *domain.App) domain.NotificationDelivery {
+	event := Event{
+		Kind:      domain.EventTest,
+		Title:     "Test notification from NanoPaaS",
+		Message:   fmt.Sprintf("Channel %q is set up to receive events.", channel.Name),
+		Timestamp: time.Now().UTC(),
+	}
+	if app != nil {
+		event.AppID = app.ID
+		event.AppName = app.Name
+		event.AppSlug = app.Slug
+	}
+	delivery := d.record(channel, event)
+
+	body, err := payload(channel.Kind, event)
+	status := 0
+	if err == nil {
+		status, _, err = d.post(channel, event.Kind, delivery.ID, body)
+	}
+	if err != nil {
+		d.finish(delivery, domain.DeliveryFailed, status, err)
+	} else {
+		d.finish(delivery, domain.DeliveryDelivered, status, nil)
+	}
+
+	d.deliveriesMu.RLock()
+	defer d.deliveriesMu.RUnlock()
+	return *delivery
+}
+
+// record adds a pending delivery to a channel's history
+func (d *Dispatcher) record(channel *domain.NotificationChannel, event Event) *domain.NotificationDelivery {
+	delivery := &domain.NotificationDelivery{
+		ID:        uuid.New(),
+		ChannelID: channel.ID,
+		AppID:     event.AppID,
+		Event:     event.Kind,
+		Title:     event.Title,
+		Status:    domain.DeliveryPending,
+		CreatedAt: time.Now().UTC(),
+	}
+	d.deliveriesMu.Lock()
+	history := append(d.deliveries[channel.ID], delivery)
+	if len(history) > deliveriesPerChannel {
+		history = history[len(history)-deliveriesPerChannel:]
+	}
+	d.deliveries[channel.ID] = history
+	d.deliveriesMu.Unlock()
+	return delivery
+}
+
+// deliver makes up to len(retryDelays)+1 attempts at a delivery
+func (d *Dispatcher) deliver(channel *domain.NotificationChannel, event Event, delivery *domain.NotificationDelivery) {
+	body, err := payload(channel.Kind, event)
+	if err != nil {
+		d.finish(delivery, domain.DeliveryFailed, 0, err)
+		return
+	}
+
+	for attempt := 0; ; attempt++ {
+		status, retry, err := d.post(channel, event.Kind, delivery.ID, body)
+		if err == nil {
+			d.finish(delivery, domain.DeliveryDelivered, status, nil)
+			return
+		}
+		if !retry || attempt == len(retryDelays) {
+			d.logger.Warn("Notification delivery failed",
+				zap.String("channel_id", channel.ID.String()),
+				zap.String("event", string(event.Kind)),
+				zap.Error(err),
+			)
+			d.finish(delivery, domain.DeliveryFailed, status, err)
+			return
+		}
+		d.finish(delivery, domain.DeliveryPending, status, err)
+
+		select {
+		case <-d.ctx.Done():
+			d.finish(delivery, domain.DeliveryFailed, status, fmt.Errorf("%w; not retried, server shutting down", err))
+			return
+		case <-time.After(retryDelays[attempt]):
+		}
+	}
+}
+
+// post sends one attempt, reporting the HTTP status and whether a failure is worth retrying
+func (d *Dispatcher) post(channel *domain.NotificationChannel, kind domain.NotificationEvent, deliveryID uuid.UUID, body []byte) (int, bool, error) {
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, channel.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "NanoPaaS-Notifier")
+	if channel.Kind == domain.ChannelWebhook {
+		req.Header.Set(EventHeader, string(kind))
+		req.Header.Set(DeliveryHeader, deliveryID.String())
+		if channel.Secret != "" {
+			mac := hmac.New(sha256.New, []byte(channel.Secret))
+			mac.Write(body)
+			req.Header.Set(SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+		}
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, !errors.Is(err, errPrivateAddress), err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp.StatusCode, false, nil
+	}
+	// Other client errors mean the channel is misconfigured; retrying won't help
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusRequestTimeout
+	return resp.StatusCode, retry, fmt.Errorf("endpoint returned %s", resp.Status)
+}
+
+// finish records the outcome of an attempt
+func (d *Dispatcher) finish(delivery *domain.NotificationDelivery, status domain.DeliveryStatus, responseStatus int, err error) {
+	d.deliveriesMu.Lock()
+	defer d.deliveriesMu.Unlock()
+
+	if responseStatus != 0 || err != nil {
+		delivery.Attempts++
+	}
+	delivery.Status = status
+	delivery.ResponseStatus = responseStatus
+	delivery.LastError = ""
+	if err != nil {
+		delivery.LastError = err.Error()
+	}
+	if status == domain.DeliveryDelivered {
+		now := time.Now().UTC()
+		delivery.DeliveredAt = &now
+	}
+}
+
+// Deliveries returns a channel's recent deliveries, newest first
+func (d *Dispatcher) Deliveries(channelID uuid.UUID) []domain.NotificationDelivery {
+	d.deliveriesMu.RLock()
+	defer d.deliveriesMu.RUnlock()
+
+	history := d.deliveries[channelID]
+	deliveries := make([]domain.NotificationDelivery, 0, len(history))
+	for i := len(history) - 1; i >= 0; i-- {
+		deliveries = append(deliveries, *history[i])
+	}
+	return deliveries
+}
+
+// Forget drops the delivery history of a deleted channel
+func (d *Dispatcher) Forget(channelID uuid.UUID) {
+	d.deliveriesMu.Lock()
+	delete(d.deliveries, channelID)
+	d.deliveriesMu.Unlock()
+}
+
+// payload renders an event in the format a channel kind expects
+func payload(kind domain.ChannelKind, event Event) ([]byte, error) {
+	switch kind {
+	case domain.ChannelSlack:
+		text := "*" + event.Title + "*"
+		if event.Message != "" {
+			text += "\n" + event.Message
+		}
+		return json.Marshal(map[string]string{"text": text})
+	case domain.ChannelDiscord:
+		content := "**" + event.Title + "**"
+		if event.Message != "" {
+			content += "\n" + event.Message
+		}
+		if len(content) > discordContentLimit {
+			content = content[:discordContentLimit-3] + "..."
+		}
+		return json.Marshal(map[string]string{"content": content})
+	default:
+		return json.Marshal(event)
+	}
+}
@@ -0,0 +1,49 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// errPrivateAddress refuses a connection to an address inside the server's network
+var errPrivateAddress = errors.New("refusing to connect to a private address")
+
+// sharedAddressSpace is the carrier-grade NAT range, private in all but name
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// newClient returns the HTTP client deliveries are posted with. Channel URLs are chosen
+// by app users, so it only connects to public addresses: the check runs on each address
+// as it is dialed, after DNS resolution, so a host that resolves to a private address,
+// or is rebound to one after the channel was saved, is still refused. Redirects are
+// dialed the same way. Proxies are not used, since they would dial on our behalf.
+func newClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   timeout,
+		KeepAlive: 30 * time.Second,
+		Control:   refusePrivate,
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// refusePrivate is a net.Dialer Control function that fails dials to loopback, private,
+// link-local and other non-public addresses
+func refusePrivate(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("unexpected dial address %s: %w", address, err)
+	}
+	addr := addrPort.Addr().Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() || addr.IsUnspecified() || sharedAddressSpace.Contains(addr) {
+		return fmt.Errorf("%w %s", errPrivateAddress, addr)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+	ws "github.com/nanopaas/nanopaas/pkg/websocket"
+)
+
+// messageType is the hub message type for pushed notifications
+const messageType = "notification"
+
+// UserTopic returns the hub topic carrying a user's personal notifications
+func UserTopic(userID uuid.UUID) string {
+	return fmt.Sprintf("user:%s:notifications", userID)
+}
+
+// Inbox stores users' notifications
+type Inbox interface {
+	Create(ctx context.Context, n *domain.Notification) error
+}
+
+// Service stores personal notifications and pushes them to connected clients
+type Service struct {
+	repo   Inbox
+	hub    *ws.Hub
+	logger *zap.Logger
+}
+
+// NewService creates a new notification service
+func NewService(repo Inbox, hub *ws.Hub, logger *zap.Logger) *Service {
+	return &Service{
+		repo:   repo,
+		hub:    hub,
+		logger: logger,
+	}
+}
+
+// Send stores a notification in the user's inbox and pushes it to their open sessions.
+// The push is best effort; the inbox is the source of truth.
+func (s *Service) Send(ctx context.Context, n *domain.Notification) error {
+	if err := s.repo.Create(ctx, n); err != nil {
+		s.logger.Error("Failed to store notification",
+			zap.String("user_id", n.UserID.String()),
+			zap.String("kind", string(n.Kind)),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	topic := UserTopic(n.UserID)
+	if s.hub.HasListeners(topic) {
+		return s.hub.Publish(topic, messageType, n)
+	}
+	return nil
+}
+
+// Notify builds and sends a notification, logging rather than returning failures
+func (s *Service) Notify(ctx context.Context, userID uuid.UUID, kind domain.NotificationKind, title, body, link string) {
+	n := domain.NewNotification(userID, kind, title, body)
+	n.Link = link
+	s.Send(ctx, n)
+}
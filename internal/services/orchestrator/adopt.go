@@ -0,0 +1,58 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// AdoptContainers starts tracking an app's running containers that were started by a
+// previous process, so a restarted server can scale, stop and route to them again.
+// It returns the number of containers adopted.
+func (o *Orchestrator) AdoptContainers(ctx context.Context, app *domain.App) (int, error) {
+	containers, err := o.dockerClient.ListContainers(ctx, false)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	type replicaContainer struct {
+		id      string
+		replica int
+	}
+	var found []replicaContainer
+	appID := app.ID.String()
+	for _, c := range containers {
+		if c.Labels["nanopaas.app.id"] != appID {
+			continue
+		}
+		replica, _ := strconv.Atoi(c.Labels["nanopaas.replica"])
+		found = append(found, replicaContainer{id: c.ID, replica: replica})
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].replica < found[j].replica })
+
+	containerIDs := make([]string, 0, len(found))
+	for _, c := range found {
+		containerIDs = append(containerIDs, c.id)
+	}
+
+	o.appContainersMu.Lock()
+	if len(containerIDs) > 0 {
+		o.appContainers[app.ID] = containerIDs
+	} else {
+		delete(o.appContainers, app.ID)
+	}
+	o.appContainersMu.Unlock()
+
+	app.Replicas = len(containerIDs)
+
+	o.logger.Info("Adopted app containers",
+		zap.String("app_id", appID),
+		zap.Int("containers", len(containerIDs)),
+	)
+	return len(containerIDs), nil
+}
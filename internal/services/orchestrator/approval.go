@@ -0,0 +1,119 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// RequestApproval records a deployment of a build's image that waits for approval
+// instead of running. It supersedes any of the app's deployments still waiting, so only
+// the newest build can be approved.
+func (o *Orchestrator) RequestApproval(app *domain.App, buildID uuid.UUID, imageID string) *domain.Deployment {
+	deployment := domain.NewApprovalDeployment(app, buildID, imageID)
+
+	o.deploymentsMu.Lock()
+	var superseded []*domain.Deployment
+	for _, d := range o.deployments {
+		if d.AppID == app.ID && d.AwaitsApproval() {
+			d.Reject(nil, fmt.Sprintf("superseded by deployment %s", deployment.ID))
+			superseded = append(superseded, d)
+		}
+	}
+	o.deployments[deployment.ID] = deployment
+	o.deploymentsMu.Unlock()
+
+	for _, d := range superseded {
+		o.deploymentChanged(d)
+	}
+	o.deploymentChanged(deployment)
+
+	o.logger.Info("Deployment awaiting approval",
+		zap.String("deployment_id", deployment.ID.String()),
+		zap.String("app_id", app.ID.String()),
+		zap.String("image", imageID),
+	)
+	return deployment
+}
+
+// PendingApprovals returns the deployments awaiting approval, oldest first
+func (o *Orchestrator) PendingApprovals() []*domain.Deployment {
+	o.deploymentsMu.RLock()
+	defer o.deploymentsMu.RUnlock()
+
+	pending := make([]*domain.Deployment, 0)
+	for _, d := range o.deployments {
+		if d.AwaitsApproval() {
+			pending = append(pending, d)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].CreatedAt.Before(pending[j].CreatedAt)
+	})
+	return pending
+}
+
+// reviewDeployment claims a deployment awaiting approval for review, so it is approved
+// or rejected exactly once
+func (o *Orchestrator) reviewDeployment(app *domain.App, deploymentID uuid.UUID, review func(d *domain.Deployment)) (*domain.Deployment, error) {
+	o.deploymentsMu.Lock()
+	defer o.deploymentsMu.Unlock()
+
+	deployment, ok := o.deployments[deploymentID]
+	if !ok || deployment.AppID != app.ID {
+		return nil, fmt.Errorf("deployment %w", domain.ErrNotFound)
+	}
+	if !deployment.AwaitsApproval() {
+		return nil, fmt.Errorf("deployment is %s, not awaiting approval: %w", deployment.Status, domain.ErrConflict)
+	}
+	review(deployment)
+	return deployment, nil
+}
+
+// ApproveDeployment runs a deployment that was awaiting approval
+func (o *Orchestrator) ApproveDeployment(ctx context.Context, app *domain.App, deploymentID, approvedBy uuid.UUID) (*domain.Deployment, error) {
+	if !app.CanDeploy() {
+		return nil, fmt.Errorf("app is not in a deployable state: %s: %w", app.Status, domain.ErrConflict)
+	}
+	deployment, err := o.reviewDeployment(app, deploymentID, func(d *domain.Deployment) {
+		d.Approve(approvedBy)
+	})
+	if err != nil {
+		return nil, err
+	}
+	o.deploymentChanged(deployment)
+
+	app.UpdateImage(deployment.ImageID)
+	deployment.PreviousImageID = app.PreviousImageID
+	deployment.Replicas = app.TargetReplicas
+
+	o.logger.Info("Deployment approved",
+		zap.String("deployment_id", deployment.ID.String()),
+		zap.String("app_id", app.ID.String()),
+		zap.String("approved_by", approvedBy.String()),
+	)
+	return deployment, o.runDeployment(ctx, app, deployment)
+}
+
+// RejectDeployment closes a deployment that was awaiting approval without running it
+func (o *Orchestrator) RejectDeployment(app *domain.App, deploymentID, rejectedBy uuid.UUID, reason string) (*domain.Deployment, error) {
+	deployment, err := o.reviewDeployment(app, deploymentID, func(d *domain.Deployment) {
+		d.Reject(&rejectedBy, reason)
+	})
+	if err != nil {
+		return nil, err
+	}
+	o.deploymentChanged(deployment)
+
+	o.logger.Info("Deployment rejected",
+		zap.String("deployment_id", deployment.ID.String()),
+		zap.String("app_id", app.ID.String()),
+		zap.String("rejected_by", rejectedBy.String()),
+	)
+	return deployment, nil
+}
@@ -0,0 +1,256 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+	"github.com/nanopaas/nanopaas/internal/infrastructure/docker"
+)
+
+// EffectiveConfig resolves the configuration a container started now would get, merged
+// with its image's defaults, and inspects each of the app's containers, recording where
+// they differ from it. Secret values are redacted. Failures for one container are
+// recorded in the result rather than aborting.
+func (o *Orchestrator) EffectiveConfig(ctx context.Context, app *domain.App) *domain.EffectiveConfig {
+	cfg := &domain.EffectiveConfig{
+		AppID:          app.ID,
+		Image:          app.CurrentImageID,
+		Command:        app.Command,
+		Port:           app.ExposedPort,
+		PendingRestart: app.HasPendingRestart(),
+		ResolvedAt:     time.Now().UTC(),
+		Containers:     []domain.ContainerConfig{},
+	}
+
+	env, err := o.containerEnv(app)
+	if err != nil {
+		cfg.Errors = append(cfg.Errors, err.Error())
+		env = app.GetEnvSlice()
+	}
+	opts := containerOptions(app, "", env, nil)
+	cfg.Resources = resourcesFromOptions(opts)
+
+	var imageEnv []string
+	if app.CurrentImageID != "" {
+		img, err := o.dockerClient.InspectImage(ctx, app.CurrentImageID)
+		if err != nil {
+			cfg.Errors = append(cfg.Errors, fmt.Sprintf("image %s: %v", app.CurrentImageID, err))
+		} else if img.Config != nil {
+			imageEnv = img.Config.Env
+			cfg.HealthCheck = healthCheckFromConfig(img.Config.Healthcheck)
+			if len(cfg.Command) == 0 {
+				cfg.Command = img.Config.Cmd
+			}
+		}
+	}
+	cfg.Env = effectiveEnv(app, mergeEnv(imageEnv, env))
+
+	for _, containerID := range o.GetAppContainers(app.ID) {
+		c, err := o.containerConfig(ctx, app, containerID, opts, imageEnv)
+		if err != nil {
+			cfg.Errors = append(cfg.Errors, fmt.Sprintf("container %s: %v", shortContainerID(containerID), err))
+			continue
+		}
+		cfg.Containers = append(cfg.Containers, c)
+	}
+	return cfg
+}
+
+// containerConfig inspects one container and compares it with the options a new
+// replica would be created with
+func (o *Orchestrator) containerConfig(ctx context.Context, app *domain.App, containerID string, want docker.ContainerOptions, imageEnv []string) (domain.ContainerConfig, error) {
+	c := domain.ContainerConfig{ContainerID: shortContainerID(containerID)}
+
+	info, err := o.dockerClient.InspectContainer(ctx, containerID)
+	if err != nil {
+		return c, err
+	}
+	c.Name = strings.TrimPrefix(info.Name, "/")
+	if info.State != nil {
+		c.State = info.State.Status
+		if started, err := time.Parse(time.RFC3339Nano, info.State.StartedAt); err == nil && !started.IsZero() {
+			c.StartedAt = &started
+		}
+	}
+	var env []string
+	if info.Config != nil {
+		env = info.Config.Env
+		c.Image = info.Config.Image
+		c.Command = info.Config.Cmd
+		c.Labels = info.Config.Labels
+		c.HealthCheck = healthCheckFromConfig(info.Config.Healthcheck)
+	}
+	c.Env = effectiveEnv(app, env)
+	if c.HealthCheck != nil && info.State != nil && info.State.Health != nil {
+		c.HealthCheck.Status = info.State.Health.Status
+	}
+	if info.HostConfig != nil {
+		c.Resources = resourcesFromHost(info.HostConfig)
+	}
+
+	c.Drift = configDrift(app, want, c, env, imageEnv)
+	return c, nil
+}
+
+// configDrift lists how a running container differs from the options a new replica would
+// get. Env vars the container has that neither the app nor its image set are reported as
+// removed, but only when the container runs the app's current image.
+func configDrift(app *domain.App, want docker.ContainerOptions, c domain.ContainerConfig, env, imageEnv []string) []string {
+	var drift []string
+	if c.Image != want.Image {
+		drift = append(drift, fmt.Sprintf("runs image %s, app deploys %s", c.Image, want.Image))
+	}
+	if len(want.Cmd) > 0 && !slices.Equal(c.Command, want.Cmd) {
+		drift = append(drift, "command differs from the app's command")
+	}
+
+	actual := envMap(env)
+	expected := envMap(want.Env)
+	for _, key := range sortedKeys(expected) {
+		kind := "env"
+		if _, ok := app.Secrets[key]; ok {
+			kind = "secret"
+		}
+		value, ok := actual[key]
+		switch {
+		case !ok:
+			drift = append(drift, fmt.Sprintf("%s %s is not set", kind, key))
+		case value != expected[key]:
+			drift = append(drift, fmt.Sprintf("%s %s has an older value", kind, key))
+		}
+	}
+	if c.Image == want.Image {
+		image := envMap(imageEnv)
+		for _, key := range sortedKeys(actual) {
+			_, inApp := expected[key]
+			_, inImage := image[key]
+			if !inApp && !inImage {
+				drift = append(drift, fmt.Sprintf("env %s was removed from the app but is still set", key))
+			}
+		}
+	}
+
+	if c.Resources.MemoryLimit != want.Memory {
+		drift = append(drift, fmt.Sprintf("memory limit is %d, app sets %d", c.Resources.MemoryLimit, want.Memory))
+	}
+	if c.Resources.CPUQuota != want.CPUQuota {
+		drift = append(drift, fmt.Sprintf("cpu quota is %d, app sets %d", c.Resources.CPUQuota, want.CPUQuota))
+	}
+	if policy := restartPolicyName(want.RestartPolicy); c.Resources.RestartPolicy != policy {
+		drift = append(drift, fmt.Sprintf("restart policy is %s, app sets %s", c.Resources.RestartPolicy, policy))
+	}
+	return drift
+}
+
+// effectiveEnv labels each KEY=value pair with where it comes from, redacting secrets
+func effectiveEnv(app *domain.App, env []string) []domain.EffectiveEnvVar {
+	vars := make([]domain.EffectiveEnvVar, 0, len(env))
+	for _, kv := range env {
+		key, value, _ := strings.Cut(kv, "=")
+		v := domain.EffectiveEnvVar{Key: key, Value: value, Source: domain.EnvSourceImage}
+		if _, ok := app.Secrets[key]; ok {
+			v.Value, v.Source = domain.RedactedValue, domain.EnvSourceSecret
+		} else if _, ok := app.EnvVars[key]; ok {
+			v.Source = domain.EnvSourceApp
+		} else if _, ok := app.ProjectEnv[key]; ok {
+			v.Source = domain.EnvSourceProject
+		}
+		vars = append(vars, v)
+	}
+	sort.Slice(vars, func(i, j int) bool { return vars[i].Key < vars[j].Key })
+	return vars
+}
+
+// mergeEnv applies container env over image env the way the runtime does
+func mergeEnv(imageEnv, env []string) []string {
+	merged := envMap(imageEnv)
+	for key, value := range envMap(env) {
+		merged[key] = value
+	}
+	kvs := make([]string, 0, len(merged))
+	for _, key := range sortedKeys(merged) {
+		kvs = append(kvs, key+"="+merged[key])
+	}
+	return kvs
+}
+
+func envMap(env []string) map[string]string {
+	m := make(map[string]string, len(env))
+	for _, kv := range env {
+		key, value, _ := strings.Cut(kv, "=")
+		m[key] = value
+	}
+	return m
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// resourcesFromOptions returns the resources a container created with opts gets
+func resourcesFromOptions(opts docker.ContainerOptions) domain.EffectiveResources {
+	return domain.EffectiveResources{
+		MemoryLimit:   opts.Memory,
+		CPUQuota:      opts.CPUQuota,
+		RestartPolicy: restartPolicyName(opts.RestartPolicy),
+		NoFileLimit:   opts.NoFileLimit,
+		ShmSize:       opts.ShmSize,
+		Tmpfs:         opts.Tmpfs,
+		Sysctls:       opts.Sysctls,
+		Binds:         opts.Binds,
+	}
+}
+
+// resourcesFromHost returns the resources a container was created with
+func resourcesFromHost(host *container.HostConfig) domain.EffectiveResources {
+	resources := domain.EffectiveResources{
+		MemoryLimit:   host.Memory,
+		CPUQuota:      host.CPUQuota,
+		RestartPolicy: string(host.RestartPolicy.Name),
+		ShmSize:       host.ShmSize,
+		Tmpfs:         host.Tmpfs,
+		Sysctls:       host.Sysctls,
+		Binds:         host.Binds,
+	}
+	for _, ulimit := range host.Ulimits {
+		if ulimit.Name == "nofile" {
+			resources.NoFileLimit = ulimit.Soft
+		}
+	}
+	return resources
+}
+
+// restartPolicyName returns the runtime restart policy an app's setting maps to;
+// unknown and empty settings fall back to on-failure, as in CreateContainer
+func restartPolicyName(policy string) string {
+	switch policy {
+	case "no", "always", "on-failure", "unless-stopped":
+		return policy
+	default:
+		return "on-failure"
+	}
+}
+
+func healthCheckFromConfig(hc *container.HealthConfig) *domain.EffectiveHealthCheck {
+	if hc == nil || len(hc.Test) == 0 || hc.Test[0] == "NONE" {
+		return nil
+	}
+	return &domain.EffectiveHealthCheck{
+		Test:     hc.Test,
+		Interval: hc.Interval,
+		Timeout:  hc.Timeout,
+		Retries:  hc.Retries,
+	}
+}
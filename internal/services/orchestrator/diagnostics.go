@@ -0,0 +1,122 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+	"github.com/nanopaas/nanopaas/internal/infrastructure/docker"
+)
+
+// DefaultDiagnosticLogLines is how many recent log lines are captured per container
+const DefaultDiagnosticLogLines = 200
+
+// RuntimeInfo identifies the container runtime the orchestrator drives
+type RuntimeInfo struct {
+	Name            string `json:"name"`
+	ServerVersion   string `json:"server_version,omitempty"`
+	OperatingSystem string `json:"operating_system,omitempty"`
+	KernelVersion   string `json:"kernel_version,omitempty"`
+	Error           string `json:"error,omitempty"`
+}
+
+// RuntimeInfo reports the container runtime and its version
+func (o *Orchestrator) RuntimeInfo(ctx context.Context) RuntimeInfo {
+	info := RuntimeInfo{Name: o.dockerClient.Name()}
+	raw, err := o.dockerClient.Info(ctx)
+	if err != nil {
+		info.Error = err.Error()
+		return info
+	}
+	info.ServerVersion = raw.ServerVersion
+	info.OperatingSystem = raw.OperatingSystem
+	info.KernelVersion = raw.KernelVersion
+	return info
+}
+
+// Diagnostics captures recent logs, redacted inspect output and a resource usage
+// sample for each of an app's containers. Failures for one container are recorded
+// in the snapshot rather than aborting the capture.
+func (o *Orchestrator) Diagnostics(ctx context.Context, app *domain.App, logLines int) *domain.DiagnosticSnapshot {
+	if logLines <= 0 {
+		logLines = DefaultDiagnosticLogLines
+	}
+
+	snapshot := &domain.DiagnosticSnapshot{
+		CapturedAt: time.Now().UTC(),
+		ImageID:    app.CurrentImageID,
+		Containers: []domain.ContainerDiagnostics{},
+	}
+	for _, containerID := range o.GetAppContainers(app.ID) {
+		diag, errs := o.containerDiagnostics(ctx, containerID, logLines)
+		snapshot.Containers = append(snapshot.Containers, diag)
+		snapshot.Errors = append(snapshot.Errors, errs...)
+	}
+	return snapshot
+}
+
+// containerDiagnostics captures a single container
+func (o *Orchestrator) containerDiagnostics(ctx context.Context, containerID string, logLines int) (domain.ContainerDiagnostics, []string) {
+	diag := domain.ContainerDiagnostics{ContainerID: shortContainerID(containerID)}
+	var errs []string
+
+	info, err := o.dockerClient.InspectContainer(ctx, containerID)
+	if err != nil {
+		return diag, append(errs, fmt.Sprintf("container %s: %v", diag.ContainerID, err))
+	}
+	diag.Name = strings.TrimPrefix(info.Name, "/")
+	if info.State != nil {
+		diag.State = info.State.Status
+	}
+	diag.Exit = ExitFromInspect(info)
+	if raw, err := json.Marshal(redactInspect(info)); err == nil {
+		diag.Inspect = raw
+	}
+
+	lines, err := o.dockerClient.ContainerLogLines(ctx, containerID, docker.LogOptions{Tail: strconv.Itoa(logLines)})
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("container %s logs: %v", diag.ContainerID, err))
+	}
+	for _, line := range lines {
+		diag.Logs = append(diag.Logs, line.Timestamp.Format(time.RFC3339Nano)+" "+string(line.Stream)+" "+line.Message)
+	}
+
+	if info.State != nil && info.State.Running {
+		stats, err := o.dockerClient.ContainerStats(ctx, containerID)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("container %s stats: %v", diag.ContainerID, err))
+		} else {
+			diag.Stats = &domain.ContainerStats{
+				CPUPercent:    stats.CPUPercent,
+				MemoryUsage:   stats.MemoryUsage,
+				MemoryLimit:   stats.MemoryLimit,
+				MemoryPercent: stats.MemoryPercent,
+				NetworkRx:     stats.NetworkRx,
+				NetworkTx:     stats.NetworkTx,
+				PIDs:          stats.PIDs,
+			}
+		}
+	}
+	return diag, errs
+}
+
+// redactInspect strips environment variable values, which hold app secrets
+func redactInspect(info types.ContainerJSON) types.ContainerJSON {
+	if info.Config == nil {
+		return info
+	}
+	config := *info.Config
+	config.Env = make([]string, len(info.Config.Env))
+	for i, kv := range info.Config.Env {
+		key, _, _ := strings.Cut(kv, "=")
+		config.Env[i] = key + "=[redacted]"
+	}
+	info.Config = &config
+	return info
+}
@@ -0,0 +1,113 @@
+package orchestrator
+
+import (
+	"context"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// ExitFromInspect returns the container's last exit, or nil if it is running and has never exited
+func ExitFromInspect(info types.ContainerJSON) *domain.ContainerExit {
+	if info.ContainerJSONBase == nil || info.State == nil {
+		return nil
+	}
+	state := info.State
+	if state.Running && !state.Restarting {
+		return nil
+	}
+
+	finishedAt, err := time.Parse(time.RFC3339Nano, state.FinishedAt)
+	if err != nil || finishedAt.IsZero() || finishedAt.Year() < 2000 {
+		// Created but never started
+		if state.Error == "" {
+			return nil
+		}
+		finishedAt = time.Now().UTC()
+	}
+
+	return &domain.ContainerExit{
+		ContainerID: shortContainerID(info.ID),
+		ExitCode:    state.ExitCode,
+		OOMKilled:   state.OOMKilled,
+		Error:       state.Error,
+		FinishedAt:  finishedAt.UTC(),
+	}
+}
+
+// SetContainerExitHandler sets a callback invoked when a managed container is found exited
+func (o *Orchestrator) SetContainerExitHandler(fn func(appID uuid.UUID, exit domain.ContainerExit)) {
+	o.onContainerExit = fn
+}
+
+// ContainerExits inspects an app's tracked containers and returns those that have exited with a failure
+func (o *Orchestrator) ContainerExits(ctx context.Context, appID uuid.UUID) []domain.ContainerExit {
+	var exits []domain.ContainerExit
+	for _, containerID := range o.GetAppContainers(appID) {
+		if exit := o.recordExit(ctx, appID, containerID); exit != nil && exit.Failed() {
+			exits = append(exits, *exit)
+		}
+	}
+	return exits
+}
+
+// recordExit inspects a container and returns its last exit, reporting it to the
+// deployment and exit handler the first time it is seen
+func (o *Orchestrator) recordExit(ctx context.Context, appID uuid.UUID, containerID string) *domain.ContainerExit {
+	info, err := o.dockerClient.InspectContainer(ctx, containerID)
+	if err != nil {
+		return nil
+	}
+	exit := ExitFromInspect(info)
+	if exit == nil {
+		return nil
+	}
+
+	o.exitsMu.Lock()
+	seen, ok := o.exitsSeen[containerID]
+	o.exitsSeen[containerID] = exit.FinishedAt
+	o.exitsMu.Unlock()
+	if ok && seen.Equal(exit.FinishedAt) {
+		return exit
+	}
+
+	var memoryLimit int64
+	if info.HostConfig != nil {
+		memoryLimit = info.HostConfig.Memory
+	}
+	o.logger.Warn("Container exited",
+		zap.String("app_id", appID.String()),
+		zap.String("container_id", exit.ContainerID),
+		zap.Int("exit_code", exit.ExitCode),
+		zap.Bool("oom_killed", exit.OOMKilled),
+		zap.String("hint", exit.Hint(memoryLimit)),
+	)
+
+	o.deploymentsMu.Lock()
+	for _, d := range o.deployments {
+		for _, id := range d.ContainerIDs {
+			if id == exit.ContainerID {
+				recorded := *exit
+				d.LastExit = &recorded
+			}
+		}
+	}
+	o.deploymentsMu.Unlock()
+
+	if o.onContainerExit != nil {
+		o.onContainerExit(appID, *exit)
+	}
+	return exit
+}
+
+// shortContainerID truncates a container ID to its 12-character short form
+func shortContainerID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
@@ -3,6 +3,7 @@ package orchestrator
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -34,7 +35,7 @@ func DefaultOrchestratorConfig() OrchestratorConfig {
 // Orchestrator manages container lifecycle and deployments
 type Orchestrator struct {
 	config       OrchestratorConfig
-	dockerClient *docker.Client
+	dockerClient docker.ContainerRuntime
 	logger       *zap.Logger
 
 	// Active deployments
@@ -45,6 +46,23 @@ type Orchestrator struct {
 	appContainers   map[uuid.UUID][]string // appID -> []containerID
 	appContainersMu sync.RWMutex
 
+	// Called when an app's containers may have new addresses (e.g. after a health restart)
+	onContainersChanged func(appID uuid.UUID)
+
+	// Called when a managed container is found exited; exitsSeen dedupes by finish time
+	onContainerExit func(appID uuid.UUID, exit domain.ContainerExit)
+	exitsSeen       map[string]time.Time // containerID -> last reported finish time
+	exitsMu         sync.Mutex
+
+	// Called with progress while a deployment pulls an image that isn't present locally
+	onPullProgress func(appID uuid.UUID, progress docker.PullProgress)
+
+	// Called when a deployment starts, succeeds or fails
+	onDeployment func(deployment *domain.Deployment)
+
+	// Decrypts app secrets for container env; nil when no master key is configured
+	secrets SecretOpener
+
 	// Health monitoring
 	ctx    context.Context
 	cancel context.CancelFunc
@@ -52,7 +70,7 @@ type Orchestrator struct {
 }
 
 // NewOrchestrator creates a new orchestrator
-func NewOrchestrator(config OrchestratorConfig, dockerClient *docker.Client, logger *zap.Logger) *Orchestrator {
+func NewOrchestrator(config OrchestratorConfig, dockerClient docker.ContainerRuntime, logger *zap.Logger) *Orchestrator {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	o := &Orchestrator{
@@ -61,6 +79,7 @@ func NewOrchestrator(config OrchestratorConfig, dockerClient *docker.Client, log
 		logger:        logger,
 		deployments:   make(map[uuid.UUID]*domain.Deployment),
 		appContainers: make(map[uuid.UUID][]string),
+		exitsSeen:     make(map[string]time.Time),
 		ctx:           ctx,
 		cancel:        cancel,
 	}
@@ -94,6 +113,11 @@ func (o *Orchestrator) Deploy(ctx context.Context, app *domain.App) (*domain.Dep
 	o.deployments[deployment.ID] = deployment
 	o.deploymentsMu.Unlock()
 
+	return deployment, o.runDeployment(ctx, app, deployment)
+}
+
+// runDeployment replaces the app's containers with the deployment's
+func (o *Orchestrator) runDeployment(ctx context.Context, app *domain.App, deployment *domain.Deployment) error {
 	o.logger.Info("Starting deployment",
 		zap.String("deployment_id", deployment.ID.String()),
 		zap.String("app_id", app.ID.String()),
@@ -102,13 +126,25 @@ func (o *Orchestrator) Deploy(ctx context.Context, app *domain.App) (*domain.Dep
 	)
 
 	// Mark as deploying
+	previousStatus := app.Status
 	app.MarkDeploying()
 	deployment.Start()
+	o.deploymentChanged(deployment)
 
 	// Deploy with timeout
 	deployCtx, cancel := context.WithTimeout(ctx, o.config.DeploymentTimeout)
 	defer cancel()
 
+	// Pull before stopping anything so the old containers keep serving during a long pull
+	if err := o.ensureImage(deployCtx, app.ID, app.CurrentImageID); err != nil {
+		// Nothing was stopped, so the app keeps running its previous image
+		deployment.Fail(err)
+		o.deploymentChanged(deployment)
+		app.Rollback()
+		app.Status = previousStatus
+		return err
+	}
+
 	// Stop old containers gracefully
 	if err := o.stopAppContainers(deployCtx, app.ID); err != nil {
 		o.logger.Warn("Failed to stop old containers", zap.Error(err))
@@ -118,6 +154,7 @@ func (o *Orchestrator) Deploy(ctx context.Context, app *domain.App) (*domain.Dep
 	containerIDs, err := o.startContainers(deployCtx, app, deployment)
 	if err != nil {
 		deployment.Fail(err)
+		o.deploymentChanged(deployment)
 		app.MarkFailed()
 
 		// Attempt rollback
@@ -131,7 +168,7 @@ func (o *Orchestrator) Deploy(ctx context.Context, app *domain.App) (*domain.Dep
 			}
 		}
 
-		return deployment, err
+		return err
 	}
 
 	// Track containers
@@ -141,8 +178,10 @@ func (o *Orchestrator) Deploy(ctx context.Context, app *domain.App) (*domain.Dep
 
 	// Success
 	deployment.Succeed(containerIDs)
+	o.deploymentChanged(deployment)
 	app.Replicas = len(containerIDs)
 	app.MarkRunning()
+	app.ClearPendingRestart()
 
 	o.logger.Info("Deployment succeeded",
 		zap.String("deployment_id", deployment.ID.String()),
@@ -151,26 +190,21 @@ func (o *Orchestrator) Deploy(ctx context.Context, app *domain.App) (*domain.Dep
 		zap.Duration("duration", deployment.Duration()),
 	)
 
-	return deployment, nil
+	return nil
 }
 
 // startContainers starts the specified number of container replicas
 func (o *Orchestrator) startContainers(ctx context.Context, app *domain.App, deployment *domain.Deployment) ([]string, error) {
 	containerIDs := make([]string, 0, app.TargetReplicas)
+	env, err := o.containerEnv(app)
+	if err != nil {
+		return nil, err
+	}
 
 	for i := 0; i < app.TargetReplicas; i++ {
 		containerName := app.GetContainerName(i)
 
-		opts := docker.ContainerOptions{
-			Name:          containerName,
-			Image:         app.CurrentImageID,
-			Env:           app.GetEnvSlice(),
-			Labels:        o.buildLabels(app, deployment, i),
-			ExposedPorts:  []string{fmt.Sprintf("%d", app.ExposedPort)},
-			Memory:        app.MemoryLimit,
-			CPUQuota:      app.CPUQuota,
-			RestartPolicy: "on-failure",
-		}
+		opts := containerOptions(app, containerName, env, o.buildLabels(app, deployment, i))
 
 		containerID, err := o.dockerClient.CreateContainer(ctx, opts)
 		if err != nil {
@@ -202,6 +236,27 @@ func (o *Orchestrator) startContainers(ctx context.Context, app *domain.App, dep
 	return containerIDs, nil
 }
 
+// containerOptions describes a replica of app's current image and configuration
+func containerOptions(app *domain.App, name string, env []string, labels map[string]string) docker.ContainerOptions {
+	return docker.ContainerOptions{
+		Name:          name,
+		Image:         app.CurrentImageID,
+		Env:           env,
+		Cmd:           app.Command,
+		Labels:        labels,
+		ExposedPorts:  app.ContainerPorts(),
+		Memory:        app.MemoryLimit,
+		CPUQuota:      app.CPUQuota,
+		RestartPolicy: app.RestartPolicy,
+		NoFileLimit:   app.NoFileLimit,
+		Tmpfs:         app.Tmpfs,
+		ShmSize:       app.ShmSize,
+		Sysctls:       app.Sysctls,
+		Binds:         app.VolumeBinds(),
+		Aliases:       app.NetworkAliases,
+	}
+}
+
 // buildLabels creates labels for a container
 func (o *Orchestrator) buildLabels(app *domain.App, deployment *domain.Deployment, replica int) map[string]string {
 	return map[string]string{
@@ -238,6 +293,12 @@ func (o *Orchestrator) stopAppContainers(ctx context.Context, appID uuid.UUID) e
 	delete(o.appContainers, appID)
 	o.appContainersMu.Unlock()
 
+	o.exitsMu.Lock()
+	for _, containerID := range containerIDs {
+		delete(o.exitsSeen, containerID)
+	}
+	o.exitsMu.Unlock()
+
 	if len(errs) > 0 {
 		return fmt.Errorf("errors stopping containers: %v", errs)
 	}
@@ -273,8 +334,10 @@ func (o *Orchestrator) rollback(ctx context.Context, app *domain.App) error {
 	o.appContainersMu.Unlock()
 
 	deployment.Succeed(containerIDs)
+	o.deploymentChanged(deployment)
 	app.Replicas = len(containerIDs)
 	app.MarkRunning()
+	app.ClearPendingRestart()
 
 	o.logger.Info("Rollback succeeded",
 		zap.String("app_id", app.ID.String()),
@@ -335,6 +398,7 @@ func (o *Orchestrator) Scale(ctx context.Context, app *domain.App, targetReplica
 		app.MarkRunning()
 	} else {
 		app.MarkStopped()
+		app.ClearPendingRestart()
 	}
 
 	return nil
@@ -350,20 +414,16 @@ func (o *Orchestrator) scaleUp(ctx context.Context, app *domain.App, currentCont
 		zap.Int("count", count),
 	)
 
+	env, err := o.containerEnv(app)
+	if err != nil {
+		return err
+	}
+
 	for i := 0; i < count; i++ {
 		replica := startReplica + i
 		containerName := app.GetContainerName(replica)
 
-		opts := docker.ContainerOptions{
-			Name:          containerName,
-			Image:         app.CurrentImageID,
-			Env:           app.GetEnvSlice(),
-			Labels:        o.buildScaleLabels(app, replica),
-			ExposedPorts:  []string{fmt.Sprintf("%d", app.ExposedPort)},
-			Memory:        app.MemoryLimit,
-			CPUQuota:      app.CPUQuota,
-			RestartPolicy: "on-failure",
-		}
+		opts := containerOptions(app, containerName, env, o.buildScaleLabels(app, replica))
 
 		o.logger.Debug("Creating container",
 			zap.String("name", containerName),
@@ -458,6 +518,7 @@ func (o *Orchestrator) Stop(ctx context.Context, app *domain.App) error {
 	}
 	app.MarkStopped()
 	app.Replicas = 0
+	app.ClearPendingRestart()
 
 	o.logger.Info("App stopped", zap.String("app_id", app.ID.String()))
 	return nil
@@ -487,6 +548,46 @@ func (o *Orchestrator) GetAppContainers(appID uuid.UUID) []string {
 	return o.appContainers[appID]
 }
 
+// SetContainersChangedHandler sets a callback invoked when an app's containers are restarted
+// outside of a request, so routes can pick up new container addresses
+func (o *Orchestrator) SetContainersChangedHandler(fn func(appID uuid.UUID)) {
+	o.onContainersChanged = fn
+}
+
+// SetDeploymentHandler sets a callback invoked when a deployment starts, succeeds or fails
+func (o *Orchestrator) SetDeploymentHandler(fn func(deployment *domain.Deployment)) {
+	o.onDeployment = fn
+}
+
+// deploymentChanged reports a deployment status change to the deployment handler
+func (o *Orchestrator) deploymentChanged(deployment *domain.Deployment) {
+	if o.onDeployment != nil {
+		o.onDeployment(deployment)
+	}
+}
+
+// ContainerIPs returns the IP address of each of an app's containers, keyed by container ID.
+// Containers whose address cannot be resolved are omitted.
+func (o *Orchestrator) ContainerIPs(ctx context.Context, appID uuid.UUID) map[string]string {
+	containerIDs := o.GetAppContainers(appID)
+	ips := make(map[string]string, len(containerIDs))
+
+	for _, containerID := range containerIDs {
+		ip, err := o.dockerClient.ContainerIP(ctx, containerID)
+		if err != nil {
+			o.logger.Warn("Failed to resolve container IP",
+				zap.String("app_id", appID.String()),
+				zap.String("container_id", containerID),
+				zap.Error(err),
+			)
+			continue
+		}
+		ips[containerID] = ip
+	}
+
+	return ips
+}
+
 // healthMonitor monitors container health
 func (o *Orchestrator) healthMonitor() {
 	defer o.wg.Done()
@@ -515,6 +616,7 @@ func (o *Orchestrator) checkContainerHealth() {
 	o.appContainersMu.RUnlock()
 
 	for appID, containerIDs := range appContainersCopy {
+		restarted := false
 		for _, containerID := range containerIDs {
 			healthy, err := o.dockerClient.HealthCheck(o.ctx, containerID)
 			if err != nil {
@@ -527,14 +629,22 @@ func (o *Orchestrator) checkContainerHealth() {
 			}
 
 			if !healthy {
+				o.recordExit(o.ctx, appID, containerID)
+
 				o.logger.Warn("Container unhealthy, restarting",
 					zap.String("app_id", appID.String()),
 					zap.String("container_id", containerID[:12]),
 				)
 				timeout := 10
-				o.dockerClient.RestartContainer(o.ctx, containerID, &timeout)
+				if err := o.dockerClient.RestartContainer(o.ctx, containerID, &timeout); err == nil {
+					restarted = true
+				}
 			}
 		}
+
+		if restarted && o.onContainersChanged != nil {
+			o.onContainersChanged(appID)
+		}
 	}
 }
 
@@ -554,6 +664,32 @@ func (o *Orchestrator) GetDeployment(deploymentID uuid.UUID) (*domain.Deployment
 	return d, ok
 }
 
+// CurrentDeployment returns the most recent succeeded deployment for an app
+func (o *Orchestrator) CurrentDeployment(appID uuid.UUID) (*domain.Deployment, bool) {
+	o.deploymentsMu.RLock()
+	defer o.deploymentsMu.RUnlock()
+
+	var current *domain.Deployment
+	for _, d := range o.deployments {
+		if d.AppID != appID || d.Status != domain.DeploymentStatusSucceeded {
+			continue
+		}
+		if current == nil || d.CreatedAt.After(current.CreatedAt) {
+			current = d
+		}
+	}
+	return current, current != nil
+}
+
+// SetDeploymentPinned marks a tracked deployment as pinned or unpinned
+func (o *Orchestrator) SetDeploymentPinned(deploymentID uuid.UUID, pinned bool) {
+	o.deploymentsMu.Lock()
+	defer o.deploymentsMu.Unlock()
+	if d, ok := o.deployments[deploymentID]; ok {
+		d.Pinned = pinned
+	}
+}
+
 // ListDeployments returns all active deployments
 func (o *Orchestrator) ListDeployments() []*domain.Deployment {
 	o.deploymentsMu.RLock()
@@ -565,3 +701,20 @@ func (o *Orchestrator) ListDeployments() []*domain.Deployment {
 	}
 	return deployments
 }
+
+// AppDeployments returns an app's deployments, newest first
+func (o *Orchestrator) AppDeployments(appID uuid.UUID) []*domain.Deployment {
+	o.deploymentsMu.RLock()
+	defer o.deploymentsMu.RUnlock()
+
+	deployments := make([]*domain.Deployment, 0)
+	for _, d := range o.deployments {
+		if d.AppID == appID {
+			deployments = append(deployments, d)
+		}
+	}
+	sort.Slice(deployments, func(i, j int) bool {
+		return deployments[i].CreatedAt.After(deployments[j].CreatedAt)
+	})
+	return deployments
+}
@@ -0,0 +1,43 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/infrastructure/docker"
+)
+
+// SetPullProgressHandler sets a callback receiving progress while a deploy pulls its image
+func (o *Orchestrator) SetPullProgressHandler(fn func(appID uuid.UUID, progress docker.PullProgress)) {
+	o.onPullProgress = fn
+}
+
+// ensureImage pulls the image when it is not present locally, reporting progress to the pull handler
+func (o *Orchestrator) ensureImage(ctx context.Context, appID uuid.UUID, imageRef string) error {
+	exists, err := o.dockerClient.ImageExists(ctx, imageRef)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	o.logger.Info("Pulling image for deployment",
+		zap.String("app_id", appID.String()),
+		zap.String("image", imageRef),
+	)
+
+	var progress docker.PullProgressFunc
+	if o.onPullProgress != nil {
+		progress = func(p docker.PullProgress) {
+			o.onPullProgress(appID, p)
+		}
+	}
+	if err := o.dockerClient.PullImageWithProgress(ctx, imageRef, progress); err != nil {
+		return fmt.Errorf("failed to pull image: %w", err)
+	}
+	return nil
+}
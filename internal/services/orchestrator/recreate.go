@@ -0,0 +1,142 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// rollingSettleDelay is how long a replaced replica runs before its health decides
+// whether the rolling recreate moves on to the next one
+const rollingSettleDelay = 3 * time.Second
+
+// Recreate replaces an app's containers with new ones from the same image, so they pick
+// up changed env vars, secrets and runtime options that a Docker restart would not.
+// A rolling recreate replaces one replica at a time and stops at the first replica that
+// fails to come up healthy, leaving the rest on the old configuration.
+func (o *Orchestrator) Recreate(ctx context.Context, app *domain.App, rolling bool) error {
+	o.appContainersMu.RLock()
+	current := append([]string(nil), o.appContainers[app.ID]...)
+	o.appContainersMu.RUnlock()
+	if len(current) == 0 {
+		app.ClearPendingRestart()
+		return nil
+	}
+
+	env, err := o.containerEnv(app)
+	if err != nil {
+		return err
+	}
+	labels := func(replica int) map[string]string {
+		if deployment, ok := o.CurrentDeployment(app.ID); ok {
+			return o.buildLabels(app, deployment, replica)
+		}
+		return o.buildScaleLabels(app, replica)
+	}
+
+	o.logger.Info("Recreating app containers",
+		zap.String("app_id", app.ID.String()),
+		zap.Int("replicas", len(current)),
+		zap.Bool("rolling", rolling),
+	)
+
+	if !rolling {
+		if err := o.stopAppContainers(ctx, app.ID); err != nil {
+			o.logger.Warn("Failed to stop old containers", zap.Error(err))
+		}
+	}
+
+	timeout := 30
+	replaced := make([]string, 0, len(current))
+	for i, oldID := range current {
+		if rolling {
+			if err := o.dockerClient.StopContainer(ctx, oldID, &timeout); err != nil {
+				o.logger.Warn("Failed to stop container", zap.String("container_id", oldID[:12]), zap.Error(err))
+			}
+			if err := o.dockerClient.RemoveContainer(ctx, oldID, true); err != nil {
+				o.logger.Warn("Failed to remove container", zap.String("container_id", oldID[:12]), zap.Error(err))
+			}
+			o.exitsMu.Lock()
+			delete(o.exitsSeen, oldID)
+			o.exitsMu.Unlock()
+		}
+
+		id, err := o.startReplica(ctx, app, i, env, labels(i))
+		if err == nil && rolling {
+			err = o.waitSettled(ctx, id)
+		}
+		if id != "" {
+			replaced = append(replaced, id)
+		}
+		if err != nil {
+			remaining := replaced
+			if rolling {
+				remaining = append(remaining, current[i+1:]...)
+			}
+			o.setAppContainers(app, remaining)
+			if len(remaining) == 0 {
+				app.MarkFailed()
+			}
+			return fmt.Errorf("failed to recreate replica %d: %w", i, err)
+		}
+		if rolling {
+			o.setAppContainers(app, append(append([]string(nil), replaced...), current[i+1:]...))
+		}
+	}
+
+	o.setAppContainers(app, replaced)
+	app.MarkRunning()
+	app.ClearPendingRestart()
+
+	o.logger.Info("App containers recreated",
+		zap.String("app_id", app.ID.String()),
+		zap.Int("replicas", len(replaced)),
+	)
+	return nil
+}
+
+// startReplica creates and starts one replica, returning its ID
+func (o *Orchestrator) startReplica(ctx context.Context, app *domain.App, replica int, env []string, labels map[string]string) (string, error) {
+	containerID, err := o.dockerClient.CreateContainer(ctx, containerOptions(app, app.GetContainerName(replica), env, labels))
+	if err != nil {
+		return "", err
+	}
+	if err := o.dockerClient.StartContainer(ctx, containerID); err != nil {
+		o.dockerClient.RemoveContainer(ctx, containerID, true)
+		return "", err
+	}
+	return containerID, nil
+}
+
+// waitSettled gives a new replica a moment to start, then checks it is healthy
+func (o *Orchestrator) waitSettled(ctx context.Context, containerID string) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(rollingSettleDelay):
+	}
+	healthy, err := o.dockerClient.HealthCheck(ctx, containerID)
+	if err != nil {
+		return err
+	}
+	if !healthy {
+		return fmt.Errorf("container %s is not healthy", containerID[:12])
+	}
+	return nil
+}
+
+// setAppContainers tracks an app's running containers and tells the router about them
+func (o *Orchestrator) setAppContainers(app *domain.App, containerIDs []string) {
+	o.appContainersMu.Lock()
+	o.appContainers[app.ID] = containerIDs
+	o.appContainersMu.Unlock()
+	app.Replicas = len(containerIDs)
+
+	if o.onContainersChanged != nil {
+		o.onContainersChanged(app.ID)
+	}
+}
@@ -0,0 +1,37 @@
+package orchestrator
+
+import (
+	"fmt"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// SecretOpener decrypts app secrets
+type SecretOpener interface {
+	Open(sealed, additionalData []byte) ([]byte, error)
+}
+
+// SetSecretOpener sets how app secrets are decrypted when containers start
+func (o *Orchestrator) SetSecretOpener(opener SecretOpener) {
+	o.secrets = opener
+}
+
+// containerEnv returns an app's env vars followed by its decrypted secrets. Secrets are
+// only decrypted here, as containers are created, so plaintext never outlives the call.
+func (o *Orchestrator) containerEnv(app *domain.App) ([]string, error) {
+	env := app.GetEnvSlice()
+	if len(app.Secrets) == 0 {
+		return env, nil
+	}
+	if o.secrets == nil {
+		return nil, fmt.Errorf("app has secrets but no secrets master key is configured")
+	}
+	for _, key := range app.SecretKeys() {
+		value, err := o.secrets.Open(app.Secrets[key].Ciphertext, domain.SecretAAD(app.ID, key))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt secret %s: %w", key, err)
+		}
+		env = append(env, key+"="+string(value))
+	}
+	return env, nil
+}
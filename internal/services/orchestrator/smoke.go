@@ -0,0 +1,173 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+	"github.com/nanopaas/nanopaas/internal/infrastructure/docker"
+)
+
+// maxSmokeBodySize limits how much of a smoke check response body is read
+const maxSmokeBodySize = 1024 * 1024 // 1MB
+
+// RunSmokeChecks runs the app's smoke checks against a deployment that is already receiving traffic.
+// On the first failure the deployment is annotated with the failing check and the app is rolled back.
+func (o *Orchestrator) RunSmokeChecks(ctx context.Context, app *domain.App, deployment *domain.Deployment, baseURL string) error {
+	if len(app.SmokeChecks) == 0 {
+		return nil
+	}
+
+	o.logger.Info("Running smoke checks",
+		zap.String("deployment_id", deployment.ID.String()),
+		zap.String("app_id", app.ID.String()),
+		zap.Int("checks", len(app.SmokeChecks)),
+	)
+
+	for _, check := range app.SmokeChecks {
+		start := time.Now()
+		err := o.runSmokeCheck(ctx, app, check, baseURL)
+
+		result := domain.SmokeCheckResult{
+			Name:     check.Name,
+			Passed:   err == nil,
+			Duration: time.Since(start),
+		}
+		if err != nil {
+			result.Message = err.Error()
+		}
+		deployment.RecordSmokeResult(result)
+
+		if err == nil {
+			continue
+		}
+
+		o.logger.Warn("Smoke check failed",
+			zap.String("deployment_id", deployment.ID.String()),
+			zap.String("check", check.Name),
+			zap.Error(err),
+		)
+
+		checkErr := fmt.Errorf("smoke check %q failed: %w", check.Name, err)
+		deployment.Fail(checkErr)
+		app.MarkFailed()
+		defer o.deploymentChanged(deployment) // Report the final failed or rolled back state once
+
+		if app.PreviousImageID == "" {
+			return checkErr
+		}
+
+		// Same automatic rollback path as a failed container start
+		if stopErr := o.stopAppContainers(ctx, app.ID); stopErr != nil {
+			o.logger.Warn("Failed to stop containers before rollback", zap.Error(stopErr))
+		}
+		if rollbackErr := o.rollback(ctx, app); rollbackErr != nil {
+			o.logger.Error("Rollback failed", zap.Error(rollbackErr))
+			return checkErr
+		}
+		deployment.MarkRolledBack(checkErr.Error())
+
+		return checkErr
+	}
+
+	o.logger.Info("Smoke checks passed",
+		zap.String("deployment_id", deployment.ID.String()),
+	)
+
+	return nil
+}
+
+// runSmokeCheck executes a single smoke check
+func (o *Orchestrator) runSmokeCheck(ctx context.Context, app *domain.App, check domain.SmokeCheck, baseURL string) error {
+	checkCtx, cancel := context.WithTimeout(ctx, check.Timeout())
+	defer cancel()
+
+	switch check.Type {
+	case domain.SmokeCheckHTTP:
+		return o.runHTTPSmokeCheck(checkCtx, check, baseURL)
+	case domain.SmokeCheckCommand:
+		return o.runCommandSmokeCheck(checkCtx, app, check)
+	default:
+		return fmt.Errorf("unknown smoke check type: %s", check.Type)
+	}
+}
+
+// runHTTPSmokeCheck requests the check path through the app's public route
+func (o *Orchestrator) runHTTPSmokeCheck(ctx context.Context, check domain.SmokeCheck, baseURL string) error {
+	method := strings.ToUpper(check.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(baseURL, "/")+check.Path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("User-Agent", "NanoPaaS-SmokeCheck")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	expected := check.ExpectedStatus
+	if expected == 0 {
+		expected = http.StatusOK
+	}
+	if resp.StatusCode != expected {
+		return fmt.Errorf("expected status %d, got %d", expected, resp.StatusCode)
+	}
+
+	if check.ExpectedBody != "" {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxSmokeBodySize))
+		if err != nil {
+			return fmt.Errorf("failed to read response: %w", err)
+		}
+		if !strings.Contains(string(body), check.ExpectedBody) {
+			return fmt.Errorf("response body does not contain %q", check.ExpectedBody)
+		}
+	}
+
+	return nil
+}
+
+// runCommandSmokeCheck runs the check command in a one-off container from the deployed image
+func (o *Orchestrator) runCommandSmokeCheck(ctx context.Context, app *domain.App, check domain.SmokeCheck) error {
+	env, err := o.containerEnv(app)
+	if err != nil {
+		return err
+	}
+	// The container is labelled apart from the app's replicas, not with nanopaas.app.id:
+	// replica adoption, log shipping and the app's container listings select by that
+	opts := docker.ContainerOptions{
+		Name:          fmt.Sprintf("%s-smoke-%d", app.Slug, time.Now().UnixNano()),
+		Image:         app.CurrentImageID,
+		Cmd:           check.Command,
+		Env:           env,
+		Labels:        map[string]string{"nanopaas.smoke": check.Name, "nanopaas.smoke.app.id": app.ID.String()},
+		Memory:        app.MemoryLimit,
+		CPUQuota:      app.CPUQuota,
+		RestartPolicy: "no",
+	}
+
+	containerID, err := o.dockerClient.CreateContainer(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("failed to create smoke check container: %w", err)
+	}
+	// Use a fresh context so cleanup still happens after a timeout
+	defer o.dockerClient.RemoveContainer(context.Background(), containerID, true)
+
+	if err := o.dockerClient.StartContainer(ctx, containerID); err != nil {
+		return fmt.Errorf("failed to start smoke check container: %w", err)
+	}
+
+	return o.dockerClient.WaitForContainer(ctx, containerID, container.WaitConditionNotRunning)
+}
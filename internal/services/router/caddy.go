@@ -0,0 +1,658 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// Caddy defaults
+const (
+	DefaultCaddyAdminURL = "http://localhost:2019"
+	DefaultCaddyServer   = "nanopaas"
+)
+
+// caddyPushTimeout bounds one configuration push to the admin API
+const caddyPushTimeout = 10 * time.Second
+
+// CaddyRouter serves apps through Caddy, replacing one named HTTP server in Caddy's
+// configuration through the admin API. Every push swaps the whole server at once, and
+// the rest of the Caddy configuration is left untouched. Caddy obtains certificates
+// itself through automatic HTTPS.
+type CaddyRouter struct {
+	config     RouterConfig
+	logger     *zap.Logger
+	httpClient *http.Client
+
+	// Active routes
+	routes   map[uuid.UUID]*Route
+	routesMu sync.RWMutex
+
+	// Serializes pushes so Caddy always ends up with the latest routes
+	pushMu sync.Mutex
+
+	// Outcome of configuration pushes
+	configs configRecorder
+
+	// Called with an app's host names when an HTTPS route is added
+	onHostRouted func(hosts []string)
+}
+
+// NewCaddyRouter creates a router backed by Caddy's admin API
+func NewCaddyRouter(config RouterConfig, logger *zap.Logger) (*CaddyRouter, error) {
+	if config.CaddyAdminURL == "" {
+		config.CaddyAdminURL = DefaultCaddyAdminURL
+	}
+	if config.CaddyServer == "" {
+		config.CaddyServer = DefaultCaddyServer
+	}
+	if _, err := url.Parse(config.CaddyAdminURL); err != nil {
+		return nil, fmt.Errorf("invalid caddy admin URL: %w", err)
+	}
+
+	r := &CaddyRouter{
+		config:     config,
+		logger:     logger,
+		httpClient: &http.Client{Timeout: caddyPushTimeout},
+		routes:     make(map[uuid.UUID]*Route),
+	}
+
+	// Start from an empty server so routes left by a previous run are dropped
+	ctx, cancel := context.WithTimeout(context.Background(), caddyPushTimeout)
+	defer cancel()
+	if err := r.push(ctx); err != nil {
+		logger.Warn("Caddy admin API not reachable yet", zap.String("admin_url", config.CaddyAdminURL), zap.Error(err))
+	}
+
+	logger.Info("Caddy router initialized",
+		zap.String("domain", config.Domain),
+		zap.String("admin_url", config.CaddyAdminURL),
+		zap.String("server", config.CaddyServer),
+	)
+
+	return r, nil
+}
+
+// AddRoute adds or updates a route for an app. Apps without an exposed port only
+// serve other apps on the shared network, so any existing route is removed instead.
+func (r *CaddyRouter) AddRoute(ctx context.Context, app *domain.App, replicas []Replica) error {
+	if app.ExposedPort <= 0 {
+		if _, routed := r.GetRoute(app.ID); routed {
+			return r.RemoveRoute(ctx, app.ID)
+		}
+		return nil
+	}
+
+	route := newRoute(r.config, app, replicas)
+	if route.isL4() {
+		return fmt.Errorf("%s routes are not supported by the caddy backend", route.Protocol)
+	}
+	if route.RateLimit != nil {
+		r.logger.Warn("Rate limits are not enforced by the caddy backend", zap.String("app_id", app.ID.String()))
+	}
+
+	r.routesMu.Lock()
+	previous, existed := r.routes[app.ID]
+	r.routes[app.ID] = route
+	r.routesMu.Unlock()
+
+	// Drop the route again if it was rejected so one bad app can't block every other update
+	if err := r.push(ctx); err != nil {
+		r.routesMu.Lock()
+		if existed {
+			r.routes[app.ID] = previous
+		} else {
+			delete(r.routes, app.ID)
+		}
+		r.routesMu.Unlock()
+		return fmt.Errorf("failed to push config: %w", err)
+	}
+
+	r.logger.Info("Route added",
+		zap.String("app_id", app.ID.String()),
+		zap.String("subdomain", app.Subdomain+"."+r.config.Domain),
+		zap.Int("replicas", len(replicas)),
+	)
+
+	if route.EnableHTTPS && r.onHostRouted != nil {
+		r.onHostRouted(route.hosts(r.config.Domain))
+	}
+
+	return nil
+}
+
+// RemoveRoute removes a route for an app
+func (r *CaddyRouter) RemoveRoute(ctx context.Context, appID uuid.UUID) error {
+	r.routesMu.Lock()
+	delete(r.routes, appID)
+	r.routesMu.Unlock()
+
+	if err := r.push(ctx); err != nil {
+		return fmt.Errorf("failed to push config: %w", err)
+	}
+
+	r.logger.Info("Route removed", zap.String("app_id", appID.String()))
+	return nil
+}
+
+// UpdateReplicas updates the replicas for a route
+func (r *CaddyRouter) UpdateReplicas(ctx context.Context, appID uuid.UUID, replicas []Replica) error {
+	return r.updateRoute(ctx, appID, func(route *Route) { route.Replicas = replicas })
+}
+
+// SetCORS replaces the CORS policy on an app's route; a nil policy removes it
+func (r *CaddyRouter) SetCORS(ctx context.Context, appID uuid.UUID, policy *domain.CORSPolicy) error {
+	return r.updateRoute(ctx, appID, func(route *Route) {
+		route.CORS = policy
+		setMiddleware(route, corsMiddlewareName(route.AppSlug), policy != nil)
+	})
+}
+
+// SetBasicAuth replaces the basic auth entry on an app's route; an empty entry removes it
+func (r *CaddyRouter) SetBasicAuth(ctx context.Context, appID uuid.UUID, entry string) error {
+	return r.updateRoute(ctx, appID, func(route *Route) {
+		route.BasicAuth = entry
+		setMiddleware(route, basicAuthMiddlewareName(route.AppSlug), entry != "")
+	})
+}
+
+// updateRoute applies a change to an existing route and pushes the result
+func (r *CaddyRouter) updateRoute(ctx context.Context, appID uuid.UUID, change func(route *Route)) error {
+	r.routesMu.Lock()
+	route, exists := r.routes[appID]
+	if !exists {
+		r.routesMu.Unlock()
+		return fmt.Errorf("route not found for app %s", appID)
+	}
+	change(route)
+	r.routesMu.Unlock()
+
+	if err := r.push(ctx); err != nil {
+		return fmt.Errorf("failed to push config: %w", err)
+	}
+	return nil
+}
+
+// GetRoute returns a route by app ID
+func (r *CaddyRouter) GetRoute(appID uuid.UUID) (*Route, bool) {
+	r.routesMu.RLock()
+	defer r.routesMu.RUnlock()
+	route, exists := r.routes[appID]
+	return route, exists
+}
+
+// ListRoutes returns all active routes
+func (r *CaddyRouter) ListRoutes() []*Route {
+	r.routesMu.RLock()
+	defer r.routesMu.RUnlock()
+
+	routes := make([]*Route, 0, len(r.routes))
+	for _, route := range r.routes {
+		routes = append(routes, route)
+	}
+	return routes
+}
+
+// WaitForRoute blocks until Caddy's running configuration holds the app's current
+// route. It returns nil immediately when verification is disabled.
+func (r *CaddyRouter) WaitForRoute(ctx context.Context, appID uuid.UUID) error {
+	if r.config.VerifyTimeout <= 0 {
+		return nil
+	}
+
+	route, exists := r.GetRoute(appID)
+	if !exists {
+		return fmt.Errorf("route not found for app %s", appID)
+	}
+	r.routesMu.RLock()
+	expected, err := normalizeJSON(r.caddyRoute(route))
+	r.routesMu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.config.VerifyTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(routeVerifyInterval)
+	defer ticker.Stop()
+
+	for {
+		var actual interface{}
+		err := r.admin(ctx, http.MethodGet, "/id/"+caddyRouteID(route.AppSlug), nil, &actual)
+		if err == nil && !reflect.DeepEqual(actual, expected) {
+			err = fmt.Errorf("caddy is serving an outdated route for %s", route.AppSlug)
+		}
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("caddy did not pick up the route within %s: %w", r.config.VerifyTimeout, err)
+		case <-ticker.C:
+		}
+	}
+}
+
+// GetAppURL returns the URL for an app
+func (r *CaddyRouter) GetAppURL(app *domain.App) string {
+	return appURL(r.config, app)
+}
+
+// ValidateSubdomain checks that a subdomain can be assigned to an app
+func (r *CaddyRouter) ValidateSubdomain(subdomain string) error {
+	return validateSubdomain(r.config, subdomain)
+}
+
+// AppHost returns the host name an app's subdomain is served on
+func (r *CaddyRouter) AppHost(app *domain.App) string {
+	return app.Subdomain + "." + r.config.Domain
+}
+
+// PlatformDomain returns the domain app subdomains are created under
+func (r *CaddyRouter) PlatformDomain() string {
+	return r.config.Domain
+}
+
+// HTTPSEnabled reports whether apps are served over HTTPS
+func (r *CaddyRouter) HTTPSEnabled() bool {
+	return r.config.EnableHTTPS
+}
+
+// SNIEnabled reports false: TCP routing needs the caddy-l4 plugin, which isn't assumed
+func (r *CaddyRouter) SNIEnabled() bool {
+	return false
+}
+
+// AllocatePort fails: the caddy backend only serves HTTP apps
+func (r *CaddyRouter) AllocatePort(appID uuid.UUID, protocol domain.RouteProtocol) (int, error) {
+	return 0, fmt.Errorf("%s routes are not supported by the caddy backend", protocol)
+}
+
+// ReleasePorts does nothing; the caddy backend allocates no ports
+func (r *CaddyRouter) ReleasePorts(appID uuid.UUID) {}
+
+// Traffic fails: Caddy's metrics are per server, not per app
+func (r *CaddyRouter) Traffic(ctx context.Context, appID uuid.UUID) (*TrafficStats, error) {
+	return nil, fmt.Errorf("%w: the caddy backend does not report per-app metrics", ErrTrafficUnavailable)
+}
+
+// SetCertificates ignores certificates issued by NanoPaaS; Caddy manages its own
+func (r *CaddyRouter) SetCertificates(certs []*domain.Certificate) error {
+	r.logger.Debug("Caddy manages its own certificates, ignoring update", zap.Int("count", len(certs)))
+	return nil
+}
+
+// SetHostRoutedHandler sets a callback invoked with an app's host names whenever an
+// HTTPS route is added
+func (r *CaddyRouter) SetHostRoutedHandler(fn func(hosts []string)) {
+	r.onHostRouted = fn
+}
+
+// Shutdown stops the router. Routes stay in Caddy so apps keep serving.
+func (r *CaddyRouter) Shutdown() {
+	r.logger.Info("Router stopped")
+}
+
+// PreviewRoute renders the Caddy route an app would get from its current settings,
+// keeping the replicas of its active route, without pushing it
+func (r *CaddyRouter) PreviewRoute(app *domain.App) (*RoutePreview, error) {
+	r.routesMu.RLock()
+	route, others, active := previewRoutes(r.config, r.routes, app)
+	config := r.caddyRoute(route)
+	var current map[string]interface{}
+	if active != nil {
+		current = r.caddyRoute(active)
+	}
+	r.routesMu.RUnlock()
+
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+	preview := &RoutePreview{
+		Backend: BackendCaddy,
+		Hosts:   route.hosts(r.config.Domain),
+		URL:     r.GetAppURL(app),
+		Config:  config,
+		Snippet: string(data),
+		Applied: current != nil && sameJSON(current, config),
+	}
+
+	err = validateConfig(append(others, route), r.config.Domain, nil)
+	if err == nil && route.isL4() {
+		err = fmt.Errorf("%s routes are not supported by the caddy backend", route.Protocol)
+	}
+	preview.setValidation(err)
+	return preview, nil
+}
+
+// push replaces the NanoPaaS server in Caddy's configuration with the current routes.
+// Caddy validates the configuration itself and keeps the previous one if it is rejected.
+func (r *CaddyRouter) push(ctx context.Context) error {
+	r.pushMu.Lock()
+	defer r.pushMu.Unlock()
+
+	routes := r.ListRoutes()
+	sort.Slice(routes, func(i, j int) bool { return routes[i].AppSlug < routes[j].AppSlug })
+
+	err := validateConfig(routes, r.config.Domain, nil)
+	if err == nil {
+		r.routesMu.RLock()
+		server := r.buildServer(routes)
+		r.routesMu.RUnlock()
+		err = r.load(ctx, server)
+	}
+	r.configs.record(err)
+	if err != nil {
+		r.logger.Error("Router config rejected, keeping the previous config", zap.Error(err))
+	}
+	return err
+}
+
+// ConfigStats returns counts of applied and rejected configurations
+func (r *CaddyRouter) ConfigStats() ConfigStats {
+	return r.configs.snapshot()
+}
+
+// load installs the NanoPaaS server, adding Caddy's HTTP app if it has none yet
+func (r *CaddyRouter) load(ctx context.Context, server map[string]interface{}) error {
+	var servers map[string]interface{}
+	if err := r.admin(ctx, http.MethodGet, "/config/apps/http/servers", nil, &servers); err != nil {
+		return err
+	}
+	if servers != nil {
+		return r.admin(ctx, http.MethodPost, "/config/apps/http/servers/"+r.config.CaddyServer, server, nil)
+	}
+
+	// Caddy has no HTTP app yet: add one holding only our server to the full configuration
+	var config map[string]interface{}
+	if err := r.admin(ctx, http.MethodGet, "/config/", nil, &config); err != nil {
+		return err
+	}
+	if config == nil {
+		config = make(map[string]interface{})
+	}
+	apps, _ := config["apps"].(map[string]interface{})
+	if apps == nil {
+		apps = make(map[string]interface{})
+		config["apps"] = apps
+	}
+	apps["http"] = map[string]interface{}{
+		"servers": map[string]interface{}{r.config.CaddyServer: server},
+	}
+	return r.admin(ctx, http.MethodPost, "/load", config, nil)
+}
+
+// admin calls Caddy's admin API, decoding the response into result if given
+func (r *CaddyRouter) admin(ctx context.Context, method, path string, body, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode caddy config: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(r.config.CaddyAdminURL, "/")+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("caddy admin API unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("caddy admin API returned %d for %s %s: %s", resp.StatusCode, method, path, strings.TrimSpace(string(message)))
+	}
+	if result != nil {
+		return json.NewDecoder(resp.Body).Decode(result)
+	}
+	return nil
+}
+
+// buildServer builds the Caddy HTTP server holding every app route
+func (r *CaddyRouter) buildServer(routes []*Route) map[string]interface{} {
+	caddyRoutes := make([]interface{}, 0, len(routes))
+	for _, route := range routes {
+		caddyRoutes = append(caddyRoutes, r.caddyRoute(route))
+	}
+
+	server := map[string]interface{}{
+		"routes": caddyRoutes,
+	}
+	switch {
+	case !r.config.EnableHTTPS:
+		server["listen"] = []string{fmt.Sprintf(":%d", r.config.HTTPPort)}
+		server["automatic_https"] = map[string]interface{}{"disable": true}
+	case r.config.RedirectHTTP:
+		// Caddy redirects plain HTTP to HTTPS on its own
+		server["listen"] = []string{fmt.Sprintf(":%d", r.config.HTTPSPort)}
+	default:
+		server["listen"] = []string{fmt.Sprintf(":%d", r.config.HTTPPort), fmt.Sprintf(":%d", r.config.HTTPSPort)}
+		server["automatic_https"] = map[string]interface{}{"disable_redirects": true}
+	}
+	return server
+}
+
+// caddyRouteID is the @id of an app's route, addressable at /id/<id> in the admin API
+func caddyRouteID(slug string) string {
+	return "nanopaas-" + slug
+}
+
+// caddyRoute builds an app's route: a host matcher in front of a subroute applying
+// the allowlist, basic auth, headers and CORS before proxying to the replicas
+func (r *CaddyRouter) caddyRoute(route *Route) map[string]interface{} {
+	var steps []interface{}
+
+	if len(route.IPAllowList) > 0 {
+		steps = append(steps, map[string]interface{}{
+			"match": []interface{}{map[string]interface{}{
+				"not": []interface{}{map[string]interface{}{
+					"remote_ip": map[string]interface{}{"ranges": route.IPAllowList},
+				}},
+			}},
+			"handle":   []interface{}{staticResponse(http.StatusForbidden)},
+			"terminal": true,
+		})
+	}
+
+	if user, hash, ok := strings.Cut(route.BasicAuth, ":"); ok {
+		steps = append(steps, map[string]interface{}{
+			"handle": []interface{}{map[string]interface{}{
+				"handler": "authentication",
+				"providers": map[string]interface{}{
+					"http_basic": map[string]interface{}{
+						"hash":     map[string]interface{}{"algorithm": "bcrypt"},
+						"accounts": []interface{}{map[string]interface{}{"username": user, "password": hash}},
+					},
+				},
+			}},
+		})
+	}
+
+	responseHeaders := map[string][]string{"X-Powered-By": {"NanoPaaS"}}
+	if route.EnableHTTPS && route.HSTS != nil {
+		responseHeaders["Strict-Transport-Security"] = []string{hstsValue(route.HSTS)}
+	}
+	requestHeaders := make(map[string][]string, len(route.Headers))
+	for name, value := range route.Headers {
+		requestHeaders[name] = []string{value}
+	}
+	steps = append(steps, map[string]interface{}{
+		"handle": []interface{}{map[string]interface{}{
+			"handler":  "headers",
+			"request":  map[string]interface{}{"set": requestHeaders},
+			"response": map[string]interface{}{"set": responseHeaders},
+		}},
+	})
+
+	if route.CORS != nil {
+		steps = append(steps, caddyCORSRoutes(route.CORS)...)
+	}
+
+	steps = append(steps, map[string]interface{}{
+		"handle": r.caddyUpstream(route),
+	})
+
+	return map[string]interface{}{
+		"@id":      caddyRouteID(route.AppSlug),
+		"match":    []interface{}{map[string]interface{}{"host": route.hosts(r.config.Domain)}},
+		"handle":   []interface{}{map[string]interface{}{"handler": "subroute", "routes": steps}},
+		"terminal": true,
+	}
+}
+
+// caddyUpstream returns the handlers serving a route: the maintenance page, a plain
+// 503 while traffic is cut off, or a reverse proxy to the replicas
+func (r *CaddyRouter) caddyUpstream(route *Route) []interface{} {
+	if route.MaintenancePage {
+		target, err := url.Parse(r.config.MaintenanceURL)
+		if err == nil {
+			return []interface{}{
+				map[string]interface{}{"handler": "rewrite", "uri": MaintenancePathPrefix + route.AppID.String()},
+				map[string]interface{}{"handler": "reverse_proxy", "upstreams": []interface{}{map[string]interface{}{"dial": dialAddress(target)}}},
+			}
+		}
+	}
+	if route.Maintenance || route.MaintenancePage {
+		return []interface{}{staticResponse(http.StatusServiceUnavailable)}
+	}
+
+	upstreams := make([]interface{}, 0, len(route.Replicas))
+	for _, replica := range route.servers() {
+		upstreams = append(upstreams, map[string]interface{}{"dial": fmt.Sprintf("%s:%d", replica.IPAddress, replica.Port)})
+	}
+	proxy := map[string]interface{}{
+		"handler":   "reverse_proxy",
+		"upstreams": upstreams,
+		"health_checks": map[string]interface{}{
+			"active": map[string]interface{}{
+				"uri":      "/health",
+				"interval": "10s",
+				"timeout":  "3s",
+			},
+		},
+	}
+	if route.Sticky != nil {
+		proxy["load_balancing"] = map[string]interface{}{
+			"selection_policy": map[string]interface{}{"policy": "cookie", "name": route.Sticky.CookieName},
+		}
+	}
+	if route.StreamingMode == domain.StreamingSSE {
+		// Flush every event to the client instead of buffering the response
+		proxy["flush_interval"] = -1
+	}
+	return []interface{}{proxy}
+}
+
+// caddyCORSRoutes answers preflight requests and adds CORS headers for allowed origins
+func caddyCORSRoutes(policy *domain.CORSPolicy) []interface{} {
+	var routes []interface{}
+	for _, origin := range policy.AllowOrigins {
+		headers := map[string][]string{
+			"Access-Control-Allow-Origin":  {origin},
+			"Access-Control-Allow-Methods": {strings.Join(policy.AllowMethods, ", ")},
+			"Vary":                         {"Origin"},
+		}
+		if len(policy.AllowHeaders) > 0 {
+			headers["Access-Control-Allow-Headers"] = []string{strings.Join(policy.AllowHeaders, ", ")}
+		}
+		if len(policy.ExposeHeaders) > 0 {
+			headers["Access-Control-Expose-Headers"] = []string{strings.Join(policy.ExposeHeaders, ", ")}
+		}
+		if policy.AllowCredentials {
+			headers["Access-Control-Allow-Credentials"] = []string{"true"}
+		}
+		if policy.MaxAge > 0 {
+			headers["Access-Control-Max-Age"] = []string{strconv.Itoa(policy.MaxAge)}
+		}
+
+		match := map[string]interface{}{}
+		if origin != "*" {
+			match["header"] = map[string][]string{"Origin": {origin}}
+		}
+		routes = append(routes, map[string]interface{}{
+			"match":  []interface{}{match},
+			"handle": []interface{}{map[string]interface{}{"handler": "headers", "response": map[string]interface{}{"set": headers}}},
+		})
+
+		preflight := map[string]interface{}{
+			"method": []string{http.MethodOptions},
+			"header": map[string][]string{"Access-Control-Request-Method": {"*"}},
+		}
+		if origin != "*" {
+			preflight["header"] = map[string][]string{"Origin": {origin}, "Access-Control-Request-Method": {"*"}}
+		}
+		routes = append(routes, map[string]interface{}{
+			"match":    []interface{}{preflight},
+			"handle":   []interface{}{staticResponse(http.StatusNoContent)},
+			"terminal": true,
+		})
+	}
+	return routes
+}
+
+// hstsValue renders an HSTS policy as a Strict-Transport-Security header value
+func hstsValue(policy *domain.HSTSPolicy) string {
+	value := "max-age=" + strconv.Itoa(policy.MaxAge)
+	if policy.IncludeSubdomains {
+		value += "; includeSubDomains"
+	}
+	if policy.Preload {
+		value += "; preload"
+	}
+	return value
+}
+
+// staticResponse builds a handler answering with a bare status code
+func staticResponse(status int) map[string]interface{} {
+	return map[string]interface{}{"handler": "static_response", "status_code": status}
+}
+
+// dialAddress returns host:port for a URL, defaulting the port from the scheme
+func dialAddress(u *url.URL) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	if u.Scheme == "https" {
+		return u.Hostname() + ":443"
+	}
+	return u.Hostname() + ":80"
+}
+
+// normalizeJSON round-trips a value through JSON so it compares equal to decoded API responses
+func normalizeJSON(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var normalized interface{}
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
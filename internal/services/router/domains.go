@@ -0,0 +1,98 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// DefaultReservedSubdomains are platform host names apps can't claim
+var DefaultReservedSubdomains = []string{"api", "www", "traefik"}
+
+// dnsCheckLabel is looked up under the platform domain to confirm a wildcard record exists
+const dnsCheckLabel = "nanopaas-wildcard-check"
+
+// NormalizePlatformDomain accepts the platform domain as "apps.example.com" or
+// "*.apps.example.com" and returns the base domain app subdomains are created under
+func NormalizePlatformDomain(platformDomain string) (string, error) {
+	d := strings.TrimPrefix(domain.NormalizeHostname(platformDomain), "*.")
+	if isLocalDomain(d) {
+		return d, nil
+	}
+	if strings.Contains(d, "*") {
+		return "", fmt.Errorf("platform domain %q may only have a wildcard as its first label", platformDomain)
+	}
+	if err := domain.ValidateHostname(d); err != nil {
+		return "", fmt.Errorf("invalid platform domain %q: %w", platformDomain, err)
+	}
+	return d, nil
+}
+
+// isLocalDomain reports whether a domain only resolves locally, so DNS checks are skipped
+func isLocalDomain(d string) bool {
+	return d == "localhost" || strings.HasSuffix(d, ".localhost") || net.ParseIP(d) != nil
+}
+
+// validateSubdomain checks that an app subdomain is a single DNS label that doesn't
+// shadow a reserved platform host
+func validateSubdomain(config RouterConfig, subdomain string) error {
+	if len(subdomain) == 0 || len(subdomain) > 63 {
+		return fmt.Errorf("subdomain must be between 1 and 63 characters")
+	}
+	if subdomain[0] == '-' || subdomain[len(subdomain)-1] == '-' {
+		return fmt.Errorf("subdomain must not start or end with a hyphen")
+	}
+	for _, r := range subdomain {
+		if !(r >= 'a' && r <= 'z') && !(r >= '0' && r <= '9') && r != '-' {
+			return fmt.Errorf("subdomain may only contain lowercase letters, digits and hyphens")
+		}
+	}
+
+	reserved := config.ReservedSubdomains
+	if reserved == nil {
+		reserved = DefaultReservedSubdomains
+	}
+	for _, name := range reserved {
+		if strings.EqualFold(subdomain, name) {
+			return fmt.Errorf("subdomain %q is reserved by the platform", subdomain)
+		}
+	}
+	return nil
+}
+
+// CheckPlatformDNS confirms that the wildcard record for app subdomains resolves and,
+// when an app is served on the apex, that the apex resolves to the same router. Local
+// domains are skipped.
+func CheckPlatformDNS(ctx context.Context, config RouterConfig, resolver *net.Resolver) error {
+	if isLocalDomain(config.Domain) {
+		return nil
+	}
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	wildcard, err := resolver.LookupHost(ctx, dnsCheckLabel+"."+config.Domain)
+	if err != nil {
+		return fmt.Errorf("no wildcard record: *.%s does not resolve: %w", config.Domain, err)
+	}
+	if config.ApexApp == "" {
+		return nil
+	}
+
+	apex, err := resolver.LookupHost(ctx, config.Domain)
+	if err != nil {
+		return fmt.Errorf("apex %s does not resolve: %w", config.Domain, err)
+	}
+	for _, addr := range apex {
+		for _, w := range wildcard {
+			if addr == w {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("apex %s resolves to %s but *.%s to %s; both must point at the router",
+		config.Domain, strings.Join(apex, ", "), config.Domain, strings.Join(wildcard, ", "))
+}
@@ -0,0 +1,241 @@
+package router
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// portPool hands out router ports from a range of statically declared entrypoints
+type portPool struct {
+	first, last int
+	owners      map[int]uuid.UUID
+}
+
+// parsePortPool parses "10000-10019" or a single port; an empty spec disables the pool
+func parsePortPool(spec string) (*portPool, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, nil
+	}
+	lo, hi, found := strings.Cut(spec, "-")
+	first, err := strconv.Atoi(strings.TrimSpace(lo))
+	if err != nil {
+		return nil, fmt.Errorf("invalid port range %q", spec)
+	}
+	last := first
+	if found {
+		if last, err = strconv.Atoi(strings.TrimSpace(hi)); err != nil {
+			return nil, fmt.Errorf("invalid port range %q", spec)
+		}
+	}
+	if first < 1 || last > 65535 || last < first {
+		return nil, fmt.Errorf("invalid port range %q", spec)
+	}
+	return &portPool{first: first, last: last, owners: make(map[int]uuid.UUID)}, nil
+}
+
+// allocate returns the app's port, claiming the lowest free one if it has none
+func (p *portPool) allocate(appID uuid.UUID) (int, error) {
+	for port, owner := range p.owners {
+		if owner == appID {
+			return port, nil
+		}
+	}
+	for port := p.first; port <= p.last; port++ {
+		if _, taken := p.owners[port]; !taken {
+			p.owners[port] = appID
+			return port, nil
+		}
+	}
+	return 0, fmt.Errorf("all %d ports in %d-%d are allocated", p.last-p.first+1, p.first, p.last)
+}
+
+// reserve records a port allocated before a restart
+func (p *portPool) reserve(port int, appID uuid.UUID) error {
+	if port < p.first || port > p.last {
+		return fmt.Errorf("port %d is outside %d-%d", port, p.first, p.last)
+	}
+	if owner, taken := p.owners[port]; taken && owner != appID {
+		return fmt.Errorf("port %d is allocated to another app", port)
+	}
+	p.owners[port] = appID
+	return nil
+}
+
+// release frees every port held by an app
+func (p *portPool) release(appID uuid.UUID) {
+	for port, owner := range p.owners {
+		if owner == appID {
+			delete(p.owners, port)
+		}
+	}
+}
+
+// ports lists every port in the pool
+func (p *portPool) ports() []int {
+	ports := make([]int, 0, p.last-p.first+1)
+	for port := p.first; port <= p.last; port++ {
+		ports = append(ports, port)
+	}
+	return ports
+}
+
+// pool returns the port pool of a protocol, nil when none is configured
+func (r *TraefikRouter) pool(protocol domain.RouteProtocol) *portPool {
+	if protocol == domain.RouteProtocolUDP {
+		return r.udpPorts
+	}
+	return r.tcpPorts
+}
+
+// AllocatePort claims a router port for an app's TCP or UDP route. The port is kept
+// until ReleasePorts, so it survives redeploys.
+func (r *TraefikRouter) AllocatePort(appID uuid.UUID, protocol domain.RouteProtocol) (int, error) {
+	r.portsMu.Lock()
+	defer r.portsMu.Unlock()
+
+	pool := r.pool(protocol)
+	if pool == nil {
+		return 0, fmt.Errorf("no %s ports are configured on the router", protocol)
+	}
+	return pool.allocate(appID)
+}
+
+// ReleasePorts frees the router ports held by an app
+func (r *TraefikRouter) ReleasePorts(appID uuid.UUID) {
+	r.portsMu.Lock()
+	defer r.portsMu.Unlock()
+
+	for _, pool := range []*portPool{r.tcpPorts, r.udpPorts} {
+		if pool != nil {
+			pool.release(appID)
+		}
+	}
+}
+
+// reservePort records an app's previously allocated port when its route is restored
+func (r *TraefikRouter) reservePort(appID uuid.UUID, protocol domain.RouteProtocol, port int) error {
+	r.portsMu.Lock()
+	defer r.portsMu.Unlock()
+
+	pool := r.pool(protocol)
+	if pool == nil {
+		return fmt.Errorf("no %s ports are configured on the router", protocol)
+	}
+	return pool.reserve(port, appID)
+}
+
+// SNIEnabled reports whether TCP routes can share the HTTPS port, routed by server name
+func (r *TraefikRouter) SNIEnabled() bool {
+	return r.config.EnableHTTPS
+}
+
+// isL4 reports whether a route is a TCP or UDP route
+func (route *Route) isL4() bool {
+	return route.Protocol == domain.RouteProtocolTCP || route.Protocol == domain.RouteProtocolUDP
+}
+
+// l4Name names an app's TCP or UDP router and service
+func l4Name(route *Route) string {
+	return route.AppSlug + "-" + string(route.Protocol)
+}
+
+// l4EntryPoint names the static entrypoint of an allocated port
+func l4EntryPoint(protocol domain.RouteProtocol, port int) string {
+	return fmt.Sprintf("%s-%d", protocol, port)
+}
+
+// splitRoutes separates HTTP routes from TCP and UDP routes
+func splitRoutes(routes []*Route) (httpRoutes, l4Routes []*Route) {
+	for _, route := range routes {
+		if route.isL4() {
+			l4Routes = append(l4Routes, route)
+		} else {
+			httpRoutes = append(httpRoutes, route)
+		}
+	}
+	sort.Slice(l4Routes, func(i, j int) bool { return l4Routes[i].AppSlug < l4Routes[j].AppSlug })
+	return httpRoutes, l4Routes
+}
+
+// hostSNIRule matches TLS connections for any of a route's hosts
+func (r *TraefikRouter) hostSNIRule(route *Route) string {
+	hosts := r.routeHosts(route)
+	rules := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		rules = append(rules, fmt.Sprintf("HostSNI(`%s`)", host))
+	}
+	return strings.Join(rules, " || ")
+}
+
+// l4Addresses returns the backend addresses of a TCP or UDP route
+func l4Addresses(route *Route) []string {
+	addresses := make([]string, 0, len(route.Replicas))
+	for _, replica := range route.servers() {
+		addresses = append(addresses, fmt.Sprintf("%s:%d", replica.IPAddress, replica.Port))
+	}
+	return addresses
+}
+
+// buildL4Config builds the tcp and udp sections of the dynamic configuration
+func (r *TraefikRouter) buildL4Config(routes []*Route) map[string]interface{} {
+	sections := make(map[string]interface{})
+	for _, route := range routes {
+		protocol := string(route.Protocol)
+		section, ok := sections[protocol].(map[string]interface{})
+		if !ok {
+			section = map[string]interface{}{
+				"routers":  make(map[string]interface{}),
+				"services": make(map[string]interface{}),
+			}
+			sections[protocol] = section
+		}
+
+		router := map[string]interface{}{
+			"service": l4Name(route),
+		}
+		switch {
+		case route.SNI:
+			router["entryPoints"] = []string{"websecure"}
+			router["rule"] = r.hostSNIRule(route)
+			router["tls"] = r.routerTLS()
+		case route.Protocol == domain.RouteProtocolTCP:
+			router["entryPoints"] = []string{l4EntryPoint(route.Protocol, route.PublicPort)}
+			router["rule"] = "HostSNI(`*`)"
+		default:
+			router["entryPoints"] = []string{l4EntryPoint(route.Protocol, route.PublicPort)}
+		}
+		section["routers"].(map[string]interface{})[l4Name(route)] = router
+
+		servers := make([]map[string]interface{}, 0, len(route.Replicas))
+		for _, address := range l4Addresses(route) {
+			servers = append(servers, map[string]interface{}{"address": address})
+		}
+		section["services"].(map[string]interface{})[l4Name(route)] = map[string]interface{}{
+			"loadBalancer": map[string]interface{}{"servers": servers},
+		}
+	}
+	return sections
+}
+
+// l4EntryPointsYAML declares the static entrypoints of the TCP and UDP port pools
+func (r *TraefikRouter) l4EntryPointsYAML() string {
+	result := ""
+	if r.tcpPorts != nil {
+		for _, port := range r.tcpPorts.ports() {
+			result += fmt.Sprintf("  %s:\n    address: \":%d\"\n", l4EntryPoint(domain.RouteProtocolTCP, port), port)
+		}
+	}
+	if r.udpPorts != nil {
+		for _, port := range r.udpPorts.ports() {
+			result += fmt.Sprintf("  %s:\n    address: \":%d/udp\"\n", l4EntryPoint(domain.RouteProtocolUDP, port), port)
+		}
+	}
+	return result
+}
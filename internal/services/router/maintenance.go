@@ -0,0 +1,36 @@
+package router
+
+// MaintenancePathPrefix is where NanoPaaS serves maintenance pages, followed by the app ID
+const MaintenancePathPrefix = "/_nanopaas/maintenance/"
+
+// maintenanceName names an app's maintenance service and path rewrite middleware
+func maintenanceName(slug string) string {
+	return slug + "-maintenance"
+}
+
+// routerService returns the service an app's routers send traffic to: the NanoPaaS
+// maintenance page while the app is in maintenance, otherwise the app's replicas
+func routerService(route *Route) string {
+	if route.MaintenancePage {
+		return maintenanceName(route.AppSlug)
+	}
+	return route.ServiceName
+}
+
+// maintenanceMiddleware rewrites every request to the app's maintenance page
+func maintenanceMiddleware(route *Route) map[string]interface{} {
+	return map[string]interface{}{
+		"replacePath": map[string]interface{}{
+			"path": MaintenancePathPrefix + route.AppID.String(),
+		},
+	}
+}
+
+// maintenanceService sends maintenance traffic to NanoPaaS
+func (r *TraefikRouter) maintenanceService() map[string]interface{} {
+	return map[string]interface{}{
+		"loadBalancer": map[string]interface{}{
+			"servers": []map[string]interface{}{{"url": r.config.MaintenanceURL}},
+		},
+	}
+}
@@ -0,0 +1,105 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// RoutePreview is the proxy configuration an app's current settings would produce
+type RoutePreview struct {
+	Backend string                 `json:"backend"`
+	Hosts   []string               `json:"hosts"`
+	URL     string                 `json:"url"`
+	Config  map[string]interface{} `json:"config"`
+	Snippet string                 `json:"snippet"` // Config in the backend's own format
+
+	// Whether the configuration would pass validation alongside every other route
+	Valid bool   `json:"valid"`
+	Error string `json:"error,omitempty"`
+
+	// Whether the proxy already serves exactly this configuration
+	Applied bool `json:"applied"`
+}
+
+// PreviewRoute renders the dynamic configuration an app would get from its current
+// settings, keeping the replicas of its active route, without applying it. Certificates
+// are left out so private keys are never returned.
+func (r *TraefikRouter) PreviewRoute(app *domain.App) (*RoutePreview, error) {
+	route, others, active := r.previewRoutes(app)
+
+	config := r.buildTraefikConfig([]*Route{route})
+	delete(config, "tls")
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+	preview := &RoutePreview{
+		Backend: BackendTraefik,
+		Hosts:   route.hosts(r.config.Domain),
+		URL:     r.GetAppURL(app),
+		Config:  config,
+		Snippet: string(data),
+	}
+
+	full, err := json.Marshal(r.buildTraefikConfig(append(others, route)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+	var parsed map[string]interface{}
+	if err := json.Unmarshal(full, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse generated config: %w", err)
+	}
+	preview.setValidation(validateConfig(append(others, route), r.config.Domain, parsed))
+
+	if active != nil {
+		current := r.buildTraefikConfig([]*Route{active})
+		delete(current, "tls")
+		preview.Applied = sameJSON(current, config)
+	}
+	return preview, nil
+}
+
+// previewRoutes builds the app's route from its settings and returns it with the other
+// active routes and the app's active route, nil when it has none
+func (r *TraefikRouter) previewRoutes(app *domain.App) (route *Route, others []*Route, active *Route) {
+	r.routesMu.RLock()
+	defer r.routesMu.RUnlock()
+	return previewRoutes(r.config, r.routes, app)
+}
+
+// previewRoutes is shared by the backends; the caller holds the routes lock
+func previewRoutes(config RouterConfig, routes map[uuid.UUID]*Route, app *domain.App) (route *Route, others []*Route, active *Route) {
+	var replicas []Replica
+	if existing, ok := routes[app.ID]; ok {
+		active = existing
+		replicas = existing.Replicas
+	}
+	route = newRoute(config, app, replicas)
+	for id, other := range routes {
+		if id != app.ID {
+			others = append(others, other)
+		}
+	}
+	return route, others, active
+}
+
+// setValidation records the outcome of validating a preview
+func (p *RoutePreview) setValidation(err error) {
+	p.Valid = err == nil
+	if err != nil {
+		p.Error = err.Error()
+	}
+}
+
+// sameJSON reports whether two configurations encode identically
+func sameJSON(a, b interface{}) bool {
+	na, errA := normalizeJSON(a)
+	nb, errB := normalizeJSON(b)
+	return errA == nil && errB == nil && reflect.DeepEqual(na, nb)
+}
@@ -0,0 +1,185 @@
+package router
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Dynamic configuration providers
+const (
+	ProviderFile = "file" // write dynamic.yml for Traefik's file provider
+	ProviderHTTP = "http" // serve the configuration to Traefik's HTTP provider
+)
+
+// routeVerifyInterval is how often the Traefik API is polled while waiting for a route
+const routeVerifyInterval = 500 * time.Millisecond
+
+// providerSnapshot is the configuration served to the HTTP provider. It is replaced
+// whole on every change, so Traefik never sees a partially updated configuration.
+type providerSnapshot struct {
+	body []byte
+	etag string
+}
+
+// publishSnapshot replaces the configuration served to Traefik's HTTP provider
+func (r *TraefikRouter) publishSnapshot(body []byte) {
+	sum := sha256.Sum256(body)
+
+	r.snapshotMu.Lock()
+	r.snapshot = &providerSnapshot{body: body, etag: `"` + hex.EncodeToString(sum[:8]) + `"`}
+	r.snapshotMu.Unlock()
+}
+
+// ServeProviderConfig serves the dynamic configuration to Traefik's HTTP provider.
+// The configuration holds certificate keys and password hashes, so requests must carry
+// the provider token as a bearer token.
+func (r *TraefikRouter) ServeProviderConfig(w http.ResponseWriter, req *http.Request) {
+	token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if r.config.ProviderToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(r.config.ProviderToken)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	r.snapshotMu.RLock()
+	snapshot := r.snapshot
+	r.snapshotMu.RUnlock()
+	if snapshot == nil {
+		http.Error(w, "configuration not ready", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("ETag", snapshot.etag)
+	if req.Header.Get("If-None-Match") == snapshot.etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(snapshot.body)
+}
+
+// WaitForRoute blocks until Traefik reports the app's router as enabled and its service
+// balancing across exactly the route's current replicas. It returns nil immediately
+// when verification is disabled.
+func (r *TraefikRouter) WaitForRoute(ctx context.Context, appID uuid.UUID) error {
+	if r.config.TraefikAPI == "" || r.config.VerifyTimeout <= 0 {
+		return nil
+	}
+
+	r.routesMu.RLock()
+	route, exists := r.routes[appID]
+	var expected []string
+	kind, routerName, serviceName := "http", "", ""
+	if exists {
+		routerName, serviceName = route.AppSlug+"-router", route.ServiceName
+		if route.isL4() {
+			kind, routerName, serviceName = string(route.Protocol), l4Name(route), l4Name(route)
+			expected = l4Addresses(route)
+		} else {
+			for _, replica := range route.servers() {
+				expected = append(expected, fmt.Sprintf("http://%s:%d", replica.IPAddress, replica.Port))
+			}
+		}
+	}
+	r.routesMu.RUnlock()
+	if !exists {
+		return fmt.Errorf("route not found for app %s", appID)
+	}
+	sort.Strings(expected)
+
+	ctx, cancel := context.WithTimeout(ctx, r.config.VerifyTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(routeVerifyInterval)
+	defer ticker.Stop()
+
+	for {
+		err := r.checkRoute(ctx, kind, routerName, serviceName, expected)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("traefik did not pick up the route within %s: %w", r.config.VerifyTimeout, err)
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkRoute compares Traefik's view of an app's router and service with the expected
+// replicas. kind is the Traefik API section: http, tcp or udp.
+func (r *TraefikRouter) checkRoute(ctx context.Context, kind, routerName, serviceName string, expected []string) error {
+	var router struct {
+		Status string `json:"status"`
+	}
+	if err := r.traefikAPI(ctx, "/api/"+kind+"/routers/"+routerName+"@"+r.provider(), &router); err != nil {
+		return err
+	}
+	if router.Status != "enabled" {
+		return fmt.Errorf("router %s is %s", routerName, router.Status)
+	}
+
+	// HTTP servers are listed by URL, TCP and UDP servers by address
+	var service struct {
+		LoadBalancer struct {
+			Servers []struct {
+				URL     string `json:"url"`
+				Address string `json:"address"`
+			} `json:"servers"`
+		} `json:"loadBalancer"`
+	}
+	if err := r.traefikAPI(ctx, "/api/"+kind+"/services/"+serviceName+"@"+r.provider(), &service); err != nil {
+		return err
+	}
+	actual := make([]string, 0, len(service.LoadBalancer.Servers))
+	for _, server := range service.LoadBalancer.Servers {
+		if server.URL != "" {
+			actual = append(actual, server.URL)
+		} else {
+			actual = append(actual, server.Address)
+		}
+	}
+	sort.Strings(actual)
+	if strings.Join(actual, ",") != strings.Join(expected, ",") {
+		return fmt.Errorf("service %s balances %v, expected %v", serviceName, actual, expected)
+	}
+	return nil
+}
+
+// traefikAPI fetches a JSON document from the Traefik API
+func (r *TraefikRouter) traefikAPI(ctx context.Context, path string, result interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(r.config.TraefikAPI, "/")+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("traefik API unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%s not found in traefik", path)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("traefik API returned %d for %s", resp.StatusCode, path)
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// provider returns the Traefik provider name routes are registered under
+func (r *TraefikRouter) provider() string {
+	if r.config.Provider == ProviderHTTP {
+		return ProviderHTTP
+	}
+	return ProviderFile
+}
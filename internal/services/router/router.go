@@ -0,0 +1,152 @@
+package router
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// Router backends
+const (
+	BackendTraefik = "traefik" // dynamic configuration through Traefik's file or HTTP provider
+	BackendCaddy   = "caddy"   // routes pushed to Caddy's admin API
+)
+
+// Router configures the reverse proxy that serves apps
+type Router interface {
+	// AddRoute adds or replaces an app's route, built from the app's settings
+	AddRoute(ctx context.Context, app *domain.App, replicas []Replica) error
+	RemoveRoute(ctx context.Context, appID uuid.UUID) error
+	UpdateReplicas(ctx context.Context, appID uuid.UUID, replicas []Replica) error
+	SetCORS(ctx context.Context, appID uuid.UUID, policy *domain.CORSPolicy) error
+	SetBasicAuth(ctx context.Context, appID uuid.UUID, entry string) error
+	GetRoute(appID uuid.UUID) (*Route, bool)
+	ListRoutes() []*Route
+
+	// PreviewRoute renders the configuration an app's settings would produce, without applying it
+	PreviewRoute(app *domain.App) (*RoutePreview, error)
+
+	// WaitForRoute blocks until the proxy serves the app's current route
+	WaitForRoute(ctx context.Context, appID uuid.UUID) error
+
+	GetAppURL(app *domain.App) string
+	AppHost(app *domain.App) string
+	ValidateSubdomain(subdomain string) error
+	PlatformDomain() string
+	HTTPSEnabled() bool
+	SNIEnabled() bool
+
+	// Router ports for TCP and UDP routes
+	AllocatePort(appID uuid.UUID, protocol domain.RouteProtocol) (int, error)
+	ReleasePorts(appID uuid.UUID)
+
+	// Certificates managed by NanoPaaS, and the hook requesting them for new hosts
+	SetCertificates(certs []*domain.Certificate) error
+	SetHostRoutedHandler(fn func(hosts []string))
+
+	// Traffic returns request counts, status codes and latency served for an app
+	Traffic(ctx context.Context, appID uuid.UUID) (*TrafficStats, error)
+
+	// ConfigStats counts applied and rejected proxy configurations
+	ConfigStats() ConfigStats
+
+	Shutdown()
+}
+
+// New creates the router for the configured backend
+func New(backend string, config RouterConfig, logger *zap.Logger) (Router, error) {
+	switch backend {
+	case "", BackendTraefik:
+		return NewTraefikRouter(config, logger)
+	case BackendCaddy:
+		return NewCaddyRouter(config, logger)
+	default:
+		return nil, fmt.Errorf("unsupported router backend %q", backend)
+	}
+}
+
+// newRoute builds an app's route from its settings. Middleware names follow the
+// Traefik naming; other backends read the settings themselves.
+func newRoute(config RouterConfig, app *domain.App, replicas []Replica) *Route {
+	route := &Route{
+		AppID:       app.ID,
+		AppSlug:     app.Slug,
+		Subdomain:   app.Subdomain,
+		ServiceName: app.Slug,
+		Port:        app.ExposedPort,
+		Replicas:    replicas,
+		EnableHTTPS: config.EnableHTTPS,
+		Headers: map[string]string{
+			"X-NanoPaaS-App": app.Slug,
+		},
+		Middleware: []string{},
+		CORS:       app.CORS,
+		BasicAuth:  app.BasicAuthEntry(),
+		HSTS:       app.HSTS,
+		Sticky:     app.StickySessions,
+
+		CustomDomains: app.CustomDomains,
+		Apex:          config.ApexApp != "" && app.Slug == config.ApexApp,
+
+		StreamingMode:     app.StreamingMode,
+		StreamIdleTimeout: app.EffectiveStreamIdleTimeout(),
+	}
+	setMiddleware(route, basicAuthMiddlewareName(app.Slug), route.BasicAuth != "")
+	setMiddleware(route, corsMiddlewareName(app.Slug), app.CORS != nil)
+	if app.InLockdown() {
+		route.Maintenance = app.Lockdown.Mode == domain.LockdownMaintenance
+	}
+	if app.InMaintenance() {
+		route.MaintenancePage = config.MaintenanceURL != ""
+		route.Maintenance = route.Maintenance || !route.MaintenancePage
+	}
+	setMiddleware(route, maintenanceName(app.Slug), route.MaintenancePage)
+	if n := app.NetworkRoute; n != nil {
+		route.Protocol = n.Protocol
+		route.SNI = n.SNI
+		route.PublicPort = n.PublicPort
+		// Only SNI routes terminate TLS and need certificates
+		route.EnableHTTPS = route.EnableHTTPS && n.SNI
+	}
+	route.RateLimit = app.EffectiveRateLimit()
+	setMiddleware(route, rateLimitMiddlewareName(app.Slug), route.RateLimit != nil)
+	route.IPAllowList = app.IPAllowList()
+	setMiddleware(route, ipAllowListMiddlewareName(app.Slug), len(route.IPAllowList) > 0)
+	return route
+}
+
+// hosts returns the host names a route is served on, its subdomain first
+func (route *Route) hosts(platformDomain string) []string {
+	hosts := []string{route.Subdomain + "." + platformDomain}
+	if route.Apex {
+		hosts = append(hosts, platformDomain)
+	}
+	return append(hosts, route.CustomDomains...)
+}
+
+// appURL returns the URL clients reach an app at
+func appURL(config RouterConfig, app *domain.App) string {
+	if n := app.NetworkRoute; n != nil {
+		if n.SNI {
+			return fmt.Sprintf("tls://%s.%s:%d", app.Subdomain, config.Domain, config.HTTPSPort)
+		}
+		return fmt.Sprintf("%s://%s:%d", n.Protocol, config.Domain, n.PublicPort)
+	}
+
+	scheme := "http"
+	port := config.HTTPPort
+
+	if config.EnableHTTPS {
+		scheme = "https"
+		port = config.HTTPSPort
+	}
+
+	if port == 80 || port == 443 {
+		return fmt.Sprintf("%s://%s.%s", scheme, app.Subdomain, config.Domain)
+	}
+	return fmt.Sprintf("%s://%s.%s:%d", scheme, app.Subdomain, config.Domain, port)
+}
@@ -0,0 +1,153 @@
+package router
+
+import (
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+)
+
+// acmeChallengeName names the router and service forwarding HTTP-01 challenges to NanoPaaS
+const acmeChallengeName = "nanopaas-acme-challenge"
+
+// acmeChallengePriority places the challenge router ahead of every app router on the same host
+const acmeChallengePriority = 10000
+
+// SetCertificates replaces the certificates Traefik serves for HTTPS routes
+func (r *TraefikRouter) SetCertificates(certs []*domain.Certificate) error {
+	r.certsMu.Lock()
+	r.certs = certs
+	r.certsMu.Unlock()
+
+	if err := r.generateConfig(); err != nil {
+		return fmt.Errorf("failed to generate config: %w", err)
+	}
+
+	r.logger.Info("Router certificates updated", zap.Int("count", len(certs)))
+	return nil
+}
+
+// SetHostRoutedHandler sets a callback invoked with an app's host names whenever an
+// HTTPS route is added, so certificates can be requested for them
+func (r *TraefikRouter) SetHostRoutedHandler(fn func(hosts []string)) {
+	r.onHostRouted = fn
+}
+
+// routeHosts returns the host names an app is served on, its subdomain first
+func (r *TraefikRouter) routeHosts(route *Route) []string {
+	return route.hosts(r.config.Domain)
+}
+
+// hostRule returns the Traefik rule matching every host of a route
+func (r *TraefikRouter) hostRule(route *Route) string {
+	hosts := r.routeHosts(route)
+	rules := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		rules = append(rules, fmt.Sprintf("Host(`%s`)", host))
+	}
+	return strings.Join(rules, " || ")
+}
+
+// secureRouterName returns the name of an app's HTTPS router
+func secureRouterName(slug string) string {
+	return slug + "-router-secure"
+}
+
+// routerTLS returns the TLS options of HTTPS routers: a Traefik cert resolver when one is
+// configured, otherwise the certificates written to the tls section
+func (r *TraefikRouter) routerTLS() map[string]interface{} {
+	if r.config.CertResolver != "" {
+		return map[string]interface{}{"certResolver": r.config.CertResolver}
+	}
+	return map[string]interface{}{}
+}
+
+// httpsRedirectName names the shared middleware redirecting plain HTTP to HTTPS
+const httpsRedirectName = "nanopaas-https-redirect"
+
+// redirectsHTTP reports whether web routers redirect to HTTPS
+func (r *TraefikRouter) redirectsHTTP() bool {
+	return r.config.EnableHTTPS && r.config.RedirectHTTP
+}
+
+// webMiddleware returns the middleware of an app's plain HTTP router. When HTTPS is
+// enforced the router only redirects, so the app is never served over plain HTTP.
+func (r *TraefikRouter) webMiddleware(route *Route) []string {
+	if route.EnableHTTPS && r.redirectsHTTP() {
+		return []string{httpsRedirectName}
+	}
+	return route.Middleware
+}
+
+// secureMiddleware returns the middleware of an app's HTTPS router, with HSTS last
+func secureMiddleware(route *Route) []string {
+	if route.HSTS == nil {
+		return route.Middleware
+	}
+	return append(append([]string{}, route.Middleware...), hstsMiddlewareName(route.AppSlug))
+}
+
+// httpsRedirectMiddleware builds the permanent redirect to HTTPS
+func httpsRedirectMiddleware() map[string]interface{} {
+	return map[string]interface{}{
+		"redirectScheme": map[string]interface{}{
+			"scheme":    "https",
+			"permanent": true,
+		},
+	}
+}
+
+// hstsMiddlewareName returns the name of an app's HSTS middleware
+func hstsMiddlewareName(slug string) string {
+	return slug + "-hsts"
+}
+
+// hstsHeaders maps an HSTS policy onto Traefik headers middleware options
+func hstsHeaders(policy *domain.HSTSPolicy) map[string]interface{} {
+	headers := map[string]interface{}{
+		"stsSeconds": policy.MaxAge,
+	}
+	if policy.IncludeSubdomains {
+		headers["stsIncludeSubdomains"] = true
+	}
+	if policy.Preload {
+		headers["stsPreload"] = true
+	}
+	return headers
+}
+
+// acmeChallengeRule matches HTTP-01 challenge requests on any host
+const acmeChallengeRule = "PathPrefix(`/.well-known/acme-challenge/`)"
+
+// acmeChallengeRouter builds the router sending HTTP-01 challenges to NanoPaaS
+func acmeChallengeRouter() map[string]interface{} {
+	return map[string]interface{}{
+		"rule":        acmeChallengeRule,
+		"service":     acmeChallengeName,
+		"entryPoints": []string{"web"},
+		"priority":    acmeChallengePriority,
+	}
+}
+
+// tlsCertificates returns the managed certificates as Traefik tls.certificates entries.
+// Traefik accepts PEM content in place of file paths, so nothing else touches the disk.
+func (r *TraefikRouter) tlsCertificates() []map[string]interface{} {
+	r.certsMu.RLock()
+	defer r.certsMu.RUnlock()
+
+	certs := make([]map[string]interface{}, 0, len(r.certs))
+	for _, cert := range r.certs {
+		certs = append(certs, map[string]interface{}{
+			"certFile": string(cert.CertPEM),
+			"keyFile":  string(cert.KeyPEM),
+		})
+	}
+	return certs
+}
+
+// HTTPSEnabled reports whether apps are served over HTTPS
+func (r *TraefikRouter) HTTPSEnabled() bool {
+	return r.config.EnableHTTPS
+}
@@ -2,15 +2,18 @@ package router
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
-	"text/template"
 	"time"
 
 	"github.com/google/uuid"
 	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
 
 	"github.com/nanopaas/nanopaas/internal/domain"
 )
@@ -22,9 +25,43 @@ type RouterConfig struct {
 	HTTPPort        int
 	HTTPSPort       int
 	EnableHTTPS     bool
+	RedirectHTTP    bool // with EnableHTTPS, web routers redirect to HTTPS instead of serving the app
 	CertResolver    string
 	EntryPoints     []string
 	RefreshInterval time.Duration
+
+	// Upstream answering ACME HTTP-01 challenges, empty when certificates are not managed by NanoPaaS
+	ACMEChallengeURL string
+
+	// Port ranges ("10000-10019") of the static tcp-<port> and udp-<port> entrypoints
+	// allocated to TCP and UDP routes; empty disables the protocol
+	TCPPorts string
+	UDPPorts string
+
+	// NanoPaaS as reached by Traefik, serving maintenance pages. When empty, apps in
+	// maintenance get Traefik's plain 503 instead.
+	MaintenanceURL string
+
+	// Provider selects how Traefik receives the dynamic configuration: "file" (default)
+	// or "http", served by ServeProviderConfig to requests bearing ProviderToken
+	Provider      string
+	ProviderToken string
+
+	// When VerifyTimeout is set, WaitForRoute polls the Traefik API until a route is live
+	TraefikAPI    string
+	VerifyTimeout time.Duration
+
+	// Traefik's Prometheus metrics endpoint, scraped for per-app traffic; empty disables it
+	MetricsURL string
+
+	// App (by slug) also served on the bare platform domain, and subdomains apps can't
+	// claim; nil uses DefaultReservedSubdomains
+	ApexApp            string
+	ReservedSubdomains []string
+
+	// Caddy backend: admin API address and the name of the HTTP server NanoPaaS owns
+	CaddyAdminURL string
+	CaddyServer   string
 }
 
 // DefaultRouterConfig returns default router configuration
@@ -35,6 +72,7 @@ func DefaultRouterConfig() RouterConfig {
 		HTTPPort:        80,
 		HTTPSPort:       443,
 		EnableHTTPS:     false,
+		RedirectHTTP:    true,
 		CertResolver:    "letsencrypt",
 		EntryPoints:     []string{"web"},
 		RefreshInterval: 5 * time.Second,
@@ -52,6 +90,41 @@ type Route struct {
 	EnableHTTPS bool
 	Headers     map[string]string
 	Middleware  []string
+	CORS        *domain.CORSPolicy
+	BasicAuth   string // htpasswd-style "user:hash", empty when unprotected
+
+	// Verified custom domains served alongside the subdomain
+	CustomDomains []string
+
+	// Streaming routes get a dedicated servers transport with a long idle timeout
+	StreamingMode     domain.StreamingMode
+	StreamIdleTimeout int // seconds
+
+	// Strict-Transport-Security added to HTTPS responses
+	HSTS *domain.HSTSPolicy
+
+	// Affinity cookie pinning clients to a replica
+	Sticky *domain.StickySessionPolicy
+
+	// Incident lockdown: maintenance sends no traffic to the app so Traefik answers 503.
+	// A rate limit throttles each client IP, from the lockdown or the app's routing policy.
+	Maintenance bool
+	RateLimit   *domain.RateLimit
+
+	// CIDR ranges allowed to reach the app, empty when unrestricted
+	IPAllowList []string
+
+	// Traffic goes to the NanoPaaS maintenance page instead of the replicas
+	MaintenancePage bool
+
+	// Also served on the bare platform domain
+	Apex bool
+
+	// TCP and UDP routes bypass HTTP routing: PublicPort is the allocated entrypoint,
+	// or SNI routes TLS connections on the HTTPS entrypoint by server name
+	Protocol   domain.RouteProtocol
+	PublicPort int
+	SNI        bool
 }
 
 // Replica represents a backend replica
@@ -71,6 +144,33 @@ type TraefikRouter struct {
 	routes   map[uuid.UUID]*Route
 	routesMu sync.RWMutex
 
+	// Serializes config updates so the last routes read are the last config written
+	applyMu sync.Mutex
+
+	// Certificates served for HTTPS routes
+	certs   []*domain.Certificate
+	certsMu sync.RWMutex
+
+	// Called with an app's host names when an HTTPS route is added
+	onHostRouted func(hosts []string)
+
+	// Router ports allocated to TCP and UDP routes
+	tcpPorts *portPool
+	udpPorts *portPool
+	portsMu  sync.Mutex
+
+	// Outcome of configuration updates
+	configs configRecorder
+
+	// Request totals sampled from the metrics endpoint, by Traefik service
+	trafficSamples map[string][]trafficSample
+	trafficMu      sync.Mutex
+
+	// Configuration served to Traefik's HTTP provider
+	snapshot   *providerSnapshot
+	snapshotMu sync.RWMutex
+	httpClient *http.Client
+
 	// File watcher context
 	ctx    context.Context
 	cancel context.CancelFunc
@@ -79,9 +179,29 @@ type TraefikRouter struct {
 
 // NewTraefikRouter creates a new Traefik router
 func NewTraefikRouter(config RouterConfig, logger *zap.Logger) (*TraefikRouter, error) {
-	// Ensure config directory exists
-	if err := os.MkdirAll(config.ConfigPath, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create config directory: %w", err)
+	switch config.Provider {
+	case "", ProviderFile:
+		// Ensure config directory exists
+		if err := os.MkdirAll(config.ConfigPath, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create config directory: %w", err)
+		}
+	case ProviderHTTP:
+		if config.ProviderToken == "" {
+			return nil, fmt.Errorf("the http provider requires a provider token")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported router provider %q", config.Provider)
+	}
+	if len(config.EntryPoints) == 0 {
+		config.EntryPoints = []string{"web"}
+	}
+	tcpPorts, err := parsePortPool(config.TCPPorts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tcp ports: %w", err)
+	}
+	udpPorts, err := parsePortPool(config.UDPPorts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid udp ports: %w", err)
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -92,38 +212,67 @@ func NewTraefikRouter(config RouterConfig, logger *zap.Logger) (*TraefikRouter,
 		routes: make(map[uuid.UUID]*Route),
 		ctx:    ctx,
 		cancel: cancel,
+
+		tcpPorts: tcpPorts,
+		udpPorts: udpPorts,
+
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+
+		trafficSamples: make(map[string][]trafficSample),
+	}
+	if config.Provider == ProviderHTTP {
+		// Serve an empty configuration until the first route is added
+		if err := r.applyConfig(nil); err != nil {
+			return nil, err
+		}
+	}
+
+	if config.MetricsURL != "" {
+		r.wg.Add(1)
+		go r.runTrafficSampler()
 	}
 
 	logger.Info("Traefik router initialized",
 		zap.String("domain", config.Domain),
+		zap.String("provider", r.provider()),
 		zap.String("config_path", config.ConfigPath),
 	)
 
 	return r, nil
 }
 
-// AddRoute adds or updates a route for an app
+// AddRoute adds or updates a route for an app. Apps without an exposed port only
+// serve other apps on the shared network, so any existing route is removed instead.
 func (r *TraefikRouter) AddRoute(ctx context.Context, app *domain.App, replicas []Replica) error {
-	route := &Route{
-		AppID:       app.ID,
-		AppSlug:     app.Slug,
-		Subdomain:   app.Subdomain,
-		ServiceName: app.Slug,
-		Port:        app.ExposedPort,
-		Replicas:    replicas,
-		EnableHTTPS: r.config.EnableHTTPS,
-		Headers: map[string]string{
-			"X-NanoPaaS-App": app.Slug,
-		},
-		Middleware: []string{},
+	if app.ExposedPort <= 0 {
+		if _, routed := r.GetRoute(app.ID); routed {
+			return r.RemoveRoute(ctx, app.ID)
+		}
+		return nil
+	}
+
+	route := newRoute(r.config, app, replicas)
+	if route.isL4() && !route.SNI {
+		if err := r.reservePort(app.ID, route.Protocol, route.PublicPort); err != nil {
+			return fmt.Errorf("failed to reserve %s port: %w", route.Protocol, err)
+		}
 	}
 
 	r.routesMu.Lock()
+	previous, existed := r.routes[app.ID]
 	r.routes[app.ID] = route
 	r.routesMu.Unlock()
 
-	// Generate and write config
+	// Generate and write config, dropping the route again if it was rejected so
+	// one bad app can't block every other route update
 	if err := r.generateConfig(); err != nil {
+		r.routesMu.Lock()
+		if existed {
+			r.routes[app.ID] = previous
+		} else {
+			delete(r.routes, app.ID)
+		}
+		r.routesMu.Unlock()
 		return fmt.Errorf("failed to generate config: %w", err)
 	}
 
@@ -133,6 +282,10 @@ func (r *TraefikRouter) AddRoute(ctx context.Context, app *domain.App, replicas
 		zap.Int("replicas", len(replicas)),
 	)
 
+	if route.EnableHTTPS && r.onHostRouted != nil {
+		r.onHostRouted(r.routeHosts(route))
+	}
+
 	return nil
 }
 
@@ -175,6 +328,54 @@ func (r *TraefikRouter) UpdateReplicas(ctx context.Context, appID uuid.UUID, rep
 	return nil
 }
 
+// SetCORS replaces the CORS policy on an app's route; a nil policy removes it
+func (r *TraefikRouter) SetCORS(ctx context.Context, appID uuid.UUID, policy *domain.CORSPolicy) error {
+	r.routesMu.Lock()
+	route, exists := r.routes[appID]
+	if !exists {
+		r.routesMu.Unlock()
+		return fmt.Errorf("route not found for app %s", appID)
+	}
+	route.CORS = policy
+	setMiddleware(route, corsMiddlewareName(route.AppSlug), policy != nil)
+	r.routesMu.Unlock()
+
+	if err := r.generateConfig(); err != nil {
+		return fmt.Errorf("failed to generate config: %w", err)
+	}
+
+	r.logger.Info("Route CORS updated",
+		zap.String("app_id", appID.String()),
+		zap.Bool("enabled", policy != nil),
+	)
+
+	return nil
+}
+
+// SetBasicAuth replaces the basic auth entry on an app's route; an empty entry removes it
+func (r *TraefikRouter) SetBasicAuth(ctx context.Context, appID uuid.UUID, entry string) error {
+	r.routesMu.Lock()
+	route, exists := r.routes[appID]
+	if !exists {
+		r.routesMu.Unlock()
+		return fmt.Errorf("route not found for app %s", appID)
+	}
+	route.BasicAuth = entry
+	setMiddleware(route, basicAuthMiddlewareName(route.AppSlug), entry != "")
+	r.routesMu.Unlock()
+
+	if err := r.generateConfig(); err != nil {
+		return fmt.Errorf("failed to generate config: %w", err)
+	}
+
+	r.logger.Info("Route basic auth updated",
+		zap.String("app_id", appID.String()),
+		zap.Bool("enabled", entry != ""),
+	)
+
+	return nil
+}
+
 // GetRoute returns a route by app ID
 func (r *TraefikRouter) GetRoute(appID uuid.UUID) (*Route, bool) {
 	r.routesMu.RLock()
@@ -195,22 +396,65 @@ func (r *TraefikRouter) ListRoutes() []*Route {
 	return routes
 }
 
-// generateConfig generates the Traefik dynamic configuration file
+// generateConfig generates the Traefik dynamic configuration and hands it to the provider.
+// A configuration that fails validation is rejected and the previous one stays live.
 func (r *TraefikRouter) generateConfig() error {
+	r.applyMu.Lock()
+	defer r.applyMu.Unlock()
+
+	// Route fields are reassigned under routesMu, so build from copies taken under it
 	r.routesMu.RLock()
 	routes := make([]*Route, 0, len(r.routes))
 	for _, route := range r.routes {
-		routes = append(routes, route)
+		copied := *route
+		routes = append(routes, &copied)
 	}
 	r.routesMu.RUnlock()
 
-	// Write to file
-	configPath := filepath.Join(r.config.ConfigPath, "dynamic.yml")
+	err := r.applyConfig(routes)
+	r.configs.record(err)
+	if err != nil {
+		r.logger.Error("Router config rejected, keeping the previous config", zap.Error(err))
+	}
+	return err
+}
 
-	// Generate YAML config
-	yamlConfig := r.convertToYAML(routes)
+// applyConfig renders, validates and publishes the configuration for routes
+func (r *TraefikRouter) applyConfig(routes []*Route) error {
+	config := r.buildTraefikConfig(routes)
 
-	if err := os.WriteFile(configPath, []byte(yamlConfig), 0644); err != nil {
+	if r.config.Provider == ProviderHTTP {
+		body, err := json.Marshal(config)
+		if err != nil {
+			return fmt.Errorf("failed to encode config: %w", err)
+		}
+		var parsed map[string]interface{}
+		if err := json.Unmarshal(body, &parsed); err != nil {
+			return fmt.Errorf("failed to parse generated config: %w", err)
+		}
+		if err := validateConfig(routes, r.config.Domain, parsed); err != nil {
+			return fmt.Errorf("invalid config: %w", err)
+		}
+		r.publishSnapshot(body)
+		r.logger.Debug("Config published", zap.String("provider", ProviderHTTP))
+		return nil
+	}
+
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	// Validate what Traefik will read back, not the structure it was built from
+	var parsed map[string]interface{}
+	if err := yaml.Unmarshal(data, &parsed); err != nil {
+		return fmt.Errorf("failed to parse generated config: %w", err)
+	}
+	if err := validateConfig(routes, r.config.Domain, parsed); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+
+	configPath := filepath.Join(r.config.ConfigPath, "dynamic.yml")
+	if err := writeFileAtomic(configPath, data, 0644); err != nil {
 		return fmt.Errorf("failed to write config: %w", err)
 	}
 
@@ -218,54 +462,96 @@ func (r *TraefikRouter) generateConfig() error {
 	return nil
 }
 
+// ConfigStats returns counts of applied and rejected configurations
+func (r *TraefikRouter) ConfigStats() ConfigStats {
+	return r.configs.snapshot()
+}
+
 // buildTraefikConfig builds the Traefik configuration structure
 func (r *TraefikRouter) buildTraefikConfig(routes []*Route) map[string]interface{} {
 	routers := make(map[string]interface{})
 	services := make(map[string]interface{})
 	middlewares := make(map[string]interface{})
+	transports := make(map[string]interface{})
 
+	routes, l4Routes := splitRoutes(routes)
 	for _, route := range routes {
 		// Router
 		routerName := route.AppSlug + "-router"
-		routeRule := fmt.Sprintf("Host(`%s.%s`)", route.Subdomain, r.config.Domain)
+		routeRule := r.hostRule(route)
 
 		router := map[string]interface{}{
 			"rule":        routeRule,
-			"service":     route.ServiceName,
+			"service":     routerService(route),
 			"entryPoints": r.config.EntryPoints,
 		}
 
-		if route.EnableHTTPS && r.config.CertResolver != "" {
-			router["tls"] = map[string]interface{}{
-				"certResolver": r.config.CertResolver,
-			}
-		}
-
-		if len(route.Middleware) > 0 {
-			router["middlewares"] = route.Middleware
+		if middleware := r.webMiddleware(route); len(middleware) > 0 {
+			router["middlewares"] = middleware
 		}
 
 		routers[routerName] = router
 
+		if route.EnableHTTPS {
+			secure := map[string]interface{}{
+				"rule":        routeRule,
+				"service":     routerService(route),
+				"entryPoints": []string{"websecure"},
+				"tls":         r.routerTLS(),
+			}
+			if middleware := secureMiddleware(route); len(middleware) > 0 {
+				secure["middlewares"] = middleware
+			}
+			routers[secureRouterName(route.AppSlug)] = secure
+		}
+
 		// Service with load balancer
 		servers := make([]map[string]interface{}, 0, len(route.Replicas))
-		for _, replica := range route.Replicas {
+		for _, replica := range route.servers() {
 			servers = append(servers, map[string]interface{}{
 				"url": fmt.Sprintf("http://%s:%d", replica.IPAddress, replica.Port),
 			})
 		}
 
-		services[route.ServiceName] = map[string]interface{}{
-			"loadBalancer": map[string]interface{}{
-				"servers": servers,
-				"healthCheck": map[string]interface{}{
-					"path":     "/health",
-					"interval": "10s",
-					"timeout":  "3s",
-				},
+		loadBalancer := map[string]interface{}{
+			"servers": servers,
+			"healthCheck": map[string]interface{}{
+				"path":     "/health",
+				"interval": "10s",
+				"timeout":  "3s",
 			},
 		}
 
+		if route.Sticky != nil {
+			loadBalancer["sticky"] = map[string]interface{}{
+				"cookie": stickyCookie(route.Sticky),
+			}
+		}
+
+		if route.StreamingMode != domain.StreamingNone {
+			loadBalancer["serversTransport"] = streamingTransportName(route.AppSlug)
+			transports[streamingTransportName(route.AppSlug)] = map[string]interface{}{
+				"forwardingTimeouts": map[string]interface{}{
+					"idleConnTimeout":       fmt.Sprintf("%ds", route.StreamIdleTimeout),
+					"responseHeaderTimeout": "0s",
+				},
+			}
+		}
+		if route.StreamingMode == domain.StreamingSSE {
+			// Flush every event to the client instead of buffering the response
+			loadBalancer["responseForwarding"] = map[string]interface{}{
+				"flushInterval": sseFlushInterval,
+			}
+		}
+
+		services[route.ServiceName] = map[string]interface{}{
+			"loadBalancer": loadBalancer,
+		}
+		if route.MaintenancePage {
+			services[maintenanceName(route.AppSlug)] = r.maintenanceService()
+			middlewares[maintenanceName(route.AppSlug)] = maintenanceMiddleware(route)
+		}
+
 		// Custom headers middleware
 		middlewareName := route.AppSlug + "-headers"
 		middlewares[middlewareName] = map[string]interface{}{
@@ -276,133 +562,201 @@ func (r *TraefikRouter) buildTraefikConfig(routes []*Route) map[string]interface
 				},
 			},
 		}
+
+		if route.CORS != nil {
+			middlewares[corsMiddlewareName(route.AppSlug)] = map[string]interface{}{
+				"headers": corsHeaders(route.CORS),
+			}
+		}
+
+		if route.BasicAuth != "" {
+			middlewares[basicAuthMiddlewareName(route.AppSlug)] = map[string]interface{}{
+				"basicAuth": map[string]interface{}{
+					"users":        []string{route.BasicAuth},
+					"removeHeader": true,
+				},
+			}
+		}
+
+		if route.EnableHTTPS && route.HSTS != nil {
+			middlewares[hstsMiddlewareName(route.AppSlug)] = map[string]interface{}{
+				"headers": hstsHeaders(route.HSTS),
+			}
+		}
+
+		if len(route.IPAllowList) > 0 {
+			middlewares[ipAllowListMiddlewareName(route.AppSlug)] = map[string]interface{}{
+				"ipAllowList": map[string]interface{}{
+					"sourceRange": route.IPAllowList,
+				},
+			}
+		}
+
+		if route.RateLimit != nil {
+			middlewares[rateLimitMiddlewareName(route.AppSlug)] = map[string]interface{}{
+				"rateLimit": map[string]interface{}{
+					"average": route.RateLimit.Average,
+					"burst":   route.RateLimit.Burst,
+					"period":  "1s",
+				},
+			}
+		}
 	}
 
-	return map[string]interface{}{
-		"http": map[string]interface{}{
-			"routers":     routers,
-			"services":    services,
-			"middlewares": middlewares,
-		},
-	}
-}
-
-// convertToYAML converts routes to YAML format
-func (r *TraefikRouter) convertToYAML(routes []*Route) string {
-	tmpl := `http:
-  routers:
-{{- range . }}
-    {{ .AppSlug }}-router:
-      rule: "Host(` + "`" + `{{ .Subdomain }}.{{ $.Domain }}` + "`" + `)"
-      service: {{ .ServiceName }}
-      entryPoints:
-        - web
-{{- if .EnableHTTPS }}
-      tls:
-        certResolver: letsencrypt
-{{- end }}
-{{- end }}
-
-  services:
-{{- range . }}
-    {{ .ServiceName }}:
-      loadBalancer:
-        servers:
-{{- range .Replicas }}
-          - url: "http://{{ .IPAddress }}:{{ .Port }}"
-{{- end }}
-        healthCheck:
-          path: /health
-          interval: 10s
-          timeout: 3s
-{{- end }}
-
-  middlewares:
-{{- range . }}
-    {{ .AppSlug }}-headers:
-      headers:
-        customRequestHeaders:
-          X-NanoPaaS-App: "{{ .AppSlug }}"
-        customResponseHeaders:
-          X-Powered-By: "NanoPaaS"
-{{- end }}
-`
-
-	t, err := template.New("traefik").Parse(tmpl)
-	if err != nil {
-		r.logger.Error("Failed to parse template", zap.Error(err))
-		return ""
+	httpConfig := map[string]interface{}{
+		"routers":     routers,
+		"services":    services,
+		"middlewares": middlewares,
+	}
+	if len(transports) > 0 {
+		httpConfig["serversTransports"] = transports
+	}
+	if r.redirectsHTTP() {
+		middlewares[httpsRedirectName] = httpsRedirectMiddleware()
+	}
+	if r.config.ACMEChallengeURL != "" {
+		routers[acmeChallengeName] = acmeChallengeRouter()
+		services[acmeChallengeName] = map[string]interface{}{
+			"loadBalancer": map[string]interface{}{
+				"servers": []map[string]interface{}{{"url": r.config.ACMEChallengeURL}},
+			},
+		}
 	}
 
-	data := struct {
-		Domain string
-		Routes []*Route
-	}{
-		Domain: r.config.Domain,
-		Routes: routes,
+	traefikConfig := map[string]interface{}{
+		"http": httpConfig,
 	}
+	for protocol, section := range r.buildL4Config(l4Routes) {
+		traefikConfig[protocol] = section
+	}
+	if certs := r.tlsCertificates(); len(certs) > 0 {
+		traefikConfig["tls"] = map[string]interface{}{"certificates": certs}
+	}
+	return traefikConfig
+}
 
-	var result string
-	// Simple approach - just build the YAML manually
-	result = "http:\n"
-	result += "  routers:\n"
+// sseFlushInterval makes Traefik flush server-sent events as they are written
+const sseFlushInterval = "1ms"
 
-	for _, route := range routes {
-		result += fmt.Sprintf("    %s-router:\n", route.AppSlug)
-		result += fmt.Sprintf("      rule: \"Host(`%s.%s`)\"\n", route.Subdomain, r.config.Domain)
-		result += fmt.Sprintf("      service: %s\n", route.ServiceName)
-		result += "      entryPoints:\n"
-		result += "        - web\n"
-		if route.EnableHTTPS {
-			result += "      tls:\n"
-			result += "        certResolver: letsencrypt\n"
+// streamingTransportName returns the name of an app's streaming servers transport
+func streamingTransportName(slug string) string {
+	return slug + "-streaming"
+}
+
+// setMiddleware adds or removes a named middleware on a route.
+// The IP allowlist and then basic auth are kept first so rejected requests never reach
+// other middleware.
+func setMiddleware(route *Route, name string, enabled bool) {
+	middleware := make([]string, 0, len(route.Middleware)+1)
+	for _, m := range route.Middleware {
+		if m != name {
+			middleware = append(middleware, m)
 		}
 	}
+	if enabled {
+		middleware = append(middleware, name)
+	}
+	first := []string{ipAllowListMiddlewareName(route.AppSlug), basicAuthMiddlewareName(route.AppSlug)}
+	sort.SliceStable(middleware, func(i, j int) bool {
+		return middlewareRank(middleware[i], first) < middlewareRank(middleware[j], first)
+	})
+	route.Middleware = middleware
+}
 
-	result += "\n  services:\n"
-	for _, route := range routes {
-		result += fmt.Sprintf("    %s:\n", route.ServiceName)
-		result += "      loadBalancer:\n"
-		result += "        servers:\n"
-		for _, replica := range route.Replicas {
-			result += fmt.Sprintf("          - url: \"http://%s:%d\"\n", replica.IPAddress, replica.Port)
+// middlewareRank orders middleware listed in first ahead of all others
+func middlewareRank(name string, first []string) int {
+	for i, f := range first {
+		if name == f {
+			return i
 		}
-		result += "        healthCheck:\n"
-		result += "          path: /health\n"
-		result += "          interval: 10s\n"
-		result += "          timeout: 3s\n"
 	}
+	return len(first)
+}
 
-	result += "\n  middlewares:\n"
-	for _, route := range routes {
-		result += fmt.Sprintf("    %s-headers:\n", route.AppSlug)
-		result += "      headers:\n"
-		result += "        customRequestHeaders:\n"
-		result += fmt.Sprintf("          X-NanoPaaS-App: \"%s\"\n", route.AppSlug)
-		result += "        customResponseHeaders:\n"
-		result += "          X-Powered-By: \"NanoPaaS\"\n"
+// basicAuthMiddlewareName returns the name of an app's basic auth middleware
+func basicAuthMiddlewareName(slug string) string {
+	return slug + "-auth"
+}
+
+// rateLimitMiddlewareName returns the name of an app's rate limit middleware
+func rateLimitMiddlewareName(slug string) string {
+	return slug + "-ratelimit"
+}
+
+// ipAllowListMiddlewareName returns the name of an app's IP allowlist middleware
+func ipAllowListMiddlewareName(slug string) string {
+	return slug + "-ipallowlist"
+}
+
+// stickyCookie maps a sticky session policy onto Traefik's sticky cookie options
+func stickyCookie(policy *domain.StickySessionPolicy) map[string]interface{} {
+	cookie := map[string]interface{}{
+		"name":     policy.CookieName,
+		"secure":   policy.Secure,
+		"httpOnly": policy.HTTPOnly,
+	}
+	if policy.SameSite != "" {
+		cookie["sameSite"] = policy.SameSite
+	}
+	if policy.MaxAge > 0 {
+		cookie["maxAge"] = policy.MaxAge
 	}
+	return cookie
+}
 
-	_ = t // Template is defined but we use manual approach for simplicity
-	_ = data
+// servers returns the replicas traffic is sent to, none while in maintenance
+func (route *Route) servers() []Replica {
+	if route.Maintenance {
+		return nil
+	}
+	return route.Replicas
+}
 
-	return result
+// corsMiddlewareName returns the name of an app's CORS middleware
+func corsMiddlewareName(slug string) string {
+	return slug + "-cors"
+}
+
+// corsHeaders maps a CORS policy onto Traefik headers middleware options
+func corsHeaders(policy *domain.CORSPolicy) map[string]interface{} {
+	headers := map[string]interface{}{
+		"accessControlAllowOriginList": policy.AllowOrigins,
+		"accessControlAllowMethods":    policy.AllowMethods,
+		"addVaryHeader":                true,
+	}
+	if len(policy.AllowHeaders) > 0 {
+		headers["accessControlAllowHeaders"] = policy.AllowHeaders
+	}
+	if len(policy.ExposeHeaders) > 0 {
+		headers["accessControlExposeHeaders"] = policy.ExposeHeaders
+	}
+	if policy.AllowCredentials {
+		headers["accessControlAllowCredentials"] = true
+	}
+	if policy.MaxAge > 0 {
+		headers["accessControlMaxAge"] = policy.MaxAge
+	}
+	return headers
 }
 
 // GetAppURL returns the URL for an app
 func (r *TraefikRouter) GetAppURL(app *domain.App) string {
-	scheme := "http"
-	port := r.config.HTTPPort
+	return appURL(r.config, app)
+}
 
-	if r.config.EnableHTTPS {
-		scheme = "https"
-		port = r.config.HTTPSPort
-	}
+// ValidateSubdomain checks that a subdomain can be assigned to an app
+func (r *TraefikRouter) ValidateSubdomain(subdomain string) error {
+	return validateSubdomain(r.config, subdomain)
+}
 
-	if port == 80 || port == 443 {
-		return fmt.Sprintf("%s://%s.%s", scheme, app.Subdomain, r.config.Domain)
-	}
-	return fmt.Sprintf("%s://%s.%s:%d", scheme, app.Subdomain, r.config.Domain, port)
+// AppHost returns the host name an app's subdomain is served on
+func (r *TraefikRouter) AppHost(app *domain.App) string {
+	return app.Subdomain + "." + r.config.Domain
+}
+
+// PlatformDomain returns the domain app subdomains are created under
+func (r *TraefikRouter) PlatformDomain() string {
+	return r.config.Domain
 }
 
 // GenerateTraefikStaticConfig generates the static Traefik configuration
@@ -412,11 +766,26 @@ api:
   dashboard: true
   insecure: true
 
+# readTimeout 0s keeps long-lived WebSocket/SSE connections from being cut at the entrypoint;
+# per-app idle limits are set on each streaming route's servers transport
 entryPoints:
   web:
     address: ":%d"
+    transport:
+      respondingTimeouts:
+        readTimeout: 0s
   websecure:
     address: ":%d"
+    transport:
+      respondingTimeouts:
+        readTimeout: 0s
+%s  metrics:
+    address: ":8082"
+
+metrics:
+  prometheus:
+    entryPoint: metrics
+    addServicesLabels: true
 
 providers:
   file:
@@ -427,7 +796,7 @@ log:
   level: INFO
 
 accessLog: {}
-`, r.config.HTTPPort, r.config.HTTPSPort, r.config.ConfigPath)
+`, r.config.HTTPPort, r.config.HTTPSPort, r.l4EntryPointsYAML(), r.config.ConfigPath)
 }
 
 // Shutdown stops the router
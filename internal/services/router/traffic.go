@@ -0,0 +1,373 @@
+package router
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// ErrTrafficUnavailable is returned when the router exposes no request metrics
+var ErrTrafficUnavailable = errors.New("traffic metrics are not available")
+
+// Traffic sampling: request counters are scraped every interval and kept for the window,
+// so recent traffic can be told apart from the totals since Traefik started
+const (
+	trafficSampleInterval = time.Minute
+	trafficWindow         = time.Hour
+)
+
+// TrafficStats summarizes the requests the router served for an app. Totals count from
+// the proxy's start; the windowed counts come from periodic samples.
+type TrafficStats struct {
+	AppID         uuid.UUID        `json:"app_id"`
+	Requests      int64            `json:"requests"`
+	StatusCodes   map[string]int64 `json:"status_codes"`
+	StatusClasses map[string]int64 `json:"status_classes"`
+	Methods       map[string]int64 `json:"methods"`
+	ErrorRate     float64          `json:"error_rate"` // share of 5xx responses
+
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+	P50LatencyMs float64 `json:"p50_latency_ms"`
+	P95LatencyMs float64 `json:"p95_latency_ms"`
+	P99LatencyMs float64 `json:"p99_latency_ms"`
+
+	// Requests in the last 5 minutes and hour, nil until enough samples were taken
+	RequestsLast5m   *int64 `json:"requests_last_5m,omitempty"`
+	RequestsLastHour *int64 `json:"requests_last_hour,omitempty"`
+
+	CollectedAt time.Time `json:"collected_at"`
+}
+
+// serviceMetrics holds the Prometheus series of one Traefik service
+type serviceMetrics struct {
+	requests      int64
+	codes         map[string]int64
+	methods       map[string]int64
+	durationSum   float64
+	durationCount int64
+	buckets       map[float64]int64 // cumulative counts by upper bound in seconds
+}
+
+// trafficSample is a service's request total at a point in time
+type trafficSample struct {
+	at       time.Time
+	requests int64
+}
+
+// Traffic returns request counts, status codes and latency for an app's HTTP route
+func (r *TraefikRouter) Traffic(ctx context.Context, appID uuid.UUID) (*TrafficStats, error) {
+	if r.config.MetricsURL == "" {
+		return nil, fmt.Errorf("%w: no Traefik metrics URL is configured", ErrTrafficUnavailable)
+	}
+	route, exists := r.GetRoute(appID)
+	if !exists {
+		return nil, fmt.Errorf("route not found for app %s", appID)
+	}
+	if route.isL4() {
+		return nil, fmt.Errorf("%w: Traefik only reports request metrics for HTTP routes", ErrTrafficUnavailable)
+	}
+
+	services, err := r.scrapeMetrics(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	stats := &TrafficStats{
+		AppID:         appID,
+		StatusCodes:   make(map[string]int64),
+		StatusClasses: make(map[string]int64),
+		Methods:       make(map[string]int64),
+		CollectedAt:   now,
+	}
+	m, ok := services[r.metricsService(route)]
+	if !ok {
+		// Traefik only creates the series once the first request arrives
+		return stats, nil
+	}
+
+	stats.Requests = m.requests
+	for code, count := range m.codes {
+		stats.StatusCodes[code] = count
+		stats.StatusClasses[code[:1]+"xx"] += count
+	}
+	for method, count := range m.methods {
+		stats.Methods[method] = count
+	}
+	if m.requests > 0 {
+		stats.ErrorRate = float64(stats.StatusClasses["5xx"]) / float64(m.requests)
+	}
+	if m.durationCount > 0 {
+		stats.AvgLatencyMs = m.durationSum / float64(m.durationCount) * 1000
+	}
+	stats.P50LatencyMs = histogramQuantile(0.50, m.buckets) * 1000
+	stats.P95LatencyMs = histogramQuantile(0.95, m.buckets) * 1000
+	stats.P99LatencyMs = histogramQuantile(0.99, m.buckets) * 1000
+
+	stats.RequestsLast5m = r.requestsSince(r.metricsService(route), m.requests, now.Add(-5*time.Minute))
+	stats.RequestsLastHour = r.requestsSince(r.metricsService(route), m.requests, now.Add(-trafficWindow))
+	return stats, nil
+}
+
+// metricsService is the service label Traefik reports an app's requests under
+func (r *TraefikRouter) metricsService(route *Route) string {
+	return route.ServiceName + "@" + r.provider()
+}
+
+// requestsSince returns the requests served after the oldest sample taken at or after
+// since, nil when no sample reaches back that far
+func (r *TraefikRouter) requestsSince(service string, current int64, since time.Time) *int64 {
+	r.trafficMu.Lock()
+	defer r.trafficMu.Unlock()
+
+	samples := r.trafficSamples[service]
+	for _, sample := range samples {
+		if sample.at.Before(since) {
+			continue
+		}
+		// Only report a window the samples actually cover
+		if sample.at.Sub(since) > trafficSampleInterval+trafficSampleInterval/2 {
+			return nil
+		}
+		count := current - sample.requests
+		if count < 0 {
+			// Traefik restarted and its counters reset
+			count = current
+		}
+		return &count
+	}
+	return nil
+}
+
+// runTrafficSampler records each service's request total every sample interval
+func (r *TraefikRouter) runTrafficSampler() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(trafficSampleInterval)
+	defer ticker.Stop()
+
+	for {
+		r.sampleTraffic()
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sampleTraffic scrapes the request totals and drops samples older than the window
+func (r *TraefikRouter) sampleTraffic() {
+	ctx, cancel := context.WithTimeout(r.ctx, 10*time.Second)
+	defer cancel()
+
+	services, err := r.scrapeMetrics(ctx)
+	if err != nil {
+		r.logger.Debug("Failed to sample traffic metrics", zap.Error(err))
+		return
+	}
+
+	now := time.Now().UTC()
+	cutoff := now.Add(-trafficWindow - trafficSampleInterval)
+
+	r.trafficMu.Lock()
+	defer r.trafficMu.Unlock()
+	for service, m := range services {
+		samples := r.trafficSamples[service]
+		kept := samples[:0]
+		for _, sample := range samples {
+			if sample.at.After(cutoff) {
+				kept = append(kept, sample)
+			}
+		}
+		r.trafficSamples[service] = append(kept, trafficSample{at: now, requests: m.requests})
+	}
+	for service := range r.trafficSamples {
+		if _, ok := services[service]; !ok {
+			delete(r.trafficSamples, service)
+		}
+	}
+}
+
+// scrapeMetrics fetches Traefik's Prometheus metrics and groups the service series
+func (r *TraefikRouter) scrapeMetrics(ctx context.Context) (map[string]*serviceMetrics, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.config.MetricsURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("traefik metrics unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("traefik metrics returned %d", resp.StatusCode)
+	}
+	return parseServiceMetrics(resp.Body)
+}
+
+// parseServiceMetrics reads the traefik_service_* series from Prometheus text format
+func parseServiceMetrics(body io.Reader) (map[string]*serviceMetrics, error) {
+	services := make(map[string]*serviceMetrics)
+	get := func(name string) *serviceMetrics {
+		m, ok := services[name]
+		if !ok {
+			m = &serviceMetrics{
+				codes:   make(map[string]int64),
+				methods: make(map[string]int64),
+				buckets: make(map[float64]int64),
+			}
+			services[name] = m
+		}
+		return m
+	}
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "traefik_service_") {
+			continue
+		}
+		name, labels, value, ok := parseMetricLine(line)
+		if !ok || labels["service"] == "" {
+			continue
+		}
+		m := get(labels["service"])
+
+		switch name {
+		case "traefik_service_requests_total":
+			count := int64(value)
+			m.requests += count
+			if code := labels["code"]; code != "" {
+				m.codes[code] += count
+			}
+			if method := labels["method"]; method != "" {
+				m.methods[method] += count
+			}
+		case "traefik_service_request_duration_seconds_sum":
+			m.durationSum += value
+		case "traefik_service_request_duration_seconds_count":
+			m.durationCount += int64(value)
+		case "traefik_service_request_duration_seconds_bucket":
+			le, err := strconv.ParseFloat(labels["le"], 64)
+			if err == nil {
+				m.buckets[le] += int64(value)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read traefik metrics: %w", err)
+	}
+	return services, nil
+}
+
+// parseMetricLine splits `name{key="value",...} value` into its parts
+func parseMetricLine(line string) (name string, labels map[string]string, value float64, ok bool) {
+	labels = make(map[string]string)
+
+	rest := line
+	if open := strings.IndexByte(line, '{'); open >= 0 {
+		end := strings.LastIndexByte(line, '}')
+		if end < open {
+			return "", nil, 0, false
+		}
+		name = line[:open]
+		for _, pair := range splitLabels(line[open+1 : end]) {
+			key, raw, found := strings.Cut(pair, "=")
+			if !found {
+				continue
+			}
+			if unquoted, err := strconv.Unquote(raw); err == nil {
+				labels[key] = unquoted
+			}
+		}
+		rest = line[end+1:]
+	} else {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return "", nil, 0, false
+		}
+		name, rest = fields[0], strings.Join(fields[1:], " ")
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return "", nil, 0, false
+	}
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return "", nil, 0, false
+	}
+	return name, labels, value, true
+}
+
+// splitLabels splits a label set on the commas outside quoted values
+func splitLabels(s string) []string {
+	var pairs []string
+	inQuotes, escaped, start := false, false, 0
+	for i, c := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == '"':
+			inQuotes = !inQuotes
+		case c == ',' && !inQuotes:
+			pairs = append(pairs, s[start:i])
+			start = i + 1
+		}
+	}
+	if start < len(s) {
+		pairs = append(pairs, s[start:])
+	}
+	return pairs
+}
+
+// histogramQuantile estimates a quantile from cumulative histogram buckets, interpolating
+// linearly inside the bucket that holds it
+func histogramQuantile(q float64, buckets map[float64]int64) float64 {
+	bounds := make([]float64, 0, len(buckets))
+	for le := range buckets {
+		bounds = append(bounds, le)
+	}
+	sort.Float64s(bounds)
+	if len(bounds) == 0 {
+		return 0
+	}
+	total := buckets[bounds[len(bounds)-1]]
+	if total == 0 {
+		return 0
+	}
+
+	target := q * float64(total)
+	lower, below := 0.0, int64(0)
+	for _, le := range bounds {
+		count := buckets[le]
+		if float64(count) >= target {
+			if math.IsInf(le, 1) {
+				// Beyond the largest finite bucket: report its bound
+				return lower
+			}
+			if count == below {
+				return le
+			}
+			return lower + (le-lower)*(target-float64(below))/float64(count-below)
+		}
+		lower, below = le, count
+	}
+	return lower
+}
@@ -0,0 +1,161 @@
+package router
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ConfigStats counts proxy configuration updates so rejected configurations can be alerted on
+type ConfigStats struct {
+	Applied        int64
+	Rejected       int64
+	LastError      string // empty when the latest update was applied
+	LastRejectedAt time.Time
+}
+
+// configRecorder tracks the ConfigStats of a router
+type configRecorder struct {
+	mu    sync.Mutex
+	stats ConfigStats
+}
+
+// record counts an update, rejected when err is non-nil
+func (c *configRecorder) record(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err != nil {
+		c.stats.Rejected++
+		c.stats.LastError = err.Error()
+		c.stats.LastRejectedAt = time.Now().UTC()
+		return
+	}
+	c.stats.Applied++
+	c.stats.LastError = ""
+}
+
+// snapshot returns the current stats
+func (c *configRecorder) snapshot() ConfigStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// validateConfig checks a dynamic configuration, as Traefik will parse it, before it
+// replaces the running one. Slugs and hosts must be unique across routes, and every
+// service, middleware and transport a router or service names must be defined.
+func validateConfig(routes []*Route, platformDomain string, config map[string]interface{}) error {
+	slugs := make(map[string]uuid.UUID, len(routes))
+	hosts := make(map[string]string)
+	for _, route := range routes {
+		if owner, taken := slugs[route.AppSlug]; taken && owner != route.AppID {
+			return fmt.Errorf("apps %s and %s share the slug %q", owner, route.AppID, route.AppSlug)
+		}
+		slugs[route.AppSlug] = route.AppID
+
+		// Port-routed TCP and UDP apps are not matched by host
+		if route.isL4() && !route.SNI {
+			continue
+		}
+		for _, host := range route.hosts(platformDomain) {
+			host = strings.ToLower(host)
+			if owner, taken := hosts[host]; taken && owner != route.AppSlug {
+				return fmt.Errorf("host %q is routed to both %s and %s", host, owner, route.AppSlug)
+			}
+			hosts[host] = route.AppSlug
+		}
+	}
+
+	for _, kind := range []string{"http", "tcp", "udp"} {
+		section, _ := config[kind].(map[string]interface{})
+		if section == nil {
+			continue
+		}
+		services := configSection(section, "services")
+		middlewares := configSection(section, "middlewares")
+		transports := configSection(section, "serversTransports")
+
+		for _, name := range sortedKeys(configSection(section, "routers")) {
+			router, _ := configSection(section, "routers")[name].(map[string]interface{})
+			if router == nil {
+				return fmt.Errorf("%s router %s is empty", kind, name)
+			}
+			if kind == "http" && router["rule"] == nil {
+				return fmt.Errorf("%s router %s has no rule", kind, name)
+			}
+			service, _ := router["service"].(string)
+			if _, ok := services[service]; !ok {
+				return fmt.Errorf("%s router %s uses undefined service %q", kind, name, service)
+			}
+			list, _ := router["middlewares"].([]interface{})
+			for _, m := range list {
+				if _, ok := middlewares[fmt.Sprint(m)]; !ok {
+					return fmt.Errorf("%s router %s uses undefined middleware %q", kind, name, m)
+				}
+			}
+		}
+
+		for _, name := range sortedKeys(services) {
+			service, _ := services[name].(map[string]interface{})
+			loadBalancer, _ := service["loadBalancer"].(map[string]interface{})
+			if loadBalancer == nil {
+				return fmt.Errorf("%s service %s has no load balancer", kind, name)
+			}
+			if transport, ok := loadBalancer["serversTransport"].(string); ok {
+				if _, defined := transports[transport]; !defined {
+					return fmt.Errorf("%s service %s uses undefined servers transport %q", kind, name, transport)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// configSection returns a named map inside a configuration section, nil when absent
+func configSection(section map[string]interface{}, name string) map[string]interface{} {
+	m, _ := section[name].(map[string]interface{})
+	return m
+}
+
+// sortedKeys returns a map's keys in order, so validation reports the same error every time
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// writeFileAtomic replaces a file through a temporary file in the same directory, so
+// readers see either the old or the new content and never a partial write
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,287 @@
+package templates
+
+import (
+	"crypto/rand"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+
+	"github.com/nanopaas/nanopaas/internal/domain"
+	"github.com/nanopaas/nanopaas/internal/services/manifest"
+)
+
+//go:embed catalog/*.yaml
+var builtin embed.FS
+
+// Placeholders filled in when a template is instantiated. Passwords are generated once
+// per instance, so an app and its database agree on them.
+const (
+	SlugPlaceholder     = "{{slug}}"
+	PasswordPlaceholder = "{{password}}"
+)
+
+// idPattern matches template IDs and service names, which end up in slugs
+var idPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,30}[a-z0-9])?$`)
+
+// Template is a starter app from the catalog: an app manifest built from the template
+// repo, plus supporting services such as a database that run stock images
+type Template struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Category    string    `json:"category,omitempty"`
+	RepoURL     string    `json:"repo_url"`
+	Branch      string    `json:"branch,omitempty"`
+	Services    []Service `json:"services,omitempty"`
+
+	app     []byte            // manifest YAML with placeholders
+	secrets map[string]string // secret values with placeholders
+}
+
+// Service is a supporting app of a template, deployed from an image
+type Service struct {
+	Name  string `json:"name"` // appended to the app's slug
+	Image string `json:"image"`
+
+	app     []byte
+	secrets map[string]string
+}
+
+// Instance is a template filled in for one app
+type Instance struct {
+	App      *manifest.Manifest
+	Secrets  map[string]string
+	Services []ServiceInstance
+}
+
+// ServiceInstance is a supporting service filled in for one app. Its slug, which is also
+// its hostname on the app network, is <app slug>-<service name>.
+type ServiceInstance struct {
+	Name    string
+	Image   string
+	App     *manifest.Manifest
+	Secrets map[string]string
+}
+
+// Instantiate fills in the template for an app with the given name and slug
+func (t *Template) Instantiate(name, slug string) (*Instance, error) {
+	password, err := generatePassword()
+	if err != nil {
+		return nil, err
+	}
+	replacer := strings.NewReplacer(SlugPlaceholder, slug, PasswordPlaceholder, password)
+
+	app, err := fill(t.app, replacer, name, slug)
+	if err != nil {
+		return nil, err
+	}
+	instance := &Instance{App: app, Secrets: fillValues(t.secrets, replacer)}
+	for _, svc := range t.Services {
+		svcSlug := slug + "-" + svc.Name
+		m, err := fill(svc.app, replacer, svcSlug, svcSlug)
+		if err != nil {
+			return nil, fmt.Errorf("service %s: %w", svc.Name, err)
+		}
+		instance.Services = append(instance.Services, ServiceInstance{
+			Name:    svc.Name,
+			Image:   svc.Image,
+			App:     m,
+			Secrets: fillValues(svc.secrets, replacer),
+		})
+	}
+	return instance, nil
+}
+
+// fill parses a manifest with its placeholders replaced
+func fill(data []byte, replacer *strings.Replacer, name, slug string) (*manifest.Manifest, error) {
+	m, err := manifest.Parse([]byte(replacer.Replace(string(data))))
+	if err != nil {
+		return nil, err
+	}
+	m.Name = name
+	m.Slug = slug
+	return m, nil
+}
+
+func fillValues(values map[string]string, replacer *strings.Replacer) map[string]string {
+	filled := make(map[string]string, len(values))
+	for k, v := range values {
+		filled[k] = replacer.Replace(v)
+	}
+	return filled
+}
+
+func generatePassword() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate password: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Catalog is the set of templates users can create apps from
+type Catalog struct {
+	templates map[string]*Template
+}
+
+// Load reads the built-in templates and, when dir is set, the *.yaml templates in dir.
+// A template in dir replaces the built-in one with the same ID.
+func Load(dir string) (*Catalog, error) {
+	c := &Catalog{templates: make(map[string]*Template)}
+	if err := c.loadFS(builtin, "catalog"); err != nil {
+		return nil, err
+	}
+	if dir != "" {
+		if err := c.loadFS(os.DirFS(dir), "."); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (c *Catalog) loadFS(fsys fs.FS, dir string) error {
+	paths, err := fs.Glob(fsys, path.Join(dir, "*.yaml"))
+	if err != nil {
+		return fmt.Errorf("failed to list templates: %w", err)
+	}
+	for _, name := range paths {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return fmt.Errorf("failed to read template %s: %w", name, err)
+		}
+		t, err := Parse(data)
+		if err != nil {
+			return fmt.Errorf("template %s: %w", name, err)
+		}
+		c.templates[t.ID] = t
+	}
+	return nil
+}
+
+// List returns the templates sorted by name
+func (c *Catalog) List() []*Template {
+	list := make([]*Template, 0, len(c.templates))
+	for _, t := range c.templates {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Get returns a template by ID
+func (c *Catalog) Get(id string) (*Template, bool) {
+	t, ok := c.templates[id]
+	return t, ok
+}
+
+// templateFile is the YAML form of a template
+type templateFile struct {
+	ID          string            `yaml:"id"`
+	Name        string            `yaml:"name"`
+	Description string            `yaml:"description"`
+	Category    string            `yaml:"category"`
+	RepoURL     string            `yaml:"repo_url"`
+	Branch      string            `yaml:"branch"`
+	App         yaml.Node         `yaml:"app"`
+	Secrets     map[string]string `yaml:"secrets"`
+	Services    []struct {
+		Name    string            `yaml:"name"`
+		Image   string            `yaml:"image"`
+		App     yaml.Node         `yaml:"app"`
+		Secrets map[string]string `yaml:"secrets"`
+	} `yaml:"services"`
+}
+
+// Parse reads a template and checks that it instantiates to valid apps
+func Parse(data []byte) (*Template, error) {
+	var f templateFile
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+	if !idPattern.MatchString(f.ID) {
+		return nil, fmt.Errorf("id must be a lowercase slug of up to 32 characters")
+	}
+	if f.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if f.RepoURL == "" {
+		return nil, fmt.Errorf("repo_url is required")
+	}
+
+	t := &Template{
+		ID:          f.ID,
+		Name:        f.Name,
+		Description: f.Description,
+		Category:    f.Category,
+		RepoURL:     f.RepoURL,
+		Branch:      f.Branch,
+		secrets:     f.Secrets,
+	}
+	var err error
+	if t.app, err = nodeBytes(&f.App); err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for _, s := range f.Services {
+		if !idPattern.MatchString(s.Name) || seen[s.Name] {
+			return nil, fmt.Errorf("service names must be unique lowercase slugs")
+		}
+		seen[s.Name] = true
+		if s.Image == "" {
+			return nil, fmt.Errorf("service %s: image is required", s.Name)
+		}
+		svc := Service{Name: s.Name, Image: s.Image, secrets: s.Secrets}
+		if svc.app, err = nodeBytes(&s.App); err != nil {
+			return nil, err
+		}
+		t.Services = append(t.Services, svc)
+	}
+
+	// Catch broken templates at load time rather than when a user picks them
+	instance, err := t.Instantiate(t.ID, t.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validate(instance.App, instance.Secrets); err != nil {
+		return nil, err
+	}
+	for _, svc := range instance.Services {
+		if err := validate(svc.App, svc.Secrets); err != nil {
+			return nil, fmt.Errorf("service %s: %w", svc.Name, err)
+		}
+	}
+	return t, nil
+}
+
+// nodeBytes renders a manifest node, treating a missing one as an empty manifest
+func nodeBytes(node *yaml.Node) ([]byte, error) {
+	if node.Kind == 0 {
+		return []byte("{}"), nil
+	}
+	data, err := yaml.Marshal(node)
+	if err != nil {
+		return nil, fmt.Errorf("invalid app manifest: %w", err)
+	}
+	return data, nil
+}
+
+func validate(m *manifest.Manifest, secrets map[string]string) error {
+	if err := m.Apply(domain.NewApp(m.Name, m.Slug, uuid.Nil)); err != nil {
+		return err
+	}
+	for key, value := range secrets {
+		if err := domain.ValidateSecret(key, value); err != nil {
+			return fmt.Errorf("secret %s: %w", key, err)
+		}
+	}
+	return nil
+}
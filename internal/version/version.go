@@ -0,0 +1,32 @@
+// Package version reports the NanoPaaS build, set at link time with
+// -ldflags "-X github.com/nanopaas/nanopaas/internal/version.Version=..."
+package version
+
+import "runtime"
+
+// Set by the linker; unset in development builds
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildDate = "unknown"
+)
+
+// Info describes the running NanoPaaS build
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+}
+
+// Get returns the running build's version info
+func Get() Info {
+	return Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+}
@@ -0,0 +1,13 @@
+-- NanoPaaS Migration: App Runtime Options
+-- Version: 004
+-- Description: Per-app restart policy, ulimits, tmpfs mounts, shm size and sysctls
+
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS restart_policy VARCHAR(50) NOT NULL DEFAULT 'on-failure';
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS nofile_limit BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS tmpfs JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS shm_size BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS sysctls JSONB NOT NULL DEFAULT '{}';
+
+ALTER TABLE apps DROP CONSTRAINT IF EXISTS apps_restart_policy_check;
+ALTER TABLE apps ADD CONSTRAINT apps_restart_policy_check
+    CHECK (restart_policy IN ('no', 'always', 'on-failure', 'unless-stopped'));
@@ -0,0 +1,5 @@
+-- NanoPaaS Migration: App Smoke Checks
+-- Version: 005
+-- Description: Post-deploy smoke checks run after routing switches to a new deployment
+
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS smoke_checks JSONB NOT NULL DEFAULT '[]';
@@ -0,0 +1,20 @@
+-- NanoPaaS Migration: Image Promotions
+-- Version: 006
+-- Description: Record images retagged for an environment without a rebuild
+
+CREATE TABLE IF NOT EXISTS image_promotions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    app_slug VARCHAR(255) NOT NULL,
+    build_id VARCHAR(64),
+    image_id VARCHAR(255) NOT NULL,
+    source_ref VARCHAR(512) NOT NULL,
+    target_tag VARCHAR(512) NOT NULL,
+    environment VARCHAR(32) NOT NULL,
+    pushed BOOLEAN NOT NULL DEFAULT FALSE,
+    digest VARCHAR(255),
+    promoted_by UUID,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_image_promotions_app_env ON image_promotions(app_slug, environment, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_image_promotions_image ON image_promotions(image_id);
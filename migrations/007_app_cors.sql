@@ -0,0 +1,5 @@
+-- NanoPaaS Migration: App CORS Policies
+-- Version: 007
+-- Description: Per-app cross-origin policies rendered as router middleware
+
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS cors JSONB;
@@ -0,0 +1,6 @@
+-- NanoPaaS Migration: App Basic Auth
+-- Version: 008
+-- Description: Router-enforced HTTP basic auth credentials for protecting apps
+
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS basic_auth_user VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS basic_auth_hash VARCHAR(255) NOT NULL DEFAULT '';
@@ -0,0 +1,6 @@
+-- NanoPaaS Migration: App Streaming Mode
+-- Version: 009
+-- Description: Per-app proxy tuning for WebSocket and SSE routes
+
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS streaming_mode VARCHAR(20) NOT NULL DEFAULT '';
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS stream_idle_timeout INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,6 @@
+-- NanoPaaS Migration: Container Exit Reporting
+-- Version: 010
+-- Description: Last container exit code and OOM state for apps and deployments
+
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS last_exit JSONB;
+ALTER TABLE deployments ADD COLUMN IF NOT EXISTS last_exit JSONB;
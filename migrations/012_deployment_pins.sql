@@ -0,0 +1,6 @@
+-- NanoPaaS Migration: Deployment Pins
+-- Version: 012
+-- Description: Pin an app's current deployment against automatic replacement
+
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS pin JSONB;
+ALTER TABLE deployments ADD COLUMN IF NOT EXISTS pinned BOOLEAN NOT NULL DEFAULT FALSE;
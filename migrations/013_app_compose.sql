@@ -0,0 +1,7 @@
+-- NanoPaaS Migration: Compose Import
+-- Version: 013
+-- Description: Command overrides, named volumes and network aliases for apps imported from docker-compose
+
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS command JSONB;
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS volumes JSONB;
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS network_aliases JSONB;
@@ -0,0 +1,24 @@
+-- NanoPaaS Migration: Incidents
+-- Version: 014
+-- Description: Incident records with timelines and app traffic lockdown
+
+CREATE TABLE IF NOT EXISTS incidents (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    app_id UUID NOT NULL REFERENCES apps(id) ON DELETE CASCADE,
+    title VARCHAR(255) NOT NULL,
+    status VARCHAR(32) NOT NULL DEFAULT 'open',
+    lockdown VARCHAR(32) NOT NULL DEFAULT 'none',
+    pinned_deployment_id UUID,
+    diagnostics JSONB,
+    timeline JSONB NOT NULL DEFAULT '[]',
+    opened_by UUID NOT NULL,
+    opened_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    resolved_by UUID,
+    resolved_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_incidents_app_opened ON incidents(app_id, opened_at DESC);
+-- At most one open incident per app
+CREATE UNIQUE INDEX IF NOT EXISTS idx_incidents_app_open ON incidents(app_id) WHERE status = 'open';
+
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS lockdown JSONB;
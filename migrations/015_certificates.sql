@@ -0,0 +1,24 @@
+-- NanoPaaS Migration: Certificates
+-- Version: 015
+-- Description: ACME account and issued TLS certificates
+
+CREATE TABLE IF NOT EXISTS acme_accounts (
+    email VARCHAR(255) PRIMARY KEY,
+    key_pem TEXT NOT NULL,
+    uri TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS certificates (
+    domain VARCHAR(255) PRIMARY KEY,
+    domains TEXT[] NOT NULL,
+    cert_pem TEXT NOT NULL,
+    key_pem TEXT NOT NULL,
+    issuer VARCHAR(255) NOT NULL DEFAULT '',
+    not_before TIMESTAMPTZ NOT NULL,
+    not_after TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_certificates_not_after ON certificates(not_after);
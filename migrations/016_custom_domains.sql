@@ -0,0 +1,23 @@
+-- NanoPaaS Migration: Custom Domains
+-- Version: 016
+-- Description: User-owned domains attached to apps, verified through DNS
+
+CREATE TABLE IF NOT EXISTS custom_domains (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    app_id UUID NOT NULL REFERENCES apps(id) ON DELETE CASCADE,
+    hostname VARCHAR(253) NOT NULL,
+    status VARCHAR(32) NOT NULL DEFAULT 'pending',
+    token VARCHAR(255) NOT NULL,
+    txt_record VARCHAR(255) NOT NULL,
+    last_error TEXT NOT NULL DEFAULT '',
+    last_checked_at TIMESTAMPTZ,
+    verified_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- A host name can be attached to only one app
+CREATE UNIQUE INDEX IF NOT EXISTS idx_custom_domains_hostname ON custom_domains(hostname);
+CREATE INDEX IF NOT EXISTS idx_custom_domains_app ON custom_domains(app_id);
+
+-- Verified host names routed to each app
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS custom_domains JSONB;
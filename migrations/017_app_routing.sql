@@ -0,0 +1,5 @@
+-- NanoPaaS Migration: App Routing Policies
+-- Version: 017
+-- Description: Per-app IP allowlists and rate limits rendered as router middleware
+
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS routing JSONB;
@@ -0,0 +1,5 @@
+-- NanoPaaS Migration: App HSTS Policies
+-- Version: 018
+-- Description: Per-app Strict-Transport-Security headers added by the router
+
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS hsts JSONB;
@@ -0,0 +1,5 @@
+-- NanoPaaS Migration: Sticky Sessions
+-- Version: 019
+-- Description: Per-app session affinity cookies on the router's load balancer
+
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS sticky_sessions JSONB;
@@ -0,0 +1,5 @@
+-- NanoPaaS Migration: Maintenance Pages
+-- Version: 020
+-- Description: Placeholder pages served instead of an app's containers
+
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS maintenance_page JSONB;
@@ -0,0 +1,5 @@
+-- NanoPaaS Migration: TCP/UDP Routes
+-- Version: 021
+-- Description: Apps exposed as raw TCP or UDP services on allocated router ports
+
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS network_route JSONB;
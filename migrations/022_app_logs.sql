@@ -0,0 +1,17 @@
+-- NanoPaaS Migration: App Log History
+-- Version: 022
+-- Description: Shipped container logs kept after containers are removed. Daily partitions
+-- are created by the log shipper and dropped whole once past retention.
+
+CREATE TABLE IF NOT EXISTS app_logs (
+    app_id UUID NOT NULL,
+    container_id VARCHAR(64) NOT NULL,
+    container_name VARCHAR(255) NOT NULL DEFAULT '',
+    replica INTEGER,
+    stream VARCHAR(16) NOT NULL,
+    message TEXT NOT NULL,
+    logged_at TIMESTAMPTZ NOT NULL
+) PARTITION BY RANGE (logged_at);
+
+CREATE INDEX IF NOT EXISTS idx_app_logs_app_logged ON app_logs(app_id, logged_at DESC);
+CREATE INDEX IF NOT EXISTS idx_app_logs_container_logged ON app_logs(container_id, logged_at DESC);
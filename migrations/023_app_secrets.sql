@@ -0,0 +1,5 @@
+-- NanoPaaS Migration: App Secrets
+-- Version: 023
+-- Description: App secrets, AES-GCM encrypted with the SECRETS_MASTER_KEY and keyed by name
+
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS secrets JSONB NOT NULL DEFAULT '{}';
@@ -0,0 +1,6 @@
+-- NanoPaaS Migration: Env Restart Policy
+-- Version: 024
+-- Description: How env var and secret changes reach running containers, and when containers are stale
+
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS env_restart_policy VARCHAR(20) NOT NULL DEFAULT '';
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS pending_restart_since TIMESTAMPTZ;
@@ -0,0 +1,5 @@
+-- NanoPaaS Migration: Deployment Approval
+-- Version: 025
+-- Description: Protected apps whose webhook-triggered deployments wait for approval
+
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS require_approval BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,18 @@
+-- NanoPaaS Migration: Notification Channels
+-- Version: 026
+-- Description: Slack, Discord and generic webhooks receiving deploy, build and crash-loop events
+
+CREATE TABLE IF NOT EXISTS notification_channels (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    app_id UUID REFERENCES apps(id) ON DELETE CASCADE, -- NULL for global channels
+    name VARCHAR(100) NOT NULL,
+    kind VARCHAR(20) NOT NULL,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL DEFAULT '',
+    events TEXT[] NOT NULL DEFAULT '{}',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by UUID NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_notification_channels_app ON notification_channels(app_id);
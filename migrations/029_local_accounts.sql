@@ -0,0 +1,21 @@
+-- NanoPaaS Migration: Local Accounts
+-- Version: 029
+-- Description: Email/password sign-in alongside GitHub, with one-time email verification and password reset tokens
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS password_hash TEXT;
+
+-- Sign-in matches emails case-insensitively
+CREATE INDEX IF NOT EXISTS idx_users_email_lower ON users(LOWER(email));
+
+CREATE TABLE IF NOT EXISTS user_tokens (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose VARCHAR(30) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE, -- hex SHA-256 of the token
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT user_tokens_purpose_check CHECK (purpose IN ('email_verification', 'password_reset'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_tokens_user ON user_tokens(user_id, purpose);
@@ -0,0 +1,12 @@
+-- NanoPaaS Migration: User Administration
+-- Version: 031
+-- Description: Deactivated accounts, and user deletion that keeps team invitations
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deactivated_at TIMESTAMPTZ;
+
+-- Deleting a user who invited others used to fail on this reference
+ALTER TABLE team_members DROP CONSTRAINT IF EXISTS team_members_invited_by_fkey;
+ALTER TABLE team_members ADD CONSTRAINT team_members_invited_by_fkey
+    FOREIGN KEY (invited_by) REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_users_role ON users(role);
@@ -0,0 +1,16 @@
+-- NanoPaaS Migration: App Collaborators
+-- Version: 033
+-- Description: Per-app read or deploy access for users outside the app's team
+
+CREATE TABLE IF NOT EXISTS app_collaborators (
+    app_id UUID NOT NULL REFERENCES apps(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    permission VARCHAR(20) NOT NULL DEFAULT 'read',
+    granted_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (app_id, user_id),
+    CONSTRAINT app_collaborators_permission_check CHECK (permission IN ('read', 'deploy'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_app_collaborators_user ON app_collaborators(user_id);
@@ -0,0 +1,17 @@
+-- NanoPaaS Migration: Deploy Tokens
+-- Version: 034
+-- Description: Short-lived per-app tokens letting external CI build and deploy one app, stored hashed
+
+CREATE TABLE IF NOT EXISTS deploy_tokens (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    app_id UUID NOT NULL REFERENCES apps(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(20) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE, -- hex SHA-256 of the token
+    created_by UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    last_used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_deploy_tokens_app ON deploy_tokens(app_id);
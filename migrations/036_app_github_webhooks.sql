@@ -0,0 +1,5 @@
+-- NanoPaaS Migration: App GitHub Webhooks
+-- Version: 036
+-- Description: The GitHub webhook created for each app, deleted from GitHub with the app
+
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS github_webhook JSONB;
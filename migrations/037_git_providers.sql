@@ -0,0 +1,18 @@
+-- NanoPaaS Migration: Git Providers
+-- Version: 037
+-- Description: The git provider each app builds from, and users' tokens for providers other than GitHub
+
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS git_provider TEXT NOT NULL DEFAULT 'github';
+
+CREATE TABLE IF NOT EXISTS git_connections (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider TEXT NOT NULL,
+    username TEXT NOT NULL DEFAULT '',
+    token TEXT NOT NULL,
+    refresh_token TEXT NOT NULL DEFAULT '',
+    expires_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (user_id, provider)
+);
@@ -0,0 +1,8 @@
+-- NanoPaaS Migration: Deploy Triggers
+-- Version: 038
+-- Description: Whether apps auto-deploy on branch pushes, tag pushes or GitHub releases, and the tag each build was made from
+
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS deploy_trigger TEXT NOT NULL DEFAULT 'branch';
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS tag_pattern TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE builds ADD COLUMN IF NOT EXISTS git_tag TEXT NOT NULL DEFAULT '';
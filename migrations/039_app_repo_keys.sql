@@ -0,0 +1,16 @@
+-- NanoPaaS Migration: App Repository Keys
+-- Version: 039
+-- Description: Normalized repository of each app, matching pushes delivered to the global GitHub webhook to apps
+
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS git_repo_key TEXT NOT NULL DEFAULT '';
+
+-- Same normalization as domain.GitRepoKey: lower-case host/owner/repo without scheme,
+-- credentials or .git
+UPDATE apps SET git_repo_key = lower(regexp_replace(
+    regexp_replace(
+        regexp_replace(git_repo_url, '^[a-zA-Z][a-zA-Z0-9+.-]*://([^@/]*@)?', ''),
+        '^[^@/:]*@([^:/]+):', '\1/'),
+    '(\.git)?/*$', ''))
+WHERE git_repo_url IS NOT NULL AND git_repo_url <> '' AND git_repo_key = '';
+
+CREATE INDEX IF NOT EXISTS idx_apps_git_repo_key ON apps(git_repo_key) WHERE auto_deploy;
@@ -0,0 +1,8 @@
+-- NanoPaaS Migration: Build Commits
+-- Version: 040
+-- Description: The pushed commits each webhook-triggered build was made from
+
+ALTER TABLE builds ADD COLUMN IF NOT EXISTS commit_message TEXT NOT NULL DEFAULT '';
+ALTER TABLE builds ADD COLUMN IF NOT EXISTS commit_author TEXT NOT NULL DEFAULT '';
+ALTER TABLE builds ADD COLUMN IF NOT EXISTS compare_url TEXT NOT NULL DEFAULT '';
+ALTER TABLE builds ADD COLUMN IF NOT EXISTS commits JSONB NOT NULL DEFAULT '[]';
@@ -0,0 +1,5 @@
+-- NanoPaaS Migration: App Webhook Secrets
+-- Version: 041
+-- Description: Per-app secrets signing webhook deliveries, sealed with the secrets master key
+
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS webhook_secret BYTEA;
@@ -0,0 +1,5 @@
+-- NanoPaaS Migration: App Required Checks
+-- Version: 042
+-- Description: Hold auto-deploys from GitHub until the commit's required status checks pass
+
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS require_checks BOOLEAN NOT NULL DEFAULT false;
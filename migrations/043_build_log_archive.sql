@@ -0,0 +1,13 @@
+-- NanoPaaS Migration: Build Log Archive
+-- Version: 043
+-- Description: Keep finished builds and their full logs, written by the builder as it runs
+
+-- Allow the statuses builds are recorded with; pending and success remain for older rows
+ALTER TABLE builds DROP CONSTRAINT IF EXISTS builds_status_check;
+ALTER TABLE builds ADD CONSTRAINT builds_status_check
+    CHECK (status IN ('queued', 'pending', 'running', 'succeeded', 'success', 'failed', 'cancelled'));
+
+-- Pages of a log are read by line number after a cursor
+CREATE UNIQUE INDEX IF NOT EXISTS idx_build_logs_build_line_unique ON build_logs(build_id, line_number);
+DROP INDEX IF EXISTS idx_build_logs_build_line;
+DROP INDEX IF EXISTS idx_build_logs_build_id;
@@ -0,0 +1,23 @@
+-- NanoPaaS Migration: App Env and Label Objects
+-- Version: 044
+-- Description: Keep app env vars and labels as JSONB objects, indexed for key and label lookups
+
+UPDATE apps SET env_vars = '{}' WHERE env_vars IS NULL OR jsonb_typeof(env_vars) <> 'object';
+UPDATE apps SET labels = '{}' WHERE labels IS NULL OR jsonb_typeof(labels) <> 'object';
+
+ALTER TABLE apps
+    ALTER COLUMN env_vars SET DEFAULT '{}',
+    ALTER COLUMN env_vars SET NOT NULL,
+    ALTER COLUMN labels SET DEFAULT '{}',
+    ALTER COLUMN labels SET NOT NULL;
+
+ALTER TABLE apps DROP CONSTRAINT IF EXISTS apps_env_vars_object;
+ALTER TABLE apps ADD CONSTRAINT apps_env_vars_object CHECK (jsonb_typeof(env_vars) = 'object');
+ALTER TABLE apps DROP CONSTRAINT IF EXISTS apps_labels_object;
+ALTER TABLE apps ADD CONSTRAINT apps_labels_object CHECK (jsonb_typeof(labels) = 'object');
+
+-- Serve labels @> '{"key": "value"}' and labels ? 'key', and the same on env_vars
+CREATE INDEX IF NOT EXISTS idx_apps_labels ON apps USING GIN (labels);
+CREATE INDEX IF NOT EXISTS idx_apps_env_vars ON apps USING GIN (env_vars);
+
+COMMENT ON COLUMN apps.labels IS 'Labels as a JSON object of strings';
@@ -0,0 +1,6 @@
+-- NanoPaaS Migration: App Overview
+-- Version: 045
+-- Description: Index each app's builds and deployments newest first, for the dashboard overview
+
+CREATE INDEX IF NOT EXISTS idx_builds_app_created ON builds(app_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_deployments_app_created ON deployments(app_id, created_at DESC);
@@ -0,0 +1,38 @@
+-- NanoPaaS Migration: Change Notifications
+-- Version: 046
+-- Description: Notify listening API instances of changes to apps and projects, so each refreshes its cached copies
+
+-- Sends {"table", "id", "origin"} on the nanopaas_changes channel. The origin is the
+-- writing connection's application_name, which lets an instance skip its own changes.
+-- Notifications are delivered when the writing transaction commits, not before.
+CREATE OR REPLACE FUNCTION nanopaas_notify_change()
+RETURNS TRIGGER AS $$
+DECLARE
+    changed_id UUID;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        changed_id := OLD.id;
+    ELSE
+        changed_id := NEW.id;
+    END IF;
+
+    PERFORM pg_notify('nanopaas_changes', json_build_object(
+        'table', TG_TABLE_NAME,
+        'id', changed_id,
+        'origin', COALESCE(current_setting('application_name', true), '')
+    )::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS apps_notify_change ON apps;
+CREATE TRIGGER apps_notify_change
+    AFTER INSERT OR UPDATE OR DELETE ON apps
+    FOR EACH ROW
+    EXECUTE FUNCTION nanopaas_notify_change();
+
+DROP TRIGGER IF EXISTS projects_notify_change ON projects;
+CREATE TRIGGER projects_notify_change
+    AFTER INSERT OR UPDATE OR DELETE ON projects
+    FOR EACH ROW
+    EXECUTE FUNCTION nanopaas_notify_change();
@@ -0,0 +1,12 @@
+-- NanoPaaS Migration: App Log Cursors
+-- Version: 047
+-- Description: How far the log shipper has stored each container's logs, so a restart
+-- resumes where it stopped instead of shipping the container's whole history again
+
+CREATE TABLE IF NOT EXISTS app_log_cursors (
+    container_id VARCHAR(64) PRIMARY KEY,
+    shipped_until TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_app_log_cursors_shipped_until ON app_log_cursors(shipped_until);
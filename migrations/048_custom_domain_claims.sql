@@ -0,0 +1,9 @@
+-- NanoPaaS Migration: Custom Domain Claims
+-- Version: 048
+-- Description: Only a verified host name is exclusive to its app. Any number of apps may
+-- claim a host name until one of them proves it owns it, so an unverified claim can't
+-- keep the owner from adding their domain.
+
+DROP INDEX IF EXISTS idx_custom_domains_hostname;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_custom_domains_verified_hostname ON custom_domains(hostname) WHERE status = 'verified';
+CREATE UNIQUE INDEX IF NOT EXISTS idx_custom_domains_app_hostname ON custom_domains(app_id, hostname);
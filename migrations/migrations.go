@@ -0,0 +1,13 @@
+// Package migrations embeds the SQL schema migrations into the nanopaas binary.
+//
+// Files are named NNN_description.sql and applied in version order. Each one must be
+// safe to run against a database that already has its changes, since installs set up
+// before migrations were tracked have no record of what was applied.
+package migrations
+
+import "embed"
+
+// FS holds every migration file
+//
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,246 @@
+// Package openapi builds OpenAPI 3 documents from registered routes, deriving
+// request and response schemas from Go types
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Version is the OpenAPI version documents are written in
+const Version = "3.0.3"
+
+// Document is an OpenAPI document
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       Info                             `json:"info"`
+	Servers    []Server                         `json:"servers,omitempty"`
+	Tags       []Tag                            `json:"tags,omitempty"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components Components                       `json:"components"`
+
+	operationIDs map[string]int
+	schemas      *schemaRegistry
+}
+
+// Info describes the API
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Server is a base URL the API is served from
+type Server struct {
+	URL string `json:"url"`
+}
+
+// Tag groups operations
+type Tag struct {
+	Name string `json:"name"`
+}
+
+// Components holds the reusable schemas and security schemes
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas,omitempty"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme describes how requests authenticate
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+// Operation is a single method on a path
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+}
+
+// Parameter is a path or query parameter
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody describes a JSON request body
+type RequestBody struct {
+	Required bool                  `json:"required,omitempty"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+// Response describes a response
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// MediaType holds the schema of a body
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Route describes an operation to add to a document
+type Route struct {
+	Method      string
+	Pattern     string // chi-style pattern relative to the server URL, e.g. /apps/{appId}
+	OperationID string
+	Summary     string
+	Tag         string
+	Secured     bool // requires a bearer token
+	Deprecated  bool
+
+	Request  interface{} // zero value of the JSON request body type, nil for none
+	Response interface{} // zero value of the JSON response body type, nil for none
+	Status   int         // success status, 200 when zero
+
+	// Params documents query parameters
+	Params []Parameter
+}
+
+// bearerScheme names the bearer token security scheme
+const bearerScheme = "bearerAuth"
+
+// NewDocument creates an empty document served from serverURL
+func NewDocument(info Info, serverURL string) *Document {
+	schemas := newSchemaRegistry()
+	schemas.register("Error", &Schema{
+		Type:       "object",
+		Properties: map[string]*Schema{"error": {Type: "string"}},
+		Required:   []string{"error"},
+	})
+	return &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Servers: []Server{{URL: serverURL}},
+		Paths:   make(map[string]map[string]*Operation),
+		Components: Components{
+			Schemas: schemas.schemas,
+			SecuritySchemes: map[string]*SecurityScheme{
+				bearerScheme: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+		operationIDs: make(map[string]int),
+		schemas:      schemas,
+	}
+}
+
+// chiParam matches a chi path parameter, optionally with a regexp
+var chiParam = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+// AddRoute adds an operation to the document
+func (d *Document) AddRoute(rt Route) {
+	path := chiParam.ReplaceAllString(rt.Pattern, "{$1}")
+	if len(path) > 1 {
+		path = strings.TrimSuffix(path, "/")
+	}
+	path = strings.ReplaceAll(path, "*", "{path}")
+
+	op := &Operation{
+		OperationID: d.uniqueOperationID(rt.OperationID),
+		Summary:     rt.Summary,
+		Responses:   make(map[string]*Response),
+		Deprecated:  rt.Deprecated,
+	}
+	if rt.Tag != "" {
+		op.Tags = []string{rt.Tag}
+		d.addTag(rt.Tag)
+	}
+	if rt.Secured {
+		op.Security = []map[string][]string{{bearerScheme: {}}}
+	}
+
+	for _, match := range chiParam.FindAllStringSubmatch(path, -1) {
+		op.Parameters = append(op.Parameters, Parameter{
+			Name:     match[1],
+			In:       "path",
+			Required: true,
+			Schema:   &Schema{Type: "string"},
+		})
+	}
+	op.Parameters = append(op.Parameters, rt.Params...)
+
+	if rt.Request != nil {
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  jsonContent(d.schemas.schemaFor(rt.Request)),
+		}
+	}
+
+	status := rt.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := &Response{Description: http.StatusText(status)}
+	if rt.Response != nil {
+		success.Content = jsonContent(d.schemas.schemaFor(rt.Response))
+	}
+	op.Responses[strconv.Itoa(status)] = success
+	op.Responses["default"] = &Response{
+		Description: "Error",
+		Content:     jsonContent(Ref("Error")),
+	}
+
+	methods, ok := d.Paths[path]
+	if !ok {
+		methods = make(map[string]*Operation)
+		d.Paths[path] = methods
+	}
+	methods[strings.ToLower(rt.Method)] = op
+}
+
+// SetErrorType replaces the Error component, which every operation's default response
+// references, with the schema of a struct value's type
+func (d *Document) SetErrorType(v interface{}) {
+	d.schemas.register("Error", d.schemas.structSchema(reflect.TypeOf(v)))
+}
+
+// Schema returns the schema of a Go value's type, registering named struct types as components
+func (d *Document) Schema(v interface{}) *Schema {
+	return d.schemas.schemaFor(v)
+}
+
+// uniqueOperationID suffixes repeated operation IDs with a counter
+func (d *Document) uniqueOperationID(id string) string {
+	d.operationIDs[id]++
+	if n := d.operationIDs[id]; n > 1 {
+		return id + strconv.Itoa(n)
+	}
+	return id
+}
+
+// addTag records a tag, keeping tags sorted
+func (d *Document) addTag(name string) {
+	for _, t := range d.Tags {
+		if t.Name == name {
+			return
+		}
+	}
+	d.Tags = append(d.Tags, Tag{Name: name})
+	sort.Slice(d.Tags, func(i, j int) bool { return d.Tags[i].Name < d.Tags[j].Name })
+}
+
+// jsonContent wraps a schema as an application/json body
+func jsonContent(schema *Schema) map[string]*MediaType {
+	return map[string]*MediaType{"application/json": {Schema: schema}}
+}
+
+// QueryParam documents a query parameter of the given JSON schema type
+func QueryParam(name, typ, description string) Parameter {
+	return Parameter{Name: name, In: "query", Description: description, Schema: &Schema{Type: typ}}
+}
@@ -1,7 +1,12 @@
 package websocket
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
@@ -24,8 +29,88 @@ const (
 
 	// Buffer size for client message channel
 	messageBufferSize = 256
+
+	// Maximum topics a client may subscribe to itself
+	maxClientTopics = 32
+
+	// Messages in a row a client may miss because its buffer is full before it is
+	// disconnected as too slow
+	maxConsecutiveDrops = 64
+)
+
+// Client-driven subscriptions let one connection multiplex several topics. Clients send
+//
+//	{"action": "subscribe", "topic": "build:<id>", "id": "1"}
+//	{"action": "unsubscribe", "topic": "build:<id>", "id": "2"}
+//	{"action": "ping"}
+//
+// and receive "subscribed", "unsubscribed" or "pong" acknowledgements, or "error" frames,
+// as envelopes whose payload holds the request id and error. The optional id is echoed
+// back so clients can match replies to requests.
+const (
+	ActionSubscribe   = "subscribe"
+	ActionUnsubscribe = "unsubscribe"
+	ActionPing        = "ping"
 )
 
+// ErrSubscriptionsDisabled is returned for subscribe requests on connections without an authorizer
+var ErrSubscriptionsDisabled = errors.New("subscriptions are not enabled on this connection")
+
+// TopicAuthorizer decides whether a client may subscribe to a topic, returning the reason when not
+type TopicAuthorizer func(topic string) error
+
+// ClientMessage is a control message sent by a client
+type ClientMessage struct {
+	Action string `json:"action"`
+	Topic  string `json:"topic,omitempty"`
+	ID     string `json:"id,omitempty"`
+}
+
+// Envelope wraps every message sent to clients
+type Envelope struct {
+	Topic     string          `json:"topic,omitempty"`
+	Type      string          `json:"type"`
+	Timestamp time.Time       `json:"timestamp"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+}
+
+// ControlPayload is the payload of acknowledgements and error frames
+type ControlPayload struct {
+	ID    string `json:"id,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// NewEnvelope encodes a message for a topic. Payloads that are already JSON are embedded
+// as is, other byte slices and strings are sent as JSON strings, and any other value is
+// marshalled.
+func NewEnvelope(topic, messageType string, payload interface{}) ([]byte, error) {
+	var raw json.RawMessage
+	switch p := payload.(type) {
+	case nil:
+	case json.RawMessage:
+		raw = p
+	case []byte:
+		if json.Valid(p) {
+			raw = p
+		} else if encoded, err := json.Marshal(string(p)); err == nil {
+			raw = encoded
+		}
+	default:
+		encoded, err := json.Marshal(p)
+		if err != nil {
+			return nil, err
+		}
+		raw = encoded
+	}
+
+	return json.Marshal(Envelope{
+		Topic:     topic,
+		Type:      messageType,
+		Timestamp: time.Now().UTC(),
+		Payload:   raw,
+	})
+}
+
 // Client represents a WebSocket client connection
 type Client struct {
 	ID       uuid.UUID
@@ -34,6 +119,17 @@ type Client struct {
 	Send     chan []byte
 	Topics   map[string]bool
 	topicsMu sync.RWMutex
+
+	authorize TopicAuthorizer
+
+	// Guards Send against replies written after the hub closed it
+	sendMu sync.Mutex
+	closed bool
+
+	// Messages dropped because Send was full: in a row (hub goroutine only) and in total
+	drops       int
+	dropped     atomic.Int64
+	closeReason string // sent in the close frame when the hub disconnects the client
 }
 
 // Hub maintains the set of active clients and broadcasts messages
@@ -62,23 +158,77 @@ type Hub struct {
 	// Mutex for thread-safe operations
 	mu sync.RWMutex
 
+	// Relays broadcasts to other instances, nil when running alone
+	bridge Bridge
+
+	// Recent messages of replayed topics, sent to new subscribers first
+	history        map[string]*topicHistory
+	replaySize     int
+	replayTTL      time.Duration
+	replayPatterns []string
+
+	// Backpressure counters
+	droppedMessages atomic.Int64
+	slowDisconnects atomic.Int64
+
 	// done channel for graceful shutdown
 	done chan struct{}
 
 	logger *zap.Logger
 }
 
-// Message represents a message to broadcast
+// Message represents a message to broadcast; Payload is the encoded Envelope
 type Message struct {
 	Topic   string `json:"topic"`
 	Type    string `json:"type"`
 	Payload []byte `json:"payload"`
 }
 
+// HubStats reports the hub's clients and how many messages slow clients missed
+type HubStats struct {
+	Clients                 int   `json:"clients"`
+	Topics                  int   `json:"topics"`
+	DroppedMessages         int64 `json:"dropped_messages"` // Messages not delivered because a client's buffer was full
+	SlowClientsDisconnected int64 `json:"slow_clients_disconnected"`
+}
+
+// Bridge relays broadcasts between hub instances, e.g. API replicas behind a load balancer.
+// Publish must not block; messages from other instances are passed to BroadcastLocal.
+type Bridge interface {
+	Publish(message *Message)
+}
+
 // Subscription represents a topic subscription request
 type Subscription struct {
 	Client *Client
 	Topic  string
+
+	done chan struct{} // closed once the hub applied the change
+}
+
+// topicHistory is a ring buffer of a topic's most recent messages
+type topicHistory struct {
+	messages [][]byte
+	next     int
+	updated  time.Time
+}
+
+// add records a message, overwriting the oldest once size messages are kept
+func (t *topicHistory) add(payload []byte, size int) {
+	if len(t.messages) < size {
+		t.messages = append(t.messages, payload)
+	} else {
+		t.messages[t.next] = payload
+		t.next = (t.next + 1) % size
+	}
+	t.updated = time.Now()
+}
+
+// ordered returns the kept messages, oldest first
+func (t *topicHistory) ordered() [][]byte {
+	out := make([][]byte, 0, len(t.messages))
+	out = append(out, t.messages[t.next:]...)
+	return append(out, t.messages[:t.next]...)
 }
 
 // NewHub creates a new Hub instance
@@ -91,21 +241,69 @@ func NewHub(logger *zap.Logger) *Hub {
 		unregister:  make(chan *Client),
 		subscribe:   make(chan *Subscription),
 		unsubscribe: make(chan *Subscription),
+		history:     make(map[string]*topicHistory),
 		done:        make(chan struct{}),
 		logger:      logger,
 	}
 }
 
+// SetReplay keeps the last size messages of topics matching one of the patterns, e.g.
+// "build:*" or "app:*:events" (path.Match syntax), so clients subscribing late, e.g. after
+// a build started, receive them before live messages. A topic's history is dropped once
+// nothing was broadcast to it for ttl. Call before Run.
+func (h *Hub) SetReplay(size int, ttl time.Duration, patterns ...string) {
+	if size > messageBufferSize {
+		size = messageBufferSize // replay must fit the client's send buffer
+	}
+	h.replaySize = size
+	h.replayTTL = ttl
+	h.replayPatterns = patterns
+}
+
+// replays reports whether a topic's recent messages are kept for late subscribers
+func (h *Hub) replays(topic string) bool {
+	if h.replaySize <= 0 {
+		return false
+	}
+	for _, pattern := range h.replayPatterns {
+		if ok, _ := path.Match(pattern, topic); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// expireHistory drops the history of topics idle for longer than the replay TTL
+func (h *Hub) expireHistory() {
+	if h.replayTTL <= 0 {
+		return
+	}
+	cutoff := time.Now().Add(-h.replayTTL)
+	for topic, history := range h.history {
+		if history.updated.Before(cutoff) {
+			delete(h.history, topic)
+		}
+	}
+}
+
 // Run starts the hub's main loop
 func (h *Hub) Run() {
+	expire := time.NewTicker(time.Minute)
+	defer expire.Stop()
+
 	for {
 		select {
+		case <-expire.C:
+			h.expireHistory()
+
 		case <-h.done:
 			// Close all client connections
 			h.mu.Lock()
 			for client := range h.clients {
-				close(client.Send)
-				client.Conn.Close()
+				client.closeSend()
+				if client.Conn != nil {
+					client.Conn.Close()
+				}
 			}
 			h.mu.Unlock()
 			return
@@ -117,24 +315,28 @@ func (h *Hub) Run() {
 
 		case client := <-h.unregister:
 			h.mu.Lock()
-			if _, ok := h.clients[client]; ok {
-				// Remove from all topics
-				for topic := range client.Topics {
-					if clients, exists := h.topics[topic]; exists {
-						delete(clients, client)
-						if len(clients) == 0 {
-							delete(h.topics, topic)
-						}
-					}
-				}
-				delete(h.clients, client)
-				close(client.Send)
-			}
+			h.removeClient(client)
 			h.mu.Unlock()
 			h.logger.Debug("Client unregistered", zap.String("client_id", client.ID.String()))
 
 		case sub := <-h.subscribe:
 			h.mu.Lock()
+			if _, ok := h.clients[sub.Client]; !ok {
+				// Already disconnected, e.g. as too slow
+				h.mu.Unlock()
+				close(sub.done)
+				continue
+			}
+			if history, ok := h.history[sub.Topic]; ok && !h.topics[sub.Topic][sub.Client] {
+			replay:
+				for _, payload := range history.ordered() {
+					select {
+					case sub.Client.Send <- payload:
+					default:
+						break replay
+					}
+				}
+			}
 			if _, exists := h.topics[sub.Topic]; !exists {
 				h.topics[sub.Topic] = make(map[*Client]bool)
 			}
@@ -143,6 +345,7 @@ func (h *Hub) Run() {
 			sub.Client.Topics[sub.Topic] = true
 			sub.Client.topicsMu.Unlock()
 			h.mu.Unlock()
+			close(sub.done)
 			h.logger.Debug("Client subscribed to topic",
 				zap.String("client_id", sub.Client.ID.String()),
 				zap.String("topic", sub.Topic),
@@ -160,22 +363,67 @@ func (h *Hub) Run() {
 			delete(sub.Client.Topics, sub.Topic)
 			sub.Client.topicsMu.Unlock()
 			h.mu.Unlock()
+			close(sub.done)
 
 		case message := <-h.broadcast:
-			h.mu.RLock()
-			clients := h.topics[message.Topic]
-			h.mu.RUnlock()
-
-			for client := range clients {
-				select {
-				case client.Send <- message.Payload:
-				default:
-					// Client's send buffer is full, remove them
-					h.unregister <- client
+			if h.replays(message.Topic) {
+				history, ok := h.history[message.Topic]
+				if !ok {
+					history = &topicHistory{}
+					h.history[message.Topic] = history
 				}
+				history.add(message.Payload, h.replaySize)
+			}
+
+			h.deliver(message)
+		}
+	}
+}
+
+// deliver sends a message to the topic's subscribers without blocking on slow clients.
+// A client whose buffer is full misses the message; one that keeps missing them is
+// disconnected so it can reconnect and catch up, e.g. with since= on log streams.
+func (h *Hub) deliver(message *Message) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for client := range h.topics[message.Topic] {
+		select {
+		case client.Send <- message.Payload:
+			client.drops = 0
+		default:
+			client.drops++
+			client.dropped.Add(1)
+			h.droppedMessages.Add(1)
+			if client.drops >= maxConsecutiveDrops {
+				h.logger.Warn("Disconnecting slow WebSocket client",
+					zap.String("client_id", client.ID.String()),
+					zap.Int64("dropped", client.dropped.Load()),
+				)
+				client.closeReason = "client too slow"
+				h.slowDisconnects.Add(1)
+				h.removeClient(client)
+			}
+		}
+	}
+}
+
+// removeClient drops a client from the hub and its topics and closes its send channel,
+// which makes WritePump close the connection; the caller holds h.mu
+func (h *Hub) removeClient(client *Client) {
+	if _, ok := h.clients[client]; !ok {
+		return
+	}
+	for topic := range client.Topics {
+		if clients, exists := h.topics[topic]; exists {
+			delete(clients, client)
+			if len(clients) == 0 {
+				delete(h.topics, topic)
 			}
 		}
 	}
+	delete(h.clients, client)
+	client.closeSend()
 }
 
 // Stop gracefully stops the hub
@@ -183,28 +431,88 @@ func (h *Hub) Stop() {
 	close(h.done)
 }
 
-// Broadcast sends a message to all clients subscribed to a topic
+// SetBridge relays broadcasts through a bridge so clients of every instance receive them.
+// Call before anything is broadcast.
+func (h *Hub) SetBridge(bridge Bridge) {
+	h.bridge = bridge
+}
+
+// Broadcast sends a message to all clients subscribed to a topic, on every bridged
+// instance. The payload is embedded in the envelope as JSON when valid, else as a string.
 func (h *Hub) Broadcast(topic string, messageType string, payload []byte) {
+	h.broadcastEnvelope(topic, messageType, payload)
+}
+
+// Publish marshals a payload and broadcasts it to a topic
+func (h *Hub) Publish(topic, messageType string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s message: %w", messageType, err)
+	}
+	h.broadcastEnvelope(topic, messageType, json.RawMessage(data))
+	return nil
+}
+
+// PublishLocal marshals a payload and sends it to this instance's subscribers only, for
+// producers that run on every instance with subscribers, such as container log streams
+func (h *Hub) PublishLocal(topic, messageType string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s message: %w", messageType, err)
+	}
+	envelope, err := NewEnvelope(topic, messageType, json.RawMessage(data))
+	if err != nil {
+		return err
+	}
+	h.BroadcastLocal(topic, messageType, envelope)
+	return nil
+}
+
+// BroadcastLocal sends an encoded envelope to this instance's subscribers only; used for
+// messages relayed from other instances
+func (h *Hub) BroadcastLocal(topic string, messageType string, envelope []byte) {
 	h.broadcast <- &Message{
 		Topic:   topic,
 		Type:    messageType,
-		Payload: payload,
+		Payload: envelope,
 	}
 }
 
 // BroadcastString sends a string message to all clients subscribed to a topic
 func (h *Hub) BroadcastString(topic, messageType, payload string) {
-	h.Broadcast(topic, messageType, []byte(payload))
+	h.broadcastEnvelope(topic, messageType, payload)
+}
+
+// broadcastEnvelope wraps a payload in an envelope and sends it locally and to the bridge
+func (h *Hub) broadcastEnvelope(topic, messageType string, payload interface{}) {
+	envelope, err := NewEnvelope(topic, messageType, payload)
+	if err != nil {
+		h.logger.Warn("Failed to encode broadcast", zap.String("topic", topic), zap.Error(err))
+		return
+	}
+	message := &Message{
+		Topic:   topic,
+		Type:    messageType,
+		Payload: envelope,
+	}
+	h.broadcast <- message
+	if h.bridge != nil {
+		h.bridge.Publish(message)
+	}
 }
 
-// Subscribe subscribes a client to a topic
+// Subscribe subscribes a client to a topic, returning once the subscription is active
 func (h *Hub) Subscribe(client *Client, topic string) {
-	h.subscribe <- &Subscription{Client: client, Topic: topic}
+	sub := &Subscription{Client: client, Topic: topic, done: make(chan struct{})}
+	h.subscribe <- sub
+	<-sub.done
 }
 
-// Unsubscribe unsubscribes a client from a topic
+// Unsubscribe unsubscribes a client from a topic, returning once it no longer receives it
 func (h *Hub) Unsubscribe(client *Client, topic string) {
-	h.unsubscribe <- &Subscription{Client: client, Topic: topic}
+	sub := &Subscription{Client: client, Topic: topic, done: make(chan struct{})}
+	h.unsubscribe <- sub
+	<-sub.done
 }
 
 // Register registers a new client
@@ -217,6 +525,18 @@ func (h *Hub) Unregister(client *Client) {
 	h.unregister <- client
 }
 
+// Stats returns client counts and backpressure counters
+func (h *Hub) Stats() HubStats {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return HubStats{
+		Clients:                 len(h.clients),
+		Topics:                  len(h.topics),
+		DroppedMessages:         h.droppedMessages.Load(),
+		SlowClientsDisconnected: h.slowDisconnects.Load(),
+	}
+}
+
 // ClientCount returns the number of connected clients
 func (h *Hub) ClientCount() int {
 	h.mu.RLock()
@@ -224,7 +544,24 @@ func (h *Hub) ClientCount() int {
 	return len(h.clients)
 }
 
-// TopicClientCount returns the number of clients subscribed to a topic
+// HasListeners reports whether a broadcast to the topic may reach anyone. Bridged hubs
+// can't see other instances' clients, so they always report true.
+func (h *Hub) HasListeners(topic string) bool {
+	return h.bridge != nil || h.TopicClientCount(topic) > 0
+}
+
+// Topics returns the topics with subscribers on this instance
+func (h *Hub) Topics() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	topics := make([]string, 0, len(h.topics))
+	for topic := range h.topics {
+		topics = append(topics, topic)
+	}
+	return topics
+}
+
+// TopicClientCount returns the number of clients subscribed to a topic on this instance
 func (h *Hub) TopicClientCount(topic string) int {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
@@ -245,6 +582,129 @@ func NewClient(hub *Hub, conn *websocket.Conn) *Client {
 	}
 }
 
+// NewStreamClient creates a client without a WebSocket connection for transports that
+// read Send themselves, such as Server-Sent Events
+func NewStreamClient(hub *Hub) *Client {
+	return NewClient(hub, nil)
+}
+
+// SetAuthorizer enables client-driven subscriptions, checking each requested topic
+func (c *Client) SetAuthorizer(authorize TopicAuthorizer) {
+	c.authorize = authorize
+}
+
+// IsSubscribed reports whether the client is subscribed to a topic
+func (c *Client) IsSubscribed(topic string) bool {
+	c.topicsMu.RLock()
+	defer c.topicsMu.RUnlock()
+	return c.Topics[topic]
+}
+
+// topicCount returns the number of topics the client is subscribed to
+func (c *Client) topicCount() int {
+	c.topicsMu.RLock()
+	defer c.topicsMu.RUnlock()
+	return len(c.Topics)
+}
+
+// closeSend closes the send channel once; called by the hub
+func (c *Client) closeSend() {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	if !c.closed {
+		c.closed = true
+		close(c.Send)
+	}
+}
+
+// closeMessage is the close frame sent when the hub closes the connection
+func (c *Client) closeMessage() []byte {
+	if c.closeReason == "" {
+		return []byte{}
+	}
+	return websocket.FormatCloseMessage(websocket.CloseTryAgainLater, c.closeReason)
+}
+
+// Dropped returns the number of messages the client missed because its buffer was full
+func (c *Client) Dropped() int64 {
+	return c.dropped.Load()
+}
+
+// reply queues a control message for the client, dropping it if the buffer is full
+func (c *Client) reply(messageType, topic string, payload ControlPayload) {
+	var body interface{}
+	if payload != (ControlPayload{}) {
+		body = payload
+	}
+	data, err := NewEnvelope(topic, messageType, body)
+	if err != nil {
+		return
+	}
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	if c.closed {
+		return
+	}
+	select {
+	case c.Send <- data:
+	default:
+	}
+}
+
+// handleMessage processes a control message from the client
+func (c *Client) handleMessage(data []byte) {
+	var msg ClientMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		c.reply("error", "", ControlPayload{Error: "invalid message: expected JSON with an action"})
+		return
+	}
+
+	fail := func(reason string) {
+		c.reply("error", msg.Topic, ControlPayload{ID: msg.ID, Error: reason})
+	}
+
+	switch msg.Action {
+	case ActionPing:
+		c.reply("pong", "", ControlPayload{ID: msg.ID})
+
+	case ActionSubscribe:
+		if msg.Topic == "" {
+			fail("topic is required")
+			return
+		}
+		if c.authorize == nil {
+			fail(ErrSubscriptionsDisabled.Error())
+			return
+		}
+		if c.IsSubscribed(msg.Topic) {
+			c.reply("subscribed", msg.Topic, ControlPayload{ID: msg.ID})
+			return
+		}
+		if c.topicCount() >= maxClientTopics {
+			fail("too many subscriptions")
+			return
+		}
+		if err := c.authorize(msg.Topic); err != nil {
+			fail(err.Error())
+			return
+		}
+		// Acknowledge first so replayed messages follow the acknowledgement
+		c.reply("subscribed", msg.Topic, ControlPayload{ID: msg.ID})
+		c.Hub.Subscribe(c, msg.Topic)
+
+	case ActionUnsubscribe:
+		if !c.IsSubscribed(msg.Topic) {
+			fail("not subscribed")
+			return
+		}
+		c.Hub.Unsubscribe(c, msg.Topic)
+		c.reply("unsubscribed", msg.Topic, ControlPayload{ID: msg.ID})
+
+	default:
+		fail("unknown action " + msg.Action)
+	}
+}
+
 // ReadPump pumps messages from the WebSocket connection to the hub
 func (c *Client) ReadPump() {
 	defer func() {
@@ -268,9 +728,7 @@ func (c *Client) ReadPump() {
 			break
 		}
 
-		// Handle subscription messages
-		// Expected format: {"action": "subscribe", "topic": "build:xxx"}
-		_ = message // Process subscription requests here if needed
+		c.handleMessage(message)
 	}
 }
 
@@ -288,7 +746,7 @@ func (c *Client) WritePump() {
 			c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if !ok {
 				// Hub closed the channel
-				c.Conn.WriteMessage(websocket.CloseMessage, []byte{})
+				c.Conn.WriteMessage(websocket.CloseMessage, c.closeMessage())
 				return
 			}
 